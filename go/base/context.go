@@ -7,12 +7,17 @@ package base
 
 import (
 	"fmt"
+	"hash/crc32"
 	"math"
+	"net/http"
 	"os"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"text/template"
 	"time"
 
 	uuid "github.com/satori/go.uuid"
@@ -41,28 +46,56 @@ const (
 	CutOverTwoStep
 )
 
+// CutOverFailureReason classifies a failed cut-over attempt
+type CutOverFailureReason string
+
+const (
+	CutOverLockTimeoutFailure      CutOverFailureReason = "lock timeout"
+	CutOverSessionsNotFoundFailure CutOverFailureReason = "sessions not found"
+	CutOverRenameBlockedFailure    CutOverFailureReason = "rename blocked"
+	CutOverUnclassifiedFailure     CutOverFailureReason = "error"
+)
+
+// CutOverRowCountMode is how the row count check ahead of cut-over counts rows, per --cut-over-row-count-mode
+type CutOverRowCountMode string
+
+const (
+	CutOverRowCountEstimate CutOverRowCountMode = "estimate"
+	CutOverRowCountExact    CutOverRowCountMode = "exact"
+)
+
 type ThrottleReasonHint string
 
 const (
 	NoThrottleReasonHint                 ThrottleReasonHint = "NoThrottleReasonHint"
 	UserCommandThrottleReasonHint        ThrottleReasonHint = "UserCommandThrottleReasonHint"
 	LeavingHibernationThrottleReasonHint ThrottleReasonHint = "LeavingHibernationThrottleReasonHint"
+	ScheduleThrottleReasonHint           ThrottleReasonHint = "ScheduleThrottleReasonHint"
 )
 
 const (
 	HTTPStatusOK       = 200
 	MaxEventsBatchSize = 1000
 	ETAUnknown         = math.MinInt64
+
+	defaultMaxAllowedPacket = 4 * 1024 * 1024
 )
 
 var (
-	envVariableRegexp = regexp.MustCompile("[$][{](.*)[}]")
+	envVariableRegexp            = regexp.MustCompile("[$][{](.*)[}]")
+	tableNamePatternTokenRegexp  = regexp.MustCompile("[{][^{}]*[}]")
+	tableNamePatternTokenLengths = map[string]int{"{table}": 0, "{timestamp}": 14, "{date}": 8}
 )
 
 type ThrottleCheckResult struct {
 	ShouldThrottle bool
 	Reason         string
 	ReasonHint     ThrottleReasonHint
+	// Criterion identifies what throttles, free of measured values, for throttle accounting
+	Criterion string
+	// OtherCriteria are further criteria of the same check throttling alongside Criterion, e.g. several
+	// max-load variables, or several lagging replicas
+	OtherCriteria []string
 }
 
 func NewThrottleCheckResult(throttle bool, reason string, reasonHint ThrottleReasonHint) *ThrottleCheckResult {
@@ -73,13 +106,37 @@ func NewThrottleCheckResult(throttle bool, reason string, reasonHint ThrottleRea
 	}
 }
 
+// AllCriteria returns Criterion followed by OtherCriteria
+func (this *ThrottleCheckResult) AllCriteria() []string {
+	return append([]string{this.Criterion}, this.OtherCriteria...)
+}
+
+// StreamerThroughput describes binlog streaming rates over the most recent sampling interval
+type StreamerThroughput struct {
+	EventsPerSecond float64
+	BytesPerSecond  float64
+	RowsPerSecond   float64
+	// BlockedRatio is the fraction of time the streamer was blocked on pushing rows down the pipeline
+	BlockedRatio float64
+}
+
+type streamerCountersSample struct {
+	sampleTime   time.Time
+	events       int64
+	bytes        int64
+	rows         int64
+	blockedNanos int64
+}
+
 // MigrationContext has the general, global state of migration. It is used by
 // all components throughout the migration process.
 type MigrationContext struct {
 	Uuid string
 
 	DatabaseName          string
+	TargetDatabaseName    string
 	OriginalTableName     string
+	NewTableName          string
 	AlterStatement        string
 	AlterStatementOptions string // anything following the 'ALTER TABLE [schema.]table' from AlterStatement
 
@@ -93,17 +150,42 @@ type MigrationContext struct {
 	AssumeRBR                bool
 	SkipForeignKeyChecks     bool
 	SkipStrictMode           bool
+	SkipAutoIncrementSync    bool
 	AllowZeroInDate          bool
+	ZeroDateRewrite          *sql.ZeroDateRewrite
+	SQLMode                  string
+	ConnectionTimeZone       string
+	SessionVariables         []mysql.SessionVariable
+	CopyConflictStrategy     sql.CopyConflictStrategy
+	CountRowsExamined        bool
+	CopyOrder                sql.CopyOrder
+	RowFilter                string
+	ChunkIndex               string
+	ChunkChecksumSampleRatio float64
+	ChunkChecksumWarnOnly    bool
 	NullableUniqueKeyAllowed bool
+	SurrogateKey             bool
+	SurrogateKeyColumnName   string
+	SurrogateKeyInvisible    bool
+	ImSureAboutDuplicates    bool
 	ApproveRenamedColumns    bool
+	ApproveCollationRisks    bool
 	SkipRenamedColumns       bool
 	IsTungsten               bool
 	DiscardForeignKeys       bool
+	PreserveForeignKeys      bool
 	AliyunRDS                bool
 	GoogleCloudPlatform      bool
 	AzureMySQL               bool
+	AuroraLagThrottle        bool
+	AuroraVersion            string
 	AttemptInstantDDL        bool
 
+	IncludeTriggers              bool
+	AllowSelfReferencingTriggers bool
+	AllowDroppedCheckConstraints bool
+	AllowPartialJSONUpdates      bool
+
 	config            ContextConfig
 	configMutex       *sync.Mutex
 	ConfigFile        string
@@ -120,58 +202,151 @@ type MigrationContext struct {
 	HeartbeatIntervalMilliseconds       int64
 	defaultNumRetries                   int64
 	ChunkSize                           int64
+	AdaptiveChunkSize                   bool
+	chunkLatencyTarget                  int64
+	chunkSizeMin                        int64
+	chunkSizeMax                        int64
 	niceRatio                           float64
+	CopyRateLimiter                     *RateLimiter
+	ThrottleRampUp                      *ThrottleRampUp
+	DMLApplyRateLimiter                 *RateLimiter
 	MaxLagMillisecondsThrottleThreshold int64
+	MaxHistoryListLength                int64
+	MinFreeDiskSpace                    *DiskSpaceThreshold
+	CriticalFreeDiskSpace               *DiskSpaceThreshold
+	DiskSpaceQuery                      string
 	throttleControlReplicaKeys          *mysql.InstanceKeyMap
+	throttleControlReplicaMaxLags       map[mysql.InstanceKey]time.Duration
+	ignoredThrottleControlReplicas      map[mysql.InstanceKey]time.Time
+	SkipLagCheckIfDelayed               bool
+	CheckLoadOnReplicas                 bool
+	DiscoverThrottleReplicas            bool
+	DiscoverThrottleReplicasInterval    time.Duration
+	DiscoverThrottleReplicasDropAfter   int64
+	discoverThrottleReplicasExclude     []string
+	ThrottleLagCheckInterval            time.Duration
+	ThrottleLoadCheckInterval           time.Duration
+	ThrottleHTTPCheckInterval           time.Duration
+	ThrottleFlagFileCheckInterval       time.Duration
+	defaultReplicaLagSource             *mysql.ReplicaLagSource
+	replicaLagSources                   map[mysql.InstanceKey]*mysql.ReplicaLagSource
 	ThrottleFlagFile                    string
+	ThrottleScheduleLocation            *time.Location
+	ThrottleScheduleApplyEvents         bool
+	throttleSchedule                    ThrottleSchedule
 	ThrottleAdditionalFlagFile          string
+	ThrottleFlagFileDir                 string
 	throttleQuery                       string
 	throttleHTTP                        string
 	IgnoreHTTPErrors                    bool
+	ThrottleHTTPMethod                  string
+	ThrottleHTTPHeaders                 http.Header
+	ThrottleHTTPBodyTemplate            *template.Template
+	ThrottleHTTPUnreachablePolicy       ThrottleHTTPUnreachablePolicy
+	ThrottleHTTPReasonFromBody          bool
 	ThrottleCommandedByUser             int64
 	HibernateUntil                      int64
 	maxLoad                             LoadMap
 	criticalLoad                        LoadMap
 	CriticalLoadIntervalMilliseconds    int64
 	CriticalLoadHibernateSeconds        int64
+	CriticalLoadEscalation              *CriticalLoadEscalation
 	PostponeCutOverFlagFile             string
+	CutOverWindow                       *CutOverWindow
 	CutOverLockTimeoutSeconds           int64
+	CutOverLockWaitTimeoutSeconds       int64 // per --cut-over-lock-wait-timeout. 0 derives from CutOverLockTimeoutSeconds
+	CutOverRenameTimeoutSeconds         int64 // per --cut-over-rename-timeout. 0 derives from CutOverLockTimeoutSeconds
 	CutOverExponentialBackoff           bool
+	CutOverRetryAttempts                int64
+	CutOverRetryInterval                time.Duration
+	PostponeCutOverOnFailure            bool
+	CutOverRehearsals                   int64
+	AutoUnpostponeCriteria              UnpostponeCriteria
+	AutoUnpostponeStableDuration        time.Duration
+	CutOverRowCountCheck                bool // per --cut-over-max-row-delta
+	CutOverMaxRowDelta                  int64
+	CutOverMaxRowDeltaPct               float64
+	CutOverRowCountMode                 CutOverRowCountMode
+	CutOverKillBlockingQueriesAfter     time.Duration
+	CutOverKillBlockingQueriesDryRun    bool
 	ExponentialBackoffMaxInterval       int64
+	StreamerReconnectRetries            int64
+	StreamerReconnectIntervalSeconds    int64
+	BinlogSyncerHeartbeatPeriodSeconds  int64
+	BinlogSyncerReadTimeoutSeconds      int64
+	BinlogEventsBufferSize              int64
+	BinlogEventsBlockedWarningSeconds   int64
+	BinlogEventsBufferThrottleDepth     int64
+	MaxDMLBacklog                       int64
+	MaxEventsLag                        time.Duration
+	BinlogStallTimeoutSeconds           int64
+	AbortOnBinlogStall                  bool
+	StreamerFailoverKeys                []mysql.InstanceKey
+	StartBinlogCoordinates              *mysql.BinlogCoordinates
+	StartGtidSet                        string
+	ResumeBinlogCoordinates             *mysql.BinlogCoordinates
+	AllowBinlogGapRecovery              bool
+	BinlogFile                          string
+	WarnOnBinlogChecksumMismatch        bool
+	WarnOnConcurrentDDL                 bool
+	WarnOnSchemaDrift                   bool
+	AuditDMLFile                        string
 	ForceNamedCutOverCommand            bool
 	ForceNamedPanicCommand              bool
 	PanicFlagFile                       string
+	PanicDiagnosticsDir                 string
+	SkipUndecodableBinlogEvents         bool
 	HooksPath                           string
 	HooksHintMessage                    string
 	HooksHintOwner                      string
 	HooksHintToken                      string
 	HooksStatusIntervalSec              int64
+	HooksBeforeCutOverTimeout           time.Duration
 
 	DropServeSocket bool
 	ServeSocketFile string
 	ServeTCPPort    int64
 
+	PrometheusListenAddr string
+
 	Noop                         bool
 	TestOnReplica                bool
 	MigrateOnReplica             bool
 	TestOnReplicaSkipReplicaStop bool
 	OkToDropTable                bool
+	DropOldTableAfter            time.Duration
+	DropOldTableDetach           bool
+	DropOldTableScratchDatabase  string
+	Cleanup                      bool
 	InitiallyDropOldTable        bool
 	InitiallyDropGhostTable      bool
+	Resume                       bool
+	CheckpointIntervalChunks     int64
 	TimestampOldTable            bool // Should old table name include a timestamp
+	OldTableNamePattern          string
+	GhostTableNamePattern        string
 	CutOverType                  CutOver
 	ReplicaServerId              uint
+	ReplicaServerIdRangeMin      uint
+	ReplicaServerIdRangeMax      uint
+	MySQLFlavor                  string
 
 	Hostname                               string
 	AssumeMasterHostname                   string
 	ApplierTimeZone                        string
+	ApplierSystemTimeZone                  string
+	ApplierMaxAllowedPacket                int64
+	ApplierSQLMode                         string
 	TableEngine                            string
+	TableAvgRowLength                      int64
 	RowsEstimate                           int64
 	RowsDeltaEstimate                      int64
+	MaxObservedRowSize                     int64
 	UsedRowsEstimateMethod                 RowsEstimateMethod
 	HasSuperPrivilege                      bool
 	OriginalBinlogFormat                   string
 	OriginalBinlogRowImage                 string
+	IsGTIDModeEnabled                      bool
 	InspectorConnectionConfig              *mysql.ConnectionConfig
 	InspectorMySQLVersion                  string
 	ApplierConnectionConfig                *mysql.ConnectionConfig
@@ -193,16 +368,65 @@ type MigrationContext struct {
 	ThrottleHTTPStatusCode                 int64
 	ThrottleHTTPTimeoutMillis              int64
 	controlReplicasLagResult               mysql.ReplicationLagResult
+	controlReplicasLagResults              []mysql.ReplicationLagResult
+	CurrentHistoryListLength               int64
+	CurrentFreeDiskSpace                   int64
+	CurrentTotalDiskSpace                  int64
 	TotalRowsCopied                        int64
+	TotalRowsExamined                      int64
+	ChunkChecksumsVerified                 int64
+	ChunkChecksumMismatches                int64
 	TotalDMLEventsApplied                  int64
+	DMLInsertsApplied                      int64
+	DMLUpdatesApplied                      int64
+	DMLDeletesApplied                      int64
+	DMLEventsApplyNanos                    int64
+	DMLApplyTransactions                   int64
+	DMLApplyBytes                          int64
+	CompactUpdatesBytesSaved               int64
+	DMLPreparedStatementHits               int64
+	DMLPreparedStatementMisses             int64
+	DMLRetries                             int64
+	StreamedRowsEvents                     int64
+	SkippedRowsEvents                      int64
+	StreamerEventsRead                     int64
+	StreamerBytesRead                      int64
+	StreamerRowsEmitted                    int64
+	StreamerBlockedNanos                   int64
+	StreamerBlockedSince                   int64
+	StreamerProgressTime                   int64
+	BinlogEventsBufferHighWaterMark        int64
+	AuditDMLDroppedLines                   int64
 	DMLBatchSize                           int64
+	DMLBatchMaxBytes                       int64
+	DMLPreparedStatementsCacheSize         int64
+	DMLRetryAttempts                       int64
+	MaxPacketBudget                        int64
+	DMLApplyConcurrency                    int64
+	CompactUpdates                         bool
+	ApplierMaxOpenConns                    int64
+	ApplierMaxIdleConns                    int64
+	ApplierConnMaxLifetime                 time.Duration
+	InspectorMaxOpenConns                  int64
+	InspectorMaxIdleConns                  int64
+	InspectorConnMaxLifetime               time.Duration
 	isThrottled                            bool
 	throttleReason                         string
 	throttleReasonHint                     ThrottleReasonHint
-	throttleGeneralCheckResult             ThrottleCheckResult
+	totalThrottledDuration                 time.Duration
+	throttleDurations                      map[string]time.Duration
 	throttleMutex                          *sync.Mutex
 	throttleHTTPMutex                      *sync.Mutex
 	IsPostponingCutOver                    int64
+	IsWaitingForCutOverWindow              int64
+	CutOverAttempts                        int64
+	CutOverVetoes                          int64
+	AutoUnpostponeCanceledFlag             int64
+	SkipCutOverRowCountCheckFlag           int64
+	CutOverKilledBlockingQueries           int64
+	CutOverState                           *CutOverState
+	IsRehearsingCutOver                    int64
+	cutOverFailures                        []CutOverFailureReason
 	CountingRowsFlag                       int64
 	AllEventsUpToLockProcessedInjectedFlag int64
 	CleanupImminentFlag                    int64
@@ -215,23 +439,44 @@ type MigrationContext struct {
 	OriginalTableColumns             *sql.ColumnList
 	OriginalTableVirtualColumns      *sql.ColumnList
 	OriginalTableUniqueKeys          [](*sql.UniqueKey)
+	OriginalTableForeignKeys         [](*sql.ForeignKey)
+	OriginalTableTriggers            [](*sql.Trigger)
+	OriginalTablePartitions          [](*sql.Partition)
 	OriginalTableAutoIncrement       uint64
+	OriginalTableHasGIPK             bool
 	GhostTableColumns                *sql.ColumnList
 	GhostTableVirtualColumns         *sql.ColumnList
 	GhostTableUniqueKeys             [](*sql.UniqueKey)
 	UniqueKey                        *sql.UniqueKey
+	UsingSurrogateKey                bool // No shared unique key; rows are matched by all shared columns, see --surrogate-key
 	SharedColumns                    *sql.ColumnList
 	ColumnRenameMap                  map[string]string
+	ExplicitColumnRenameMap          map[string]string // per --rename-column
 	DroppedColumnsMap                map[string]bool
+	ColumnVisibilityMap              map[string]bool // columns the ALTER explicitly makes visible (false) or invisible (true)
 	MappedSharedColumns              *sql.ColumnList
 	MigrationRangeMinValues          *sql.ColumnValues
 	MigrationRangeMaxValues          *sql.ColumnValues
 	Iteration                        int64
+	// The iterated chunk's start and end values, in copy order: with --copy-order=desc, the min values are the
+	// chunk's upper bound, and the max values its lower bound
 	MigrationIterationRangeMinValues *sql.ColumnValues
 	MigrationIterationRangeMaxValues *sql.ColumnValues
+	MigrationRangePartition          *sql.Partition
+	MigrationRangeStartIteration     int64
 	ForceTmpTableName                string
 
+	OriginalTableCheckConstraints [](*sql.CheckConstraint)
+	GhostTableCheckConstraints    [](*sql.CheckConstraint)
+	// Ghost table CHECK constraint names to rename, onto the original constraints' ghost names
+	GhostCheckConstraintRenames map[string]string
+	// CHECK constraints newly enforced by the ghost table, with their clauses in terms of the original table's
+	// columns: row copy verifies the copied rows satisfy them
+	NewCheckConstraints [](*sql.CheckConstraint)
+
 	recentBinlogCoordinates mysql.BinlogCoordinates
+	streamerCountersSample  streamerCountersSample
+	streamerThroughput      StreamerThroughput
 
 	Log Logger
 }
@@ -271,26 +516,101 @@ func NewMigrationContext() *MigrationContext {
 		Uuid:                                uuid.NewV4().String(),
 		defaultNumRetries:                   60,
 		ChunkSize:                           1000,
+		chunkLatencyTarget:                  int64(500 * time.Millisecond),
+		chunkSizeMin:                        10,
+		chunkSizeMax:                        100000,
+		CopyRateLimiter:                     NewRateLimiter(0),
+		ThrottleRampUp:                      NewThrottleRampUp(0, 30*time.Second),
+		CriticalLoadEscalation:              NewCriticalLoadEscalation(0, 0, 0),
+		ThrottleScheduleLocation:            time.Local,
+		ThrottleScheduleApplyEvents:         true,
+		DMLApplyRateLimiter:                 NewRateLimiter(0),
 		InspectorConnectionConfig:           mysql.NewConnectionConfig(),
 		ApplierConnectionConfig:             mysql.NewConnectionConfig(),
+		ThrottleHTTPMethod:                  http.MethodHead,
+		ThrottleHTTPHeaders:                 http.Header{},
+		ThrottleHTTPUnreachablePolicy:       ThrottleHTTPUnreachableThrottle,
 		MaxLagMillisecondsThrottleThreshold: 1500,
 		CutOverLockTimeoutSeconds:           3,
+		CutOverRetryInterval:                time.Second,
+		AutoUnpostponeStableDuration:        5 * time.Minute,
+		HooksBeforeCutOverTimeout:           time.Minute,
+		CopyConflictStrategy:                sql.IgnoreCopyConflictStrategy,
+		CopyOrder:                           sql.AscendingCopyOrder,
+		ConnectionTimeZone:                  mysql.DefaultTimeZone,
+		SurrogateKeyColumnName:              "gh_ost_rowid",
+		StreamerReconnectIntervalSeconds:    5,
+		BinlogSyncerHeartbeatPeriodSeconds:  30,
+		BinlogSyncerReadTimeoutSeconds:      90,
+		BinlogEventsBufferSize:              1,
+		BinlogEventsBlockedWarningSeconds:   10,
 		DMLBatchSize:                        10,
+		DMLRetryAttempts:                    5,
+		DMLApplyConcurrency:                 1,
 		etaNanoseonds:                       ETAUnknown,
 		maxLoad:                             NewLoadMap(),
 		criticalLoad:                        NewLoadMap(),
 		throttleMutex:                       &sync.Mutex{},
+		CutOverState:                        NewCutOverState(),
 		throttleHTTPMutex:                   &sync.Mutex{},
 		throttleControlReplicaKeys:          mysql.NewInstanceKeyMap(),
+		throttleControlReplicaMaxLags:       make(map[mysql.InstanceKey]time.Duration),
+		ignoredThrottleControlReplicas:      make(map[mysql.InstanceKey]time.Time),
+		throttleDurations:                   make(map[string]time.Duration),
+		defaultReplicaLagSource:             &mysql.ReplicaLagSource{Type: mysql.HeartbeatReplicaLagSource},
+		replicaLagSources:                   make(map[mysql.InstanceKey]*mysql.ReplicaLagSource),
 		configMutex:                         &sync.Mutex{},
 		pointOfInterestTimeMutex:            &sync.Mutex{},
 		lastHeartbeatOnChangelogMutex:       &sync.Mutex{},
 		ColumnRenameMap:                     make(map[string]string),
+		ExplicitColumnRenameMap:             make(map[string]string),
 		PanicAbort:                          make(chan error),
 		Log:                                 NewDefaultLogger(),
 	}
 }
 
+// ValidateTableNamePattern validates an --old-table-name-pattern or --ghost-table-name-pattern value, such as
+// _{table}_del_{timestamp}. The pattern must include {table} exactly once, and leave room for a hash
+// truncated table name.
+func ValidateTableNamePattern(pattern string) error {
+	if strings.Count(pattern, "{table}") != 1 {
+		return fmt.Errorf("Invalid table name pattern: %s. Must include {table} exactly once", pattern)
+	}
+	for _, token := range tableNamePatternTokenRegexp.FindAllString(pattern, -1) {
+		if _, ok := tableNamePatternTokenLengths[token]; !ok {
+			return fmt.Errorf("Invalid table name pattern: %s. Unknown token %s; supported tokens are {table}, {timestamp} and {date}", pattern, token)
+		}
+	}
+	if strings.ContainsAny(tableNamePatternTokenRegexp.ReplaceAllString(pattern, ""), "{}`") {
+		return fmt.Errorf("Invalid table name pattern: %s", pattern)
+	}
+	length := len(pattern)
+	for token, tokenLength := range tableNamePatternTokenLengths {
+		length += strings.Count(pattern, token) * (tokenLength - len(token))
+	}
+	// A table name too long is truncated down to as little as an underscore and an 8 character hash
+	if length+9 > mysql.MaxTableNameLength {
+		return fmt.Errorf("Invalid table name pattern: %s. Leaves no room for the table name within %d characters", pattern, mysql.MaxTableNameLength)
+	}
+	return nil
+}
+
+// resolveTableNamePattern resolves a validated table name pattern for given table name. Should the resulting name
+// exceed the maximum table name length, the table name is truncated, and suffixed with a hash of the full name,
+// keeping names of distinct tables distinct.
+func (this *MigrationContext) resolveTableNamePattern(pattern string, tableName string) string {
+	t := this.StartTime
+	name := strings.NewReplacer(
+		"{timestamp}", fmt.Sprintf("%d%02d%02d%02d%02d%02d", t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second()),
+		"{date}", fmt.Sprintf("%d%02d%02d", t.Year(), t.Month(), t.Day()),
+	).Replace(pattern)
+	if extraCharacters := len(name) - len("{table}") + len(tableName) - mysql.MaxTableNameLength; extraCharacters > 0 {
+		hash := fmt.Sprintf("%08x", crc32.ChecksumIEEE([]byte(tableName)))
+		tableName = fmt.Sprintf("%s_%s", tableName[0:len(tableName)-extraCharacters-len(hash)-1], hash)
+	}
+	return strings.Replace(name, "{table}", tableName, 1)
+}
+
 func getSafeTableName(baseName string, suffix string) string {
 	name := fmt.Sprintf("_%s_%s", baseName, suffix)
 	if len(name) <= mysql.MaxTableNameLength {
@@ -300,9 +620,54 @@ func getSafeTableName(baseName string, suffix string) string {
 	return fmt.Sprintf("_%s_%s", baseName[0:len(baseName)-extraCharacters], suffix)
 }
 
+// GetTargetDatabaseName returns the schema in which the ghost table, and thus the migrated table, is placed:
+// --target-database, or else the original table's schema
+func (this *MigrationContext) GetTargetDatabaseName() string {
+	if this.TargetDatabaseName != "" {
+		return this.TargetDatabaseName
+	}
+	return this.DatabaseName
+}
+
+// GetMigratedTableName returns the name the migrated table takes at cut-over: --new-table-name, or else the
+// original table's name
+func (this *MigrationContext) GetMigratedTableName() string {
+	if this.NewTableName != "" {
+		return this.NewTableName
+	}
+	return this.OriginalTableName
+}
+
+// IsTableRenamed is `true` when the migrated table takes a name other than the original table's, per --new-table-name
+func (this *MigrationContext) IsTableRenamed() bool {
+	return this.GetMigratedTableName() != this.OriginalTableName
+}
+
+// ValidateNewTableName verifies --new-table-name is a valid table name
+func (this *MigrationContext) ValidateNewTableName() error {
+	if this.NewTableName == "" {
+		return nil
+	}
+	if len(this.NewTableName) > mysql.MaxTableNameLength {
+		return fmt.Errorf("--new-table-name: %s is too long (only %d characters allowed)", this.NewTableName, mysql.MaxTableNameLength)
+	}
+	if strings.TrimSpace(this.NewTableName) != this.NewTableName || strings.ContainsAny(this.NewTableName, "`/\\.\x00") {
+		return fmt.Errorf("--new-table-name: invalid table name %q", this.NewTableName)
+	}
+	return nil
+}
+
+// IsCrossSchemaMigration is `true` when the ghost table is placed in a schema other than the original table's
+func (this *MigrationContext) IsCrossSchemaMigration() bool {
+	return this.GetTargetDatabaseName() != this.DatabaseName
+}
+
 // GetGhostTableName generates the name of ghost table, based on original table name
 // or a given table name
 func (this *MigrationContext) GetGhostTableName() string {
+	if this.GhostTableNamePattern != "" {
+		return this.resolveTableNamePattern(this.GhostTableNamePattern, this.getTmpTableBaseName())
+	}
 	if this.ForceTmpTableName != "" {
 		return getSafeTableName(this.ForceTmpTableName, "gho")
 	} else {
@@ -319,6 +684,9 @@ func (this *MigrationContext) GetOldTableName() string {
 		tableName = this.OriginalTableName
 	}
 
+	if this.OldTableNamePattern != "" {
+		return this.resolveTableNamePattern(this.OldTableNamePattern, tableName)
+	}
 	if this.TimestampOldTable {
 		t := this.StartTime
 		timestamp := fmt.Sprintf("%d%02d%02d%02d%02d%02d",
@@ -329,6 +697,42 @@ func (this *MigrationContext) GetOldTableName() string {
 	return getSafeTableName(tableName, "del")
 }
 
+// GetDDLComment returns the comment marking this migration's DDL statements, naming its ghost table. Concurrent
+// DDL detection tells the migration's own statements apart by it, including from other gh-ost migrations'.
+func (this *MigrationContext) GetDDLComment() string {
+	return fmt.Sprintf("/* gh-ost %s.%s */", sql.EscapeName(this.GetTargetDatabaseName()), sql.EscapeName(this.GetGhostTableName()))
+}
+
+// getTmpTableBaseName returns the table name the ghost, old and changelog table names are based on
+func (this *MigrationContext) getTmpTableBaseName() string {
+	if this.ForceTmpTableName != "" {
+		return this.ForceTmpTableName
+	}
+	return this.OriginalTableName
+}
+
+// ValidateTableNames verifies the original, new, ghost, old and changelog table names are all distinct
+func (this *MigrationContext) ValidateTableNames() error {
+	tableNames := map[string]string{}
+	for _, tableName := range [][2]string{
+		{"original", this.OriginalTableName},
+		{"new", this.NewTableName},
+		{"ghost", this.GetGhostTableName()},
+		{"old", this.GetOldTableName()},
+		{"changelog", this.GetChangelogTableName()},
+	} {
+		if tableName[1] == "" {
+			continue
+		}
+		role, name := tableName[0], strings.ToLower(tableName[1])
+		if conflictingRole, ok := tableNames[name]; ok {
+			return fmt.Errorf("The %s table and the %s table are both named %s", conflictingRole, role, tableName[1])
+		}
+		tableNames[name] = role
+	}
+	return nil
+}
+
 // GetChangelogTableName generates the name of changelog table, based on original table name
 // or a given table name.
 func (this *MigrationContext) GetChangelogTableName() string {
@@ -350,6 +754,21 @@ func (this *MigrationContext) RequiresBinlogFormatChange() bool {
 	return this.OriginalBinlogFormat != "ROW"
 }
 
+// IsMariaDB is `true` when the inspected server is MariaDB, either detected or explicitly provided
+func (this *MigrationContext) IsMariaDB() bool {
+	return strings.ToLower(this.MySQLFlavor) == "mariadb"
+}
+
+// GetConnectionTimeZoneLocation returns the location of the time zone gh-ost sessions run in, which is what
+// binlog TIMESTAMP values are formatted in
+func (this *MigrationContext) GetConnectionTimeZoneLocation() *time.Location {
+	location, err := mysql.ParseTimeZone(this.ConnectionTimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
 // GetApplierHostname is a safe access method to the applier hostname
 func (this *MigrationContext) GetApplierHostname() string {
 	if this.ApplierConnectionConfig == nil {
@@ -385,6 +804,44 @@ func (this *MigrationContext) HasMigrationRange() bool {
 	return this.MigrationRangeMinValues != nil && this.MigrationRangeMaxValues != nil
 }
 
+// IsDescendingCopyOrder tells whether row copy iterates the unique key from its max values down, per --copy-order=desc
+func (this *MigrationContext) IsDescendingCopyOrder() bool {
+	return this.CopyOrder == sql.DescendingCopyOrder
+}
+
+// GetMigrationRangeStartValues returns the unique key values row copy starts from: the min values, or the max
+// values with --copy-order=desc
+func (this *MigrationContext) GetMigrationRangeStartValues() *sql.ColumnValues {
+	if this.IsDescendingCopyOrder() {
+		return this.MigrationRangeMaxValues
+	}
+	return this.MigrationRangeMinValues
+}
+
+// GetMigrationRangeEndValues returns the unique key values row copy ends with: the max values, or the min
+// values with --copy-order=desc
+func (this *MigrationContext) GetMigrationRangeEndValues() *sql.ColumnValues {
+	if this.IsDescendingCopyOrder() {
+		return this.MigrationRangeMinValues
+	}
+	return this.MigrationRangeMaxValues
+}
+
+// GetMigrationRangePartitionName returns the partition row copy iterates, or an empty string
+// when not iterating per partition
+func (this *MigrationContext) GetMigrationRangePartitionName() string {
+	if this.MigrationRangePartition == nil {
+		return ""
+	}
+	return this.MigrationRangePartition.Name
+}
+
+// IsFirstRangeIteration tells us whether the next copy iteration is the first of the migration range,
+// i.e. of the table or of the partition being copied, and should include the range's min values
+func (this *MigrationContext) IsFirstRangeIteration() bool {
+	return this.GetIteration() == atomic.LoadInt64(&this.MigrationRangeStartIteration)
+}
+
 func (this *MigrationContext) SetCutOverLockTimeoutSeconds(timeoutSeconds int64) error {
 	if timeoutSeconds < 1 {
 		return fmt.Errorf("Minimal timeout is 1sec. Timeout remains at %d", this.CutOverLockTimeoutSeconds)
@@ -396,6 +853,77 @@ func (this *MigrationContext) SetCutOverLockTimeoutSeconds(timeoutSeconds int64)
 	return nil
 }
 
+// SetCutOverLockWaitAndRenameTimeouts sets the atomic cut-over's lock_wait_timeout of the session locking the
+// original table and of the session renaming the tables, as per --cut-over-lock-wait-timeout and
+// --cut-over-rename-timeout. A zero timeout derives from --cut-over-lock-timeout-seconds. The RENAME must
+// not outlast the lock.
+func (this *MigrationContext) SetCutOverLockWaitAndRenameTimeouts(lockWaitTimeout, renameTimeout time.Duration) error {
+	toSeconds := func(flagName string, timeout time.Duration) (int64, error) {
+		if timeout == 0 {
+			return 0, nil
+		}
+		if timeout < time.Second || timeout%time.Second != 0 {
+			return 0, fmt.Errorf("--%s must be a whole number of seconds, at least 1s. Got %+v", flagName, timeout)
+		}
+		return int64(timeout / time.Second), nil
+	}
+	lockWaitTimeoutSeconds, err := toSeconds("cut-over-lock-wait-timeout", lockWaitTimeout)
+	if err != nil {
+		return err
+	}
+	renameTimeoutSeconds, err := toSeconds("cut-over-rename-timeout", renameTimeout)
+	if err != nil {
+		return err
+	}
+	this.CutOverLockWaitTimeoutSeconds = lockWaitTimeoutSeconds
+	this.CutOverRenameTimeoutSeconds = renameTimeoutSeconds
+	if this.GetCutOverRenameTimeoutSeconds() > this.GetCutOverLockWaitTimeoutSeconds() {
+		return fmt.Errorf("Cut-over rename timeout (%ds) may not exceed cut-over lock wait timeout (%ds)", this.GetCutOverRenameTimeoutSeconds(), this.GetCutOverLockWaitTimeoutSeconds())
+	}
+	return nil
+}
+
+// GetCutOverLockWaitTimeoutSeconds returns the lock_wait_timeout of the atomic cut-over's session locking the
+// original table, which defaults to twice --cut-over-lock-timeout-seconds
+func (this *MigrationContext) GetCutOverLockWaitTimeoutSeconds() int64 {
+	if this.CutOverLockWaitTimeoutSeconds > 0 {
+		return this.CutOverLockWaitTimeoutSeconds
+	}
+	return this.CutOverLockTimeoutSeconds * 2
+}
+
+// GetCutOverRenameTimeoutSeconds returns the lock_wait_timeout of the atomic cut-over's session renaming the
+// tables, which defaults to --cut-over-lock-timeout-seconds
+func (this *MigrationContext) GetCutOverRenameTimeoutSeconds() int64 {
+	if this.CutOverRenameTimeoutSeconds > 0 {
+		return this.CutOverRenameTimeoutSeconds
+	}
+	return this.CutOverLockTimeoutSeconds
+}
+
+// AddExplicitColumnRename adds a column rename given as old_name:new_name, as per --rename-column.
+// A column may only be renamed once, and onto a column no other column is renamed onto.
+func (this *MigrationContext) AddExplicitColumnRename(rename string) error {
+	tokens := strings.Split(rename, ":")
+	if len(tokens) != 2 || strings.TrimSpace(tokens[0]) == "" || strings.TrimSpace(tokens[1]) == "" {
+		return fmt.Errorf("Invalid column rename: %s. Expected old_name:new_name", rename)
+	}
+	oldName, newName := strings.TrimSpace(tokens[0]), strings.TrimSpace(tokens[1])
+	if strings.EqualFold(oldName, newName) {
+		return fmt.Errorf("Invalid column rename: %s renames a column onto itself", rename)
+	}
+	for explicitOldName, explicitNewName := range this.ExplicitColumnRenameMap {
+		if strings.EqualFold(explicitOldName, oldName) {
+			return fmt.Errorf("Conflicting column renames: %s is renamed to both %s and %s", oldName, explicitNewName, newName)
+		}
+		if strings.EqualFold(explicitNewName, newName) {
+			return fmt.Errorf("Conflicting column renames: both %s and %s are renamed to %s", explicitOldName, oldName, newName)
+		}
+	}
+	this.ExplicitColumnRenameMap[oldName] = newName
+	return nil
+}
+
 func (this *MigrationContext) SetExponentialBackoffMaxInterval(intervalSeconds int64) error {
 	if intervalSeconds < 2 {
 		return fmt.Errorf("Minimal maximum interval is 2sec. Timeout remains at %d", this.ExponentialBackoffMaxInterval)
@@ -419,6 +947,121 @@ func (this *MigrationContext) MaxRetries() int64 {
 	return retries
 }
 
+// CutOverMaxRetries returns the number of cut-over attempts before giving up (or postponing, as per
+// --postpone-cut-over-on-failure), which defaults to the general number of retries
+func (this *MigrationContext) CutOverMaxRetries() int64 {
+	if this.CutOverRetryAttempts > 0 {
+		return this.CutOverRetryAttempts
+	}
+	return this.MaxRetries()
+}
+
+// CutOverRetryWait returns the time to wait following given number of successive failed cut-over
+// attempts. With --cut-over-exponential-backoff the wait doubles with each failure, up to
+// --exponential-backoff-max-interval
+func (this *MigrationContext) CutOverRetryWait(failedAttempts int64) time.Duration {
+	interval := this.CutOverRetryInterval
+	if !this.CutOverExponentialBackoff {
+		return interval
+	}
+	maxInterval := time.Duration(this.ExponentialBackoffMaxInterval) * time.Second
+	for i := int64(1); i < failedAttempts && interval < maxInterval; i++ {
+		interval *= 2
+	}
+	if interval > maxInterval {
+		interval = maxInterval
+	}
+	return interval
+}
+
+// AddCutOverFailure records the reason for a failed cut-over attempt
+func (this *MigrationContext) AddCutOverFailure(reason CutOverFailureReason) {
+	this.throttleMutex.Lock()
+	defer this.throttleMutex.Unlock()
+	this.cutOverFailures = append(this.cutOverFailures, reason)
+}
+
+// GetCutOverFailures returns the reasons for failed cut-over attempts, in order of attempt
+func (this *MigrationContext) GetCutOverFailures() []CutOverFailureReason {
+	this.throttleMutex.Lock()
+	defer this.throttleMutex.Unlock()
+	return append([]CutOverFailureReason{}, this.cutOverFailures...)
+}
+
+// TransitionCutOverPhase moves the cut-over attempt onto given phase, logging the transition. Cut-over
+// rehearsals do not count as attempts, and do not transition.
+func (this *MigrationContext) TransitionCutOverPhase(phase CutOverPhase) {
+	if atomic.LoadInt64(&this.IsRehearsingCutOver) > 0 {
+		return
+	}
+	if previous, elapsed := this.CutOverState.Transition(phase); previous == CutOverPhaseNone {
+		this.Log.Infof("Cut-over phase: %s", phase)
+	} else {
+		this.Log.Infof("Cut-over phase: %s, after %+v in %s", phase, elapsed.Round(time.Millisecond), previous)
+	}
+}
+
+// SetCutOverMaxRowDelta sets the row count difference between the original and ghost tables beyond which
+// cut-over is refused, as per --cut-over-max-row-delta: either a number of rows, or a percentage of the original
+// table's rows, e.g. 0.1%. An empty value disables the check.
+func (this *MigrationContext) SetCutOverMaxRowDelta(maxRowDelta string) error {
+	this.CutOverRowCountCheck = false
+	if maxRowDelta == "" {
+		return nil
+	}
+	if pctValue := strings.TrimSuffix(maxRowDelta, "%"); pctValue != maxRowDelta {
+		pct, err := strconv.ParseFloat(pctValue, 64)
+		if err != nil || pct < 0 || pct > 100 {
+			return fmt.Errorf("--cut-over-max-row-delta: invalid percentage %s. Expected e.g. 0.1%%", maxRowDelta)
+		}
+		this.CutOverMaxRowDelta, this.CutOverMaxRowDeltaPct = 0, pct
+	} else {
+		delta, err := strconv.ParseInt(maxRowDelta, 10, 64)
+		if err != nil || delta < 0 {
+			return fmt.Errorf("--cut-over-max-row-delta: invalid number of rows %s. Expected e.g. 1000, or a percentage, e.g. 0.1%%", maxRowDelta)
+		}
+		this.CutOverMaxRowDelta, this.CutOverMaxRowDeltaPct = delta, 0
+	}
+	this.CutOverRowCountCheck = true
+	return nil
+}
+
+// GetCutOverMaxRowDelta returns --cut-over-max-row-delta as given
+func (this *MigrationContext) GetCutOverMaxRowDelta() string {
+	if this.CutOverMaxRowDeltaPct > 0 {
+		return fmt.Sprintf("%s%%", strconv.FormatFloat(this.CutOverMaxRowDeltaPct, 'f', -1, 64))
+	}
+	return fmt.Sprintf("%d", this.CutOverMaxRowDelta)
+}
+
+// IsCutOverRowCountChecked tells whether cut-over is preceded by the row count check, i.e. --cut-over-max-row-delta
+// is given and the check is not skipped by the skip-cut-over-row-count-check interactive command
+func (this *MigrationContext) IsCutOverRowCountChecked() bool {
+	return this.CutOverRowCountCheck && atomic.LoadInt64(&this.SkipCutOverRowCountCheckFlag) == 0
+}
+
+// CutOverRowDeltaExceeded tells whether given original and ghost table row counts differ by more than
+// --cut-over-max-row-delta
+func (this *MigrationContext) CutOverRowDeltaExceeded(originalTableRows, ghostTableRows int64) bool {
+	delta := originalTableRows - ghostTableRows
+	if delta < 0 {
+		delta = -delta
+	}
+	if this.CutOverMaxRowDeltaPct > 0 {
+		return float64(delta) > float64(originalTableRows)*this.CutOverMaxRowDeltaPct/100
+	}
+	return delta > this.CutOverMaxRowDelta
+}
+
+// StreamerMaxReconnectRetries returns the number of successive binlog streamer reconnect
+// attempts, which defaults to the general number of retries
+func (this *MigrationContext) StreamerMaxReconnectRetries() int64 {
+	if this.StreamerReconnectRetries > 0 {
+		return this.StreamerReconnectRetries
+	}
+	return this.MaxRetries()
+}
+
 func (this *MigrationContext) IsTransactionalTable() bool {
 	switch strings.ToLower(this.TableEngine) {
 	case "innodb":
@@ -538,6 +1181,38 @@ func (this *MigrationContext) GetTotalRowsCopied() int64 {
 	return atomic.LoadInt64(&this.TotalRowsCopied)
 }
 
+// ShouldCountRowsExamined tells whether each row copy chunk's rows are counted ahead of being copied: on
+// --count-rows-examined, and with --copy-conflict-strategy=replace, whose affected rows count replaced rows twice.
+// A conflict fails the chunk with --copy-conflict-strategy=fail, such that rows copied are rows examined.
+func (this *MigrationContext) ShouldCountRowsExamined() bool {
+	switch this.CopyConflictStrategy {
+	case sql.FailCopyConflictStrategy:
+		return false
+	case sql.ReplaceCopyConflictStrategy:
+		return true
+	}
+	return this.CountRowsExamined
+}
+
+// GetTotalRowsExamined returns the number of rows row copy read off the original table. Rows examined
+// and not copied are rows found on the ghost table, and ignored per --copy-conflict-strategy
+func (this *MigrationContext) GetTotalRowsExamined() int64 {
+	return atomic.LoadInt64(&this.TotalRowsExamined)
+}
+
+// ShouldVerifyChunkChecksum tells whether the row copy chunk of given iteration is sampled for
+// checksum verification, per --verify-chunk-checksums
+func (this *MigrationContext) ShouldVerifyChunkChecksum(iteration int64) bool {
+	if this.ChunkChecksumSampleRatio <= 0 {
+		return false
+	}
+	interval := int64(math.Round(1 / this.ChunkChecksumSampleRatio))
+	if interval < 1 {
+		interval = 1
+	}
+	return iteration%interval == 0
+}
+
 func (this *MigrationContext) GetIteration() int64 {
 	return atomic.LoadInt64(&this.Iteration)
 }
@@ -598,6 +1273,128 @@ func (this *MigrationContext) SetChunkSize(chunkSize int64) {
 	atomic.StoreInt64(&this.ChunkSize, chunkSize)
 }
 
+// GetChunkSizeBounds returns the range within which --adaptive-chunk-size adjusts the chunk size
+func (this *MigrationContext) GetChunkSizeBounds() (chunkSizeMin int64, chunkSizeMax int64) {
+	this.throttleMutex.Lock()
+	defer this.throttleMutex.Unlock()
+
+	return this.chunkSizeMin, this.chunkSizeMax
+}
+
+// SetChunkSizeBounds sets the range within which --adaptive-chunk-size adjusts the chunk size. Bounds are
+// capped by the allowed chunk size range. With --adaptive-chunk-size, the current chunk size is brought within bounds.
+func (this *MigrationContext) SetChunkSizeBounds(chunkSizeMin int64, chunkSizeMax int64) error {
+	if chunkSizeMin < 10 {
+		chunkSizeMin = 10
+	}
+	if chunkSizeMax > 100000 {
+		chunkSizeMax = 100000
+	}
+	if chunkSizeMin > chunkSizeMax {
+		return fmt.Errorf("chunk-size-min (%d) must not exceed chunk-size-max (%d)", chunkSizeMin, chunkSizeMax)
+	}
+	this.throttleMutex.Lock()
+	defer this.throttleMutex.Unlock()
+
+	this.chunkSizeMin = chunkSizeMin
+	this.chunkSizeMax = chunkSizeMax
+	if this.AdaptiveChunkSize {
+		atomic.StoreInt64(&this.ChunkSize, boundChunkSize(atomic.LoadInt64(&this.ChunkSize), chunkSizeMin, chunkSizeMax))
+	}
+	return nil
+}
+
+func (this *MigrationContext) GetChunkLatencyTarget() time.Duration {
+	return time.Duration(atomic.LoadInt64(&this.chunkLatencyTarget))
+}
+
+func (this *MigrationContext) SetChunkLatencyTarget(chunkLatencyTarget time.Duration) error {
+	if chunkLatencyTarget <= 0 {
+		return fmt.Errorf("chunk-latency-target must be positive")
+	}
+	atomic.StoreInt64(&this.chunkLatencyTarget, int64(chunkLatencyTarget))
+	return nil
+}
+
+func boundChunkSize(chunkSize, chunkSizeMin, chunkSizeMax int64) int64 {
+	if chunkSize < chunkSizeMin {
+		return chunkSizeMin
+	}
+	if chunkSize > chunkSizeMax {
+		return chunkSizeMax
+	}
+	return chunkSize
+}
+
+// AdjustChunkSize sizes the next row copy chunk given the latency of the chunk just copied, for --adaptive-chunk-size.
+// The chunk size is multiplied by the ratio of the latency target to the latency, by no less than half and no
+// more than double, and within chunk size bounds. A latency within 10% of the target leaves the chunk size as is.
+func (this *MigrationContext) AdjustChunkSize(latency time.Duration) (chunkSize int64) {
+	chunkSize = atomic.LoadInt64(&this.ChunkSize)
+	target := this.GetChunkLatencyTarget()
+
+	factor := 2.0
+	if latency > 0 {
+		factor = math.Min(math.Max(float64(target)/float64(latency), 0.5), 2.0)
+	}
+	if factor > 1/1.1 && factor < 1/0.9 {
+		return chunkSize
+	}
+	chunkSizeMin, chunkSizeMax := this.GetChunkSizeBounds()
+	chunkSize = boundChunkSize(int64(math.Round(float64(chunkSize)*factor)), chunkSizeMin, chunkSizeMax)
+	atomic.StoreInt64(&this.ChunkSize, chunkSize)
+	return chunkSize
+}
+
+// GetMaxAllowedPacket returns the applier's max_allowed_packet, or the MySQL default when not yet read
+func (this *MigrationContext) GetMaxAllowedPacket() int64 {
+	if this.ApplierMaxAllowedPacket > 0 {
+		return this.ApplierMaxAllowedPacket
+	}
+	return defaultMaxAllowedPacket
+}
+
+// GetPacketBudget returns the bytes of values a generated statement may carry: --max-packet-budget, or else
+// half of max_allowed_packet, leaving room for the statement's text and for escaping
+func (this *MigrationContext) GetPacketBudget() int64 {
+	if this.MaxPacketBudget > 0 {
+		return this.MaxPacketBudget
+	}
+	return this.GetMaxAllowedPacket() / 2
+}
+
+// ObserveRowSize records the size of a row seen in the binary log, such that row copy chunks are sized
+// by the largest row observed
+func (this *MigrationContext) ObserveRowSize(size int64) {
+	for {
+		observed := atomic.LoadInt64(&this.MaxObservedRowSize)
+		if size <= observed || atomic.CompareAndSwapInt64(&this.MaxObservedRowSize, observed, size) {
+			return
+		}
+	}
+}
+
+// GetPacketBoundChunkSize returns the row copy chunk size, reduced as needed such that a chunk's rows fit
+// within the packet budget. Rows are estimated by the table's average row length, or by the largest row
+// observed in the binary log if larger. A chunk has at least a single row.
+func (this *MigrationContext) GetPacketBoundChunkSize() int64 {
+	chunkSize := atomic.LoadInt64(&this.ChunkSize)
+	rowSize := this.TableAvgRowLength
+	if observed := atomic.LoadInt64(&this.MaxObservedRowSize); observed > rowSize {
+		rowSize = observed
+	}
+	if rowSize <= 0 {
+		return chunkSize
+	}
+	if maxRows := this.GetPacketBudget() / rowSize; maxRows < chunkSize {
+		if maxRows < 1 {
+			return 1
+		}
+		return maxRows
+	}
+	return chunkSize
+}
+
 func (this *MigrationContext) SetDMLBatchSize(batchSize int64) {
 	if batchSize < 1 {
 		batchSize = 1
@@ -608,18 +1405,13 @@ func (this *MigrationContext) SetDMLBatchSize(batchSize int64) {
 	atomic.StoreInt64(&this.DMLBatchSize, batchSize)
 }
 
-func (this *MigrationContext) SetThrottleGeneralCheckResult(checkResult *ThrottleCheckResult) *ThrottleCheckResult {
-	this.throttleMutex.Lock()
-	defer this.throttleMutex.Unlock()
-	this.throttleGeneralCheckResult = *checkResult
-	return checkResult
-}
-
-func (this *MigrationContext) GetThrottleGeneralCheckResult() *ThrottleCheckResult {
-	this.throttleMutex.Lock()
-	defer this.throttleMutex.Unlock()
-	result := this.throttleGeneralCheckResult
-	return &result
+// GetDMLApplyAverageTransactionSize returns the average bytes of row images per DML apply transaction
+func (this *MigrationContext) GetDMLApplyAverageTransactionSize() float64 {
+	transactions := atomic.LoadInt64(&this.DMLApplyTransactions)
+	if transactions == 0 {
+		return 0
+	}
+	return float64(atomic.LoadInt64(&this.DMLApplyBytes)) / float64(transactions)
 }
 
 func (this *MigrationContext) SetThrottled(throttle bool, reason string, reasonHint ThrottleReasonHint) {
@@ -681,6 +1473,9 @@ func (this *MigrationContext) SetIgnoreHTTPErrors(ignoreHTTPErrors bool) {
 	defer this.throttleHTTPMutex.Unlock()
 
 	this.IgnoreHTTPErrors = ignoreHTTPErrors
+	if ignoreHTTPErrors {
+		this.ThrottleHTTPUnreachablePolicy = ThrottleHTTPUnreachableIgnore
+	}
 }
 
 func (this *MigrationContext) GetMaxLoad() LoadMap {
@@ -730,6 +1525,54 @@ func (this *MigrationContext) SetRecentBinlogCoordinates(coordinates mysql.Binlo
 	this.recentBinlogCoordinates = coordinates
 }
 
+// ObserveBinlogEventsBufferDepth raises the binlog events buffer high-water mark to given depth, if needed
+func (this *MigrationContext) ObserveBinlogEventsBufferDepth(depth int64) {
+	for {
+		highWaterMark := atomic.LoadInt64(&this.BinlogEventsBufferHighWaterMark)
+		if depth <= highWaterMark || atomic.CompareAndSwapInt64(&this.BinlogEventsBufferHighWaterMark, highWaterMark, depth) {
+			return
+		}
+	}
+}
+
+// SampleStreamerThroughput computes streaming rates since the previous sample. It is
+// expected to be called periodically.
+func (this *MigrationContext) SampleStreamerThroughput() {
+	sample := streamerCountersSample{
+		sampleTime:   time.Now(),
+		events:       atomic.LoadInt64(&this.StreamerEventsRead),
+		bytes:        atomic.LoadInt64(&this.StreamerBytesRead),
+		rows:         atomic.LoadInt64(&this.StreamerRowsEmitted),
+		blockedNanos: atomic.LoadInt64(&this.StreamerBlockedNanos),
+	}
+
+	this.throttleMutex.Lock()
+	defer this.throttleMutex.Unlock()
+
+	previous := this.streamerCountersSample
+	this.streamerCountersSample = sample
+	if previous.sampleTime.IsZero() {
+		return
+	}
+	elapsed := sample.sampleTime.Sub(previous.sampleTime)
+	if elapsed <= 0 {
+		return
+	}
+	this.streamerThroughput = StreamerThroughput{
+		EventsPerSecond: float64(sample.events-previous.events) / elapsed.Seconds(),
+		BytesPerSecond:  float64(sample.bytes-previous.bytes) / elapsed.Seconds(),
+		RowsPerSecond:   float64(sample.rows-previous.rows) / elapsed.Seconds(),
+		BlockedRatio:    math.Min(float64(sample.blockedNanos-previous.blockedNanos)/float64(elapsed.Nanoseconds()), 1),
+	}
+}
+
+func (this *MigrationContext) GetStreamerThroughput() StreamerThroughput {
+	this.throttleMutex.Lock()
+	defer this.throttleMutex.Unlock()
+
+	return this.streamerThroughput
+}
+
 // ReadMaxLoad parses the `--max-load` flag, which is in multiple key-value format,
 // such as: 'Threads_running=100,Threads_connected=500'
 // It only applies changes in case there's no parsing error.
@@ -778,6 +1621,28 @@ func (this *MigrationContext) SetControlReplicasLagResult(lagResult *mysql.Repli
 	}
 }
 
+// GetControlReplicasLagResults returns the lag of each control replica, as last read by the throttler, sorted by replica
+func (this *MigrationContext) GetControlReplicasLagResults() []mysql.ReplicationLagResult {
+	this.throttleMutex.Lock()
+	defer this.throttleMutex.Unlock()
+
+	lagResults := append([]mysql.ReplicationLagResult{}, this.controlReplicasLagResults...)
+	sort.Slice(lagResults, func(i, j int) bool {
+		return lagResults[i].Key.SmallerThan(&lagResults[j].Key)
+	})
+	return lagResults
+}
+
+func (this *MigrationContext) SetControlReplicasLagResults(lagResults []*mysql.ReplicationLagResult) {
+	this.throttleMutex.Lock()
+	defer this.throttleMutex.Unlock()
+
+	this.controlReplicasLagResults = nil
+	for _, lagResult := range lagResults {
+		this.controlReplicasLagResults = append(this.controlReplicasLagResults, *lagResult)
+	}
+}
+
 func (this *MigrationContext) GetThrottleControlReplicaKeys() *mysql.InstanceKeyMap {
 	this.throttleMutex.Lock()
 	defer this.throttleMutex.Unlock()
@@ -787,19 +1652,196 @@ func (this *MigrationContext) GetThrottleControlReplicaKeys() *mysql.InstanceKey
 	return keys
 }
 
+// ReadThrottleControlReplicaKeys parses the `--throttle-control-replicas` flag: a comma delimited list of replicas,
+// each optionally followed by its own max lag, overriding --max-lag-millis, e.g. 'replica1:3306,analytics1:3306;maxlag=30s'
 func (this *MigrationContext) ReadThrottleControlReplicaKeys(throttleControlReplicas string) error {
 	keys := mysql.NewInstanceKeyMap()
-	if err := keys.ReadCommaDelimitedList(throttleControlReplicas); err != nil {
-		return err
+	maxLags := make(map[mysql.InstanceKey]time.Duration)
+	if throttleControlReplicas != "" {
+		for _, token := range strings.Split(throttleControlReplicas, ",") {
+			tokens := strings.Split(token, ";")
+			key, err := mysql.ParseInstanceKey(strings.TrimSpace(tokens[0]))
+			if err != nil {
+				return err
+			}
+			for _, option := range tokens[1:] {
+				optionTokens := strings.SplitN(option, "=", 2)
+				if len(optionTokens) != 2 || strings.TrimSpace(optionTokens[0]) != "maxlag" {
+					return fmt.Errorf("Cannot parse throttle control replica: %s. Expected host:port;maxlag=<duration>", token)
+				}
+				maxLag, err := time.ParseDuration(strings.TrimSpace(optionTokens[1]))
+				if err != nil || maxLag <= 0 {
+					return fmt.Errorf("Cannot parse throttle control replica: %s. Invalid maxlag; expected a positive duration, e.g. 30s", token)
+				}
+				maxLags[*key] = maxLag
+			}
+			keys.AddKey(*key)
+		}
 	}
 
 	this.throttleMutex.Lock()
 	defer this.throttleMutex.Unlock()
 
 	this.throttleControlReplicaKeys = keys
+	this.throttleControlReplicaMaxLags = maxLags
 	return nil
 }
 
+// GetThrottleControlReplicas returns the control replicas, sorted, each followed by its own max lag if it has one.
+// It reads back via ReadThrottleControlReplicaKeys.
+func (this *MigrationContext) GetThrottleControlReplicas() string {
+	this.throttleMutex.Lock()
+	defer this.throttleMutex.Unlock()
+
+	replicas := []string{}
+	for _, key := range this.throttleControlReplicaKeys.GetInstanceKeys() {
+		replica := key.DisplayString()
+		if maxLag, ok := this.throttleControlReplicaMaxLags[key]; ok {
+			replica = fmt.Sprintf("%s;maxlag=%+v", replica, maxLag)
+		}
+		replicas = append(replicas, replica)
+	}
+	sort.Strings(replicas)
+	return strings.Join(replicas, ",")
+}
+
+// GetThrottleControlReplicaMaxLag returns the lag beyond which given control replica throttles: its own max lag,
+// if given one via --throttle-control-replicas, or else --max-lag-millis
+func (this *MigrationContext) GetThrottleControlReplicaMaxLag(key mysql.InstanceKey) time.Duration {
+	this.throttleMutex.Lock()
+	defer this.throttleMutex.Unlock()
+
+	if maxLag, ok := this.throttleControlReplicaMaxLags[key]; ok {
+		return maxLag
+	}
+	return time.Duration(atomic.LoadInt64(&this.MaxLagMillisecondsThrottleThreshold)) * time.Millisecond
+}
+
+// GetMinThrottleControlReplicaMaxLag returns the lowest max lag of any control replica, or of --max-lag-millis
+func (this *MigrationContext) GetMinThrottleControlReplicaMaxLag() time.Duration {
+	this.throttleMutex.Lock()
+	defer this.throttleMutex.Unlock()
+
+	minMaxLag := time.Duration(atomic.LoadInt64(&this.MaxLagMillisecondsThrottleThreshold)) * time.Millisecond
+	for _, maxLag := range this.throttleControlReplicaMaxLags {
+		if maxLag < minMaxLag {
+			minMaxLag = maxLag
+		}
+	}
+	return minMaxLag
+}
+
+// ReadReplicaServerIdRange parses the `--replica-server-id-range` flag, in the form min-max
+func (this *MigrationContext) ReadReplicaServerIdRange(replicaServerIdRange string) error {
+	tokens := strings.Split(replicaServerIdRange, "-")
+	if len(tokens) != 2 {
+		return fmt.Errorf("Cannot parse replica server id range: %s. Expected min-max", replicaServerIdRange)
+	}
+	min, err := strconv.ParseUint(strings.TrimSpace(tokens[0]), 10, 32)
+	if err != nil {
+		return fmt.Errorf("Cannot parse replica server id range: %s. %+v", replicaServerIdRange, err)
+	}
+	max, err := strconv.ParseUint(strings.TrimSpace(tokens[1]), 10, 32)
+	if err != nil {
+		return fmt.Errorf("Cannot parse replica server id range: %s. %+v", replicaServerIdRange, err)
+	}
+	if min == 0 || min > max {
+		return fmt.Errorf("Invalid replica server id range: %s", replicaServerIdRange)
+	}
+	this.ReplicaServerIdRangeMin = uint(min)
+	this.ReplicaServerIdRangeMax = uint(max)
+	return nil
+}
+
+// ReadStreamerFailoverKeys parses the ordered, comma delimited list of hosts to which
+// binlog streaming may fail over
+func (this *MigrationContext) ReadStreamerFailoverKeys(streamerFailoverHosts string) error {
+	keys := []mysql.InstanceKey{}
+	if streamerFailoverHosts != "" {
+		for _, token := range strings.Split(streamerFailoverHosts, ",") {
+			key, err := mysql.ParseInstanceKey(strings.TrimSpace(token))
+			if err != nil {
+				return err
+			}
+			keys = append(keys, *key)
+		}
+	}
+	this.StreamerFailoverKeys = keys
+	return nil
+}
+
+// ReadStartBinlogCoordinates parses the file:pos coordinates at which binlog streaming begins
+func (this *MigrationContext) ReadStartBinlogCoordinates(startBinlogCoordinates string) error {
+	if startBinlogCoordinates == "" {
+		return nil
+	}
+	coordinates, err := mysql.ParseBinlogCoordinates(startBinlogCoordinates)
+	if err != nil {
+		return err
+	}
+	if coordinates.LogFile == "" || coordinates.LogPos < 4 {
+		return fmt.Errorf("Invalid binlog coordinates: %s. Expected format is file:pos, pos at least 4", startBinlogCoordinates)
+	}
+	this.StartBinlogCoordinates = coordinates
+	return nil
+}
+
+// ReadReplicaLagSources parses the `--replica-lag-source` flag: a comma delimited list of lag sources, each
+// either applying to all control replicas, or to a single one, as host:port=source. e.g.
+// 'replica_status,replica3:3306=pt-heartbeat:percona.heartbeat'
+func (this *MigrationContext) ReadReplicaLagSources(replicaLagSources string) error {
+	defaultSource := &mysql.ReplicaLagSource{Type: mysql.HeartbeatReplicaLagSource}
+	sources := make(map[mysql.InstanceKey]*mysql.ReplicaLagSource)
+	defaultSourceFound := false
+	for _, token := range strings.Split(replicaLagSources, ",") {
+		if strings.TrimSpace(token) == "" {
+			continue
+		}
+		tokens := strings.SplitN(token, "=", 2)
+		if len(tokens) == 1 {
+			if defaultSourceFound {
+				return fmt.Errorf("Cannot parse replica lag sources: %s. Only one source may apply to all replicas", replicaLagSources)
+			}
+			source, err := mysql.ParseReplicaLagSource(tokens[0])
+			if err != nil {
+				return err
+			}
+			defaultSource, defaultSourceFound = source, true
+			continue
+		}
+		key, err := mysql.ParseInstanceKey(strings.TrimSpace(tokens[0]))
+		if err != nil {
+			return err
+		}
+		if _, ok := sources[*key]; ok {
+			return fmt.Errorf("Cannot parse replica lag sources: %s. Duplicate source for %s", replicaLagSources, key)
+		}
+		source, err := mysql.ParseReplicaLagSource(tokens[1])
+		if err != nil {
+			return err
+		}
+		sources[*key] = source
+	}
+
+	this.throttleMutex.Lock()
+	defer this.throttleMutex.Unlock()
+
+	this.defaultReplicaLagSource = defaultSource
+	this.replicaLagSources = sources
+	return nil
+}
+
+// GetReplicaLagSource returns how the lag of given control replica is measured
+func (this *MigrationContext) GetReplicaLagSource(key mysql.InstanceKey) *mysql.ReplicaLagSource {
+	this.throttleMutex.Lock()
+	defer this.throttleMutex.Unlock()
+
+	if source, ok := this.replicaLagSources[key]; ok {
+		return source
+	}
+	return this.defaultReplicaLagSource
+}
+
 func (this *MigrationContext) AddThrottleControlReplicaKey(key mysql.InstanceKey) error {
 	this.throttleMutex.Lock()
 	defer this.throttleMutex.Unlock()
@@ -808,6 +1850,133 @@ func (this *MigrationContext) AddThrottleControlReplicaKey(key mysql.InstanceKey
 	return nil
 }
 
+func (this *MigrationContext) RemoveThrottleControlReplicaKey(key mysql.InstanceKey) {
+	this.throttleMutex.Lock()
+	defer this.throttleMutex.Unlock()
+
+	delete(*this.throttleControlReplicaKeys, key)
+	delete(this.throttleControlReplicaMaxLags, key)
+	delete(this.ignoredThrottleControlReplicas, key)
+}
+
+// IgnoreThrottleControlReplica masks given control replica from lag and load checks, e.g. while it is down for
+// maintenance, for given TTL, or until unignored given a non-positive TTL. It remains a control replica.
+func (this *MigrationContext) IgnoreThrottleControlReplica(key mysql.InstanceKey, ttl time.Duration) (until time.Time, err error) {
+	this.throttleMutex.Lock()
+	defer this.throttleMutex.Unlock()
+
+	if !this.throttleControlReplicaKeys.HasKey(key) {
+		return until, fmt.Errorf("%s is not a throttle control replica", key.DisplayString())
+	}
+	if ttl > 0 {
+		until = time.Now().Add(ttl)
+	}
+	this.ignoredThrottleControlReplicas[key] = until
+	return until, nil
+}
+
+// UnignoreThrottleControlReplica ends the masking of given control replica from lag and load checks
+func (this *MigrationContext) UnignoreThrottleControlReplica(key mysql.InstanceKey) error {
+	this.throttleMutex.Lock()
+	defer this.throttleMutex.Unlock()
+
+	this.expireIgnoredThrottleControlReplicas()
+	if _, ok := this.ignoredThrottleControlReplicas[key]; !ok {
+		return fmt.Errorf("%s is not an ignored throttle control replica", key.DisplayString())
+	}
+	delete(this.ignoredThrottleControlReplicas, key)
+	return nil
+}
+
+// expireIgnoredThrottleControlReplicas unignores replicas whose TTL expired, or which are no longer control replicas.
+// The throttle mutex must be held.
+func (this *MigrationContext) expireIgnoredThrottleControlReplicas() {
+	now := time.Now()
+	for key, until := range this.ignoredThrottleControlReplicas {
+		if !this.throttleControlReplicaKeys.HasKey(key) {
+			delete(this.ignoredThrottleControlReplicas, key)
+		} else if !until.IsZero() && !now.Before(until) {
+			delete(this.ignoredThrottleControlReplicas, key)
+			this.Log.Infof("Throttle control replica %s no longer ignored: its ignore TTL expired", key.DisplayString())
+		}
+	}
+}
+
+// GetIgnoredThrottleControlReplicas returns the ignored control replicas, each with the time it is ignored until,
+// or the zero time if it has no TTL
+func (this *MigrationContext) GetIgnoredThrottleControlReplicas() map[mysql.InstanceKey]time.Time {
+	this.throttleMutex.Lock()
+	defer this.throttleMutex.Unlock()
+
+	this.expireIgnoredThrottleControlReplicas()
+	ignored := make(map[mysql.InstanceKey]time.Time)
+	for key, until := range this.ignoredThrottleControlReplicas {
+		ignored[key] = until
+	}
+	return ignored
+}
+
+// DescribeIgnoredThrottleControlReplicas lists the ignored control replicas, sorted, each with the time left until
+// it is no longer ignored, if it has a TTL, e.g. "replica1:3306 (1h59m left), replica2:3306"
+func (this *MigrationContext) DescribeIgnoredThrottleControlReplicas() string {
+	descriptions := []string{}
+	for key, until := range this.GetIgnoredThrottleControlReplicas() {
+		description := key.DisplayString()
+		if !until.IsZero() {
+			description = fmt.Sprintf("%s (%s left)", description, PrettifyDurationOutput(time.Until(until)))
+		}
+		descriptions = append(descriptions, description)
+	}
+	sort.Strings(descriptions)
+	return strings.Join(descriptions, ", ")
+}
+
+// GetCheckedThrottleControlReplicaKeys returns the control replicas whose lag and load are checked: all but the ignored ones
+func (this *MigrationContext) GetCheckedThrottleControlReplicaKeys() *mysql.InstanceKeyMap {
+	this.throttleMutex.Lock()
+	defer this.throttleMutex.Unlock()
+
+	this.expireIgnoredThrottleControlReplicas()
+	keys := mysql.NewInstanceKeyMap()
+	for _, key := range this.throttleControlReplicaKeys.GetInstanceKeys() {
+		if _, ok := this.ignoredThrottleControlReplicas[key]; !ok {
+			keys.AddKey(key)
+		}
+	}
+	return keys
+}
+
+// GetThrottleAdditionalFlagFiles returns the `--throttle-additional-flag-file` paths, which the flag comma delimits
+func (this *MigrationContext) GetThrottleAdditionalFlagFiles() (flagFiles []string) {
+	for _, flagFile := range strings.Split(this.ThrottleAdditionalFlagFile, ",") {
+		if flagFile = strings.TrimSpace(flagFile); flagFile != "" {
+			flagFiles = append(flagFiles, flagFile)
+		}
+	}
+	return flagFiles
+}
+
+// ReadDiscoverThrottleReplicasExclude parses the `--discover-throttle-replicas-exclude` flag, a comma delimited
+// list of hostnames, matching any port, or host:port entries
+func (this *MigrationContext) ReadDiscoverThrottleReplicasExclude(excludeList string) {
+	this.discoverThrottleReplicasExclude = []string{}
+	for _, entry := range strings.Split(excludeList, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			this.discoverThrottleReplicasExclude = append(this.discoverThrottleReplicasExclude, entry)
+		}
+	}
+}
+
+// IsThrottleReplicaDiscoveryExcluded tells whether a discovered replica is excluded by `--discover-throttle-replicas-exclude`
+func (this *MigrationContext) IsThrottleReplicaDiscoveryExcluded(key mysql.InstanceKey) bool {
+	for _, entry := range this.discoverThrottleReplicasExclude {
+		if strings.EqualFold(entry, key.Hostname) || strings.EqualFold(entry, key.String()) {
+			return true
+		}
+	}
+	return false
+}
+
 // ApplyCredentials sorts out the credentials between the config file and the CLI flags
 func (this *MigrationContext) ApplyCredentials() {
 	this.configMutex.Lock()
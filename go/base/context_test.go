@@ -8,11 +8,15 @@ package base
 import (
 	"io/ioutil"
 	"os"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/outbrain/golib/log"
 	test "github.com/outbrain/golib/tests"
+
+	"github.com/github/gh-ost/go/mysql"
+	"github.com/github/gh-ost/go/sql"
 )
 
 func init() {
@@ -59,6 +63,111 @@ func TestGetTableNames(t *testing.T) {
 	}
 }
 
+func TestGetTableNamesByPattern(t *testing.T) {
+	startTime := time.Date(2013, 2, 3, 19, 54, 0, 0, time.UTC)
+	{
+		context := NewMigrationContext()
+		context.OriginalTableName = "some_table"
+		context.StartTime = startTime
+		context.OldTableNamePattern = "_{table}_del_{timestamp}"
+		context.GhostTableNamePattern = "{table}_gho_{date}"
+		test.S(t).ExpectEquals(context.GetOldTableName(), "_some_table_del_20130203195400")
+		test.S(t).ExpectEquals(context.GetGhostTableName(), "some_table_gho_20130203")
+		test.S(t).ExpectEquals(context.GetChangelogTableName(), "_some_table_ghc")
+		test.S(t).ExpectNil(context.ValidateTableNames())
+	}
+	{
+		context := NewMigrationContext()
+		context.OriginalTableName = "a123456789012345678901234567890123456789012345678901234567890123"
+		context.ForceTmpTableName = "b123456789012345678901234567890123456789012345678901234567890123"
+		context.StartTime = startTime
+		context.OldTableNamePattern = "_{table}_del_{timestamp}"
+		oldTableName := context.GetOldTableName()
+		test.S(t).ExpectEquals(len(oldTableName), 64)
+		test.S(t).ExpectEquals(oldTableName, "_b1234567890123456789012345678901234_f3484e53_del_20130203195400")
+
+		context.ForceTmpTableName = "b123456789012345678901234567890123456789012345678901234567890124"
+		test.S(t).ExpectEquals(len(context.GetOldTableName()), 64)
+		test.S(t).ExpectNotEquals(context.GetOldTableName(), oldTableName)
+	}
+	{
+		context := NewMigrationContext()
+		context.OriginalTableName = "some_table"
+		context.OldTableNamePattern = "_{table}_gho"
+		test.S(t).ExpectNotNil(context.ValidateTableNames())
+		context.OldTableNamePattern = "{table}"
+		test.S(t).ExpectNotNil(context.ValidateTableNames())
+	}
+}
+
+func TestValidateTableNamePattern(t *testing.T) {
+	test.S(t).ExpectNil(ValidateTableNamePattern("_{table}_del"))
+	test.S(t).ExpectNil(ValidateTableNamePattern("_{table}_del_{timestamp}"))
+	test.S(t).ExpectNil(ValidateTableNamePattern("{date}_{table}_old"))
+	test.S(t).ExpectNotNil(ValidateTableNamePattern(""))
+	test.S(t).ExpectNotNil(ValidateTableNamePattern("_del_{timestamp}"))
+	test.S(t).ExpectNotNil(ValidateTableNamePattern("_{table}_{table}"))
+	test.S(t).ExpectNotNil(ValidateTableNamePattern("_{table}_{hostname}"))
+	test.S(t).ExpectNotNil(ValidateTableNamePattern("_{table}_{date"))
+	test.S(t).ExpectNotNil(ValidateTableNamePattern("_{table}`_del"))
+	test.S(t).ExpectNotNil(ValidateTableNamePattern("_{table}_a1234567890123456789012345678901234567890_{timestamp}"))
+}
+
+func TestGetTargetDatabaseName(t *testing.T) {
+	context := NewMigrationContext()
+	context.DatabaseName = "source_db"
+	test.S(t).ExpectEquals(context.GetTargetDatabaseName(), "source_db")
+	test.S(t).ExpectFalse(context.IsCrossSchemaMigration())
+
+	context.TargetDatabaseName = "source_db"
+	test.S(t).ExpectFalse(context.IsCrossSchemaMigration())
+
+	context.TargetDatabaseName = "target_db"
+	test.S(t).ExpectEquals(context.GetTargetDatabaseName(), "target_db")
+	test.S(t).ExpectTrue(context.IsCrossSchemaMigration())
+}
+
+func TestShouldCountRowsExamined(t *testing.T) {
+	context := NewMigrationContext()
+	test.S(t).ExpectFalse(context.ShouldCountRowsExamined())
+
+	context.CountRowsExamined = true
+	test.S(t).ExpectTrue(context.ShouldCountRowsExamined())
+
+	context.CopyConflictStrategy = sql.FailCopyConflictStrategy
+	test.S(t).ExpectFalse(context.ShouldCountRowsExamined())
+
+	context.CountRowsExamined = false
+	context.CopyConflictStrategy = sql.ReplaceCopyConflictStrategy
+	test.S(t).ExpectTrue(context.ShouldCountRowsExamined())
+}
+
+func TestGetMigratedTableName(t *testing.T) {
+	context := NewMigrationContext()
+	context.OriginalTableName = "orders"
+	test.S(t).ExpectEquals(context.GetMigratedTableName(), "orders")
+	test.S(t).ExpectFalse(context.IsTableRenamed())
+	test.S(t).ExpectNil(context.ValidateNewTableName())
+
+	context.NewTableName = "orders_v2"
+	test.S(t).ExpectEquals(context.GetMigratedTableName(), "orders_v2")
+	test.S(t).ExpectTrue(context.IsTableRenamed())
+	test.S(t).ExpectNil(context.ValidateNewTableName())
+	test.S(t).ExpectNil(context.ValidateTableNames())
+
+	context.NewTableName = "_orders_gho"
+	test.S(t).ExpectNotNil(context.ValidateTableNames())
+
+	context.NewTableName = "orders.v2"
+	test.S(t).ExpectNotNil(context.ValidateNewTableName())
+
+	context.NewTableName = "orders_v2 "
+	test.S(t).ExpectNotNil(context.ValidateNewTableName())
+
+	context.NewTableName = "a1234567890123456789012345678901234567890123456789012345678901234"
+	test.S(t).ExpectNotNil(context.ValidateNewTableName())
+}
+
 func TestReadConfigFile(t *testing.T) {
 	{
 		context := NewMigrationContext()
@@ -120,3 +229,375 @@ func TestReadConfigFile(t *testing.T) {
 		}
 	}
 }
+
+func TestReadStreamerFailoverKeys(t *testing.T) {
+	{
+		context := NewMigrationContext()
+		test.S(t).ExpectNil(context.ReadStreamerFailoverKeys(""))
+		test.S(t).ExpectEquals(len(context.StreamerFailoverKeys), 0)
+	}
+	{
+		context := NewMigrationContext()
+		test.S(t).ExpectNil(context.ReadStreamerFailoverKeys("replica-02.com:3307, replica-01.com,127.0.0.1"))
+		test.S(t).ExpectEquals(len(context.StreamerFailoverKeys), 3)
+		test.S(t).ExpectEquals(context.StreamerFailoverKeys[0].String(), "replica-02.com:3307")
+		test.S(t).ExpectEquals(context.StreamerFailoverKeys[1].String(), "replica-01.com:3306")
+		test.S(t).ExpectEquals(context.StreamerFailoverKeys[2].String(), "127.0.0.1:3306")
+	}
+	{
+		context := NewMigrationContext()
+		test.S(t).ExpectNotNil(context.ReadStreamerFailoverKeys("replica-01.com:port"))
+	}
+}
+
+func TestSampleStreamerThroughput(t *testing.T) {
+	context := NewMigrationContext()
+	context.SampleStreamerThroughput()
+	test.S(t).ExpectEquals(context.GetStreamerThroughput().EventsPerSecond, 0.0)
+
+	context.StreamerEventsRead = 1000
+	context.StreamerBytesRead = 1 << 20
+	context.StreamerRowsEmitted = 100
+	context.StreamerBlockedNanos = int64(time.Hour)
+	context.SampleStreamerThroughput()
+	throughput := context.GetStreamerThroughput()
+	test.S(t).ExpectTrue(throughput.EventsPerSecond > 0)
+	test.S(t).ExpectTrue(throughput.BytesPerSecond > throughput.EventsPerSecond)
+	test.S(t).ExpectTrue(throughput.RowsPerSecond < throughput.EventsPerSecond)
+	test.S(t).ExpectEquals(throughput.BlockedRatio, 1.0)
+}
+
+func TestReadReplicaLagSources(t *testing.T) {
+	replica1 := mysql.InstanceKey{Hostname: "replica1", Port: 3306}
+	replica2 := mysql.InstanceKey{Hostname: "replica2", Port: 3307}
+	{
+		context := NewMigrationContext()
+		test.S(t).ExpectEquals(context.GetReplicaLagSource(replica1).Type, mysql.HeartbeatReplicaLagSource)
+		test.S(t).ExpectNil(context.ReadReplicaLagSources("heartbeat"))
+		test.S(t).ExpectEquals(context.GetReplicaLagSource(replica1).Type, mysql.HeartbeatReplicaLagSource)
+	}
+	{
+		context := NewMigrationContext()
+		test.S(t).ExpectNil(context.ReadReplicaLagSources("replica_status,replica2:3307=pt-heartbeat:percona.heartbeat"))
+		test.S(t).ExpectEquals(context.GetReplicaLagSource(replica1).Type, mysql.ReplicaStatusReplicaLagSource)
+		test.S(t).ExpectEquals(context.GetReplicaLagSource(replica2).Type, mysql.PtHeartbeatReplicaLagSource)
+		test.S(t).ExpectEquals(context.GetReplicaLagSource(replica2).Table, "percona.heartbeat")
+	}
+	{
+		context := NewMigrationContext()
+		test.S(t).ExpectNil(context.ReadReplicaLagSources("replica1=performance_schema"))
+		test.S(t).ExpectEquals(context.GetReplicaLagSource(replica1).Type, mysql.PerformanceSchemaReplicaLagSource)
+		test.S(t).ExpectEquals(context.GetReplicaLagSource(replica2).Type, mysql.HeartbeatReplicaLagSource)
+	}
+	{
+		context := NewMigrationContext()
+		test.S(t).ExpectNotNil(context.ReadReplicaLagSources("replica_status,heartbeat"))
+		test.S(t).ExpectNotNil(context.ReadReplicaLagSources("replica1=replica_status,replica1:3306=heartbeat"))
+		test.S(t).ExpectNotNil(context.ReadReplicaLagSources("replica1=unknown"))
+	}
+}
+
+func TestReadThrottleControlReplicaKeys(t *testing.T) {
+	replica1 := mysql.InstanceKey{Hostname: "replica1", Port: 3306}
+	analytics1 := mysql.InstanceKey{Hostname: "analytics1", Port: 3306}
+	{
+		context := NewMigrationContext()
+		context.SetMaxLagMillisecondsThrottleThreshold(1500)
+		test.S(t).ExpectNil(context.ReadThrottleControlReplicaKeys("replica1:3306, analytics1;maxlag=30s"))
+		test.S(t).ExpectEquals(context.GetThrottleControlReplicaKeys().Len(), 2)
+		test.S(t).ExpectEquals(context.GetThrottleControlReplicaMaxLag(replica1), 1500*time.Millisecond)
+		test.S(t).ExpectEquals(context.GetThrottleControlReplicaMaxLag(analytics1), 30*time.Second)
+		test.S(t).ExpectEquals(context.GetMinThrottleControlReplicaMaxLag(), 1500*time.Millisecond)
+		test.S(t).ExpectEquals(context.GetThrottleControlReplicas(), "analytics1:3306;maxlag=30s,replica1:3306")
+
+		// a change of --max-lag-millis applies to replicas without their own max lag
+		context.SetMaxLagMillisecondsThrottleThreshold(500)
+		test.S(t).ExpectEquals(context.GetThrottleControlReplicaMaxLag(replica1), 500*time.Millisecond)
+		test.S(t).ExpectEquals(context.GetThrottleControlReplicaMaxLag(analytics1), 30*time.Second)
+
+		// reading a new list replaces all max lags
+		test.S(t).ExpectNil(context.ReadThrottleControlReplicaKeys(context.GetThrottleControlReplicas() + ";maxlag=200ms"))
+		test.S(t).ExpectEquals(context.GetThrottleControlReplicaMaxLag(replica1), 200*time.Millisecond)
+		test.S(t).ExpectEquals(context.GetMinThrottleControlReplicaMaxLag(), 200*time.Millisecond)
+		test.S(t).ExpectNil(context.ReadThrottleControlReplicaKeys("replica1"))
+		test.S(t).ExpectEquals(context.GetThrottleControlReplicaMaxLag(analytics1), 500*time.Millisecond)
+	}
+	{
+		context := NewMigrationContext()
+		test.S(t).ExpectNotNil(context.ReadThrottleControlReplicaKeys("replica1;maxlag=soon"))
+		test.S(t).ExpectNotNil(context.ReadThrottleControlReplicaKeys("replica1;maxlag=0s"))
+		test.S(t).ExpectNotNil(context.ReadThrottleControlReplicaKeys("replica1;lag=30s"))
+		test.S(t).ExpectEquals(context.GetThrottleControlReplicaKeys().Len(), 0)
+	}
+}
+
+func TestIgnoreThrottleControlReplica(t *testing.T) {
+	replica1 := mysql.InstanceKey{Hostname: "replica1", Port: 3306}
+	replica2 := mysql.InstanceKey{Hostname: "replica2", Port: 3306}
+	context := NewMigrationContext()
+	test.S(t).ExpectNil(context.ReadThrottleControlReplicaKeys("replica1,replica2"))
+
+	_, err := context.IgnoreThrottleControlReplica(mysql.InstanceKey{Hostname: "replica3", Port: 3306}, 0)
+	test.S(t).ExpectNotNil(err)
+	until, err := context.IgnoreThrottleControlReplica(replica1, 0)
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectTrue(until.IsZero())
+	test.S(t).ExpectEquals(context.GetThrottleControlReplicaKeys().Len(), 2)
+	test.S(t).ExpectEquals(context.GetCheckedThrottleControlReplicaKeys().Len(), 1)
+	test.S(t).ExpectTrue(context.GetCheckedThrottleControlReplicaKeys().HasKey(replica2))
+	test.S(t).ExpectEquals(context.DescribeIgnoredThrottleControlReplicas(), "replica1:3306")
+
+	until, err = context.IgnoreThrottleControlReplica(replica2, time.Hour)
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectFalse(until.IsZero())
+	test.S(t).ExpectEquals(context.GetCheckedThrottleControlReplicaKeys().Len(), 0)
+
+	// An expired TTL unignores the replica
+	context.ignoredThrottleControlReplicas[replica2] = time.Now().Add(-time.Second)
+	test.S(t).ExpectEquals(context.GetCheckedThrottleControlReplicaKeys().Len(), 1)
+	test.S(t).ExpectNotNil(context.UnignoreThrottleControlReplica(replica2))
+
+	test.S(t).ExpectNil(context.UnignoreThrottleControlReplica(replica1))
+	test.S(t).ExpectEquals(context.GetCheckedThrottleControlReplicaKeys().Len(), 2)
+	test.S(t).ExpectEquals(context.DescribeIgnoredThrottleControlReplicas(), "")
+
+	// A replica no longer listed is no longer ignored
+	context.IgnoreThrottleControlReplica(replica1, 0)
+	test.S(t).ExpectNil(context.ReadThrottleControlReplicaKeys("replica2"))
+	test.S(t).ExpectEquals(len(context.GetIgnoredThrottleControlReplicas()), 0)
+}
+
+func TestIsThrottleReplicaDiscoveryExcluded(t *testing.T) {
+	context := NewMigrationContext()
+	context.ReadDiscoverThrottleReplicasExclude("backup1, analytics1:3307,")
+	test.S(t).ExpectTrue(context.IsThrottleReplicaDiscoveryExcluded(mysql.InstanceKey{Hostname: "backup1", Port: 3306}))
+	test.S(t).ExpectTrue(context.IsThrottleReplicaDiscoveryExcluded(mysql.InstanceKey{Hostname: "backup1", Port: 3307}))
+	test.S(t).ExpectTrue(context.IsThrottleReplicaDiscoveryExcluded(mysql.InstanceKey{Hostname: "analytics1", Port: 3307}))
+	test.S(t).ExpectFalse(context.IsThrottleReplicaDiscoveryExcluded(mysql.InstanceKey{Hostname: "analytics1", Port: 3306}))
+	test.S(t).ExpectFalse(context.IsThrottleReplicaDiscoveryExcluded(mysql.InstanceKey{Hostname: "replica1", Port: 3306}))
+}
+
+func TestReadReplicaServerIdRange(t *testing.T) {
+	context := NewMigrationContext()
+	test.S(t).ExpectNil(context.ReadReplicaServerIdRange("100000-999999"))
+	test.S(t).ExpectEquals(context.ReplicaServerIdRangeMin, uint(100000))
+	test.S(t).ExpectEquals(context.ReplicaServerIdRangeMax, uint(999999))
+	test.S(t).ExpectNil(context.ReadReplicaServerIdRange("7-7"))
+	test.S(t).ExpectEquals(context.ReplicaServerIdRangeMin, uint(7))
+
+	test.S(t).ExpectNotNil(context.ReadReplicaServerIdRange("100000"))
+	test.S(t).ExpectNotNil(context.ReadReplicaServerIdRange("0-10"))
+	test.S(t).ExpectNotNil(context.ReadReplicaServerIdRange("10-9"))
+	test.S(t).ExpectNotNil(context.ReadReplicaServerIdRange("1-99999999999"))
+	test.S(t).ExpectEquals(context.ReplicaServerIdRangeMax, uint(7))
+}
+
+func TestObserveBinlogEventsBufferDepth(t *testing.T) {
+	context := NewMigrationContext()
+	test.S(t).ExpectEquals(context.BinlogEventsBufferSize, int64(1))
+	context.ObserveBinlogEventsBufferDepth(3)
+	context.ObserveBinlogEventsBufferDepth(1)
+	test.S(t).ExpectEquals(context.BinlogEventsBufferHighWaterMark, int64(3))
+	context.ObserveBinlogEventsBufferDepth(8)
+	test.S(t).ExpectEquals(context.BinlogEventsBufferHighWaterMark, int64(8))
+}
+
+func TestReadStartBinlogCoordinates(t *testing.T) {
+	context := NewMigrationContext()
+	test.S(t).ExpectNil(context.ReadStartBinlogCoordinates(""))
+	test.S(t).ExpectTrue(context.StartBinlogCoordinates == nil)
+
+	test.S(t).ExpectNil(context.ReadStartBinlogCoordinates("mysql-bin.000123:4"))
+	test.S(t).ExpectEquals(context.StartBinlogCoordinates.LogFile, "mysql-bin.000123")
+	test.S(t).ExpectEquals(context.StartBinlogCoordinates.LogPos, int64(4))
+
+	test.S(t).ExpectNotNil(context.ReadStartBinlogCoordinates("mysql-bin.000123"))
+	test.S(t).ExpectNotNil(context.ReadStartBinlogCoordinates("mysql-bin.000123:abc"))
+	test.S(t).ExpectNotNil(context.ReadStartBinlogCoordinates(":1234"))
+	test.S(t).ExpectNotNil(context.ReadStartBinlogCoordinates("mysql-bin.000123:0"))
+}
+
+func TestMigrationRangePartition(t *testing.T) {
+	context := NewMigrationContext()
+	test.S(t).ExpectEquals(context.GetMigrationRangePartitionName(), "")
+	test.S(t).ExpectTrue(context.IsFirstRangeIteration())
+
+	context.Iteration = 3
+	test.S(t).ExpectFalse(context.IsFirstRangeIteration())
+
+	// Moving onto the next partition starts a new range
+	context.MigrationRangePartition = &sql.Partition{Name: "p1"}
+	context.MigrationRangeStartIteration = 3
+	test.S(t).ExpectEquals(context.GetMigrationRangePartitionName(), "p1")
+	test.S(t).ExpectTrue(context.IsFirstRangeIteration())
+}
+
+func TestShouldVerifyChunkChecksum(t *testing.T) {
+	context := NewMigrationContext()
+	test.S(t).ExpectFalse(context.ShouldVerifyChunkChecksum(0))
+
+	context.ChunkChecksumSampleRatio = 1
+	test.S(t).ExpectTrue(context.ShouldVerifyChunkChecksum(0))
+	test.S(t).ExpectTrue(context.ShouldVerifyChunkChecksum(7))
+
+	context.ChunkChecksumSampleRatio = 0.1
+	test.S(t).ExpectTrue(context.ShouldVerifyChunkChecksum(0))
+	test.S(t).ExpectFalse(context.ShouldVerifyChunkChecksum(7))
+	test.S(t).ExpectTrue(context.ShouldVerifyChunkChecksum(20))
+}
+
+func TestAdjustChunkSize(t *testing.T) {
+	context := NewMigrationContext()
+	context.AdaptiveChunkSize = true
+	test.S(t).ExpectNil(context.SetChunkLatencyTarget(500 * time.Millisecond))
+	test.S(t).ExpectNil(context.SetChunkSizeBounds(100, 5000))
+
+	// Within 10% of the target
+	test.S(t).ExpectEquals(context.AdjustChunkSize(520*time.Millisecond), int64(1000))
+	test.S(t).ExpectEquals(context.AdjustChunkSize(250*time.Millisecond), int64(2000))
+	test.S(t).ExpectEquals(context.AdjustChunkSize(1000*time.Millisecond), int64(1000))
+	// No more than double, no less than half
+	test.S(t).ExpectEquals(context.AdjustChunkSize(time.Millisecond), int64(2000))
+	test.S(t).ExpectEquals(context.AdjustChunkSize(10*time.Second), int64(1000))
+	test.S(t).ExpectEquals(context.AdjustChunkSize(400*time.Millisecond), int64(1250))
+	// Within bounds
+	test.S(t).ExpectEquals(context.AdjustChunkSize(0), int64(2500))
+	test.S(t).ExpectEquals(context.AdjustChunkSize(0), int64(5000))
+	test.S(t).ExpectEquals(context.AdjustChunkSize(0), int64(5000))
+	test.S(t).ExpectNil(context.SetChunkSizeBounds(100, 3000))
+	test.S(t).ExpectEquals(context.ChunkSize, int64(3000))
+	for i := 0; i < 10; i++ {
+		context.AdjustChunkSize(time.Minute)
+	}
+	test.S(t).ExpectEquals(context.ChunkSize, int64(100))
+}
+
+func TestGetPacketBoundChunkSize(t *testing.T) {
+	context := NewMigrationContext()
+	test.S(t).ExpectEquals(context.GetPacketBudget(), int64(2*1024*1024))
+	test.S(t).ExpectEquals(context.GetPacketBoundChunkSize(), int64(1000))
+
+	context.ApplierMaxAllowedPacket = 64 * 1024 * 1024
+	context.TableAvgRowLength = 100 * 1024
+	test.S(t).ExpectEquals(context.GetPacketBudget(), int64(32*1024*1024))
+	test.S(t).ExpectEquals(context.GetPacketBoundChunkSize(), int64(327))
+
+	context.ObserveRowSize(1024 * 1024)
+	context.ObserveRowSize(1024)
+	test.S(t).ExpectEquals(context.MaxObservedRowSize, int64(1024*1024))
+	test.S(t).ExpectEquals(context.GetPacketBoundChunkSize(), int64(32))
+
+	context.MaxPacketBudget = 512 * 1024
+	test.S(t).ExpectEquals(context.GetPacketBoundChunkSize(), int64(1))
+}
+
+func TestGetMigrationRangeValuesCopyOrder(t *testing.T) {
+	context := NewMigrationContext()
+	context.MigrationRangeMinValues = sql.ToColumnValues([]interface{}{1})
+	context.MigrationRangeMaxValues = sql.ToColumnValues([]interface{}{100})
+	test.S(t).ExpectFalse(context.IsDescendingCopyOrder())
+	test.S(t).ExpectEquals(context.GetMigrationRangeStartValues().String(), "1")
+	test.S(t).ExpectEquals(context.GetMigrationRangeEndValues().String(), "100")
+
+	context.CopyOrder = sql.DescendingCopyOrder
+	test.S(t).ExpectTrue(context.IsDescendingCopyOrder())
+	test.S(t).ExpectEquals(context.GetMigrationRangeStartValues().String(), "100")
+	test.S(t).ExpectEquals(context.GetMigrationRangeEndValues().String(), "1")
+}
+
+func TestSetChunkSizeBounds(t *testing.T) {
+	context := NewMigrationContext()
+	test.S(t).ExpectNil(context.SetChunkSizeBounds(1, 1000000))
+	chunkSizeMin, chunkSizeMax := context.GetChunkSizeBounds()
+	test.S(t).ExpectEquals(chunkSizeMin, int64(10))
+	test.S(t).ExpectEquals(chunkSizeMax, int64(100000))
+
+	test.S(t).ExpectNotNil(context.SetChunkSizeBounds(2000, 1000))
+	test.S(t).ExpectNotNil(context.SetChunkLatencyTarget(0))
+}
+
+func TestAddExplicitColumnRename(t *testing.T) {
+	context := NewMigrationContext()
+	test.S(t).ExpectNil(context.AddExplicitColumnRename("name:full_name"))
+	test.S(t).ExpectNil(context.AddExplicitColumnRename(" age : years "))
+	test.S(t).ExpectEquals(len(context.ExplicitColumnRenameMap), 2)
+	test.S(t).ExpectEquals(context.ExplicitColumnRenameMap["name"], "full_name")
+	test.S(t).ExpectEquals(context.ExplicitColumnRenameMap["age"], "years")
+
+	// malformed
+	test.S(t).ExpectNotNil(context.AddExplicitColumnRename("name"))
+	test.S(t).ExpectNotNil(context.AddExplicitColumnRename("name:"))
+	test.S(t).ExpectNotNil(context.AddExplicitColumnRename("a:b:c"))
+	test.S(t).ExpectNotNil(context.AddExplicitColumnRename("city:CITY"))
+	// conflicting
+	test.S(t).ExpectNotNil(context.AddExplicitColumnRename("NAME:title"))
+	test.S(t).ExpectNotNil(context.AddExplicitColumnRename("title:Full_Name"))
+	test.S(t).ExpectEquals(len(context.ExplicitColumnRenameMap), 2)
+}
+
+func TestCutOverRetries(t *testing.T) {
+	context := NewMigrationContext()
+	context.SetDefaultNumRetries(10)
+	test.S(t).ExpectEquals(context.CutOverMaxRetries(), int64(10))
+	context.CutOverRetryAttempts = 3
+	test.S(t).ExpectEquals(context.CutOverMaxRetries(), int64(3))
+
+	context.CutOverRetryInterval = 5 * time.Second
+	test.S(t).ExpectEquals(context.CutOverRetryWait(1), 5*time.Second)
+	test.S(t).ExpectEquals(context.CutOverRetryWait(4), 5*time.Second)
+
+	context.CutOverExponentialBackoff = true
+	context.ExponentialBackoffMaxInterval = 30
+	test.S(t).ExpectEquals(context.CutOverRetryWait(1), 5*time.Second)
+	test.S(t).ExpectEquals(context.CutOverRetryWait(2), 10*time.Second)
+	test.S(t).ExpectEquals(context.CutOverRetryWait(3), 20*time.Second)
+	test.S(t).ExpectEquals(context.CutOverRetryWait(4), 30*time.Second)
+	test.S(t).ExpectEquals(context.CutOverRetryWait(100), 30*time.Second)
+}
+
+func TestSetCutOverMaxRowDelta(t *testing.T) {
+	context := NewMigrationContext()
+	test.S(t).ExpectNil(context.SetCutOverMaxRowDelta(""))
+	test.S(t).ExpectFalse(context.IsCutOverRowCountChecked())
+
+	test.S(t).ExpectNil(context.SetCutOverMaxRowDelta("1000"))
+	test.S(t).ExpectTrue(context.IsCutOverRowCountChecked())
+	test.S(t).ExpectEquals(context.GetCutOverMaxRowDelta(), "1000")
+	test.S(t).ExpectFalse(context.CutOverRowDeltaExceeded(100000, 99000))
+	test.S(t).ExpectFalse(context.CutOverRowDeltaExceeded(100000, 101000))
+	test.S(t).ExpectTrue(context.CutOverRowDeltaExceeded(100000, 98999))
+	test.S(t).ExpectTrue(context.CutOverRowDeltaExceeded(100000, 101001))
+
+	test.S(t).ExpectNil(context.SetCutOverMaxRowDelta("0.5%"))
+	test.S(t).ExpectEquals(context.GetCutOverMaxRowDelta(), "0.5%")
+	test.S(t).ExpectFalse(context.CutOverRowDeltaExceeded(100000, 99500))
+	test.S(t).ExpectTrue(context.CutOverRowDeltaExceeded(100000, 99499))
+
+	test.S(t).ExpectNil(context.SetCutOverMaxRowDelta("0"))
+	test.S(t).ExpectFalse(context.CutOverRowDeltaExceeded(100, 100))
+	test.S(t).ExpectTrue(context.CutOverRowDeltaExceeded(100, 99))
+
+	atomic.StoreInt64(&context.SkipCutOverRowCountCheckFlag, 1)
+	test.S(t).ExpectFalse(context.IsCutOverRowCountChecked())
+
+	for _, invalid := range []string{"-1", "many", "101%", "-1%", "x%"} {
+		test.S(t).ExpectNotNil(context.SetCutOverMaxRowDelta(invalid))
+	}
+}
+
+func TestSetCutOverLockWaitAndRenameTimeouts(t *testing.T) {
+	context := NewMigrationContext()
+	test.S(t).ExpectNil(context.SetCutOverLockWaitAndRenameTimeouts(0, 0))
+	test.S(t).ExpectEquals(context.GetCutOverLockWaitTimeoutSeconds(), int64(6))
+	test.S(t).ExpectEquals(context.GetCutOverRenameTimeoutSeconds(), int64(3))
+
+	test.S(t).ExpectNil(context.SetCutOverLockWaitAndRenameTimeouts(30*time.Second, time.Second))
+	test.S(t).ExpectEquals(context.GetCutOverLockWaitTimeoutSeconds(), int64(30))
+	test.S(t).ExpectEquals(context.GetCutOverRenameTimeoutSeconds(), int64(1))
+
+	test.S(t).ExpectNil(context.SetCutOverLockWaitAndRenameTimeouts(0, 6*time.Second))
+	test.S(t).ExpectNotNil(context.SetCutOverLockWaitAndRenameTimeouts(0, 7*time.Second))
+	test.S(t).ExpectNotNil(context.SetCutOverLockWaitAndRenameTimeouts(2*time.Second, 3*time.Second))
+	test.S(t).ExpectNotNil(context.SetCutOverLockWaitAndRenameTimeouts(1500*time.Millisecond, 0))
+	test.S(t).ExpectNotNil(context.SetCutOverLockWaitAndRenameTimeouts(0, 500*time.Millisecond))
+}
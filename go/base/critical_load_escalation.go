@@ -0,0 +1,132 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package base
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CriticalLoadStage is the response to a critical load which persists, per the critical-load escalation policy
+type CriticalLoadStage string
+
+const (
+	NoCriticalLoadStage        CriticalLoadStage = ""
+	CriticalLoadThrottleStage  CriticalLoadStage = "throttle"
+	CriticalLoadHibernateStage CriticalLoadStage = "hibernate"
+	CriticalLoadAbortStage     CriticalLoadStage = "abort"
+)
+
+// CriticalLoadEscalation is the critical-load escalation policy: rather than aborting the migration as soon as
+// critical-load is met, it throttles for --critical-load-throttle-duration, then hibernates for
+// --critical-load-hibernate-seconds at a time, and aborts once critical load persists for --critical-load-abort-after.
+// A stage whose duration is not set is skipped. A check which no longer meets critical load ends the escalation.
+type CriticalLoadEscalation struct {
+	mutex             *sync.Mutex
+	throttleDuration  time.Duration
+	hibernateDuration time.Duration
+	abortAfter        time.Duration
+
+	stage      CriticalLoadStage
+	metSince   time.Time
+	stageSince time.Time
+}
+
+func NewCriticalLoadEscalation(throttleDuration time.Duration, hibernateDuration time.Duration, abortAfter time.Duration) *CriticalLoadEscalation {
+	return &CriticalLoadEscalation{
+		mutex:             &sync.Mutex{},
+		throttleDuration:  throttleDuration,
+		hibernateDuration: hibernateDuration,
+		abortAfter:        abortAfter,
+	}
+}
+
+// IsEnabled tells whether critical-load escalates; otherwise critical-load aborts or hibernates right away
+func (this *CriticalLoadEscalation) IsEnabled() bool {
+	return this.throttleDuration > 0 || this.abortAfter > 0
+}
+
+// Evaluate notes whether critical load is met at given time, and returns the stage to respond with, as well as
+// whether the stage just changed
+func (this *CriticalLoadEscalation) Evaluate(met bool, now time.Time) (stage CriticalLoadStage, changed bool) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if !met {
+		changed = this.stage != NoCriticalLoadStage
+		this.stage, this.metSince, this.stageSince = NoCriticalLoadStage, time.Time{}, time.Time{}
+		return this.stage, changed
+	}
+	if this.metSince.IsZero() {
+		this.metSince = now
+	}
+	stage = this.stage
+	switch {
+	case this.abortAfter > 0 && now.Sub(this.metSince) >= this.abortAfter:
+		stage = CriticalLoadAbortStage
+	case stage == NoCriticalLoadStage && (this.throttleDuration > 0 || this.hibernateDuration <= 0):
+		stage = CriticalLoadThrottleStage
+	case stage == NoCriticalLoadStage:
+		stage = CriticalLoadHibernateStage
+	case stage == CriticalLoadThrottleStage && this.hibernateDuration > 0 && now.Sub(this.stageSince) >= this.throttleDuration:
+		stage = CriticalLoadHibernateStage
+	}
+	if stage != this.stage {
+		this.stage, this.stageSince, changed = stage, now, true
+	}
+	return this.stage, changed
+}
+
+// MetFor returns how long critical load has been met for, as of given time
+func (this *CriticalLoadEscalation) MetFor(now time.Time) time.Duration {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.metSince.IsZero() {
+		return 0
+	}
+	return now.Sub(this.metSince)
+}
+
+// NextStage returns the stage escalated to next, should critical load persist, and how long until then
+func (this *CriticalLoadEscalation) NextStage(now time.Time) (stage CriticalLoadStage, until time.Duration) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.stage == NoCriticalLoadStage || this.stage == CriticalLoadAbortStage {
+		return NoCriticalLoadStage, 0
+	}
+	if this.stage == CriticalLoadThrottleStage && this.hibernateDuration > 0 {
+		stage, until = CriticalLoadHibernateStage, this.stageSince.Add(this.throttleDuration).Sub(now)
+	}
+	if this.abortAfter > 0 {
+		if abortUntil := this.metSince.Add(this.abortAfter).Sub(now); stage == NoCriticalLoadStage || abortUntil < until {
+			stage, until = CriticalLoadAbortStage, abortUntil
+		}
+	}
+	if until < 0 {
+		until = 0
+	}
+	return stage, until
+}
+
+// Describe returns the current stage and the next escalation, e.g. "critical-load: throttle, hibernate in 45s";
+// empty when critical load is not met
+func (this *CriticalLoadEscalation) Describe(now time.Time) string {
+	this.mutex.Lock()
+	stage := this.stage
+	this.mutex.Unlock()
+
+	if stage == NoCriticalLoadStage {
+		return ""
+	}
+	description := fmt.Sprintf("critical-load: %s", stage)
+	if nextStage, until := this.NextStage(now); nextStage != NoCriticalLoadStage {
+		description = fmt.Sprintf("%s, %s in %s", description, nextStage, PrettifyDurationOutput(until))
+	}
+	return description
+}
@@ -0,0 +1,99 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package base
+
+import (
+	"testing"
+	"time"
+
+	test "github.com/outbrain/golib/tests"
+)
+
+func TestCriticalLoadEscalation(t *testing.T) {
+	test.S(t).ExpectFalse(NewCriticalLoadEscalation(0, time.Minute, 0).IsEnabled())
+
+	escalation := NewCriticalLoadEscalation(time.Minute, 5*time.Minute, 10*time.Minute)
+	test.S(t).ExpectTrue(escalation.IsEnabled())
+	now := time.Now()
+	{
+		stage, changed := escalation.Evaluate(false, now)
+		test.S(t).ExpectEquals(stage, NoCriticalLoadStage)
+		test.S(t).ExpectFalse(changed)
+		test.S(t).ExpectEquals(escalation.Describe(now), "")
+	}
+	{
+		stage, changed := escalation.Evaluate(true, now)
+		test.S(t).ExpectEquals(stage, CriticalLoadThrottleStage)
+		test.S(t).ExpectTrue(changed)
+		nextStage, until := escalation.NextStage(now.Add(15 * time.Second))
+		test.S(t).ExpectEquals(nextStage, CriticalLoadHibernateStage)
+		test.S(t).ExpectEquals(until, 45*time.Second)
+		test.S(t).ExpectEquals(escalation.Describe(now.Add(15*time.Second)), "critical-load: throttle, hibernate in 45s")
+	}
+	{
+		stage, changed := escalation.Evaluate(true, now.Add(30*time.Second))
+		test.S(t).ExpectEquals(stage, CriticalLoadThrottleStage)
+		test.S(t).ExpectFalse(changed)
+	}
+	{
+		stage, changed := escalation.Evaluate(true, now.Add(time.Minute))
+		test.S(t).ExpectEquals(stage, CriticalLoadHibernateStage)
+		test.S(t).ExpectTrue(changed)
+		nextStage, until := escalation.NextStage(now.Add(time.Minute))
+		test.S(t).ExpectEquals(nextStage, CriticalLoadAbortStage)
+		test.S(t).ExpectEquals(until, 9*time.Minute)
+	}
+	{
+		// Still met after hibernating: hibernates again
+		stage, changed := escalation.Evaluate(true, now.Add(6*time.Minute))
+		test.S(t).ExpectEquals(stage, CriticalLoadHibernateStage)
+		test.S(t).ExpectFalse(changed)
+		test.S(t).ExpectEquals(escalation.MetFor(now.Add(6*time.Minute)), 6*time.Minute)
+	}
+	{
+		stage, changed := escalation.Evaluate(true, now.Add(11*time.Minute))
+		test.S(t).ExpectEquals(stage, CriticalLoadAbortStage)
+		test.S(t).ExpectTrue(changed)
+	}
+	{
+		// Not met: the escalation starts over
+		stage, changed := escalation.Evaluate(false, now.Add(12*time.Minute))
+		test.S(t).ExpectEquals(stage, NoCriticalLoadStage)
+		test.S(t).ExpectTrue(changed)
+		stage, _ = escalation.Evaluate(true, now.Add(13*time.Minute))
+		test.S(t).ExpectEquals(stage, CriticalLoadThrottleStage)
+		test.S(t).ExpectEquals(escalation.MetFor(now.Add(14*time.Minute)), time.Minute)
+	}
+}
+
+func TestCriticalLoadEscalationSkipsStages(t *testing.T) {
+	now := time.Now()
+	{
+		// No throttle duration: hibernates right away
+		escalation := NewCriticalLoadEscalation(0, 5*time.Minute, 10*time.Minute)
+		stage, _ := escalation.Evaluate(true, now)
+		test.S(t).ExpectEquals(stage, CriticalLoadHibernateStage)
+	}
+	{
+		// No hibernation: throttles until aborting
+		escalation := NewCriticalLoadEscalation(time.Minute, 0, 10*time.Minute)
+		stage, _ := escalation.Evaluate(true, now)
+		test.S(t).ExpectEquals(stage, CriticalLoadThrottleStage)
+		stage, _ = escalation.Evaluate(true, now.Add(5*time.Minute))
+		test.S(t).ExpectEquals(stage, CriticalLoadThrottleStage)
+		test.S(t).ExpectEquals(escalation.Describe(now.Add(5*time.Minute)), "critical-load: throttle, abort in 5m0s")
+		stage, _ = escalation.Evaluate(true, now.Add(10*time.Minute))
+		test.S(t).ExpectEquals(stage, CriticalLoadAbortStage)
+	}
+	{
+		// No abort: hibernates for as long as critical load persists
+		escalation := NewCriticalLoadEscalation(time.Minute, 5*time.Minute, 0)
+		escalation.Evaluate(true, now)
+		stage, _ := escalation.Evaluate(true, now.Add(time.Hour))
+		test.S(t).ExpectEquals(stage, CriticalLoadHibernateStage)
+		test.S(t).ExpectEquals(escalation.Describe(now.Add(time.Hour)), "critical-load: hibernate")
+	}
+}
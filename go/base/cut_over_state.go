@@ -0,0 +1,158 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package base
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CutOverPhase is a phase of a cut-over attempt
+type CutOverPhase string
+
+const (
+	CutOverPhaseNone             CutOverPhase = ""
+	CutOverPhaseCreatingSentry   CutOverPhase = "creating-sentry"
+	CutOverPhaseLocking          CutOverPhase = "locking"
+	CutOverPhaseWaitingForEvents CutOverPhase = "waiting-for-events"
+	CutOverPhaseRenaming         CutOverPhase = "renaming"
+	CutOverPhaseVerifying        CutOverPhase = "verifying"
+	CutOverPhaseRolledBack       CutOverPhase = "rolled-back"
+	CutOverPhaseDone             CutOverPhase = "done"
+)
+
+// cutOverPhases are the phases a cut-over attempt goes through, in order
+var cutOverPhases = []CutOverPhase{
+	CutOverPhaseCreatingSentry,
+	CutOverPhaseLocking,
+	CutOverPhaseWaitingForEvents,
+	CutOverPhaseRenaming,
+	CutOverPhaseVerifying,
+}
+
+// writesBlockingCutOverPhases are the phases in which writes onto the original table are blocked: by the
+// pending or held cut-over lock
+var writesBlockingCutOverPhases = map[CutOverPhase]bool{
+	CutOverPhaseLocking:          true,
+	CutOverPhaseWaitingForEvents: true,
+	CutOverPhaseRenaming:         true,
+	CutOverPhaseVerifying:        true,
+}
+
+// CutOverState is the state machine of the current, or last, cut-over attempt: its phase, since when, and the
+// time spent in each phase so far
+type CutOverState struct {
+	mutex              *sync.Mutex
+	phase              CutOverPhase
+	since              time.Time
+	durations          map[CutOverPhase]time.Duration
+	totalWritesBlocked time.Duration
+	failedPhase        CutOverPhase
+}
+
+func NewCutOverState() *CutOverState {
+	return &CutOverState{
+		mutex:     &sync.Mutex{},
+		durations: make(map[CutOverPhase]time.Duration),
+	}
+}
+
+// Begin begins a cut-over attempt, resetting the time spent in each phase
+func (this *CutOverState) Begin() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.phase = CutOverPhaseNone
+	this.since = time.Now()
+	this.durations = make(map[CutOverPhase]time.Duration)
+	this.failedPhase = CutOverPhaseNone
+}
+
+// Transition moves the attempt onto given phase. It returns the phase moved from, and the time spent in it.
+func (this *CutOverState) Transition(phase CutOverPhase) (previous CutOverPhase, elapsed time.Duration) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	now := time.Now()
+	previous, elapsed = this.phase, now.Sub(this.since)
+	if previous != CutOverPhaseNone {
+		this.durations[previous] += elapsed
+		if writesBlockingCutOverPhases[previous] {
+			this.totalWritesBlocked += elapsed
+		}
+	}
+	if phase == CutOverPhaseRolledBack {
+		this.failedPhase = previous
+	}
+	this.phase = phase
+	this.since = now
+	return previous, elapsed
+}
+
+// Phase returns the attempt's current phase, and the time spent in it so far
+func (this *CutOverState) Phase() (CutOverPhase, time.Duration) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.phase, time.Since(this.since)
+}
+
+// FailedPhase returns the phase in which a rolled back attempt failed
+func (this *CutOverState) FailedPhase() CutOverPhase {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.failedPhase
+}
+
+// Durations describes the time the attempt spent in each phase, including the current one, e.g.
+// "creating-sentry: 12ms, locking: 1.5s, waiting-for-events: 230ms"
+func (this *CutOverState) Durations() string {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	durations := []string{}
+	for _, phase := range cutOverPhases {
+		duration, ok := this.durations[phase]
+		if phase == this.phase {
+			duration, ok = duration+time.Since(this.since), true
+		}
+		if ok {
+			durations = append(durations, fmt.Sprintf("%s: %+v", phase, duration.Round(time.Millisecond)))
+		}
+	}
+	return strings.Join(durations, ", ")
+}
+
+// WritesBlocked returns the time writes onto the original table were blocked, by the current or last
+// attempt and by all attempts
+func (this *CutOverState) WritesBlocked() (attempt time.Duration, total time.Duration) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	for phase := range writesBlockingCutOverPhases {
+		attempt += this.durations[phase]
+	}
+	total = this.totalWritesBlocked
+	if writesBlockingCutOverPhases[this.phase] {
+		current := time.Since(this.since)
+		attempt += current
+		total += current
+	}
+	return attempt, total
+}
+
+// Summary describes the attempt's phase and the time spent in each phase, for logs and failure reports
+func (this *CutOverState) Summary() string {
+	phase, _ := this.Phase()
+	description := string(phase)
+	if phase == CutOverPhaseNone {
+		description = "none"
+	}
+	if failedPhase := this.FailedPhase(); phase == CutOverPhaseRolledBack && failedPhase != CutOverPhaseNone {
+		description = fmt.Sprintf("%s, failed in %s", phase, failedPhase)
+	}
+	attemptWritesBlocked, totalWritesBlocked := this.WritesBlocked()
+	return fmt.Sprintf("cut-over phase: %s (%s); writes blocked: %+v (all attempts: %+v)",
+		description, this.Durations(), attemptWritesBlocked.Round(time.Millisecond), totalWritesBlocked.Round(time.Millisecond),
+	)
+}
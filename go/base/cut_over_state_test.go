@@ -0,0 +1,53 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package base
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	test "github.com/outbrain/golib/tests"
+)
+
+func TestCutOverState(t *testing.T) {
+	state := NewCutOverState()
+	phase, _ := state.Phase()
+	test.S(t).ExpectEquals(phase, CutOverPhaseNone)
+	test.S(t).ExpectEquals(state.Durations(), "")
+
+	state.Begin()
+	previous, _ := state.Transition(CutOverPhaseCreatingSentry)
+	test.S(t).ExpectEquals(previous, CutOverPhaseNone)
+	previous, _ = state.Transition(CutOverPhaseLocking)
+	test.S(t).ExpectEquals(previous, CutOverPhaseCreatingSentry)
+	time.Sleep(20 * time.Millisecond)
+	state.Transition(CutOverPhaseWaitingForEvents)
+	state.Transition(CutOverPhaseRolledBack)
+
+	phase, _ = state.Phase()
+	test.S(t).ExpectEquals(phase, CutOverPhaseRolledBack)
+	test.S(t).ExpectEquals(state.FailedPhase(), CutOverPhaseWaitingForEvents)
+	durations := state.Durations()
+	test.S(t).ExpectTrue(strings.HasPrefix(durations, "creating-sentry: "))
+	test.S(t).ExpectTrue(strings.Contains(durations, ", locking: "))
+	test.S(t).ExpectTrue(strings.Contains(durations, ", waiting-for-events: "))
+	test.S(t).ExpectFalse(strings.Contains(durations, "renaming"))
+	attemptWritesBlocked, totalWritesBlocked := state.WritesBlocked()
+	test.S(t).ExpectTrue(attemptWritesBlocked >= 20*time.Millisecond)
+	test.S(t).ExpectEquals(totalWritesBlocked, attemptWritesBlocked)
+	test.S(t).ExpectTrue(strings.HasPrefix(state.Summary(), "cut-over phase: rolled-back, failed in waiting-for-events (creating-sentry: "))
+
+	// A new attempt resets the phase durations, while writes blocked accumulate across attempts
+	state.Begin()
+	test.S(t).ExpectEquals(state.FailedPhase(), CutOverPhaseNone)
+	state.Transition(CutOverPhaseLocking)
+	state.Transition(CutOverPhaseDone)
+	test.S(t).ExpectTrue(strings.HasPrefix(state.Durations(), "locking: "))
+	attemptWritesBlocked, totalWritesBlocked = state.WritesBlocked()
+	test.S(t).ExpectTrue(attemptWritesBlocked < 20*time.Millisecond)
+	test.S(t).ExpectTrue(totalWritesBlocked >= 20*time.Millisecond)
+}
@@ -0,0 +1,96 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package base
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var cutOverWindowRegexp = regexp.MustCompile(`^([0-9]{1,2}):([0-9]{2})-([0-9]{1,2}):([0-9]{2})$`)
+
+// CutOverWindow is a daily wall clock window, e.g. 02:00-04:00, within which cut-over may take place.
+// A window ending earlier than it starts, e.g. 23:00-01:00, spans midnight.
+type CutOverWindow struct {
+	Start    time.Duration // since midnight
+	End      time.Duration // since midnight
+	Location *time.Location
+}
+
+// ParseCutOverWindow parses a --cut-over-window value, HH:MM-HH:MM, in given time zone
+func ParseCutOverWindow(window string, location *time.Location) (*CutOverWindow, error) {
+	return parseDailyWindow("cut-over window", window, location)
+}
+
+// parseDailyWindow parses a daily HH:MM-HH:MM window, in given time zone. kind names the window in errors.
+func parseDailyWindow(kind string, window string, location *time.Location) (*CutOverWindow, error) {
+	submatch := cutOverWindowRegexp.FindStringSubmatch(window)
+	if submatch == nil {
+		return nil, fmt.Errorf("Invalid %s: %s. Expected HH:MM-HH:MM, e.g. 02:00-04:00", kind, window)
+	}
+	parseTimeOfDay := func(hours, minutes string) (time.Duration, error) {
+		h, _ := strconv.Atoi(hours)
+		m, _ := strconv.Atoi(minutes)
+		if h > 23 || m > 59 {
+			return 0, fmt.Errorf("Invalid %s: %s. %s:%s is not a time of day", kind, window, hours, minutes)
+		}
+		return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+	}
+	start, err := parseTimeOfDay(submatch[1], submatch[2])
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseTimeOfDay(submatch[3], submatch[4])
+	if err != nil {
+		return nil, err
+	}
+	if start == end {
+		return nil, fmt.Errorf("Invalid %s: %s. Window is empty", kind, window)
+	}
+	return &CutOverWindow{Start: start, End: end, Location: location}, nil
+}
+
+// sinceMidnight returns the wall clock time of day of given time, in the window's time zone
+func (this *CutOverWindow) sinceMidnight(t time.Time) time.Duration {
+	t = t.In(this.Location)
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+}
+
+// Contains tells whether given time is within the window
+func (this *CutOverWindow) Contains(t time.Time) bool {
+	timeOfDay := this.sinceMidnight(t)
+	if this.Start < this.End {
+		return timeOfDay >= this.Start && timeOfDay < this.End
+	}
+	return timeOfDay >= this.Start || timeOfDay < this.End
+}
+
+// Until returns the time from given time until the window next opens, or 0 while within the window
+func (this *CutOverWindow) Until(t time.Time) time.Duration {
+	if this.Contains(t) {
+		return 0
+	}
+	t = t.In(this.Location)
+	start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, this.Location).Add(this.Start)
+	if !start.After(t) {
+		start = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, this.Location).Add(this.Start)
+	}
+	return start.Sub(t)
+}
+
+// hours returns the window as HH:MM-HH:MM
+func (this *CutOverWindow) hours() string {
+	formatTimeOfDay := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return fmt.Sprintf("%s-%s", formatTimeOfDay(this.Start), formatTimeOfDay(this.End))
+}
+
+func (this *CutOverWindow) String() string {
+	return fmt.Sprintf("%s (%s)", this.hours(), this.Location)
+}
@@ -0,0 +1,66 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package base
+
+import (
+	"testing"
+	"time"
+
+	test "github.com/outbrain/golib/tests"
+)
+
+func TestParseCutOverWindow(t *testing.T) {
+	{
+		window, err := ParseCutOverWindow("02:00-04:30", time.UTC)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(window.Start, 2*time.Hour)
+		test.S(t).ExpectEquals(window.End, 4*time.Hour+30*time.Minute)
+		test.S(t).ExpectEquals(window.String(), "02:00-04:30 (UTC)")
+	}
+	{
+		window, err := ParseCutOverWindow("23:00-1:00", time.UTC)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(window.String(), "23:00-01:00 (UTC)")
+	}
+	for _, invalid := range []string{"", "02:00", "02:00-", "2-4", "24:00-01:00", "02:60-04:00", "02:00-02:00", "02:00 - 04:00"} {
+		_, err := ParseCutOverWindow(invalid, time.UTC)
+		test.S(t).ExpectNotNil(err)
+	}
+}
+
+func TestCutOverWindow(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2022, 3, 15, hour, minute, 0, 0, time.UTC)
+	}
+	{
+		window, _ := ParseCutOverWindow("02:00-04:00", time.UTC)
+		test.S(t).ExpectFalse(window.Contains(at(1, 59)))
+		test.S(t).ExpectTrue(window.Contains(at(2, 0)))
+		test.S(t).ExpectTrue(window.Contains(at(3, 59)))
+		test.S(t).ExpectFalse(window.Contains(at(4, 0)))
+
+		test.S(t).ExpectEquals(window.Until(at(3, 0)), time.Duration(0))
+		test.S(t).ExpectEquals(window.Until(at(1, 30)), 30*time.Minute)
+		// missed today's window: wait for tomorrow's
+		test.S(t).ExpectEquals(window.Until(at(20, 48)), 5*time.Hour+12*time.Minute)
+	}
+	{
+		window, _ := ParseCutOverWindow("23:00-01:00", time.UTC)
+		test.S(t).ExpectTrue(window.Contains(at(23, 30)))
+		test.S(t).ExpectTrue(window.Contains(at(0, 30)))
+		test.S(t).ExpectFalse(window.Contains(at(1, 0)))
+		test.S(t).ExpectFalse(window.Contains(at(22, 59)))
+		test.S(t).ExpectEquals(window.Until(at(12, 0)), 11*time.Hour)
+	}
+	{
+		// wall clock time is that of the window's time zone
+		location := time.FixedZone("UTC+2", 2*60*60)
+		window, _ := ParseCutOverWindow("02:00-04:00", location)
+		test.S(t).ExpectTrue(window.Contains(at(0, 30)))
+		test.S(t).ExpectFalse(window.Contains(at(2, 30)))
+		test.S(t).ExpectEquals(window.Until(at(2, 30)), 21*time.Hour+30*time.Minute)
+	}
+}
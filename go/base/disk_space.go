@@ -0,0 +1,97 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package base
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var diskSpaceThresholdRegexp = regexp.MustCompile(`^([0-9]+(?:[.][0-9]+)?)\s*(%|B|K|KB|M|MB|G|GB|T|TB)?$`)
+
+var diskSpaceUnits = map[string]float64{
+	"":   1,
+	"B":  1,
+	"K":  1 << 10,
+	"KB": 1 << 10,
+	"M":  1 << 20,
+	"MB": 1 << 20,
+	"G":  1 << 30,
+	"GB": 1 << 30,
+	"T":  1 << 40,
+	"TB": 1 << 40,
+}
+
+// DiskSpaceThreshold is a free disk space threshold, either in bytes or in percent of the disk's size,
+// as per --min-free-disk-space and --critical-free-disk-space
+type DiskSpaceThreshold struct {
+	Bytes   int64
+	Percent float64
+	token   string
+}
+
+// ParseDiskSpaceThreshold parses a threshold such as 100GB, 500M or 10%. Byte units are powers of 1024.
+func ParseDiskSpaceThreshold(threshold string) (*DiskSpaceThreshold, error) {
+	token := strings.TrimSpace(threshold)
+	submatch := diskSpaceThresholdRegexp.FindStringSubmatch(strings.ToUpper(token))
+	if len(submatch) == 0 {
+		return nil, fmt.Errorf("Cannot parse disk space threshold: %s. Expected e.g. 100GB or 10%%", threshold)
+	}
+	value, err := strconv.ParseFloat(submatch[1], 64)
+	if err != nil {
+		return nil, err
+	}
+	if submatch[2] == "%" {
+		if value <= 0 || value >= 100 {
+			return nil, fmt.Errorf("Disk space threshold %s must be between 0%% and 100%%", threshold)
+		}
+		return &DiskSpaceThreshold{Percent: value, token: token}, nil
+	}
+	bytes := int64(value * diskSpaceUnits[submatch[2]])
+	if bytes <= 0 {
+		return nil, fmt.Errorf("Disk space threshold %s must be positive", threshold)
+	}
+	return &DiskSpaceThreshold{Bytes: bytes, token: token}, nil
+}
+
+// IsPercent tells whether the threshold is in percent of the disk's size, and thus requires the size be known
+func (this *DiskSpaceThreshold) IsPercent() bool {
+	return this.Percent > 0
+}
+
+// IsMet tells whether given free bytes, out of given total bytes, fall below the threshold
+func (this *DiskSpaceThreshold) IsMet(freeBytes int64, totalBytes int64) (bool, error) {
+	if !this.IsPercent() {
+		return freeBytes < this.Bytes, nil
+	}
+	if totalBytes <= 0 {
+		return false, fmt.Errorf("disk size unknown; cannot evaluate %s", this.token)
+	}
+	return float64(freeBytes)*100 < this.Percent*float64(totalBytes), nil
+}
+
+func (this *DiskSpaceThreshold) String() string {
+	if this == nil {
+		return "none"
+	}
+	return this.token
+}
+
+// DescribeFreeDiskSpace formats free bytes, along with their share of the total bytes if known, e.g. 120.5 GB (12.1%)
+func DescribeFreeDiskSpace(freeBytes int64, totalBytes int64) string {
+	if totalBytes <= 0 {
+		return PrettifyBytes(float64(freeBytes))
+	}
+	return fmt.Sprintf("%s (%.1f%%)", PrettifyBytes(float64(freeBytes)), float64(freeBytes)*100/float64(totalBytes))
+}
+
+// IsDiskSpaceCheckEnabled tells whether the applier's free disk space is checked, per --min-free-disk-space
+// or --critical-free-disk-space
+func (this *MigrationContext) IsDiskSpaceCheckEnabled() bool {
+	return this.MinFreeDiskSpace != nil || this.CriticalFreeDiskSpace != nil
+}
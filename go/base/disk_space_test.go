@@ -0,0 +1,63 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package base
+
+import (
+	"testing"
+
+	test "github.com/outbrain/golib/tests"
+)
+
+func TestParseDiskSpaceThreshold(t *testing.T) {
+	{
+		threshold, err := ParseDiskSpaceThreshold("100GB")
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(threshold.Bytes, int64(100<<30))
+		test.S(t).ExpectFalse(threshold.IsPercent())
+		test.S(t).ExpectEquals(threshold.String(), "100GB")
+	}
+	{
+		threshold, err := ParseDiskSpaceThreshold("1.5t")
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(threshold.Bytes, int64(3<<39))
+	}
+	{
+		threshold, err := ParseDiskSpaceThreshold("10%")
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectTrue(threshold.IsPercent())
+		test.S(t).ExpectEquals(threshold.Percent, 10.0)
+	}
+	for _, invalid := range []string{"", "GB", "-5GB", "100PB", "0%", "100%", "0"} {
+		_, err := ParseDiskSpaceThreshold(invalid)
+		test.S(t).ExpectNotNil(err)
+	}
+}
+
+func TestDiskSpaceThresholdIsMet(t *testing.T) {
+	{
+		threshold, _ := ParseDiskSpaceThreshold("100GB")
+		met, err := threshold.IsMet(99<<30, 0)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectTrue(met)
+		met, _ = threshold.IsMet(100<<30, 0)
+		test.S(t).ExpectFalse(met)
+	}
+	{
+		threshold, _ := ParseDiskSpaceThreshold("10%")
+		met, err := threshold.IsMet(9, 100)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectTrue(met)
+		met, _ = threshold.IsMet(10, 100)
+		test.S(t).ExpectFalse(met)
+		_, err = threshold.IsMet(9, 0)
+		test.S(t).ExpectNotNil(err)
+	}
+}
+
+func TestDescribeFreeDiskSpace(t *testing.T) {
+	test.S(t).ExpectEquals(DescribeFreeDiskSpace(120<<30, 0), "120.0 GB")
+	test.S(t).ExpectEquals(DescribeFreeDiskSpace(120<<30, 1000<<30), "120.0 GB (12.0%)")
+}
@@ -0,0 +1,156 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package base
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	rateLimiterMaxWait        = time.Second
+	rateLimiterSampleInterval = time.Second
+)
+
+// RateLimiter is a token bucket limiting the rate of rows written per second. Writers take the rows
+// they write, possibly running into debt, and wait for the debt to be repaid before writing further.
+// The bucket holds up to a second's worth of rows. A rate of 0 disables the limit.
+type RateLimiter struct {
+	rate int64
+
+	mutex      *sync.Mutex
+	scale      float64
+	tokens     float64
+	lastRefill time.Time
+
+	sampleStart time.Time
+	sampleRows  int64
+	currentRate float64
+}
+
+func NewRateLimiter(rate int64) *RateLimiter {
+	limiter := &RateLimiter{
+		mutex:       &sync.Mutex{},
+		scale:       1,
+		lastRefill:  time.Now(),
+		sampleStart: time.Now(),
+	}
+	limiter.SetRate(rate)
+	return limiter
+}
+
+// GetRate returns the rate limit, in rows per second
+func (this *RateLimiter) GetRate() int64 {
+	return atomic.LoadInt64(&this.rate)
+}
+
+// SetRate sets the rate limit, in rows per second; 0 disables the limit
+func (this *RateLimiter) SetRate(rate int64) {
+	if rate < 0 {
+		rate = 0
+	}
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.refill(time.Now())
+	atomic.StoreInt64(&this.rate, rate)
+	if effectiveRate := this.effectiveRate(); this.tokens > effectiveRate {
+		this.tokens = effectiveRate
+	}
+}
+
+// SetScale scales the rate limit, e.g. while ramping up after throttling, such that the effective rate is
+// the rate times given scale, in the range (0..1]. The rate as set, and GetRate(), are unaffected.
+func (this *RateLimiter) SetScale(scale float64) {
+	if scale <= 0 || scale > 1 {
+		scale = 1
+	}
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.refill(time.Now())
+	this.scale = scale
+	if effectiveRate := this.effectiveRate(); this.tokens > effectiveRate {
+		this.tokens = effectiveRate
+	}
+}
+
+// effectiveRate returns the scaled rate limit. Must be called under mutex.
+func (this *RateLimiter) effectiveRate() float64 {
+	return float64(atomic.LoadInt64(&this.rate)) * this.scale
+}
+
+// refill adds tokens for the time passed since last refilled. Must be called under mutex.
+func (this *RateLimiter) refill(now time.Time) {
+	rate := this.effectiveRate()
+	elapsed := now.Sub(this.lastRefill)
+	this.lastRefill = now
+	if rate == 0 {
+		// No limit, hence no debt
+		this.tokens = 0
+		return
+	}
+	this.tokens += elapsed.Seconds() * rate
+	if this.tokens > rate {
+		this.tokens = rate
+	}
+}
+
+// Take accounts for given number of rows written. It does not block; see Wait()
+func (this *RateLimiter) Take(rows int64) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	now := time.Now()
+	this.refill(now)
+	if atomic.LoadInt64(&this.rate) > 0 {
+		this.tokens -= float64(rows)
+	}
+	this.sampleRows += rows
+	if elapsed := now.Sub(this.sampleStart); elapsed >= rateLimiterSampleInterval {
+		this.currentRate = float64(this.sampleRows) / elapsed.Seconds()
+		this.sampleStart = now
+		this.sampleRows = 0
+	}
+}
+
+// Wait blocks for as long as rows taken exceed the rate limit
+func (this *RateLimiter) Wait() {
+	for {
+		waitDuration := func() time.Duration {
+			this.mutex.Lock()
+			defer this.mutex.Unlock()
+
+			this.refill(time.Now())
+			rate := this.effectiveRate()
+			if rate == 0 || this.tokens >= 0 {
+				return 0
+			}
+			return time.Duration(-this.tokens / rate * float64(time.Second))
+		}()
+		if waitDuration <= 0 {
+			return
+		}
+		// Wake up periodically, so that a changed rate applies
+		if waitDuration > rateLimiterMaxWait {
+			waitDuration = rateLimiterMaxWait
+		}
+		time.Sleep(waitDuration)
+	}
+}
+
+// GetCurrentRate returns the recent rate of rows taken, per second
+func (this *RateLimiter) GetCurrentRate() float64 {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if elapsed := time.Since(this.sampleStart); elapsed >= 2*rateLimiterSampleInterval {
+		// No rows taken lately
+		return float64(this.sampleRows) / elapsed.Seconds()
+	}
+	return this.currentRate
+}
@@ -0,0 +1,56 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package base
+
+import (
+	"testing"
+	"time"
+
+	test "github.com/outbrain/golib/tests"
+)
+
+func TestRateLimiterUnlimited(t *testing.T) {
+	limiter := NewRateLimiter(0)
+	limiter.Take(1000000)
+	startTime := time.Now()
+	limiter.Wait()
+	test.S(t).ExpectTrue(time.Since(startTime) < 50*time.Millisecond)
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	limiter := NewRateLimiter(10000)
+	limiter.Take(2000)
+	startTime := time.Now()
+	limiter.Wait()
+	elapsed := time.Since(startTime)
+	test.S(t).ExpectTrue(elapsed >= 150*time.Millisecond)
+	test.S(t).ExpectTrue(elapsed < time.Second)
+
+	// Disabling the limit forgives the debt
+	limiter.Take(100000)
+	limiter.SetRate(0)
+	startTime = time.Now()
+	limiter.Wait()
+	test.S(t).ExpectTrue(time.Since(startTime) < 50*time.Millisecond)
+	test.S(t).ExpectEquals(limiter.GetRate(), int64(0))
+}
+
+func TestRateLimiterNegativeRate(t *testing.T) {
+	limiter := NewRateLimiter(-5)
+	test.S(t).ExpectEquals(limiter.GetRate(), int64(0))
+}
+
+func TestRateLimiterScale(t *testing.T) {
+	limiter := NewRateLimiter(10000)
+	limiter.SetScale(0.5)
+	test.S(t).ExpectEquals(limiter.GetRate(), int64(10000))
+	limiter.Take(1000)
+	startTime := time.Now()
+	limiter.Wait()
+	elapsed := time.Since(startTime)
+	test.S(t).ExpectTrue(elapsed >= 150*time.Millisecond)
+	test.S(t).ExpectTrue(elapsed < time.Second)
+}
@@ -0,0 +1,77 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package base
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ThrottleDuration is the cumulative time the migration was throttled by a given criterion. Criteria are stable
+// identifiers, free of measured values, e.g. replica-lag:replica1:3306 or max-load:Threads_running, such that
+// durations aggregate across migrations.
+type ThrottleDuration struct {
+	Criterion string
+	Duration  time.Duration
+}
+
+// AddThrottleDurations accounts elapsed time onto the total throttled time, and onto each of the criteria the
+// migration was throttled by. Criteria may overlap, such that their durations add up to more than the total.
+func (this *MigrationContext) AddThrottleDurations(criteria []string, elapsed time.Duration) {
+	this.throttleMutex.Lock()
+	defer this.throttleMutex.Unlock()
+
+	this.totalThrottledDuration += elapsed
+	accounted := make(map[string]bool)
+	for _, criterion := range criteria {
+		if accounted[criterion] {
+			continue
+		}
+		accounted[criterion] = true
+		this.throttleDurations[criterion] += elapsed
+	}
+}
+
+// GetThrottleDurations returns the total throttled time, and the throttled time by criterion, longest first
+func (this *MigrationContext) GetThrottleDurations() (total time.Duration, durations []ThrottleDuration) {
+	this.throttleMutex.Lock()
+	defer this.throttleMutex.Unlock()
+
+	for criterion, duration := range this.throttleDurations {
+		durations = append(durations, ThrottleDuration{Criterion: criterion, Duration: duration})
+	}
+	sort.Slice(durations, func(i, j int) bool {
+		if durations[i].Duration != durations[j].Duration {
+			return durations[i].Duration > durations[j].Duration
+		}
+		return durations[i].Criterion < durations[j].Criterion
+	})
+	return this.totalThrottledDuration, durations
+}
+
+// DescribeThrottleDurations lists the first limit durations, or all given a non-positive limit,
+// e.g. "replica-lag:replica1:3306 12m4s, flag-file:/tmp/ghost.throttle 30s"
+func DescribeThrottleDurations(durations []ThrottleDuration, limit int) string {
+	if limit > 0 && len(durations) > limit {
+		durations = durations[:limit]
+	}
+	descriptions := []string{}
+	for _, duration := range durations {
+		descriptions = append(descriptions, fmt.Sprintf("%s %+v", duration.Criterion, duration.Duration.Round(time.Second)))
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+// ThrottleDurationsEnvValue lists all durations as criterion=seconds, comma delimited, for hooks
+func ThrottleDurationsEnvValue(durations []ThrottleDuration) string {
+	values := []string{}
+	for _, duration := range durations {
+		values = append(values, fmt.Sprintf("%s=%.3f", duration.Criterion, duration.Duration.Seconds()))
+	}
+	return strings.Join(values, ",")
+}
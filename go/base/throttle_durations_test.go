@@ -0,0 +1,34 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package base
+
+import (
+	"testing"
+	"time"
+
+	test "github.com/outbrain/golib/tests"
+)
+
+func TestThrottleDurations(t *testing.T) {
+	context := NewMigrationContext()
+	{
+		total, durations := context.GetThrottleDurations()
+		test.S(t).ExpectEquals(total, time.Duration(0))
+		test.S(t).ExpectEquals(len(durations), 0)
+		test.S(t).ExpectEquals(DescribeThrottleDurations(durations, 3), "")
+	}
+	context.AddThrottleDurations([]string{"flag-file:/tmp/ghost.throttle"}, 30*time.Second)
+	context.AddThrottleDurations([]string{"replica-lag:replica1:3306", "http", "replica-lag:replica1:3306"}, time.Minute)
+	context.AddThrottleDurations([]string{"replica-lag:replica1:3306"}, time.Minute)
+
+	total, durations := context.GetThrottleDurations()
+	test.S(t).ExpectEquals(total, 2*time.Minute+30*time.Second)
+	test.S(t).ExpectEquals(len(durations), 3)
+	test.S(t).ExpectEquals(durations[0], ThrottleDuration{Criterion: "replica-lag:replica1:3306", Duration: 2 * time.Minute})
+	test.S(t).ExpectEquals(durations[1], ThrottleDuration{Criterion: "http", Duration: time.Minute})
+	test.S(t).ExpectEquals(DescribeThrottleDurations(durations, 2), "replica-lag:replica1:3306 2m0s, http 1m0s")
+	test.S(t).ExpectEquals(ThrottleDurationsEnvValue(durations), "replica-lag:replica1:3306=120.000,http=60.000,flag-file:/tmp/ghost.throttle=30.000")
+}
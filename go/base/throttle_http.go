@@ -0,0 +1,120 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package base
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"text/template"
+)
+
+// ThrottleHTTPUnreachablePolicy is what to do when the --throttle-http URL cannot be reached, e.g. on
+// connection errors or timeouts. Responses other than 200 always throttle.
+type ThrottleHTTPUnreachablePolicy string
+
+const (
+	ThrottleHTTPUnreachableThrottle ThrottleHTTPUnreachablePolicy = "throttle"
+	ThrottleHTTPUnreachableIgnore   ThrottleHTTPUnreachablePolicy = "ignore"
+	ThrottleHTTPUnreachablePanic    ThrottleHTTPUnreachablePolicy = "panic"
+)
+
+// ParseThrottleHTTPUnreachablePolicy parses a --throttle-http-unreachable-policy value: throttle, ignore or panic
+func ParseThrottleHTTPUnreachablePolicy(policy string) (ThrottleHTTPUnreachablePolicy, error) {
+	switch ThrottleHTTPUnreachablePolicy(policy) {
+	case ThrottleHTTPUnreachableThrottle, ThrottleHTTPUnreachableIgnore, ThrottleHTTPUnreachablePanic:
+		return ThrottleHTTPUnreachablePolicy(policy), nil
+	}
+	return "", fmt.Errorf("Unknown throttle-http unreachable policy: %s. Expected throttle, ignore or panic", policy)
+}
+
+// ParseThrottleHTTPMethod parses a --throttle-http-method value: HEAD, GET or POST
+func ParseThrottleHTTPMethod(method string) (string, error) {
+	switch method = strings.ToUpper(method); method {
+	case http.MethodHead, http.MethodGet, http.MethodPost:
+		return method, nil
+	}
+	return "", fmt.Errorf("Unsupported throttle-http method: %s. Expected HEAD, GET or POST", method)
+}
+
+// ParseHTTPHeader parses a "Name: value" header, as given to --throttle-http-header
+func ParseHTTPHeader(header string) (name string, value string, err error) {
+	tokens := strings.SplitN(header, ":", 2)
+	if len(tokens) != 2 || strings.TrimSpace(tokens[0]) == "" {
+		return "", "", fmt.Errorf("Cannot parse HTTP header: %s. Expected Name: value", header)
+	}
+	return strings.TrimSpace(tokens[0]), strings.TrimSpace(tokens[1]), nil
+}
+
+// AddThrottleHTTPHeader adds a "Name: value" header onto --throttle-http requests, as per --throttle-http-header
+func (this *MigrationContext) AddThrottleHTTPHeader(header string) error {
+	name, value, err := ParseHTTPHeader(header)
+	if err != nil {
+		return err
+	}
+	this.ThrottleHTTPHeaders.Add(name, value)
+	return nil
+}
+
+// ThrottleHTTPRequestBody is the migration's identity and progress, which --throttle-http POST requests carry.
+// --throttle-http-body templates render it.
+type ThrottleHTTPRequestBody struct {
+	Uuid           string  `json:"uuid"`
+	Host           string  `json:"host"`
+	Database       string  `json:"database"`
+	Table          string  `json:"table"`
+	GhostTable     string  `json:"ghost_table"`
+	RowsCopied     int64   `json:"rows_copied"`
+	RowsEstimate   int64   `json:"rows_estimate"`
+	ProgressPct    float64 `json:"progress_pct"`
+	ElapsedSeconds int64   `json:"elapsed_seconds"`
+	ETASeconds     int64   `json:"eta_seconds"`
+}
+
+// NewThrottleHTTPRequestBody returns the migration's current identity and progress
+func (this *MigrationContext) NewThrottleHTTPRequestBody() *ThrottleHTTPRequestBody {
+	body := &ThrottleHTTPRequestBody{
+		Uuid:           this.Uuid,
+		Database:       this.DatabaseName,
+		Table:          this.OriginalTableName,
+		GhostTable:     this.GetGhostTableName(),
+		RowsCopied:     this.GetTotalRowsCopied(),
+		RowsEstimate:   atomic.LoadInt64(&this.RowsEstimate) + atomic.LoadInt64(&this.RowsDeltaEstimate),
+		ProgressPct:    this.GetProgressPct(),
+		ElapsedSeconds: int64(this.ElapsedTime().Seconds()),
+		ETASeconds:     this.GetETASeconds(),
+	}
+	if this.ApplierConnectionConfig != nil {
+		body.Host = this.ApplierConnectionConfig.Key.String()
+	}
+	return body
+}
+
+// ParseThrottleHTTPBodyTemplate parses a --throttle-http-body template. Templates render a ThrottleHTTPRequestBody,
+// and may use the json function to quote values, e.g. {"table": {{json .Table}}}
+func ParseThrottleHTTPBodyTemplate(text string) (*template.Template, error) {
+	return template.New("throttle-http-body").Funcs(template.FuncMap{
+		"json": func(value interface{}) (string, error) {
+			encoded, err := json.Marshal(value)
+			return string(encoded), err
+		},
+	}).Option("missingkey=error").Parse(text)
+}
+
+// Render returns the request body, per given template; or, given no template, the body as a JSON document
+func (this *ThrottleHTTPRequestBody) Render(bodyTemplate *template.Template) ([]byte, error) {
+	if bodyTemplate == nil {
+		return json.Marshal(this)
+	}
+	var buf bytes.Buffer
+	if err := bodyTemplate.Execute(&buf, this); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
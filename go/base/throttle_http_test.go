@@ -0,0 +1,71 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package base
+
+import (
+	"encoding/json"
+	"testing"
+
+	test "github.com/outbrain/golib/tests"
+)
+
+func TestParseThrottleHTTPUnreachablePolicy(t *testing.T) {
+	{
+		policy, err := ParseThrottleHTTPUnreachablePolicy("panic")
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(policy, ThrottleHTTPUnreachablePanic)
+	}
+	{
+		_, err := ParseThrottleHTTPUnreachablePolicy("retry")
+		test.S(t).ExpectNotNil(err)
+	}
+}
+
+func TestParseThrottleHTTPMethod(t *testing.T) {
+	{
+		method, err := ParseThrottleHTTPMethod("post")
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(method, "POST")
+	}
+	{
+		_, err := ParseThrottleHTTPMethod("PUT")
+		test.S(t).ExpectNotNil(err)
+	}
+}
+
+func TestAddThrottleHTTPHeader(t *testing.T) {
+	migrationContext := NewMigrationContext()
+	test.S(t).ExpectNil(migrationContext.AddThrottleHTTPHeader("Authorization: Bearer a:b"))
+	test.S(t).ExpectEquals(migrationContext.ThrottleHTTPHeaders.Get("Authorization"), "Bearer a:b")
+	test.S(t).ExpectNotNil(migrationContext.AddThrottleHTTPHeader("Authorization"))
+	test.S(t).ExpectNotNil(migrationContext.AddThrottleHTTPHeader(": value"))
+}
+
+func TestThrottleHTTPRequestBodyRender(t *testing.T) {
+	migrationContext := NewMigrationContext()
+	migrationContext.DatabaseName = "test"
+	migrationContext.OriginalTableName = `or"ders`
+	body := migrationContext.NewThrottleHTTPRequestBody()
+	{
+		rendered, err := body.Render(nil)
+		test.S(t).ExpectNil(err)
+		var document map[string]interface{}
+		test.S(t).ExpectNil(json.Unmarshal(rendered, &document))
+		test.S(t).ExpectEquals(document["table"], `or"ders`)
+		test.S(t).ExpectEquals(document["ghost_table"], `_or"ders_gho`)
+	}
+	{
+		bodyTemplate, err := ParseThrottleHTTPBodyTemplate(`{"schema": {{json .Database}}, "table": {{json .Table}}}`)
+		test.S(t).ExpectNil(err)
+		rendered, err := body.Render(bodyTemplate)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(string(rendered), `{"schema": "test", "table": "or\"ders"}`)
+	}
+	{
+		_, err := ParseThrottleHTTPBodyTemplate(`{"table": {{json .Table}`)
+		test.S(t).ExpectNotNil(err)
+	}
+}
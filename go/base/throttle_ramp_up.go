@@ -0,0 +1,116 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package base
+
+import (
+	"sync"
+	"time"
+)
+
+// throttleRampUpMinFactor is the pace a ramp-up starts at, such that the migration makes progress right away
+const throttleRampUpMinFactor = 0.05
+
+// ThrottleRampUp gradually resumes the migration's pace once a throttle period ends, per --throttle-ramp-up-duration.
+// Throttle periods shorter than the threshold resume at full pace. A ramp-up is reset once throttling re-engages.
+type ThrottleRampUp struct {
+	mutex     *sync.Mutex
+	duration  time.Duration
+	threshold time.Duration
+
+	throttledSince time.Time
+	rampUpSince    time.Time
+}
+
+func NewThrottleRampUp(duration time.Duration, threshold time.Duration) *ThrottleRampUp {
+	return &ThrottleRampUp{
+		mutex:     &sync.Mutex{},
+		duration:  duration,
+		threshold: threshold,
+	}
+}
+
+// GetDuration returns the ramp-up duration; 0 when disabled
+func (this *ThrottleRampUp) GetDuration() time.Duration {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.duration
+}
+
+// SetDuration sets the ramp-up duration. 0 disables ramp-up, and ends an ongoing ramp-up.
+func (this *ThrottleRampUp) SetDuration(duration time.Duration) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.duration = duration
+	if duration <= 0 {
+		this.rampUpSince = time.Time{}
+	}
+}
+
+// GetThreshold returns the minimal throttle period which is followed by a ramp-up
+func (this *ThrottleRampUp) GetThreshold() time.Duration {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.threshold
+}
+
+// SetThreshold sets the minimal throttle period which is followed by a ramp-up
+func (this *ThrottleRampUp) SetThreshold(threshold time.Duration) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.threshold = threshold
+}
+
+// SetThrottled notes whether the migration is throttled at given time. A throttle period lasting at least
+// the threshold begins a ramp-up once it ends; throttling resets any ongoing ramp-up.
+func (this *ThrottleRampUp) SetThrottled(throttled bool, now time.Time) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if throttled {
+		if this.throttledSince.IsZero() {
+			this.throttledSince = now
+		}
+		this.rampUpSince = time.Time{}
+		return
+	}
+	if this.throttledSince.IsZero() {
+		return
+	}
+	if this.duration > 0 && now.Sub(this.throttledSince) >= this.threshold {
+		this.rampUpSince = now
+	}
+	this.throttledSince = time.Time{}
+}
+
+// Factor returns the fraction of the full pace the migration should run at, at given time: linearly
+// increasing from throttleRampUpMinFactor up to 1 over the ramp-up duration, and 1 when not ramping up
+func (this *ThrottleRampUp) Factor(now time.Time) (factor float64, rampingUp bool) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.rampUpSince.IsZero() || this.duration <= 0 {
+		return 1, false
+	}
+	factor = float64(now.Sub(this.rampUpSince)) / float64(this.duration)
+	if factor >= 1 {
+		this.rampUpSince = time.Time{}
+		return 1, false
+	}
+	if factor < throttleRampUpMinFactor {
+		factor = throttleRampUpMinFactor
+	}
+	return factor, true
+}
+
+// RampUpNiceRatio returns the nice ratio to apply at given ramp-up factor, such that work which runs at full
+// pace under given nice ratio runs at the factor's fraction of that pace: a factor of 0.25 under a nice ratio
+// of 0 makes for a nice ratio of 3, sleeping 3ms for every 1ms of work
+func RampUpNiceRatio(niceRatio float64, factor float64) float64 {
+	if factor <= 0 || factor >= 1 {
+		return niceRatio
+	}
+	return (1+niceRatio)/factor - 1
+}
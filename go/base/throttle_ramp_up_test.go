@@ -0,0 +1,76 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package base
+
+import (
+	"testing"
+	"time"
+
+	test "github.com/outbrain/golib/tests"
+)
+
+func TestThrottleRampUp(t *testing.T) {
+	rampUp := NewThrottleRampUp(time.Minute, 30*time.Second)
+	now := time.Now()
+	{
+		factor, rampingUp := rampUp.Factor(now)
+		test.S(t).ExpectFalse(rampingUp)
+		test.S(t).ExpectEquals(factor, 1.0)
+	}
+	// A throttle period shorter than the threshold resumes at full pace
+	rampUp.SetThrottled(true, now)
+	rampUp.SetThrottled(false, now.Add(10*time.Second))
+	{
+		_, rampingUp := rampUp.Factor(now.Add(10 * time.Second))
+		test.S(t).ExpectFalse(rampingUp)
+	}
+	// A longer throttle period ramps up
+	rampUp.SetThrottled(true, now)
+	rampUp.SetThrottled(true, now.Add(20*time.Second))
+	rampUp.SetThrottled(false, now.Add(40*time.Second))
+	{
+		factor, rampingUp := rampUp.Factor(now.Add(40 * time.Second))
+		test.S(t).ExpectTrue(rampingUp)
+		test.S(t).ExpectEquals(factor, throttleRampUpMinFactor)
+	}
+	{
+		factor, rampingUp := rampUp.Factor(now.Add(70 * time.Second))
+		test.S(t).ExpectTrue(rampingUp)
+		test.S(t).ExpectEquals(factor, 0.5)
+	}
+	// Throttling again resets the ramp-up
+	rampUp.SetThrottled(true, now.Add(71*time.Second))
+	{
+		factor, rampingUp := rampUp.Factor(now.Add(72 * time.Second))
+		test.S(t).ExpectFalse(rampingUp)
+		test.S(t).ExpectEquals(factor, 1.0)
+	}
+	rampUp.SetThrottled(false, now.Add(110*time.Second))
+	{
+		_, rampingUp := rampUp.Factor(now.Add(110 * time.Second))
+		test.S(t).ExpectTrue(rampingUp)
+	}
+	// The ramp-up ends after its duration
+	{
+		factor, rampingUp := rampUp.Factor(now.Add(170 * time.Second))
+		test.S(t).ExpectFalse(rampingUp)
+		test.S(t).ExpectEquals(factor, 1.0)
+	}
+	// Disabled
+	rampUp.SetDuration(0)
+	rampUp.SetThrottled(true, now)
+	rampUp.SetThrottled(false, now.Add(time.Hour))
+	{
+		_, rampingUp := rampUp.Factor(now.Add(time.Hour))
+		test.S(t).ExpectFalse(rampingUp)
+	}
+}
+
+func TestRampUpNiceRatio(t *testing.T) {
+	test.S(t).ExpectEquals(RampUpNiceRatio(0, 0.25), 3.0)
+	test.S(t).ExpectEquals(RampUpNiceRatio(1, 0.5), 3.0)
+	test.S(t).ExpectEquals(RampUpNiceRatio(0.7, 1), 0.7)
+}
@@ -0,0 +1,177 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package base
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// weekdayNames are indexed by time.Weekday
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// ThrottleScheduleWindow is a weekly wall clock window within which to throttle, e.g. Mon-Fri 08:00-20:00.
+// A window spanning midnight, e.g. Fri 22:00-02:00, belongs to the day it starts on.
+type ThrottleScheduleWindow struct {
+	Label string
+
+	days     [7]bool
+	daysSpec string
+	daily    *CutOverWindow
+}
+
+// parseWeekdays parses a comma delimited list of days and day ranges, e.g. Mon-Fri or Sat,Sun. A range may
+// wrap around the week, e.g. Fri-Mon.
+func parseWeekdays(spec string) (days [7]bool, err error) {
+	parseWeekday := func(name string) (time.Weekday, error) {
+		for weekday, weekdayName := range weekdayNames {
+			if strings.EqualFold(name, weekdayName) {
+				return time.Weekday(weekday), nil
+			}
+		}
+		return 0, fmt.Errorf("Invalid day: %s. Expected Mon, Tue, Wed, Thu, Fri, Sat or Sun", name)
+	}
+	for _, token := range strings.Split(spec, ",") {
+		if token == "*" {
+			for weekday := range days {
+				days[weekday] = true
+			}
+			continue
+		}
+		bounds := strings.SplitN(token, "-", 2)
+		first, err := parseWeekday(bounds[0])
+		if err != nil {
+			return days, err
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = parseWeekday(bounds[1]); err != nil {
+				return days, err
+			}
+		}
+		for weekday := first; ; weekday = (weekday + 1) % 7 {
+			days[weekday] = true
+			if weekday == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// ParseThrottleScheduleWindow parses a --throttle-schedule value, [label=][days ]HH:MM-HH:MM, in given time zone.
+// Days are comma delimited days and day ranges, e.g. Mon-Fri or Sat,Sun; no days means every day. The label,
+// e.g. "business hours=Mon-Fri 08:00-20:00", names the window in the throttle status.
+func ParseThrottleScheduleWindow(spec string, location *time.Location) (*ThrottleScheduleWindow, error) {
+	window := &ThrottleScheduleWindow{daysSpec: "*"}
+	if tokens := strings.SplitN(spec, "=", 2); len(tokens) == 2 {
+		window.Label = strings.TrimSpace(tokens[0])
+		spec = tokens[1]
+	}
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 1:
+	case 2:
+		window.daysSpec = fields[0]
+	default:
+		return nil, fmt.Errorf("Invalid throttle schedule: %s. Expected [label=][days ]HH:MM-HH:MM, e.g. Mon-Fri 08:00-20:00", spec)
+	}
+	days, err := parseWeekdays(window.daysSpec)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid throttle schedule: %s. %+v", spec, err)
+	}
+	window.days = days
+	if window.daily, err = parseDailyWindow("throttle schedule", fields[len(fields)-1], location); err != nil {
+		return nil, err
+	}
+	if window.Label == "" {
+		window.Label = strings.Join(fields, " ")
+	}
+	return window, nil
+}
+
+// Contains tells whether given time is within the window
+func (this *ThrottleScheduleWindow) Contains(t time.Time) bool {
+	if !this.daily.Contains(t) {
+		return false
+	}
+	t = t.In(this.daily.Location)
+	weekday := t.Weekday()
+	if this.daily.Start > this.daily.End && this.daily.sinceMidnight(t) < this.daily.End {
+		// Past midnight: the window started the day before
+		weekday = (weekday + 6) % 7
+	}
+	return this.days[weekday]
+}
+
+func (this *ThrottleScheduleWindow) String() string {
+	spec := this.daily.hours()
+	if this.daysSpec != "*" {
+		spec = fmt.Sprintf("%s %s", this.daysSpec, spec)
+	}
+	if this.Label != spec {
+		spec = fmt.Sprintf("%s=%s", this.Label, spec)
+	}
+	return spec
+}
+
+// ThrottleSchedule is a set of windows within which to throttle, per --throttle-schedule
+type ThrottleSchedule []*ThrottleScheduleWindow
+
+// ParseThrottleSchedule parses semicolon delimited --throttle-schedule windows, as given to the throttle-schedule
+// interactive command, in given time zone. An empty schedule never throttles.
+func ParseThrottleSchedule(schedule string, location *time.Location) (ThrottleSchedule, error) {
+	windows := ThrottleSchedule{}
+	for _, spec := range strings.Split(schedule, ";") {
+		if strings.TrimSpace(spec) == "" {
+			continue
+		}
+		window, err := ParseThrottleScheduleWindow(spec, location)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
+
+// ActiveWindow returns the first window containing given time, or nil when none does
+func (this ThrottleSchedule) ActiveWindow(t time.Time) *ThrottleScheduleWindow {
+	for _, window := range this {
+		if window.Contains(t) {
+			return window
+		}
+	}
+	return nil
+}
+
+func (this ThrottleSchedule) String() string {
+	specs := []string{}
+	for _, window := range this {
+		specs = append(specs, window.String())
+	}
+	return strings.Join(specs, "; ")
+}
+
+// GetThrottleSchedule returns the --throttle-schedule windows
+func (this *MigrationContext) GetThrottleSchedule() ThrottleSchedule {
+	this.throttleMutex.Lock()
+	defer this.throttleMutex.Unlock()
+	return this.throttleSchedule
+}
+
+// ReadThrottleSchedule sets the --throttle-schedule windows, semicolon delimited, in the --throttle-schedule-time-zone
+func (this *MigrationContext) ReadThrottleSchedule(schedule string) error {
+	windows, err := ParseThrottleSchedule(schedule, this.ThrottleScheduleLocation)
+	if err != nil {
+		return err
+	}
+	this.throttleMutex.Lock()
+	defer this.throttleMutex.Unlock()
+	this.throttleSchedule = windows
+	return nil
+}
@@ -0,0 +1,71 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package base
+
+import (
+	"testing"
+	"time"
+
+	test "github.com/outbrain/golib/tests"
+)
+
+func TestParseThrottleScheduleWindow(t *testing.T) {
+	// 2022-01-03 is a Monday
+	at := func(day int, hour int, minute int) time.Time {
+		return time.Date(2022, 1, day, hour, minute, 0, 0, time.UTC)
+	}
+	{
+		window, err := ParseThrottleScheduleWindow("Mon-Fri 08:00-20:00", time.UTC)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(window.Label, "Mon-Fri 08:00-20:00")
+		test.S(t).ExpectEquals(window.String(), "Mon-Fri 08:00-20:00")
+		test.S(t).ExpectTrue(window.Contains(at(3, 8, 0)))
+		test.S(t).ExpectTrue(window.Contains(at(7, 19, 59)))
+		test.S(t).ExpectFalse(window.Contains(at(7, 20, 0)))
+		test.S(t).ExpectFalse(window.Contains(at(8, 12, 0)))
+	}
+	{
+		window, err := ParseThrottleScheduleWindow("weekend nights=sat,sun 22:00-2:00", time.UTC)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(window.Label, "weekend nights")
+		test.S(t).ExpectEquals(window.String(), "weekend nights=sat,sun 22:00-02:00")
+		test.S(t).ExpectTrue(window.Contains(at(8, 23, 0)))
+		// Monday past midnight belongs to Sunday's window
+		test.S(t).ExpectTrue(window.Contains(at(10, 1, 0)))
+		// Saturday past midnight belongs to Friday, which is not scheduled
+		test.S(t).ExpectFalse(window.Contains(at(8, 1, 0)))
+	}
+	{
+		window, err := ParseThrottleScheduleWindow("Fri-Mon 12:00-13:00", time.UTC)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectTrue(window.Contains(at(9, 12, 30)))
+		test.S(t).ExpectTrue(window.Contains(at(3, 12, 30)))
+		test.S(t).ExpectFalse(window.Contains(at(4, 12, 30)))
+	}
+	{
+		window, err := ParseThrottleScheduleWindow("12:00-13:00", time.UTC)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectTrue(window.Contains(at(4, 12, 30)))
+	}
+	for _, invalid := range []string{"", "Mon-Fri", "Mon-Fri 08:00", "Mon-Xyz 08:00-20:00", "Mon 08:00-20:00 UTC", "Mon 25:00-26:00"} {
+		_, err := ParseThrottleScheduleWindow(invalid, time.UTC)
+		test.S(t).ExpectNotNil(err)
+	}
+}
+
+func TestParseThrottleSchedule(t *testing.T) {
+	schedule, err := ParseThrottleSchedule("Mon-Fri 08:00-20:00; lunch=12:00-13:00;", time.UTC)
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectEquals(len(schedule), 2)
+	test.S(t).ExpectEquals(schedule.String(), "Mon-Fri 08:00-20:00; lunch=12:00-13:00")
+	test.S(t).ExpectEquals(schedule.ActiveWindow(time.Date(2022, 1, 8, 12, 30, 0, 0, time.UTC)).Label, "lunch")
+	test.S(t).ExpectTrue(schedule.ActiveWindow(time.Date(2022, 1, 8, 14, 0, 0, 0, time.UTC)) == nil)
+
+	schedule, err = ParseThrottleSchedule("", time.UTC)
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectEquals(len(schedule), 0)
+	test.S(t).ExpectTrue(schedule.ActiveWindow(time.Now()) == nil)
+}
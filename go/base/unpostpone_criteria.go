@@ -0,0 +1,137 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package base
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var unpostponeCriterionRegexp = regexp.MustCompile(`^([a-z]+)(<=|>=|<|>|=)(.+)$`)
+
+// UnpostponeMetrics are the migration's health metrics, against which --auto-unpostpone-when criteria are evaluated
+type UnpostponeMetrics struct {
+	HeartbeatLag    time.Duration
+	Backlog         int64
+	CopyProgressPct float64
+	Throttled       bool
+}
+
+// UnpostponeCriterion is a single --auto-unpostpone-when condition, e.g. lag<1s
+type UnpostponeCriterion struct {
+	Metric   string
+	Operator string
+	Value    float64
+	token    string
+}
+
+// UnpostponeCriteria are --auto-unpostpone-when conditions, all of which must be met
+type UnpostponeCriteria []*UnpostponeCriterion
+
+// ParseUnpostponeCriteria parses an --auto-unpostpone-when value, a comma delimited list of conditions such as:
+//
+//	'lag<1s,backlog<1000,copy=100%,throttled=false'
+func ParseUnpostponeCriteria(criteriaList string) (UnpostponeCriteria, error) {
+	criteria := UnpostponeCriteria{}
+	for _, token := range strings.Split(criteriaList, ",") {
+		token = strings.TrimSpace(token)
+		submatch := unpostponeCriterionRegexp.FindStringSubmatch(token)
+		if submatch == nil {
+			return nil, fmt.Errorf("Error parsing auto-unpostpone condition: %s. Expected <metric><operator><value>, e.g. lag<1s", token)
+		}
+		criterion := &UnpostponeCriterion{Metric: submatch[1], Operator: submatch[2], token: token}
+		value := submatch[3]
+		switch criterion.Metric {
+		case "lag":
+			duration, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("Error parsing lag in auto-unpostpone condition: %s. Expected a duration, e.g. 1s or 500ms", token)
+			}
+			criterion.Value = duration.Seconds()
+		case "backlog":
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Error parsing backlog in auto-unpostpone condition: %s. Expected a number of events", token)
+			}
+			criterion.Value = float64(n)
+		case "copy":
+			pct, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+			if err != nil {
+				return nil, fmt.Errorf("Error parsing copy in auto-unpostpone condition: %s. Expected a percentage, e.g. 100%%", token)
+			}
+			criterion.Value = pct
+		case "throttled":
+			throttled, err := strconv.ParseBool(value)
+			if err != nil || criterion.Operator != "=" {
+				return nil, fmt.Errorf("Error parsing throttled in auto-unpostpone condition: %s. Expected throttled=true or throttled=false", token)
+			}
+			if throttled {
+				criterion.Value = 1
+			}
+		default:
+			return nil, fmt.Errorf("Unknown metric in auto-unpostpone condition: %s. Supported metrics are lag, backlog, copy and throttled", token)
+		}
+		criteria = append(criteria, criterion)
+	}
+	return criteria, nil
+}
+
+func (this *UnpostponeCriterion) metricValue(metrics *UnpostponeMetrics) float64 {
+	switch this.Metric {
+	case "lag":
+		return metrics.HeartbeatLag.Seconds()
+	case "backlog":
+		return float64(metrics.Backlog)
+	case "copy":
+		return metrics.CopyProgressPct
+	case "throttled":
+		if metrics.Throttled {
+			return 1
+		}
+	}
+	return 0
+}
+
+// IsMet tells whether given metrics meet this criterion
+func (this *UnpostponeCriterion) IsMet(metrics *UnpostponeMetrics) bool {
+	value := this.metricValue(metrics)
+	switch this.Operator {
+	case "<":
+		return value < this.Value
+	case "<=":
+		return value <= this.Value
+	case ">":
+		return value > this.Value
+	case ">=":
+		return value >= this.Value
+	}
+	return value == this.Value
+}
+
+func (this *UnpostponeCriterion) String() string {
+	return this.token
+}
+
+// UnmetCriterion returns the first criterion given metrics do not meet, or nil when all are met
+func (this UnpostponeCriteria) UnmetCriterion(metrics *UnpostponeMetrics) *UnpostponeCriterion {
+	for _, criterion := range this {
+		if !criterion.IsMet(metrics) {
+			return criterion
+		}
+	}
+	return nil
+}
+
+func (this UnpostponeCriteria) String() string {
+	tokens := []string{}
+	for _, criterion := range this {
+		tokens = append(tokens, criterion.String())
+	}
+	return strings.Join(tokens, ",")
+}
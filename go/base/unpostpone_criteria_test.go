@@ -0,0 +1,58 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package base
+
+import (
+	"testing"
+	"time"
+
+	test "github.com/outbrain/golib/tests"
+)
+
+func TestParseUnpostponeCriteria(t *testing.T) {
+	{
+		criteria, err := ParseUnpostponeCriteria("lag<1s, backlog<=1000,copy=100%,throttled=false")
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(len(criteria), 4)
+		test.S(t).ExpectEquals(criteria[0].Metric, "lag")
+		test.S(t).ExpectEquals(criteria[0].Operator, "<")
+		test.S(t).ExpectEquals(criteria[0].Value, 1.0)
+		test.S(t).ExpectEquals(criteria[1].Operator, "<=")
+		test.S(t).ExpectEquals(criteria[1].Value, 1000.0)
+		test.S(t).ExpectEquals(criteria[2].Value, 100.0)
+		test.S(t).ExpectEquals(criteria[3].Value, 0.0)
+		test.S(t).ExpectEquals(criteria.String(), "lag<1s,backlog<=1000,copy=100%,throttled=false")
+	}
+	{
+		criteria, err := ParseUnpostponeCriteria("lag<500ms")
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(criteria[0].Value, 0.5)
+	}
+	for _, invalid := range []string{"", "lag", "lag<1", "backlog<many", "copy>=all", "throttled<true", "throttled=maybe", "load<10", "lag<1s,"} {
+		_, err := ParseUnpostponeCriteria(invalid)
+		test.S(t).ExpectNotNil(err)
+	}
+}
+
+func TestUnpostponeCriteriaUnmetCriterion(t *testing.T) {
+	criteria, err := ParseUnpostponeCriteria("lag<1s,backlog<1000,copy=100%,throttled=false")
+	test.S(t).ExpectNil(err)
+
+	metrics := &UnpostponeMetrics{HeartbeatLag: 200 * time.Millisecond, Backlog: 10, CopyProgressPct: 100}
+	test.S(t).ExpectTrue(criteria.UnmetCriterion(metrics) == nil)
+
+	metrics.HeartbeatLag = time.Second
+	test.S(t).ExpectEquals(criteria.UnmetCriterion(metrics).String(), "lag<1s")
+	metrics.HeartbeatLag = 0
+	metrics.Backlog = 1000
+	test.S(t).ExpectEquals(criteria.UnmetCriterion(metrics).String(), "backlog<1000")
+	metrics.Backlog = 0
+	metrics.CopyProgressPct = 99.9
+	test.S(t).ExpectEquals(criteria.UnmetCriterion(metrics).String(), "copy=100%")
+	metrics.CopyProgressPct = 100
+	metrics.Throttled = true
+	test.S(t).ExpectEquals(criteria.UnmetCriterion(metrics).String(), "throttled=false")
+}
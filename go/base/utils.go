@@ -28,6 +28,48 @@ func PrettifyDurationOutput(d time.Duration) string {
 	return prettifyDurationRegexp.ReplaceAllString(d.String(), "")
 }
 
+// PrettifyCount formats a count (or rate) in human readable form, e.g. 12.3k
+func PrettifyCount(count float64) string {
+	switch {
+	case count >= 1e9:
+		return fmt.Sprintf("%.1fG", count/1e9)
+	case count >= 1e6:
+		return fmt.Sprintf("%.1fM", count/1e6)
+	case count >= 1e3:
+		return fmt.Sprintf("%.1fk", count/1e3)
+	}
+	return fmt.Sprintf("%.0f", count)
+}
+
+// PrettifyThousands formats a count with thousands separators, e.g. 12,345,678
+func PrettifyThousands(count int64) string {
+	if count < 0 {
+		return "-" + PrettifyThousands(-count)
+	}
+	digits := fmt.Sprintf("%d", count)
+	var result strings.Builder
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			result.WriteByte(',')
+		}
+		result.WriteRune(digit)
+	}
+	return result.String()
+}
+
+// PrettifyBytes formats a size in bytes in human readable form, e.g. 4.1 MB
+func PrettifyBytes(bytes float64) string {
+	switch {
+	case bytes >= 1<<30:
+		return fmt.Sprintf("%.1f GB", bytes/(1<<30))
+	case bytes >= 1<<20:
+		return fmt.Sprintf("%.1f MB", bytes/(1<<20))
+	case bytes >= 1<<10:
+		return fmt.Sprintf("%.1f KB", bytes/(1<<10))
+	}
+	return fmt.Sprintf("%.0f B", bytes)
+}
+
 func FileExists(fileName string) bool {
 	if _, err := os.Stat(fileName); err == nil {
 		return true
@@ -93,3 +135,20 @@ func ValidateConnection(db *gosql.DB, connectionConfig *mysql.ConnectionConfig,
 		return "", fmt.Errorf("Unexpected database port reported: %+v / extra_port: %+v", port, extraPort)
 	}
 }
+
+// LogSessionVariables logs the effective values of --session-variable variables on given connection
+func LogSessionVariables(db *gosql.DB, migrationContext *MigrationContext, name string) error {
+	if len(migrationContext.SessionVariables) == 0 {
+		return nil
+	}
+	effective, err := mysql.ReadSessionVariables(db, migrationContext.SessionVariables)
+	if err != nil {
+		return err
+	}
+	tokens := []string{}
+	for _, variable := range effective {
+		tokens = append(tokens, variable.String())
+	}
+	migrationContext.Log.Infof("%s session variables: %s", name, strings.Join(tokens, ", "))
+	return nil
+}
@@ -27,3 +27,27 @@ func TestStringContainsAll(t *testing.T) {
 	test.S(t).ExpectTrue(StringContainsAll(s, "insert", ""))
 	test.S(t).ExpectTrue(StringContainsAll(s, "insert", "update", "delete"))
 }
+
+func TestPrettifyCount(t *testing.T) {
+	test.S(t).ExpectEquals(PrettifyCount(0), "0")
+	test.S(t).ExpectEquals(PrettifyCount(310.4), "310")
+	test.S(t).ExpectEquals(PrettifyCount(12345), "12.3k")
+	test.S(t).ExpectEquals(PrettifyCount(4100000), "4.1M")
+	test.S(t).ExpectEquals(PrettifyCount(2500000000), "2.5G")
+}
+
+func TestPrettifyThousands(t *testing.T) {
+	test.S(t).ExpectEquals(PrettifyThousands(0), "0")
+	test.S(t).ExpectEquals(PrettifyThousands(999), "999")
+	test.S(t).ExpectEquals(PrettifyThousands(1000), "1,000")
+	test.S(t).ExpectEquals(PrettifyThousands(12345678), "12,345,678")
+	test.S(t).ExpectEquals(PrettifyThousands(-123456), "-123,456")
+}
+
+func TestPrettifyBytes(t *testing.T) {
+	test.S(t).ExpectEquals(PrettifyBytes(0), "0 B")
+	test.S(t).ExpectEquals(PrettifyBytes(1023), "1023 B")
+	test.S(t).ExpectEquals(PrettifyBytes(2048), "2.0 KB")
+	test.S(t).ExpectEquals(PrettifyBytes(4.1*(1<<20)), "4.1 MB")
+	test.S(t).ExpectEquals(PrettifyBytes(3*(1<<30)), "3.0 GB")
+}
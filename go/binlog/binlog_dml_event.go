@@ -8,7 +8,10 @@ package binlog
 import (
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/github/gh-ost/go/mysql"
 	"github.com/github/gh-ost/go/sql"
 )
 
@@ -35,7 +38,7 @@ func ToEventDML(description string) EventDML {
 	if strings.HasPrefix(description, "WriteRows") {
 		return InsertDML
 	}
-	if strings.HasPrefix(description, "UpdateRows") {
+	if strings.HasPrefix(description, "UpdateRows") || strings.HasPrefix(description, "PartialUpdateRows") {
 		return UpdateDML
 	}
 	if strings.HasPrefix(description, "DeleteRows") {
@@ -51,6 +54,12 @@ type BinlogDMLEvent struct {
 	DML               EventDML
 	WhereColumnValues *sql.ColumnValues
 	NewColumnValues   *sql.ColumnValues
+	// Coordinates of the rows event, and its originating query, where known
+	// (binlog_rows_query_log_events / binlog_annotate_row_events)
+	Coordinates mysql.BinlogCoordinates
+	Query       string
+	// Timestamp is when the rows event was written, with a precision of seconds
+	Timestamp time.Time
 }
 
 func NewBinlogDMLEvent(databaseName, tableName string, dml EventDML) *BinlogDMLEvent {
@@ -62,6 +71,34 @@ func NewBinlogDMLEvent(databaseName, tableName string, dml EventDML) *BinlogDMLE
 	return event
 }
 
+var binlogDMLEventPool = sync.Pool{
+	New: func() interface{} {
+		return &BinlogDMLEvent{}
+	},
+}
+
+// acquireBinlogDMLEvent gets a pooled BinlogDMLEvent, see Release()
+func acquireBinlogDMLEvent(databaseName, tableName string, dml EventDML) *BinlogDMLEvent {
+	event := binlogDMLEventPool.Get().(*BinlogDMLEvent)
+	event.DatabaseName = databaseName
+	event.TableName = tableName
+	event.DML = dml
+	return event
+}
+
+// Release returns this event, along with its column values, to the pool. See BinlogEntry
+// on ownership.
+func (this *BinlogDMLEvent) Release() {
+	if this.WhereColumnValues != nil {
+		this.WhereColumnValues.Release()
+	}
+	if this.NewColumnValues != nil {
+		this.NewColumnValues.Release()
+	}
+	*this = BinlogDMLEvent{}
+	binlogDMLEventPool.Put(this)
+}
+
 func (this *BinlogDMLEvent) String() string {
 	return fmt.Sprintf("[%+v on %s:%s]", this.DML, this.DatabaseName, this.TableName)
 }
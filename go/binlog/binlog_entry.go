@@ -7,14 +7,37 @@ package binlog
 
 import (
 	"fmt"
+	"sync"
+
 	"github.com/github/gh-ost/go/mysql"
 )
 
+// Entries created by the reader are pooled, to reduce allocation and GC pressure on busy
+// binary logs. Ownership goes as follows:
+// - The reader acquires a BinlogEntry, along with its BinlogDMLEvent and column values, and
+//   sends the entry down the entries channel.
+// - The receiver of the entry owns it, and releases the entry once done with it. Releasing an
+//   entry does not release its DmlEvent, which the receiver may hand over elsewhere.
+// - Whoever ends up owning the DmlEvent releases it once it no longer reads it, e.g. the
+//   applier once the event has been applied.
+// Nothing may be used after being released. Releasing is optional: unreleased objects are
+// garbage collected as usual.
+var binlogEntryPool = sync.Pool{
+	New: func() interface{} {
+		return &BinlogEntry{}
+	},
+}
+
 // BinlogEntry describes an entry in the binary log
 type BinlogEntry struct {
 	Coordinates mysql.BinlogCoordinates
 	EndLogPos   uint64
 
+	// TransactionSequence identifies the transaction this entry belongs to. IsTransactionEnd
+	// marks an entry which carries no DML, and which indicates said transaction has committed.
+	TransactionSequence int64
+	IsTransactionEnd    bool
+
 	DmlEvent *BinlogDMLEvent
 }
 
@@ -34,6 +57,29 @@ func NewBinlogEntryAt(coordinates mysql.BinlogCoordinates) *BinlogEntry {
 	return binlogEntry
 }
 
+// acquireBinlogEntryAt gets a pooled BinlogEntry, see Release()
+func acquireBinlogEntryAt(coordinates mysql.BinlogCoordinates) *BinlogEntry {
+	binlogEntry := binlogEntryPool.Get().(*BinlogEntry)
+	binlogEntry.Coordinates = coordinates
+	return binlogEntry
+}
+
+// Release returns this entry to the pool. It does not release the entry's DmlEvent.
+func (this *BinlogEntry) Release() {
+	*this = BinlogEntry{}
+	binlogEntryPool.Put(this)
+}
+
+// NewTransactionEndBinlogEntryAt creates an end-of-transaction marker entry
+func NewTransactionEndBinlogEntryAt(coordinates mysql.BinlogCoordinates, transactionSequence int64) *BinlogEntry {
+	binlogEntry := &BinlogEntry{
+		Coordinates:         coordinates,
+		TransactionSequence: transactionSequence,
+		IsTransactionEnd:    true,
+	}
+	return binlogEntry
+}
+
 // Duplicate creates and returns a new binlog entry, with some of the attributes pre-assigned
 func (this *BinlogEntry) Duplicate() *BinlogEntry {
 	binlogEntry := NewBinlogEntry(this.Coordinates.LogFile, uint64(this.Coordinates.LogPos))
@@ -43,5 +89,8 @@ func (this *BinlogEntry) Duplicate() *BinlogEntry {
 
 // String() returns a string representation of this binlog entry
 func (this *BinlogEntry) String() string {
-	return fmt.Sprintf("[BinlogEntry at %+v; dml:%+v]", this.Coordinates, this.DmlEvent)
+	if this.IsTransactionEnd {
+		return fmt.Sprintf("[BinlogEntry at %+v; end of trx:%d]", this.Coordinates, this.TransactionSequence)
+	}
+	return fmt.Sprintf("[BinlogEntry at %+v; trx:%d; dml:%+v]", this.Coordinates, this.TransactionSequence, this.DmlEvent)
 }
@@ -0,0 +1,134 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package binlog
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/github/gh-ost/go/base"
+	"github.com/github/gh-ost/go/mysql"
+
+	test "github.com/openark/golib/tests"
+	gomysql "github.com/siddontang/go-mysql/mysql"
+	"github.com/siddontang/go-mysql/replication"
+)
+
+// writeTestBinlogFile writes a binary log holding a single transaction, which inserts a row
+// into `test`.`tbl`, followed by a rotate event. It returns the end positions of its events.
+func writeTestBinlogFile(t *testing.T, fileName string) (endLogPositions []uint32) {
+	formatDescription := make([]byte, 2+50+4+1+40+5)
+	binary.LittleEndian.PutUint16(formatDescription, 4)
+	copy(formatDescription[2:], "8.0.30")
+	formatDescription[56] = replication.EventHeaderSize
+	// table id 7: `test`.`tbl` (id int, val int)
+	tableMap := []byte{7, 0, 0, 0, 0, 0, 0, 0, 4, 't', 'e', 's', 't', 0, 3, 't', 'b', 'l', 0, 2, gomysql.MYSQL_TYPE_LONG, gomysql.MYSQL_TYPE_LONG, 0, 0}
+	// (id, val) = (3, 17)
+	rows := []byte{7, 0, 0, 0, 0, 0, 0, 0, 2, 0, 2, 0xff, 0, 3, 0, 0, 0, 17, 0, 0, 0}
+	xid := make([]byte, 8)
+	rotate := append(make([]byte, 8), "mysql-bin.000018"...)
+	binary.LittleEndian.PutUint64(rotate, 4)
+
+	data := append([]byte{}, replication.BinLogFileHeader...)
+	for _, event := range []struct {
+		eventType replication.EventType
+		body      []byte
+	}{
+		{replication.FORMAT_DESCRIPTION_EVENT, formatDescription},
+		{replication.TABLE_MAP_EVENT, tableMap},
+		{replication.WRITE_ROWS_EVENTv2, rows},
+		{replication.XID_EVENT, xid},
+		{replication.ROTATE_EVENT, rotate},
+	} {
+		endLogPos := uint32(len(data) + replication.EventHeaderSize + len(event.body))
+		data = append(data, buildTestEvent(event.eventType, endLogPos, event.body)...)
+		endLogPositions = append(endLogPositions, endLogPos)
+	}
+	test.S(t).ExpectNil(ioutil.WriteFile(fileName, data, 0640))
+	return endLogPositions
+}
+
+func newTestBinlogFileReader(fileName string) *BinlogFileReader {
+	migrationContext := base.NewMigrationContext()
+	migrationContext.ReplicaServerId = 99999
+	migrationContext.DatabaseName = "test"
+	migrationContext.OriginalTableName = "tbl"
+	return NewBinlogFileReader(migrationContext, fileName)
+}
+
+// streamTestBinlogFile streams the file's events up to its rotate event, and returns the entries
+func streamTestBinlogFile(t *testing.T, reader *BinlogFileReader) (entries []*BinlogEntry) {
+	ctx, cancel := context.WithCancel(context.Background())
+	entriesChannel := make(chan *BinlogEntry, 10)
+	go func() {
+		for reader.GetCurrentBinlogCoordinates().LogFile != "mysql-bin.000018" {
+			time.Sleep(10 * time.Millisecond)
+		}
+		cancel()
+	}()
+	test.S(t).ExpectNil(reader.StreamEvents(ctx, entriesChannel))
+	close(entriesChannel)
+	for entry := range entriesChannel {
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestBinlogFileReader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gh-ost-binlog-file")
+	test.S(t).ExpectNil(err)
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "mysql-bin.000017")
+	endLogPositions := writeTestBinlogFile(t, fileName)
+
+	var reader BinlogReader = newTestBinlogFileReader(fileName)
+	// Coordinates of another binary log are meaningless in the file
+	test.S(t).ExpectNil(reader.ConnectBinlogStreamer(mysql.BinlogCoordinates{LogFile: "mysql-bin.000123", LogPos: 5000}))
+	test.S(t).ExpectEquals(reader.GetCurrentBinlogCoordinates().DisplayString(), "mysql-bin.000017:4")
+
+	entries := streamTestBinlogFile(t, reader.(*BinlogFileReader))
+	test.S(t).ExpectEquals(len(entries), 2)
+	test.S(t).ExpectEquals(entries[0].Coordinates.DisplayString(), fmt.Sprintf("mysql-bin.000017:%d", endLogPositions[2]))
+	test.S(t).ExpectEquals(entries[0].DmlEvent.DatabaseName, "test")
+	test.S(t).ExpectEquals(entries[0].DmlEvent.TableName, "tbl")
+	test.S(t).ExpectEquals(entries[0].DmlEvent.DML, InsertDML)
+	test.S(t).ExpectEquals(entries[0].DmlEvent.NewColumnValues.AbstractValues()[1], int32(17))
+	test.S(t).ExpectTrue(entries[1].IsTransactionEnd)
+	test.S(t).ExpectEquals(entries[1].Coordinates.DisplayString(), fmt.Sprintf("mysql-bin.000017:%d", endLogPositions[3]))
+	// The file ends with a rotate onto the next binary log
+	test.S(t).ExpectEquals(reader.GetCurrentBinlogCoordinates().DisplayString(), "mysql-bin.000018:4")
+	lastAppliedRowsEventHint := reader.GetLastAppliedRowsEventHint()
+	test.S(t).ExpectEquals(lastAppliedRowsEventHint.DisplayString(), fmt.Sprintf("mysql-bin.000017:%d", endLogPositions[2]))
+	test.S(t).ExpectNil(reader.Close())
+}
+
+func TestBinlogFileReaderResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gh-ost-binlog-file")
+	test.S(t).ExpectNil(err)
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "mysql-bin.000017")
+	endLogPositions := writeTestBinlogFile(t, fileName)
+
+	// Resuming at the table map event: the format description is read ahead, yet not handled
+	reader := newTestBinlogFileReader(fileName)
+	test.S(t).ExpectNil(reader.ConnectBinlogStreamer(mysql.BinlogCoordinates{LogFile: "mysql-bin.000017", LogPos: int64(endLogPositions[0])}))
+	entries := streamTestBinlogFile(t, reader)
+	test.S(t).ExpectEquals(len(entries), 2)
+	test.S(t).ExpectEquals(entries[0].Coordinates.DisplayString(), fmt.Sprintf("mysql-bin.000017:%d", endLogPositions[2]))
+	test.S(t).ExpectTrue(entries[1].IsTransactionEnd)
+
+	// Rows events up to the last applied hint are not handed over again
+	reader = newTestBinlogFileReader(fileName)
+	reader.SetLastAppliedRowsEventHint(entries[0].Coordinates)
+	test.S(t).ExpectNil(reader.ConnectBinlogStreamer(mysql.BinlogCoordinates{LogFile: "mysql-bin.000017", LogPos: 4}))
+	test.S(t).ExpectEquals(len(streamTestBinlogFile(t, reader)), 0)
+}
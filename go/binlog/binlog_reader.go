@@ -5,9 +5,21 @@
 
 package binlog
 
+import (
+	"context"
+	"time"
+
+	"github.com/github/gh-ost/go/mysql"
+)
+
 // BinlogReader is a general interface whose implementations can choose their methods of reading
 // a binary log file and parsing it into binlog entries
 type BinlogReader interface {
-	StreamEvents(canStopStreaming func() bool, entriesChannel chan<- *BinlogEntry) error
-	Reconnect() error
+	ConnectBinlogStreamer(coordinates mysql.BinlogCoordinates) error
+	StreamEvents(ctx context.Context, entriesChannel chan<- *BinlogEntry) error
+	GetCurrentBinlogCoordinates() *mysql.BinlogCoordinates
+	GetLastEventTimestamp() time.Time
+	GetLastAppliedRowsEventHint() mysql.BinlogCoordinates
+	SetLastAppliedRowsEventHint(coordinates mysql.BinlogCoordinates)
+	Close() error
 }
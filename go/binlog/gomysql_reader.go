@@ -6,48 +6,327 @@
 package binlog
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
 	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/github/gh-ost/go/base"
 	"github.com/github/gh-ost/go/mysql"
 	"github.com/github/gh-ost/go/sql"
 
+	"github.com/juju/errors"
+	uuid "github.com/satori/go.uuid"
+	"github.com/siddontang/go-mysql/client"
 	gomysql "github.com/siddontang/go-mysql/mysql"
 	"github.com/siddontang/go-mysql/replication"
-	"golang.org/x/net/context"
 )
 
+// timestampFormat is what TIMESTAMP values are formatted as, up to microseconds
+const timestampFormat = "2006-01-02 15:04:05.999999"
+
+// ConcurrentDDLError indicates a DDL statement on the migrated table (or on the ghost or old
+// tables) was found in the binary logs. It cannot be recovered from by reconnecting.
+type ConcurrentDDLError struct {
+	Query       string
+	Coordinates mysql.BinlogCoordinates
+}
+
+func (this *ConcurrentDDLError) Error() string {
+	return fmt.Sprintf("Found concurrent DDL on migrated table at %+v: %s", this.Coordinates, this.Query)
+}
+
+// ReplicaServerIdConflictError indicates another replication client connected to the streamed
+// host with our server_id, which made the server drop our connection.
+type ReplicaServerIdConflictError struct {
+	ServerId uint
+	Err      error
+}
+
+func (this *ReplicaServerIdConflictError) Error() string {
+	return fmt.Sprintf("Another replication client with the same server_id (%d) connected: %+v", this.ServerId, this.Err)
+}
+
+// FatalBinlogReadError indicates the streamed host failed serving binary logs at the requested
+// coordinates (ER_MASTER_FATAL_ERROR_READING_BINLOG), typically since they were purged.
+// Reconnecting at the same coordinates fails alike.
+type FatalBinlogReadError struct {
+	Coordinates mysql.BinlogCoordinates
+	Err         error
+}
+
+func (this *FatalBinlogReadError) Error() string {
+	return fmt.Sprintf("Fatal error reading binary logs at %+v: %+v", this.Coordinates, this.Err)
+}
+
+// IsPurged returns true when the binary logs at the requested coordinates or GTID set were purged
+func (this *FatalBinlogReadError) IsPurged() bool {
+	message := this.Err.Error()
+	return strings.Contains(message, "Could not find first log file name in binary log index file") ||
+		strings.Contains(message, "purged")
+}
+
+// newUndecodableEventError describes an event which failed decoding, given its header and data,
+// and dumps its data under --panic-diagnostics-dir
+func (this *GoMySQLReader) newUndecodableEventError(header *replication.EventHeader, data []byte, tableMap *replication.TableMapEvent, err error) *UndecodableEventError {
+	undecodableEventError := &UndecodableEventError{
+		Coordinates: mysql.BinlogCoordinates{
+			LogFile: this.GetCurrentBinlogCoordinates().LogFile,
+			LogPos:  int64(header.LogPos) - int64(header.EventSize),
+		},
+		Header:   *header,
+		TableMap: tableMap,
+		Err:      err.Error(),
+	}
+	if tableMap == nil && isRowsEventType(header.EventType) && len(data) >= 6 {
+		// v1/v2 rows events begin with a 6 bytes table id
+		undecodableEventError.TableMap = this.tableMapEvents[gomysql.FixedLengthInt(data[0:6])]
+	}
+	if this.migrationContext.PanicDiagnosticsDir != "" {
+		diagnosticsFile, err := writeEventDiagnostics(this.migrationContext.PanicDiagnosticsDir, undecodableEventError, data)
+		if err != nil {
+			this.migrationContext.Log.Errorf("Cannot write diagnostics of undecodable event: %+v", err)
+		}
+		undecodableEventError.DiagnosticsFile = diagnosticsFile
+	}
+	return undecodableEventError
+}
+
+// writeEventDiagnostics writes given event description followed by a hexdump of its data onto
+// a file under given directory, and returns the file name
+func writeEventDiagnostics(dir string, undecodableEventError *UndecodableEventError, data []byte) (string, error) {
+	fileName := filepath.Join(dir, fmt.Sprintf("gh-ost-undecodable-event-%s-%d.txt", filepath.Base(undecodableEventError.Coordinates.LogFile), undecodableEventError.Coordinates.LogPos))
+	content := fmt.Sprintf("%s\n\n%d bytes:\n%s", undecodableEventError.Error(), len(data), hex.Dump(data))
+	if err := ioutil.WriteFile(fileName, []byte(content), 0640); err != nil {
+		return "", err
+	}
+	return fileName, nil
+}
+
+// isRowsEventType returns true for the rows event types go-mysql decodes
+func isRowsEventType(eventType replication.EventType) bool {
+	switch eventType {
+	case replication.WRITE_ROWS_EVENTv1, replication.UPDATE_ROWS_EVENTv1, replication.DELETE_ROWS_EVENTv1,
+		replication.WRITE_ROWS_EVENTv2, replication.UPDATE_ROWS_EVENTv2, replication.DELETE_ROWS_EVENTv2,
+		replication.PARTIAL_UPDATE_ROWS_EVENT:
+		return true
+	}
+	return false
+}
+
+// isFatalBinlogReadError recognizes ER_MASTER_FATAL_ERROR_READING_BINLOG sent by the streamed host
+func isFatalBinlogReadError(err error) bool {
+	myError, ok := errors.Cause(err).(*gomysql.MyError)
+	return ok && myError.Code == gomysql.ER_MASTER_FATAL_ERROR_READING_BINLOG
+}
+
+// UndecodableEventError indicates a binlog event which could not be decoded: of an exotic column
+// type, or malformed, e.g. by relay log corruption. It carries context for reproducing the failure.
+type UndecodableEventError struct {
+	Coordinates     mysql.BinlogCoordinates
+	Header          replication.EventHeader
+	TableMap        *replication.TableMapEvent
+	Err             string
+	DiagnosticsFile string
+}
+
+func (this *UndecodableEventError) Error() string {
+	table := ""
+	if this.TableMap != nil {
+		table = fmt.Sprintf(" on %s.%s (table id %d, %d columns, types %v)", sql.EscapeName(string(this.TableMap.Schema)), sql.EscapeName(string(this.TableMap.Table)), this.TableMap.TableID, this.TableMap.ColumnCount, this.TableMap.ColumnType)
+	}
+	diagnostics := ""
+	if this.DiagnosticsFile != "" {
+		diagnostics = fmt.Sprintf(". Event dumped to %s", this.DiagnosticsFile)
+	}
+	return fmt.Sprintf("Cannot decode %s event at %s (size %d, server_id %d, timestamp %d, flags %#x)%s: %s%s",
+		this.Header.EventType, this.Coordinates.DisplayString(), this.Header.EventSize, this.Header.ServerID, this.Header.Timestamp, this.Header.Flags, table, this.Err, diagnostics)
+}
+
+// NextCoordinates returns the coordinates of the event following the undecodable one
+func (this *UndecodableEventError) NextCoordinates() mysql.BinlogCoordinates {
+	return mysql.BinlogCoordinates{LogFile: this.Coordinates.LogFile, LogPos: int64(this.Header.LogPos)}
+}
+
+// SchemaDriftError indicates rows events of the migrated table which do not match the inspected
+// table schema. Applying them would map values onto the wrong columns.
+type SchemaDriftError struct {
+	DatabaseName string
+	TableName    string
+	Mismatch     string
+	Coordinates  mysql.BinlogCoordinates
+}
+
+func (this *SchemaDriftError) Error() string {
+	return fmt.Sprintf("Schema drift detected on %s.%s at %+v: %s", this.DatabaseName, this.TableName, this.Coordinates, this.Mismatch)
+}
+
+// binlogColumnTypes returns the types a column of given COLUMN_TYPE may be written as in a table map
+// event, or nil when unknown. ENUM and SET columns are written as strings, and temporal columns are
+// written with fractional seconds (DATETIME2 etc.) unless created before MySQL 5.6.
+func binlogColumnTypes(columnType string) []byte {
+	typeName := strings.ToLower(columnType)
+	if i := strings.IndexAny(typeName, "( "); i >= 0 {
+		typeName = typeName[:i]
+	}
+	switch typeName {
+	case "tinyint", "bool", "boolean":
+		return []byte{gomysql.MYSQL_TYPE_TINY}
+	case "smallint":
+		return []byte{gomysql.MYSQL_TYPE_SHORT}
+	case "mediumint":
+		return []byte{gomysql.MYSQL_TYPE_INT24}
+	case "int", "integer":
+		return []byte{gomysql.MYSQL_TYPE_LONG}
+	case "bigint":
+		return []byte{gomysql.MYSQL_TYPE_LONGLONG}
+	case "float":
+		return []byte{gomysql.MYSQL_TYPE_FLOAT}
+	case "double", "real":
+		return []byte{gomysql.MYSQL_TYPE_DOUBLE}
+	case "decimal", "numeric":
+		return []byte{gomysql.MYSQL_TYPE_NEWDECIMAL}
+	case "date":
+		return []byte{gomysql.MYSQL_TYPE_DATE, gomysql.MYSQL_TYPE_NEWDATE}
+	case "datetime":
+		return []byte{gomysql.MYSQL_TYPE_DATETIME2, gomysql.MYSQL_TYPE_DATETIME}
+	case "timestamp":
+		return []byte{gomysql.MYSQL_TYPE_TIMESTAMP2, gomysql.MYSQL_TYPE_TIMESTAMP}
+	case "time":
+		return []byte{gomysql.MYSQL_TYPE_TIME2, gomysql.MYSQL_TYPE_TIME}
+	case "year":
+		return []byte{gomysql.MYSQL_TYPE_YEAR}
+	case "char", "binary", "enum", "set":
+		return []byte{gomysql.MYSQL_TYPE_STRING}
+	case "varchar", "varbinary":
+		return []byte{gomysql.MYSQL_TYPE_VARCHAR, gomysql.MYSQL_TYPE_VAR_STRING}
+	case "tinytext", "text", "mediumtext", "longtext", "tinyblob", "blob", "mediumblob", "longblob":
+		return []byte{gomysql.MYSQL_TYPE_BLOB}
+	case "json":
+		return []byte{gomysql.MYSQL_TYPE_JSON}
+	case "bit":
+		return []byte{gomysql.MYSQL_TYPE_BIT}
+	case "geometry", "point", "linestring", "polygon", "multipoint", "multilinestring", "multipolygon", "geometrycollection", "geomcollection":
+		return []byte{gomysql.MYSQL_TYPE_GEOMETRY}
+	}
+	return nil
+}
+
+// describeSchemaDrift describes how a table map event of the migrated table mismatches the inspected
+// table's columns, or returns empty when it matches. The number and types of columns are always
+// validated; column names are only found in table map events with binlog_row_metadata=FULL.
+func describeSchemaDrift(tableMapEvent *replication.TableMapEvent, columns *sql.ColumnList) string {
+	if tableMapEvent.ColumnCount != uint64(columns.Len()) {
+		return fmt.Sprintf("binlog rows event has %d columns, while inspected table has %d columns", tableMapEvent.ColumnCount, columns.Len())
+	}
+	for i, column := range columns.Columns() {
+		if i < len(tableMapEvent.ColumnName) && !strings.EqualFold(string(tableMapEvent.ColumnName[i]), column.Name) {
+			return fmt.Sprintf("binlog column #%d is %s, while inspected table's column #%d is %s", i+1, sql.EscapeName(string(tableMapEvent.ColumnName[i])), i+1, sql.EscapeName(column.Name))
+		}
+		if i >= len(tableMapEvent.ColumnType) {
+			continue
+		}
+		if expectedTypes := binlogColumnTypes(column.MySQLType); len(expectedTypes) > 0 && bytes.IndexByte(expectedTypes, tableMapEvent.ColumnType[i]) < 0 {
+			return fmt.Sprintf("binlog column #%d has binlog type %d, which is not that of inspected table's column #%d %s %s", i+1, tableMapEvent.ColumnType[i], i+1, sql.EscapeName(column.Name), column.MySQLType)
+		}
+	}
+	return ""
+}
+
+// binlogEventStreamer delivers the parsed events of a binary log stream, as does the binlog syncer's streamer
+type binlogEventStreamer interface {
+	GetEvent(ctx context.Context) (*replication.BinlogEvent, error)
+}
+
 type GoMySQLReader struct {
 	migrationContext         *base.MigrationContext
 	connectionConfig         *mysql.ConnectionConfig
+	flavor                   string
 	binlogSyncer             *replication.BinlogSyncer
-	binlogStreamer           *replication.BinlogStreamer
+	binlogStreamer           binlogEventStreamer
 	currentCoordinates       mysql.BinlogCoordinates
 	currentCoordinatesMutex  *sync.Mutex
+	checksumAlgorithm        byte
+	formatDescription        []byte
+	currentGTID              string
+	transactionSequence      int64
+	transactionHasRows       bool
+	lastEventTimestamp       int64
+	tableMapEvents           map[uint64]*replication.TableMapEvent
+	schemaDriftWarnedTableID uint64
+	schemaMatchingTableMap   *replication.TableMapEvent
+	currentRowsQuery         string
+	cancelStreaming          context.CancelFunc
+	cancelStreamingMutex     *sync.Mutex
+	timestampLocation        *time.Location
 	LastAppliedRowsEventHint mysql.BinlogCoordinates
 }
 
-func NewGoMySQLReader(migrationContext *base.MigrationContext) *GoMySQLReader {
-	connectionConfig := migrationContext.InspectorConnectionConfig
+func NewGoMySQLReader(migrationContext *base.MigrationContext, connectionConfig *mysql.ConnectionConfig) *GoMySQLReader {
+	flavor := gomysql.MySQLFlavor
+	if migrationContext.IsMariaDB() {
+		flavor = gomysql.MariaDBFlavor
+	}
 	return &GoMySQLReader{
 		migrationContext:        migrationContext,
 		connectionConfig:        connectionConfig,
+		flavor:                  flavor,
 		currentCoordinates:      mysql.BinlogCoordinates{},
 		currentCoordinatesMutex: &sync.Mutex{},
-		binlogSyncer: replication.NewBinlogSyncer(replication.BinlogSyncerConfig{
-			ServerID:   uint32(migrationContext.ReplicaServerId),
-			Flavor:     gomysql.MySQLFlavor,
-			Host:       connectionConfig.Key.Hostname,
-			Port:       uint16(connectionConfig.Key.Port),
-			User:       connectionConfig.User,
-			Password:   connectionConfig.Password,
-			TLSConfig:  connectionConfig.TLSConfig(),
-			UseDecimal: true,
-		}),
+		timestampLocation:       migrationContext.GetConnectionTimeZoneLocation(),
+		checksumAlgorithm:       replication.BINLOG_CHECKSUM_ALG_UNDEF,
+		tableMapEvents:          make(map[uint64]*replication.TableMapEvent),
+		cancelStreamingMutex:    &sync.Mutex{},
+		binlogSyncer:            replication.NewBinlogSyncer(newBinlogSyncerConfig(migrationContext, connectionConfig, flavor)),
+	}
+}
+
+// newBinlogSyncerConfig returns the configuration of the binlog syncer. With --warn-on-binlog-checksum-mismatch,
+// the syncer does not verify event checksums, and mismatches are only logged by handleEvent.
+func newBinlogSyncerConfig(migrationContext *base.MigrationContext, connectionConfig *mysql.ConnectionConfig, flavor string) replication.BinlogSyncerConfig {
+	return replication.BinlogSyncerConfig{
+		ServerID:       uint32(migrationContext.ReplicaServerId),
+		Flavor:         flavor,
+		Host:           connectionConfig.Key.Hostname,
+		Port:           uint16(connectionConfig.Key.Port),
+		User:           connectionConfig.User,
+		Password:       connectionConfig.Password,
+		TLSConfig:      connectionConfig.TLSConfig(),
+		UseDecimal:     true,
+		VerifyChecksum: !migrationContext.WarnOnBinlogChecksumMismatch,
+		// The server sends heartbeats on an idle binlog, so that a read timeout only
+		// happens on a dead connection. The streamer then reconnects.
+		HeartbeatPeriod: time.Duration(migrationContext.BinlogSyncerHeartbeatPeriodSeconds) * time.Second,
+		ReadTimeout:     time.Duration(migrationContext.BinlogSyncerReadTimeoutSeconds) * time.Second,
 	}
 }
 
+// VerifyReplicationHandshake connects and authenticates the way the binlog syncer does, without
+// registering as a replica. The binlog syncer uses its own MySQL client, which negotiates the
+// authentication plugin (e.g. caching_sha2_password) independently of our other connections.
+func (this *GoMySQLReader) VerifyReplicationHandshake() error {
+	address := fmt.Sprintf("%s:%d", this.connectionConfig.Key.Hostname, this.connectionConfig.Key.Port)
+	conn, err := client.Connect(address, this.connectionConfig.User, this.connectionConfig.Password, "", func(c *client.Conn) {
+		c.SetTLSConfig(this.connectionConfig.TLSConfig())
+	})
+	if err != nil {
+		return fmt.Errorf("Binlog replication handshake failed on %s as %s: %+v", this.connectionConfig.Key.String(), this.connectionConfig.User, err)
+	}
+	defer conn.Close()
+	if err := conn.Ping(); err != nil {
+		return fmt.Errorf("Binlog replication connection to %s failed after handshake: %+v", this.connectionConfig.Key.String(), err)
+	}
+	return nil
+}
+
 // ConnectBinlogStreamer
 func (this *GoMySQLReader) ConnectBinlogStreamer(coordinates mysql.BinlogCoordinates) (err error) {
 	if coordinates.IsEmpty() {
@@ -55,6 +334,16 @@ func (this *GoMySQLReader) ConnectBinlogStreamer(coordinates mysql.BinlogCoordin
 	}
 
 	this.currentCoordinates = coordinates
+	if this.migrationContext.IsGTIDModeEnabled && coordinates.HasExecutedGtidSet() {
+		gtidSet, err := gomysql.ParseGTIDSet(this.flavor, coordinates.ExecutedGtidSet)
+		if err == nil {
+			this.migrationContext.Log.Infof("Connecting binlog streamer at GTID set %s", coordinates.ExecutedGtidSet)
+			// Start sync with specified executed GTID set
+			this.binlogStreamer, err = this.binlogSyncer.StartSyncGTID(gtidSet)
+			return err
+		}
+		this.migrationContext.Log.Warningf("Cannot parse GTID set %s: %+v. Falling back to file/pos coordinates", coordinates.ExecutedGtidSet, err)
+	}
 	this.migrationContext.Log.Infof("Connecting binlog streamer at %+v", this.currentCoordinates)
 	// Start sync with specified binlog file and position
 	this.binlogStreamer, err = this.binlogSyncer.StartSync(gomysql.Position{
@@ -65,6 +354,39 @@ func (this *GoMySQLReader) ConnectBinlogStreamer(coordinates mysql.BinlogCoordin
 	return err
 }
 
+// eventCoordinates computes the coordinates of given event, following the coordinates of the
+// last processed event. A rotate event moves on to the next binary log; artificial events carry
+// no position.
+func (this *GoMySQLReader) eventCoordinates(ev *replication.BinlogEvent) mysql.BinlogCoordinates {
+	coordinates := *this.GetCurrentBinlogCoordinates()
+	if rotateEvent, ok := ev.Event.(*replication.RotateEvent); ok {
+		coordinates.LogFile = string(rotateEvent.NextLogName)
+		coordinates.LogPos = int64(rotateEvent.Position)
+		return coordinates
+	}
+	if ev.Header.LogPos > 0 {
+		coordinates.LogPos = int64(ev.Header.LogPos)
+	}
+	return coordinates
+}
+
+// setCurrentBinlogCoordinates marks given coordinates, those of a fully processed event, as current
+func (this *GoMySQLReader) setCurrentBinlogCoordinates(coordinates mysql.BinlogCoordinates) {
+	this.currentCoordinatesMutex.Lock()
+	defer this.currentCoordinatesMutex.Unlock()
+	this.currentCoordinates = coordinates
+}
+
+// withExecutedGtidSet returns given coordinates updated with given executed GTID set. It applies
+// upon transaction commit, so that a reconnect never skips over a partially streamed transaction.
+func withExecutedGtidSet(coordinates mysql.BinlogCoordinates, gtidSet gomysql.GTIDSet) mysql.BinlogCoordinates {
+	if gtidSet != nil {
+		coordinates.ExecutedGtidSet = gtidSet.String()
+	}
+	return coordinates
+}
+
+// GetCurrentBinlogCoordinates returns the coordinates of the last fully processed event
 func (this *GoMySQLReader) GetCurrentBinlogCoordinates() *mysql.BinlogCoordinates {
 	this.currentCoordinatesMutex.Lock()
 	defer this.currentCoordinatesMutex.Unlock()
@@ -72,16 +394,151 @@ func (this *GoMySQLReader) GetCurrentBinlogCoordinates() *mysql.BinlogCoordinate
 	return &returnCoordinates
 }
 
+// GetLastAppliedRowsEventHint returns the coordinates of the last rows event handed over, below
+// which rows events are skipped as already applied
+func (this *GoMySQLReader) GetLastAppliedRowsEventHint() mysql.BinlogCoordinates {
+	return this.LastAppliedRowsEventHint
+}
+
+// SetLastAppliedRowsEventHint carries over the hint of a previous reader, resuming earlier on
+func (this *GoMySQLReader) SetLastAppliedRowsEventHint(coordinates mysql.BinlogCoordinates) {
+	this.LastAppliedRowsEventHint = coordinates
+}
+
+// GetLastEventTimestamp returns the timestamp of the most recently read binlog event,
+// or the zero time if no timestamped event has been read yet
+func (this *GoMySQLReader) GetLastEventTimestamp() time.Time {
+	lastEventTimestamp := atomic.LoadInt64(&this.lastEventTimestamp)
+	if lastEventTimestamp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(lastEventTimestamp, 0)
+}
+
+// isReplicaServerIdConflict recognizes the error the server sends to a replication client which
+// got replaced by another client with the same server_id
+func isReplicaServerIdConflict(err error) bool {
+	return strings.Contains(err.Error(), "same server_uuid/server_id") || strings.Contains(err.Error(), "same server_id")
+}
+
+// sendEntry pushes given entry down the channel, accounting for time blocked on a full channel
+func (this *GoMySQLReader) sendEntry(entriesChannel chan<- *BinlogEntry, binlogEntry *BinlogEntry) {
+	select {
+	case entriesChannel <- binlogEntry:
+	default:
+		blockedStartTime := time.Now()
+		atomic.StoreInt64(&this.migrationContext.StreamerBlockedSince, blockedStartTime.UnixNano())
+		entriesChannel <- binlogEntry
+		atomic.StoreInt64(&this.migrationContext.StreamerBlockedSince, 0)
+		// Time spent blocked does not count as a stalled stream
+		atomic.StoreInt64(&this.migrationContext.StreamerProgressTime, time.Now().UnixNano())
+		atomic.AddInt64(&this.migrationContext.StreamerBlockedNanos, int64(time.Since(blockedStartTime)))
+	}
+	this.migrationContext.ObserveBinlogEventsBufferDepth(int64(len(entriesChannel)))
+}
+
+// isStreamedTable returns true when events of given table are of interest to the migration,
+// i.e. the migrated table or the changelog table
+func (this *GoMySQLReader) isStreamedTable(databaseName, tableName string) bool {
+	if !strings.EqualFold(databaseName, this.migrationContext.DatabaseName) {
+		return false
+	}
+	return strings.EqualFold(tableName, this.migrationContext.OriginalTableName) ||
+		strings.EqualFold(tableName, this.migrationContext.GetChangelogTableName())
+}
+
+// toJSONDiffs converts a partial JSON value, per binlog_row_value_options=PARTIAL_JSON
+func toJSONDiffs(jsonDiffs replication.JsonDiffs) sql.JSONDiffs {
+	diffs := make(sql.JSONDiffs, len(jsonDiffs))
+	for i, jsonDiff := range jsonDiffs {
+		diffs[i] = sql.JSONDiff{Path: jsonDiff.Path, Value: jsonDiff.Value}
+		switch jsonDiff.Op {
+		case replication.JsonDiffOperationReplace:
+			diffs[i].Operation = sql.JSONDiffReplace
+		case replication.JsonDiffOperationInsert:
+			diffs[i].Operation = sql.JSONDiffInsert
+		case replication.JsonDiffOperationRemove:
+			diffs[i].Operation = sql.JSONDiffRemove
+		}
+	}
+	return diffs
+}
+
+// toRowColumnValues creates the column values of a row image. A rows event column bitmap
+// with unset bits indicates a partial row image, as with binlog_row_image=MINIMAL.
+// TIMESTAMP values, decoded as points in time, are formatted in given location: that of the time
+// zone sessions run in, which applies them as the very same points in time, regardless of the
+// local time zone or of the driver's.
+func toRowColumnValues(row []interface{}, columnBitmap []byte, timestampLocation *time.Location) *sql.ColumnValues {
+	for i, value := range row {
+		switch value := value.(type) {
+		case time.Time:
+			row[i] = value.In(timestampLocation).Format(timestampFormat)
+		case replication.JsonDiffs:
+			row[i] = toJSONDiffs(value)
+		}
+	}
+	isPresent := func(i int) bool {
+		return i>>3 < len(columnBitmap) && columnBitmap[i>>3]&(1<<(uint(i)&7)) > 0
+	}
+	var presentColumns []bool
+	for i := range row {
+		if presentColumns == nil && !isPresent(i) {
+			// A partial image. This is rare enough that we only allocate here.
+			presentColumns = make([]bool, len(row))
+			for j := range row {
+				presentColumns[j] = isPresent(j)
+			}
+		}
+	}
+	// go-mysql allocates each row afresh, so we may take over the slice
+	return sql.WrapColumnValues(row, presentColumns)
+}
+
+// verifyEventChecksum validates the CRC32 checksum trailing a raw binlog event. Events read from
+// binary logs written without checksums are not verified.
+func verifyEventChecksum(checksumAlgorithm byte, rawData []byte) error {
+	if checksumAlgorithm != replication.BINLOG_CHECKSUM_ALG_CRC32 {
+		return nil
+	}
+	if len(rawData) < replication.EventHeaderSize+replication.BinlogChecksumLength {
+		return replication.ErrChecksumMismatch
+	}
+	checksumOffset := len(rawData) - replication.BinlogChecksumLength
+	if crc32.ChecksumIEEE(rawData[:checksumOffset]) != binary.LittleEndian.Uint32(rawData[checksumOffset:]) {
+		return replication.ErrChecksumMismatch
+	}
+	return nil
+}
+
 // StreamEvents
-func (this *GoMySQLReader) handleRowsEvent(ev *replication.BinlogEvent, rowsEvent *replication.RowsEvent, entriesChannel chan<- *BinlogEntry) error {
-	if this.currentCoordinates.SmallerThanOrEquals(&this.LastAppliedRowsEventHint) {
-		this.migrationContext.Log.Debugf("Skipping handled query at %+v", this.currentCoordinates)
+func (this *GoMySQLReader) handleRowsEvent(ev *replication.BinlogEvent, rowsEvent *replication.RowsEvent, coordinates mysql.BinlogCoordinates, entriesChannel chan<- *BinlogEntry) error {
+	if coordinates.SmallerThanOrEquals(&this.LastAppliedRowsEventHint) {
+		this.migrationContext.Log.Debugf("Skipping handled query at %+v", coordinates)
 		return nil
 	}
 
 	dml := ToEventDML(ev.Header.EventType.String())
 	if dml == NotDML {
-		return fmt.Errorf("Unknown DML type: %s", ev.Header.EventType.String())
+		return this.newUndecodableEventError(ev.Header, ev.RawData, rowsEvent.Table, fmt.Errorf("Unknown DML type: %s", ev.Header.EventType.String()))
+	}
+	if dml == UpdateDML && len(rowsEvent.Rows)%2 != 0 {
+		return this.newUndecodableEventError(ev.Header, ev.RawData, rowsEvent.Table, fmt.Errorf("Malformed update rows event: %d row images, expected before/after pairs", len(rowsEvent.Rows)))
+	}
+	if !this.isStreamedTable(string(rowsEvent.Table.Schema), string(rowsEvent.Table.Table)) {
+		// Most rows events are typically for other tables; skip them before building any entries
+		atomic.AddInt64(&this.migrationContext.SkippedRowsEvents, 1)
+		this.LastAppliedRowsEventHint = coordinates
+		return nil
+	}
+	if ev.Header.EventType == replication.PARTIAL_UPDATE_ROWS_EVENT && !this.migrationContext.AllowPartialJSONUpdates {
+		return fmt.Errorf("Partial JSON update event on %s.%s at %+v. Please `set global binlog_row_value_options=''` and make sure no session sets PARTIAL_JSON, or provide --allow-partial-json-updates", rowsEvent.Table.Schema, rowsEvent.Table.Table, coordinates)
+	}
+	atomic.AddInt64(&this.migrationContext.StreamedRowsEvents, 1)
+	databaseName := string(rowsEvent.Table.Schema)
+	tableName := string(rowsEvent.Table.Table)
+	if err := this.detectSchemaDrift(rowsEvent.Table, coordinates); err != nil {
+		return err
 	}
 	for i, row := range rowsEvent.Rows {
 		if dml == UpdateDML && i%2 == 1 {
@@ -89,68 +546,288 @@ func (this *GoMySQLReader) handleRowsEvent(ev *replication.BinlogEvent, rowsEven
 			// We do both at the same time
 			continue
 		}
-		binlogEntry := NewBinlogEntryAt(this.currentCoordinates)
-		binlogEntry.DmlEvent = NewBinlogDMLEvent(
-			string(rowsEvent.Table.Schema),
-			string(rowsEvent.Table.Table),
-			dml,
-		)
+		binlogEntry := acquireBinlogEntryAt(coordinates)
+		binlogEntry.TransactionSequence = this.transactionSequence
+		binlogEntry.DmlEvent = acquireBinlogDMLEvent(databaseName, tableName, dml)
+		binlogEntry.DmlEvent.Coordinates = coordinates
+		binlogEntry.DmlEvent.Query = this.currentRowsQuery
+		binlogEntry.DmlEvent.Timestamp = time.Unix(int64(ev.Header.Timestamp), 0)
 		switch dml {
 		case InsertDML:
 			{
-				binlogEntry.DmlEvent.NewColumnValues = sql.ToColumnValues(row)
+				binlogEntry.DmlEvent.NewColumnValues = toRowColumnValues(row, rowsEvent.ColumnBitmap1, this.timestampLocation)
 			}
 		case UpdateDML:
 			{
-				binlogEntry.DmlEvent.WhereColumnValues = sql.ToColumnValues(row)
-				binlogEntry.DmlEvent.NewColumnValues = sql.ToColumnValues(rowsEvent.Rows[i+1])
+				binlogEntry.DmlEvent.WhereColumnValues = toRowColumnValues(row, rowsEvent.ColumnBitmap1, this.timestampLocation)
+				binlogEntry.DmlEvent.NewColumnValues = toRowColumnValues(rowsEvent.Rows[i+1], rowsEvent.ColumnBitmap2, this.timestampLocation)
 			}
 		case DeleteDML:
 			{
-				binlogEntry.DmlEvent.WhereColumnValues = sql.ToColumnValues(row)
+				binlogEntry.DmlEvent.WhereColumnValues = toRowColumnValues(row, rowsEvent.ColumnBitmap1, this.timestampLocation)
 			}
 		}
 		// The channel will do the throttling. Whoever is reading from the channel
 		// decides whether action is taken synchronously (meaning we wait before
 		// next iteration) or asynchronously (we keep pushing more events)
 		// In reality, reads will be synchronous
-		entriesChannel <- binlogEntry
+		this.sendEntry(entriesChannel, binlogEntry)
+		atomic.AddInt64(&this.migrationContext.StreamerRowsEmitted, 1)
+		this.transactionHasRows = true
 	}
-	this.LastAppliedRowsEventHint = this.currentCoordinates
+	this.LastAppliedRowsEventHint = coordinates
 	return nil
 }
 
-// StreamEvents
-func (this *GoMySQLReader) StreamEvents(canStopStreaming func() bool, entriesChannel chan<- *BinlogEntry) error {
-	if canStopStreaming() {
+// detectConcurrentDDL checks whether a query event is a DDL statement on the migrated table, or on
+// the ghost or old tables. Statements issued by this migration itself are ignored.
+func (this *GoMySQLReader) detectConcurrentDDL(queryEvent *replication.QueryEvent, coordinates mysql.BinlogCoordinates) error {
+	query := string(queryEvent.Query)
+	if this.isOwnDDL(query) {
+		return nil
+	}
+	ddl, tables := sql.ParseDDLStatementTables(query)
+	watchedTables := []sql.DDLTable{{Schema: this.migrationContext.DatabaseName, Name: this.migrationContext.OriginalTableName}}
+	if ddl != "DROP" {
+		// The server rewrites DROP TABLE statements, dropping gh-ost's comment. Since gh-ost itself
+		// drops the ghost & old tables, only the original table is watched for DROP.
+		watchedTables = append(watchedTables,
+			sql.DDLTable{Schema: this.migrationContext.GetTargetDatabaseName(), Name: this.migrationContext.GetGhostTableName()},
+			sql.DDLTable{Schema: this.migrationContext.DatabaseName, Name: this.migrationContext.GetOldTableName()},
+		)
+	}
+	for _, table := range tables {
+		schema := table.Schema
+		if schema == "" {
+			schema = string(queryEvent.Schema)
+		}
+		for _, watchedTable := range watchedTables {
+			if !strings.EqualFold(schema, watchedTable.Schema) || !strings.EqualFold(table.Name, watchedTable.Name) {
+				continue
+			}
+			ddlError := &ConcurrentDDLError{Query: query, Coordinates: coordinates}
+			if this.migrationContext.WarnOnConcurrentDDL {
+				this.migrationContext.Log.Warningf("%s. --warn-on-concurrent-ddl provided, so I'm proceeding", ddlError.Error())
+				return nil
+			}
+			return ddlError
+		}
+	}
+	return nil
+}
+
+// isOwnDDL tells whether given statement is issued by this migration: marked by its DDL comment, or, as are
+// statements dropping the old table's foreign keys, by the old table's. Other gh-ost migrations' are not.
+func (this *GoMySQLReader) isOwnDDL(query string) bool {
+	oldTableComment := fmt.Sprintf("/* gh-ost %s.%s */", sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.GetOldTableName()))
+	return strings.Contains(query, this.migrationContext.GetDDLComment()) || strings.Contains(query, oldTableComment)
+}
+
+// detectSchemaDrift validates the table map of a rows event against the inspected migrated table:
+// its column count and types, and, given binlog_row_metadata=FULL, its column names.
+func (this *GoMySQLReader) detectSchemaDrift(tableMapEvent *replication.TableMapEvent, coordinates mysql.BinlogCoordinates) error {
+	if !strings.EqualFold(string(tableMapEvent.Table), this.migrationContext.OriginalTableName) {
+		return nil
+	}
+	originalTableColumns := this.migrationContext.OriginalTableColumns
+	if originalTableColumns == nil || tableMapEvent == this.schemaMatchingTableMap {
+		// Rows events following a table map share it: it is validated once
+		return nil
+	}
+	mismatch := describeSchemaDrift(tableMapEvent, originalTableColumns)
+	if mismatch == "" {
+		this.schemaMatchingTableMap = tableMapEvent
+		return nil
+	}
+	driftError := &SchemaDriftError{
+		DatabaseName: string(tableMapEvent.Schema),
+		TableName:    string(tableMapEvent.Table),
+		Mismatch:     mismatch,
+		Coordinates:  coordinates,
+	}
+	if !this.migrationContext.WarnOnSchemaDrift {
+		return driftError
+	}
+	if this.schemaDriftWarnedTableID != tableMapEvent.TableID {
+		// Warn once per table map, rather than on every rows event
+		this.migrationContext.Log.Warningf("%s. --warn-on-schema-drift provided, so I'm proceeding", driftError.Error())
+		this.schemaDriftWarnedTableID = tableMapEvent.TableID
+	}
+	return nil
+}
+
+// handleTransactionEnd notes a transaction commit. An end-of-transaction marker is only sent
+// downstream for transactions which had rows events streamed.
+func (this *GoMySQLReader) handleTransactionEnd(coordinates mysql.BinlogCoordinates, entriesChannel chan<- *BinlogEntry) {
+	if this.transactionHasRows {
+		this.sendEntry(entriesChannel, NewTransactionEndBinlogEntryAt(coordinates, this.transactionSequence))
+	}
+	this.transactionSequence++
+	this.transactionHasRows = false
+	this.currentRowsQuery = ""
+}
+
+// handleEvent processes a single binlog event. The event's coordinates are computed once, and
+// only become current once the event is fully processed.
+func (this *GoMySQLReader) handleEvent(ev *replication.BinlogEvent, entriesChannel chan<- *BinlogEntry) (err error) {
+	atomic.AddInt64(&this.migrationContext.StreamerEventsRead, 1)
+	atomic.AddInt64(&this.migrationContext.StreamerBytesRead, int64(len(ev.RawData)))
+	atomic.StoreInt64(&this.migrationContext.StreamerProgressTime, time.Now().UnixNano())
+	coordinates := this.eventCoordinates(ev)
+	if formatDescriptionEvent, ok := ev.Event.(*replication.FormatDescriptionEvent); ok {
+		this.checksumAlgorithm = formatDescriptionEvent.ChecksumAlgorithm
+		// Kept for parsing the events of compressed transactions
+		this.formatDescription = append(this.formatDescription[:0], ev.RawData...)
+	} else if this.migrationContext.WarnOnBinlogChecksumMismatch {
+		if err := verifyEventChecksum(this.checksumAlgorithm, ev.RawData); err != nil {
+			this.migrationContext.Log.Warningf("Binlog checksum mismatch on %s event ending at %s; binary log data may be corrupted", ev.Header.EventType, coordinates.DisplayString())
+		}
+	}
+	if ev.Header.Timestamp > 0 {
+		// Artificial events (e.g. format description, rotate) carry a zero timestamp
+		atomic.StoreInt64(&this.lastEventTimestamp, int64(ev.Header.Timestamp))
+	}
+
+	if ev.Header.EventType == transactionPayloadEventType {
+		coordinates, err = this.handleTransactionPayloadEvent(ev, coordinates, entriesChannel)
+	} else {
+		coordinates, err = this.handleDecodedEvent(ev, coordinates, entriesChannel)
+	}
+	if err != nil {
+		return err
+	}
+	this.setCurrentBinlogCoordinates(coordinates)
+	return nil
+}
+
+// handleDecodedEvent processes a single binlog event, streamed or of a compressed transaction, at given
+// coordinates. It returns the coordinates to mark as current once processed.
+func (this *GoMySQLReader) handleDecodedEvent(ev *replication.BinlogEvent, coordinates mysql.BinlogCoordinates, entriesChannel chan<- *BinlogEntry) (mysql.BinlogCoordinates, error) {
+	switch binlogEvent := ev.Event.(type) {
+	case *replication.RotateEvent:
+		this.migrationContext.Log.Infof("rotate to next log from %s to %s", this.GetCurrentBinlogCoordinates().DisplayString(), coordinates.DisplayString())
+	case *replication.TableMapEvent:
+		this.tableMapEvents[binlogEvent.TableID] = binlogEvent
+	case *replication.RowsEvent:
+		if err := this.handleRowsEvent(ev, binlogEvent, coordinates, entriesChannel); err != nil {
+			return coordinates, err
+		}
+		if binlogEvent.Flags&replication.RowsEventStmtEndFlag > 0 {
+			this.currentRowsQuery = ""
+		}
+	case *replication.RowsQueryEvent:
+		// The originating query of the rows events that follow, up to the statement's end
+		this.currentRowsQuery = string(binlogEvent.Query)
+	case *replication.MariadbAnnotateRowsEvent:
+		this.currentRowsQuery = string(binlogEvent.Query)
+	case *replication.MariadbGTIDEvent:
+		// GTID progress is tracked upon XID
+	case *replication.XIDEvent:
+		coordinates = withExecutedGtidSet(coordinates, binlogEvent.GSet)
+		this.handleTransactionEnd(coordinates, entriesChannel)
+	case *replication.GTIDEvent:
+		// A GTID event opens a new transaction
+		if this.transactionHasRows {
+			this.handleTransactionEnd(*this.GetCurrentBinlogCoordinates(), entriesChannel)
+		}
+		if sid, err := uuid.FromBytes(binlogEvent.SID); err == nil {
+			this.currentGTID = fmt.Sprintf("%s:%d", sid.String(), binlogEvent.GNO)
+		}
+	case *replication.QueryEvent:
+		if err := this.detectConcurrentDDL(binlogEvent, coordinates); err != nil {
+			return coordinates, err
+		}
+		query := strings.ToUpper(string(binlogEvent.Query))
+		if query != "BEGIN" {
+			coordinates = withExecutedGtidSet(coordinates, binlogEvent.GSet)
+		}
+		if query == "COMMIT" {
+			// Non transactional engines commit via query event rather than XID
+			this.handleTransactionEnd(coordinates, entriesChannel)
+		}
+	}
+	return coordinates, nil
+}
+
+// handleTransactionPayloadEvent processes the events of a transaction compressed per binlog_transaction_compression.
+// They carry no positions of their own, and are processed at the payload event's coordinates.
+func (this *GoMySQLReader) handleTransactionPayloadEvent(ev *replication.BinlogEvent, coordinates mysql.BinlogCoordinates, entriesChannel chan<- *BinlogEntry) (mysql.BinlogCoordinates, error) {
+	genericEvent, ok := ev.Event.(*replication.GenericEvent)
+	if !ok {
+		return coordinates, this.newUndecodableEventError(ev.Header, ev.RawData, nil, fmt.Errorf("Unexpected transaction payload event decoding: %T", ev.Event))
+	}
+	payloadEvents, err := parseTransactionPayload(this.formatDescription, genericEvent.Data)
+	if err != nil {
+		return coordinates, this.newUndecodableEventError(ev.Header, ev.RawData, nil, err)
+	}
+	// The payload's rows events share its coordinates: all of them are handled, unless the payload
+	// was handled already. Each is checked against the rows event hint as of the payload.
+	lastAppliedRowsEventHint := this.LastAppliedRowsEventHint
+	for _, payloadEvent := range payloadEvents {
+		switch binlogEvent := payloadEvent.Event.(type) {
+		case *replication.RowsEvent:
+			this.LastAppliedRowsEventHint = lastAppliedRowsEventHint
+		case *replication.XIDEvent:
+			// The binlog syncer only tracks the GTID set of the events it reads itself
+			binlogEvent.GSet = this.payloadExecutedGtidSet(coordinates)
+		}
+		if coordinates, err = this.handleDecodedEvent(payloadEvent, coordinates, entriesChannel); err != nil {
+			return coordinates, err
+		}
+	}
+	return coordinates, nil
+}
+
+// payloadExecutedGtidSet returns the executed GTID set upon committing a compressed transaction: that
+// of given coordinates, updated with the transaction's GTID. It is nil unless streaming by GTID.
+func (this *GoMySQLReader) payloadExecutedGtidSet(coordinates mysql.BinlogCoordinates) gomysql.GTIDSet {
+	if this.flavor != gomysql.MySQLFlavor || !coordinates.HasExecutedGtidSet() || this.currentGTID == "" {
 		return nil
 	}
+	gtidSet, err := gomysql.ParseMysqlGTIDSet(coordinates.ExecutedGtidSet)
+	if err != nil {
+		this.migrationContext.Log.Warningf("Cannot parse GTID set %s: %+v", coordinates.ExecutedGtidSet, err)
+		return nil
+	}
+	if err := gtidSet.Update(this.currentGTID); err != nil {
+		this.migrationContext.Log.Warningf("Cannot update GTID set %s with %s: %+v", coordinates.ExecutedGtidSet, this.currentGTID, err)
+		return nil
+	}
+	return gtidSet
+}
+
+// StreamEvents
+func (this *GoMySQLReader) StreamEvents(ctx context.Context, entriesChannel chan<- *BinlogEntry) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	this.setCancelStreaming(cancel)
+
 	for {
-		if canStopStreaming() {
+		if ctx.Err() != nil {
 			break
 		}
-		ev, err := this.binlogStreamer.GetEvent(context.Background())
+		ev, err := this.binlogStreamer.GetEvent(ctx)
 		if err != nil {
+			if ctx.Err() != nil {
+				// Streaming was stopped while waiting on an event
+				break
+			}
+			if isReplicaServerIdConflict(err) {
+				return &ReplicaServerIdConflictError{ServerId: this.migrationContext.ReplicaServerId, Err: err}
+			}
+			if isFatalBinlogReadError(err) {
+				return &FatalBinlogReadError{Coordinates: *this.GetCurrentBinlogCoordinates(), Err: err}
+			}
+			if eventError, ok := errors.Cause(err).(*replication.EventError); ok {
+				return this.newUndecodableEventError(eventError.Header, eventError.Data, nil, errors.New(eventError.Err))
+			}
+			if errors.Cause(err) == replication.ErrChecksumMismatch {
+				return fmt.Errorf("Binlog checksum mismatch on event following %+v; binary log data may be corrupted. Use --warn-on-binlog-checksum-mismatch to proceed regardless", *this.GetCurrentBinlogCoordinates())
+			}
 			return err
 		}
-		func() {
-			this.currentCoordinatesMutex.Lock()
-			defer this.currentCoordinatesMutex.Unlock()
-			this.currentCoordinates.LogPos = int64(ev.Header.LogPos)
-		}()
-
-		switch binlogEvent := ev.Event.(type) {
-		case *replication.RotateEvent:
-			func() {
-				this.currentCoordinatesMutex.Lock()
-				defer this.currentCoordinatesMutex.Unlock()
-				this.currentCoordinates.LogFile = string(binlogEvent.NextLogName)
-			}()
-			this.migrationContext.Log.Infof("rotate to next log from %s:%d to %s", this.currentCoordinates.LogFile, int64(ev.Header.LogPos), binlogEvent.NextLogName)
-		case *replication.RowsEvent:
-			if err := this.handleRowsEvent(ev, binlogEvent, entriesChannel); err != nil {
-				return err
-			}
+		if err := this.handleEvent(ev, entriesChannel); err != nil {
+			return err
 		}
 	}
 	this.migrationContext.Log.Debugf("done streaming events")
@@ -158,7 +835,22 @@ func (this *GoMySQLReader) StreamEvents(canStopStreaming func() bool, entriesCha
 	return nil
 }
 
+// setCancelStreaming registers the cancel function of an ongoing StreamEvents, to be used by Close
+func (this *GoMySQLReader) setCancelStreaming(cancel context.CancelFunc) {
+	this.cancelStreamingMutex.Lock()
+	defer this.cancelStreamingMutex.Unlock()
+	this.cancelStreaming = cancel
+}
+
 func (this *GoMySQLReader) Close() error {
+	func() {
+		// Interrupt an in-flight GetEvent, which would otherwise block on an idle binlog
+		this.cancelStreamingMutex.Lock()
+		defer this.cancelStreamingMutex.Unlock()
+		if this.cancelStreaming != nil {
+			this.cancelStreaming()
+		}
+	}()
 	this.binlogSyncer.Close()
 	return nil
 }
@@ -0,0 +1,886 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package binlog
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/github/gh-ost/go/base"
+	"github.com/github/gh-ost/go/mysql"
+	"github.com/github/gh-ost/go/sql"
+
+	"github.com/juju/errors"
+	"github.com/klauspost/compress/zstd"
+	"github.com/openark/golib/log"
+	test "github.com/openark/golib/tests"
+	gomysql "github.com/siddontang/go-mysql/mysql"
+	"github.com/siddontang/go-mysql/replication"
+)
+
+func init() {
+	log.SetLevel(log.ERROR)
+}
+
+func newTestRawEvent(payload []byte) []byte {
+	rawData := make([]byte, replication.EventHeaderSize, replication.EventHeaderSize+len(payload)+replication.BinlogChecksumLength)
+	rawData = append(rawData, payload...)
+	checksum := make([]byte, replication.BinlogChecksumLength)
+	binary.LittleEndian.PutUint32(checksum, crc32.ChecksumIEEE(rawData))
+	return append(rawData, checksum...)
+}
+
+func TestVerifyEventChecksum(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		rawData := newTestRawEvent([]byte("some row event payload"))
+		test.S(t).ExpectNil(verifyEventChecksum(replication.BINLOG_CHECKSUM_ALG_CRC32, rawData))
+	})
+	t.Run("corrupted", func(t *testing.T) {
+		rawData := newTestRawEvent([]byte("some row event payload"))
+		rawData[replication.EventHeaderSize+3] ^= 0xff
+		test.S(t).ExpectEquals(verifyEventChecksum(replication.BINLOG_CHECKSUM_ALG_CRC32, rawData), replication.ErrChecksumMismatch)
+	})
+	t.Run("truncated", func(t *testing.T) {
+		rawData := make([]byte, replication.EventHeaderSize)
+		test.S(t).ExpectEquals(verifyEventChecksum(replication.BINLOG_CHECKSUM_ALG_CRC32, rawData), replication.ErrChecksumMismatch)
+	})
+	t.Run("without checksum", func(t *testing.T) {
+		rawData := append(make([]byte, replication.EventHeaderSize), []byte("some row event payload")...)
+		test.S(t).ExpectNil(verifyEventChecksum(replication.BINLOG_CHECKSUM_ALG_OFF, rawData))
+		test.S(t).ExpectNil(verifyEventChecksum(replication.BINLOG_CHECKSUM_ALG_UNDEF, rawData))
+	})
+}
+
+// testBinlogEventStreamer parses given raw events as the binlog syncer does, per the syncer's configuration.
+// Once out of events, it cancels streaming.
+type testBinlogEventStreamer struct {
+	parser    *replication.BinlogParser
+	rawEvents [][]byte
+	cancel    context.CancelFunc
+}
+
+func (this *testBinlogEventStreamer) GetEvent(ctx context.Context) (*replication.BinlogEvent, error) {
+	if len(this.rawEvents) == 0 {
+		this.cancel()
+		return nil, ctx.Err()
+	}
+	rawEvent := this.rawEvents[0]
+	this.rawEvents = this.rawEvents[1:]
+	ev, err := this.parser.Parse(rawEvent)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return ev, nil
+}
+
+// buildTestChecksumEvent returns the raw bytes of a binlog event, as buildTestEvent, trailed by its CRC32 checksum
+func buildTestChecksumEvent(eventType replication.EventType, logPos uint32, body []byte) []byte {
+	data := buildTestEvent(eventType, logPos, append(body, make([]byte, replication.BinlogChecksumLength)...))
+	checksumOffset := len(data) - replication.BinlogChecksumLength
+	binary.LittleEndian.PutUint32(data[checksumOffset:], crc32.ChecksumIEEE(data[:checksumOffset]))
+	return data
+}
+
+func TestStreamEventsChecksumMismatch(t *testing.T) {
+	streamEvents := func(migrationContext *base.MigrationContext) (*GoMySQLReader, error) {
+		reader := NewGoMySQLReader(migrationContext, migrationContext.InspectorConnectionConfig)
+		reader.currentCoordinates = mysql.BinlogCoordinates{LogFile: "mysql-bin.000017", LogPos: 100}
+
+		formatDescription := make([]byte, 2+50+4+1+40+5)
+		binary.LittleEndian.PutUint16(formatDescription, 4)
+		copy(formatDescription[2:], "8.0.30")
+		formatDescription[56] = replication.EventHeaderSize
+		formatDescription[len(formatDescription)-5] = replication.BINLOG_CHECKSUM_ALG_CRC32
+		corruptedXID := buildTestChecksumEvent(replication.XID_EVENT, 300, []byte{2, 0, 0, 0, 0, 0, 0, 0})
+		corruptedXID[replication.EventHeaderSize] ^= 0xff
+
+		parser := replication.NewBinlogParser()
+		parser.SetVerifyChecksum(newBinlogSyncerConfig(migrationContext, migrationContext.InspectorConnectionConfig, reader.flavor).VerifyChecksum)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		reader.binlogStreamer = &testBinlogEventStreamer{
+			parser: parser,
+			rawEvents: [][]byte{
+				buildTestEvent(replication.FORMAT_DESCRIPTION_EVENT, 0, formatDescription),
+				buildTestChecksumEvent(replication.XID_EVENT, 200, []byte{1, 0, 0, 0, 0, 0, 0, 0}),
+				corruptedXID,
+			},
+			cancel: cancel,
+		}
+		return reader, reader.StreamEvents(ctx, make(chan *BinlogEntry, 10))
+	}
+
+	t.Run("verified", func(t *testing.T) {
+		migrationContext := base.NewMigrationContext()
+		migrationContext.ReplicaServerId = 99999
+		reader, err := streamEvents(migrationContext)
+		test.S(t).ExpectNotNil(err)
+		test.S(t).ExpectTrue(strings.Contains(err.Error(), "Binlog checksum mismatch on event following mysql-bin.000017:200"))
+		test.S(t).ExpectTrue(strings.Contains(err.Error(), "--warn-on-binlog-checksum-mismatch"))
+		test.S(t).ExpectEquals(reader.GetCurrentBinlogCoordinates().LogPos, int64(200))
+	})
+	t.Run("warn-on-binlog-checksum-mismatch", func(t *testing.T) {
+		migrationContext := base.NewMigrationContext()
+		migrationContext.ReplicaServerId = 99999
+		migrationContext.WarnOnBinlogChecksumMismatch = true
+		reader, err := streamEvents(migrationContext)
+		// The mismatch is logged, and the corrupted event is handled
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(reader.GetCurrentBinlogCoordinates().LogPos, int64(300))
+		test.S(t).ExpectEquals(reader.checksumAlgorithm, byte(replication.BINLOG_CHECKSUM_ALG_CRC32))
+	})
+}
+
+func TestHandleTransactionEnd(t *testing.T) {
+	migrationContext := base.NewMigrationContext()
+	migrationContext.ReplicaServerId = 99999
+	reader := NewGoMySQLReader(migrationContext, migrationContext.InspectorConnectionConfig)
+	coordinates := mysql.BinlogCoordinates{LogFile: "mysql-bin.000017", LogPos: 1234}
+	entriesChannel := make(chan *BinlogEntry, 1)
+
+	reader.handleTransactionEnd(coordinates, entriesChannel)
+	test.S(t).ExpectEquals(len(entriesChannel), 0)
+	test.S(t).ExpectEquals(reader.transactionSequence, int64(1))
+
+	reader.transactionHasRows = true
+	reader.handleTransactionEnd(coordinates, entriesChannel)
+	test.S(t).ExpectEquals(len(entriesChannel), 1)
+	test.S(t).ExpectEquals(reader.transactionSequence, int64(2))
+	test.S(t).ExpectFalse(reader.transactionHasRows)
+
+	binlogEntry := <-entriesChannel
+	test.S(t).ExpectTrue(binlogEntry.IsTransactionEnd)
+	test.S(t).ExpectTrue(binlogEntry.DmlEvent == nil)
+	test.S(t).ExpectEquals(binlogEntry.TransactionSequence, int64(1))
+	test.S(t).ExpectEquals(binlogEntry.Coordinates.LogPos, int64(1234))
+}
+
+func TestDetectConcurrentDDL(t *testing.T) {
+	migrationContext := base.NewMigrationContext()
+	migrationContext.ReplicaServerId = 99999
+	migrationContext.DatabaseName = "test"
+	migrationContext.OriginalTableName = "tbl"
+	reader := NewGoMySQLReader(migrationContext, migrationContext.InspectorConnectionConfig)
+
+	queryEvent := func(schema, query string) *replication.QueryEvent {
+		return &replication.QueryEvent{Schema: []byte(schema), Query: []byte(query)}
+	}
+	test.S(t).ExpectNotNil(reader.detectConcurrentDDL(queryEvent("test", "alter table tbl add column i int"), mysql.BinlogCoordinates{}))
+	test.S(t).ExpectNotNil(reader.detectConcurrentDDL(queryEvent("other", "truncate table `test`.`tbl`"), mysql.BinlogCoordinates{}))
+	test.S(t).ExpectNotNil(reader.detectConcurrentDDL(queryEvent("test", "rename table other to _tbl_gho"), mysql.BinlogCoordinates{}))
+	test.S(t).ExpectNotNil(reader.detectConcurrentDDL(queryEvent("test", "DROP TABLE `tbl` /* generated by server */"), mysql.BinlogCoordinates{}))
+	test.S(t).ExpectNil(reader.detectConcurrentDDL(queryEvent("test", "DROP TABLE `_tbl_del` /* generated by server */"), mysql.BinlogCoordinates{}))
+	test.S(t).ExpectNil(reader.detectConcurrentDDL(queryEvent("test", "alter table other add column tbl int"), mysql.BinlogCoordinates{}))
+	test.S(t).ExpectNil(reader.detectConcurrentDDL(queryEvent("other", "alter table tbl add column i int"), mysql.BinlogCoordinates{}))
+	test.S(t).ExpectNil(reader.detectConcurrentDDL(queryEvent("test", "rename /* gh-ost `test`.`_tbl_gho` */ table `test`.`tbl` to `test`.`_tbl_del`"), mysql.BinlogCoordinates{}))
+	test.S(t).ExpectNil(reader.detectConcurrentDDL(queryEvent("test", "alter /* gh-ost `test`.`_tbl_del` */ table `test`.`_tbl_del` drop foreign key fk"), mysql.BinlogCoordinates{}))
+	// Other gh-ost migrations' statements are concurrent DDL
+	test.S(t).ExpectNotNil(reader.detectConcurrentDDL(queryEvent("test", "rename /* gh-ost */ table `test`.`tbl` to `test`.`_tbl_del`"), mysql.BinlogCoordinates{}))
+	test.S(t).ExpectNotNil(reader.detectConcurrentDDL(queryEvent("test", "alter /* gh-ost `test`.`_tbl_v2_gho` */ table `test`.`tbl` rename `test`.`_tbl_v2_del`"), mysql.BinlogCoordinates{}))
+	test.S(t).ExpectNil(reader.detectConcurrentDDL(queryEvent("test", "BEGIN"), mysql.BinlogCoordinates{}))
+
+	migrationContext.TargetDatabaseName = "target"
+	test.S(t).ExpectNotNil(reader.detectConcurrentDDL(queryEvent("other", "alter table `target`.`_tbl_gho` add column i int"), mysql.BinlogCoordinates{}))
+	test.S(t).ExpectNil(reader.detectConcurrentDDL(queryEvent("test", "alter table _tbl_gho add column i int"), mysql.BinlogCoordinates{}))
+	test.S(t).ExpectNotNil(reader.detectConcurrentDDL(queryEvent("test", "alter table tbl add column i int"), mysql.BinlogCoordinates{}))
+
+	migrationContext.WarnOnConcurrentDDL = true
+	test.S(t).ExpectNil(reader.detectConcurrentDDL(queryEvent("test", "alter table tbl add column i int"), mysql.BinlogCoordinates{}))
+}
+
+func TestToRowColumnValues(t *testing.T) {
+	row := []interface{}{1, nil, "c", nil, nil, nil, nil, nil, 9}
+
+	fullValues := toRowColumnValues(row, []byte{0xff, 0x01}, time.UTC)
+	test.S(t).ExpectFalse(fullValues.IsPartial())
+
+	partialValues := toRowColumnValues(row, []byte{0x05, 0x01}, time.UTC)
+	test.S(t).ExpectTrue(partialValues.IsPartial())
+	test.S(t).ExpectTrue(partialValues.IsPresent(0))
+	test.S(t).ExpectFalse(partialValues.IsPresent(1))
+	test.S(t).ExpectTrue(partialValues.IsPresent(2))
+	test.S(t).ExpectFalse(partialValues.IsPresent(3))
+	test.S(t).ExpectTrue(partialValues.IsPresent(8))
+}
+
+func TestToRowColumnValuesTimestamp(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	test.S(t).ExpectNil(err)
+	// Local time in Berlin, one hour before the end of DST; and the same point in time in UTC
+	timestamp := time.Date(2023, 10, 29, 1, 30, 0, 0, time.UTC).In(berlin)
+	{
+		values := toRowColumnValues([]interface{}{1, timestamp}, []byte{0x03}, time.UTC)
+		test.S(t).ExpectEquals(values.StringColumn(1), "2023-10-29 01:30:00")
+	}
+	{
+		values := toRowColumnValues([]interface{}{1, timestamp.Add(123456 * time.Microsecond)}, []byte{0x03}, time.FixedZone("+02:00", 2*3600))
+		test.S(t).ExpectEquals(values.StringColumn(1), "2023-10-29 03:30:00.123456")
+	}
+	{
+		values := toRowColumnValues([]interface{}{1, "0000-00-00 00:00:00"}, []byte{0x03}, time.UTC)
+		test.S(t).ExpectEquals(values.StringColumn(1), "0000-00-00 00:00:00")
+	}
+}
+
+func TestHandlePartialUpdateRowsEvent(t *testing.T) {
+	parser := replication.NewBinlogParser()
+	formatDescription := make([]byte, 2+50+4+1+40+5)
+	binary.LittleEndian.PutUint16(formatDescription, 4)
+	copy(formatDescription[2:], "8.0.30")
+	formatDescription[56] = replication.EventHeaderSize
+	_, err := parser.Parse(buildTestEvent(replication.FORMAT_DESCRIPTION_EVENT, 0, formatDescription))
+	test.S(t).ExpectNil(err)
+
+	// table id 7: `test`.`tbl` (id int, doc json)
+	tableMap := []byte{7, 0, 0, 0, 0, 0, 0, 0, 4, 't', 'e', 's', 't', 0, 3, 't', 'b', 'l', 0, 2, gomysql.MYSQL_TYPE_LONG, gomysql.MYSQL_TYPE_JSON, 1, 4, 0}
+	_, err = parser.Parse(buildTestEvent(replication.TABLE_MAP_EVENT, 1000, tableMap))
+	test.S(t).ExpectNil(err)
+
+	jsonValue := func(data []byte) []byte {
+		value := make([]byte, 4)
+		binary.LittleEndian.PutUint32(value, uint32(len(data)))
+		return append(value, data...)
+	}
+	rows := []byte{7, 0, 0, 0, 0, 0, 0, 0, 2, 0, 2, 0xff, 0xff}
+	// before image: (1, '{"a": 1}')
+	rows = append(rows, 0, 1, 0, 0, 0)
+	rows = append(rows, jsonValue([]byte{0x00, 1, 0, 12, 0, 11, 0, 1, 0, 0x05, 1, 0, 'a'})...)
+	// after image: value options, partial JSON bitmap, then (1, <diffs>)
+	rows = append(rows, 1, 0x01, 0, 1, 0, 0, 0)
+	diffs := []byte{byte(replication.JsonDiffOperationReplace), 5}
+	diffs = append(diffs, "$.a.b"...)
+	diffs = append(diffs, 3, 0x05, 2, 0)
+	diffs = append(diffs, byte(replication.JsonDiffOperationRemove), 8)
+	diffs = append(diffs, "$.arr[1]"...)
+	diffs = append(diffs, byte(replication.JsonDiffOperationInsert), 8)
+	diffs = append(diffs, "$.arr[0]"...)
+	diffs = append(diffs, 3, 0x0c, 1, 'x')
+	rows = append(rows, jsonValue(diffs)...)
+
+	migrationContext := base.NewMigrationContext()
+	migrationContext.ReplicaServerId = 99999
+	migrationContext.DatabaseName = "test"
+	migrationContext.OriginalTableName = "tbl"
+	parseRowsEvent := func() *replication.BinlogEvent {
+		rowsEvent, err := parser.Parse(buildTestEvent(replication.PARTIAL_UPDATE_ROWS_EVENT, 1000+replication.EventHeaderSize+uint32(len(rows)), rows))
+		test.S(t).ExpectNil(err)
+		return rowsEvent
+	}
+	entriesChannel := make(chan *BinlogEntry, 10)
+	{
+		reader := NewGoMySQLReader(migrationContext, migrationContext.InspectorConnectionConfig)
+		reader.currentCoordinates = mysql.BinlogCoordinates{LogFile: "mysql-bin.000017", LogPos: 1000}
+		test.S(t).ExpectNotNil(reader.handleEvent(parseRowsEvent(), entriesChannel))
+		test.S(t).ExpectEquals(len(entriesChannel), 0)
+	}
+	migrationContext.AllowPartialJSONUpdates = true
+	reader := NewGoMySQLReader(migrationContext, migrationContext.InspectorConnectionConfig)
+	reader.currentCoordinates = mysql.BinlogCoordinates{LogFile: "mysql-bin.000017", LogPos: 1000}
+	test.S(t).ExpectNil(reader.handleEvent(parseRowsEvent(), entriesChannel))
+	test.S(t).ExpectEquals(len(entriesChannel), 1)
+
+	dmlEvent := (<-entriesChannel).DmlEvent
+	test.S(t).ExpectEquals(dmlEvent.DML, UpdateDML)
+	test.S(t).ExpectEquals(string(dmlEvent.WhereColumnValues.AbstractValues()[1].([]byte)), `{"a":1}`)
+	test.S(t).ExpectTrue(reflect.DeepEqual(dmlEvent.NewColumnValues.AbstractValues()[1], sql.JSONDiffs{
+		{Operation: sql.JSONDiffReplace, Path: "$.a.b", Value: "2"},
+		{Operation: sql.JSONDiffRemove, Path: "$.arr[1]"},
+		{Operation: sql.JSONDiffInsert, Path: "$.arr[0]", Value: `"x"`},
+	}))
+}
+
+func TestStreamEventsStopsWhenBlocked(t *testing.T) {
+	newBlockedReader := func() *GoMySQLReader {
+		migrationContext := base.NewMigrationContext()
+		migrationContext.ReplicaServerId = 99999
+		reader := NewGoMySQLReader(migrationContext, migrationContext.InspectorConnectionConfig)
+		// A streamer which never delivers events, as with an idle binlog
+		reader.binlogStreamer = &replication.BinlogStreamer{}
+		return reader
+	}
+	streamEvents := func(ctx context.Context, reader *GoMySQLReader) chan error {
+		result := make(chan error, 1)
+		go func() {
+			result <- reader.StreamEvents(ctx, make(chan *BinlogEntry))
+		}()
+		return result
+	}
+	expectStopped := func(t *testing.T, result chan error) {
+		select {
+		case err := <-result:
+			test.S(t).ExpectNil(err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("StreamEvents did not stop")
+		}
+	}
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		result := streamEvents(ctx, newBlockedReader())
+		cancel()
+		expectStopped(t, result)
+	})
+	t.Run("deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		expectStopped(t, streamEvents(ctx, newBlockedReader()))
+	})
+	t.Run("closed", func(t *testing.T) {
+		reader := newBlockedReader()
+		result := streamEvents(context.Background(), reader)
+		for {
+			reader.cancelStreamingMutex.Lock()
+			started := reader.cancelStreaming != nil
+			reader.cancelStreamingMutex.Unlock()
+			if started {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		test.S(t).ExpectNil(reader.Close())
+		expectStopped(t, result)
+	})
+}
+
+func TestHandleRowsEventSkipsOtherTables(t *testing.T) {
+	migrationContext := base.NewMigrationContext()
+	migrationContext.ReplicaServerId = 99999
+	migrationContext.DatabaseName = "test"
+	migrationContext.OriginalTableName = "tbl"
+	reader := NewGoMySQLReader(migrationContext, migrationContext.InspectorConnectionConfig)
+	coordinates := mysql.BinlogCoordinates{LogFile: "mysql-bin.000017", LogPos: 1234}
+	entriesChannel := make(chan *BinlogEntry, 10)
+
+	ev := &replication.BinlogEvent{Header: &replication.EventHeader{EventType: replication.WRITE_ROWS_EVENTv2}}
+	rowsEvent := func(tableName string) *replication.RowsEvent {
+		coordinates.LogPos += 100
+		return &replication.RowsEvent{
+			Table:         &replication.TableMapEvent{Schema: []byte("test"), Table: []byte(tableName)},
+			ColumnBitmap1: []byte{0xff},
+			Rows:          [][]interface{}{{1, "a"}, {2, "b"}},
+		}
+	}
+	test.S(t).ExpectNil(reader.handleRowsEvent(ev, rowsEvent("other"), coordinates, entriesChannel))
+	test.S(t).ExpectEquals(len(entriesChannel), 0)
+	test.S(t).ExpectFalse(reader.transactionHasRows)
+
+	test.S(t).ExpectNil(reader.handleRowsEvent(ev, rowsEvent("TBL"), coordinates, entriesChannel))
+	test.S(t).ExpectEquals(len(entriesChannel), 2)
+	test.S(t).ExpectNil(reader.handleRowsEvent(ev, rowsEvent("_tbl_ghc"), coordinates, entriesChannel))
+	test.S(t).ExpectEquals(len(entriesChannel), 4)
+
+	test.S(t).ExpectEquals(migrationContext.StreamedRowsEvents, int64(2))
+	test.S(t).ExpectEquals(migrationContext.SkippedRowsEvents, int64(1))
+}
+
+func TestHandleRowsEventAttachesRowsQuery(t *testing.T) {
+	migrationContext := base.NewMigrationContext()
+	migrationContext.ReplicaServerId = 99999
+	migrationContext.DatabaseName = "test"
+	migrationContext.OriginalTableName = "tbl"
+	reader := NewGoMySQLReader(migrationContext, migrationContext.InspectorConnectionConfig)
+	coordinates := mysql.BinlogCoordinates{LogFile: "mysql-bin.000017", LogPos: 1234}
+	reader.currentRowsQuery = "delete from tbl where id < 3"
+	entriesChannel := make(chan *BinlogEntry, 10)
+
+	ev := &replication.BinlogEvent{Header: &replication.EventHeader{EventType: replication.DELETE_ROWS_EVENTv2}}
+	rowsEvent := &replication.RowsEvent{
+		Table:         &replication.TableMapEvent{Schema: []byte("test"), Table: []byte("tbl")},
+		ColumnBitmap1: []byte{0xff},
+		Rows:          [][]interface{}{{1, "a"}, {2, "b"}},
+	}
+	test.S(t).ExpectNil(reader.handleRowsEvent(ev, rowsEvent, coordinates, entriesChannel))
+	test.S(t).ExpectEquals(len(entriesChannel), 2)
+	for i := 0; i < 2; i++ {
+		binlogEntry := <-entriesChannel
+		test.S(t).ExpectEquals(binlogEntry.DmlEvent.Query, "delete from tbl where id < 3")
+		test.S(t).ExpectTrue(binlogEntry.DmlEvent.Coordinates.Equals(&coordinates))
+	}
+
+	reader.handleTransactionEnd(coordinates, entriesChannel)
+	test.S(t).ExpectEquals(reader.currentRowsQuery, "")
+}
+
+func TestHandleEventCoordinates(t *testing.T) {
+	migrationContext := base.NewMigrationContext()
+	migrationContext.ReplicaServerId = 99999
+	migrationContext.DatabaseName = "test"
+	migrationContext.OriginalTableName = "tbl"
+	reader := NewGoMySQLReader(migrationContext, migrationContext.InspectorConnectionConfig)
+	reader.currentCoordinates = mysql.BinlogCoordinates{LogFile: "mysql-bin.000017", LogPos: 4}
+	entriesChannel := make(chan *BinlogEntry, 10)
+
+	event := func(eventType replication.EventType, logPos uint32, ev replication.Event) *replication.BinlogEvent {
+		return &replication.BinlogEvent{Header: &replication.EventHeader{EventType: eventType, LogPos: logPos}, Event: ev}
+	}
+	rowsEvent := &replication.RowsEvent{
+		Table:         &replication.TableMapEvent{Schema: []byte("test"), Table: []byte("tbl")},
+		ColumnBitmap1: []byte{0xff},
+		Rows:          [][]interface{}{{1, "a"}},
+	}
+	events := []*replication.BinlogEvent{
+		event(replication.WRITE_ROWS_EVENTv2, 500, rowsEvent),
+		event(replication.XID_EVENT, 531, &replication.XIDEvent{}),
+		event(replication.ROTATE_EVENT, 600, &replication.RotateEvent{NextLogName: []byte("mysql-bin.000018"), Position: 4}),
+		// Artificial format description event, which carries no position
+		event(replication.FORMAT_DESCRIPTION_EVENT, 0, &replication.FormatDescriptionEvent{}),
+		event(replication.WRITE_ROWS_EVENTv2, 300, rowsEvent),
+		event(replication.DELETE_ROWS_EVENTv2, 400, rowsEvent),
+		event(replication.XID_EVENT, 431, &replication.XIDEvent{}),
+	}
+	currentCoordinates := []string{"mysql-bin.000017:500", "mysql-bin.000017:531", "mysql-bin.000018:4", "mysql-bin.000018:4", "mysql-bin.000018:300", "mysql-bin.000018:400", "mysql-bin.000018:431"}
+	for i, ev := range events {
+		test.S(t).ExpectNil(reader.handleEvent(ev, entriesChannel))
+		test.S(t).ExpectEquals(reader.GetCurrentBinlogCoordinates().DisplayString(), currentCoordinates[i])
+	}
+
+	entryCoordinates := []string{"mysql-bin.000017:500", "mysql-bin.000017:531", "mysql-bin.000018:300", "mysql-bin.000018:400", "mysql-bin.000018:431"}
+	test.S(t).ExpectEquals(len(entriesChannel), len(entryCoordinates))
+	var previousCoordinates *mysql.BinlogCoordinates
+	for _, expectedCoordinates := range entryCoordinates {
+		binlogEntry := <-entriesChannel
+		test.S(t).ExpectEquals(binlogEntry.Coordinates.DisplayString(), expectedCoordinates)
+		if previousCoordinates != nil {
+			test.S(t).ExpectTrue(previousCoordinates.SmallerThan(&binlogEntry.Coordinates))
+		}
+		previousCoordinates = &binlogEntry.Coordinates
+	}
+
+	// An event failing processing does not become current
+	migrationContext.WarnOnConcurrentDDL = false
+	queryEvent := &replication.QueryEvent{Schema: []byte("test"), Query: []byte("alter table tbl add column i int")}
+	err := reader.handleEvent(event(replication.QUERY_EVENT, 600, queryEvent), entriesChannel)
+	test.S(t).ExpectNotNil(err)
+	test.S(t).ExpectEquals(err.(*ConcurrentDDLError).Coordinates.DisplayString(), "mysql-bin.000018:600")
+	test.S(t).ExpectEquals(reader.GetCurrentBinlogCoordinates().DisplayString(), "mysql-bin.000018:431")
+}
+
+// buildTestTransactionPayload returns the data of a transaction payload event wrapping given raw events,
+// zstd compressed
+func buildTestTransactionPayload(rawEvents ...[]byte) []byte {
+	var uncompressed []byte
+	for _, rawEvent := range rawEvents {
+		uncompressed = append(uncompressed, rawEvent...)
+	}
+	encoder, _ := zstd.NewWriter(nil)
+	defer encoder.Close()
+	compressed := encoder.EncodeAll(uncompressed, nil)
+
+	field := func(fieldType byte, value int) []byte {
+		encodedValue := gomysql.PutLengthEncodedInt(uint64(value))
+		return append([]byte{fieldType, byte(len(encodedValue))}, encodedValue...)
+	}
+	data := field(transactionPayloadSizeField, len(compressed))
+	data = append(data, field(transactionPayloadCompressionTypeField, transactionPayloadCompressionZstd)...)
+	data = append(data, field(transactionPayloadUncompressedSizeField, len(uncompressed))...)
+	data = append(data, transactionPayloadHeaderEndMark)
+	return append(data, compressed...)
+}
+
+func TestHandleTransactionPayloadEvent(t *testing.T) {
+	formatDescription := make([]byte, 2+50+4+1+40+5)
+	binary.LittleEndian.PutUint16(formatDescription, 4)
+	copy(formatDescription[2:], "8.0.30")
+	formatDescription[56] = replication.EventHeaderSize
+	formatDescription[len(formatDescription)-5] = replication.BINLOG_CHECKSUM_ALG_CRC32
+
+	// A compressed transaction on `test`.`tbl` (id int, val int), GTID 3e11fa47-71ca-11e1-9e33-c80aa9429562:6:
+	// two rows events of a single statement, three rows overall. Events within the payload have no checksums.
+	sid := []byte{0x3e, 0x11, 0xfa, 0x47, 0x71, 0xca, 0x11, 0xe1, 0x9e, 0x33, 0xc8, 0x0a, 0xa9, 0x42, 0x95, 0x62}
+	gtid := append(append([]byte{1}, sid...), 6, 0, 0, 0, 0, 0, 0, 0)
+	tableMap := []byte{7, 0, 0, 0, 0, 0, 0, 0, 4, 't', 'e', 's', 't', 0, 3, 't', 'b', 'l', 0, 2, gomysql.MYSQL_TYPE_LONG, gomysql.MYSQL_TYPE_LONG, 0, 0}
+	rows := []byte{7, 0, 0, 0, 0, 0, 0, 0, 2, 0, 2, 0xff, 0, 1, 0, 0, 0, 10, 0, 0, 0, 0, 2, 0, 0, 0, 20, 0, 0, 0}
+	statementEndRows := []byte{7, 0, 0, 0, 0, 0, 1, 0, 2, 0, 2, 0xff, 0, 3, 0, 0, 0, 30, 0, 0, 0}
+	payload := buildTestTransactionPayload(
+		buildTestEvent(replication.QUERY_EVENT, 0, []byte{1, 0, 0, 0, 0, 0, 0, 0, 0, 4, 0, 0, 0, 0, 't', 'e', 's', 't', 0, 'B', 'E', 'G', 'I', 'N'}),
+		buildTestEvent(replication.TABLE_MAP_EVENT, 0, tableMap),
+		buildTestEvent(replication.WRITE_ROWS_EVENTv2, 0, rows),
+		buildTestEvent(replication.WRITE_ROWS_EVENTv2, 0, statementEndRows),
+		buildTestEvent(replication.XID_EVENT, 0, []byte{9, 0, 0, 0, 0, 0, 0, 0}),
+	)
+	rawEvents := [][]byte{
+		buildTestEvent(replication.FORMAT_DESCRIPTION_EVENT, 0, formatDescription),
+		buildTestChecksumEvent(replication.GTID_EVENT, 500, gtid),
+		buildTestChecksumEvent(transactionPayloadEventType, 700, payload),
+	}
+
+	replay := func(reader *GoMySQLReader) []*BinlogEntry {
+		parser := replication.NewBinlogParser()
+		parser.SetVerifyChecksum(true)
+		entriesChannel := make(chan *BinlogEntry, 10)
+		for _, rawEvent := range rawEvents {
+			ev, err := parser.Parse(rawEvent)
+			test.S(t).ExpectNil(err)
+			test.S(t).ExpectNil(reader.handleEvent(ev, entriesChannel))
+		}
+		close(entriesChannel)
+		var binlogEntries []*BinlogEntry
+		for binlogEntry := range entriesChannel {
+			binlogEntries = append(binlogEntries, binlogEntry)
+		}
+		return binlogEntries
+	}
+	newReader := func() *GoMySQLReader {
+		migrationContext := base.NewMigrationContext()
+		migrationContext.ReplicaServerId = 99999
+		migrationContext.DatabaseName = "test"
+		migrationContext.OriginalTableName = "tbl"
+		reader := NewGoMySQLReader(migrationContext, migrationContext.InspectorConnectionConfig)
+		reader.currentCoordinates = mysql.BinlogCoordinates{LogFile: "mysql-bin.000017", LogPos: 4, ExecutedGtidSet: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"}
+		return reader
+	}
+
+	t.Run("streamed", func(t *testing.T) {
+		reader := newReader()
+		binlogEntries := replay(reader)
+		test.S(t).ExpectEquals(len(binlogEntries), 4)
+		for i, binlogEntry := range binlogEntries[:3] {
+			test.S(t).ExpectEquals(binlogEntry.Coordinates.DisplayString(), "mysql-bin.000017:700")
+			test.S(t).ExpectEquals(binlogEntry.TransactionSequence, int64(0))
+			test.S(t).ExpectEquals(binlogEntry.DmlEvent.DatabaseName, "test")
+			test.S(t).ExpectEquals(binlogEntry.DmlEvent.TableName, "tbl")
+			test.S(t).ExpectEquals(binlogEntry.DmlEvent.DML, InsertDML)
+			test.S(t).ExpectTrue(reflect.DeepEqual(binlogEntry.DmlEvent.NewColumnValues.AbstractValues(), []interface{}{int32(i + 1), int32(10 * (i + 1))}))
+		}
+		transactionEnd := binlogEntries[3]
+		test.S(t).ExpectTrue(transactionEnd.IsTransactionEnd)
+		test.S(t).ExpectEquals(transactionEnd.Coordinates.DisplayString(), "mysql-bin.000017:700")
+		test.S(t).ExpectEquals(transactionEnd.Coordinates.ExecutedGtidSet, "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-6")
+
+		test.S(t).ExpectEquals(reader.GetCurrentBinlogCoordinates().ExecutedGtidSet, "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-6")
+		test.S(t).ExpectEquals(reader.LastAppliedRowsEventHint.DisplayString(), "mysql-bin.000017:700")
+		test.S(t).ExpectEquals(reader.migrationContext.StreamedRowsEvents, int64(2))
+	})
+	t.Run("handled", func(t *testing.T) {
+		// Reconnecting ahead of the payload, once its rows were handed over
+		reader := newReader()
+		reader.SetLastAppliedRowsEventHint(mysql.BinlogCoordinates{LogFile: "mysql-bin.000017", LogPos: 700})
+		binlogEntries := replay(reader)
+		test.S(t).ExpectEquals(len(binlogEntries), 0)
+		test.S(t).ExpectEquals(reader.GetCurrentBinlogCoordinates().DisplayString(), "mysql-bin.000017:700")
+	})
+	t.Run("corrupted", func(t *testing.T) {
+		reader := newReader()
+		corruptedPayload := append([]byte{}, payload...)
+		corruptedPayload[len(corruptedPayload)-4] ^= 0xff
+		rawEvents := [][]byte{rawEvents[0], rawEvents[1], buildTestChecksumEvent(transactionPayloadEventType, 700, corruptedPayload)}
+		parser := replication.NewBinlogParser()
+		entriesChannel := make(chan *BinlogEntry, 10)
+		var err error
+		for _, rawEvent := range rawEvents {
+			ev, parseErr := parser.Parse(rawEvent)
+			test.S(t).ExpectNil(parseErr)
+			err = reader.handleEvent(ev, entriesChannel)
+		}
+		test.S(t).ExpectNotNil(err)
+		test.S(t).ExpectTrue(strings.Contains(err.Error(), "Cannot decode"))
+		test.S(t).ExpectTrue(strings.Contains(err.Error(), "mysql-bin.000017:"))
+		test.S(t).ExpectEquals(len(entriesChannel), 0)
+		test.S(t).ExpectEquals(reader.GetCurrentBinlogCoordinates().DisplayString(), "mysql-bin.000017:500")
+	})
+}
+
+func BenchmarkHandleRowsEvent(b *testing.B) {
+	migrationContext := base.NewMigrationContext()
+	migrationContext.ReplicaServerId = 99999
+	migrationContext.DatabaseName = "test"
+	migrationContext.OriginalTableName = "tbl"
+	reader := NewGoMySQLReader(migrationContext, migrationContext.InspectorConnectionConfig)
+	coordinates := mysql.BinlogCoordinates{LogFile: "mysql-bin.000017", LogPos: 4}
+
+	entriesChannel := make(chan *BinlogEntry, 100)
+	go func() {
+		// Play the part of both the streamer and the applier
+		for binlogEntry := range entriesChannel {
+			binlogEntry.DmlEvent.Release()
+			binlogEntry.Release()
+		}
+	}()
+	defer close(entriesChannel)
+
+	rows := make([][]interface{}, 0, 100)
+	for i := 0; i < cap(rows); i++ {
+		rows = append(rows, []interface{}{i, "some name", 3.14, []byte("some blob"), nil, int64(i * 1000)})
+	}
+	ev := &replication.BinlogEvent{Header: &replication.EventHeader{EventType: replication.WRITE_ROWS_EVENTv2}}
+	for _, tableName := range []string{"other", "tbl"} {
+		rowsEvent := &replication.RowsEvent{
+			Table:         &replication.TableMapEvent{Schema: []byte("test"), Table: []byte(tableName)},
+			ColumnBitmap1: []byte{0xff},
+			Rows:          rows,
+		}
+		b.Run(tableName, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				coordinates.LogPos++
+				reader.handleRowsEvent(ev, rowsEvent, coordinates, entriesChannel)
+			}
+		})
+	}
+}
+
+func TestBinlogEntryLifecycle(t *testing.T) {
+	coordinates := mysql.BinlogCoordinates{LogFile: "mysql-bin.000017", LogPos: 1234}
+	binlogEntry := acquireBinlogEntryAt(coordinates)
+	binlogEntry.DmlEvent = acquireBinlogDMLEvent("test", "tbl", UpdateDML)
+	whereColumnValues := toRowColumnValues([]interface{}{1, "a"}, []byte{0x01}, time.UTC)
+	newColumnValues := toRowColumnValues([]interface{}{nil, "b"}, []byte{0x02}, time.UTC)
+	binlogEntry.DmlEvent.WhereColumnValues = whereColumnValues
+	binlogEntry.DmlEvent.NewColumnValues = newColumnValues
+	test.S(t).ExpectEquals(binlogEntry.Coordinates.LogPos, int64(1234))
+
+	// Releasing the entry leaves the DML event to its owner
+	dmlEvent := binlogEntry.DmlEvent
+	binlogEntry.Release()
+	test.S(t).ExpectTrue(binlogEntry.DmlEvent == nil)
+	test.S(t).ExpectEquals(binlogEntry.Coordinates.LogPos, int64(0))
+	test.S(t).ExpectEquals(dmlEvent.TableName, "tbl")
+	test.S(t).ExpectEquals(dmlEvent.NewColumnValues.AbstractValues()[1], "b")
+
+	dmlEvent.Release()
+	test.S(t).ExpectEquals(dmlEvent.TableName, "")
+	test.S(t).ExpectTrue(dmlEvent.WhereColumnValues == nil)
+	test.S(t).ExpectTrue(dmlEvent.NewColumnValues == nil)
+	test.S(t).ExpectEquals(len(whereColumnValues.AbstractValues()), 0)
+	test.S(t).ExpectFalse(newColumnValues.IsPartial())
+}
+
+func TestIsReplicaServerIdConflict(t *testing.T) {
+	test.S(t).ExpectTrue(isReplicaServerIdConflict(fmt.Errorf("ERROR 1236 (HY000): A slave with the same server_uuid/server_id as this slave has connected to the master; the first event 'mysql-bin.000017' at 4")))
+	test.S(t).ExpectTrue(isReplicaServerIdConflict(fmt.Errorf("ERROR 1236 (HY000): A replica with the same server_uuid/server_id as this replica has connected to the source")))
+	test.S(t).ExpectFalse(isReplicaServerIdConflict(fmt.Errorf("connection was bad")))
+}
+
+func TestFatalBinlogReadError(t *testing.T) {
+	purgedFileErr := gomysql.NewError(gomysql.ER_MASTER_FATAL_ERROR_READING_BINLOG, "Could not find first log file name in binary log index file")
+	purgedGtidErr := gomysql.NewError(gomysql.ER_MASTER_FATAL_ERROR_READING_BINLOG, "Cannot replicate because the master purged required binary logs.")
+	positionErr := gomysql.NewError(gomysql.ER_MASTER_FATAL_ERROR_READING_BINLOG, "Client requested master to start replication from position > file size")
+
+	test.S(t).ExpectTrue(isFatalBinlogReadError(purgedFileErr))
+	test.S(t).ExpectTrue(isFatalBinlogReadError(errors.Trace(purgedGtidErr)))
+	test.S(t).ExpectFalse(isFatalBinlogReadError(gomysql.NewError(gomysql.ER_ACCESS_DENIED_ERROR, "Access denied")))
+	test.S(t).ExpectFalse(isFatalBinlogReadError(fmt.Errorf("Could not find first log file name in binary log index file")))
+
+	test.S(t).ExpectTrue((&FatalBinlogReadError{Err: purgedFileErr}).IsPurged())
+	test.S(t).ExpectTrue((&FatalBinlogReadError{Err: purgedGtidErr}).IsPurged())
+	test.S(t).ExpectFalse((&FatalBinlogReadError{Err: positionErr}).IsPurged())
+}
+
+// buildTestEvent returns the raw bytes of a binlog event: header followed by given body
+func buildTestEvent(eventType replication.EventType, logPos uint32, body []byte) []byte {
+	data := make([]byte, replication.EventHeaderSize, replication.EventHeaderSize+len(body))
+	data[4] = byte(eventType)
+	binary.LittleEndian.PutUint32(data[5:], 1)
+	binary.LittleEndian.PutUint32(data[9:], uint32(replication.EventHeaderSize+len(body)))
+	binary.LittleEndian.PutUint32(data[13:], logPos)
+	return append(data, body...)
+}
+
+func TestUndecodableEventDiagnostics(t *testing.T) {
+	parser := replication.NewBinlogParser()
+
+	formatDescription := make([]byte, 2+50+4+1+40+5)
+	binary.LittleEndian.PutUint16(formatDescription, 4)
+	copy(formatDescription[2:], "8.0.30")
+	formatDescription[56] = replication.EventHeaderSize
+	_, err := parser.Parse(buildTestEvent(replication.FORMAT_DESCRIPTION_EVENT, 0, formatDescription))
+	test.S(t).ExpectNil(err)
+
+	// table id 7: `test`.`tbl` (id int, val int)
+	tableMap := []byte{7, 0, 0, 0, 0, 0, 0, 0, 4, 't', 'e', 's', 't', 0, 3, 't', 'b', 'l', 0, 2, gomysql.MYSQL_TYPE_LONG, gomysql.MYSQL_TYPE_LONG, 0, 0}
+	tableMapEvent, err := parser.Parse(buildTestEvent(replication.TABLE_MAP_EVENT, 1000, tableMap))
+	test.S(t).ExpectNil(err)
+
+	// The row image of the second column is truncated
+	rows := []byte{7, 0, 0, 0, 0, 0, 0, 0, 2, 0, 2, 0xff, 0, 1, 0, 0, 0, 2, 0}
+	_, err = parser.Parse(buildTestEvent(replication.WRITE_ROWS_EVENTv2, 1000+replication.EventHeaderSize+uint32(len(rows)), rows))
+	test.S(t).ExpectNotNil(err)
+	eventError, ok := errors.Cause(err).(*replication.EventError)
+	test.S(t).ExpectTrue(ok)
+
+	dir, err := ioutil.TempDir("", "gh-ost-diagnostics")
+	test.S(t).ExpectNil(err)
+	defer os.RemoveAll(dir)
+
+	migrationContext := base.NewMigrationContext()
+	migrationContext.ReplicaServerId = 99999
+	migrationContext.PanicDiagnosticsDir = dir
+	reader := NewGoMySQLReader(migrationContext, migrationContext.InspectorConnectionConfig)
+	reader.currentCoordinates = mysql.BinlogCoordinates{LogFile: "mysql-bin.000017", LogPos: 1000}
+	reader.tableMapEvents[7] = tableMapEvent.Event.(*replication.TableMapEvent)
+
+	undecodableEventError := reader.newUndecodableEventError(eventError.Header, eventError.Data, nil, errors.New(eventError.Err))
+	test.S(t).ExpectEquals(undecodableEventError.Coordinates.DisplayString(), "mysql-bin.000017:1000")
+	test.S(t).ExpectEquals(undecodableEventError.NextCoordinates().String(), fmt.Sprintf("mysql-bin.000017:%d", 1000+replication.EventHeaderSize+len(rows)))
+	test.S(t).ExpectTrue(undecodableEventError.TableMap != nil)
+	test.S(t).ExpectTrue(strings.Contains(undecodableEventError.Error(), "WriteRowsEventV2 event at mysql-bin.000017:1000"))
+	test.S(t).ExpectTrue(strings.Contains(undecodableEventError.Error(), "`test`.`tbl` (table id 7"))
+
+	test.S(t).ExpectEquals(undecodableEventError.DiagnosticsFile, filepath.Join(dir, "gh-ost-undecodable-event-mysql-bin.000017-1000.txt"))
+	diagnostics, err := ioutil.ReadFile(undecodableEventError.DiagnosticsFile)
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectTrue(strings.Contains(string(diagnostics), hex.Dump(eventError.Data)))
+}
+
+func TestDecodeTimestampAcrossDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone data not available: %+v", err)
+	}
+	// TIMESTAMP values are decoded as of the machine's local time zone
+	local := time.Local
+	time.Local = berlin
+	defer func() { time.Local = local }()
+
+	parser := replication.NewBinlogParser()
+	formatDescription := make([]byte, 2+50+4+1+40+5)
+	binary.LittleEndian.PutUint16(formatDescription, 4)
+	copy(formatDescription[2:], "8.0.30")
+	formatDescription[56] = replication.EventHeaderSize
+	_, err = parser.Parse(buildTestEvent(replication.FORMAT_DESCRIPTION_EVENT, 0, formatDescription))
+	test.S(t).ExpectNil(err)
+
+	// table id 7: `test`.`tbl` (id int, ts timestamp)
+	tableMap := []byte{7, 0, 0, 0, 0, 0, 0, 0, 4, 't', 'e', 's', 't', 0, 3, 't', 'b', 'l', 0, 2, gomysql.MYSQL_TYPE_LONG, gomysql.MYSQL_TYPE_TIMESTAMP2, 1, 0, 0}
+	_, err = parser.Parse(buildTestEvent(replication.TABLE_MAP_EVENT, 1000, tableMap))
+	test.S(t).ExpectNil(err)
+
+	// Europe/Berlin turns clocks back at 2021-10-31 01:00:00 UTC: both rows are at 02:30:00 local time
+	rows := []byte{7, 0, 0, 0, 0, 0, 0, 0, 2, 0, 2, 0xff}
+	for id, ts := range []uint32{1635640200, 1635643800} {
+		row := make([]byte, 9)
+		binary.LittleEndian.PutUint32(row[1:], uint32(id+1))
+		binary.BigEndian.PutUint32(row[5:], ts)
+		rows = append(rows, row...)
+	}
+	rowsEvent, err := parser.Parse(buildTestEvent(replication.WRITE_ROWS_EVENTv2, 1000+replication.EventHeaderSize+uint32(len(rows)), rows))
+	test.S(t).ExpectNil(err)
+
+	migrationContext := base.NewMigrationContext()
+	migrationContext.ReplicaServerId = 99999
+	migrationContext.DatabaseName = "test"
+	migrationContext.OriginalTableName = "tbl"
+	reader := NewGoMySQLReader(migrationContext, migrationContext.InspectorConnectionConfig)
+	reader.currentCoordinates = mysql.BinlogCoordinates{LogFile: "mysql-bin.000017", LogPos: 1000}
+	entriesChannel := make(chan *BinlogEntry, 10)
+	test.S(t).ExpectNil(reader.handleEvent(rowsEvent, entriesChannel))
+	test.S(t).ExpectEquals(len(entriesChannel), 2)
+
+	// Values are instants, formatted in the time zone of sessions (UTC), regardless of the local time zone
+	for _, expected := range []string{"2021-10-31 00:30:00", "2021-10-31 01:30:00"} {
+		value := (<-entriesChannel).DmlEvent.NewColumnValues.AbstractValues()[1]
+		test.S(t).ExpectEquals(value, expected)
+	}
+
+	// Per --connection-time-zone
+	migrationContext.ConnectionTimeZone = "+03:00"
+	rowsEvent, err = parser.Parse(buildTestEvent(replication.WRITE_ROWS_EVENTv2, 1000+replication.EventHeaderSize+uint32(len(rows)), rows))
+	test.S(t).ExpectNil(err)
+	reader = NewGoMySQLReader(migrationContext, migrationContext.InspectorConnectionConfig)
+	reader.currentCoordinates = mysql.BinlogCoordinates{LogFile: "mysql-bin.000017", LogPos: 1000}
+	test.S(t).ExpectNil(reader.handleEvent(rowsEvent, entriesChannel))
+	for _, expected := range []string{"2021-10-31 03:30:00", "2021-10-31 04:30:00"} {
+		value := (<-entriesChannel).DmlEvent.NewColumnValues.AbstractValues()[1]
+		test.S(t).ExpectEquals(value, expected)
+	}
+}
+
+func TestDetectSchemaDrift(t *testing.T) {
+	migrationContext := base.NewMigrationContext()
+	migrationContext.ReplicaServerId = 99999
+	migrationContext.DatabaseName = "test"
+	migrationContext.OriginalTableName = "tbl"
+	migrationContext.OriginalTableColumns = sql.NewColumnList([]string{"id", "name", "age"})
+	reader := NewGoMySQLReader(migrationContext, migrationContext.InspectorConnectionConfig)
+
+	tableMapEvent := func(tableName string, columnCount uint64) *replication.TableMapEvent {
+		return &replication.TableMapEvent{TableID: 17, Schema: []byte("test"), Table: []byte(tableName), ColumnCount: columnCount}
+	}
+	test.S(t).ExpectNil(reader.detectSchemaDrift(tableMapEvent("tbl", 3), mysql.BinlogCoordinates{}))
+	test.S(t).ExpectNil(reader.detectSchemaDrift(tableMapEvent("_tbl_ghc", 5), mysql.BinlogCoordinates{}))
+
+	err := reader.detectSchemaDrift(tableMapEvent("tbl", 4), mysql.BinlogCoordinates{})
+	test.S(t).ExpectNotNil(err)
+	driftError, ok := err.(*SchemaDriftError)
+	test.S(t).ExpectTrue(ok)
+	test.S(t).ExpectEquals(driftError.Mismatch, "binlog rows event has 4 columns, while inspected table has 3 columns")
+
+	migrationContext.WarnOnSchemaDrift = true
+	test.S(t).ExpectNil(reader.detectSchemaDrift(tableMapEvent("tbl", 4), mysql.BinlogCoordinates{}))
+	test.S(t).ExpectEquals(reader.schemaDriftWarnedTableID, uint64(17))
+}
+
+func TestDescribeSchemaDrift(t *testing.T) {
+	columns := sql.NewColumnList([]string{"id", "name", "created_at"})
+	columns.GetColumn("id").MySQLType = "int(10) unsigned"
+	columns.GetColumn("name").MySQLType = "varchar(64)"
+	columns.GetColumn("created_at").MySQLType = "datetime(3)"
+	columnTypes := []byte{gomysql.MYSQL_TYPE_LONG, gomysql.MYSQL_TYPE_VARCHAR, gomysql.MYSQL_TYPE_DATETIME2}
+	tableMapEvent := func(columnTypes []byte, columnNames ...string) *replication.TableMapEvent {
+		tableMapEvent := &replication.TableMapEvent{Schema: []byte("test"), Table: []byte("tbl"), ColumnCount: uint64(len(columnTypes)), ColumnType: columnTypes}
+		for _, columnName := range columnNames {
+			tableMapEvent.ColumnName = append(tableMapEvent.ColumnName, []byte(columnName))
+		}
+		return tableMapEvent
+	}
+
+	test.S(t).ExpectEquals(describeSchemaDrift(tableMapEvent(columnTypes), columns), "")
+	test.S(t).ExpectEquals(describeSchemaDrift(tableMapEvent(columnTypes, "id", "Name", "created_at"), columns), "")
+	test.S(t).ExpectEquals(describeSchemaDrift(tableMapEvent(columnTypes[:2]), columns), "binlog rows event has 2 columns, while inspected table has 3 columns")
+
+	// A column swapped for another of the same type
+	test.S(t).ExpectEquals(describeSchemaDrift(tableMapEvent(columnTypes, "id", "nickname", "created_at"), columns), "binlog column #2 is `nickname`, while inspected table's column #2 is `name`")
+
+	// A column modified, or columns reordered
+	modifiedTypes := []byte{gomysql.MYSQL_TYPE_LONGLONG, gomysql.MYSQL_TYPE_VARCHAR, gomysql.MYSQL_TYPE_DATETIME2}
+	test.S(t).ExpectEquals(describeSchemaDrift(tableMapEvent(modifiedTypes), columns), "binlog column #1 has binlog type 8, which is not that of inspected table's column #1 `id` int(10) unsigned")
+	reorderedTypes := []byte{gomysql.MYSQL_TYPE_LONG, gomysql.MYSQL_TYPE_DATETIME2, gomysql.MYSQL_TYPE_VARCHAR}
+	test.S(t).ExpectEquals(describeSchemaDrift(tableMapEvent(reorderedTypes), columns), "binlog column #2 has binlog type 18, which is not that of inspected table's column #2 `name` varchar(64)")
+
+	// Pre MySQL 5.6 temporal types, and types not known, are accepted
+	test.S(t).ExpectEquals(describeSchemaDrift(tableMapEvent([]byte{gomysql.MYSQL_TYPE_LONG, gomysql.MYSQL_TYPE_VARCHAR, gomysql.MYSQL_TYPE_DATETIME}), columns), "")
+	columns.GetColumn("created_at").MySQLType = "vector(3)"
+	test.S(t).ExpectEquals(describeSchemaDrift(tableMapEvent([]byte{gomysql.MYSQL_TYPE_LONG, gomysql.MYSQL_TYPE_VARCHAR, gomysql.MYSQL_TYPE_BLOB}), columns), "")
+}
+
+func TestDecodeTableMapColumnNames(t *testing.T) {
+	parser := replication.NewBinlogParser()
+	formatDescription := make([]byte, 2+50+4+1+40+5)
+	binary.LittleEndian.PutUint16(formatDescription, 4)
+	copy(formatDescription[2:], "8.0.30")
+	formatDescription[56] = replication.EventHeaderSize
+	_, err := parser.Parse(buildTestEvent(replication.FORMAT_DESCRIPTION_EVENT, 0, formatDescription))
+	test.S(t).ExpectNil(err)
+
+	// Table `test`.`tbl` (id int, name varchar(64)), with binlog_row_metadata=FULL: signedness, then column names
+	tableMap := []byte{17, 0, 0, 0, 0, 0, 1, 0, 4, 't', 'e', 's', 't', 0, 3, 't', 'b', 'l', 0, 2, gomysql.MYSQL_TYPE_LONG, gomysql.MYSQL_TYPE_VARCHAR, 2, 64, 0, 0x03}
+	tableMap = append(tableMap, 1, 1, 0x80)
+	tableMap = append(tableMap, 4, 8, 2, 'i', 'd', 4, 'n', 'a', 'm', 'e')
+	ev, err := parser.Parse(buildTestEvent(replication.TABLE_MAP_EVENT, 1000, tableMap))
+	test.S(t).ExpectNil(err)
+	tableMapEvent := ev.Event.(*replication.TableMapEvent)
+	test.S(t).ExpectEquals(len(tableMapEvent.ColumnName), 2)
+	test.S(t).ExpectEquals(string(tableMapEvent.ColumnName[0]), "id")
+	test.S(t).ExpectEquals(string(tableMapEvent.ColumnName[1]), "name")
+
+	// Without optional metadata, or with malformed optional metadata
+	ev, err = parser.Parse(buildTestEvent(replication.TABLE_MAP_EVENT, 1000, tableMap[:26]))
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectEquals(len(ev.Event.(*replication.TableMapEvent).ColumnName), 0)
+	ev, err = parser.Parse(buildTestEvent(replication.TABLE_MAP_EVENT, 1000, append(tableMap[:29:29], 4, 9, 2, 'i', 'd')))
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectEquals(len(ev.Event.(*replication.TableMapEvent).ColumnName), 0)
+}
@@ -0,0 +1,135 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package binlog
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/klauspost/compress/zstd"
+	gomysql "github.com/siddontang/go-mysql/mysql"
+	"github.com/siddontang/go-mysql/replication"
+)
+
+// transactionPayloadEventType is MySQL 8.0's TRANSACTION_PAYLOAD_EVENT, which wraps the events of a
+// transaction compressed per binlog_transaction_compression. go-mysql reads it as a generic event.
+const transactionPayloadEventType replication.EventType = 40
+
+// Fields of a transaction payload event's header, which the payload follows
+const (
+	transactionPayloadHeaderEndMark         = 0
+	transactionPayloadSizeField             = 1
+	transactionPayloadCompressionTypeField  = 2
+	transactionPayloadUncompressedSizeField = 3
+)
+
+// Compression types of a transaction payload
+const (
+	transactionPayloadCompressionZstd = 0
+	transactionPayloadCompressionNone = 255
+)
+
+// zstdDecoder decompresses transaction payloads. It is safe for concurrent use.
+var zstdDecoder, _ = zstd.NewReader(nil)
+
+// transactionPayload is the decoded data of a transaction payload event
+type transactionPayload struct {
+	compressionType  uint64
+	uncompressedSize uint64
+	payload          []byte
+}
+
+// decodeTransactionPayload decodes the data of a transaction payload event: header fields, each a length
+// encoded type, length and value, up to the end mark, followed by the payload
+func decodeTransactionPayload(data []byte) (*transactionPayload, error) {
+	transactionPayload := &transactionPayload{compressionType: transactionPayloadCompressionNone}
+	for pos := 0; pos < len(data); {
+		fieldType, _, n := gomysql.LengthEncodedInt(data[pos:])
+		pos += n
+		if fieldType == transactionPayloadHeaderEndMark {
+			transactionPayload.payload = data[pos:]
+			return transactionPayload, nil
+		}
+		if pos >= len(data) {
+			break
+		}
+		fieldLength, _, n := gomysql.LengthEncodedInt(data[pos:])
+		pos += n
+		if pos+int(fieldLength) > len(data) {
+			break
+		}
+		fieldValue, _, _ := gomysql.LengthEncodedInt(data[pos : pos+int(fieldLength)])
+		pos += int(fieldLength)
+		switch fieldType {
+		case transactionPayloadCompressionTypeField:
+			transactionPayload.compressionType = fieldValue
+		case transactionPayloadUncompressedSizeField:
+			transactionPayload.uncompressedSize = fieldValue
+		}
+	}
+	return nil, fmt.Errorf("Truncated transaction payload header")
+}
+
+// uncompress returns the transaction's events, in raw form: headers are followed by bodies with no checksum
+func (this *transactionPayload) uncompress() ([]byte, error) {
+	switch this.compressionType {
+	case transactionPayloadCompressionNone:
+		return this.payload, nil
+	case transactionPayloadCompressionZstd:
+		events, err := zstdDecoder.DecodeAll(this.payload, make([]byte, 0, this.uncompressedSize))
+		if err != nil {
+			return nil, fmt.Errorf("Cannot decompress transaction payload: %+v", err)
+		}
+		if uint64(len(events)) != this.uncompressedSize {
+			return nil, fmt.Errorf("Transaction payload decompressed to %d bytes, expected %d bytes", len(events), this.uncompressedSize)
+		}
+		return events, nil
+	}
+	return nil, fmt.Errorf("Unknown transaction payload compression type %d", this.compressionType)
+}
+
+// parseTransactionPayload returns the events of a compressed transaction. Given the raw format description event
+// of the binary log, the events are parsed as the binlog syncer parses streamed events, but for checksums, which
+// they have none of.
+func parseTransactionPayload(formatDescription []byte, data []byte) ([]*replication.BinlogEvent, error) {
+	if len(formatDescription) < replication.EventHeaderSize+replication.BinlogChecksumLength+1 {
+		return nil, fmt.Errorf("No format description event read ahead of transaction payload")
+	}
+	transactionPayload, err := decodeTransactionPayload(data)
+	if err != nil {
+		return nil, err
+	}
+	rawEvents, err := transactionPayload.uncompress()
+	if err != nil {
+		return nil, err
+	}
+
+	parser := replication.NewBinlogParser()
+	parser.SetUseDecimal(true)
+	// The checksum algorithm precedes the format description event's own checksum
+	uncheckedFormatDescription := append([]byte{}, formatDescription...)
+	uncheckedFormatDescription[len(uncheckedFormatDescription)-replication.BinlogChecksumLength-1] = replication.BINLOG_CHECKSUM_ALG_OFF
+	if _, err := parser.Parse(uncheckedFormatDescription); err != nil {
+		return nil, err
+	}
+	var events []*replication.BinlogEvent
+	for pos := 0; pos < len(rawEvents); {
+		if pos+replication.EventHeaderSize > len(rawEvents) {
+			return nil, fmt.Errorf("Truncated event header in transaction payload at offset %d", pos)
+		}
+		eventSize := int(binary.LittleEndian.Uint32(rawEvents[pos+9:]))
+		if eventSize < replication.EventHeaderSize || pos+eventSize > len(rawEvents) {
+			return nil, fmt.Errorf("Invalid event size %d in transaction payload at offset %d", eventSize, pos)
+		}
+		ev, err := parser.Parse(rawEvents[pos : pos+eventSize])
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, ev)
+		pos += eventSize
+	}
+	return events, nil
+}
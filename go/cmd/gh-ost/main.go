@@ -8,13 +8,21 @@ package main
 import (
 	"flag"
 	"fmt"
+	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/github/gh-ost/go/base"
 	"github.com/github/gh-ost/go/logic"
+	"github.com/github/gh-ost/go/metrics"
+	"github.com/github/gh-ost/go/mysql"
 	"github.com/github/gh-ost/go/sql"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/outbrain/golib/log"
@@ -24,6 +32,96 @@ import (
 
 var AppVersion string
 
+var sqlModeRegexp = regexp.MustCompile(`^[A-Za-z_]+(,[A-Za-z_]+)*$`)
+
+// sampleRatioFlag is a flag given either bare, sampling everything, or with a sample ratio in (0, 1]
+type sampleRatioFlag struct {
+	ratio *float64
+}
+
+func (this *sampleRatioFlag) String() string {
+	if this.ratio == nil {
+		return "0"
+	}
+	return strconv.FormatFloat(*this.ratio, 'f', -1, 64)
+}
+
+func (this *sampleRatioFlag) Set(value string) error {
+	switch value {
+	case "true":
+		*this.ratio = 1
+		return nil
+	case "false":
+		*this.ratio = 0
+		return nil
+	}
+	ratio, err := strconv.ParseFloat(value, 64)
+	if err != nil || ratio <= 0 || ratio > 1 {
+		return fmt.Errorf("expected a sample ratio in (0, 1], got %s", value)
+	}
+	*this.ratio = ratio
+	return nil
+}
+
+func (this *sampleRatioFlag) IsBoolFlag() bool {
+	return true
+}
+
+// columnRenamesFlag is a repeatable flag, each given a column rename as old_name:new_name
+type columnRenamesFlag struct {
+	migrationContext *base.MigrationContext
+}
+
+func (this *columnRenamesFlag) String() string {
+	if this.migrationContext == nil {
+		return ""
+	}
+	renames := []string{}
+	for oldName, newName := range this.migrationContext.ExplicitColumnRenameMap {
+		renames = append(renames, fmt.Sprintf("%s:%s", oldName, newName))
+	}
+	sort.Strings(renames)
+	return strings.Join(renames, ",")
+}
+
+func (this *columnRenamesFlag) Set(value string) error {
+	return this.migrationContext.AddExplicitColumnRename(value)
+}
+
+// throttleHTTPHeadersFlag is a repeatable flag, each given an HTTP header as Name: value
+type throttleHTTPHeadersFlag struct {
+	migrationContext *base.MigrationContext
+}
+
+func (this *throttleHTTPHeadersFlag) String() string {
+	if this.migrationContext == nil {
+		return ""
+	}
+	// Header values may well be secrets
+	names := []string{}
+	for name := range this.migrationContext.ThrottleHTTPHeaders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func (this *throttleHTTPHeadersFlag) Set(value string) error {
+	return this.migrationContext.AddThrottleHTTPHeader(value)
+}
+
+// throttleScheduleFlag is a repeatable flag, each given a --throttle-schedule window
+type throttleScheduleFlag []string
+
+func (this *throttleScheduleFlag) String() string {
+	return strings.Join(*this, "; ")
+}
+
+func (this *throttleScheduleFlag) Set(value string) error {
+	*this = append(*this, value)
+	return nil
+}
+
 // acceptSignals registers for OS signals
 func acceptSignals(migrationContext *base.MigrationContext) {
 	c := make(chan os.Signal, 1)
@@ -65,8 +163,10 @@ func main() {
 	flag.BoolVar(&migrationContext.TLSAllowInsecure, "ssl-allow-insecure", false, "Skips verification of MySQL hosts' certificate chain and host name. Requires --ssl")
 
 	flag.StringVar(&migrationContext.DatabaseName, "database", "", "database name (mandatory)")
+	flag.StringVar(&migrationContext.TargetDatabaseName, "target-database", "", "Place the ghost table, and thus the migrated table, in this database rather than in --database. The original table is renamed away within --database at cut-over")
 	flag.StringVar(&migrationContext.OriginalTableName, "table", "", "table name (mandatory)")
-	flag.StringVar(&migrationContext.AlterStatement, "alter", "", "alter statement (mandatory)")
+	flag.StringVar(&migrationContext.NewTableName, "new-table-name", "", "Rename the migrated table to this name at cut-over. The original table is renamed away as usual. With this flag --alter is optional, for a rebuild and rename of the table")
+	flag.StringVar(&migrationContext.AlterStatement, "alter", "", "alter statement (mandatory, unless --new-table-name is given)")
 	flag.BoolVar(&migrationContext.AttemptInstantDDL, "attempt-instant-ddl", false, "Attempt to use instant DDL for this migration first")
 
 	flag.BoolVar(&migrationContext.CountTableRows, "exact-rowcount", false, "actually count table rows as opposed to estimate them (results in more accurate progress estimation)")
@@ -74,15 +174,40 @@ func main() {
 	flag.BoolVar(&migrationContext.AllowedRunningOnMaster, "allow-on-master", false, "allow this migration to run directly on master. Preferably it would run on a replica")
 	flag.BoolVar(&migrationContext.AllowedMasterMaster, "allow-master-master", false, "explicitly allow running in a master-master setup")
 	flag.BoolVar(&migrationContext.NullableUniqueKeyAllowed, "allow-nullable-unique-key", false, "allow gh-ost to migrate based on a unique key with nullable columns. As long as no NULL values exist, this should be OK. If NULL values exist in chosen key, data may be corrupted. Use at your own risk!")
+	flag.BoolVar(&migrationContext.SurrogateKey, "surrogate-key", false, "Migrate a table lacking a shared unique key: add an AUTO_INCREMENT column onto the ghost table, and match rows by all their columns when copying rows and applying binlog events. Requires binlog_row_image=FULL. Meant for small tables, see docs")
+	flag.StringVar(&migrationContext.SurrogateKeyColumnName, "surrogate-key-column", "gh_ost_rowid", "With --surrogate-key, name of the AUTO_INCREMENT column added onto the migrated table")
+	flag.BoolVar(&migrationContext.SurrogateKeyInvisible, "surrogate-key-invisible", false, "With --surrogate-key, add the column as INVISIBLE (MySQL 8.0.23 and above)")
+	flag.BoolVar(&migrationContext.ImSureAboutDuplicates, "im-sure-about-duplicates", false, "With --surrogate-key, proceed even though the table has duplicate identical rows. Changes to identical rows may not apply faithfully. Use at your own risk!")
+	flag.BoolVar(&migrationContext.ApproveCollationRisks, "approve-collation-risks", false, "in case your `ALTER` statement changes the charset, collation or length of character columns, gh-ost analyzes the table before row copy begins for values that would collide in a unique key, or be truncated, on the migrated table. By default gh-ost does not proceed when it finds such values. This flag approves proceeding nonetheless")
 	flag.BoolVar(&migrationContext.ApproveRenamedColumns, "approve-renamed-columns", false, "in case your `ALTER` statement renames columns, gh-ost will note that and offer its interpretation of the rename. By default gh-ost does not proceed to execute. This flag approves that gh-ost's interpretation is correct")
+	flag.Var(&columnRenamesFlag{migrationContext: migrationContext}, "rename-column", "Column rename as old_name:new_name, mapping an original table column onto a ghost table column. May be given multiple times. Overrides and supplements the renames gh-ost infers from the ALTER statement, and needs no --approve-renamed-columns")
 	flag.BoolVar(&migrationContext.SkipRenamedColumns, "skip-renamed-columns", false, "in case your `ALTER` statement renames columns, gh-ost will note that and offer its interpretation of the rename. By default gh-ost does not proceed to execute. This flag tells gh-ost to skip the renamed columns, i.e. to treat what gh-ost thinks are renamed columns as unrelated columns. NOTE: you may lose column data")
 	flag.BoolVar(&migrationContext.IsTungsten, "tungsten", false, "explicitly let gh-ost know that you are running on a tungsten-replication based topology (you are likely to also provide --assume-master-host)")
 	flag.BoolVar(&migrationContext.DiscardForeignKeys, "discard-foreign-keys", false, "DANGER! This flag will migrate a table that has foreign keys and will NOT create foreign keys on the ghost table, thus your altered table will have NO foreign keys. This is useful for intentional dropping of foreign keys")
+	flag.BoolVar(&migrationContext.AllowDroppedCheckConstraints, "allow-dropped-check-constraints", false, "Allow the ALTER to drop or un-enforce CHECK constraints of the migrated table. Without it, the migration bails out listing the CHECK constraints whose enforcement the ALTER drops")
+	flag.BoolVar(&migrationContext.AllowPartialJSONUpdates, "allow-partial-json-updates", false, "Allow binlog_row_value_options=PARTIAL_JSON. Partial JSON updates are applied onto the ghost table via JSON_SET, JSON_ARRAY_INSERT and JSON_REMOVE. Without it, gh-ost refuses to run when PARTIAL_JSON is set globally, and fails streaming upon a partial JSON update on the migrated table")
+	flag.BoolVar(&migrationContext.PreserveForeignKeys, "preserve-foreign-keys", false, "Migrate a table that has child-side foreign keys, creating them on the ghost table just before cut-over, and dropping them off the old table following cut-over. Foreign keys must not CASCADE nor SET NULL/DEFAULT")
+	flag.BoolVar(&migrationContext.IncludeTriggers, "include-triggers", false, "Migrate a table that has triggers, creating them on the ghost table within the cut-over lock. Triggers are created under temporary names, and renamed to their original names following cut-over")
+	flag.BoolVar(&migrationContext.AllowSelfReferencingTriggers, "allow-self-referencing-triggers", false, "With --include-triggers, allow triggers whose body references the migrated table itself")
 	flag.BoolVar(&migrationContext.SkipForeignKeyChecks, "skip-foreign-key-checks", false, "set to 'true' when you know for certain there are no foreign keys on your table, and wish to skip the time it takes for gh-ost to verify that")
+	flag.BoolVar(&migrationContext.SkipAutoIncrementSync, "skip-auto-increment-sync", false, "Do not sync the ghost table's AUTO_INCREMENT up to the original table's within the cut-over lock. The ghost table still gets the original table's AUTO_INCREMENT upon creation")
 	flag.BoolVar(&migrationContext.SkipStrictMode, "skip-strict-mode", false, "explicitly tell gh-ost binlog applier not to enforce strict sql mode")
+	flag.StringVar(&migrationContext.ConnectionTimeZone, "connection-time-zone", mysql.DefaultTimeZone, "time_zone of all gh-ost sessions, which TIMESTAMP values are read and written in, both in row copy and binlog event apply. An offset (e.g. '+00:00') or a named time zone of a fixed offset (e.g. 'UTC'); named time zones observing DST are rejected")
+	flag.StringVar(&migrationContext.SQLMode, "sql-mode", "", "sql_mode for applier connections, row copy and binlog event apply alike (e.g. 'NO_ENGINE_SUBSTITUTION'). Replaces the server's sql_mode, and is not made strict by gh-ost. Default: the server's sql_mode, with STRICT_ALL_TABLES")
+	flag.Func("session-variable", "Session variable to set on applier and inspector connections, as name=value (e.g. innodb_lock_wait_timeout=10). May be repeated. The cut-over's lock_wait_timeout is --cut-over-lock-timeout-seconds regardless", func(token string) error {
+		variable, err := mysql.ParseSessionVariable(token)
+		if err != nil {
+			return err
+		}
+		migrationContext.SessionVariables = append(migrationContext.SessionVariables, *variable)
+		return nil
+	})
+	flag.BoolVar(&migrationContext.AllowZeroInDate, "allow-zero-dates", false, "Remove NO_ZERO_DATE and NO_ZERO_IN_DATE from the sql_mode of applier connections, allowing zero dates and zero in dates to be copied")
+	zeroDateRewrite := flag.String("zero-date-rewrite", "", "Rewrite zero dates (and dates with a zero month or day) of DATE, DATETIME and TIMESTAMP columns into NULL or into a given value (e.g. '1970-01-01'), both in row copy and binlog event apply")
 	flag.BoolVar(&migrationContext.AliyunRDS, "aliyun-rds", false, "set to 'true' when you execute on Aliyun RDS.")
 	flag.BoolVar(&migrationContext.GoogleCloudPlatform, "gcp", false, "set to 'true' when you execute on a 1st generation Google Cloud Platform (GCP).")
 	flag.BoolVar(&migrationContext.AzureMySQL, "azure", false, "set to 'true' when you execute on Azure Database on MySQL.")
+	flag.BoolVar(&migrationContext.AuroraLagThrottle, "aurora-lag-throttle", false, "On Amazon Aurora, throttle on the lag of the cluster's readers, as reported by the writer, beyond --max-lag-millis")
 
 	executeFlag := flag.Bool("execute", false, "actually execute the alter & migrate the table. Default is noop: do some tests and exit")
 	flag.BoolVar(&migrationContext.TestOnReplica, "test-on-replica", false, "Have the migration run on a replica, not on the master. At the end of migration replication is stopped, and tables are swapped and immediately swap-revert. Replication remains stopped and you can compare the two tables for building trust")
@@ -90,53 +215,156 @@ func main() {
 	flag.BoolVar(&migrationContext.MigrateOnReplica, "migrate-on-replica", false, "Have the migration run on a replica, not on the master. This will do the full migration on the replica including cut-over (as opposed to --test-on-replica)")
 
 	flag.BoolVar(&migrationContext.OkToDropTable, "ok-to-drop-table", false, "Shall the tool drop the old table at end of operation. DROPping tables can be a long locking operation, which is why I'm not doing it by default. I'm an online tool, yes?")
+	flag.DurationVar(&migrationContext.DropOldTableAfter, "drop-old-table-after", 0, "Drop the old table this long after a successful cut-over (e.g. 4h), rather than right away. gh-ost waits and then drops it, or, with --drop-old-table-detach, leaves it to a later --cleanup run. The scheduled drop is recorded on the changelog table, kept until then")
+	flag.BoolVar(&migrationContext.DropOldTableDetach, "drop-old-table-detach", false, "With --drop-old-table-after, record the scheduled drop and exit, rather than wait for it. A later 'gh-ost --cleanup' run of the same --database and --table drops the old table once due")
+	flag.StringVar(&migrationContext.DropOldTableScratchDatabase, "drop-old-table-scratch-database", "", "With --drop-old-table-after, first rename the old table into this database, and drop it from there")
+	flag.BoolVar(&migrationContext.Cleanup, "cleanup", false, "Rather than migrate, finish the scheduled drop of the old table of a migration run with --drop-old-table-after: wait until due, drop the old table, then drop the changelog table. Requires --execute to actually drop")
 	flag.BoolVar(&migrationContext.InitiallyDropOldTable, "initially-drop-old-table", false, "Drop a possibly existing OLD table (remains from a previous run?) before beginning operation. Default is to panic and abort if such table exists")
 	flag.BoolVar(&migrationContext.InitiallyDropGhostTable, "initially-drop-ghost-table", false, "Drop a possibly existing Ghost table (remains from a previous run?) before beginning operation. Default is to panic and abort if such table exists")
+	flag.BoolVar(&migrationContext.Resume, "resume", false, "Resume an interrupted migration of the same table and ALTER statement, from the last checkpoint on its changelog table, onto its existing ghost table")
+	flag.Int64Var(&migrationContext.CheckpointIntervalChunks, "checkpoint-interval-chunks", 100, "Write a row copy checkpoint, from which --resume continues, every this many copied chunks. 0 disables checkpoints")
 	flag.BoolVar(&migrationContext.TimestampOldTable, "timestamp-old-table", false, "Use a timestamp in old table name. This makes old table names unique and non conflicting cross migrations")
+	flag.StringVar(&migrationContext.OldTableNamePattern, "old-table-name-pattern", "", "Name of the old table, into which the original table is renamed at cut-over, as a pattern of the tokens {table}, {timestamp} and {date}, e.g. _{table}_del_{timestamp}. Names exceeding 64 characters have the table name truncated and hashed. Default: _{table}_del")
+	flag.StringVar(&migrationContext.GhostTableNamePattern, "ghost-table-name-pattern", "", "Name of the ghost table, as a pattern of the tokens {table}, {timestamp} and {date}, e.g. _{table}_gho_{date}. Default: _{table}_gho")
 	cutOver := flag.String("cut-over", "atomic", "choose cut-over type (default|atomic, two-step)")
+	flag.StringVar(&migrationContext.RowFilter, "row-filter", "", "SQL predicate; only rows matching it are migrated onto the ghost table, such that cut-over swaps in a table of just those rows (e.g. \"created_at >= '2023-01-01'\"). Must be valid on both the original and the ghost table")
+	copyOrder := flag.String("copy-order", "asc", "Order in which row copy iterates the unique key: asc (from min values up) or desc (from max values down, copying recent rows first on ever increasing keys)")
+	copyConflictStrategy := flag.String("copy-conflict-strategy", "ignore", "How row copy handles rows already found on the ghost table: ignore (INSERT IGNORE), replace (REPLACE), or fail (plain INSERT, aborting the migration on any conflict)")
+	flag.BoolVar(&migrationContext.CountRowsExamined, "count-rows-examined", false, "Count each row copy chunk's rows, within its transaction, ahead of copying them; the status then reports rows examined along with rows copied, such that rows ignored by --copy-conflict-strategy=ignore are visible. Adds a locking read per chunk")
+	flag.Var(&sampleRatioFlag{ratio: &migrationContext.ChunkChecksumSampleRatio}, "verify-chunk-checksums", "Checksum copied chunks on both original and ghost tables, failing the migration on mismatch. Optionally given a sample ratio in (0, 1], e.g. --verify-chunk-checksums=0.1 verifies every 10th chunk. Skipped while throttled")
+	flag.BoolVar(&migrationContext.ChunkChecksumWarnOnly, "verify-chunk-checksums-warn-only", false, "With --verify-chunk-checksums, log chunk checksum mismatches as warnings rather than failing the migration")
 	flag.BoolVar(&migrationContext.ForceNamedCutOverCommand, "force-named-cut-over", false, "When true, the 'unpostpone|cut-over' interactive command must name the migrated table")
 	flag.BoolVar(&migrationContext.ForceNamedPanicCommand, "force-named-panic", false, "When true, the 'panic' interactive command must name the migrated table")
 
 	flag.BoolVar(&migrationContext.SwitchToRowBinlogFormat, "switch-to-rbr", false, "let this tool automatically switch binary log format to 'ROW' on the replica, if needed. The format will NOT be switched back. I'm too scared to do that, and wish to protect you if you happen to execute another migration while this one is running")
 	flag.BoolVar(&migrationContext.AssumeRBR, "assume-rbr", false, "set to 'true' when you know for certain your server uses 'ROW' binlog_format. gh-ost is unable to tell, event after reading binlog_format, whether the replication process does indeed use 'ROW', and restarts replication to be certain RBR setting is applied. Such operation requires SUPER privileges which you might not have. Setting this flag avoids restarting replication and you can proceed to use gh-ost without SUPER privileges")
 	flag.BoolVar(&migrationContext.CutOverExponentialBackoff, "cut-over-exponential-backoff", false, "Wait exponentially longer intervals between failed cut-over attempts. Wait intervals obey a maximum configurable with 'exponential-backoff-max-interval').")
+	flag.Int64Var(&migrationContext.CutOverRetryAttempts, "cut-over-retry-attempts", 0, "Number of cut-over attempts before giving up. Default: 0, meaning use --default-retries")
+	flag.DurationVar(&migrationContext.CutOverRetryInterval, "cut-over-retry-interval", time.Second, "Time to wait between failed cut-over attempts. With --cut-over-exponential-backoff, the initial wait, doubling with each failed attempt")
+	flag.Int64Var(&migrationContext.CutOverRehearsals, "cut-over-rehearsals", 0, "Number of cut-over rehearsals once ready to cut-over: lock the tables and wait for events up to the lock, as the atomic cut-over does, then unlock without renaming. Timings are logged. See also the rehearse-cut-over interactive command")
+	flag.DurationVar(&migrationContext.CutOverKillBlockingQueriesAfter, "cut-over-kill-blocking-queries-after", 0, "During cut-over, kill sessions holding metadata locks which block the cut-over lock, once running for this long (e.g. 60s). Replication threads and gh-ost's own sessions are never killed. Requires --cut-over-kill-blocking-queries-for, and the performance_schema metadata locks instrument. Default: never kill")
+	cutOverKillBlockingQueriesFor := flag.String("cut-over-kill-blocking-queries-for", "", "Confirms --cut-over-kill-blocking-queries-after: must name the migrated table")
+	flag.BoolVar(&migrationContext.CutOverKillBlockingQueriesDryRun, "cut-over-kill-blocking-queries-dry-run", false, "With --cut-over-kill-blocking-queries-after, only log the sessions which would be killed")
+	cutOverMaxRowDelta := flag.String("cut-over-max-row-delta", "", "Refuse cut-over should the original and ghost tables' row counts differ by more than this many rows, or this percentage of the original table's rows (e.g. 1000 or 0.1%). A refused cut-over is postponed as with a vetoing gh-ost-on-before-cut-over hook. Default: no check")
+	cutOverRowCountMode := flag.String("cut-over-row-count-mode", "estimate", "With --cut-over-max-row-delta, how rows are counted: estimate (information_schema estimates, ahead of the cut-over lock) or exact (count(*) within the cut-over lock, bounded by --cut-over-lock-timeout-seconds)")
+	flag.BoolVar(&migrationContext.PostponeCutOverOnFailure, "postpone-cut-over-on-failure", false, "Once cut-over attempts are exhausted, postpone cut-over by re-creating --postpone-cut-over-flag-file, rather than abort the migration. Requires --postpone-cut-over-flag-file")
 	exponentialBackoffMaxInterval := flag.Int64("exponential-backoff-max-interval", 64, "Maximum number of seconds to wait between attempts when performing various operations with exponential backoff.")
+	flag.StringVar(&migrationContext.ChunkIndex, "chunk-index", "", "Name of the unique key to iterate row copy by, overriding gh-ost's choice of shared unique key. The key must be unique, and present with the same columns on both original and ghost tables")
 	chunkSize := flag.Int64("chunk-size", 1000, "amount of rows to handle in each iteration (allowed range: 10-100,000)")
+	flag.BoolVar(&migrationContext.AdaptiveChunkSize, "adaptive-chunk-size", false, "Adjust the chunk size after each copied chunk, increasing or decreasing it to hold the copy's latency at --chunk-latency-target. --chunk-size is the initial chunk size")
+	chunkLatencyTarget := flag.Duration("chunk-latency-target", 500*time.Millisecond, "With --adaptive-chunk-size, the target execution time of a row copy chunk")
+	chunkSizeMin := flag.Int64("chunk-size-min", 10, "With --adaptive-chunk-size, the minimal chunk size")
+	chunkSizeMax := flag.Int64("chunk-size-max", 100000, "With --adaptive-chunk-size, the maximal chunk size")
+	maxCopyRowsPerSecond := flag.Int64("max-copy-rows-per-second", 0, "Limit row copy to this many rows per second. 0 disables the limit")
+	maxDMLApplyRowsPerSecond := flag.Int64("max-dml-apply-rows-per-second", 0, "Limit applying binlog DML events onto the ghost table to this many rows per second. 0 disables the limit")
+	throttleRampUpDuration := flag.Duration("throttle-ramp-up-duration", 0, "Once a throttle period of at least --throttle-ramp-up-threshold ends, resume row copy and DML apply gradually, from 5% up to full pace over this duration, e.g. 60s. 0 resumes at full pace right away")
+	throttleRampUpThreshold := flag.Duration("throttle-ramp-up-threshold", 30*time.Second, "Minimal throttle period after which --throttle-ramp-up-duration applies")
 	dmlBatchSize := flag.Int64("dml-batch-size", 10, "batch size for DML events to apply in a single transaction (range 1-100)")
+	flag.Int64Var(&migrationContext.DMLBatchMaxBytes, "dml-batch-max-bytes", 0, "Approximate maximum bytes of row images to apply in a single transaction; a transaction is closed once either --dml-batch-size or this is reached. 0 disables the limit")
+	flag.BoolVar(&migrationContext.CompactUpdates, "compact-updates", false, "Apply full-image UPDATE events onto the ghost table by setting only the columns whose values changed, rather than all columns. Saves bytes written on tables with large JSON/TEXT columns")
+	flag.Int64Var(&migrationContext.DMLPreparedStatementsCacheSize, "dml-prepared-statements-cache-size", 100, "Number of prepared statements to cache for applying DML events onto the ghost table. 0 disables prepared statements")
+	flag.Int64Var(&migrationContext.DMLApplyConcurrency, "dml-apply-concurrency", 1, "Number of workers concurrently applying DML events onto the ghost table, sharded by unique key values (range 1-64). 1 applies events serially")
+	flag.Int64Var(&migrationContext.ApplierMaxOpenConns, "applier-max-open-conns", 0, "Maximum number of open applier connections. 0 keeps the default, which is 3, plus --dml-apply-concurrency when greater than 1")
+	flag.Int64Var(&migrationContext.ApplierMaxIdleConns, "applier-max-idle-conns", 0, "Maximum number of idle applier connections. 0 keeps the default, which is the default maximum number of open connections")
+	flag.DurationVar(&migrationContext.ApplierConnMaxLifetime, "applier-conn-max-lifetime", 0, "Maximum time an applier connection may be reused, e.g. '1h'. Keep below the server's wait_timeout. 0 reuses connections indefinitely")
+	flag.Int64Var(&migrationContext.InspectorMaxOpenConns, "inspector-max-open-conns", 0, "Maximum number of open inspector connections. 0 keeps the default of 3")
+	flag.Int64Var(&migrationContext.InspectorMaxIdleConns, "inspector-max-idle-conns", 0, "Maximum number of idle inspector connections. 0 keeps the default of 3")
+	flag.DurationVar(&migrationContext.InspectorConnMaxLifetime, "inspector-conn-max-lifetime", 0, "Maximum time an inspector connection may be reused, e.g. '1h'. 0 reuses connections indefinitely")
+	flag.Int64Var(&migrationContext.MaxPacketBudget, "max-packet-budget", 0, "Bytes of row values a row copy chunk or a coalesced DML statement may carry. Must not exceed the applier's max_allowed_packet. 0 means half of max_allowed_packet")
+	flag.Int64Var(&migrationContext.DMLRetryAttempts, "dml-retry-attempts", 5, "Number of times to retry applying a batch of DML events upon deadlock or lock wait timeout, backing off exponentially, before failing")
 	defaultRetries := flag.Int64("default-retries", 60, "Default number of retries for various operations before panicking")
+	flag.Int64Var(&migrationContext.StreamerReconnectRetries, "streamer-reconnect-retries", 0, "Number of successive binlog streamer reconnect attempts before panicking. Default: 0, meaning use --default-retries")
+	flag.Int64Var(&migrationContext.StreamerReconnectIntervalSeconds, "streamer-reconnect-interval", 5, "Number of seconds to wait before reconnecting the binlog streamer upon error")
+	startBinlogCoordinates := flag.String("start-binlog-coordinates", "", "Binlog coordinates (file:pos) at which to begin streaming, instead of the current master status. Example: mysql-bin.000123:4")
+	flag.StringVar(&migrationContext.StartGtidSet, "start-gtid-set", "", "Executed GTID set at which to begin streaming, instead of the current master status. Requires gtid_mode=ON")
+	flag.StringVar(&migrationContext.PanicDiagnosticsDir, "panic-diagnostics-dir", "", "Directory onto which gh-ost writes diagnostics, such as a hexdump of a binlog event it fails to decode")
+	flag.BoolVar(&migrationContext.SkipUndecodableBinlogEvents, "skip-undecodable-binlog-events", false, "When true, a binlog event which cannot be decoded is logged, with diagnostics, and skipped. The migrated table may then miss changes. Default: the migration aborts")
+	flag.StringVar(&migrationContext.BinlogFile, "binlog-file", "", "(debug) Replay this binary log file (e.g. as saved by 'mysqlbinlog --read-from-remote-server --raw') instead of streaming binary logs from the server. The migration cannot cut-over")
+	flag.BoolVar(&migrationContext.AllowBinlogGapRecovery, "allow-binlog-gap-recovery", false, "With GTID, when the binary logs being streamed are purged, resume streaming at the earliest available binary log, skipping the purged events. The migrated table may then miss changes and must be verified. Default: the migration aborts")
+	streamerFailoverHosts := flag.String("streamer-failover-hosts", "", "Ordered list of hosts to fail binlog streaming over to, once streamer reconnect retries are exhausted; comma delimited. Example: myhost1.com:3306,myhost2.com")
+	flag.Int64Var(&migrationContext.BinlogSyncerHeartbeatPeriodSeconds, "binlogsyncer-heartbeat-period", 30, "Number of seconds between heartbeats the server sends on an idle binlog stream. 0 to disable")
+	flag.Int64Var(&migrationContext.BinlogSyncerReadTimeoutSeconds, "binlogsyncer-read-timeout", 90, "Number of seconds without any binlog event or heartbeat after which the binlog streamer reconnects. 0 to disable")
+	flag.Int64Var(&migrationContext.BinlogEventsBufferSize, "binlog-events-buffer-size", 1, "Number of binlog entries buffered between the binlog reader and the applier. A larger buffer absorbs bursts of binlog events at the expense of memory")
+	flag.Int64Var(&migrationContext.BinlogEventsBlockedWarningSeconds, "binlog-events-blocked-warning", 10, "Number of seconds the binlog reader may be blocked on a full binlog events buffer before a warning is logged; the warning repeats at this interval. 0 to disable")
+	flag.Int64Var(&migrationContext.MaxDMLBacklog, "max-dml-backlog", 0, "Pause row copy, while binlog events keep applying, when this many binlog events are queued and not yet applied. 0 to disable")
+	flag.DurationVar(&migrationContext.MaxEventsLag, "max-events-lag", 0, "Pause row copy, while binlog events keep applying, when the oldest binlog event not yet applied was written this long ago, e.g. 10s. 0 to disable")
+	flag.Int64Var(&migrationContext.BinlogEventsBufferThrottleDepth, "throttle-binlog-events-buffer-depth", 0, "When positive, throttle row copy while the binlog events buffer holds at least this many entries, letting the applier catch up. Should not exceed --binlog-events-buffer-size. 0 to disable")
+	flag.Int64Var(&migrationContext.BinlogStallTimeoutSeconds, "binlog-stall-timeout", 0, "Number of seconds without any binlog event read (heartbeat events included) after which the binlog stream is considered stalled, and is reconnected. 0 to disable")
+	flag.BoolVar(&migrationContext.AbortOnBinlogStall, "abort-on-binlog-stall", false, "When true, a stalled binlog stream (see --binlog-stall-timeout) aborts the migration. Default: the stream is reconnected")
+	flag.StringVar(&migrationContext.AuditDMLFile, "audit-dml-file", "", "File onto which a line per applied binlog DML event is appended: binlog coordinates, DML type and originating query (requires binlog_rows_query_log_events)")
+	flag.BoolVar(&migrationContext.WarnOnSchemaDrift, "warn-on-schema-drift", false, "When true, rows events whose columns (count, types, and names given binlog_row_metadata=FULL) mismatch the inspected migrated table are logged as a warning and the migration proceeds. Default: the migration aborts upon such schema drift")
+	flag.BoolVar(&migrationContext.WarnOnConcurrentDDL, "warn-on-concurrent-ddl", false, "When true, DDL on the migrated table found in the binary logs is logged as a warning and the migration proceeds. Default: the migration aborts upon such DDL")
+	flag.BoolVar(&migrationContext.WarnOnBinlogChecksumMismatch, "warn-on-binlog-checksum-mismatch", false, "When true, a binlog event checksum mismatch is logged as a warning and streaming proceeds. Default: the streamer fails upon checksum mismatch")
 	cutOverLockTimeoutSeconds := flag.Int64("cut-over-lock-timeout-seconds", 3, "Max number of seconds to hold locks on tables while attempting to cut-over (retry attempted when lock exceeds timeout)")
+	cutOverLockWaitTimeout := flag.Duration("cut-over-lock-wait-timeout", 0, "lock_wait_timeout of the atomic cut-over's session locking the original table, in whole seconds (e.g. 10s). Default: 0, meaning twice --cut-over-lock-timeout-seconds")
+	cutOverRenameTimeout := flag.Duration("cut-over-rename-timeout", 0, "lock_wait_timeout of the atomic cut-over's session renaming the tables, in whole seconds (e.g. 1s). May not exceed the lock wait timeout. Default: 0, meaning --cut-over-lock-timeout-seconds")
 	niceRatio := flag.Float64("nice-ratio", 0, "force being 'nice', imply sleep time per chunk time; range: [0.0..100.0]. Example values: 0 is aggressive. 1: for every 1ms spent copying rows, sleep additional 1ms (effectively doubling runtime); 0.7: for every 10ms spend in a rowcopy chunk, spend 7ms sleeping immediately after")
 
 	maxLagMillis := flag.Int64("max-lag-millis", 1500, "replication lag at which to throttle operation")
+	flag.Int64Var(&migrationContext.MaxHistoryListLength, "max-history-list-length", 0, "Throttle while the InnoDB history list length on the applier host exceeds this value. 0 to disable")
+	minFreeDiskSpace := flag.String("min-free-disk-space", "", "Throttle while free disk space on the applier's data directory is below this value, in bytes with an optional unit, or percent, e.g. 100GB or 10%. Read via --disk-space-query")
+	criticalFreeDiskSpace := flag.String("critical-free-disk-space", "", "Abort the migration once free disk space on the applier's data directory is below this value, e.g. 20GB or 2%. Read via --disk-space-query")
+	flag.StringVar(&migrationContext.DiskSpaceQuery, "disk-space-query", "", "Query returning the free bytes of the applier's data directory disk, and optionally its total bytes as a second column. Required by --min-free-disk-space and --critical-free-disk-space")
 	replicationLagQuery := flag.String("replication-lag-query", "", "Deprecated. gh-ost uses an internal, subsecond resolution query")
-	throttleControlReplicas := flag.String("throttle-control-replicas", "", "List of replicas on which to check for lag; comma delimited. Example: myhost1.com:3306,myhost2.com,myhost3.com:3307")
+	throttleControlReplicas := flag.String("throttle-control-replicas", "", "List of replicas on which to check for lag; comma delimited. A replica may override --max-lag-millis as host:port;maxlag=<duration>. Example: myhost1.com:3306,myhost2.com,myhost3.com:3307;maxlag=30s")
+	flag.BoolVar(&migrationContext.DiscoverThrottleReplicas, "discover-throttle-replicas", false, "Periodically discover the replicas of the applier host, one level deep, via SHOW REPLICAS, and add them onto the throttle control replicas. Replicas must set report_host")
+	flag.DurationVar(&migrationContext.DiscoverThrottleReplicasInterval, "discover-throttle-replicas-interval", time.Minute, "Interval between --discover-throttle-replicas discovery rounds")
+	flag.Int64Var(&migrationContext.DiscoverThrottleReplicasDropAfter, "discover-throttle-replicas-drop-after", 3, "Drop a discovered replica from the throttle control replicas once missing for this many consecutive discovery rounds")
+	discoverThrottleReplicasExclude := flag.String("discover-throttle-replicas-exclude", "", "Replicas --discover-throttle-replicas never adds; comma delimited hostnames, matching any port, or host:port entries")
+	flag.BoolVar(&migrationContext.SkipLagCheckIfDelayed, "skip-lag-check-if-delayed", false, "Skip lag checks of --throttle-control-replicas which lag intentionally: delayed replicas (SQL_Delay > 0) and replicas whose replication is stopped without error. Replicas whose replication is broken still throttle")
+	flag.BoolVar(&migrationContext.CheckLoadOnReplicas, "check-load-on-replicas", false, "Also evaluate --max-load and --critical-load thresholds on each of --throttle-control-replicas")
+	replicaLagSource := flag.String("replica-lag-source", "heartbeat", "How to measure the lag of --throttle-control-replicas: heartbeat, replica_status, performance_schema or pt-heartbeat:schema.table. Comma delimited; a host:port=source entry applies to a single replica. Example: replica_status,myhost3.com:3307=pt-heartbeat:percona.heartbeat")
 	throttleQuery := flag.String("throttle-query", "", "when given, issued (every second) to check if operation should throttle. Expecting to return zero for no-throttle, >0 for throttle. Query is issued on the migrated server. Make sure this query is lightweight")
 	throttleHTTP := flag.String("throttle-http", "", "when given, gh-ost checks given URL via HEAD request; any response code other than 200 (OK) causes throttling; make sure it has low latency response")
 	flag.Int64Var(&migrationContext.ThrottleHTTPIntervalMillis, "throttle-http-interval-millis", 100, "Number of milliseconds to wait before triggering another HTTP throttle check")
+	flag.DurationVar(&migrationContext.ThrottleHTTPCheckInterval, "throttle-http-check-interval", 0, "Interval between HTTP throttle checks, e.g. 500ms. Default: 0, meaning --throttle-http-interval-millis")
+	flag.DurationVar(&migrationContext.ThrottleLagCheckInterval, "throttle-lag-check-interval", 0, "Interval between --throttle-control-replicas lag checks, e.g. 250ms. Default: 0, meaning every second, or every 100ms when --max-lag-millis is below 1000")
+	flag.DurationVar(&migrationContext.ThrottleLoadCheckInterval, "throttle-load-check-interval", time.Second, "Interval between --max-load, --critical-load, --max-history-list-length and --throttle-query checks")
+	flag.DurationVar(&migrationContext.ThrottleFlagFileCheckInterval, "throttle-flag-file-check-interval", time.Second, "Interval between checks for --throttle-flag-file, --throttle-additional-flag-file and --panic-flag-file")
 	flag.Int64Var(&migrationContext.ThrottleHTTPTimeoutMillis, "throttle-http-timeout-millis", 1000, "Number of milliseconds to use as an HTTP throttle check timeout")
-	ignoreHTTPErrors := flag.Bool("ignore-http-errors", false, "ignore HTTP connection errors during throttle check")
+	ignoreHTTPErrors := flag.Bool("ignore-http-errors", false, "ignore HTTP connection errors during throttle check. Same as --throttle-http-unreachable-policy=ignore")
+	throttleHTTPMethod := flag.String("throttle-http-method", "HEAD", "HTTP method of --throttle-http requests: HEAD, GET or POST. POST requests carry the migration's identity and progress as JSON, see --throttle-http-body")
+	throttleHTTPBody := flag.String("throttle-http-body", "", "Go text/template of --throttle-http POST request bodies, rendering the migration's identity and progress, e.g. '{\"table\": {{json .Table}}, \"progress\": {{.ProgressPct}}}'. Default: a JSON document of all fields")
+	flag.Var(&throttleHTTPHeadersFlag{migrationContext: migrationContext}, "throttle-http-header", "HTTP header of --throttle-http requests, as 'Name: value', e.g. 'Authorization: Bearer ...'. May be given multiple times")
+	throttleHTTPUnreachablePolicy := flag.String("throttle-http-unreachable-policy", "throttle", "What to do when --throttle-http cannot be reached, e.g. on connection errors or timeouts: throttle, ignore or panic. Responses other than 200 always throttle")
+	flag.BoolVar(&migrationContext.ThrottleHTTPReasonFromBody, "throttle-http-reason-from-body", false, "Show a reason read off --throttle-http response bodies in the throttle status: the 'reason' field of a JSON object, or the first line of text. Requires GET or POST")
 	heartbeatIntervalMillis := flag.Int64("heartbeat-interval-millis", 100, "how frequently would gh-ost inject a heartbeat value")
 	flag.StringVar(&migrationContext.ThrottleFlagFile, "throttle-flag-file", "", "operation pauses when this file exists; hint: use a file that is specific to the table being altered")
-	flag.StringVar(&migrationContext.ThrottleAdditionalFlagFile, "throttle-additional-flag-file", "/tmp/gh-ost.throttle", "operation pauses when this file exists; hint: keep default, use for throttling multiple gh-ost operations")
+	flag.StringVar(&migrationContext.ThrottleAdditionalFlagFile, "throttle-additional-flag-file", "/tmp/gh-ost.throttle", "operation pauses when this file exists; hint: keep default, use for throttling multiple gh-ost operations. Comma delimited list of files; operation pauses when any exists")
+	flag.StringVar(&migrationContext.ThrottleFlagFileDir, "throttle-flag-file-dir", "", "operation pauses when any file exists in this directory, reporting the file as the throttle reason; hidden files and subdirectories are ignored")
 	flag.StringVar(&migrationContext.PostponeCutOverFlagFile, "postpone-cut-over-flag-file", "", "while this file exists, migration will postpone the final stage of swapping tables, and will keep on syncing the ghost table. Cut-over/swapping would be ready to perform the moment the file is deleted.")
+	autoUnpostponeWhen := flag.String("auto-unpostpone-when", "", "Proceed to cut-over despite --postpone-cut-over-flag-file once these health criteria are continuously met for --auto-unpostpone-stable-duration. Comma delimited conditions on lag (heartbeat lag), backlog (DML events), copy (percent) and throttled, e.g. 'lag<1s,backlog<1000,copy=100%,throttled=false'")
+	flag.DurationVar(&migrationContext.AutoUnpostponeStableDuration, "auto-unpostpone-stable-duration", 5*time.Minute, "Time for which --auto-unpostpone-when criteria must be continuously met")
+	cutOverWindow := flag.String("cut-over-window", "", "Daily wall clock window within which to cut-over, as HH:MM-HH:MM (e.g. 02:00-04:00). Once ready, migration postpones cut-over until the window opens, waiting for the next day's window if missed. Combines with --postpone-cut-over-flag-file: both must clear")
+	cutOverWindowTimeZone := flag.String("cut-over-window-time-zone", "Local", "Time zone of --cut-over-window, e.g. UTC or Europe/Berlin. Defaults to the local time zone")
+	throttleSchedule := throttleScheduleFlag{}
+	flag.Var(&throttleSchedule, "throttle-schedule", "Weekly wall clock window within which to throttle row copy, as [label=][days ]HH:MM-HH:MM, e.g. 'business hours=Mon-Fri 08:00-20:00'. May be given multiple times")
+	throttleScheduleTimeZone := flag.String("throttle-schedule-time-zone", "Local", "Time zone of --throttle-schedule, e.g. UTC or Europe/Berlin. Defaults to the local time zone")
+	flag.BoolVar(&migrationContext.ThrottleScheduleApplyEvents, "throttle-schedule-apply-events", true, "Keep applying binlog events while throttled by --throttle-schedule alone. When false, a scheduled throttle pauses the migration as any other throttle does")
 	flag.StringVar(&migrationContext.PanicFlagFile, "panic-flag-file", "", "when this file is created, gh-ost will immediately terminate, without cleanup")
 
 	flag.BoolVar(&migrationContext.DropServeSocket, "initially-drop-socket-file", false, "Should gh-ost forcibly delete an existing socket file. Be careful: this might drop the socket file of a running migration!")
 	flag.StringVar(&migrationContext.ServeSocketFile, "serve-socket-file", "", "Unix socket file to serve on. Default: auto-determined and advertised upon startup")
 	flag.Int64Var(&migrationContext.ServeTCPPort, "serve-tcp-port", 0, "TCP port to serve on. Default: disabled")
+	flag.StringVar(&migrationContext.PrometheusListenAddr, "prometheus-listen-addr", "", "Address to serve Prometheus metrics on, at /metrics, e.g. ':9191'. Default: disabled")
 
 	flag.StringVar(&migrationContext.HooksPath, "hooks-path", "", "directory where hook files are found (default: empty, ie. hooks disabled). Hook files found on this path, and conforming to hook naming conventions will be executed")
 	flag.StringVar(&migrationContext.HooksHintMessage, "hooks-hint", "", "arbitrary message to be injected to hooks via GH_OST_HOOKS_HINT, for your convenience")
 	flag.StringVar(&migrationContext.HooksHintOwner, "hooks-hint-owner", "", "arbitrary name of owner to be injected to hooks via GH_OST_HOOKS_HINT_OWNER, for your convenience")
 	flag.StringVar(&migrationContext.HooksHintToken, "hooks-hint-token", "", "arbitrary token to be injected to hooks via GH_OST_HOOKS_HINT_TOKEN, for your convenience")
 	flag.Int64Var(&migrationContext.HooksStatusIntervalSec, "hooks-status-interval", 60, "how many seconds to wait between calling onStatus hook")
+	flag.DurationVar(&migrationContext.HooksBeforeCutOverTimeout, "hooks-before-cut-over-timeout", time.Minute, "time to wait for the gh-ost-on-before-cut-over hook, which runs ahead of each cut-over attempt. A hook failing or timing out vetoes the attempt, and cut-over is postponed")
 
 	flag.UintVar(&migrationContext.ReplicaServerId, "replica-server-id", 99999, "server id used by gh-ost process. Default: 99999")
+	replicaServerIdRange := flag.String("replica-server-id-range", "100000-999999", "Range (min-max) within which gh-ost picks a random server id, should --replica-server-id already be in use by another replication client")
+	flag.StringVar(&migrationContext.MySQLFlavor, "mysql-flavor", "", "(optional) explicitly tell gh-ost the flavor of the inspected server (mysql|mariadb). Default: auto-detected. This is useful when connecting via a proxy that masks the server version")
 
 	maxLoad := flag.String("max-load", "", "Comma delimited status-name=threshold. e.g: 'Threads_running=100,Threads_connected=500'. When status exceeds threshold, app throttles writes")
 	criticalLoad := flag.String("critical-load", "", "Comma delimited status-name=threshold, same format as --max-load. When status exceeds threshold, app panics and quits")
 	flag.Int64Var(&migrationContext.CriticalLoadIntervalMilliseconds, "critical-load-interval-millis", 0, "When 0, migration immediately bails out upon meeting critical-load. When non-zero, a second check is done after given interval, and migration only bails out if 2nd check still meets critical load")
 	flag.Int64Var(&migrationContext.CriticalLoadHibernateSeconds, "critical-load-hibernate-seconds", 0, "When non-zero, critical-load does not panic and bail out; instead, gh-ost goes into hibernation for the specified duration. It will not read/write anything from/to any server")
+	criticalLoadThrottleDuration := flag.Duration("critical-load-throttle-duration", 0, "When non-zero, critical-load first throttles for this duration, then escalates to hibernation per --critical-load-hibernate-seconds, should critical-load persist. Enables the critical-load escalation policy")
+	criticalLoadAbortAfter := flag.Duration("critical-load-abort-after", 0, "When non-zero, critical-load only bails out once met for this long, throttling or hibernating meanwhile. Enables the critical-load escalation policy")
 	quiet := flag.Bool("quiet", false, "quiet")
 	verbose := flag.Bool("verbose", false, "verbose")
 	debug := flag.Bool("debug", false, "debug mode (very verbose)")
@@ -181,8 +409,8 @@ func main() {
 		migrationContext.Log.SetLevel(log.ERROR)
 	}
 
-	if migrationContext.AlterStatement == "" {
-		log.Fatalf("--alter must be provided and statement must not be empty")
+	if migrationContext.AlterStatement == "" && migrationContext.NewTableName == "" && !migrationContext.Cleanup {
+		log.Fatalf("--alter must be provided and statement must not be empty, unless --new-table-name is given")
 	}
 	parser := sql.NewParserFromAlterStatement(migrationContext.AlterStatement)
 	migrationContext.AlterStatementOptions = parser.GetAlterStatementOptions()
@@ -216,6 +444,144 @@ func main() {
 	if migrationContext.MigrateOnReplica && migrationContext.TestOnReplica {
 		migrationContext.Log.Fatalf("--migrate-on-replica and --test-on-replica are mutually exclusive")
 	}
+	if migrationContext.PreserveForeignKeys && migrationContext.DiscardForeignKeys {
+		migrationContext.Log.Fatalf("--preserve-foreign-keys and --discard-foreign-keys are mutually exclusive")
+	}
+	if migrationContext.PreserveForeignKeys && migrationContext.SkipForeignKeyChecks {
+		migrationContext.Log.Fatalf("--preserve-foreign-keys and --skip-foreign-key-checks are mutually exclusive")
+	}
+	if migrationContext.IsCrossSchemaMigration() && migrationContext.IncludeTriggers {
+		migrationContext.Log.Fatalf("--target-database and --include-triggers are mutually exclusive: triggers cannot be renamed across databases")
+	}
+	if migrationContext.IsCrossSchemaMigration() && migrationContext.AttemptInstantDDL {
+		migrationContext.Log.Fatalf("--target-database and --attempt-instant-ddl are mutually exclusive")
+	}
+	if err := migrationContext.ValidateNewTableName(); err != nil {
+		migrationContext.Log.Fatale(err)
+	}
+	if migrationContext.IsTableRenamed() && migrationContext.AttemptInstantDDL {
+		migrationContext.Log.Fatalf("--new-table-name and --attempt-instant-ddl are mutually exclusive: instant DDL does not rename the table")
+	}
+	if migrationContext.AllowSelfReferencingTriggers && !migrationContext.IncludeTriggers {
+		migrationContext.Log.Fatalf("--allow-self-referencing-triggers requires --include-triggers")
+	}
+	if migrationContext.PostponeCutOverOnFailure && migrationContext.PostponeCutOverFlagFile == "" {
+		migrationContext.Log.Fatalf("--postpone-cut-over-on-failure requires --postpone-cut-over-flag-file")
+	}
+	if migrationContext.CutOverRetryAttempts < 0 {
+		migrationContext.Log.Fatalf("--cut-over-retry-attempts must be non-negative")
+	}
+	if migrationContext.HooksBeforeCutOverTimeout <= 0 {
+		migrationContext.Log.Fatalf("--hooks-before-cut-over-timeout must be positive")
+	}
+	if migrationContext.CutOverRetryInterval < 0 {
+		migrationContext.Log.Fatalf("--cut-over-retry-interval must be non-negative")
+	}
+	if migrationContext.DropOldTableAfter < 0 {
+		migrationContext.Log.Fatalf("--drop-old-table-after must be non-negative")
+	}
+	if migrationContext.DropOldTableAfter > 0 && migrationContext.OkToDropTable {
+		migrationContext.Log.Fatalf("--drop-old-table-after and --ok-to-drop-table are mutually exclusive")
+	}
+	if migrationContext.DropOldTableAfter > 0 && migrationContext.TestOnReplica {
+		migrationContext.Log.Fatalf("--drop-old-table-after and --test-on-replica are mutually exclusive")
+	}
+	if migrationContext.DropOldTableDetach && migrationContext.DropOldTableAfter == 0 {
+		migrationContext.Log.Fatalf("--drop-old-table-detach requires --drop-old-table-after")
+	}
+	if migrationContext.DropOldTableScratchDatabase != "" && migrationContext.DropOldTableAfter == 0 && !migrationContext.Cleanup {
+		migrationContext.Log.Fatalf("--drop-old-table-scratch-database requires --drop-old-table-after")
+	}
+	if migrationContext.Cleanup && migrationContext.DropOldTableAfter > 0 {
+		migrationContext.Log.Fatalf("--cleanup and --drop-old-table-after are mutually exclusive: --cleanup honors the delay the migration was run with")
+	}
+	if migrationContext.CutOverKillBlockingQueriesAfter != 0 {
+		if migrationContext.CutOverKillBlockingQueriesAfter < time.Second {
+			migrationContext.Log.Fatalf("--cut-over-kill-blocking-queries-after must be at least 1s")
+		}
+		if *cutOverKillBlockingQueriesFor != migrationContext.OriginalTableName {
+			migrationContext.Log.Fatalf("--cut-over-kill-blocking-queries-after kills sessions on the master. To confirm, --cut-over-kill-blocking-queries-for must name the migrated table, %s", migrationContext.OriginalTableName)
+		}
+	} else if *cutOverKillBlockingQueriesFor != "" || migrationContext.CutOverKillBlockingQueriesDryRun {
+		migrationContext.Log.Fatalf("--cut-over-kill-blocking-queries-for and --cut-over-kill-blocking-queries-dry-run require --cut-over-kill-blocking-queries-after")
+	}
+	if err := migrationContext.SetCutOverMaxRowDelta(*cutOverMaxRowDelta); err != nil {
+		migrationContext.Log.Fatale(err)
+	}
+	switch base.CutOverRowCountMode(*cutOverRowCountMode) {
+	case base.CutOverRowCountEstimate, base.CutOverRowCountExact:
+		migrationContext.CutOverRowCountMode = base.CutOverRowCountMode(*cutOverRowCountMode)
+	default:
+		migrationContext.Log.Fatalf("Unknown cut-over row count mode: %s. Expected estimate or exact", *cutOverRowCountMode)
+	}
+	if migrationContext.CutOverRowCountCheck && migrationContext.RowFilter != "" {
+		migrationContext.Log.Fatalf("--cut-over-max-row-delta and --row-filter are mutually exclusive: the ghost table holds a subset of the rows")
+	}
+	if migrationContext.OldTableNamePattern != "" {
+		if migrationContext.TimestampOldTable {
+			migrationContext.Log.Fatalf("--old-table-name-pattern and --timestamp-old-table are mutually exclusive")
+		}
+		if err := base.ValidateTableNamePattern(migrationContext.OldTableNamePattern); err != nil {
+			migrationContext.Log.Fatalf("--old-table-name-pattern: %+v", err)
+		}
+	}
+	if migrationContext.GhostTableNamePattern != "" {
+		if err := base.ValidateTableNamePattern(migrationContext.GhostTableNamePattern); err != nil {
+			migrationContext.Log.Fatalf("--ghost-table-name-pattern: %+v", err)
+		}
+		if migrationContext.Resume && (strings.Contains(migrationContext.GhostTableNamePattern, "{timestamp}") || strings.Contains(migrationContext.GhostTableNamePattern, "{date}")) {
+			migrationContext.Log.Fatalf("--ghost-table-name-pattern with {timestamp} or {date} cannot be resumed: the interrupted migration's ghost table cannot be found")
+		}
+	}
+	if err := migrationContext.ValidateTableNames(); err != nil {
+		migrationContext.Log.Fatale(err)
+	}
+	if *autoUnpostponeWhen != "" {
+		if migrationContext.PostponeCutOverFlagFile == "" {
+			migrationContext.Log.Fatalf("--auto-unpostpone-when requires --postpone-cut-over-flag-file")
+		}
+		criteria, err := base.ParseUnpostponeCriteria(*autoUnpostponeWhen)
+		if err != nil {
+			migrationContext.Log.Fatalf("--auto-unpostpone-when: %+v", err)
+		}
+		migrationContext.AutoUnpostponeCriteria = criteria
+	}
+	if *cutOverWindow != "" {
+		location, err := time.LoadLocation(*cutOverWindowTimeZone)
+		if err != nil {
+			migrationContext.Log.Fatalf("--cut-over-window-time-zone: %+v", err)
+		}
+		window, err := base.ParseCutOverWindow(*cutOverWindow, location)
+		if err != nil {
+			migrationContext.Log.Fatalf("--cut-over-window: %+v", err)
+		}
+		migrationContext.CutOverWindow = window
+	}
+	if location, err := time.LoadLocation(*throttleScheduleTimeZone); err != nil {
+		migrationContext.Log.Fatalf("--throttle-schedule-time-zone: %+v", err)
+	} else {
+		migrationContext.ThrottleScheduleLocation = location
+	}
+	if err := migrationContext.ReadThrottleSchedule(strings.Join(throttleSchedule, ";")); err != nil {
+		migrationContext.Log.Fatalf("--throttle-schedule: %+v", err)
+	}
+	if *zeroDateRewrite != "" {
+		rewrite, err := sql.ParseZeroDateRewrite(*zeroDateRewrite)
+		if err != nil {
+			migrationContext.Log.Fatalf("--zero-date-rewrite: %+v", err)
+		}
+		migrationContext.ZeroDateRewrite = rewrite
+		if migrationContext.SurrogateKey {
+			migrationContext.Log.Fatalf("--zero-date-rewrite and --surrogate-key are mutually exclusive")
+		}
+	}
+	if _, err := mysql.ParseTimeZone(migrationContext.ConnectionTimeZone); err != nil {
+		migrationContext.Log.Fatalf("--connection-time-zone: %+v", err)
+	}
+	migrationContext.InspectorConnectionConfig.TimeZone = migrationContext.ConnectionTimeZone
+	if migrationContext.SQLMode != "" && !sqlModeRegexp.MatchString(migrationContext.SQLMode) {
+		migrationContext.Log.Fatalf("--sql-mode must be a comma separated list of modes, got: %s", migrationContext.SQLMode)
+	}
 	if migrationContext.SwitchToRowBinlogFormat && migrationContext.AssumeRBR {
 		migrationContext.Log.Fatalf("--switch-to-rbr and --assume-rbr are mutually exclusive")
 	}
@@ -243,6 +609,116 @@ func main() {
 	if migrationContext.TLSAllowInsecure && !migrationContext.UseTLS {
 		migrationContext.Log.Fatalf("--ssl-allow-insecure requires --ssl")
 	}
+	switch strings.ToLower(migrationContext.MySQLFlavor) {
+	case "", "mysql", "mariadb":
+	default:
+		migrationContext.Log.Fatalf("Unknown --mysql-flavor: %s", migrationContext.MySQLFlavor)
+	}
+	if migrationContext.StreamerReconnectIntervalSeconds < 0 {
+		migrationContext.Log.Fatalf("--streamer-reconnect-interval must be non-negative")
+	}
+	if migrationContext.BinlogSyncerHeartbeatPeriodSeconds < 0 || migrationContext.BinlogSyncerReadTimeoutSeconds < 0 {
+		migrationContext.Log.Fatalf("--binlogsyncer-heartbeat-period and --binlogsyncer-read-timeout must be non-negative")
+	}
+	if migrationContext.BinlogSyncerReadTimeoutSeconds > 0 && migrationContext.BinlogSyncerReadTimeoutSeconds <= migrationContext.BinlogSyncerHeartbeatPeriodSeconds {
+		migrationContext.Log.Fatalf("--binlogsyncer-read-timeout must be greater than --binlogsyncer-heartbeat-period")
+	}
+	if migrationContext.BinlogSyncerReadTimeoutSeconds > 0 && migrationContext.BinlogSyncerHeartbeatPeriodSeconds == 0 {
+		migrationContext.Log.Warningf("--binlogsyncer-read-timeout is set without --binlogsyncer-heartbeat-period; an idle binlog will cause needless streamer reconnects")
+	}
+	if migrationContext.BinlogEventsBufferSize < 1 {
+		migrationContext.Log.Fatalf("--binlog-events-buffer-size must be at least 1")
+	}
+	if migrationContext.BinlogStallTimeoutSeconds < 0 {
+		migrationContext.Log.Fatalf("--binlog-stall-timeout must be non-negative")
+	}
+	if migrationContext.DMLPreparedStatementsCacheSize < 0 {
+		migrationContext.Log.Fatalf("--dml-prepared-statements-cache-size must be non-negative")
+	}
+	if migrationContext.DMLApplyConcurrency < 1 || migrationContext.DMLApplyConcurrency > 64 {
+		migrationContext.Log.Fatalf("--dml-apply-concurrency must be in the range 1-64")
+	}
+	if migrationContext.ApplierMaxOpenConns < 0 || migrationContext.ApplierMaxIdleConns < 0 || migrationContext.ApplierConnMaxLifetime < 0 {
+		migrationContext.Log.Fatalf("--applier-max-open-conns, --applier-max-idle-conns and --applier-conn-max-lifetime must be non-negative")
+	}
+	if migrationContext.InspectorMaxOpenConns < 0 || migrationContext.InspectorMaxIdleConns < 0 || migrationContext.InspectorConnMaxLifetime < 0 {
+		migrationContext.Log.Fatalf("--inspector-max-open-conns, --inspector-max-idle-conns and --inspector-conn-max-lifetime must be non-negative")
+	}
+	if migrationContext.DMLRetryAttempts < 0 {
+		migrationContext.Log.Fatalf("--dml-retry-attempts must be non-negative")
+	}
+	if migrationContext.DMLBatchMaxBytes < 0 {
+		migrationContext.Log.Fatalf("--dml-batch-max-bytes must be non-negative")
+	}
+	if migrationContext.MaxPacketBudget < 0 {
+		migrationContext.Log.Fatalf("--max-packet-budget must be non-negative")
+	}
+	if migrationContext.AbortOnBinlogStall && migrationContext.BinlogStallTimeoutSeconds == 0 {
+		migrationContext.Log.Fatalf("--abort-on-binlog-stall requires --binlog-stall-timeout")
+	}
+	if migrationContext.BinlogEventsBlockedWarningSeconds < 0 {
+		migrationContext.Log.Fatalf("--binlog-events-blocked-warning must be non-negative")
+	}
+	if migrationContext.MaxDMLBacklog < 0 || migrationContext.MaxDMLBacklog > base.MaxEventsBatchSize {
+		migrationContext.Log.Fatalf("--max-dml-backlog must be in the range 0..%d", base.MaxEventsBatchSize)
+	}
+	if migrationContext.MaxEventsLag < 0 {
+		migrationContext.Log.Fatalf("--max-events-lag must be non-negative")
+	}
+	if migrationContext.BinlogEventsBufferThrottleDepth < 0 || migrationContext.BinlogEventsBufferThrottleDepth > migrationContext.BinlogEventsBufferSize {
+		migrationContext.Log.Fatalf("--throttle-binlog-events-buffer-depth must be in the range 0..--binlog-events-buffer-size")
+	}
+	if migrationContext.CheckLoadOnReplicas && *throttleControlReplicas == "" && !migrationContext.DiscoverThrottleReplicas && !migrationContext.TestOnReplica && !migrationContext.MigrateOnReplica {
+		migrationContext.Log.Warningf("--check-load-on-replicas given without --throttle-control-replicas; load is only checked on replicas added via interactive commands")
+	}
+	if migrationContext.DiscoverThrottleReplicas {
+		if migrationContext.DiscoverThrottleReplicasInterval <= 0 {
+			migrationContext.Log.Fatalf("--discover-throttle-replicas-interval must be positive")
+		}
+		if migrationContext.DiscoverThrottleReplicasDropAfter < 1 {
+			migrationContext.Log.Fatalf("--discover-throttle-replicas-drop-after must be at least 1")
+		}
+	} else if *discoverThrottleReplicasExclude != "" {
+		migrationContext.Log.Warningf("--discover-throttle-replicas-exclude given without --discover-throttle-replicas; ignoring")
+	}
+	migrationContext.ReadDiscoverThrottleReplicasExclude(*discoverThrottleReplicasExclude)
+	if migrationContext.ThrottleLagCheckInterval < 0 || migrationContext.ThrottleHTTPCheckInterval < 0 {
+		migrationContext.Log.Fatalf("--throttle-lag-check-interval and --throttle-http-check-interval must be non-negative")
+	}
+	if migrationContext.ThrottleLoadCheckInterval <= 0 || migrationContext.ThrottleFlagFileCheckInterval <= 0 {
+		migrationContext.Log.Fatalf("--throttle-load-check-interval and --throttle-flag-file-check-interval must be positive")
+	}
+	if migrationContext.ThrottleHTTPCheckInterval == 0 && migrationContext.ThrottleHTTPIntervalMillis <= 0 {
+		migrationContext.Log.Fatalf("--throttle-http-interval-millis must be positive")
+	}
+	if *minFreeDiskSpace != "" {
+		threshold, err := base.ParseDiskSpaceThreshold(*minFreeDiskSpace)
+		if err != nil {
+			migrationContext.Log.Fatalf("--min-free-disk-space: %+v", err)
+		}
+		migrationContext.MinFreeDiskSpace = threshold
+	}
+	if *criticalFreeDiskSpace != "" {
+		threshold, err := base.ParseDiskSpaceThreshold(*criticalFreeDiskSpace)
+		if err != nil {
+			migrationContext.Log.Fatalf("--critical-free-disk-space: %+v", err)
+		}
+		migrationContext.CriticalFreeDiskSpace = threshold
+	}
+	if min, critical := migrationContext.MinFreeDiskSpace, migrationContext.CriticalFreeDiskSpace; min != nil && critical != nil && min.IsPercent() == critical.IsPercent() {
+		if min.Bytes < critical.Bytes || min.Percent < critical.Percent {
+			migrationContext.Log.Fatalf("--critical-free-disk-space must be lower than --min-free-disk-space")
+		}
+	}
+	if migrationContext.DiskSpaceQuery == "" && migrationContext.IsDiskSpaceCheckEnabled() {
+		migrationContext.Log.Fatalf("--min-free-disk-space and --critical-free-disk-space require --disk-space-query")
+	}
+	if migrationContext.DiskSpaceQuery != "" && !migrationContext.IsDiskSpaceCheckEnabled() {
+		migrationContext.Log.Warningf("--disk-space-query given without --min-free-disk-space or --critical-free-disk-space; ignoring")
+	}
+	if migrationContext.MaxHistoryListLength < 0 {
+		migrationContext.Log.Fatalf("--max-history-list-length must be non-negative")
+	}
 	if *replicationLagQuery != "" {
 		migrationContext.Log.Warningf("--replication-lag-query is deprecated")
 	}
@@ -255,18 +731,100 @@ func main() {
 	default:
 		migrationContext.Log.Fatalf("Unknown cut-over: %s", *cutOver)
 	}
+	if migrationContext.CutOverRehearsals > 0 && migrationContext.CutOverType != base.CutOverAtomic {
+		migrationContext.Log.Fatalf("--cut-over-rehearsals requires --cut-over=atomic")
+	}
+	switch sql.CopyConflictStrategy(*copyConflictStrategy) {
+	case sql.IgnoreCopyConflictStrategy, sql.ReplaceCopyConflictStrategy, sql.FailCopyConflictStrategy:
+		migrationContext.CopyConflictStrategy = sql.CopyConflictStrategy(*copyConflictStrategy)
+	default:
+		migrationContext.Log.Fatalf("Unknown copy-conflict-strategy: %s", *copyConflictStrategy)
+	}
+	switch sql.CopyOrder(*copyOrder) {
+	case sql.AscendingCopyOrder, sql.DescendingCopyOrder:
+		migrationContext.CopyOrder = sql.CopyOrder(*copyOrder)
+	default:
+		migrationContext.Log.Fatalf("Unknown copy-order: %s", *copyOrder)
+	}
 	if err := migrationContext.ReadConfigFile(); err != nil {
 		migrationContext.Log.Fatale(err)
 	}
 	if err := migrationContext.ReadThrottleControlReplicaKeys(*throttleControlReplicas); err != nil {
 		migrationContext.Log.Fatale(err)
 	}
+	if err := migrationContext.ReadReplicaLagSources(*replicaLagSource); err != nil {
+		migrationContext.Log.Fatalf("--replica-lag-source: %+v", err)
+	}
+	if err := migrationContext.ReadStreamerFailoverKeys(*streamerFailoverHosts); err != nil {
+		migrationContext.Log.Fatale(err)
+	}
+	if err := migrationContext.ReadStartBinlogCoordinates(*startBinlogCoordinates); err != nil {
+		migrationContext.Log.Fatale(err)
+	}
+	if migrationContext.StartBinlogCoordinates != nil && migrationContext.StartGtidSet != "" {
+		migrationContext.Log.Fatalf("--start-binlog-coordinates and --start-gtid-set are mutually exclusive")
+	}
+	if migrationContext.Resume {
+		if migrationContext.InitiallyDropGhostTable {
+			migrationContext.Log.Fatalf("--resume and --initially-drop-ghost-table are mutually exclusive")
+		}
+		if migrationContext.StartBinlogCoordinates != nil || migrationContext.StartGtidSet != "" || migrationContext.BinlogFile != "" {
+			migrationContext.Log.Fatalf("--resume streams from the checkpoint's binlog coordinates; it is mutually exclusive with --start-binlog-coordinates, --start-gtid-set and --binlog-file")
+		}
+		if migrationContext.CopyConflictStrategy == sql.FailCopyConflictStrategy {
+			migrationContext.Log.Fatalf("--resume copies anew the chunks copied since the last checkpoint, which conflict under --copy-conflict-strategy=fail")
+		}
+	}
+	if migrationContext.SurrogateKey {
+		if migrationContext.Resume {
+			migrationContext.Log.Fatalf("--resume does not support --surrogate-key")
+		}
+		if migrationContext.ChunkIndex != "" {
+			migrationContext.Log.Fatalf("--chunk-index and --surrogate-key are mutually exclusive")
+		}
+		if migrationContext.CopyConflictStrategy != sql.IgnoreCopyConflictStrategy {
+			migrationContext.Log.Fatalf("--surrogate-key copies only rows not yet found on the ghost table; it is mutually exclusive with --copy-conflict-strategy")
+		}
+		if migrationContext.SurrogateKeyColumnName == "" {
+			migrationContext.Log.Fatalf("--surrogate-key-column must not be empty")
+		}
+	}
+	if migrationContext.RowFilter != "" {
+		if migrationContext.SurrogateKey {
+			migrationContext.Log.Fatalf("--row-filter and --surrogate-key are mutually exclusive")
+		}
+		if migrationContext.AttemptInstantDDL {
+			migrationContext.Log.Fatalf("--row-filter migrates a subset of rows, which instant DDL does not; it is mutually exclusive with --attempt-instant-ddl")
+		}
+	}
+	if migrationContext.CheckpointIntervalChunks < 0 {
+		migrationContext.Log.Fatalf("--checkpoint-interval-chunks must be non-negative")
+	}
+	if migrationContext.BinlogFile != "" {
+		if migrationContext.StartGtidSet != "" {
+			migrationContext.Log.Fatalf("--binlog-file and --start-gtid-set are mutually exclusive")
+		}
+		if _, err := os.Stat(migrationContext.BinlogFile); err != nil {
+			migrationContext.Log.Fatale(err)
+		}
+		migrationContext.Log.Warningf("--binlog-file: replaying %s instead of streaming binary logs. This migration cannot cut-over", migrationContext.BinlogFile)
+	}
+	if err := migrationContext.ReadReplicaServerIdRange(*replicaServerIdRange); err != nil {
+		migrationContext.Log.Fatale(err)
+	}
 	if err := migrationContext.ReadMaxLoad(*maxLoad); err != nil {
 		migrationContext.Log.Fatale(err)
 	}
 	if err := migrationContext.ReadCriticalLoad(*criticalLoad); err != nil {
 		migrationContext.Log.Fatale(err)
 	}
+	if *criticalLoadThrottleDuration < 0 || *criticalLoadAbortAfter < 0 {
+		migrationContext.Log.Fatalf("--critical-load-throttle-duration and --critical-load-abort-after must be non-negative")
+	}
+	migrationContext.CriticalLoadEscalation = base.NewCriticalLoadEscalation(*criticalLoadThrottleDuration, time.Duration(migrationContext.CriticalLoadHibernateSeconds)*time.Second, *criticalLoadAbortAfter)
+	if migrationContext.CriticalLoadEscalation.IsEnabled() && migrationContext.CriticalLoadIntervalMilliseconds > 0 {
+		migrationContext.Log.Warningf("--critical-load-interval-millis is ignored under --critical-load-throttle-duration or --critical-load-abort-after")
+	}
 	if migrationContext.ServeSocketFile == "" {
 		migrationContext.ServeSocketFile = fmt.Sprintf("/tmp/gh-ost.%s.%s.sock", migrationContext.DatabaseName, migrationContext.OriginalTableName)
 	}
@@ -281,11 +839,49 @@ func main() {
 	migrationContext.SetHeartbeatIntervalMilliseconds(*heartbeatIntervalMillis)
 	migrationContext.SetNiceRatio(*niceRatio)
 	migrationContext.SetChunkSize(*chunkSize)
+	if err := migrationContext.SetChunkLatencyTarget(*chunkLatencyTarget); err != nil {
+		migrationContext.Log.Fatale(err)
+	}
+	if err := migrationContext.SetChunkSizeBounds(*chunkSizeMin, *chunkSizeMax); err != nil {
+		migrationContext.Log.Fatale(err)
+	}
 	migrationContext.SetDMLBatchSize(*dmlBatchSize)
+	migrationContext.CopyRateLimiter.SetRate(*maxCopyRowsPerSecond)
+	migrationContext.DMLApplyRateLimiter.SetRate(*maxDMLApplyRowsPerSecond)
+	if *throttleRampUpDuration < 0 || *throttleRampUpThreshold < 0 {
+		migrationContext.Log.Fatalf("--throttle-ramp-up-duration and --throttle-ramp-up-threshold must be non-negative")
+	}
+	migrationContext.ThrottleRampUp.SetDuration(*throttleRampUpDuration)
+	migrationContext.ThrottleRampUp.SetThreshold(*throttleRampUpThreshold)
 	migrationContext.SetMaxLagMillisecondsThrottleThreshold(*maxLagMillis)
 	migrationContext.SetThrottleQuery(*throttleQuery)
 	migrationContext.SetThrottleHTTP(*throttleHTTP)
+	if policy, err := base.ParseThrottleHTTPUnreachablePolicy(*throttleHTTPUnreachablePolicy); err != nil {
+		migrationContext.Log.Fatale(err)
+	} else if *ignoreHTTPErrors && policy != base.ThrottleHTTPUnreachableThrottle && policy != base.ThrottleHTTPUnreachableIgnore {
+		migrationContext.Log.Fatalf("--ignore-http-errors conflicts with --throttle-http-unreachable-policy=%s", policy)
+	} else {
+		migrationContext.ThrottleHTTPUnreachablePolicy = policy
+	}
 	migrationContext.SetIgnoreHTTPErrors(*ignoreHTTPErrors)
+	if method, err := base.ParseThrottleHTTPMethod(*throttleHTTPMethod); err != nil {
+		migrationContext.Log.Fatale(err)
+	} else {
+		migrationContext.ThrottleHTTPMethod = method
+	}
+	if *throttleHTTPBody != "" {
+		if migrationContext.ThrottleHTTPMethod != http.MethodPost {
+			migrationContext.Log.Fatalf("--throttle-http-body requires --throttle-http-method=POST")
+		}
+		bodyTemplate, err := base.ParseThrottleHTTPBodyTemplate(*throttleHTTPBody)
+		if err != nil {
+			migrationContext.Log.Fatalf("Cannot parse --throttle-http-body: %+v", err)
+		}
+		migrationContext.ThrottleHTTPBodyTemplate = bodyTemplate
+	}
+	if migrationContext.ThrottleHTTPReasonFromBody && migrationContext.ThrottleHTTPMethod == http.MethodHead {
+		migrationContext.Log.Fatalf("--throttle-http-reason-from-body requires --throttle-http-method=GET or POST")
+	}
 	migrationContext.SetDefaultNumRetries(*defaultRetries)
 	migrationContext.ApplyCredentials()
 	if err := migrationContext.SetupTLS(); err != nil {
@@ -294,6 +890,9 @@ func main() {
 	if err := migrationContext.SetCutOverLockTimeoutSeconds(*cutOverLockTimeoutSeconds); err != nil {
 		migrationContext.Log.Errore(err)
 	}
+	if err := migrationContext.SetCutOverLockWaitAndRenameTimeouts(*cutOverLockWaitTimeout, *cutOverRenameTimeout); err != nil {
+		migrationContext.Log.Fatale(err)
+	}
 	if err := migrationContext.SetExponentialBackoffMaxInterval(*exponentialBackoffMaxInterval); err != nil {
 		migrationContext.Log.Errore(err)
 	}
@@ -301,11 +900,33 @@ func main() {
 	log.Infof("starting gh-ost %+v", AppVersion)
 	acceptSignals(migrationContext)
 
+	if migrationContext.PrometheusListenAddr != "" {
+		listener, err := metrics.Serve(migrationContext.PrometheusListenAddr, metrics.DefaultRegistry)
+		if err != nil {
+			migrationContext.Log.Fatalf("--prometheus-listen-addr: %+v", err)
+		}
+		defer listener.Close()
+		migrationContext.Log.Infof("Serving Prometheus metrics on %s/metrics", listener.Addr())
+	}
+
 	migrator := logic.NewMigrator(migrationContext, AppVersion)
+	if migrationContext.Cleanup {
+		if err := migrator.Cleanup(); err != nil {
+			migrationContext.Log.Fatale(err)
+		}
+		fmt.Fprintf(os.Stdout, "# Done\n")
+		return
+	}
 	err := migrator.Migrate()
 	if err != nil {
 		migrator.ExecOnFailureHook()
 		migrationContext.Log.Fatale(err)
 	}
+	if migrator.HasScheduledOldTableDrop() && !migrationContext.DropOldTableDetach {
+		// Janitor: the migration is complete, its connections torn down. Wait and drop the old table
+		if err := logic.NewMigrator(migrationContext, AppVersion).Cleanup(); err != nil {
+			migrationContext.Log.Fatale(err)
+		}
+	}
 	fmt.Fprintf(os.Stdout, "# Done\n")
 }
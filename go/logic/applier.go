@@ -6,6 +6,7 @@
 package logic
 
 import (
+	"context"
 	gosql "database/sql"
 	"fmt"
 	"strings"
@@ -23,6 +24,7 @@ import (
 
 const (
 	atomicCutOverMagicHint = "ghost-cut-over-sentry"
+	dmlRetryInitialBackoff = 100 * time.Millisecond
 )
 
 type dmlBuildResult struct {
@@ -30,6 +32,9 @@ type dmlBuildResult struct {
 	args      []interface{}
 	rowsDelta int64
 	err       error
+	// bytesSaved counts the value bytes --compact-updates spared this query. A result with an empty
+	// query has nothing to apply, and only accounts for the bytes saved.
+	bytesSaved int64
 }
 
 func newDmlBuildResult(query string, args []interface{}, rowsDelta int64, err error) *dmlBuildResult {
@@ -56,31 +61,81 @@ type Applier struct {
 	connectionConfig  *mysql.ConnectionConfig
 	db                *gosql.DB
 	singletonDB       *gosql.DB
+	dmlDB             *gosql.DB
+	dmlStatements     *dmlStatementCache
 	migrationContext  *base.MigrationContext
 	finishedMigrating int64
 	name              string
+
+	// magicLockRowCountRequests have the session holding the atomic cut-over lock count the original table's rows
+	magicLockRowCountRequests chan *rowCountRequest
+}
+
+// rowCountResult is a table's row count, as counted by the session holding the cut-over lock
+type rowCountResult struct {
+	rows int64
+	err  error
+}
+
+// rowCountRequest asks the session holding the cut-over lock to count the original table's rows within timeout
+type rowCountRequest struct {
+	timeout time.Duration
+	result  chan rowCountResult
 }
 
 func NewApplier(migrationContext *base.MigrationContext) *Applier {
 	return &Applier{
-		connectionConfig:  migrationContext.ApplierConnectionConfig,
-		migrationContext:  migrationContext,
-		finishedMigrating: 0,
-		name:              "applier",
+		connectionConfig:          migrationContext.ApplierConnectionConfig,
+		migrationContext:          migrationContext,
+		finishedMigrating:         0,
+		name:                      "applier",
+		magicLockRowCountRequests: make(chan *rowCountRequest),
 	}
 }
 
 func (this *Applier) InitDBConnections() (err error) {
-
+	// All applier connections, row copy, binlog event apply and heartbeat writes alike, share the sql_mode
+	this.connectionConfig.SQLMode = this.generateSqlMode()
+	this.connectionConfig.SessionVariables = this.migrationContext.SessionVariables
 	applierUri := this.connectionConfig.GetDBUri(this.migrationContext.DatabaseName)
 	if this.db, _, err = mysql.GetDB(this.migrationContext.Uuid, applierUri); err != nil {
 		return err
 	}
+	if mysql.HidesGeneratedInvisiblePrimaryKeys(this.db) {
+		this.migrationContext.Log.Infof("Showing generated invisible primary keys on applier connections")
+		this.connectionConfig.ShowGeneratedInvisiblePrimaryKeys = true
+		applierUri = this.connectionConfig.GetDBUri(this.migrationContext.DatabaseName)
+		if this.db, _, err = mysql.GetDB(this.migrationContext.Uuid, applierUri); err != nil {
+			return err
+		}
+	}
 	singletonApplierUri := fmt.Sprintf("%s&timeout=0", applierUri)
 	if this.singletonDB, _, err = mysql.GetDB(this.migrationContext.Uuid, singletonApplierUri); err != nil {
 		return err
 	}
 	this.singletonDB.SetMaxOpenConns(1)
+	if dmlApplyConcurrency := int(this.migrationContext.DMLApplyConcurrency); dmlApplyConcurrency > 1 {
+		// Room for concurrent DML apply workers, on top of row copy and other operations
+		this.db.SetMaxOpenConns(mysql.MaxDBPoolConnections + dmlApplyConcurrency)
+		this.db.SetMaxIdleConns(mysql.MaxDBPoolConnections + dmlApplyConcurrency)
+	}
+	mysql.SetDBPoolLimits(this.db,
+		int(this.migrationContext.ApplierMaxOpenConns),
+		int(this.migrationContext.ApplierMaxIdleConns),
+		this.migrationContext.ApplierConnMaxLifetime,
+	)
+	mysql.SetDBPoolLimits(this.singletonDB, 0, 0, this.migrationContext.ApplierConnMaxLifetime)
+	if cacheSize := this.migrationContext.DMLPreparedStatementsCacheSize; cacheSize > 0 {
+		// Dedicated connections, one per DML apply worker, apply binlog events via server side prepared statements.
+		// Not the singleton connection, which holds table locks throughout a cut-over, while events still apply.
+		dmlApplierUri := fmt.Sprintf("%s&interpolateParams=false", applierUri)
+		if this.dmlDB, _, err = mysql.GetDB(this.migrationContext.Uuid, dmlApplierUri); err != nil {
+			return err
+		}
+		this.dmlDB.SetMaxOpenConns(int(this.migrationContext.DMLApplyConcurrency))
+		mysql.SetDBPoolLimits(this.dmlDB, 0, 0, this.migrationContext.ApplierConnMaxLifetime)
+		this.dmlStatements = newDMLStatementCache(this.migrationContext, this.dmlDB, int(cacheSize))
+	}
 	version, err := base.ValidateConnection(this.db, this.connectionConfig, this.migrationContext, this.name)
 	if err != nil {
 		return err
@@ -92,6 +147,16 @@ func (this *Applier) InitDBConnections() (err error) {
 	if err := this.validateAndReadTimeZone(); err != nil {
 		return err
 	}
+	if err := this.readMaxAllowedPacket(); err != nil {
+		return err
+	}
+	if err := this.readSQLMode(); err != nil {
+		return err
+	}
+	this.migrationContext.Log.Infof("Applier sql_mode is '%s'", this.migrationContext.ApplierSQLMode)
+	if err := base.LogSessionVariables(this.db, this.migrationContext, this.name); err != nil {
+		return err
+	}
 	if !this.migrationContext.AliyunRDS && !this.migrationContext.GoogleCloudPlatform && !this.migrationContext.AzureMySQL {
 		if impliedKey, err := mysql.GetInstanceKey(this.db); err != nil {
 			return err
@@ -99,6 +164,12 @@ func (this *Applier) InitDBConnections() (err error) {
 			this.connectionConfig.ImpliedKey = impliedKey
 		}
 	}
+	if err := this.detectAurora(); err != nil {
+		return err
+	}
+	if err := this.validateFreeDiskSpace(); err != nil {
+		return err
+	}
 	if err := this.readTableColumns(); err != nil {
 		return err
 	}
@@ -106,37 +177,132 @@ func (this *Applier) InitDBConnections() (err error) {
 	return nil
 }
 
+// detectAurora tells whether the applier is an Amazon Aurora writer, via @@aurora_version, which no other server has.
+// Aurora readers do not replicate via binary logs: their lag is only found via --aurora-lag-throttle.
+func (this *Applier) detectAurora() error {
+	var auroraVersion string
+	if err := this.db.QueryRow(`select /* gh-ost */ @@aurora_version`).Scan(&auroraVersion); err != nil {
+		if this.migrationContext.AuroraLagThrottle {
+			return fmt.Errorf("--aurora-lag-throttle given, but %s is not Amazon Aurora: %+v", this.connectionConfig.Key.String(), err)
+		}
+		return nil
+	}
+	this.migrationContext.AuroraVersion = auroraVersion
+	if this.migrationContext.AuroraLagThrottle {
+		this.migrationContext.Log.Infof("Amazon Aurora %s detected; throttling on readers lag", auroraVersion)
+	} else {
+		this.migrationContext.Log.Infof("Amazon Aurora %s detected. Aurora readers do not replicate via binary logs, and neither the heartbeat nor SHOW REPLICA STATUS reflect their lag. Consider --aurora-lag-throttle to throttle on readers lag", auroraVersion)
+	}
+	return nil
+}
+
+// validateFreeDiskSpace reads the free disk space once, with --min-free-disk-space or --critical-free-disk-space,
+// so that a disk space query which cannot work on this server fails the migration upfront
+func (this *Applier) validateFreeDiskSpace() error {
+	if !this.migrationContext.IsDiskSpaceCheckEnabled() {
+		return nil
+	}
+	freeBytes, totalBytes, err := this.ShowFreeDiskSpace()
+	if err != nil {
+		return fmt.Errorf("Cannot read free disk space via --disk-space-query: %+v", err)
+	}
+	for _, threshold := range []*base.DiskSpaceThreshold{this.migrationContext.MinFreeDiskSpace, this.migrationContext.CriticalFreeDiskSpace} {
+		if threshold == nil {
+			continue
+		}
+		if _, err := threshold.IsMet(freeBytes, totalBytes); err != nil {
+			return fmt.Errorf("Cannot evaluate free disk space threshold: %+v. The disk space query must return total bytes as a second column for percent thresholds", err)
+		}
+	}
+	this.migrationContext.Log.Infof("Free disk space on applier: %s; min-free-disk-space: %s; critical-free-disk-space: %s; query: %s",
+		base.DescribeFreeDiskSpace(freeBytes, totalBytes),
+		this.migrationContext.MinFreeDiskSpace,
+		this.migrationContext.CriticalFreeDiskSpace,
+		strings.Join(strings.Fields(this.migrationContext.DiskSpaceQuery), " "),
+	)
+	if threshold := this.migrationContext.CriticalFreeDiskSpace; threshold != nil {
+		if met, _ := threshold.IsMet(freeBytes, totalBytes); met {
+			return fmt.Errorf("Free disk space on applier is %s, below --critical-free-disk-space=%s", base.DescribeFreeDiskSpace(freeBytes, totalBytes), threshold)
+		}
+	}
+	return nil
+}
+
+// ShowFreeDiskSpace returns the free and, if known, total bytes of the applier's data directory disk
+func (this *Applier) ShowFreeDiskSpace() (freeBytes int64, totalBytes int64, err error) {
+	freeBytes, totalBytes, err = mysql.GetFreeDiskSpace(this.db, this.migrationContext.DiskSpaceQuery)
+	if err != nil {
+		return freeBytes, totalBytes, err
+	}
+	atomic.StoreInt64(&this.migrationContext.CurrentFreeDiskSpace, freeBytes)
+	atomic.StoreInt64(&this.migrationContext.CurrentTotalDiskSpace, totalBytes)
+	return freeBytes, totalBytes, nil
+}
+
 // validateAndReadTimeZone potentially reads server time-zone
 func (this *Applier) validateAndReadTimeZone() error {
-	query := `select @@global.time_zone`
-	if err := this.db.QueryRow(query).Scan(&this.migrationContext.ApplierTimeZone); err != nil {
+	query := `select @@global.time_zone, @@global.system_time_zone`
+	if err := this.db.QueryRow(query).Scan(&this.migrationContext.ApplierTimeZone, &this.migrationContext.ApplierSystemTimeZone); err != nil {
 		return err
 	}
 
-	this.migrationContext.Log.Infof("will use time_zone='%s' on applier", this.migrationContext.ApplierTimeZone)
+	this.migrationContext.Log.Infof("will convert DATETIME values in time_zone='%s' on applier; sessions run in time_zone='%s'", this.migrationContext.ApplierTimeZone, this.migrationContext.ConnectionTimeZone)
 	return nil
 }
-// generateSqlModeQuery return a `sql_mode = ...` query, to be wrapped with a `set session` or `set global`,
+
+// readMaxAllowedPacket reads the applier's max_allowed_packet, which bounds row copy chunks and coalesced DML statements
+func (this *Applier) readMaxAllowedPacket() error {
+	query := `select @@session.max_allowed_packet`
+	if err := this.db.QueryRow(query).Scan(&this.migrationContext.ApplierMaxAllowedPacket); err != nil {
+		return err
+	}
+	if this.migrationContext.MaxPacketBudget > this.migrationContext.ApplierMaxAllowedPacket {
+		return fmt.Errorf("--max-packet-budget (%d) exceeds max_allowed_packet (%d) on applier", this.migrationContext.MaxPacketBudget, this.migrationContext.ApplierMaxAllowedPacket)
+	}
+	this.migrationContext.Log.Infof("Applier max_allowed_packet is %d; packet budget is %d bytes", this.migrationContext.ApplierMaxAllowedPacket, this.migrationContext.GetPacketBudget())
+	return nil
+}
+
+// readSQLMode reads the effective sql_mode of applier connections
+func (this *Applier) readSQLMode() error {
+	query := `select @@session.sql_mode`
+	if err := this.db.QueryRow(query).Scan(&this.migrationContext.ApplierSQLMode); err != nil {
+		return err
+	}
+	return nil
+}
+
+// generateSqlMode returns the sql_mode expression applier connections run with,
 // based on gh-ost configuration:
+// - User may provide the sql_mode, otherwise the server's mode applies, in strict mode
 // - User may skip strict mode
 // - User may allow zero dats or zero in dates
-func (this *Applier) generateSqlModeQuery() string {
+func (this *Applier) generateSqlMode() string {
+	baseSqlMode := `@@session.sql_mode`
+	if this.migrationContext.SQLMode != "" {
+		baseSqlMode = fmt.Sprintf("'%s'", this.migrationContext.SQLMode)
+	}
 	sqlModeAddendum := []string{`NO_AUTO_VALUE_ON_ZERO`}
-	if !this.migrationContext.SkipStrictMode {
+	if !this.migrationContext.SkipStrictMode && this.migrationContext.SQLMode == "" {
 		sqlModeAddendum = append(sqlModeAddendum, `STRICT_ALL_TABLES`)
 	}
-	sqlModeQuery := fmt.Sprintf("CONCAT(@@session.sql_mode, ',%s')", strings.Join(sqlModeAddendum, ","))
+	sqlModeQuery := fmt.Sprintf("CONCAT(%s, ',%s')", baseSqlMode, strings.Join(sqlModeAddendum, ","))
 	if this.migrationContext.AllowZeroInDate {
 		sqlModeQuery = fmt.Sprintf("REPLACE(REPLACE(%s, 'NO_ZERO_IN_DATE', ''), 'NO_ZERO_DATE', '')", sqlModeQuery)
 	}
+	return sqlModeQuery
+}
 
-	return fmt.Sprintf("sql_mode = %s", sqlModeQuery)
+// generateSqlModeQuery return a `sql_mode = ...` query, to be wrapped with a `set session` or `set global`
+func (this *Applier) generateSqlModeQuery() string {
+	return fmt.Sprintf("sql_mode = %s", this.generateSqlMode())
 }
 
 // generateInstantDDLQuery returns the SQL for this ALTER operation
 // with an INSTANT assertion (requires MySQL 8.0+)
 func (this *Applier) generateInstantDDLQuery() string {
-	return fmt.Sprintf(`ALTER /* gh-ost */ TABLE %s.%s %s, ALGORITHM=INSTANT`,
+	return fmt.Sprintf(`ALTER %s TABLE %s.%s %s, ALGORITHM=INSTANT`,
+		this.migrationContext.GetDDLComment(),
 		sql.EscapeName(this.migrationContext.DatabaseName),
 		sql.EscapeName(this.migrationContext.OriginalTableName),
 		this.migrationContext.AlterStatementOptions,
@@ -154,8 +320,8 @@ func (this *Applier) readTableColumns() (err error) {
 }
 
 // showTableStatus returns the output of `show table status like '...'` command
-func (this *Applier) showTableStatus(tableName string) (rowMap sqlutils.RowMap) {
-	query := fmt.Sprintf(`show /* gh-ost */ table status from %s like '%s'`, sql.EscapeName(this.migrationContext.DatabaseName), tableName)
+func (this *Applier) showTableStatus(databaseName, tableName string) (rowMap sqlutils.RowMap) {
+	query := fmt.Sprintf(`show /* gh-ost */ table status from %s like '%s'`, sql.EscapeName(databaseName), tableName)
 	sqlutils.QueryRowsMap(this.db, query, func(m sqlutils.RowMap) error {
 		rowMap = m
 		return nil
@@ -163,22 +329,35 @@ func (this *Applier) showTableStatus(tableName string) (rowMap sqlutils.RowMap)
 	return rowMap
 }
 
-// tableExists checks if a given table exists in database
-func (this *Applier) tableExists(tableName string) (tableFound bool) {
-	m := this.showTableStatus(tableName)
+// tableExists checks if a given table exists in given database
+func (this *Applier) tableExists(databaseName, tableName string) (tableFound bool) {
+	m := this.showTableStatus(databaseName, tableName)
 	return (m != nil)
 }
 
 // ValidateOrDropExistingTables verifies ghost and changelog tables do not exist,
-// or attempts to drop them if instructed to.
+// or attempts to drop them if instructed to. With --resume, they must exist.
 func (this *Applier) ValidateOrDropExistingTables() error {
 	if this.migrationContext.InitiallyDropGhostTable {
 		if err := this.DropGhostTable(); err != nil {
 			return err
 		}
 	}
-	if this.tableExists(this.migrationContext.GetGhostTableName()) {
-		return fmt.Errorf("Table %s already exists. Panicking. Use --initially-drop-ghost-table to force dropping it, though I really prefer that you drop it or rename it away", sql.EscapeName(this.migrationContext.GetGhostTableName()))
+	if this.migrationContext.Resume {
+		if !this.tableExists(this.migrationContext.GetTargetDatabaseName(), this.migrationContext.GetGhostTableName()) {
+			return fmt.Errorf("--resume: table %s.%s not found on %s. There is no interrupted migration to resume", sql.EscapeName(this.migrationContext.GetTargetDatabaseName()), sql.EscapeName(this.migrationContext.GetGhostTableName()), this.connectionConfig.Key.String())
+		}
+		if !this.tableExists(this.migrationContext.DatabaseName, this.migrationContext.GetChangelogTableName()) {
+			return fmt.Errorf("--resume: table %s.%s not found on %s. There is no interrupted migration to resume", sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.GetChangelogTableName()), this.connectionConfig.Key.String())
+		}
+	} else if this.tableExists(this.migrationContext.GetTargetDatabaseName(), this.migrationContext.GetGhostTableName()) {
+		return fmt.Errorf("Table %s.%s already exists. Panicking. Use --initially-drop-ghost-table to force dropping it, though I really prefer that you drop it or rename it away", sql.EscapeName(this.migrationContext.GetTargetDatabaseName()), sql.EscapeName(this.migrationContext.GetGhostTableName()))
+	}
+	if this.migrationContext.IsCrossSchemaMigration() && !this.migrationContext.IsTableRenamed() && this.tableExists(this.migrationContext.GetTargetDatabaseName(), this.migrationContext.OriginalTableName) {
+		return fmt.Errorf("--target-database: table %s.%s already exists. The migrated table cannot be renamed onto it at cut-over", sql.EscapeName(this.migrationContext.GetTargetDatabaseName()), sql.EscapeName(this.migrationContext.OriginalTableName))
+	}
+	if this.migrationContext.IsTableRenamed() && this.tableExists(this.migrationContext.GetTargetDatabaseName(), this.migrationContext.GetMigratedTableName()) {
+		return fmt.Errorf("--new-table-name: table %s.%s already exists. The migrated table cannot be renamed onto it at cut-over", sql.EscapeName(this.migrationContext.GetTargetDatabaseName()), sql.EscapeName(this.migrationContext.GetMigratedTableName()))
 	}
 	if this.migrationContext.InitiallyDropOldTable {
 		if err := this.DropOldTable(); err != nil {
@@ -186,10 +365,10 @@ func (this *Applier) ValidateOrDropExistingTables() error {
 		}
 	}
 	if len(this.migrationContext.GetOldTableName()) > mysql.MaxTableNameLength {
-		this.migrationContext.Log.Fatalf("--timestamp-old-table defined, but resulting table name (%s) is too long (only %d characters allowed)", this.migrationContext.GetOldTableName(), mysql.MaxTableNameLength)
+		this.migrationContext.Log.Fatalf("Old table name (%s) is too long (only %d characters allowed)", this.migrationContext.GetOldTableName(), mysql.MaxTableNameLength)
 	}
 
-	if this.tableExists(this.migrationContext.GetOldTableName()) {
+	if this.tableExists(this.migrationContext.DatabaseName, this.migrationContext.GetOldTableName()) {
 		return fmt.Errorf("Table %s already exists. Panicking. Use --initially-drop-old-table to force dropping it, though I really prefer that you drop it or rename it away", sql.EscapeName(this.migrationContext.GetOldTableName()))
 	}
 
@@ -219,51 +398,112 @@ func (this *Applier) AttemptInstantDDL() error {
 
 // CreateGhostTable creates the ghost table on the applier host
 func (this *Applier) CreateGhostTable() error {
-	query := fmt.Sprintf(`create /* gh-ost */ table %s.%s like %s.%s`,
-		sql.EscapeName(this.migrationContext.DatabaseName),
+	query := fmt.Sprintf(`create %s table %s.%s like %s.%s`,
+		this.migrationContext.GetDDLComment(),
+		sql.EscapeName(this.migrationContext.GetTargetDatabaseName()),
 		sql.EscapeName(this.migrationContext.GetGhostTableName()),
 		sql.EscapeName(this.migrationContext.DatabaseName),
 		sql.EscapeName(this.migrationContext.OriginalTableName),
 	)
 	this.migrationContext.Log.Infof("Creating ghost table %s.%s",
-		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(this.migrationContext.GetTargetDatabaseName()),
 		sql.EscapeName(this.migrationContext.GetGhostTableName()),
 	)
 	if _, err := sqlutils.ExecNoPrepare(this.db, query); err != nil {
 		return err
 	}
+	if this.migrationContext.OriginalTableHasGIPK {
+		if err := this.ensureGhostGeneratedInvisiblePrimaryKey(); err != nil {
+			return err
+		}
+	}
+	this.dmlStatements.invalidate()
 	this.migrationContext.Log.Infof("Ghost table created")
 	return nil
 }
 
+// ensureGhostGeneratedInvisiblePrimaryKey regenerates the original table's generated invisible
+// primary key on the ghost table, should the ghost table have been created without it
+func (this *Applier) ensureGhostGeneratedInvisiblePrimaryKey() error {
+	ghostColumns, _, err := mysql.GetTableColumns(this.db, this.migrationContext.GetTargetDatabaseName(), this.migrationContext.GetGhostTableName())
+	if err != nil {
+		return err
+	}
+	if ghostColumns.GetColumn(mysql.GeneratedInvisiblePrimaryKeyColumnName) != nil {
+		return nil
+	}
+	query := fmt.Sprintf(`alter %s table %s.%s add column %s bigint unsigned not null auto_increment invisible primary key first`,
+		this.migrationContext.GetDDLComment(),
+		sql.EscapeName(this.migrationContext.GetTargetDatabaseName()),
+		sql.EscapeName(this.migrationContext.GetGhostTableName()),
+		sql.EscapeName(mysql.GeneratedInvisiblePrimaryKeyColumnName),
+	)
+	this.migrationContext.Log.Infof("Adding generated invisible primary key onto ghost table")
+	_, err = sqlutils.ExecNoPrepare(this.db, query)
+	return err
+}
+
 // AlterGhost applies `alter` statement on ghost table
 func (this *Applier) AlterGhost() error {
-	query := fmt.Sprintf(`alter /* gh-ost */ table %s.%s %s`,
-		sql.EscapeName(this.migrationContext.DatabaseName),
+	if strings.TrimSpace(this.migrationContext.AlterStatementOptions) == "" {
+		this.migrationContext.Log.Infof("No ALTER statement given: ghost table keeps the original table's structure")
+		return nil
+	}
+	query := fmt.Sprintf(`alter %s table %s.%s %s`,
+		this.migrationContext.GetDDLComment(),
+		sql.EscapeName(this.migrationContext.GetTargetDatabaseName()),
 		sql.EscapeName(this.migrationContext.GetGhostTableName()),
 		this.migrationContext.AlterStatementOptions,
 	)
 	this.migrationContext.Log.Infof("Altering ghost table %s.%s",
-		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(this.migrationContext.GetTargetDatabaseName()),
 		sql.EscapeName(this.migrationContext.GetGhostTableName()),
 	)
 	this.migrationContext.Log.Debugf("ALTER statement: %s", query)
 	if _, err := sqlutils.ExecNoPrepare(this.db, query); err != nil {
 		return err
 	}
+	this.dmlStatements.invalidate()
 	this.migrationContext.Log.Infof("Ghost table altered")
 	return nil
 }
 
+// AddSurrogateKeyColumn adds the --surrogate-key AUTO_INCREMENT column onto the ghost table, keying the
+// migrated table's rows
+func (this *Applier) AddSurrogateKeyColumn() error {
+	invisibleClause := ""
+	if this.migrationContext.SurrogateKeyInvisible {
+		invisibleClause = " invisible"
+	}
+	columnName := sql.EscapeName(this.migrationContext.SurrogateKeyColumnName)
+	query := fmt.Sprintf(`alter %s table %s.%s add column %s bigint unsigned not null auto_increment%s, add unique key %s (%s)`,
+		this.migrationContext.GetDDLComment(),
+		sql.EscapeName(this.migrationContext.GetTargetDatabaseName()),
+		sql.EscapeName(this.migrationContext.GetGhostTableName()),
+		columnName, invisibleClause, columnName, columnName,
+	)
+	this.migrationContext.Log.Infof("Adding surrogate key column %s onto ghost table %s.%s",
+		columnName,
+		sql.EscapeName(this.migrationContext.GetTargetDatabaseName()),
+		sql.EscapeName(this.migrationContext.GetGhostTableName()),
+	)
+	if _, err := sqlutils.ExecNoPrepare(this.db, query); err != nil {
+		return err
+	}
+	this.dmlStatements.invalidate()
+	return nil
+}
+
 // AlterGhost applies `alter` statement on ghost table
 func (this *Applier) AlterGhostAutoIncrement() error {
-	query := fmt.Sprintf(`alter /* gh-ost */ table %s.%s AUTO_INCREMENT=%d`,
-		sql.EscapeName(this.migrationContext.DatabaseName),
+	query := fmt.Sprintf(`alter %s table %s.%s AUTO_INCREMENT=%d`,
+		this.migrationContext.GetDDLComment(),
+		sql.EscapeName(this.migrationContext.GetTargetDatabaseName()),
 		sql.EscapeName(this.migrationContext.GetGhostTableName()),
 		this.migrationContext.OriginalTableAutoIncrement,
 	)
 	this.migrationContext.Log.Infof("Altering ghost table AUTO_INCREMENT value %s.%s",
-		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(this.migrationContext.GetTargetDatabaseName()),
 		sql.EscapeName(this.migrationContext.GetGhostTableName()),
 	)
 	this.migrationContext.Log.Debugf("AUTO_INCREMENT ALTER statement: %s", query)
@@ -274,6 +514,341 @@ func (this *Applier) AlterGhostAutoIncrement() error {
 	return nil
 }
 
+// getAutoIncrementColumnName returns the name of a table's AUTO_INCREMENT column, or empty when it has none
+func (this *Applier) getAutoIncrementColumnName(databaseName, tableName string) (columnName string, err error) {
+	query := `
+		SELECT
+			COLUMN_NAME
+		FROM INFORMATION_SCHEMA.COLUMNS
+		WHERE
+			TABLE_SCHEMA = ?
+			AND TABLE_NAME = ?
+			AND LOCATE('auto_increment', EXTRA) > 0
+	`
+	err = sqlutils.QueryRowsMap(this.db, query, func(m sqlutils.RowMap) error {
+		columnName = m.GetString("COLUMN_NAME")
+		return nil
+	}, databaseName, tableName)
+	return columnName, err
+}
+
+// showCreateTable returns a table's SHOW CREATE TABLE statement. A table's current AUTO_INCREMENT counter is
+// read off it, since INFORMATION_SCHEMA.TABLES may serve a cached value (see information_schema_stats_expiry)
+func (this *Applier) showCreateTable(databaseName, tableName string) (createTableStatement string, err error) {
+	var dummy string
+	query := fmt.Sprintf(`show /* gh-ost */ create table %s.%s`, sql.EscapeName(databaseName), sql.EscapeName(tableName))
+	err = this.db.QueryRow(query).Scan(&dummy, &createTableStatement)
+	return createTableStatement, err
+}
+
+// getGhostAutoIncrementSync returns the AUTO_INCREMENT value to set the ghost table to, given the original and
+// ghost tables' SHOW CREATE TABLE statements, or 0 when the ghost table's counter is not behind the original's
+func getGhostAutoIncrementSync(originalCreateTableStatement, ghostCreateTableStatement string) uint64 {
+	originalAutoIncrement := sql.ParseCreateTableAutoIncrement(originalCreateTableStatement)
+	if sql.ParseCreateTableAutoIncrement(ghostCreateTableStatement) >= originalAutoIncrement {
+		return 0
+	}
+	return originalAutoIncrement
+}
+
+// SyncGhostAutoIncrement bumps the ghost table's AUTO_INCREMENT up to the original table's. Rows
+// inserted then deleted on the original table advance its counter, but never reach the ghost table.
+// This takes place within the cut-over lock, where the original table's counter no longer moves.
+func (this *Applier) SyncGhostAutoIncrement() error {
+	originalColumnName, err := this.getAutoIncrementColumnName(this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName)
+	if err != nil {
+		return err
+	}
+	if originalColumnName == "" {
+		return nil
+	}
+	ghostColumnName, err := this.getAutoIncrementColumnName(this.migrationContext.GetTargetDatabaseName(), this.migrationContext.GetGhostTableName())
+	if err != nil {
+		return err
+	}
+	expectedGhostColumnName := originalColumnName
+	if mappedName, ok := this.migrationContext.ColumnRenameMap[originalColumnName]; ok {
+		expectedGhostColumnName = mappedName
+	}
+	if !strings.EqualFold(ghostColumnName, expectedGhostColumnName) {
+		// The ALTER drops the AUTO_INCREMENT column, or makes another column AUTO_INCREMENT:
+		// the original table's counter does not apply to the ghost table's
+		this.migrationContext.Log.Infof("AUTO_INCREMENT column %s is not the ghost table's AUTO_INCREMENT column; not syncing AUTO_INCREMENT", sql.EscapeName(originalColumnName))
+		return nil
+	}
+	originalCreateTableStatement, err := this.showCreateTable(this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName)
+	if err != nil {
+		return err
+	}
+	ghostCreateTableStatement, err := this.showCreateTable(this.migrationContext.GetTargetDatabaseName(), this.migrationContext.GetGhostTableName())
+	if err != nil {
+		return err
+	}
+	this.migrationContext.Log.Infof("AUTO_INCREMENT values: original table: %d, ghost table: %d", sql.ParseCreateTableAutoIncrement(originalCreateTableStatement), sql.ParseCreateTableAutoIncrement(ghostCreateTableStatement))
+	originalAutoIncrement := getGhostAutoIncrementSync(originalCreateTableStatement, ghostCreateTableStatement)
+	if originalAutoIncrement == 0 {
+		return nil
+	}
+	query := fmt.Sprintf(`alter %s table %s.%s AUTO_INCREMENT=%d`,
+		this.migrationContext.GetDDLComment(),
+		sql.EscapeName(this.migrationContext.GetTargetDatabaseName()),
+		sql.EscapeName(this.migrationContext.GetGhostTableName()),
+		originalAutoIncrement,
+	)
+	this.migrationContext.Log.Infof("Syncing ghost table AUTO_INCREMENT up to %d", originalAutoIncrement)
+	if _, err := sqlutils.ExecNoPrepare(this.db, query); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RenameGhostCheckConstraints renames the ghost table's CHECK constraints onto the original constraints'
+// ghost names, as noted by the inspector. The ghost table is yet empty, and so re-adding the constraints
+// validates no rows.
+func (this *Applier) RenameGhostCheckConstraints() error {
+	if len(this.migrationContext.GhostCheckConstraintRenames) == 0 {
+		return nil
+	}
+	query, err := sql.BuildRenameCheckConstraintsQuery(this.migrationContext.GetTargetDatabaseName(), this.migrationContext.GetGhostTableName(), this.migrationContext.GhostTableCheckConstraints, this.migrationContext.GhostCheckConstraintRenames)
+	if err != nil {
+		return err
+	}
+	this.migrationContext.Log.Infof("Renaming CHECK constraints on ghost table %s.%s",
+		sql.EscapeName(this.migrationContext.GetTargetDatabaseName()),
+		sql.EscapeName(this.migrationContext.GetGhostTableName()),
+	)
+	this.migrationContext.Log.Debugf("CHECK constraints ALTER statement: %s", query)
+	if _, err := sqlutils.ExecNoPrepare(this.db, query); err != nil {
+		return err
+	}
+	for _, checkConstraint := range this.migrationContext.GhostTableCheckConstraints {
+		if newName, ok := this.migrationContext.GhostCheckConstraintRenames[checkConstraint.Name]; ok {
+			checkConstraint.Name = newName
+		}
+	}
+	this.migrationContext.Log.Infof("Ghost table CHECK constraints renamed")
+	return nil
+}
+
+// CreateGhostForeignKeys creates the original table's foreign keys onto the ghost table, under their
+// ghost names. Foreign key checks are disabled: rows are not validated against the parent tables,
+// and the statement only changes metadata.
+func (this *Applier) CreateGhostForeignKeys() error {
+	query, err := sql.BuildAddForeignKeysQuery(this.migrationContext.GetTargetDatabaseName(), this.migrationContext.GetGhostTableName(), this.migrationContext.OriginalTableForeignKeys, this.migrationContext.ColumnRenameMap)
+	if err != nil {
+		return err
+	}
+	this.migrationContext.Log.Infof("Creating foreign keys on ghost table %s.%s",
+		sql.EscapeName(this.migrationContext.GetTargetDatabaseName()),
+		sql.EscapeName(this.migrationContext.GetGhostTableName()),
+	)
+	this.migrationContext.Log.Debugf("Foreign keys ALTER statement: %s", query)
+	if err := this.execWithoutForeignKeyChecks(query); err != nil {
+		return err
+	}
+	this.dmlStatements.invalidate()
+	this.migrationContext.Log.Infof("Ghost table foreign keys created")
+	return nil
+}
+
+// DropOldTableForeignKeys drops all foreign keys off the old table, once cut-over is complete. As long
+// as they exist, the parent tables may not delete nor update rows still referenced by the old table.
+// Constraint names are read anew, as renaming a table may rename its generated constraint names.
+func (this *Applier) DropOldTableForeignKeys() error {
+	query := `
+		SELECT CONSTRAINT_NAME
+			FROM INFORMATION_SCHEMA.REFERENTIAL_CONSTRAINTS
+			WHERE
+				CONSTRAINT_SCHEMA=?
+				AND TABLE_NAME=?
+	`
+	constraintNames := []string{}
+	err := sqlutils.QueryRowsMap(this.db, query, func(m sqlutils.RowMap) error {
+		constraintNames = append(constraintNames, m.GetString("CONSTRAINT_NAME"))
+		return nil
+	}, this.migrationContext.DatabaseName, this.migrationContext.GetOldTableName())
+	if err != nil {
+		return err
+	}
+	if len(constraintNames) == 0 {
+		return nil
+	}
+	dropQuery, err := sql.BuildDropForeignKeysQuery(this.migrationContext.DatabaseName, this.migrationContext.GetOldTableName(), constraintNames)
+	if err != nil {
+		return err
+	}
+	this.migrationContext.Log.Infof("Dropping foreign keys off old table %s.%s: %s",
+		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(this.migrationContext.GetOldTableName()),
+		strings.Join(constraintNames, ", "),
+	)
+	if err := this.execWithoutForeignKeyChecks(dropQuery); err != nil {
+		return err
+	}
+	this.migrationContext.Log.Infof("Old table foreign keys dropped")
+	return nil
+}
+
+// CreateGhostTriggers creates the original table's triggers onto the ghost table, under their ghost
+// names. It runs within the cut-over lock, once all events are applied: writes applied onto the ghost
+// table by gh-ost would otherwise fire the triggers a second time.
+func (this *Applier) CreateGhostTriggers() error {
+	ctx := context.Background()
+	conn, err := this.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	restoreSQLMode, err := this.saveSessionSQLMode(conn)
+	if err != nil {
+		return err
+	}
+	defer restoreSQLMode()
+	for _, trigger := range this.migrationContext.OriginalTableTriggers {
+		this.migrationContext.Log.Infof("Creating trigger %s on ghost table %s.%s", sql.EscapeName(trigger.GhostName()), sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.GetGhostTableName()))
+		if err := this.execTriggerQueries(conn, trigger,
+			sql.BuildDropTriggerQuery(this.migrationContext.DatabaseName, trigger.GhostName()),
+			sql.BuildCreateTriggerQuery(this.migrationContext.DatabaseName, this.migrationContext.GetGhostTableName(), trigger.GhostName(), trigger),
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RenameMigratedTableTriggers gives the triggers their original names back, following cut-over and
+// once the old table's triggers are dropped. There is no RENAME TRIGGER: each trigger is created
+// anew, and its ghost named counterpart dropped, while the migrated table is locked, such that no
+// write fires both or neither.
+func (this *Applier) RenameMigratedTableTriggers() error {
+	ctx := context.Background()
+	conn, err := this.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var lockWaitTimeout int64
+	if err := conn.QueryRowContext(ctx, `select @@session.lock_wait_timeout`).Scan(&lockWaitTimeout); err != nil {
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, `set session lock_wait_timeout:=?`, this.migrationContext.CutOverLockTimeoutSeconds); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, `set session lock_wait_timeout:=?`, lockWaitTimeout)
+	restoreSQLMode, err := this.saveSessionSQLMode(conn)
+	if err != nil {
+		return err
+	}
+	defer restoreSQLMode()
+
+	query := fmt.Sprintf(`lock /* gh-ost */ tables %s.%s write`,
+		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(this.migrationContext.GetMigratedTableName()),
+	)
+	this.migrationContext.Log.Infof("Locking %s.%s to rename its triggers",
+		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(this.migrationContext.GetMigratedTableName()),
+	)
+	if _, err := conn.ExecContext(ctx, query); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, `unlock tables`)
+	for _, trigger := range this.migrationContext.OriginalTableTriggers {
+		this.migrationContext.Log.Infof("Renaming trigger %s to %s", sql.EscapeName(trigger.GhostName()), sql.EscapeName(trigger.Name))
+		if err := this.execTriggerQueries(conn, trigger,
+			// A prior attempt may have created the trigger already
+			sql.BuildDropTriggerQuery(this.migrationContext.DatabaseName, trigger.Name),
+			sql.BuildCreateTriggerQuery(this.migrationContext.DatabaseName, this.migrationContext.GetMigratedTableName(), trigger.Name, trigger),
+			sql.BuildDropTriggerQuery(this.migrationContext.DatabaseName, trigger.GhostName()),
+		); err != nil {
+			return err
+		}
+	}
+	this.migrationContext.Log.Infof("Triggers renamed")
+	return nil
+}
+
+// DropGhostTriggers drops any triggers off the ghost table, following a failed cut-over
+func (this *Applier) DropGhostTriggers() error {
+	return this.dropTableTriggers(this.migrationContext.GetGhostTableName())
+}
+
+// DropOldTableTriggers drops the triggers off the old table following cut-over, freeing their names
+func (this *Applier) DropOldTableTriggers() error {
+	return this.dropTableTriggers(this.migrationContext.GetOldTableName())
+}
+
+// dropTableTriggers drops the triggers found on the given table. Trigger names are read anew, as
+// names are per schema, and a trigger of a given name may since be found on another table.
+func (this *Applier) dropTableTriggers(tableName string) error {
+	query := `
+		SELECT TRIGGER_NAME
+			FROM INFORMATION_SCHEMA.TRIGGERS
+			WHERE
+				TRIGGER_SCHEMA=?
+				AND EVENT_OBJECT_TABLE=?
+	`
+	triggerNames := []string{}
+	err := sqlutils.QueryRowsMap(this.db, query, func(m sqlutils.RowMap) error {
+		triggerNames = append(triggerNames, m.GetString("TRIGGER_NAME"))
+		return nil
+	}, this.migrationContext.DatabaseName, tableName)
+	if err != nil {
+		return err
+	}
+	for _, triggerName := range triggerNames {
+		this.migrationContext.Log.Infof("Dropping trigger %s off %s.%s", sql.EscapeName(triggerName), sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(tableName))
+		if _, err := sqlutils.ExecNoPrepare(this.db, sql.BuildDropTriggerQuery(this.migrationContext.DatabaseName, triggerName)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveSessionSQLMode returns a function restoring the connection's current sql_mode
+func (this *Applier) saveSessionSQLMode(conn *gosql.Conn) (restore func(), err error) {
+	var sqlMode string
+	if err := conn.QueryRowContext(context.Background(), `select @@session.sql_mode`).Scan(&sqlMode); err != nil {
+		return nil, err
+	}
+	return func() {
+		conn.ExecContext(context.Background(), `set session sql_mode = ?`, sqlMode)
+	}, nil
+}
+
+// execTriggerQueries executes queries creating or dropping a trigger, with the sql_mode the trigger
+// was originally created with: a trigger's body runs with its creation time sql_mode.
+func (this *Applier) execTriggerQueries(conn *gosql.Conn, trigger *sql.Trigger, queries ...string) error {
+	if _, err := conn.ExecContext(context.Background(), `set session sql_mode = ?`, trigger.SQLMode); err != nil {
+		return err
+	}
+	for _, query := range queries {
+		this.migrationContext.Log.Debugf("Trigger statement: %s", query)
+		if _, err := conn.ExecContext(context.Background(), query); err != nil {
+			return fmt.Errorf("%w; query=%s", err, query)
+		}
+	}
+	return nil
+}
+
+// execWithoutForeignKeyChecks executes a query in a session with foreign_key_checks disabled
+func (this *Applier) execWithoutForeignKeyChecks(query string) error {
+	conn, err := this.db.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(context.Background(), `set /* gh-ost */ session foreign_key_checks = 0`); err != nil {
+		return err
+	}
+	// The connection returns to the pool
+	defer conn.ExecContext(context.Background(), `set /* gh-ost */ session foreign_key_checks = 1`)
+	_, err = conn.ExecContext(context.Background(), query)
+	return err
+}
+
 // CreateChangelogTable creates the changelog table on the applier host
 func (this *Applier) CreateChangelogTable() error {
 	if err := this.DropChangelogTable(); err != nil {
@@ -303,13 +878,13 @@ func (this *Applier) CreateChangelogTable() error {
 }
 
 // dropTable drops a given table on the applied host
-func (this *Applier) dropTable(tableName string) error {
+func (this *Applier) dropTable(databaseName, tableName string) error {
 	query := fmt.Sprintf(`drop /* gh-ost */ table if exists %s.%s`,
-		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(databaseName),
 		sql.EscapeName(tableName),
 	)
 	this.migrationContext.Log.Infof("Dropping table %s.%s",
-		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(databaseName),
 		sql.EscapeName(tableName),
 	)
 	if _, err := sqlutils.ExecNoPrepare(this.db, query); err != nil {
@@ -321,17 +896,55 @@ func (this *Applier) dropTable(tableName string) error {
 
 // DropChangelogTable drops the changelog table on the applier host
 func (this *Applier) DropChangelogTable() error {
-	return this.dropTable(this.migrationContext.GetChangelogTableName())
+	return this.dropTable(this.migrationContext.DatabaseName, this.migrationContext.GetChangelogTableName())
 }
 
 // DropOldTable drops the _Old table on the applier host
 func (this *Applier) DropOldTable() error {
-	return this.dropTable(this.migrationContext.GetOldTableName())
+	return this.dropTable(this.migrationContext.DatabaseName, this.migrationContext.GetOldTableName())
+}
+
+// DropScheduledOldTable drops the old table of a --drop-old-table-after scheduled drop. With a scratch database,
+// the table is first renamed into it, out of the way of the migrated database, then dropped from there.
+func (this *Applier) DropScheduledOldTable(drop *ScheduledDrop) error {
+	if drop.ScratchDatabaseName == "" {
+		return this.dropTable(drop.DatabaseName, drop.TableName)
+	}
+	if this.tableExists(drop.DatabaseName, drop.TableName) {
+		query := fmt.Sprintf(`rename /* gh-ost */ table %s.%s to %s.%s`,
+			sql.EscapeName(drop.DatabaseName),
+			sql.EscapeName(drop.TableName),
+			sql.EscapeName(drop.ScratchDatabaseName),
+			sql.EscapeName(drop.TableName),
+		)
+		this.migrationContext.Log.Infof("Renaming old table into scratch database %s", sql.EscapeName(drop.ScratchDatabaseName))
+		if _, err := sqlutils.ExecNoPrepare(this.db, query); err != nil {
+			return err
+		}
+	}
+	return this.dropTable(drop.ScratchDatabaseName, drop.TableName)
 }
 
 // DropGhostTable drops the ghost table on the applier host
 func (this *Applier) DropGhostTable() error {
-	return this.dropTable(this.migrationContext.GetGhostTableName())
+	this.dmlStatements.invalidate()
+	return this.dropTable(this.migrationContext.GetTargetDatabaseName(), this.migrationContext.GetGhostTableName())
+}
+
+// ReadChangelog reads the value of a given hint off the changelog table; empty when not found
+func (this *Applier) ReadChangelog(hint string) (string, error) {
+	query := fmt.Sprintf(`
+		select /* gh-ost */ value from %s.%s where hint = ?
+		`,
+		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(this.migrationContext.GetChangelogTableName()),
+	)
+	result := ""
+	err := sqlutils.QueryRowsMap(this.db, query, func(m sqlutils.RowMap) error {
+		result = m.GetString("value")
+		return nil
+	}, hint)
+	return result, err
 }
 
 // WriteChangelog writes a value to the changelog table.
@@ -345,6 +958,10 @@ func (this *Applier) WriteChangelog(hint, value string) (string, error) {
 		explicitId = 2
 	case "throttle":
 		explicitId = 3
+	case checkpointChangelogHint:
+		explicitId = 4
+	case scheduledDropChangelogHint:
+		explicitId = 5
 	}
 	query := fmt.Sprintf(`
 			insert /* gh-ost */ into %s.%s
@@ -425,7 +1042,7 @@ func (this *Applier) ExecuteThrottleQuery() (int64, error) {
 // ReadMigrationMinValues returns the minimum values to be iterated on rowcopy
 func (this *Applier) ReadMigrationMinValues(uniqueKey *sql.UniqueKey) error {
 	this.migrationContext.Log.Debugf("Reading migration range according to key: %s", uniqueKey.Name)
-	query, err := sql.BuildUniqueKeyMinValuesPreparedQuery(this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName, &uniqueKey.Columns)
+	query, err := sql.BuildUniqueKeyMinValuesPreparedQuery(this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName, this.migrationContext.GetMigrationRangePartitionName(), uniqueKey.Name, &uniqueKey.Columns)
 	if err != nil {
 		return err
 	}
@@ -441,6 +1058,7 @@ func (this *Applier) ReadMigrationMinValues(uniqueKey *sql.UniqueKey) error {
 		if err = rows.Scan(this.migrationContext.MigrationRangeMinValues.ValuesPointers...); err != nil {
 			return err
 		}
+		this.migrationContext.MigrationRangeMinValues.ApplyUnsigned(&uniqueKey.Columns)
 	}
 	this.migrationContext.Log.Infof("Migration min values: [%s]", this.migrationContext.MigrationRangeMinValues)
 
@@ -450,7 +1068,7 @@ func (this *Applier) ReadMigrationMinValues(uniqueKey *sql.UniqueKey) error {
 // ReadMigrationMaxValues returns the maximum values to be iterated on rowcopy
 func (this *Applier) ReadMigrationMaxValues(uniqueKey *sql.UniqueKey) error {
 	this.migrationContext.Log.Debugf("Reading migration range according to key: %s", uniqueKey.Name)
-	query, err := sql.BuildUniqueKeyMaxValuesPreparedQuery(this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName, &uniqueKey.Columns)
+	query, err := sql.BuildUniqueKeyMaxValuesPreparedQuery(this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName, this.migrationContext.GetMigrationRangePartitionName(), uniqueKey.Name, &uniqueKey.Columns)
 	if err != nil {
 		return err
 	}
@@ -466,6 +1084,7 @@ func (this *Applier) ReadMigrationMaxValues(uniqueKey *sql.UniqueKey) error {
 		if err = rows.Scan(this.migrationContext.MigrationRangeMaxValues.ValuesPointers...); err != nil {
 			return err
 		}
+		this.migrationContext.MigrationRangeMaxValues.ApplyUnsigned(&uniqueKey.Columns)
 	}
 	this.migrationContext.Log.Infof("Migration max values: [%s]", this.migrationContext.MigrationRangeMaxValues)
 
@@ -494,15 +1113,97 @@ func (this *Applier) ReadMigrationRangeValues() error {
 		return err
 	}
 
+	if len(this.migrationContext.OriginalTablePartitions) > 0 {
+		_, err := this.ReadNextPartitionRangeValues()
+		return err
+	}
+	if err := this.ReadMigrationMinValues(this.migrationContext.UniqueKey); err != nil {
+		return err
+	}
+	if err := this.ReadMigrationMaxValues(this.migrationContext.UniqueKey); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ResumeMigrationRangeValues reads min/max values for rowcopy as ReadMigrationRangeValues does, and
+// positions the range iteration past the rows copied as of given checkpoint
+func (this *Applier) ResumeMigrationRangeValues(checkpoint *Checkpoint) error {
+	if _, err := this.WriteChangelogState(string(ReadMigrationRangeValues)); err != nil {
+		return err
+	}
+	atomic.StoreInt64(&this.migrationContext.Iteration, checkpoint.Iteration)
+	atomic.StoreInt64(&this.migrationContext.TotalRowsCopied, checkpoint.RowsCopied)
+	if len(this.migrationContext.OriginalTablePartitions) > 0 {
+		for _, partition := range this.migrationContext.OriginalTablePartitions {
+			if partition.Name == checkpoint.Partition {
+				this.migrationContext.MigrationRangePartition = partition
+			}
+		}
+		if this.migrationContext.MigrationRangePartition == nil {
+			return fmt.Errorf("--resume: partition %s of checkpoint not found", sql.EscapeName(checkpoint.Partition))
+		}
+		this.migrationContext.Log.Infof("Reading migration range of partition %s", sql.EscapeName(checkpoint.Partition))
+	}
 	if err := this.ReadMigrationMinValues(this.migrationContext.UniqueKey); err != nil {
 		return err
 	}
 	if err := this.ReadMigrationMaxValues(this.migrationContext.UniqueKey); err != nil {
 		return err
 	}
+	if len(this.migrationContext.OriginalTablePartitions) > 0 && !this.migrationContext.HasMigrationRange() {
+		// The partition has since been emptied
+		_, err := this.ReadNextPartitionRangeValues()
+		return err
+	}
+	// The next chunk begins right after the checkpoint's range end
+	atomic.StoreInt64(&this.migrationContext.MigrationRangeStartIteration, checkpoint.RangeStartIteration)
+	this.migrationContext.MigrationIterationRangeMaxValues = checkpoint.GetRangeEndValues()
+	this.migrationContext.MigrationIterationRangeMaxValues.ApplyUnsigned(&this.migrationContext.UniqueKey.Columns)
+	this.migrationContext.Log.Infof("Row copy resumes after [%s]", this.migrationContext.MigrationIterationRangeMaxValues)
 	return nil
 }
 
+// ReadNextPartitionRangeValues moves row copy onto the next non-empty partition, reading its min/max values,
+// and resets the range iteration. It returns "false" if there is no further partition to copy.
+func (this *Applier) ReadNextPartitionRangeValues() (hasFurtherPartition bool, err error) {
+	partitions := this.migrationContext.OriginalTablePartitions
+	if this.migrationContext.IsDescendingCopyOrder() {
+		// Partitions are copied last to first
+		partitions = make([](*sql.Partition), len(this.migrationContext.OriginalTablePartitions))
+		for i, partition := range this.migrationContext.OriginalTablePartitions {
+			partitions[len(partitions)-1-i] = partition
+		}
+	}
+	nextIndex := 0
+	for i, partition := range partitions {
+		if partition == this.migrationContext.MigrationRangePartition {
+			nextIndex = i + 1
+		}
+	}
+	for _, partition := range partitions[nextIndex:] {
+		this.migrationContext.MigrationRangePartition = partition
+		this.migrationContext.MigrationRangeMinValues = nil
+		this.migrationContext.MigrationRangeMaxValues = nil
+		this.migrationContext.MigrationIterationRangeMinValues = nil
+		this.migrationContext.MigrationIterationRangeMaxValues = nil
+		atomic.StoreInt64(&this.migrationContext.MigrationRangeStartIteration, this.migrationContext.GetIteration())
+
+		this.migrationContext.Log.Infof("Reading migration range of partition %s", sql.EscapeName(partition.Name))
+		if err := this.ReadMigrationMinValues(this.migrationContext.UniqueKey); err != nil {
+			return false, err
+		}
+		if err := this.ReadMigrationMaxValues(this.migrationContext.UniqueKey); err != nil {
+			return false, err
+		}
+		if this.migrationContext.HasMigrationRange() {
+			return true, nil
+		}
+		this.migrationContext.Log.Infof("Partition %s is empty", sql.EscapeName(partition.Name))
+	}
+	return false, nil
+}
+
 // CalculateNextIterationRangeEndValues reads the next-iteration-range-end unique key values,
 // which will be used for copying the next chunk of rows. Ir returns "false" if there is
 // no further chunk to work through, i.e. we're past the last chunk and are done with
@@ -510,7 +1211,7 @@ func (this *Applier) ReadMigrationRangeValues() error {
 func (this *Applier) CalculateNextIterationRangeEndValues() (hasFurtherRange bool, err error) {
 	this.migrationContext.MigrationIterationRangeMinValues = this.migrationContext.MigrationIterationRangeMaxValues
 	if this.migrationContext.MigrationIterationRangeMinValues == nil {
-		this.migrationContext.MigrationIterationRangeMinValues = this.migrationContext.MigrationRangeMinValues
+		this.migrationContext.MigrationIterationRangeMinValues = this.migrationContext.GetMigrationRangeStartValues()
 	}
 	for i := 0; i < 2; i++ {
 		buildFunc := sql.BuildUniqueKeyRangeEndPreparedQueryViaOffset
@@ -520,11 +1221,14 @@ func (this *Applier) CalculateNextIterationRangeEndValues() (hasFurtherRange boo
 		query, explodedArgs, err := buildFunc(
 			this.migrationContext.DatabaseName,
 			this.migrationContext.OriginalTableName,
+			this.migrationContext.GetMigrationRangePartitionName(),
+			this.migrationContext.UniqueKey.Name,
 			&this.migrationContext.UniqueKey.Columns,
 			this.migrationContext.MigrationIterationRangeMinValues.AbstractValues(),
-			this.migrationContext.MigrationRangeMaxValues.AbstractValues(),
-			atomic.LoadInt64(&this.migrationContext.ChunkSize),
-			this.migrationContext.GetIteration() == 0,
+			this.migrationContext.GetMigrationRangeEndValues().AbstractValues(),
+			this.migrationContext.GetPacketBoundChunkSize(),
+			this.migrationContext.IsFirstRangeIteration(),
+			this.migrationContext.CopyOrder,
 			fmt.Sprintf("iteration:%d", this.migrationContext.GetIteration()),
 		)
 		if err != nil {
@@ -537,68 +1241,252 @@ func (this *Applier) CalculateNextIterationRangeEndValues() (hasFurtherRange boo
 		}
 		defer rows.Close()
 
-		iterationRangeMaxValues := sql.NewColumnValues(this.migrationContext.UniqueKey.Len())
-		for rows.Next() {
-			if err = rows.Scan(iterationRangeMaxValues.ValuesPointers...); err != nil {
-				return hasFurtherRange, err
-			}
-			hasFurtherRange = true
-		}
-		if err = rows.Err(); err != nil {
-			return hasFurtherRange, err
-		}
-		if hasFurtherRange {
-			this.migrationContext.MigrationIterationRangeMaxValues = iterationRangeMaxValues
-			return hasFurtherRange, nil
+		iterationRangeMaxValues := sql.NewColumnValues(this.migrationContext.UniqueKey.Len())
+		for rows.Next() {
+			if err = rows.Scan(iterationRangeMaxValues.ValuesPointers...); err != nil {
+				return hasFurtherRange, err
+			}
+			hasFurtherRange = true
+		}
+		if err = rows.Err(); err != nil {
+			return hasFurtherRange, err
+		}
+		if hasFurtherRange {
+			iterationRangeMaxValues.ApplyUnsigned(&this.migrationContext.UniqueKey.Columns)
+			this.migrationContext.MigrationIterationRangeMaxValues = iterationRangeMaxValues
+			return hasFurtherRange, nil
+		}
+	}
+	this.migrationContext.Log.Debugf("Iteration complete: no further range to iterate")
+	return hasFurtherRange, nil
+}
+
+// ApplyIterationInsertQuery issues a chunk-INSERT query on the ghost table. It is where
+// data actually gets copied from original table.
+// Unless failing on conflicts, the chunk's rows are first counted in the same transaction, such that
+// rows found on the ghost table, and ignored or replaced, are accounted for.
+// ChunkChecksumMismatchError tells a row copy chunk checksums differently on the ghost table than on the
+// original table, per --verify-chunk-checksums. The chunk is nonetheless copied.
+type ChunkChecksumMismatchError struct {
+	UniqueKey        string
+	RangeMinValues   *sql.ColumnValues
+	RangeMaxValues   *sql.ColumnValues
+	OriginalRows     int64
+	OriginalChecksum uint64
+	GhostRows        int64
+	GhostChecksum    uint64
+}
+
+func (this *ChunkChecksumMismatchError) Error() string {
+	return fmt.Sprintf("Chunk checksum mismatch on %s range [%s]..[%s]: original table: %d rows, checksum %d; ghost table: %d rows, checksum %d",
+		this.UniqueKey, this.RangeMinValues, this.RangeMaxValues, this.OriginalRows, this.OriginalChecksum, this.GhostRows, this.GhostChecksum)
+}
+
+// buildIterationChecksumQueries builds the queries checksumming the current row copy chunk on the original
+// and on the ghost table
+func (this *Applier) buildIterationChecksumQueries() (originalQuery string, ghostQuery string, explodedArgs []interface{}, err error) {
+	originalExpressions, ghostExpressions, err := sql.BuildChecksumColumnExpressions(this.migrationContext.SharedColumns, this.migrationContext.MappedSharedColumns)
+	if err != nil {
+		return originalQuery, ghostQuery, explodedArgs, err
+	}
+	originalQuery, explodedArgs, err = sql.BuildRangeChecksumPreparedQuery(
+		this.migrationContext.DatabaseName,
+		this.migrationContext.OriginalTableName,
+		this.migrationContext.GetMigrationRangePartitionName(),
+		this.migrationContext.UniqueKey.Name,
+		&this.migrationContext.UniqueKey.Columns,
+		originalExpressions,
+		this.migrationContext.MigrationIterationRangeMinValues.AbstractValues(),
+		this.migrationContext.MigrationIterationRangeMaxValues.AbstractValues(),
+		this.migrationContext.IsFirstRangeIteration(),
+		this.migrationContext.CopyOrder,
+		this.migrationContext.IsTransactionalTable(),
+		this.migrationContext.RowFilter,
+	)
+	if err != nil {
+		return originalQuery, ghostQuery, explodedArgs, err
+	}
+	// The ghost table's partitions and keys are of its own. Per --row-filter, it only holds matching rows
+	ghostQuery, _, err = sql.BuildRangeChecksumPreparedQuery(
+		this.migrationContext.GetTargetDatabaseName(),
+		this.migrationContext.GetGhostTableName(),
+		"",
+		"",
+		&this.migrationContext.UniqueKey.Columns,
+		ghostExpressions,
+		this.migrationContext.MigrationIterationRangeMinValues.AbstractValues(),
+		this.migrationContext.MigrationIterationRangeMaxValues.AbstractValues(),
+		this.migrationContext.IsFirstRangeIteration(),
+		this.migrationContext.CopyOrder,
+		this.migrationContext.IsTransactionalTable(),
+		"",
+	)
+	return originalQuery, ghostQuery, explodedArgs, err
+}
+
+// checkIterationCheckConstraints looks for a row of the copied chunk which violates a CHECK constraint newly
+// enforced by the ghost table. Row copy would otherwise skip such a row, or fail without telling which.
+func (this *Applier) checkIterationCheckConstraints(tx *gosql.Tx, violationQuery string, violationExplodedArgs []interface{}) error {
+	uniqueKeyValues := sql.NewColumnValues(this.migrationContext.UniqueKey.Len())
+	violations := make([]bool, len(this.migrationContext.NewCheckConstraints))
+	scanArgs := uniqueKeyValues.ValuesPointers
+	for i := range violations {
+		scanArgs = append(scanArgs, &violations[i])
+	}
+	err := tx.QueryRow(violationQuery, violationExplodedArgs...).Scan(scanArgs...)
+	if err == gosql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	violatedConstraints := []string{}
+	for i, checkConstraint := range this.migrationContext.NewCheckConstraints {
+		if violations[i] {
+			violatedConstraints = append(violatedConstraints, checkConstraint.String())
 		}
 	}
-	this.migrationContext.Log.Debugf("Iteration complete: no further range to iterate")
-	return hasFurtherRange, nil
+	return fmt.Errorf("Row with %s (%s) = (%s) violates CHECK constraint %s, newly enforced on the ghost table. Bailing out",
+		this.migrationContext.UniqueKey.Name, this.migrationContext.UniqueKey.Columns.String(), uniqueKeyValues.String(), strings.Join(violatedConstraints, ", "))
 }
 
-// ApplyIterationInsertQuery issues a chunk-INSERT query on the ghost table. It is where
-// data actually gets copied from original table.
-func (this *Applier) ApplyIterationInsertQuery() (chunkSize int64, rowsAffected int64, duration time.Duration, err error) {
+// ApplyIterationInsertQuery copies the current row copy chunk onto the ghost table. With verifyChecksum, the chunk
+// is then checksummed on both tables within the same transaction, and a mismatch is returned as a *ChunkChecksumMismatchError.
+func (this *Applier) ApplyIterationInsertQuery(verifyChecksum bool) (chunkSize int64, rowsAffected int64, rowsExamined int64, duration time.Duration, err error) {
 	startTime := time.Now()
-	chunkSize = atomic.LoadInt64(&this.migrationContext.ChunkSize)
+	chunkSize = this.migrationContext.GetPacketBoundChunkSize()
 
 	query, explodedArgs, err := sql.BuildRangeInsertPreparedQuery(
 		this.migrationContext.DatabaseName,
 		this.migrationContext.OriginalTableName,
+		this.migrationContext.GetMigrationRangePartitionName(),
+		this.migrationContext.GetTargetDatabaseName(),
 		this.migrationContext.GetGhostTableName(),
 		this.migrationContext.SharedColumns.Names(),
-		this.migrationContext.MappedSharedColumns.Names(),
+		this.migrationContext.MappedSharedColumns,
 		this.migrationContext.UniqueKey.Name,
 		&this.migrationContext.UniqueKey.Columns,
 		this.migrationContext.MigrationIterationRangeMinValues.AbstractValues(),
 		this.migrationContext.MigrationIterationRangeMaxValues.AbstractValues(),
-		this.migrationContext.GetIteration() == 0,
+		this.migrationContext.IsFirstRangeIteration(),
+		this.migrationContext.CopyOrder,
 		this.migrationContext.IsTransactionalTable(),
+		this.migrationContext.CopyConflictStrategy,
+		this.migrationContext.RowFilter,
 	)
 	if err != nil {
-		return chunkSize, rowsAffected, duration, err
+		return chunkSize, rowsAffected, rowsExamined, duration, err
 	}
+	var countQuery string
+	var countExplodedArgs []interface{}
+	countRows := this.migrationContext.ShouldCountRowsExamined()
+	if countRows {
+		countQuery, countExplodedArgs, err = sql.BuildRangeCountPreparedQuery(
+			this.migrationContext.DatabaseName,
+			this.migrationContext.OriginalTableName,
+			this.migrationContext.GetMigrationRangePartitionName(),
+			this.migrationContext.UniqueKey.Name,
+			&this.migrationContext.UniqueKey.Columns,
+			this.migrationContext.MigrationIterationRangeMinValues.AbstractValues(),
+			this.migrationContext.MigrationIterationRangeMaxValues.AbstractValues(),
+			this.migrationContext.IsFirstRangeIteration(),
+			this.migrationContext.CopyOrder,
+			this.migrationContext.IsTransactionalTable(),
+			this.migrationContext.RowFilter,
+		)
+		if err != nil {
+			return chunkSize, rowsAffected, rowsExamined, duration, err
+		}
+	}
+
+	var violationQuery string
+	var violationExplodedArgs []interface{}
+	if len(this.migrationContext.NewCheckConstraints) > 0 {
+		violationQuery, violationExplodedArgs, err = sql.BuildRangeCheckConstraintsViolationPreparedQuery(
+			this.migrationContext.DatabaseName,
+			this.migrationContext.OriginalTableName,
+			this.migrationContext.GetMigrationRangePartitionName(),
+			this.migrationContext.UniqueKey.Name,
+			&this.migrationContext.UniqueKey.Columns,
+			this.migrationContext.MigrationIterationRangeMinValues.AbstractValues(),
+			this.migrationContext.MigrationIterationRangeMaxValues.AbstractValues(),
+			this.migrationContext.IsFirstRangeIteration(),
+			this.migrationContext.CopyOrder,
+			this.migrationContext.IsTransactionalTable(),
+			this.migrationContext.RowFilter,
+			this.migrationContext.NewCheckConstraints,
+		)
+		if err != nil {
+			return chunkSize, rowsAffected, rowsExamined, duration, err
+		}
+	}
+
+	var originalChecksumQuery, ghostChecksumQuery string
+	var checksumExplodedArgs []interface{}
+	if verifyChecksum {
+		if originalChecksumQuery, ghostChecksumQuery, checksumExplodedArgs, err = this.buildIterationChecksumQueries(); err != nil {
+			return chunkSize, rowsAffected, rowsExamined, duration, err
+		}
+	}
+	var mismatch *ChunkChecksumMismatchError
+	checksumVerified := false
 
 	sqlResult, err := func() (gosql.Result, error) {
+		mismatch = nil
+		checksumVerified = false
 		tx, err := this.db.Begin()
 		if err != nil {
 			return nil, err
 		}
 		defer tx.Rollback()
-		sessionQuery := fmt.Sprintf(`SET SESSION time_zone = '%s'`, this.migrationContext.ApplierTimeZone)
-		sqlModeAddendum := `,NO_AUTO_VALUE_ON_ZERO`
-		if !this.migrationContext.SkipStrictMode {
-			sqlModeAddendum = fmt.Sprintf("%s,STRICT_ALL_TABLES", sqlModeAddendum)
-		}
-		sessionQuery = fmt.Sprintf("%s, sql_mode = CONCAT(@@session.sql_mode, ',%s')", sessionQuery, sqlModeAddendum)
-
+		// As with applied binlog events: DATETIME/TIMESTAMP conversions are explicit in the query
+		sessionQuery := fmt.Sprintf("SET SESSION time_zone = '%s'", this.migrationContext.ConnectionTimeZone)
 		if _, err := tx.Exec(sessionQuery); err != nil {
 			return nil, err
 		}
+		if countRows {
+			if err := tx.QueryRow(countQuery, countExplodedArgs...).Scan(&rowsExamined); err != nil {
+				return nil, err
+			}
+		}
+		if violationQuery != "" {
+			if err := this.checkIterationCheckConstraints(tx, violationQuery, violationExplodedArgs); err != nil {
+				return nil, err
+			}
+		}
 		result, err := tx.Exec(query, explodedArgs...)
 		if err != nil {
 			return nil, err
 		}
+		if verifyChecksum {
+			// Within the copy's transaction, the chunk's rows are locked on both tables: no binlog event
+			// applies onto them meanwhile
+			checksum := &ChunkChecksumMismatchError{
+				UniqueKey:      this.migrationContext.UniqueKey.Name,
+				RangeMinValues: this.migrationContext.MigrationIterationRangeMinValues,
+				RangeMaxValues: this.migrationContext.MigrationIterationRangeMaxValues,
+			}
+			if err := tx.QueryRow(originalChecksumQuery, checksumExplodedArgs...).Scan(&checksum.OriginalRows, &checksum.OriginalChecksum); err != nil {
+				return nil, err
+			}
+			if err := tx.QueryRow(ghostChecksumQuery, checksumExplodedArgs...).Scan(&checksum.GhostRows, &checksum.GhostChecksum); err != nil {
+				return nil, err
+			}
+			affected, _ := result.RowsAffected()
+			switch {
+			case checksum.OriginalRows != checksum.GhostRows:
+				// The ghost table holds rows since deleted from the original table, pending binlog events
+				this.migrationContext.Log.Debugf("Chunk checksum inconclusive: %d rows on original table, %d rows on ghost table", checksum.OriginalRows, checksum.GhostRows)
+			case (this.migrationContext.CopyConflictStrategy == sql.IgnoreCopyConflictStrategy || this.migrationContext.CopyConflictStrategy == sql.SkipExistingCopyConflictStrategy) && affected != checksum.OriginalRows:
+				// Rows ignored by the copy are as last applied from the binary log, possibly pending further events
+				this.migrationContext.Log.Debugf("Chunk checksum inconclusive: %d of %d rows found on ghost table", checksum.OriginalRows-affected, checksum.OriginalRows)
+			default:
+				checksumVerified = true
+				if checksum.OriginalChecksum != checksum.GhostChecksum {
+					mismatch = checksum
+				}
+			}
+		}
 		if err := tx.Commit(); err != nil {
 			return nil, err
 		}
@@ -606,17 +1494,34 @@ func (this *Applier) ApplyIterationInsertQuery() (chunkSize int64, rowsAffected
 	}()
 
 	if err != nil {
-		return chunkSize, rowsAffected, duration, err
+		if mysql.IsPacketTooLargeError(err) {
+			err = fmt.Errorf("%w; chunk of %d rows on range [%s]..[%s] exceeds max_allowed_packet (%d). Consider --max-packet-budget", err, chunkSize,
+				this.migrationContext.MigrationIterationRangeMinValues, this.migrationContext.MigrationIterationRangeMaxValues, this.migrationContext.GetMaxAllowedPacket())
+		}
+		return chunkSize, rowsAffected, rowsExamined, duration, err
 	}
 	rowsAffected, _ = sqlResult.RowsAffected()
+	if !countRows {
+		// Rows examined are unknown: rows ignored, if any, are not told apart from rows copied
+		rowsExamined = rowsAffected
+	}
 	duration = time.Since(startTime)
 	this.migrationContext.Log.Debugf(
-		"Issued INSERT on range: [%s]..[%s]; iteration: %d; chunk-size: %d",
+		"Issued INSERT on range: [%s]..[%s]; iteration: %d; chunk-size: %d; rows affected: %d; rows examined: %d",
 		this.migrationContext.MigrationIterationRangeMinValues,
 		this.migrationContext.MigrationIterationRangeMaxValues,
 		this.migrationContext.GetIteration(),
-		chunkSize)
-	return chunkSize, rowsAffected, duration, nil
+		chunkSize,
+		rowsAffected,
+		rowsExamined)
+	if checksumVerified {
+		atomic.AddInt64(&this.migrationContext.ChunkChecksumsVerified, 1)
+	}
+	if mismatch != nil {
+		atomic.AddInt64(&this.migrationContext.ChunkChecksumMismatches, 1)
+		return chunkSize, rowsAffected, rowsExamined, duration, mismatch
+	}
+	return chunkSize, rowsAffected, rowsExamined, duration, nil
 }
 
 // LockOriginalTable places a write lock on the original table
@@ -637,6 +1542,74 @@ func (this *Applier) LockOriginalTable() error {
 	return nil
 }
 
+// buildCountTableRowsWithinLockQuery counts a table's rows within the cut-over lock, and so must not take longer
+// than given timeout, a share of --cut-over-lock-timeout-seconds
+func (this *Applier) buildCountTableRowsWithinLockQuery(databaseName, tableName string, timeout time.Duration) string {
+	return fmt.Sprintf(`select /*+ MAX_EXECUTION_TIME(%d) */ /* gh-ost */ count(*) from %s.%s`,
+		timeout.Milliseconds(),
+		sql.EscapeName(databaseName),
+		sql.EscapeName(tableName),
+	)
+}
+
+// CountOriginalTableRowsWithinLock counts the original table's rows on the session holding the cut-over lock:
+// the singleton session of the two-step cut-over, or the session holding the atomic cut-over's magic lock
+func (this *Applier) CountOriginalTableRowsWithinLock(timeout time.Duration) (rows int64, err error) {
+	if this.migrationContext.CutOverType == base.CutOverTwoStep {
+		query := this.buildCountTableRowsWithinLockQuery(this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName, timeout)
+		err = this.singletonDB.QueryRow(query).Scan(&rows)
+		return rows, err
+	}
+	request := &rowCountRequest{timeout: timeout, result: make(chan rowCountResult, 1)}
+	select {
+	case this.magicLockRowCountRequests <- request:
+	case <-time.After(timeout):
+		return 0, fmt.Errorf("Timeout waiting for the session holding the cut-over lock to count rows")
+	}
+	counted := <-request.result
+	return counted.rows, counted.err
+}
+
+// CountGhostTableRows counts the ghost table's rows within the cut-over lock
+func (this *Applier) CountGhostTableRows(timeout time.Duration) (rows int64, err error) {
+	query := this.buildCountTableRowsWithinLockQuery(this.migrationContext.GetTargetDatabaseName(), this.migrationContext.GetGhostTableName(), timeout)
+	err = this.db.QueryRow(query).Scan(&rows)
+	return rows, err
+}
+
+// EstimateTableRows reads a table's rows estimate off information_schema. On MySQL 8.0, whose
+// information_schema serves cached statistics, per information_schema_stats_expiry, it reads fresh ones.
+func (this *Applier) EstimateTableRows(databaseName, tableName string) (rows int64, err error) {
+	ctx := context.Background()
+	conn, err := this.db.Conn(ctx)
+	if err != nil {
+		return rows, err
+	}
+	defer conn.Close()
+
+	var variableName, statsExpiry string
+	err = conn.QueryRowContext(ctx, `show /* gh-ost */ session variables like 'information_schema_stats_expiry'`).Scan(&variableName, &statsExpiry)
+	switch {
+	case err == gosql.ErrNoRows:
+		// Statistics are not cached
+	case err != nil:
+		return rows, err
+	default:
+		if _, err := conn.ExecContext(ctx, `set /* gh-ost */ session information_schema_stats_expiry = 0`); err != nil {
+			return rows, err
+		}
+		// The connection returns to the pool
+		defer conn.ExecContext(ctx, `set /* gh-ost */ session information_schema_stats_expiry = ?`, statsExpiry)
+	}
+	query := `
+		select /* gh-ost */ ifnull(table_rows, 0)
+			from information_schema.tables
+			where table_schema = ? and table_name = ?
+	`
+	err = conn.QueryRowContext(ctx, query, databaseName, tableName).Scan(&rows)
+	return rows, err
+}
+
 // UnlockTables makes tea. No wait, it unlocks tables.
 func (this *Applier) UnlockTables() error {
 	query := `unlock /* gh-ost */ tables`
@@ -653,9 +1626,11 @@ func (this *Applier) UnlockTables() error {
 // - rename ghost table to original
 // There is a point in time in between where the table does not exist.
 func (this *Applier) SwapTablesQuickAndBumpy() error {
-	query := fmt.Sprintf(`alter /* gh-ost */ table %s.%s rename %s`,
+	query := fmt.Sprintf(`alter %s table %s.%s rename %s.%s`,
+		this.migrationContext.GetDDLComment(),
 		sql.EscapeName(this.migrationContext.DatabaseName),
 		sql.EscapeName(this.migrationContext.OriginalTableName),
+		sql.EscapeName(this.migrationContext.DatabaseName),
 		sql.EscapeName(this.migrationContext.GetOldTableName()),
 	)
 	this.migrationContext.Log.Infof("Renaming original table")
@@ -663,10 +1638,12 @@ func (this *Applier) SwapTablesQuickAndBumpy() error {
 	if _, err := sqlutils.ExecNoPrepare(this.singletonDB, query); err != nil {
 		return err
 	}
-	query = fmt.Sprintf(`alter /* gh-ost */ table %s.%s rename %s`,
-		sql.EscapeName(this.migrationContext.DatabaseName),
+	query = fmt.Sprintf(`alter %s table %s.%s rename %s.%s`,
+		this.migrationContext.GetDDLComment(),
+		sql.EscapeName(this.migrationContext.GetTargetDatabaseName()),
 		sql.EscapeName(this.migrationContext.GetGhostTableName()),
-		sql.EscapeName(this.migrationContext.OriginalTableName),
+		sql.EscapeName(this.migrationContext.GetTargetDatabaseName()),
+		sql.EscapeName(this.migrationContext.GetMigratedTableName()),
 	)
 	this.migrationContext.Log.Infof("Renaming ghost table")
 	if _, err := sqlutils.ExecNoPrepare(this.db, query); err != nil {
@@ -683,10 +1660,11 @@ func (this *Applier) SwapTablesQuickAndBumpy() error {
 func (this *Applier) RenameTablesRollback() (renameError error) {
 	// Restoring tables to original names.
 	// We prefer the single, atomic operation:
-	query := fmt.Sprintf(`rename /* gh-ost */ table %s.%s to %s.%s, %s.%s to %s.%s`,
-		sql.EscapeName(this.migrationContext.DatabaseName),
-		sql.EscapeName(this.migrationContext.OriginalTableName),
-		sql.EscapeName(this.migrationContext.DatabaseName),
+	query := fmt.Sprintf(`rename %s table %s.%s to %s.%s, %s.%s to %s.%s`,
+		this.migrationContext.GetDDLComment(),
+		sql.EscapeName(this.migrationContext.GetTargetDatabaseName()),
+		sql.EscapeName(this.migrationContext.GetMigratedTableName()),
+		sql.EscapeName(this.migrationContext.GetTargetDatabaseName()),
 		sql.EscapeName(this.migrationContext.GetGhostTableName()),
 		sql.EscapeName(this.migrationContext.DatabaseName),
 		sql.EscapeName(this.migrationContext.GetOldTableName()),
@@ -698,17 +1676,19 @@ func (this *Applier) RenameTablesRollback() (renameError error) {
 		return nil
 	}
 	// But, if for some reason the above was impossible to do, we rename one by one.
-	query = fmt.Sprintf(`rename /* gh-ost */ table %s.%s to %s.%s`,
-		sql.EscapeName(this.migrationContext.DatabaseName),
-		sql.EscapeName(this.migrationContext.OriginalTableName),
-		sql.EscapeName(this.migrationContext.DatabaseName),
+	query = fmt.Sprintf(`rename %s table %s.%s to %s.%s`,
+		this.migrationContext.GetDDLComment(),
+		sql.EscapeName(this.migrationContext.GetTargetDatabaseName()),
+		sql.EscapeName(this.migrationContext.GetMigratedTableName()),
+		sql.EscapeName(this.migrationContext.GetTargetDatabaseName()),
 		sql.EscapeName(this.migrationContext.GetGhostTableName()),
 	)
 	this.migrationContext.Log.Infof("Renaming back to ghost table")
 	if _, err := sqlutils.ExecNoPrepare(this.db, query); err != nil {
 		renameError = err
 	}
-	query = fmt.Sprintf(`rename /* gh-ost */ table %s.%s to %s.%s`,
+	query = fmt.Sprintf(`rename %s table %s.%s to %s.%s`,
+		this.migrationContext.GetDDLComment(),
 		sql.EscapeName(this.migrationContext.DatabaseName),
 		sql.EscapeName(this.migrationContext.GetOldTableName()),
 		sql.EscapeName(this.migrationContext.DatabaseName),
@@ -843,7 +1823,7 @@ func (this *Applier) ExpectProcess(sessionId int64, stateHint, infoHint string)
 func (this *Applier) DropAtomicCutOverSentryTableIfExists() error {
 	this.migrationContext.Log.Infof("Looking for magic cut-over table")
 	tableName := this.migrationContext.GetOldTableName()
-	rowMap := this.showTableStatus(tableName)
+	rowMap := this.showTableStatus(this.migrationContext.DatabaseName, tableName)
 	if rowMap == nil {
 		// Table does not exist
 		return nil
@@ -852,7 +1832,7 @@ func (this *Applier) DropAtomicCutOverSentryTableIfExists() error {
 		return fmt.Errorf("Expected magic comment on %s, did not find it", tableName)
 	}
 	this.migrationContext.Log.Infof("Dropping magic cut-over table")
-	return this.dropTable(tableName)
+	return this.dropTable(this.migrationContext.DatabaseName, tableName)
 }
 
 // CreateAtomicCutOverSentryTable
@@ -862,10 +1842,11 @@ func (this *Applier) CreateAtomicCutOverSentryTable() error {
 	}
 	tableName := this.migrationContext.GetOldTableName()
 
-	query := fmt.Sprintf(`create /* gh-ost */ table %s.%s (
+	query := fmt.Sprintf(`create %s table %s.%s (
 			id int auto_increment primary key
 		) engine=%s comment='%s'
 		`,
+		this.migrationContext.GetDDLComment(),
 		sql.EscapeName(this.migrationContext.DatabaseName),
 		sql.EscapeName(tableName),
 		this.migrationContext.TableEngine,
@@ -915,7 +1896,7 @@ func (this *Applier) AtomicCutOverMagicLock(sessionIdChan chan int64, tableLocke
 		return err
 	}
 
-	tableLockTimeoutSeconds := this.migrationContext.CutOverLockTimeoutSeconds * 2
+	tableLockTimeoutSeconds := this.migrationContext.GetCutOverLockWaitTimeoutSeconds()
 	this.migrationContext.Log.Infof("Setting LOCK timeout as %d seconds", tableLockTimeoutSeconds)
 	query = fmt.Sprintf(`set session lock_wait_timeout:=%d`, tableLockTimeoutSeconds)
 	if _, err := tx.Exec(query); err != nil {
@@ -940,6 +1921,7 @@ func (this *Applier) AtomicCutOverMagicLock(sessionIdChan chan int64, tableLocke
 		sql.EscapeName(this.migrationContext.DatabaseName),
 		sql.EscapeName(this.migrationContext.GetOldTableName()),
 	)
+	this.migrationContext.TransitionCutOverPhase(base.CutOverPhaseLocking)
 	this.migrationContext.LockTablesStartTime = time.Now()
 	if _, err := tx.Exec(query); err != nil {
 		tableLocked <- err
@@ -952,8 +1934,18 @@ func (this *Applier) AtomicCutOverMagicLock(sessionIdChan chan int64, tableLocke
 	// the UNLOCK must execute (or, alternatively, this connection dies, which gets the same impact)
 
 	// The cut-over phase will proceed to apply remaining backlog onto ghost table,
-	// and issue RENAME. We wait here until told to proceed.
-	<-okToUnlockTable
+	// and issue RENAME. We wait here until told to proceed. Meanwhile, this is the only session
+	// able to count the original table's rows.
+	for locked := true; locked; {
+		select {
+		case <-okToUnlockTable:
+			locked = false
+		case request := <-this.magicLockRowCountRequests:
+			var rows int64
+			err := tx.QueryRow(this.buildCountTableRowsWithinLockQuery(this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName, request.timeout)).Scan(&rows)
+			request.result <- rowCountResult{rows: rows, err: err}
+		}
+	}
 	this.migrationContext.Log.Infof("Will now proceed to drop magic table and unlock tables")
 
 	// The magic table is here because we locked it. And we are the only ones allowed to drop it.
@@ -997,27 +1989,32 @@ func (this *Applier) AtomicCutoverRename(sessionIdChan chan int64, tablesRenamed
 		sessionIdChan <- -1
 		tablesRenamed <- fmt.Errorf("Unexpected error in AtomicCutoverRename(), injected to release blocking channel reads")
 	}()
+	if atomic.LoadInt64(&this.migrationContext.IsRehearsingCutOver) > 0 {
+		return fmt.Errorf("Refusing to rename tables while rehearsing cut-over")
+	}
 	var sessionId int64
 	if err := tx.QueryRow(`select connection_id()`).Scan(&sessionId); err != nil {
 		return err
 	}
 	sessionIdChan <- sessionId
 
-	this.migrationContext.Log.Infof("Setting RENAME timeout as %d seconds", this.migrationContext.CutOverLockTimeoutSeconds)
-	query := fmt.Sprintf(`set session lock_wait_timeout:=%d`, this.migrationContext.CutOverLockTimeoutSeconds)
+	renameTimeoutSeconds := this.migrationContext.GetCutOverRenameTimeoutSeconds()
+	this.migrationContext.Log.Infof("Setting RENAME timeout as %d seconds", renameTimeoutSeconds)
+	query := fmt.Sprintf(`set session lock_wait_timeout:=%d`, renameTimeoutSeconds)
 	if _, err := tx.Exec(query); err != nil {
 		return err
 	}
 
-	query = fmt.Sprintf(`rename /* gh-ost */ table %s.%s to %s.%s, %s.%s to %s.%s`,
+	query = fmt.Sprintf(`rename %s table %s.%s to %s.%s, %s.%s to %s.%s`,
+		this.migrationContext.GetDDLComment(),
 		sql.EscapeName(this.migrationContext.DatabaseName),
 		sql.EscapeName(this.migrationContext.OriginalTableName),
 		sql.EscapeName(this.migrationContext.DatabaseName),
 		sql.EscapeName(this.migrationContext.GetOldTableName()),
-		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(this.migrationContext.GetTargetDatabaseName()),
 		sql.EscapeName(this.migrationContext.GetGhostTableName()),
-		sql.EscapeName(this.migrationContext.DatabaseName),
-		sql.EscapeName(this.migrationContext.OriginalTableName),
+		sql.EscapeName(this.migrationContext.GetTargetDatabaseName()),
+		sql.EscapeName(this.migrationContext.GetMigratedTableName()),
 	)
 	this.migrationContext.Log.Infof("Issuing and expecting this to block: %s", query)
 	if _, err := tx.Exec(query); err != nil {
@@ -1029,12 +2026,132 @@ func (this *Applier) AtomicCutoverRename(sessionIdChan chan int64, tablesRenamed
 	return nil
 }
 
-func (this *Applier) ShowStatusVariable(variableName string) (result int64, err error) {
-	query := fmt.Sprintf(`show global status like '%s'`, variableName)
-	if err := this.db.QueryRow(query).Scan(&variableName, &result); err != nil {
-		return 0, err
+// GetBlockedSessions returns the number of sessions waiting on a table lock, and the longest such wait
+func (this *Applier) GetBlockedSessions() (count int64, maxWait time.Duration, err error) {
+	query := `
+		select count(*), ifnull(max(time), 0)
+			from information_schema.processlist
+			where
+				id != connection_id()
+				and state like 'Waiting for table%lock'
+	`
+	var maxWaitSeconds int64
+	if err := this.db.QueryRow(query).Scan(&count, &maxWaitSeconds); err != nil {
+		return 0, 0, err
 	}
-	return result, nil
+	return count, time.Duration(maxWaitSeconds) * time.Second, nil
+}
+
+// BlockingSession is a session holding a metadata lock which a cut-over session waits on
+type BlockingSession struct {
+	Id    int64
+	User  string
+	Host  string
+	Time  int64
+	Query string
+}
+
+// ValidateMetadataLocksInstrumented verifies performance_schema instruments metadata locks, by which
+// --cut-over-kill-blocking-queries-after finds the sessions blocking cut-over
+func (this *Applier) ValidateMetadataLocksInstrumented() error {
+	query := `
+		select /* gh-ost */ enabled
+			from performance_schema.setup_instruments
+			where name = 'wait/lock/metadata/sql/mdl'
+	`
+	enabled := ""
+	if err := this.db.QueryRow(query).Scan(&enabled); err != nil {
+		return fmt.Errorf("--cut-over-kill-blocking-queries-after requires performance_schema: %+v", err)
+	}
+	if enabled != "YES" {
+		return fmt.Errorf("--cut-over-kill-blocking-queries-after requires the wait/lock/metadata/sql/mdl performance_schema instrument to be enabled")
+	}
+	return nil
+}
+
+// GetBlockingSessions returns the user sessions, running for at least minTime, which hold metadata locks given
+// session waits on. Replication threads and gh-ost's own sessions are excluded.
+func (this *Applier) GetBlockingSessions(sessionId int64, minTime time.Duration) (sessions [](*BlockingSession), err error) {
+	query := `
+		select /* gh-ost */ distinct
+				blocking_thread.processlist_id as id,
+				ifnull(blocking_thread.processlist_user, '') as user,
+				ifnull(blocking_thread.processlist_host, '') as host,
+				ifnull(blocking_thread.processlist_time, 0) as time,
+				ifnull(blocking_thread.processlist_info, '') as info
+			from performance_schema.metadata_locks waiting_lock
+				join performance_schema.threads waiting_thread on (waiting_thread.thread_id = waiting_lock.owner_thread_id)
+				join performance_schema.metadata_locks blocking_lock on (
+					blocking_lock.object_type = waiting_lock.object_type
+					and blocking_lock.object_schema = waiting_lock.object_schema
+					and blocking_lock.object_name = waiting_lock.object_name
+					and blocking_lock.lock_status = 'GRANTED'
+				)
+				join performance_schema.threads blocking_thread on (blocking_thread.thread_id = blocking_lock.owner_thread_id)
+			where
+				waiting_thread.processlist_id = ?
+				and waiting_lock.lock_status = 'PENDING'
+				and blocking_thread.processlist_id != ?
+				and blocking_thread.processlist_id != connection_id()
+				and blocking_thread.type = 'FOREGROUND'
+				and ifnull(blocking_thread.processlist_user, '') != 'system user'
+				and ifnull(blocking_thread.processlist_command, '') not like 'Binlog Dump%'
+				and ifnull(blocking_thread.processlist_info, '') not like '%/* gh-ost%'
+				and ifnull(blocking_thread.processlist_time, 0) >= ?
+	`
+	err = sqlutils.QueryRowsMap(this.db, query, func(m sqlutils.RowMap) error {
+		sessions = append(sessions, &BlockingSession{
+			Id:    m.GetInt64("id"),
+			User:  m.GetString("user"),
+			Host:  m.GetString("host"),
+			Time:  m.GetInt64("time"),
+			Query: m.GetString("info"),
+		})
+		return nil
+	}, sessionId, sessionId, int64(minTime/time.Second))
+	return sessions, err
+}
+
+// KillSession kills a session, releasing its locks
+func (this *Applier) KillSession(sessionId int64) error {
+	query := fmt.Sprintf(`kill /* gh-ost */ %d`, sessionId)
+	_, err := sqlutils.ExecNoPrepare(this.db, query)
+	return err
+}
+
+// GetSingletonSessionId returns the connection id of the singleton session, which locks and renames the tables
+// in the two-step cut-over
+func (this *Applier) GetSingletonSessionId() (sessionId int64, err error) {
+	err = this.singletonDB.QueryRow(`select /* gh-ost */ connection_id()`).Scan(&sessionId)
+	return sessionId, err
+}
+
+// Keepalive pings the applier's connections, such that they do not hit wait_timeout while idle, e.g. while
+// postponing cut-over
+func (this *Applier) Keepalive() error {
+	if err := this.db.Ping(); err != nil {
+		return err
+	}
+	return this.singletonDB.Ping()
+}
+
+// GetPoolStats returns a short description of the applier's connection pool usage
+func (this *Applier) GetPoolStats() string {
+	return mysql.DBPoolStats(this.db)
+}
+
+func (this *Applier) ShowStatusVariable(variableName string) (result int64, err error) {
+	return mysql.GetStatusVariable(this.db, variableName)
+}
+
+// ShowReplicaHosts returns the replicas registered with the applier host
+func (this *Applier) ShowReplicaHosts() ([]mysql.ReplicaHost, error) {
+	return mysql.GetReplicaHosts(this.db)
+}
+
+// ShowHistoryListLength returns the InnoDB history list length on the applier host
+func (this *Applier) ShowHistoryListLength() (int64, error) {
+	return mysql.GetHistoryListLength(this.db)
 }
 
 // updateModifiesUniqueKeyColumns checks whether a UPDATE DML event actually
@@ -1052,30 +2169,134 @@ func (this *Applier) updateModifiesUniqueKeyColumns(dmlEvent *binlog.BinlogDMLEv
 	return "", false
 }
 
+// buildSurrogateKeyDMLEventQuery creates a query to operate on the ghost table, matching rows by all
+// shared columns, as per --surrogate-key. A row is only inserted if still found on the original
+// table, and only deleted if no longer found there; this reconciles events with concurrent row copy.
+// Within the cut-over critical section the original table is locked, and not looked into.
+func (this *Applier) buildSurrogateKeyDMLEventQuery(dmlEvent *binlog.BinlogDMLEvent) (results [](*dmlBuildResult)) {
+	originalTableName := this.migrationContext.OriginalTableName
+	if atomic.LoadInt64(&this.migrationContext.InCutOverCriticalSectionFlag) > 0 {
+		originalTableName = ""
+	}
+	switch dmlEvent.DML {
+	case binlog.DeleteDML:
+		{
+			query, args, err := sql.BuildDMLRowMatchDeleteQuery(dmlEvent.DatabaseName, originalTableName, this.migrationContext.GetTargetDatabaseName(), this.migrationContext.GetGhostTableName(), this.migrationContext.OriginalTableColumns, this.migrationContext.SharedColumns, this.migrationContext.MappedSharedColumns, dmlEvent.WhereColumnValues.AbstractValues())
+			return append(results, newDmlBuildResult(query, args, -1, err))
+		}
+	case binlog.InsertDML:
+		{
+			query, args, err := sql.BuildDMLRowMatchInsertQuery(dmlEvent.DatabaseName, originalTableName, this.migrationContext.GetTargetDatabaseName(), this.migrationContext.GetGhostTableName(), this.migrationContext.OriginalTableColumns, this.migrationContext.SharedColumns, this.migrationContext.MappedSharedColumns, dmlEvent.NewColumnValues.AbstractValues())
+			return append(results, newDmlBuildResult(query, args, 1, err))
+		}
+	case binlog.UpdateDML:
+		{
+			// The event keeps its UPDATE type, as counted and audited once applied, and as rebuilt on retry
+			defer func() { dmlEvent.DML = binlog.UpdateDML }()
+			dmlEvent.DML = binlog.DeleteDML
+			results = append(results, this.buildSurrogateKeyDMLEventQuery(dmlEvent)...)
+			dmlEvent.DML = binlog.InsertDML
+			results = append(results, this.buildSurrogateKeyDMLEventQuery(dmlEvent)...)
+			return results
+		}
+	}
+	return append(results, newDmlBuildResultError(fmt.Errorf("Unknown dml event type: %+v", dmlEvent.DML)))
+}
+
+// buildRowFilterDeleteQuery creates a query deleting the rows just inserted onto the ghost table by given
+// INSERT events, unless they match --row-filter. Without a row filter, there is nothing to delete.
+func (this *Applier) buildRowFilterDeleteQuery(dmlEvents ...*binlog.BinlogDMLEvent) (results [](*dmlBuildResult)) {
+	if this.migrationContext.RowFilter == "" {
+		return results
+	}
+	rowsArgs := make([][]interface{}, len(dmlEvents))
+	for i, dmlEvent := range dmlEvents {
+		rowsArgs[i] = dmlEvent.NewColumnValues.AbstractValues()
+	}
+	query, uniqueKeyArgs, err := sql.BuildDMLRowFilterDeleteQuery(this.migrationContext.GetTargetDatabaseName(), this.migrationContext.GetGhostTableName(), this.migrationContext.RowFilter, this.migrationContext.OriginalTableColumns, &this.migrationContext.UniqueKey.Columns, rowsArgs)
+	return append(results, newDmlBuildResult(query, uniqueKeyArgs, -1, err))
+}
+
+// resolveJSONDiffs replaces the partial JSON values of given update event's after image (see
+// --allow-partial-json-updates) with full values, computed by the server off the before image. This is
+// required where the after image is inserted onto the ghost table, rather than applied onto its row.
+func (this *Applier) resolveJSONDiffs(dmlEvent *binlog.BinlogDMLEvent) error {
+	newValues := dmlEvent.NewColumnValues.AbstractValues()
+	for i, value := range newValues {
+		diffs, ok := value.(sql.JSONDiffs)
+		if !ok {
+			continue
+		}
+		if !dmlEvent.WhereColumnValues.IsPresent(i) {
+			return fmt.Errorf("Cannot apply partial JSON update onto %s: its before value is not logged, as per binlog_row_image=MINIMAL", sql.EscapeName(this.migrationContext.OriginalTableColumns.Columns()[i].Name))
+		}
+		expression, diffsArgs := diffs.Expression("cast(convert(? using utf8mb4) as json)")
+		args := append([]interface{}{dmlEvent.WhereColumnValues.AbstractValues()[i]}, diffsArgs...)
+		var resolved string
+		if err := this.db.QueryRow(fmt.Sprintf(`select /* gh-ost */ %s`, expression), args...).Scan(&resolved); err != nil {
+			return err
+		}
+		newValues[i] = resolved
+	}
+	return nil
+}
+
 // buildDMLEventQuery creates a query to operate on the ghost table, based on an intercepted binlog
 // event entry on the original table.
 func (this *Applier) buildDMLEventQuery(dmlEvent *binlog.BinlogDMLEvent) (results [](*dmlBuildResult)) {
+	if this.migrationContext.UsingSurrogateKey {
+		if dmlEvent.DML == binlog.UpdateDML {
+			if err := this.resolveJSONDiffs(dmlEvent); err != nil {
+				return append(results, newDmlBuildResultError(err))
+			}
+		}
+		return this.buildSurrogateKeyDMLEventQuery(dmlEvent)
+	}
 	switch dmlEvent.DML {
 	case binlog.DeleteDML:
 		{
-			query, uniqueKeyArgs, err := sql.BuildDMLDeleteQuery(dmlEvent.DatabaseName, this.migrationContext.GetGhostTableName(), this.migrationContext.OriginalTableColumns, &this.migrationContext.UniqueKey.Columns, dmlEvent.WhereColumnValues.AbstractValues())
+			query, uniqueKeyArgs, err := sql.BuildDMLDeleteQuery(this.migrationContext.GetTargetDatabaseName(), this.migrationContext.GetGhostTableName(), this.migrationContext.OriginalTableColumns, &this.migrationContext.UniqueKey.Columns, dmlEvent.WhereColumnValues.AbstractValues())
 			return append(results, newDmlBuildResult(query, uniqueKeyArgs, -1, err))
 		}
 	case binlog.InsertDML:
 		{
-			query, sharedArgs, err := sql.BuildDMLInsertQuery(dmlEvent.DatabaseName, this.migrationContext.GetGhostTableName(), this.migrationContext.OriginalTableColumns, this.migrationContext.SharedColumns, this.migrationContext.MappedSharedColumns, dmlEvent.NewColumnValues.AbstractValues())
-			return append(results, newDmlBuildResult(query, sharedArgs, 1, err))
+			sharedColumns, mappedSharedColumns := sql.FilterPresentColumns(this.migrationContext.OriginalTableColumns, this.migrationContext.SharedColumns, this.migrationContext.MappedSharedColumns, dmlEvent.NewColumnValues)
+			query, sharedArgs, err := sql.BuildDMLInsertQuery(this.migrationContext.GetTargetDatabaseName(), this.migrationContext.GetGhostTableName(), this.migrationContext.OriginalTableColumns, sharedColumns, mappedSharedColumns, dmlEvent.NewColumnValues.AbstractValues())
+			results = append(results, newDmlBuildResult(query, sharedArgs, 1, err))
+			return append(results, this.buildRowFilterDeleteQuery(dmlEvent)...)
 		}
 	case binlog.UpdateDML:
 		{
-			if _, isModified := this.updateModifiesUniqueKeyColumns(dmlEvent); isModified {
+			if dmlEvent.NewColumnValues.IsPartial() {
+				// Minimal row image: the after image only holds the changed columns, and the before image
+				// holds the identifying columns. We only ever update the present columns, in place, since
+				// a DELETE+INSERT would lose the absent columns.
+				dmlEvent.NewColumnValues.MergeAbsentFrom(dmlEvent.WhereColumnValues)
+				sharedColumns, mappedSharedColumns := sql.FilterPresentColumns(this.migrationContext.OriginalTableColumns, this.migrationContext.SharedColumns, this.migrationContext.MappedSharedColumns, dmlEvent.NewColumnValues)
+				query, sharedArgs, uniqueKeyArgs, err := sql.BuildDMLUpdateQuery(this.migrationContext.GetTargetDatabaseName(), this.migrationContext.GetGhostTableName(), this.migrationContext.OriginalTableColumns, sharedColumns, mappedSharedColumns, &this.migrationContext.UniqueKey.Columns, dmlEvent.NewColumnValues.AbstractValues(), dmlEvent.WhereColumnValues.AbstractValues())
+				args := sqlutils.Args()
+				args = append(args, sharedArgs...)
+				args = append(args, uniqueKeyArgs...)
+				return append(results, newDmlBuildResult(query, args, 0, err))
+			}
+			if _, isModified := this.updateModifiesUniqueKeyColumns(dmlEvent); isModified || this.migrationContext.RowFilter != "" {
+				// Per --row-filter, the row may newly match or no longer match, and the ghost table
+				// may or may not hold it. The insert, followed by a delete unless matching, covers all.
+				if err := this.resolveJSONDiffs(dmlEvent); err != nil {
+					return append(results, newDmlBuildResultError(err))
+				}
+				// The event keeps its UPDATE type, as counted and audited once applied, and as rebuilt on retry
+				defer func() { dmlEvent.DML = binlog.UpdateDML }()
 				dmlEvent.DML = binlog.DeleteDML
 				results = append(results, this.buildDMLEventQuery(dmlEvent)...)
 				dmlEvent.DML = binlog.InsertDML
 				results = append(results, this.buildDMLEventQuery(dmlEvent)...)
 				return results
 			}
-			query, sharedArgs, uniqueKeyArgs, err := sql.BuildDMLUpdateQuery(dmlEvent.DatabaseName, this.migrationContext.GetGhostTableName(), this.migrationContext.OriginalTableColumns, this.migrationContext.SharedColumns, this.migrationContext.MappedSharedColumns, &this.migrationContext.UniqueKey.Columns, dmlEvent.NewColumnValues.AbstractValues(), dmlEvent.WhereColumnValues.AbstractValues())
+			if this.migrationContext.CompactUpdates {
+				return append(results, this.buildCompactUpdateQuery(dmlEvent))
+			}
+			query, sharedArgs, uniqueKeyArgs, err := sql.BuildDMLUpdateQuery(this.migrationContext.GetTargetDatabaseName(), this.migrationContext.GetGhostTableName(), this.migrationContext.OriginalTableColumns, this.migrationContext.SharedColumns, this.migrationContext.MappedSharedColumns, &this.migrationContext.UniqueKey.Columns, dmlEvent.NewColumnValues.AbstractValues(), dmlEvent.WhereColumnValues.AbstractValues())
 			args := sqlutils.Args()
 			args = append(args, sharedArgs...)
 			args = append(args, uniqueKeyArgs...)
@@ -1085,13 +2306,251 @@ func (this *Applier) buildDMLEventQuery(dmlEvent *binlog.BinlogDMLEvent) (result
 	return append(results, newDmlBuildResultError(fmt.Errorf("Unknown dml event type: %+v", dmlEvent.DML)))
 }
 
-// ApplyDMLEventQueries applies multiple DML queries onto the _ghost_ table
+// buildCompactUpdateQuery creates an UPDATE query setting only the columns whose values differ between the
+// before and after images of given full-image event, which must not modify the unique key. An event which
+// changes no shared column yields a result with an empty query.
+func (this *Applier) buildCompactUpdateQuery(dmlEvent *binlog.BinlogDMLEvent) *dmlBuildResult {
+	uniqueKeyColumns := &this.migrationContext.UniqueKey.Columns
+	sharedColumns, mappedSharedColumns := sql.FilterChangedColumns(this.migrationContext.OriginalTableColumns, this.migrationContext.SharedColumns, this.migrationContext.MappedSharedColumns, uniqueKeyColumns, dmlEvent.WhereColumnValues, dmlEvent.NewColumnValues)
+	// Bytes saved are the values of the shared columns a full UPDATE would have set, and did not change
+	var bytesSaved int64
+	for _, column := range this.migrationContext.SharedColumns.Columns() {
+		if sharedColumns.GetColumn(column.Name) == nil {
+			bytesSaved += valueSize(dmlEvent.NewColumnValues.AbstractValues()[this.migrationContext.OriginalTableColumns.Ordinals[column.Name]])
+		}
+	}
+	if sharedColumns.Len() == uniqueKeyColumns.Len() {
+		// Only the unchanged unique key columns remain: there is nothing to apply
+		for _, column := range uniqueKeyColumns.Columns() {
+			bytesSaved += 2 * valueSize(dmlEvent.WhereColumnValues.AbstractValues()[this.migrationContext.OriginalTableColumns.Ordinals[column.Name]])
+		}
+		result := newDmlBuildResult("", nil, 0, nil)
+		result.bytesSaved = bytesSaved
+		return result
+	}
+	query, sharedArgs, uniqueKeyArgs, err := sql.BuildDMLUpdateQuery(this.migrationContext.GetTargetDatabaseName(), this.migrationContext.GetGhostTableName(), this.migrationContext.OriginalTableColumns, sharedColumns, mappedSharedColumns, uniqueKeyColumns, dmlEvent.NewColumnValues.AbstractValues(), dmlEvent.WhereColumnValues.AbstractValues())
+	args := sqlutils.Args()
+	args = append(args, sharedArgs...)
+	args = append(args, uniqueKeyArgs...)
+	result := newDmlBuildResult(query, args, 0, err)
+	result.bytesSaved = bytesSaved
+	return result
+}
+
+// estimateDMLEventSize estimates the bytes a DML event's values take in an interpolated statement.
+// String values are assumed to be fully escaped.
+func estimateDMLEventSize(dmlEvent *binlog.BinlogDMLEvent) (size int64) {
+	for _, values := range []*sql.ColumnValues{dmlEvent.WhereColumnValues, dmlEvent.NewColumnValues} {
+		if values == nil {
+			continue
+		}
+		for _, value := range values.AbstractValues() {
+			switch value := value.(type) {
+			case string:
+				size += int64(2*len(value) + 4)
+			case []byte:
+				size += int64(2*len(value) + 4)
+			default:
+				size += 32
+			}
+		}
+	}
+	return size
+}
+
+// rowImageSize returns the bytes of a row image's values, as sent unescaped
+func rowImageSize(values *sql.ColumnValues) (size int64) {
+	if values == nil {
+		return 0
+	}
+	for _, value := range values.AbstractValues() {
+		size += valueSize(value)
+	}
+	return size
+}
+
+// valueSize returns the bytes of a single column value, as sent unescaped
+func valueSize(value interface{}) int64 {
+	switch value := value.(type) {
+	case string:
+		return int64(len(value))
+	case []byte:
+		return int64(len(value))
+	}
+	return 8
+}
+
+// dmlEventRowSize returns the bytes of the largest row image of a DML event
+func dmlEventRowSize(dmlEvent *binlog.BinlogDMLEvent) int64 {
+	whereSize := rowImageSize(dmlEvent.WhereColumnValues)
+	newSize := rowImageSize(dmlEvent.NewColumnValues)
+	if whereSize > newSize {
+		return whereSize
+	}
+	return newSize
+}
+
+// dmlEventsSize returns the bytes of the row images of given DML events
+func dmlEventsSize(dmlEvents [](*binlog.BinlogDMLEvent)) (size int64) {
+	for _, dmlEvent := range dmlEvents {
+		size += rowImageSize(dmlEvent.WhereColumnValues) + rowImageSize(dmlEvent.NewColumnValues)
+	}
+	return size
+}
+
+// dmlEventUniqueKeyValues returns the unique key values of the row a DML event writes, for logging
+func (this *Applier) dmlEventUniqueKeyValues(dmlEvent *binlog.BinlogDMLEvent) string {
+	values := dmlEvent.WhereColumnValues
+	if values == nil {
+		values = dmlEvent.NewColumnValues
+	}
+	var uniqueKeyValues []string
+	for _, column := range this.migrationContext.UniqueKey.Columns.Columns() {
+		ordinal, ok := this.migrationContext.OriginalTableColumns.Ordinals[column.Name]
+		if !ok || values == nil || ordinal >= len(values.AbstractValues()) {
+			continue
+		}
+		uniqueKeyValues = append(uniqueKeyValues, fmt.Sprintf("%s=%v", column.Name, values.StringColumn(ordinal)))
+	}
+	return strings.Join(uniqueKeyValues, ", ")
+}
+
+// buildDMLEventQueries builds the queries applying given DML events, in order. Consecutive INSERT events
+// (with the same present columns) coalesce into a multi-row REPLACE, and consecutive DELETE events into a
+// single DELETE, as long as the statement is estimated to fit within the packet budget, and within
+// --dml-batch-max-bytes if set; an oversized row is applied on its own. UPDATE events break a run of coalesced events, thus preserving the order of events.
+// A row which cannot fit within max_allowed_packet fails the batch.
+func (this *Applier) buildDMLEventQueries(dmlEvents [](*binlog.BinlogDMLEvent)) (results [](*dmlBuildResult)) {
+	maxStatementSize := this.migrationContext.GetPacketBudget()
+	if maxBatchBytes := atomic.LoadInt64(&this.migrationContext.DMLBatchMaxBytes); maxBatchBytes > 0 && maxBatchBytes < maxStatementSize {
+		maxStatementSize = maxBatchBytes
+	}
+
+	var pendingEvents [](*binlog.BinlogDMLEvent)
+	var pendingColumns string
+	var pendingSize int64
+	flush := func() {
+		if len(pendingEvents) > 0 {
+			results = append(results, this.buildCoalescedDMLEventQuery(pendingEvents)...)
+		}
+		pendingEvents = nil
+		pendingSize = 0
+	}
+	for _, dmlEvent := range dmlEvents {
+		rowSize := dmlEventRowSize(dmlEvent)
+		if rowSize > this.migrationContext.GetMaxAllowedPacket() {
+			err := fmt.Errorf("Row with unique key (%s) on %s.%s takes %d bytes, exceeding max_allowed_packet (%d) on applier. Bailing out",
+				this.dmlEventUniqueKeyValues(dmlEvent), sql.EscapeName(dmlEvent.DatabaseName), sql.EscapeName(dmlEvent.TableName), rowSize, this.migrationContext.GetMaxAllowedPacket())
+			return append(results, newDmlBuildResultError(err))
+		}
+		this.migrationContext.ObserveRowSize(rowSize)
+
+		var columns string
+		switch {
+		case this.migrationContext.UsingSurrogateKey:
+			// rows are matched by all columns, one at a time
+			flush()
+			results = append(results, this.buildDMLEventQuery(dmlEvent)...)
+			continue
+		case dmlEvent.DML == binlog.InsertDML:
+			_, mappedSharedColumns := sql.FilterPresentColumns(this.migrationContext.OriginalTableColumns, this.migrationContext.SharedColumns, this.migrationContext.MappedSharedColumns, dmlEvent.NewColumnValues)
+			columns = strings.Join(mappedSharedColumns.Names(), ",")
+		case dmlEvent.DML == binlog.DeleteDML:
+			// rows are identified by unique key columns, always present
+		default:
+			flush()
+			results = append(results, this.buildDMLEventQuery(dmlEvent)...)
+			continue
+		}
+		size := estimateDMLEventSize(dmlEvent)
+		if len(pendingEvents) > 0 && (dmlEvent.DML != pendingEvents[0].DML || columns != pendingColumns || pendingSize+size > maxStatementSize) {
+			flush()
+		}
+		pendingEvents = append(pendingEvents, dmlEvent)
+		pendingColumns = columns
+		pendingSize += size
+	}
+	flush()
+	return results
+}
+
+// buildCoalescedDMLEventQuery builds a single query for multiple INSERT events, or for multiple DELETE events
+func (this *Applier) buildCoalescedDMLEventQuery(dmlEvents [](*binlog.BinlogDMLEvent)) (results [](*dmlBuildResult)) {
+	if len(dmlEvents) == 1 {
+		return this.buildDMLEventQuery(dmlEvents[0])
+	}
+	rowsArgs := make([][]interface{}, len(dmlEvents))
+	switch dmlEvents[0].DML {
+	case binlog.DeleteDML:
+		{
+			for i, dmlEvent := range dmlEvents {
+				rowsArgs[i] = dmlEvent.WhereColumnValues.AbstractValues()
+			}
+			query, uniqueKeyArgs, err := sql.BuildDMLMultiDeleteQuery(this.migrationContext.GetTargetDatabaseName(), this.migrationContext.GetGhostTableName(), this.migrationContext.OriginalTableColumns, &this.migrationContext.UniqueKey.Columns, rowsArgs)
+			return append(results, newDmlBuildResult(query, uniqueKeyArgs, -1, err))
+		}
+	case binlog.InsertDML:
+		{
+			for i, dmlEvent := range dmlEvents {
+				rowsArgs[i] = dmlEvent.NewColumnValues.AbstractValues()
+			}
+			sharedColumns, mappedSharedColumns := sql.FilterPresentColumns(this.migrationContext.OriginalTableColumns, this.migrationContext.SharedColumns, this.migrationContext.MappedSharedColumns, dmlEvents[0].NewColumnValues)
+			query, sharedArgs, err := sql.BuildDMLMultiInsertQuery(this.migrationContext.GetTargetDatabaseName(), this.migrationContext.GetGhostTableName(), this.migrationContext.OriginalTableColumns, sharedColumns, mappedSharedColumns, rowsArgs)
+			results = append(results, newDmlBuildResult(query, sharedArgs, 1, err))
+			return append(results, this.buildRowFilterDeleteQuery(dmlEvents...)...)
+		}
+	}
+	return append(results, newDmlBuildResultError(fmt.Errorf("Cannot coalesce dml event type: %+v", dmlEvents[0].DML)))
+}
+
+// dmlRetryBackoff returns the interval to wait before given retry of a DML batch: exponentially
+// growing from dmlRetryInitialBackoff, and capped by the exponential backoff max interval
+func (this *Applier) dmlRetryBackoff(retry int64) time.Duration {
+	maxInterval := time.Duration(this.migrationContext.ExponentialBackoffMaxInterval) * time.Second
+	interval := dmlRetryInitialBackoff
+	for i := int64(1); i < retry && interval < maxInterval; i++ {
+		interval *= 2
+	}
+	if interval > maxInterval {
+		interval = maxInterval
+	}
+	return interval
+}
+
+// ApplyDMLEventQueries applies multiple DML queries onto the _ghost_ table.
+// A batch rolled back on deadlock or lock wait timeout is applied anew, up to DMLRetryAttempts times;
+// queries are idempotent, such that re-applying the batch is safe.
 func (this *Applier) ApplyDMLEventQueries(dmlEvents [](*binlog.BinlogDMLEvent)) error {
 
 	var totalDelta int64
 
-	err := func() error {
-		tx, err := this.db.Begin()
+	buildResults := this.buildDMLEventQueries(dmlEvents)
+	startTime := time.Now()
+	applyBatch := func() error {
+		totalDelta = 0
+		for _, buildResult := range buildResults {
+			if buildResult.err != nil {
+				return buildResult.err
+			}
+		}
+		db := this.db
+		var stmts [](*gosql.Stmt)
+		if this.dmlStatements != nil {
+			// Statements are prepared ahead of the transaction, which holds the single connection
+			db = this.dmlDB
+			for _, buildResult := range buildResults {
+				if buildResult.query == "" {
+					stmts = append(stmts, nil)
+					continue
+				}
+				stmt, err := this.dmlStatements.prepare(buildResult.query)
+				if err != nil {
+					return fmt.Errorf("%s; query=%s", err.Error(), buildResult.query)
+				}
+				stmts = append(stmts, stmt)
+			}
+		}
+		tx, err := db.Begin()
 		if err != nil {
 			return err
 		}
@@ -1101,49 +2560,83 @@ func (this *Applier) ApplyDMLEventQueries(dmlEvents [](*binlog.BinlogDMLEvent))
 			return err
 		}
 
-		sessionQuery := "SET SESSION time_zone = '+00:00'"
-
-		sqlModeAddendum := `,NO_AUTO_VALUE_ON_ZERO`
-		if !this.migrationContext.SkipStrictMode {
-			sqlModeAddendum = fmt.Sprintf("%s,STRICT_ALL_TABLES", sqlModeAddendum)
+		sessionQuery := fmt.Sprintf("SET SESSION time_zone = '%s'", this.migrationContext.ConnectionTimeZone)
+		if this.migrationContext.PreserveForeignKeys {
+			// Once created on the ghost table, foreign keys must not fail events the original table accepted
+			sessionQuery = fmt.Sprintf("%s, foreign_key_checks = 0", sessionQuery)
 		}
-		sessionQuery = fmt.Sprintf("%s, sql_mode = CONCAT(@@session.sql_mode, ',%s')", sessionQuery, sqlModeAddendum)
 
 		if _, err := tx.Exec(sessionQuery); err != nil {
 			return rollback(err)
 		}
-		for _, dmlEvent := range dmlEvents {
-			for _, buildResult := range this.buildDMLEventQuery(dmlEvent) {
-				if buildResult.err != nil {
-					return rollback(buildResult.err)
-				}
-				result, err := tx.Exec(buildResult.query, buildResult.args...)
-				if err != nil {
-					err = fmt.Errorf("%s; query=%s; args=%+v", err.Error(), buildResult.query, buildResult.args)
+		for i, buildResult := range buildResults {
+			if buildResult.query == "" {
+				continue
+			}
+			var result gosql.Result
+			if stmts != nil {
+				result, err = tx.Stmt(stmts[i]).Exec(buildResult.args...)
+			} else {
+				result, err = tx.Exec(buildResult.query, buildResult.args...)
+			}
+			if err != nil {
+				if mysql.IsPacketTooLargeError(err) {
+					// Arguments are too large to log
+					err = fmt.Errorf("%w; statement exceeds max_allowed_packet (%d), packet budget is %d bytes. Consider --max-packet-budget; query=%s", err, this.migrationContext.GetMaxAllowedPacket(), this.migrationContext.GetPacketBudget(), buildResult.query)
 					return rollback(err)
 				}
+				err = fmt.Errorf("%w; query=%s; args=%+v", err, buildResult.query, buildResult.args)
+				return rollback(err)
+			}
 
-				rowsAffected, err := result.RowsAffected()
-				if err != nil {
-					log.Warningf("error getting rows affected from DML event query: %s. i'm going to assume that the DML affected a single row, but this may result in inaccurate statistics", err)
-					rowsAffected = 1
-				}
-				// each DML is either a single insert (delta +1), update (delta +0) or delete (delta -1).
-				// multiplying by the rows actually affected (either 0 or 1) will give an accurate row delta for this DML event
-				totalDelta += buildResult.rowsDelta * rowsAffected
+			rowsAffected, err := result.RowsAffected()
+			if err != nil {
+				log.Warningf("error getting rows affected from DML event query: %s. i'm going to assume that the DML affected a single row, but this may result in inaccurate statistics", err)
+				rowsAffected = 1
 			}
+			// each DML is either an insert (delta +1), update (delta +0) or delete (delta -1), possibly coalesced
+			// over multiple rows. multiplying by the rows actually affected will give an accurate row delta for this DML
+			totalDelta += buildResult.rowsDelta * rowsAffected
 		}
 		if err := tx.Commit(); err != nil {
 			return err
 		}
 		return nil
-	}()
+	}
+	err := applyBatch()
+	for retry := int64(1); err != nil && mysql.IsLockContentionError(err) && retry <= this.migrationContext.DMLRetryAttempts; retry++ {
+		backoff := this.dmlRetryBackoff(retry)
+		this.migrationContext.Log.Warningf("ApplyDMLEventQueries() rolled back on lock contention; retrying batch of %d events in %+v (%d/%d): %+v",
+			len(dmlEvents), backoff, retry, this.migrationContext.DMLRetryAttempts, err,
+		)
+		atomic.AddInt64(&this.migrationContext.DMLRetries, 1)
+		time.Sleep(backoff)
+		err = applyBatch()
+	}
 
 	if err != nil {
+		// The ghost table may have changed underneath; statements are prepared anew on retry
+		this.dmlStatements.invalidate()
 		return this.migrationContext.Log.Errore(err)
 	}
 	// no error
 	atomic.AddInt64(&this.migrationContext.TotalDMLEventsApplied, int64(len(dmlEvents)))
+	for _, dmlEvent := range dmlEvents {
+		switch dmlEvent.DML {
+		case binlog.InsertDML:
+			atomic.AddInt64(&this.migrationContext.DMLInsertsApplied, 1)
+		case binlog.UpdateDML:
+			atomic.AddInt64(&this.migrationContext.DMLUpdatesApplied, 1)
+		case binlog.DeleteDML:
+			atomic.AddInt64(&this.migrationContext.DMLDeletesApplied, 1)
+		}
+	}
+	atomic.AddInt64(&this.migrationContext.DMLApplyTransactions, 1)
+	atomic.AddInt64(&this.migrationContext.DMLApplyBytes, dmlEventsSize(dmlEvents))
+	for _, buildResult := range buildResults {
+		atomic.AddInt64(&this.migrationContext.CompactUpdatesBytesSaved, buildResult.bytesSaved)
+	}
+	atomic.AddInt64(&this.migrationContext.DMLEventsApplyNanos, int64(time.Since(startTime)))
 	if this.migrationContext.CountTableRows {
 		atomic.AddInt64(&this.migrationContext.RowsDeltaEstimate, totalDelta)
 	}
@@ -1155,5 +2648,9 @@ func (this *Applier) Teardown() {
 	this.migrationContext.Log.Debugf("Tearing down...")
 	this.db.Close()
 	this.singletonDB.Close()
+	if this.dmlDB != nil {
+		this.dmlStatements.invalidate()
+		this.dmlDB.Close()
+	}
 	atomic.StoreInt64(&this.finishedMigrating, 1)
 }
@@ -6,8 +6,14 @@
 package logic
 
 import (
+	gosql "database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	test "github.com/openark/golib/tests"
 
@@ -50,6 +56,24 @@ func TestApplierGenerateSqlModeQuery(t *testing.T) {
 			`sql_mode = REPLACE(REPLACE(CONCAT(@@session.sql_mode, ',NO_AUTO_VALUE_ON_ZERO'), 'NO_ZERO_IN_DATE', ''), 'NO_ZERO_DATE', '')`,
 		)
 	}
+	{
+		// A given sql_mode replaces the server's, and is not made strict
+		migrationContext.SkipStrictMode = false
+		migrationContext.AllowZeroInDate = false
+		migrationContext.SQLMode = "NO_ENGINE_SUBSTITUTION"
+		test.S(t).ExpectEquals(
+			applier.generateSqlModeQuery(),
+			`sql_mode = CONCAT('NO_ENGINE_SUBSTITUTION', ',NO_AUTO_VALUE_ON_ZERO')`,
+		)
+	}
+	{
+		migrationContext.AllowZeroInDate = true
+		migrationContext.SQLMode = "STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE"
+		test.S(t).ExpectEquals(
+			applier.generateSqlMode(),
+			`REPLACE(REPLACE(CONCAT('STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE', ',NO_AUTO_VALUE_ON_ZERO'), 'NO_ZERO_IN_DATE', ''), 'NO_ZERO_DATE', '')`,
+		)
+	}
 }
 
 func TestApplierUpdateModifiesUniqueKeyColumns(t *testing.T) {
@@ -93,6 +117,7 @@ func TestApplierBuildDMLEventQuery(t *testing.T) {
 	columnValues := sql.ToColumnValues([]interface{}{123456, 42})
 
 	migrationContext := base.NewMigrationContext()
+	migrationContext.DatabaseName = "test"
 	migrationContext.OriginalTableName = "test"
 	migrationContext.OriginalTableColumns = columns
 	migrationContext.SharedColumns = columns
@@ -171,6 +196,412 @@ func TestApplierBuildDMLEventQuery(t *testing.T) {
 	})
 }
 
+func TestApplierBuildCompactUpdateQuery(t *testing.T) {
+	columns := sql.NewColumnList([]string{"id", "doc", "counter"})
+
+	migrationContext := base.NewMigrationContext()
+	migrationContext.DatabaseName = "test"
+	migrationContext.OriginalTableName = "test"
+	migrationContext.OriginalTableColumns = columns
+	migrationContext.SharedColumns = columns
+	migrationContext.MappedSharedColumns = columns
+	migrationContext.UniqueKey = &sql.UniqueKey{
+		Columns: *sql.NewColumnList([]string{"id"}),
+	}
+	migrationContext.CompactUpdates = true
+	applier := NewApplier(migrationContext)
+
+	t.Run("changed", func(t *testing.T) {
+		binlogEvent := &binlog.BinlogDMLEvent{
+			DatabaseName:      "test",
+			DML:               binlog.UpdateDML,
+			WhereColumnValues: sql.ToColumnValues([]interface{}{123456, []byte(`{"large":"document"}`), 1}),
+			NewColumnValues:   sql.ToColumnValues([]interface{}{123456, []byte(`{"large":"document"}`), 2}),
+		}
+		res := applier.buildDMLEventQuery(binlogEvent)
+		test.S(t).ExpectEquals(len(res), 1)
+		test.S(t).ExpectNil(res[0].err)
+		test.S(t).ExpectTrue(strings.Contains(res[0].query, "`id`=?, `counter`=?\n"))
+		test.S(t).ExpectFalse(strings.Contains(res[0].query, "`doc`"))
+		test.S(t).ExpectTrue(reflect.DeepEqual(res[0].args, []interface{}{123456, 2, 123456}))
+		test.S(t).ExpectEquals(res[0].bytesSaved, int64(20))
+	})
+
+	t.Run("unchanged", func(t *testing.T) {
+		values := sql.ToColumnValues([]interface{}{123456, []byte(`{"large":"document"}`), 1})
+		binlogEvent := &binlog.BinlogDMLEvent{
+			DatabaseName:      "test",
+			DML:               binlog.UpdateDML,
+			WhereColumnValues: values,
+			NewColumnValues:   values,
+		}
+		res := applier.buildDMLEventQuery(binlogEvent)
+		test.S(t).ExpectEquals(len(res), 1)
+		test.S(t).ExpectNil(res[0].err)
+		test.S(t).ExpectEquals(res[0].query, "")
+		// all of doc and counter, and id in both set and where clauses
+		test.S(t).ExpectEquals(res[0].bytesSaved, int64(20+8+2*8))
+	})
+
+	t.Run("unique key modified", func(t *testing.T) {
+		binlogEvent := &binlog.BinlogDMLEvent{
+			DatabaseName:      "test",
+			DML:               binlog.UpdateDML,
+			WhereColumnValues: sql.ToColumnValues([]interface{}{123456, []byte(`{"large":"document"}`), 1}),
+			NewColumnValues:   sql.ToColumnValues([]interface{}{123457, []byte(`{"large":"document"}`), 1}),
+		}
+		res := applier.buildDMLEventQuery(binlogEvent)
+		test.S(t).ExpectEquals(len(res), 2)
+		test.S(t).ExpectTrue(strings.HasPrefix(strings.TrimSpace(res[0].query), "delete"))
+		test.S(t).ExpectTrue(strings.HasPrefix(strings.TrimSpace(res[1].query), "replace"))
+		test.S(t).ExpectEquals(res[1].bytesSaved, int64(0))
+	})
+}
+
+func TestApplierBuildSurrogateKeyDMLEventQuery(t *testing.T) {
+	columns := sql.NewColumnList([]string{"id", "item_id"})
+
+	migrationContext := base.NewMigrationContext()
+	migrationContext.DatabaseName = "test"
+	migrationContext.OriginalTableName = "test"
+	migrationContext.OriginalTableColumns = columns
+	migrationContext.SharedColumns = columns
+	migrationContext.MappedSharedColumns = columns
+	migrationContext.UniqueKey = &sql.UniqueKey{
+		Columns: *sql.NewColumnList([]string{"id"}),
+	}
+	migrationContext.UsingSurrogateKey = true
+	applier := NewApplier(migrationContext)
+
+	binlogEvent := &binlog.BinlogDMLEvent{
+		DatabaseName:      "test",
+		DML:               binlog.UpdateDML,
+		NewColumnValues:   sql.ToColumnValues([]interface{}{123456, 43}),
+		WhereColumnValues: sql.ToColumnValues([]interface{}{123456, 42}),
+	}
+	t.Run("update", func(t *testing.T) {
+		res := applier.buildDMLEventQueries([]*binlog.BinlogDMLEvent{binlogEvent})
+		test.S(t).ExpectEquals(len(res), 2)
+		test.S(t).ExpectNil(res[0].err)
+		test.S(t).ExpectTrue(strings.HasPrefix(strings.TrimSpace(res[0].query), "delete"))
+		test.S(t).ExpectTrue(strings.Contains(res[0].query, "not exists (select 1 from `test`.`test`"))
+		test.S(t).ExpectTrue(reflect.DeepEqual(res[0].args, []interface{}{123456, 42, 123456, 42}))
+		test.S(t).ExpectNil(res[1].err)
+		test.S(t).ExpectTrue(strings.HasPrefix(strings.TrimSpace(res[1].query), "insert"))
+		test.S(t).ExpectTrue(reflect.DeepEqual(res[1].args, []interface{}{123456, 43, 123456, 43, 123456, 43}))
+	})
+
+	t.Run("cut-over", func(t *testing.T) {
+		migrationContext.InCutOverCriticalSectionFlag = 1
+		defer func() { migrationContext.InCutOverCriticalSectionFlag = 0 }()
+		binlogEvent.DML = binlog.UpdateDML
+		res := applier.buildDMLEventQueries([]*binlog.BinlogDMLEvent{binlogEvent})
+		test.S(t).ExpectEquals(len(res), 2)
+		test.S(t).ExpectFalse(strings.Contains(res[0].query, "`test`.`test`"))
+		test.S(t).ExpectTrue(reflect.DeepEqual(res[0].args, []interface{}{123456, 42}))
+		test.S(t).ExpectFalse(strings.Contains(res[1].query, "`test`.`test`"))
+		test.S(t).ExpectTrue(reflect.DeepEqual(res[1].args, []interface{}{123456, 43, 123456, 43}))
+	})
+
+	t.Run("target-database", func(t *testing.T) {
+		migrationContext.TargetDatabaseName = "target"
+		defer func() { migrationContext.TargetDatabaseName = "" }()
+		binlogEvent.DML = binlog.UpdateDML
+		res := applier.buildDMLEventQueries([]*binlog.BinlogDMLEvent{binlogEvent})
+		test.S(t).ExpectEquals(len(res), 2)
+		test.S(t).ExpectTrue(strings.Contains(res[0].query, "`target`.`_test_gho`"))
+		test.S(t).ExpectFalse(strings.Contains(res[0].query, "`test`.`_test_gho`"))
+		test.S(t).ExpectTrue(strings.Contains(res[0].query, "not exists (select 1 from `test`.`test`"))
+		test.S(t).ExpectTrue(strings.Contains(res[1].query, "`target`.`_test_gho`"))
+		test.S(t).ExpectFalse(strings.Contains(res[1].query, "`test`.`_test_gho`"))
+		test.S(t).ExpectTrue(strings.Contains(res[1].query, "exists (select 1 from `test`.`test`"))
+	})
+}
+
+func TestApplierBuildRowFilterDMLEventQuery(t *testing.T) {
+	columns := sql.NewColumnList([]string{"id", "item_id"})
+
+	migrationContext := base.NewMigrationContext()
+	migrationContext.DatabaseName = "test"
+	migrationContext.OriginalTableName = "test"
+	migrationContext.OriginalTableColumns = columns
+	migrationContext.SharedColumns = columns
+	migrationContext.MappedSharedColumns = columns
+	migrationContext.UniqueKey = &sql.UniqueKey{
+		Columns: *sql.NewColumnList([]string{"id"}),
+	}
+	migrationContext.RowFilter = "item_id > 42"
+	applier := NewApplier(migrationContext)
+
+	t.Run("insert", func(t *testing.T) {
+		binlogEvents := []*binlog.BinlogDMLEvent{
+			{DatabaseName: "test", DML: binlog.InsertDML, NewColumnValues: sql.ToColumnValues([]interface{}{123456, 42})},
+			{DatabaseName: "test", DML: binlog.InsertDML, NewColumnValues: sql.ToColumnValues([]interface{}{123457, 43})},
+		}
+		res := applier.buildDMLEventQueries(binlogEvents)
+		test.S(t).ExpectEquals(len(res), 2)
+		test.S(t).ExpectNil(res[0].err)
+		test.S(t).ExpectTrue(strings.HasPrefix(strings.TrimSpace(res[0].query), "replace"))
+		test.S(t).ExpectEquals(res[0].rowsDelta, int64(1))
+		test.S(t).ExpectNil(res[1].err)
+		test.S(t).ExpectTrue(strings.HasPrefix(strings.TrimSpace(res[1].query), "delete"))
+		test.S(t).ExpectTrue(strings.Contains(res[1].query, "((item_id > 42) is not true)"))
+		test.S(t).ExpectTrue(reflect.DeepEqual(res[1].args, []interface{}{123456, 123457}))
+		test.S(t).ExpectEquals(res[1].rowsDelta, int64(-1))
+	})
+
+	t.Run("update", func(t *testing.T) {
+		binlogEvent := &binlog.BinlogDMLEvent{
+			DatabaseName:      "test",
+			DML:               binlog.UpdateDML,
+			NewColumnValues:   sql.ToColumnValues([]interface{}{123456, 43}),
+			WhereColumnValues: sql.ToColumnValues([]interface{}{123456, 42}),
+		}
+		res := applier.buildDMLEventQueries([]*binlog.BinlogDMLEvent{binlogEvent})
+		test.S(t).ExpectEquals(len(res), 3)
+		test.S(t).ExpectTrue(strings.HasPrefix(strings.TrimSpace(res[0].query), "delete"))
+		test.S(t).ExpectTrue(reflect.DeepEqual(res[0].args, []interface{}{123456}))
+		test.S(t).ExpectTrue(strings.HasPrefix(strings.TrimSpace(res[1].query), "replace"))
+		test.S(t).ExpectTrue(reflect.DeepEqual(res[1].args, []interface{}{123456, 43}))
+		test.S(t).ExpectTrue(strings.Contains(res[2].query, "is not true"))
+		test.S(t).ExpectTrue(reflect.DeepEqual(res[2].args, []interface{}{123456}))
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		binlogEvent := &binlog.BinlogDMLEvent{
+			DatabaseName:      "test",
+			DML:               binlog.DeleteDML,
+			WhereColumnValues: sql.ToColumnValues([]interface{}{123456, 42}),
+		}
+		res := applier.buildDMLEventQueries([]*binlog.BinlogDMLEvent{binlogEvent})
+		test.S(t).ExpectEquals(len(res), 1)
+		test.S(t).ExpectTrue(strings.HasPrefix(strings.TrimSpace(res[0].query), "delete"))
+		test.S(t).ExpectFalse(strings.Contains(res[0].query, "is not true"))
+	})
+}
+
+func TestApplierBuildDMLEventQueries(t *testing.T) {
+	columns := sql.NewColumnList([]string{"id", "item_id"})
+
+	migrationContext := base.NewMigrationContext()
+	migrationContext.DatabaseName = "test"
+	migrationContext.OriginalTableName = "test"
+	migrationContext.OriginalTableColumns = columns
+	migrationContext.SharedColumns = columns
+	migrationContext.MappedSharedColumns = columns
+	migrationContext.UniqueKey = &sql.UniqueKey{
+		Name:    t.Name(),
+		Columns: *sql.NewColumnList([]string{"id"}),
+	}
+	applier := NewApplier(migrationContext)
+
+	dmlEvent := func(dml binlog.EventDML, id int, itemId interface{}) *binlog.BinlogDMLEvent {
+		columnValues := sql.ToColumnValues([]interface{}{id, itemId})
+		dmlEvent := &binlog.BinlogDMLEvent{DatabaseName: "test", DML: dml}
+		switch dml {
+		case binlog.InsertDML:
+			dmlEvent.NewColumnValues = columnValues
+		case binlog.DeleteDML:
+			dmlEvent.WhereColumnValues = columnValues
+		case binlog.UpdateDML:
+			dmlEvent.NewColumnValues = columnValues
+			dmlEvent.WhereColumnValues = columnValues
+		}
+		return dmlEvent
+	}
+
+	t.Run("coalesce", func(t *testing.T) {
+		res := applier.buildDMLEventQueries([]*binlog.BinlogDMLEvent{
+			dmlEvent(binlog.InsertDML, 1, 11),
+			dmlEvent(binlog.InsertDML, 2, 12),
+			dmlEvent(binlog.InsertDML, 3, 13),
+			dmlEvent(binlog.UpdateDML, 2, 12),
+			dmlEvent(binlog.InsertDML, 4, 14),
+			dmlEvent(binlog.DeleteDML, 1, 11),
+			dmlEvent(binlog.DeleteDML, 3, 13),
+		})
+		test.S(t).ExpectEquals(len(res), 4)
+		for _, buildResult := range res {
+			test.S(t).ExpectNil(buildResult.err)
+		}
+		test.S(t).ExpectTrue(strings.HasSuffix(strings.TrimSpace(res[0].query), "(?, ?), (?, ?), (?, ?)"))
+		test.S(t).ExpectTrue(reflect.DeepEqual(res[0].args, []interface{}{1, 11, 2, 12, 3, 13}))
+		test.S(t).ExpectEquals(res[0].rowsDelta, int64(1))
+		test.S(t).ExpectTrue(strings.HasPrefix(strings.TrimSpace(res[1].query), "update"))
+		test.S(t).ExpectTrue(strings.HasSuffix(strings.TrimSpace(res[2].query), "values\n\t\t\t\t\t(?, ?)"))
+		test.S(t).ExpectTrue(reflect.DeepEqual(res[2].args, []interface{}{4, 14}))
+		test.S(t).ExpectTrue(strings.HasSuffix(strings.TrimSpace(res[3].query), "`id` in (?, ?)"))
+		test.S(t).ExpectTrue(reflect.DeepEqual(res[3].args, []interface{}{1, 3}))
+		test.S(t).ExpectEquals(res[3].rowsDelta, int64(-1))
+	})
+
+	t.Run("max-allowed-packet", func(t *testing.T) {
+		migrationContext.ApplierMaxAllowedPacket = 1024
+		defer func() { migrationContext.ApplierMaxAllowedPacket = 0 }()
+		value := strings.Repeat("x", 100)
+		res := applier.buildDMLEventQueries([]*binlog.BinlogDMLEvent{
+			dmlEvent(binlog.InsertDML, 1, value),
+			dmlEvent(binlog.InsertDML, 2, value),
+			dmlEvent(binlog.InsertDML, 3, value),
+		})
+		// Each row is estimated at 236 bytes, with room for 512 bytes per statement
+		test.S(t).ExpectEquals(len(res), 2)
+		test.S(t).ExpectEquals(len(res[0].args), 4)
+		test.S(t).ExpectEquals(len(res[1].args), 2)
+	})
+
+	t.Run("max-packet-budget", func(t *testing.T) {
+		migrationContext.MaxPacketBudget = 300
+		defer func() { migrationContext.MaxPacketBudget = 0 }()
+		res := applier.buildDMLEventQueries([]*binlog.BinlogDMLEvent{
+			dmlEvent(binlog.InsertDML, 1, strings.Repeat("x", 200)),
+			dmlEvent(binlog.InsertDML, 2, "y"),
+			dmlEvent(binlog.InsertDML, 3, "z"),
+		})
+		// The oversized row is applied on its own
+		test.S(t).ExpectEquals(len(res), 2)
+		test.S(t).ExpectEquals(len(res[0].args), 2)
+		test.S(t).ExpectEquals(len(res[1].args), 4)
+		test.S(t).ExpectEquals(migrationContext.MaxObservedRowSize, int64(208))
+	})
+
+	t.Run("dml-batch-max-bytes", func(t *testing.T) {
+		migrationContext.DMLBatchMaxBytes = 300
+		defer func() { migrationContext.DMLBatchMaxBytes = 0 }()
+		value := strings.Repeat("x", 100)
+		res := applier.buildDMLEventQueries([]*binlog.BinlogDMLEvent{
+			dmlEvent(binlog.InsertDML, 1, value),
+			dmlEvent(binlog.InsertDML, 2, value),
+			dmlEvent(binlog.InsertDML, 3, value),
+		})
+		// Each row is estimated at 236 bytes
+		test.S(t).ExpectEquals(len(res), 3)
+		test.S(t).ExpectEquals(dmlEventsSize([]*binlog.BinlogDMLEvent{dmlEvent(binlog.UpdateDML, 1, value)}), int64(216))
+	})
+
+	t.Run("row-exceeds-max-allowed-packet", func(t *testing.T) {
+		migrationContext.ApplierMaxAllowedPacket = 1024
+		defer func() { migrationContext.ApplierMaxAllowedPacket = 0 }()
+		res := applier.buildDMLEventQueries([]*binlog.BinlogDMLEvent{
+			dmlEvent(binlog.InsertDML, 1, "x"),
+			dmlEvent(binlog.UpdateDML, 7, strings.Repeat("x", 2000)),
+		})
+		// The batch fails as a whole
+		test.S(t).ExpectEquals(len(res), 1)
+		test.S(t).ExpectNotNil(res[0].err)
+		test.S(t).ExpectTrue(strings.Contains(res[0].err.Error(), "(id=7)"))
+	})
+}
+
+// testExecDriver accepts all statements, recording the queries executed, each affecting a single row
+type testExecDriver struct {
+	queries []string
+}
+
+type testExecConn struct {
+	driver *testExecDriver
+}
+
+type testExecStmt struct {
+	driver *testExecDriver
+	query  string
+}
+
+type testExecTx struct{}
+
+func (this *testExecDriver) Open(name string) (driver.Conn, error) {
+	return &testExecConn{driver: this}, nil
+}
+
+func (this *testExecConn) Prepare(query string) (driver.Stmt, error) {
+	return &testExecStmt{driver: this.driver, query: query}, nil
+}
+
+func (this *testExecConn) Close() error              { return nil }
+func (this *testExecConn) Begin() (driver.Tx, error) { return &testExecTx{}, nil }
+
+func (this *testExecTx) Commit() error   { return nil }
+func (this *testExecTx) Rollback() error { return nil }
+
+func (this *testExecStmt) Close() error  { return nil }
+func (this *testExecStmt) NumInput() int { return -1 }
+func (this *testExecStmt) Exec(args []driver.Value) (driver.Result, error) {
+	this.driver.queries = append(this.driver.queries, strings.TrimSpace(this.query))
+	return driver.RowsAffected(1), nil
+}
+func (this *testExecStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+var testExecDriverInstance = &testExecDriver{}
+var registerTestExecDriver sync.Once
+
+// newTestExecApplier returns an applier onto test.test, whose unique key is id, applying events onto a
+// testExecDriver
+func newTestExecApplier(t *testing.T) (*Applier, *testExecDriver) {
+	registerTestExecDriver.Do(func() {
+		gosql.Register("gh-ost-test-exec", testExecDriverInstance)
+	})
+	db, err := gosql.Open("gh-ost-test-exec", "")
+	test.S(t).ExpectNil(err)
+	t.Cleanup(func() { db.Close() })
+	testExecDriverInstance.queries = nil
+
+	columns := sql.NewColumnList([]string{"id", "item_id"})
+	migrationContext := base.NewMigrationContext()
+	migrationContext.DatabaseName = "test"
+	migrationContext.OriginalTableName = "test"
+	migrationContext.OriginalTableColumns = columns
+	migrationContext.SharedColumns = columns
+	migrationContext.MappedSharedColumns = columns
+	migrationContext.UniqueKey = &sql.UniqueKey{
+		Name:    t.Name(),
+		Columns: *sql.NewColumnList([]string{"id"}),
+	}
+	applier := NewApplier(migrationContext)
+	applier.db = db
+	return applier, testExecDriverInstance
+}
+
+func TestApplierApplyDMLEventQueriesUniqueKeyUpdate(t *testing.T) {
+	applier, testDriver := newTestExecApplier(t)
+	migrationContext := applier.migrationContext
+
+	dmlEvent := &binlog.BinlogDMLEvent{
+		DatabaseName:      "test",
+		DML:               binlog.UpdateDML,
+		WhereColumnValues: sql.ToColumnValues([]interface{}{1, 11}),
+		NewColumnValues:   sql.ToColumnValues([]interface{}{2, 11}),
+	}
+	test.S(t).ExpectNil(applier.ApplyDMLEventQueries([]*binlog.BinlogDMLEvent{dmlEvent}))
+	test.S(t).ExpectEquals(dmlEvent.DML, binlog.UpdateDML)
+	test.S(t).ExpectEquals(migrationContext.DMLUpdatesApplied, int64(1))
+	test.S(t).ExpectEquals(migrationContext.DMLInsertsApplied, int64(0))
+	test.S(t).ExpectEquals(migrationContext.DMLDeletesApplied, int64(0))
+	test.S(t).ExpectEquals(len(testDriver.queries), 3)
+	test.S(t).ExpectTrue(strings.HasPrefix(testDriver.queries[1], "delete"))
+	test.S(t).ExpectTrue(strings.HasPrefix(testDriver.queries[2], "replace"))
+
+	// Applied again, as on retry, the event still deletes the row by its former unique key
+	testDriver.queries = nil
+	test.S(t).ExpectNil(applier.ApplyDMLEventQueries([]*binlog.BinlogDMLEvent{dmlEvent}))
+	test.S(t).ExpectEquals(migrationContext.DMLUpdatesApplied, int64(2))
+	test.S(t).ExpectEquals(len(testDriver.queries), 3)
+	test.S(t).ExpectTrue(strings.HasPrefix(testDriver.queries[1], "delete"))
+}
+
+func TestApplierDMLRetryBackoff(t *testing.T) {
+	migrationContext := base.NewMigrationContext()
+	migrationContext.ExponentialBackoffMaxInterval = 2
+	applier := NewApplier(migrationContext)
+
+	test.S(t).ExpectEquals(applier.dmlRetryBackoff(1), 100*time.Millisecond)
+	test.S(t).ExpectEquals(applier.dmlRetryBackoff(2), 200*time.Millisecond)
+	test.S(t).ExpectEquals(applier.dmlRetryBackoff(5), 1600*time.Millisecond)
+	test.S(t).ExpectEquals(applier.dmlRetryBackoff(6), 2*time.Second)
+	test.S(t).ExpectEquals(applier.dmlRetryBackoff(100), 2*time.Second)
+}
+
 func TestApplierInstantDDL(t *testing.T) {
 	migrationContext := base.NewMigrationContext()
 	migrationContext.DatabaseName = "test"
@@ -180,6 +611,34 @@ func TestApplierInstantDDL(t *testing.T) {
 
 	t.Run("instantDDLstmt", func(t *testing.T) {
 		stmt := applier.generateInstantDDLQuery()
-		test.S(t).ExpectEquals(stmt, "ALTER /* gh-ost */ TABLE `test`.`mytable` ADD INDEX (foo), ALGORITHM=INSTANT")
+		test.S(t).ExpectEquals(stmt, "ALTER /* gh-ost `test`.`_mytable_gho` */ TABLE `test`.`mytable` ADD INDEX (foo), ALGORITHM=INSTANT")
+	})
+}
+
+func TestBlockingSessionString(t *testing.T) {
+	session := &BlockingSession{Id: 17, User: "analytics", Host: "10.0.0.1:51234", Time: 1200, Query: "select count(*) from mytable"}
+	test.S(t).ExpectEquals(session.String(), "session 17 (analytics@10.0.0.1:51234), running for 1200s: select count(*) from mytable")
+
+	session.Query = strings.Repeat("x", 150)
+	test.S(t).ExpectEquals(session.String(), "session 17 (analytics@10.0.0.1:51234), running for 1200s: "+strings.Repeat("x", 100)+"...")
+}
+
+func TestGetGhostAutoIncrementSync(t *testing.T) {
+	partitioned := func(autoIncrement string) string {
+		return "CREATE TABLE `tbl` (\n  `id` int NOT NULL AUTO_INCREMENT,\n  PRIMARY KEY (`id`)\n) ENGINE=InnoDB " + autoIncrement +
+			" DEFAULT CHARSET=utf8mb4 COMMENT='ids (per region)'\n/*!50100 PARTITION BY RANGE (`id`)\n(PARTITION p0 VALUES LESS THAN (1000) ENGINE = InnoDB,\n PARTITION p1 VALUES LESS THAN MAXVALUE ENGINE = InnoDB) */"
+	}
+	t.Run("behind", func(t *testing.T) {
+		test.S(t).ExpectEquals(getGhostAutoIncrementSync(partitioned("AUTO_INCREMENT=1043"), partitioned("AUTO_INCREMENT=1001")), uint64(1043))
+	})
+	t.Run("no ghost counter", func(t *testing.T) {
+		test.S(t).ExpectEquals(getGhostAutoIncrementSync(partitioned("AUTO_INCREMENT=1043"), partitioned("")), uint64(1043))
+	})
+	t.Run("ahead", func(t *testing.T) {
+		test.S(t).ExpectEquals(getGhostAutoIncrementSync(partitioned("AUTO_INCREMENT=1043"), partitioned("AUTO_INCREMENT=1043")), uint64(0))
+		test.S(t).ExpectEquals(getGhostAutoIncrementSync(partitioned("AUTO_INCREMENT=1001"), partitioned("AUTO_INCREMENT=1043")), uint64(0))
+	})
+	t.Run("no original counter", func(t *testing.T) {
+		test.S(t).ExpectEquals(getGhostAutoIncrementSync(partitioned(""), partitioned("")), uint64(0))
 	})
 }
@@ -0,0 +1,107 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package logic
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/github/gh-ost/go/base"
+	"github.com/github/gh-ost/go/binlog"
+	"github.com/github/gh-ost/go/sql"
+)
+
+const auditDMLBufferSize = 10000
+
+// DMLAuditor writes a line per applied binlog DML event onto --audit-dml-file. Writing is
+// asynchronous, and never blocks the apply path: should the file fall behind, lines are
+// dropped and counted.
+type DMLAuditor struct {
+	migrationContext *base.MigrationContext
+	file             *os.File
+	lines            chan string
+	linesMutex       *sync.RWMutex
+	closed           bool
+	done             chan bool
+}
+
+func NewDMLAuditor(migrationContext *base.MigrationContext) *DMLAuditor {
+	return &DMLAuditor{
+		migrationContext: migrationContext,
+		lines:            make(chan string, auditDMLBufferSize),
+		linesMutex:       &sync.RWMutex{},
+		done:             make(chan bool),
+	}
+}
+
+// Open opens the audit file for appending, and begins writing audited lines onto it
+func (this *DMLAuditor) Open() (err error) {
+	if this.file, err = os.OpenFile(this.migrationContext.AuditDMLFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640); err != nil {
+		return err
+	}
+	go this.writeLines()
+	return nil
+}
+
+func (this *DMLAuditor) writeLines() {
+	defer close(this.done)
+
+	writer := bufio.NewWriter(this.file)
+	for line := range this.lines {
+		if _, err := writer.WriteString(line); err != nil {
+			this.migrationContext.Log.Errorf("Error writing to --audit-dml-file: %+v", err)
+		}
+		if len(this.lines) == 0 {
+			writer.Flush()
+		}
+	}
+	writer.Flush()
+}
+
+// formatAuditLine formats an audit line: time, binlog coordinates, DML type, table and originating query
+func formatAuditLine(auditTime time.Time, dmlEvent *binlog.BinlogDMLEvent) string {
+	return fmt.Sprintf("%s\t%s\t%s\t%s.%s\t%s\n",
+		auditTime.Format(time.RFC3339Nano),
+		dmlEvent.Coordinates.DisplayString(),
+		dmlEvent.DML,
+		sql.EscapeName(dmlEvent.DatabaseName),
+		sql.EscapeName(dmlEvent.TableName),
+		strconv.Quote(dmlEvent.Query),
+	)
+}
+
+// Audit queues a line for given, applied, DML event. It does not block.
+func (this *DMLAuditor) Audit(dmlEvent *binlog.BinlogDMLEvent) {
+	line := formatAuditLine(time.Now(), dmlEvent)
+
+	this.linesMutex.RLock()
+	defer this.linesMutex.RUnlock()
+	if this.closed {
+		return
+	}
+	select {
+	case this.lines <- line:
+	default:
+		atomic.AddInt64(&this.migrationContext.AuditDMLDroppedLines, 1)
+	}
+}
+
+// Close writes any queued lines, and closes the audit file
+func (this *DMLAuditor) Close() error {
+	func() {
+		this.linesMutex.Lock()
+		defer this.linesMutex.Unlock()
+		this.closed = true
+		close(this.lines)
+	}()
+	<-this.done
+	return this.file.Close()
+}
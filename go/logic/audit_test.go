@@ -0,0 +1,79 @@
+/*
+   Copyright 2022 GitHub Inc.
+         See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package logic
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	test "github.com/openark/golib/tests"
+
+	"github.com/github/gh-ost/go/base"
+	"github.com/github/gh-ost/go/binlog"
+	"github.com/github/gh-ost/go/mysql"
+	"github.com/github/gh-ost/go/sql"
+)
+
+func newTestAuditedDMLEvent() *binlog.BinlogDMLEvent {
+	dmlEvent := binlog.NewBinlogDMLEvent("test", "tbl", binlog.UpdateDML)
+	dmlEvent.Coordinates = mysql.BinlogCoordinates{LogFile: "mysql-bin.000017", LogPos: 1234}
+	dmlEvent.Query = "update tbl set name='a\tb' where id=1"
+	return dmlEvent
+}
+
+func TestFormatAuditLine(t *testing.T) {
+	auditTime := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+	line := formatAuditLine(auditTime, newTestAuditedDMLEvent())
+	test.S(t).ExpectEquals(line, "2022-01-02T03:04:05Z\tmysql-bin.000017:1234\tUpdate\t`test`.`tbl`\t\"update tbl set name='a\\tb' where id=1\"\n")
+}
+
+func TestFormatAuditLineUniqueKeyUpdate(t *testing.T) {
+	applier, _ := newTestExecApplier(t)
+	dmlEvent := newTestAuditedDMLEvent()
+	dmlEvent.DatabaseName = "test"
+	dmlEvent.WhereColumnValues = sql.ToColumnValues([]interface{}{1, 11})
+	dmlEvent.NewColumnValues = sql.ToColumnValues([]interface{}{2, 11})
+	// Applied as a delete and an insert, the event is audited as the update it is
+	test.S(t).ExpectNil(applier.ApplyDMLEventQueries([]*binlog.BinlogDMLEvent{dmlEvent}))
+	auditTime := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+	line := formatAuditLine(auditTime, dmlEvent)
+	test.S(t).ExpectTrue(strings.HasPrefix(line, "2022-01-02T03:04:05Z\tmysql-bin.000017:1234\tUpdate\t`test`.`tbl`\t"))
+}
+
+func TestDMLAuditor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gh-ost-audit")
+	test.S(t).ExpectNil(err)
+	defer os.RemoveAll(dir)
+
+	migrationContext := base.NewMigrationContext()
+	migrationContext.AuditDMLFile = filepath.Join(dir, "audit.log")
+	{
+		dmlAuditor := NewDMLAuditor(migrationContext)
+		test.S(t).ExpectNil(dmlAuditor.Open())
+		dmlAuditor.Audit(newTestAuditedDMLEvent())
+		dmlAuditor.Audit(newTestAuditedDMLEvent())
+		test.S(t).ExpectNil(dmlAuditor.Close())
+		// Closed; no-op
+		dmlAuditor.Audit(newTestAuditedDMLEvent())
+
+		content, err := ioutil.ReadFile(migrationContext.AuditDMLFile)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(strings.Count(string(content), "\n"), 2)
+		test.S(t).ExpectEquals(migrationContext.AuditDMLDroppedLines, int64(0))
+	}
+	{
+		// Writer not running: lines beyond the buffer are dropped rather than block
+		dmlAuditor := NewDMLAuditor(migrationContext)
+		for i := 0; i < auditDMLBufferSize+3; i++ {
+			dmlAuditor.Audit(newTestAuditedDMLEvent())
+		}
+		test.S(t).ExpectEquals(migrationContext.AuditDMLDroppedLines, int64(3))
+	}
+}
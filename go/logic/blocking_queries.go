@@ -0,0 +1,66 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package logic
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+const blockingQuerySnippetLength = 100
+
+func (this *BlockingSession) String() string {
+	query := this.Query
+	if len(query) > blockingQuerySnippetLength {
+		query = query[:blockingQuerySnippetLength] + "..."
+	}
+	return fmt.Sprintf("session %d (%s@%s), running for %ds: %s", this.Id, this.User, this.Host, this.Time, query)
+}
+
+// killBlockingQueries watches for sessions blocking given cut-over session for longer than
+// --cut-over-kill-blocking-queries-after, and kills them; or, with --cut-over-kill-blocking-queries-dry-run,
+// only reports them. It returns a function which stops watching.
+func (this *Migrator) killBlockingQueries(sessionId int64) (stop func()) {
+	if this.migrationContext.CutOverKillBlockingQueriesAfter == 0 || sessionId <= 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		reported := make(map[int64]bool)
+		for {
+			sessions, err := this.applier.GetBlockingSessions(sessionId, this.migrationContext.CutOverKillBlockingQueriesAfter)
+			if err != nil {
+				this.migrationContext.Log.Warningf("Cannot read sessions blocking cut-over: %+v", err)
+			}
+			for _, session := range sessions {
+				if this.migrationContext.CutOverKillBlockingQueriesDryRun {
+					if !reported[session.Id] {
+						this.migrationContext.Log.Infof("--cut-over-kill-blocking-queries-dry-run: would kill %s", session)
+						reported[session.Id] = true
+					}
+					continue
+				}
+				this.migrationContext.Log.Infof("Killing %s, blocking cut-over", session)
+				if err := this.applier.KillSession(session.Id); err != nil {
+					this.migrationContext.Log.Warningf("Cannot kill session %d: %+v", session.Id, err)
+					continue
+				}
+				atomic.AddInt64(&this.migrationContext.CutOverKilledBlockingQueries, 1)
+			}
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return func() {
+		close(done)
+	}
+}
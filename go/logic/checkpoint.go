@@ -0,0 +1,166 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package logic
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/github/gh-ost/go/base"
+	"github.com/github/gh-ost/go/mysql"
+	"github.com/github/gh-ost/go/sql"
+)
+
+// checkpointChangelogHint is the changelog table hint under which the row copy checkpoint is written
+const checkpointChangelogHint = "checkpoint"
+
+// Checkpoint is row copy progress, periodically written onto the changelog table, from which
+// --resume continues an interrupted migration. All binlog events up to BinlogCoordinates are
+// applied onto the ghost table, and all rows up to RangeEndValues are copied.
+type Checkpoint struct {
+	AlterStatementHash  string
+	ColumnsHash         string
+	UniqueKey           string
+	Partition           string
+	RangeEndValues      [][]byte
+	Iteration           int64
+	RangeStartIteration int64
+	RowsCopied          int64
+	BinlogFile          string
+	BinlogPos           int64
+	Time                time.Time
+}
+
+// hashCheckpointAlterStatement hashes the migration's ALTER statement, and row filter and descending copy order
+// if any, which a resumed migration must share
+func hashCheckpointAlterStatement(migrationContext *base.MigrationContext) string {
+	statement := strings.TrimSpace(migrationContext.AlterStatement)
+	if migrationContext.RowFilter != "" {
+		statement = fmt.Sprintf("%s\n%s", statement, strings.TrimSpace(migrationContext.RowFilter))
+	}
+	if migrationContext.IsDescendingCopyOrder() {
+		statement = fmt.Sprintf("%s\ncopy-order=%s", statement, migrationContext.CopyOrder)
+	}
+	hash := sha256.Sum256([]byte(statement))
+	return hex.EncodeToString(hash[:])
+}
+
+// hashCheckpointColumns hashes the original and ghost table columns, which a resumed migration must share
+func hashCheckpointColumns(migrationContext *base.MigrationContext) string {
+	columns := fmt.Sprintf("%s;%s",
+		strings.Join(migrationContext.OriginalTableColumns.Names(), ","),
+		strings.Join(migrationContext.GhostTableColumns.Names(), ","),
+	)
+	hash := sha256.Sum256([]byte(columns))
+	return hex.EncodeToString(hash[:])
+}
+
+// toCheckpointValue converts a unique key value, as scanned off the database, into bytes
+// that compare as the same value when given back as a query argument. NULL is nil.
+func toCheckpointValue(value interface{}) ([]byte, error) {
+	switch value := value.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return value, nil
+	case string:
+		return []byte(value), nil
+	case int64, int32, int16, int8, int, uint64, uint32, uint16, uint8, uint, float64, float32:
+		return []byte(fmt.Sprintf("%v", value)), nil
+	case time.Time:
+		return []byte(value.Format("2006-01-02 15:04:05.999999")), nil
+	}
+	return nil, fmt.Errorf("Unsupported unique key value for checkpoint: %v (%T)", value, value)
+}
+
+// NewCheckpoint captures row copy progress up to the last copied chunk, and binlog events applied
+// up to given coordinates
+func NewCheckpoint(migrationContext *base.MigrationContext, coordinates mysql.BinlogCoordinates) (*Checkpoint, error) {
+	checkpoint := &Checkpoint{
+		AlterStatementHash:  hashCheckpointAlterStatement(migrationContext),
+		ColumnsHash:         hashCheckpointColumns(migrationContext),
+		UniqueKey:           migrationContext.UniqueKey.Name,
+		Partition:           migrationContext.GetMigrationRangePartitionName(),
+		Iteration:           migrationContext.GetIteration(),
+		RangeStartIteration: migrationContext.MigrationRangeStartIteration,
+		RowsCopied:          migrationContext.GetTotalRowsCopied(),
+		BinlogFile:          coordinates.LogFile,
+		BinlogPos:           coordinates.LogPos,
+		Time:                time.Now(),
+	}
+	for _, value := range migrationContext.MigrationIterationRangeMaxValues.AbstractValues() {
+		checkpointValue, err := toCheckpointValue(value)
+		if err != nil {
+			return nil, err
+		}
+		checkpoint.RangeEndValues = append(checkpoint.RangeEndValues, checkpointValue)
+	}
+	return checkpoint, nil
+}
+
+// ParseCheckpoint parses a checkpoint as written onto the changelog table
+func ParseCheckpoint(value string) (*Checkpoint, error) {
+	checkpoint := &Checkpoint{}
+	if err := json.Unmarshal([]byte(value), checkpoint); err != nil {
+		return nil, fmt.Errorf("Cannot parse checkpoint: %+v", err)
+	}
+	return checkpoint, nil
+}
+
+func (this *Checkpoint) String() string {
+	return fmt.Sprintf("iteration %d, %d rows copied, binlog coordinates %s:%d, at %s", this.Iteration, this.RowsCopied, this.BinlogFile, this.BinlogPos, this.Time.Format(time.RFC3339))
+}
+
+// ToJSON returns the checkpoint as written onto the changelog table
+func (this *Checkpoint) ToJSON() (string, error) {
+	value, err := json.Marshal(this)
+	return string(value), err
+}
+
+// GetBinlogCoordinates returns the coordinates up to which binlog events are applied
+func (this *Checkpoint) GetBinlogCoordinates() mysql.BinlogCoordinates {
+	return mysql.BinlogCoordinates{LogFile: this.BinlogFile, LogPos: this.BinlogPos}
+}
+
+// GetRangeEndValues returns the unique key values up to which rows are copied
+func (this *Checkpoint) GetRangeEndValues() *sql.ColumnValues {
+	values := make([]interface{}, len(this.RangeEndValues))
+	for i, value := range this.RangeEndValues {
+		if value == nil {
+			// NULL value of a nullable unique key column
+			continue
+		}
+		values[i] = value
+	}
+	return sql.ToColumnValues(values)
+}
+
+// ValidateAlterStatement verifies the migration's ALTER statement is the one the checkpoint was written by
+func (this *Checkpoint) ValidateAlterStatement(migrationContext *base.MigrationContext) error {
+	if this.AlterStatementHash != hashCheckpointAlterStatement(migrationContext) {
+		return fmt.Errorf("--resume: the ALTER statement, --row-filter or --copy-order differs from that of the interrupted migration. Refusing to resume")
+	}
+	return nil
+}
+
+// ValidateTables verifies the original and ghost tables, and the chosen unique key, are the ones
+// the checkpoint was written with
+func (this *Checkpoint) ValidateTables(migrationContext *base.MigrationContext) error {
+	if this.ColumnsHash != hashCheckpointColumns(migrationContext) {
+		return fmt.Errorf("--resume: the original or ghost table columns differ from those of the interrupted migration. Refusing to resume")
+	}
+	if this.UniqueKey != migrationContext.UniqueKey.Name {
+		return fmt.Errorf("--resume: the interrupted migration iterated unique key %s, this migration chose %s. Refusing to resume; see --chunk-index", sql.EscapeName(this.UniqueKey), sql.EscapeName(migrationContext.UniqueKey.Name))
+	}
+	if len(this.RangeEndValues) != migrationContext.UniqueKey.Len() {
+		return fmt.Errorf("--resume: checkpoint has %d unique key values, expected %d", len(this.RangeEndValues), migrationContext.UniqueKey.Len())
+	}
+	return nil
+}
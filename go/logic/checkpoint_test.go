@@ -0,0 +1,104 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package logic
+
+import (
+	"testing"
+	"time"
+
+	test "github.com/openark/golib/tests"
+
+	"github.com/github/gh-ost/go/base"
+	"github.com/github/gh-ost/go/mysql"
+	"github.com/github/gh-ost/go/sql"
+)
+
+func newTestCheckpointMigrationContext() *base.MigrationContext {
+	migrationContext := base.NewMigrationContext()
+	migrationContext.AlterStatement = "add column name varchar(32)"
+	migrationContext.OriginalTableColumns = sql.NewColumnList([]string{"id", "created_at"})
+	migrationContext.GhostTableColumns = sql.NewColumnList([]string{"id", "created_at", "name"})
+	migrationContext.UniqueKey = &sql.UniqueKey{Name: "PRIMARY", Columns: *sql.NewColumnList([]string{"id", "created_at"})}
+	migrationContext.MigrationIterationRangeMaxValues = sql.ToColumnValues([]interface{}{[]byte("1000"), time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)})
+	migrationContext.Iteration = 10
+	migrationContext.MigrationRangeStartIteration = 0
+	migrationContext.TotalRowsCopied = 9876
+	return migrationContext
+}
+
+func TestCheckpoint(t *testing.T) {
+	migrationContext := newTestCheckpointMigrationContext()
+	checkpoint, err := NewCheckpoint(migrationContext, mysql.BinlogCoordinates{LogFile: "mysql-bin.000017", LogPos: 1234})
+	test.S(t).ExpectNil(err)
+
+	value, err := checkpoint.ToJSON()
+	test.S(t).ExpectNil(err)
+	parsed, err := ParseCheckpoint(value)
+	test.S(t).ExpectNil(err)
+
+	test.S(t).ExpectEquals(parsed.Iteration, int64(10))
+	test.S(t).ExpectEquals(parsed.RangeStartIteration, int64(0))
+	test.S(t).ExpectEquals(parsed.RowsCopied, int64(9876))
+	test.S(t).ExpectEquals(parsed.UniqueKey, "PRIMARY")
+	coordinates := parsed.GetBinlogCoordinates()
+	test.S(t).ExpectTrue(coordinates.Equals(&mysql.BinlogCoordinates{LogFile: "mysql-bin.000017", LogPos: 1234}))
+	test.S(t).ExpectEquals(parsed.GetRangeEndValues().String(), "1000,2022-01-02 03:04:05")
+
+	test.S(t).ExpectNil(parsed.ValidateAlterStatement(migrationContext))
+	test.S(t).ExpectNil(parsed.ValidateTables(migrationContext))
+}
+
+func TestCheckpointNullValues(t *testing.T) {
+	migrationContext := newTestCheckpointMigrationContext()
+	migrationContext.MigrationIterationRangeMaxValues = sql.ToColumnValues([]interface{}{[]byte(""), nil})
+	checkpoint, err := NewCheckpoint(migrationContext, mysql.BinlogCoordinates{LogFile: "mysql-bin.000017", LogPos: 1234})
+	test.S(t).ExpectNil(err)
+
+	value, err := checkpoint.ToJSON()
+	test.S(t).ExpectNil(err)
+	parsed, err := ParseCheckpoint(value)
+	test.S(t).ExpectNil(err)
+	rangeEndValues := parsed.GetRangeEndValues().AbstractValues()
+	// An empty value is not NULL
+	test.S(t).ExpectTrue(rangeEndValues[0] != nil)
+	test.S(t).ExpectEquals(len(rangeEndValues[0].([]byte)), 0)
+	test.S(t).ExpectTrue(rangeEndValues[1] == nil)
+}
+
+func TestCheckpointValidation(t *testing.T) {
+	checkpoint, err := NewCheckpoint(newTestCheckpointMigrationContext(), mysql.BinlogCoordinates{LogFile: "mysql-bin.000017", LogPos: 1234})
+	test.S(t).ExpectNil(err)
+	{
+		migrationContext := newTestCheckpointMigrationContext()
+		migrationContext.AlterStatement = "add column name varchar(64)"
+		test.S(t).ExpectNotNil(checkpoint.ValidateAlterStatement(migrationContext))
+	}
+	{
+		migrationContext := newTestCheckpointMigrationContext()
+		migrationContext.RowFilter = "created_at >= '2023-01-01'"
+		test.S(t).ExpectNotNil(checkpoint.ValidateAlterStatement(migrationContext))
+	}
+	{
+		migrationContext := newTestCheckpointMigrationContext()
+		migrationContext.CopyOrder = sql.DescendingCopyOrder
+		test.S(t).ExpectNotNil(checkpoint.ValidateAlterStatement(migrationContext))
+	}
+	{
+		migrationContext := newTestCheckpointMigrationContext()
+		migrationContext.GhostTableColumns = sql.NewColumnList([]string{"id", "created_at", "title"})
+		test.S(t).ExpectNotNil(checkpoint.ValidateTables(migrationContext))
+	}
+	{
+		migrationContext := newTestCheckpointMigrationContext()
+		migrationContext.UniqueKey = &sql.UniqueKey{Name: "id_uidx", Columns: *sql.NewColumnList([]string{"id", "created_at"})}
+		test.S(t).ExpectNotNil(checkpoint.ValidateTables(migrationContext))
+	}
+}
+
+func TestParseCheckpointInvalid(t *testing.T) {
+	_, err := ParseCheckpoint("not a checkpoint")
+	test.S(t).ExpectNotNil(err)
+}
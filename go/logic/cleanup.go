@@ -0,0 +1,160 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package logic
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/github/gh-ost/go/base"
+	"github.com/github/gh-ost/go/sql"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// scheduledDropChangelogHint is the changelog table hint under which a --drop-old-table-after drop is recorded
+const scheduledDropChangelogHint = "scheduled-drop"
+
+// ScheduledDrop is the drop of a migration's old table, per --drop-old-table-after. It is written onto the
+// changelog table, which is kept until the drop is done, such that a 'gh-ost --cleanup' run may finish the job.
+type ScheduledDrop struct {
+	DatabaseName        string
+	TableName           string
+	ScratchDatabaseName string
+	DueAt               time.Time
+}
+
+// NewScheduledDrop schedules the drop of the migration's old table, --drop-old-table-after from now
+func NewScheduledDrop(migrationContext *base.MigrationContext) *ScheduledDrop {
+	return &ScheduledDrop{
+		DatabaseName:        migrationContext.DatabaseName,
+		TableName:           migrationContext.GetOldTableName(),
+		ScratchDatabaseName: migrationContext.DropOldTableScratchDatabase,
+		DueAt:               time.Now().Add(migrationContext.DropOldTableAfter),
+	}
+}
+
+// ParseScheduledDrop parses a scheduled drop as written onto the changelog table
+func ParseScheduledDrop(value string) (*ScheduledDrop, error) {
+	drop := &ScheduledDrop{}
+	if err := json.Unmarshal([]byte(value), drop); err != nil {
+		return nil, fmt.Errorf("Cannot parse scheduled drop: %+v", err)
+	}
+	if drop.DatabaseName == "" || drop.TableName == "" {
+		return nil, fmt.Errorf("Cannot parse scheduled drop: no table found in %s", value)
+	}
+	return drop, nil
+}
+
+// ToJSON returns the scheduled drop as written onto the changelog table
+func (this *ScheduledDrop) ToJSON() (string, error) {
+	value, err := json.Marshal(this)
+	return string(value), err
+}
+
+func (this *ScheduledDrop) String() string {
+	return fmt.Sprintf("%s.%s at %s", sql.EscapeName(this.DatabaseName), sql.EscapeName(this.TableName), this.DueAt.Format(time.RFC3339))
+}
+
+// CleanupCommand returns the gh-ost command which finishes the scheduled drop
+func (this *ScheduledDrop) CleanupCommand(migrationContext *base.MigrationContext) string {
+	args := []string{"gh-ost", "--cleanup",
+		fmt.Sprintf("--host=%s", migrationContext.InspectorConnectionConfig.Key.Hostname),
+		fmt.Sprintf("--port=%d", migrationContext.InspectorConnectionConfig.Key.Port),
+	}
+	if migrationContext.AssumeMasterHostname != "" {
+		args = append(args, fmt.Sprintf("--assume-master-host=%s", migrationContext.AssumeMasterHostname))
+	}
+	if migrationContext.MigrateOnReplica {
+		args = append(args, "--migrate-on-replica")
+	}
+	args = append(args,
+		fmt.Sprintf("--database=%s", migrationContext.DatabaseName),
+		fmt.Sprintf("--table=%s", migrationContext.OriginalTableName),
+	)
+	if migrationContext.ForceTmpTableName != "" {
+		args = append(args, fmt.Sprintf("--force-table-names=%s", migrationContext.ForceTmpTableName))
+	}
+	args = append(args, "--execute")
+	return strings.Join(args, " ")
+}
+
+// HasScheduledOldTableDrop tells whether the migration completed with a --drop-old-table-after drop
+// of the old table yet to be done
+func (this *Migrator) HasScheduledOldTableDrop() bool {
+	return this.scheduledDrop != nil
+}
+
+// scheduleOldTableDrop records the drop of the old table onto the changelog table, in place of dropping it
+func (this *Migrator) scheduleOldTableDrop() error {
+	drop := NewScheduledDrop(this.migrationContext)
+	value, err := drop.ToJSON()
+	if err != nil {
+		return err
+	}
+	if err := this.retryOperation(func() error {
+		_, err := this.applier.WriteChangelog(scheduledDropChangelogHint, value)
+		return err
+	}); err != nil {
+		return err
+	}
+	this.scheduledDrop = drop
+	this.migrationContext.Log.Infof("Scheduled drop of old table %s", drop)
+	this.migrationContext.Log.Infof("Should gh-ost exit before then, finish the drop, and the drop of changelog table %s, with:", sql.EscapeName(this.migrationContext.GetChangelogTableName()))
+	this.migrationContext.Log.Infof("%s", drop.CleanupCommand(this.migrationContext))
+	return nil
+}
+
+// Cleanup finishes the scheduled drop of a migration's old table, per --drop-old-table-after: it waits for the
+// drop to be due, drops the old table, and then the changelog table. This is --cleanup, and also the janitor
+// waiting on a migration just completed.
+func (this *Migrator) Cleanup() (err error) {
+	// A completed migration on this same context has its connections torn down, and cached by uuid
+	this.migrationContext.Uuid = uuid.NewV4().String()
+	defer this.teardown()
+
+	this.inspector = NewInspector(this.migrationContext)
+	if err := this.inspector.InitDBConnections(); err != nil {
+		return err
+	}
+	if err := this.initiateApplierConnectionConfig(); err != nil {
+		return err
+	}
+	this.applier = NewApplier(this.migrationContext)
+	if err := this.applier.InitDBConnections(); err != nil {
+		return err
+	}
+	value, err := this.applier.ReadChangelog(scheduledDropChangelogHint)
+	if err != nil {
+		return fmt.Errorf("--cleanup: cannot read scheduled drop from changelog table %s: %+v", sql.EscapeName(this.migrationContext.GetChangelogTableName()), err)
+	}
+	if value == "" {
+		return fmt.Errorf("--cleanup: no scheduled drop found on changelog table %s", sql.EscapeName(this.migrationContext.GetChangelogTableName()))
+	}
+	drop, err := ParseScheduledDrop(value)
+	if err != nil {
+		return err
+	}
+	if this.migrationContext.DropOldTableScratchDatabase != "" {
+		drop.ScratchDatabaseName = this.migrationContext.DropOldTableScratchDatabase
+	}
+	if this.migrationContext.Noop {
+		this.migrationContext.Log.Infof("Found scheduled drop of old table %s. --execute not given: not dropping", drop)
+		return nil
+	}
+	if wait := time.Until(drop.DueAt); wait > 0 {
+		this.migrationContext.Log.Infof("Waiting %+v for scheduled drop of old table %s", wait.Round(time.Second), drop)
+		time.Sleep(wait)
+	}
+	if err := this.retryOperation(func() error {
+		return this.applier.DropScheduledOldTable(drop)
+	}); err != nil {
+		return err
+	}
+	return this.retryOperation(this.applier.DropChangelogTable)
+}
@@ -0,0 +1,48 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package logic
+
+import (
+	"testing"
+	"time"
+
+	test "github.com/openark/golib/tests"
+
+	"github.com/github/gh-ost/go/base"
+)
+
+func TestScheduledDrop(t *testing.T) {
+	migrationContext := base.NewMigrationContext()
+	migrationContext.InspectorConnectionConfig.Key.Hostname = "replica1"
+	migrationContext.InspectorConnectionConfig.Key.Port = 3306
+	migrationContext.DatabaseName = "test"
+	migrationContext.OriginalTableName = "mytable"
+	migrationContext.DropOldTableAfter = 4 * time.Hour
+	migrationContext.DropOldTableScratchDatabase = "scratch"
+
+	drop := NewScheduledDrop(migrationContext)
+	test.S(t).ExpectEquals(drop.DatabaseName, "test")
+	test.S(t).ExpectEquals(drop.TableName, "_mytable_del")
+	test.S(t).ExpectTrue(time.Until(drop.DueAt) > 3*time.Hour)
+
+	value, err := drop.ToJSON()
+	test.S(t).ExpectNil(err)
+	parsed, err := ParseScheduledDrop(value)
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectEquals(parsed.TableName, "_mytable_del")
+	test.S(t).ExpectEquals(parsed.ScratchDatabaseName, "scratch")
+	test.S(t).ExpectTrue(parsed.DueAt.Equal(drop.DueAt))
+
+	_, err = ParseScheduledDrop(`{"DatabaseName":"test"}`)
+	test.S(t).ExpectNotNil(err)
+	_, err = ParseScheduledDrop("not json")
+	test.S(t).ExpectNotNil(err)
+
+	test.S(t).ExpectEquals(drop.CleanupCommand(migrationContext), "gh-ost --cleanup --host=replica1 --port=3306 --database=test --table=mytable --execute")
+	migrationContext.ForceTmpTableName = "tmp"
+	migrationContext.AssumeMasterHostname = "master1:3307"
+	test.S(t).ExpectEquals(drop.CleanupCommand(migrationContext), "gh-ost --cleanup --host=replica1 --port=3306 --assume-master-host=master1:3307 --database=test --table=mytable --force-table-names=tmp --execute")
+}
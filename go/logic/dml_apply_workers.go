@@ -0,0 +1,197 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package logic
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	"github.com/github/gh-ost/go/base"
+	"github.com/github/gh-ost/go/binlog"
+)
+
+const dmlApplyWorkerQueueSize = 10
+
+// dmlApplyFunc applies a batch of DML events, in order, onto the ghost table
+type dmlApplyFunc func(dmlEvents [](*binlog.BinlogDMLEvent)) error
+
+// dmlApplyWorkers apply DML events concurrently. Events are sharded by hash of their unique key
+// values, such that all events of a given row are applied by the same worker, in binlog order.
+// Events of different rows may apply in any order. Work is grouped by source transaction, which is
+// never split: a transaction whose events all fall in a single shard is applied by that shard's
+// worker, and any other transaction is a barrier, applied whole once all prior events are, and before
+// any following event is dispatched. flush() waits on all events dispatched so far.
+type dmlApplyWorkers struct {
+	migrationContext *base.MigrationContext
+	applyFunc        dmlApplyFunc
+	shardOrdinals    []int
+	queues           [](chan [](*binlog.BinlogDMLEvent))
+	pending          sync.WaitGroup
+	done             chan struct{}
+	closeOnce        sync.Once
+	err              error
+	errMutex         sync.Mutex
+}
+
+// newDMLApplyWorkers starts `concurrency` workers, sharding events by the values of given
+// column ordinals, which must identify a row.
+func newDMLApplyWorkers(migrationContext *base.MigrationContext, concurrency int, shardOrdinals []int, applyFunc dmlApplyFunc) *dmlApplyWorkers {
+	workers := &dmlApplyWorkers{
+		migrationContext: migrationContext,
+		applyFunc:        applyFunc,
+		shardOrdinals:    shardOrdinals,
+		done:             make(chan struct{}),
+	}
+	for i := 0; i < concurrency; i++ {
+		queue := make(chan [](*binlog.BinlogDMLEvent), dmlApplyWorkerQueueSize)
+		workers.queues = append(workers.queues, queue)
+		go workers.work(queue)
+	}
+	return workers
+}
+
+// work applies the batches of a single shard, in order
+func (this *dmlApplyWorkers) work(queue chan [](*binlog.BinlogDMLEvent)) {
+	for {
+		select {
+		case dmlEvents := <-queue:
+			// Following a failure, no further events are applied; the migration is bound to abort
+			if this.getError() == nil {
+				if err := this.applyFunc(dmlEvents); err != nil {
+					this.setError(err)
+				}
+			}
+			this.pending.Done()
+		case <-this.done:
+			return
+		}
+	}
+}
+
+func (this *dmlApplyWorkers) getError() error {
+	this.errMutex.Lock()
+	defer this.errMutex.Unlock()
+	return this.err
+}
+
+func (this *dmlApplyWorkers) setError(err error) {
+	this.errMutex.Lock()
+	defer this.errMutex.Unlock()
+	if this.err == nil {
+		this.err = err
+	}
+}
+
+// shardOf returns the worker applying given event. A row may only move between shards by an
+// UPDATE modifying the shard columns, in which case shardOf returns false: such an event must
+// be ordered against both its shards.
+func (this *dmlApplyWorkers) shardOf(dmlEvent *binlog.BinlogDMLEvent) (shard int, ok bool) {
+	identifyingValues := dmlEvent.WhereColumnValues
+	if dmlEvent.DML == binlog.InsertDML {
+		identifyingValues = dmlEvent.NewColumnValues
+	}
+	if dmlEvent.DML == binlog.UpdateDML {
+		for _, ordinal := range this.shardOrdinals {
+			if !dmlEvent.NewColumnValues.IsPresent(ordinal) {
+				// Minimal row image: the column is unchanged
+				continue
+			}
+			whereValue := fmt.Sprintf("%v", dmlEvent.WhereColumnValues.AbstractValues()[ordinal])
+			newValue := fmt.Sprintf("%v", dmlEvent.NewColumnValues.AbstractValues()[ordinal])
+			if whereValue != newValue {
+				return 0, false
+			}
+		}
+	}
+	hash := fnv.New32a()
+	for _, ordinal := range this.shardOrdinals {
+		fmt.Fprintf(hash, "%v\x00", identifyingValues.AbstractValues()[ordinal])
+	}
+	return int(hash.Sum32() % uint32(len(this.queues))), true
+}
+
+func (this *dmlApplyWorkers) dispatch(shard int, dmlEvents [](*binlog.BinlogDMLEvent)) error {
+	this.pending.Add(1)
+	select {
+	case this.queues[shard] <- dmlEvents:
+		return nil
+	case <-this.done:
+		this.pending.Done()
+		return fmt.Errorf("DML apply workers are closed")
+	}
+}
+
+// shardOfTransaction returns the worker applying all of given transaction's events, or false when
+// they span shards, or move a row between shards.
+func (this *dmlApplyWorkers) shardOfTransaction(dmlEvents [](*binlog.BinlogDMLEvent)) (shard int, ok bool) {
+	for i, dmlEvent := range dmlEvents {
+		eventShard, ok := this.shardOf(dmlEvent)
+		if !ok || (i > 0 && eventShard != shard) {
+			return 0, false
+		}
+		shard = eventShard
+	}
+	return shard, true
+}
+
+// apply dispatches source transactions among the workers, and returns without waiting on them to be
+// applied. A transaction spanning shards is applied once all prior events are, and before any following
+// event is dispatched.
+func (this *dmlApplyWorkers) apply(dmlTransactions [][](*binlog.BinlogDMLEvent)) error {
+	if err := this.getError(); err != nil {
+		return err
+	}
+	shardEvents := make([][](*binlog.BinlogDMLEvent), len(this.queues))
+	dispatchShardEvents := func() error {
+		for shard, events := range shardEvents {
+			if len(events) == 0 {
+				continue
+			}
+			if err := this.dispatch(shard, events); err != nil {
+				return err
+			}
+			shardEvents[shard] = nil
+		}
+		return nil
+	}
+	for _, dmlEvents := range dmlTransactions {
+		if len(dmlEvents) == 0 {
+			continue
+		}
+		shard, ok := this.shardOfTransaction(dmlEvents)
+		if ok {
+			shardEvents[shard] = append(shardEvents[shard], dmlEvents...)
+			continue
+		}
+		if err := dispatchShardEvents(); err != nil {
+			return err
+		}
+		if err := this.flush(); err != nil {
+			return err
+		}
+		if err := this.dispatch(0, dmlEvents); err != nil {
+			return err
+		}
+		if err := this.flush(); err != nil {
+			return err
+		}
+	}
+	return dispatchShardEvents()
+}
+
+// flush waits on all dispatched events to be applied, and returns the first failure, if any
+func (this *dmlApplyWorkers) flush() error {
+	this.pending.Wait()
+	return this.getError()
+}
+
+// close stops the workers. Events dispatched and not yet applied are abandoned.
+func (this *dmlApplyWorkers) close() {
+	this.closeOnce.Do(func() {
+		close(this.done)
+	})
+}
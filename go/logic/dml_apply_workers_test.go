@@ -0,0 +1,241 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package logic
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	test "github.com/openark/golib/tests"
+
+	"github.com/github/gh-ost/go/base"
+	"github.com/github/gh-ost/go/binlog"
+	"github.com/github/gh-ost/go/sql"
+)
+
+// newTestRowEvent returns an event on a (id, seq) table, where seq is the event's position in the binlog
+func newTestRowEvent(dml binlog.EventDML, whereId, newId, seq int) *binlog.BinlogDMLEvent {
+	dmlEvent := binlog.NewBinlogDMLEvent("test", "tbl", dml)
+	if dml != binlog.InsertDML {
+		dmlEvent.WhereColumnValues = sql.ToColumnValues([]interface{}{whereId, seq})
+	}
+	if dml != binlog.DeleteDML {
+		dmlEvent.NewColumnValues = sql.ToColumnValues([]interface{}{newId, seq})
+	}
+	return dmlEvent
+}
+
+func TestDMLApplyWorkersShardOf(t *testing.T) {
+	workers := newDMLApplyWorkers(base.NewMigrationContext(), 4, []int{0}, nil)
+	defer workers.close()
+
+	insertShard, ok := workers.shardOf(newTestRowEvent(binlog.InsertDML, 0, 17, 1))
+	test.S(t).ExpectTrue(ok)
+	updateShard, ok := workers.shardOf(newTestRowEvent(binlog.UpdateDML, 17, 17, 2))
+	test.S(t).ExpectTrue(ok)
+	test.S(t).ExpectEquals(updateShard, insertShard)
+	deleteShard, ok := workers.shardOf(newTestRowEvent(binlog.DeleteDML, 17, 0, 3))
+	test.S(t).ExpectTrue(ok)
+	test.S(t).ExpectEquals(deleteShard, insertShard)
+
+	// Moving a row between keys
+	_, ok = workers.shardOf(newTestRowEvent(binlog.UpdateDML, 17, 18, 4))
+	test.S(t).ExpectFalse(ok)
+	// Minimal row image, where the key is absent from the after image
+	minimalUpdate := newTestRowEvent(binlog.UpdateDML, 17, 0, 5)
+	minimalUpdate.NewColumnValues = sql.ToPartialColumnValues([]interface{}{nil, 5}, []bool{false, true})
+	minimalUpdateShard, ok := workers.shardOf(minimalUpdate)
+	test.S(t).ExpectTrue(ok)
+	test.S(t).ExpectEquals(minimalUpdateShard, insertShard)
+}
+
+// TestDMLApplyWorkersHotRows applies a stream of events on a handful of rows, and validates that
+// events of any row are never reordered, and that rows moving between keys act as barriers.
+// Event sequence numbers are their binlog positions.
+func TestDMLApplyWorkersHotRows(t *testing.T) {
+	const numRows = 5
+	const numEvents = 5000
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	// Generate a valid binlog: the events a serial client would produce on the table
+	var dmlEvents [](*binlog.BinlogDMLEvent)
+	expectedTable := make(map[int]int)
+	for len(dmlEvents) < numEvents {
+		seq := len(dmlEvents) + 1
+		id := random.Intn(numRows)
+		_, exists := expectedTable[id]
+		switch {
+		case !exists:
+			dmlEvents = append(dmlEvents, newTestRowEvent(binlog.InsertDML, 0, id, seq))
+			expectedTable[id] = seq
+		case random.Intn(10) == 0:
+			dmlEvents = append(dmlEvents, newTestRowEvent(binlog.DeleteDML, id, 0, seq))
+			delete(expectedTable, id)
+		case random.Intn(10) == 0:
+			newId := random.Intn(numRows)
+			if _, newExists := expectedTable[newId]; newExists {
+				continue
+			}
+			dmlEvents = append(dmlEvents, newTestRowEvent(binlog.UpdateDML, id, newId, seq))
+			delete(expectedTable, id)
+			expectedTable[newId] = seq
+		default:
+			dmlEvents = append(dmlEvents, newTestRowEvent(binlog.UpdateDML, id, id, seq))
+			expectedTable[id] = seq
+		}
+	}
+
+	var mutex sync.Mutex
+	table := make(map[int]int)
+	lastSeqs := make(map[int]int)
+	appliedEvents := 0
+	var violations []string
+	expectLaterThan := func(id, seq int) {
+		if seq <= lastSeqs[id] {
+			violations = append(violations, "reordered")
+		}
+		lastSeqs[id] = seq
+	}
+	applyFunc := func(batch [](*binlog.BinlogDMLEvent)) error {
+		time.Sleep(time.Duration(rand.Intn(100)) * time.Microsecond)
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, dmlEvent := range batch {
+			switch dmlEvent.DML {
+			case binlog.InsertDML:
+				values := dmlEvent.NewColumnValues.AbstractValues()
+				expectLaterThan(values[0].(int), values[1].(int))
+				table[values[0].(int)] = values[1].(int)
+			case binlog.DeleteDML:
+				values := dmlEvent.WhereColumnValues.AbstractValues()
+				expectLaterThan(values[0].(int), values[1].(int))
+				delete(table, values[0].(int))
+			case binlog.UpdateDML:
+				whereValues := dmlEvent.WhereColumnValues.AbstractValues()
+				newValues := dmlEvent.NewColumnValues.AbstractValues()
+				expectLaterThan(whereValues[0].(int), newValues[1].(int))
+				if whereValues[0] != newValues[0] {
+					if appliedEvents != newValues[1].(int)-1 {
+						violations = append(violations, "row moved ahead of prior events")
+					}
+					expectLaterThan(newValues[0].(int), newValues[1].(int))
+				}
+				delete(table, whereValues[0].(int))
+				table[newValues[0].(int)] = newValues[1].(int)
+			}
+			appliedEvents++
+		}
+		return nil
+	}
+
+	workers := newDMLApplyWorkers(base.NewMigrationContext(), 4, []int{0}, applyFunc)
+	defer workers.close()
+	for i := 0; i < len(dmlEvents); {
+		batchSize := 1 + random.Intn(20)
+		if i+batchSize > len(dmlEvents) {
+			batchSize = len(dmlEvents) - i
+		}
+		// Split the batch into source transactions
+		var dmlTransactions [][](*binlog.BinlogDMLEvent)
+		for j := i; j < i+batchSize; {
+			transactionSize := 1 + random.Intn(3)
+			if j+transactionSize > i+batchSize {
+				transactionSize = i + batchSize - j
+			}
+			dmlTransactions = append(dmlTransactions, dmlEvents[j:j+transactionSize])
+			j += transactionSize
+		}
+		test.S(t).ExpectNil(workers.apply(dmlTransactions))
+		i += batchSize
+	}
+	test.S(t).ExpectNil(workers.flush())
+
+	test.S(t).ExpectEquals(appliedEvents, len(dmlEvents))
+	test.S(t).ExpectEquals(len(violations), 0)
+	test.S(t).ExpectEquals(len(table), len(expectedTable))
+	for id, seq := range expectedTable {
+		test.S(t).ExpectEquals(table[id], seq)
+	}
+}
+
+// TestDMLApplyWorkersTransactions validates that source transactions are never split, and that
+// a transaction spanning shards is applied once all prior events are, and before following events.
+func TestDMLApplyWorkersTransactions(t *testing.T) {
+	var mutex sync.Mutex
+	var batches [][](*binlog.BinlogDMLEvent)
+	// First events of the transactions spanning rows
+	spanningTransactions := make(map[*binlog.BinlogDMLEvent]bool)
+	appliedEvents := 0
+	var violations []string
+	applyFunc := func(batch [](*binlog.BinlogDMLEvent)) error {
+		time.Sleep(time.Duration(rand.Intn(100)) * time.Microsecond)
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, dmlEvent := range batch {
+			if spanningTransactions[dmlEvent] && appliedEvents != dmlEvent.NewColumnValues.AbstractValues()[1].(int)-1 {
+				violations = append(violations, "transaction spanning shards applied ahead of prior events")
+			}
+		}
+		batches = append(batches, batch)
+		appliedEvents += len(batch)
+		return nil
+	}
+
+	workers := newDMLApplyWorkers(base.NewMigrationContext(), 4, []int{0}, applyFunc)
+	defer workers.close()
+	var dmlTransactions [][](*binlog.BinlogDMLEvent)
+	seq := 0
+	for i := 0; i < 200; i++ {
+		var dmlEvents [](*binlog.BinlogDMLEvent)
+		if i%5 == 0 {
+			// Several rows in a single transaction
+			for id := 0; id < 8; id++ {
+				seq++
+				dmlEvents = append(dmlEvents, newTestRowEvent(binlog.InsertDML, 0, 1000*i+id, seq))
+			}
+			spanningTransactions[dmlEvents[0]] = true
+		} else {
+			seq++
+			dmlEvents = append(dmlEvents, newTestRowEvent(binlog.InsertDML, 0, 1000*i, seq))
+		}
+		dmlTransactions = append(dmlTransactions, dmlEvents)
+	}
+	for i := 0; i < len(dmlTransactions); i += 10 {
+		test.S(t).ExpectNil(workers.apply(dmlTransactions[i : i+10]))
+	}
+	test.S(t).ExpectNil(workers.flush())
+
+	test.S(t).ExpectEquals(appliedEvents, seq)
+	test.S(t).ExpectEquals(len(violations), 0)
+	// Each transaction applies within a single batch
+	batchOf := make(map[*binlog.BinlogDMLEvent]int)
+	for i, batch := range batches {
+		for _, dmlEvent := range batch {
+			batchOf[dmlEvent] = i
+		}
+	}
+	for _, dmlEvents := range dmlTransactions {
+		for _, dmlEvent := range dmlEvents {
+			test.S(t).ExpectEquals(batchOf[dmlEvent], batchOf[dmlEvents[0]])
+		}
+	}
+}
+
+func TestDMLApplyWorkersError(t *testing.T) {
+	applyErr := errors.New("cannot apply")
+	applyFunc := func(batch [](*binlog.BinlogDMLEvent)) error {
+		return applyErr
+	}
+	workers := newDMLApplyWorkers(base.NewMigrationContext(), 2, []int{0}, applyFunc)
+	defer workers.close()
+
+	test.S(t).ExpectNil(workers.apply([][](*binlog.BinlogDMLEvent){{newTestRowEvent(binlog.InsertDML, 0, 1, 1)}}))
+	test.S(t).ExpectEquals(workers.flush(), applyErr)
+	test.S(t).ExpectEquals(workers.apply([][](*binlog.BinlogDMLEvent){{newTestRowEvent(binlog.InsertDML, 0, 2, 2)}}), applyErr)
+}
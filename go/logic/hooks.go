@@ -6,11 +6,13 @@
 package logic
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"sync/atomic"
+	"time"
 
 	"github.com/github/gh-ost/go/base"
 	"github.com/outbrain/golib/log"
@@ -23,13 +25,19 @@ const (
 	onBeforeRowCopy      = "gh-ost-on-before-row-copy"
 	onRowCopyComplete    = "gh-ost-on-row-copy-complete"
 	onBeginPostponed     = "gh-ost-on-begin-postponed"
+	onAutoUnpostponed    = "gh-ost-on-auto-unpostponed"
 	onBeforeCutOver      = "gh-ost-on-before-cut-over"
+	onRowCountMismatch   = "gh-ost-on-row-count-mismatch"
 	onInteractiveCommand = "gh-ost-on-interactive-command"
 	onSuccess            = "gh-ost-on-success"
 	onFailure            = "gh-ost-on-failure"
 	onStatus             = "gh-ost-on-status"
 	onStopReplication    = "gh-ost-on-stop-replication"
 	onStartReplication   = "gh-ost-on-start-replication"
+	onCriticalLoad       = "gh-ost-on-critical-load"
+
+	onBeforeCreateForeignKeys = "gh-ost-on-before-create-foreign-keys"
+	onForeignKeysRepointed    = "gh-ost-on-foreign-keys-repointed"
 )
 
 type HooksExecutor struct {
@@ -50,6 +58,8 @@ func (this *HooksExecutor) applyEnvironmentVariables(extraVariables ...string) [
 	env := os.Environ()
 	env = append(env, fmt.Sprintf("GH_OST_DATABASE_NAME=%s", this.migrationContext.DatabaseName))
 	env = append(env, fmt.Sprintf("GH_OST_TABLE_NAME=%s", this.migrationContext.OriginalTableName))
+	env = append(env, fmt.Sprintf("GH_OST_TARGET_DATABASE_NAME=%s", this.migrationContext.GetTargetDatabaseName()))
+	env = append(env, fmt.Sprintf("GH_OST_NEW_TABLE_NAME=%s", this.migrationContext.GetMigratedTableName()))
 	env = append(env, fmt.Sprintf("GH_OST_GHOST_TABLE_NAME=%s", this.migrationContext.GetGhostTableName()))
 	env = append(env, fmt.Sprintf("GH_OST_OLD_TABLE_NAME=%s", this.migrationContext.GetOldTableName()))
 	env = append(env, fmt.Sprintf("GH_OST_DDL=%s", this.migrationContext.AlterStatement))
@@ -65,11 +75,25 @@ func (this *HooksExecutor) applyEnvironmentVariables(extraVariables ...string) [
 	env = append(env, fmt.Sprintf("GH_OST_INSPECTED_LAG=%f", this.migrationContext.GetCurrentLagDuration().Seconds()))
 	env = append(env, fmt.Sprintf("GH_OST_HEARTBEAT_LAG=%f", this.migrationContext.TimeSinceLastHeartbeatOnChangelog().Seconds()))
 	env = append(env, fmt.Sprintf("GH_OST_PROGRESS=%f", this.migrationContext.GetProgressPct()))
+	streamerThroughput := this.migrationContext.GetStreamerThroughput()
+	env = append(env, fmt.Sprintf("GH_OST_STREAMER_EVENTS_PER_SECOND=%f", streamerThroughput.EventsPerSecond))
+	env = append(env, fmt.Sprintf("GH_OST_STREAMER_BYTES_PER_SECOND=%f", streamerThroughput.BytesPerSecond))
+	env = append(env, fmt.Sprintf("GH_OST_STREAMER_ROWS_PER_SECOND=%f", streamerThroughput.RowsPerSecond))
+	env = append(env, fmt.Sprintf("GH_OST_STREAMER_BLOCKED_RATIO=%f", streamerThroughput.BlockedRatio))
 	env = append(env, fmt.Sprintf("GH_OST_ETA_SECONDS=%d", this.migrationContext.GetETASeconds()))
+	totalThrottled, throttleDurations := this.migrationContext.GetThrottleDurations()
+	env = append(env, fmt.Sprintf("GH_OST_THROTTLED_SECONDS=%f", totalThrottled.Seconds()))
+	env = append(env, fmt.Sprintf("GH_OST_THROTTLE_DURATIONS=%s", base.ThrottleDurationsEnvValue(throttleDurations)))
 	env = append(env, fmt.Sprintf("GH_OST_HOOKS_HINT=%s", this.migrationContext.HooksHintMessage))
 	env = append(env, fmt.Sprintf("GH_OST_HOOKS_HINT_OWNER=%s", this.migrationContext.HooksHintOwner))
 	env = append(env, fmt.Sprintf("GH_OST_HOOKS_HINT_TOKEN=%s", this.migrationContext.HooksHintToken))
 	env = append(env, fmt.Sprintf("GH_OST_DRY_RUN=%t", this.migrationContext.Noop))
+	cutOverPhase, _ := this.migrationContext.CutOverState.Phase()
+	cutOverWritesBlocked, _ := this.migrationContext.CutOverState.WritesBlocked()
+	env = append(env, fmt.Sprintf("GH_OST_CUT_OVER_PHASE=%s", cutOverPhase))
+	env = append(env, fmt.Sprintf("GH_OST_CUT_OVER_FAILED_PHASE=%s", this.migrationContext.CutOverState.FailedPhase()))
+	env = append(env, fmt.Sprintf("GH_OST_CUT_OVER_PHASE_DURATIONS=%s", this.migrationContext.CutOverState.Durations()))
+	env = append(env, fmt.Sprintf("GH_OST_CUT_OVER_WRITES_BLOCKED_SECONDS=%f", cutOverWritesBlocked.Seconds()))
 
 	env = append(env, extraVariables...)
 	return env
@@ -78,7 +102,12 @@ func (this *HooksExecutor) applyEnvironmentVariables(extraVariables ...string) [
 // executeHook executes a command, and sets relevant environment variables
 // combined output & error are printed to gh-ost's standard error.
 func (this *HooksExecutor) executeHook(hook string, extraVariables ...string) error {
-	cmd := exec.Command(hook)
+	return this.executeHookContext(context.Background(), hook, extraVariables...)
+}
+
+// executeHookContext executes a command as executeHook does, killing it once given context is done
+func (this *HooksExecutor) executeHookContext(ctx context.Context, hook string, extraVariables ...string) error {
+	cmd := exec.CommandContext(ctx, hook)
 	cmd.Env = this.applyEnvironmentVariables(extraVariables...)
 
 	combinedOutput, err := cmd.CombinedOutput()
@@ -96,13 +125,17 @@ func (this *HooksExecutor) detectHooks(baseName string) (hooks []string, err err
 }
 
 func (this *HooksExecutor) executeHooks(baseName string, extraVariables ...string) error {
+	return this.executeHooksContext(context.Background(), baseName, extraVariables...)
+}
+
+func (this *HooksExecutor) executeHooksContext(ctx context.Context, baseName string, extraVariables ...string) error {
 	hooks, err := this.detectHooks(baseName)
 	if err != nil {
 		return err
 	}
 	for _, hook := range hooks {
 		log.Infof("executing %+v hook: %+v", baseName, hook)
-		if err := this.executeHook(hook, extraVariables...); err != nil {
+		if err := this.executeHookContext(ctx, hook, extraVariables...); err != nil {
 			return err
 		}
 	}
@@ -132,8 +165,42 @@ func (this *HooksExecutor) onBeginPostponed() error {
 	return this.executeHooks(onBeginPostponed)
 }
 
-func (this *HooksExecutor) onBeforeCutOver() error {
-	return this.executeHooks(onBeforeCutOver)
+func (this *HooksExecutor) onAutoUnpostponed() error {
+	v := fmt.Sprintf("GH_OST_AUTO_UNPOSTPONE_WHEN=%s", this.migrationContext.AutoUnpostponeCriteria)
+	return this.executeHooks(onAutoUnpostponed, v)
+}
+
+// onBeforeCutOver executes the onBeforeCutOver hooks ahead of a cut-over attempt, before any locks
+// are taken. A hook returning with error, or not returning within --hooks-before-cut-over-timeout,
+// vetoes the attempt.
+func (this *HooksExecutor) onBeforeCutOver(attempt int64, binlogCoordinates string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), this.migrationContext.HooksBeforeCutOverTimeout)
+	defer cancel()
+	err := this.executeHooksContext(ctx, onBeforeCutOver,
+		fmt.Sprintf("GH_OST_CUT_OVER_ATTEMPT=%d", attempt),
+		fmt.Sprintf("GH_OST_BINLOG_COORDINATES=%s", binlogCoordinates),
+	)
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%s hooks timed out after %+v", onBeforeCutOver, this.migrationContext.HooksBeforeCutOverTimeout)
+	}
+	return err
+}
+
+func (this *HooksExecutor) onCutOverRowCountMismatch(mode base.CutOverRowCountMode, originalTableRows, ghostTableRows int64) error {
+	return this.executeHooks(onRowCountMismatch,
+		fmt.Sprintf("GH_OST_ROW_COUNT_MODE=%s", mode),
+		fmt.Sprintf("GH_OST_ORIGINAL_TABLE_ROWS=%d", originalTableRows),
+		fmt.Sprintf("GH_OST_GHOST_TABLE_ROWS=%d", ghostTableRows),
+		fmt.Sprintf("GH_OST_MAX_ROW_DELTA=%s", this.migrationContext.GetCutOverMaxRowDelta()),
+	)
+}
+
+func (this *HooksExecutor) onBeforeCreateForeignKeys() error {
+	return this.executeHooks(onBeforeCreateForeignKeys)
+}
+
+func (this *HooksExecutor) onForeignKeysRepointed() error {
+	return this.executeHooks(onForeignKeysRepointed)
 }
 
 func (this *HooksExecutor) onInteractiveCommand(command string) error {
@@ -149,11 +216,32 @@ func (this *HooksExecutor) onFailure() error {
 	return this.executeHooks(onFailure)
 }
 
+// onCutOverAttemptFailure executes the onFailure hooks for a failed cut-over attempt. Unlike a failed
+// migration, the attempt's metadata is set, telling the hooks the migration may yet retry cut-over
+func (this *HooksExecutor) onCutOverAttemptFailure(totalAttempts int64, attempt int64, maxAttempts int64, reason base.CutOverFailureReason, err error) error {
+	return this.executeHooks(onFailure,
+		fmt.Sprintf("GH_OST_CUT_OVER_ATTEMPT=%d", attempt),
+		fmt.Sprintf("GH_OST_CUT_OVER_MAX_ATTEMPTS=%d", maxAttempts),
+		fmt.Sprintf("GH_OST_CUT_OVER_TOTAL_ATTEMPTS=%d", totalAttempts),
+		fmt.Sprintf("GH_OST_CUT_OVER_FAILURE_REASON=%s", reason),
+		fmt.Sprintf("GH_OST_CUT_OVER_ERROR=%s", err),
+	)
+}
+
 func (this *HooksExecutor) onStatus(statusMessage string) error {
 	v := fmt.Sprintf("GH_OST_STATUS='%s'", statusMessage)
 	return this.executeHooks(onStatus, v)
 }
 
+// onCriticalLoad executes the onCriticalLoad hooks upon each escalation of the critical-load escalation policy
+func (this *HooksExecutor) onCriticalLoad(stage base.CriticalLoadStage, criticalLoad string, metFor time.Duration) error {
+	return this.executeHooks(onCriticalLoad,
+		fmt.Sprintf("GH_OST_CRITICAL_LOAD_STAGE=%s", stage),
+		fmt.Sprintf("GH_OST_CRITICAL_LOAD=%s", criticalLoad),
+		fmt.Sprintf("GH_OST_CRITICAL_LOAD_SECONDS=%.3f", metFor.Seconds()),
+	)
+}
+
 func (this *HooksExecutor) onStopReplication() error {
 	return this.executeHooks(onStopReplication)
 }
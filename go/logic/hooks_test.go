@@ -0,0 +1,45 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package logic
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	test "github.com/openark/golib/tests"
+
+	"github.com/github/gh-ost/go/base"
+)
+
+func TestHooksExecutorOnBeforeCutOver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gh-ost-hooks")
+	test.S(t).ExpectNil(err)
+	defer os.RemoveAll(dir)
+
+	migrationContext := base.NewMigrationContext()
+	migrationContext.HooksPath = dir
+	migrationContext.HooksBeforeCutOverTimeout = time.Second
+	hooksExecutor := NewHooksExecutor(migrationContext)
+	writeHook := func(script string) {
+		hook := filepath.Join(dir, onBeforeCutOver)
+		test.S(t).ExpectNil(ioutil.WriteFile(hook, []byte("#!/bin/sh\n"+script+"\n"), 0755))
+	}
+
+	// no hooks
+	test.S(t).ExpectNil(hooksExecutor.onBeforeCutOver(1, "mysql-bin.000017:1234"))
+
+	writeHook(`[ "$GH_OST_CUT_OVER_ATTEMPT" = "3" ] && [ "$GH_OST_BINLOG_COORDINATES" = "mysql-bin.000017:1234" ]`)
+	test.S(t).ExpectNil(hooksExecutor.onBeforeCutOver(3, "mysql-bin.000017:1234"))
+	test.S(t).ExpectNotNil(hooksExecutor.onBeforeCutOver(2, "mysql-bin.000017:1234"))
+
+	writeHook("exec sleep 10")
+	startTime := time.Now()
+	test.S(t).ExpectNotNil(hooksExecutor.onBeforeCutOver(1, "mysql-bin.000017:1234"))
+	test.S(t).ExpectTrue(time.Since(startTime) < 5*time.Second)
+}
@@ -46,15 +46,49 @@ func (this *Inspector) InitDBConnections() (err error) {
 	if this.db, _, err = mysql.GetDB(this.migrationContext.Uuid, inspectorUri); err != nil {
 		return err
 	}
+	if mysql.HidesGeneratedInvisiblePrimaryKeys(this.db) {
+		// Generated invisible primary keys are part of the row images we read from the binary logs,
+		// and must then be part of the inspected table structure
+		this.migrationContext.Log.Infof("Showing generated invisible primary keys on inspector connections")
+		this.connectionConfig.ShowGeneratedInvisiblePrimaryKeys = true
+		inspectorUri = this.connectionConfig.GetDBUri(this.migrationContext.DatabaseName)
+		if this.db, _, err = mysql.GetDB(this.migrationContext.Uuid, inspectorUri); err != nil {
+			return err
+		}
+	}
+	if len(this.migrationContext.SessionVariables) > 0 {
+		// Validated on a connection not yet setting them: a global only variable fails connecting altogether
+		if err := mysql.ValidateSessionVariables(this.db, this.migrationContext.SessionVariables); err != nil {
+			return err
+		}
+		this.connectionConfig.SessionVariables = this.migrationContext.SessionVariables
+		inspectorUri = this.connectionConfig.GetDBUri(this.migrationContext.DatabaseName)
+		if this.db, _, err = mysql.GetDB(this.migrationContext.Uuid, inspectorUri); err != nil {
+			return err
+		}
+		if err := base.LogSessionVariables(this.db, this.migrationContext, this.name); err != nil {
+			return err
+		}
+	}
 
 	informationSchemaUri := this.connectionConfig.GetDBUri("information_schema")
 	if this.informationSchemaDb, _, err = mysql.GetDB(this.migrationContext.Uuid, informationSchemaUri); err != nil {
 		return err
 	}
+	for _, db := range []*gosql.DB{this.db, this.informationSchemaDb} {
+		mysql.SetDBPoolLimits(db,
+			int(this.migrationContext.InspectorMaxOpenConns),
+			int(this.migrationContext.InspectorMaxIdleConns),
+			this.migrationContext.InspectorConnMaxLifetime,
+		)
+	}
 
 	if err := this.validateConnection(); err != nil {
 		return err
 	}
+	if err := this.detectMySQLFlavor(); err != nil {
+		return err
+	}
 	if !this.migrationContext.AliyunRDS && !this.migrationContext.GoogleCloudPlatform && !this.migrationContext.AzureMySQL {
 		if impliedKey, err := mysql.GetInstanceKey(this.db); err != nil {
 			return err
@@ -85,21 +119,30 @@ func (this *Inspector) ValidateOriginalTable() (err error) {
 	if err := this.validateTableTriggers(); err != nil {
 		return err
 	}
+	if err := this.validateNewTableName(); err != nil {
+		return err
+	}
 	if err := this.estimateTableRowsViaExplain(); err != nil {
 		return err
 	}
+	if err := this.inspectTablePartitions(); err != nil {
+		return err
+	}
 	return nil
 }
 
-func (this *Inspector) InspectTableColumnsAndUniqueKeys(tableName string) (columns *sql.ColumnList, virtualColumns *sql.ColumnList, uniqueKeys [](*sql.UniqueKey), err error) {
-	uniqueKeys, err = this.getCandidateUniqueKeys(tableName)
+func (this *Inspector) InspectTableColumnsAndUniqueKeys(databaseName, tableName string) (columns *sql.ColumnList, virtualColumns *sql.ColumnList, uniqueKeys [](*sql.UniqueKey), err error) {
+	uniqueKeys, err = this.getCandidateUniqueKeys(databaseName, tableName)
 	if err != nil {
 		return columns, virtualColumns, uniqueKeys, err
 	}
-	if len(uniqueKeys) == 0 {
-		return columns, virtualColumns, uniqueKeys, fmt.Errorf("No PRIMARY nor UNIQUE key found in table! Bailing out")
+	if len(uniqueKeys) == 0 && !this.migrationContext.SurrogateKey {
+		return columns, virtualColumns, uniqueKeys, fmt.Errorf("No PRIMARY nor UNIQUE key found in table! Bailing out. See --surrogate-key for migrating tables without one")
+	}
+	if functionalUniqueKeys := getFunctionalUniqueKeyNames(uniqueKeys); len(functionalUniqueKeys) == len(uniqueKeys) && !this.migrationContext.SurrogateKey {
+		return columns, virtualColumns, uniqueKeys, fmt.Errorf("%s.%s only has functional unique keys (%s), which row copy cannot iterate. Bailing out. Add a unique key on plain columns, e.g. on a STORED generated column computing the expression, or see --surrogate-key", sql.EscapeName(databaseName), sql.EscapeName(tableName), strings.Join(functionalUniqueKeys, ", "))
 	}
-	columns, virtualColumns, err = mysql.GetTableColumns(this.db, this.migrationContext.DatabaseName, tableName)
+	columns, virtualColumns, err = mysql.GetTableColumns(this.db, databaseName, tableName)
 	if err != nil {
 		return columns, virtualColumns, uniqueKeys, err
 	}
@@ -108,7 +151,7 @@ func (this *Inspector) InspectTableColumnsAndUniqueKeys(tableName string) (colum
 }
 
 func (this *Inspector) InspectOriginalTable() (err error) {
-	this.migrationContext.OriginalTableColumns, this.migrationContext.OriginalTableVirtualColumns, this.migrationContext.OriginalTableUniqueKeys, err = this.InspectTableColumnsAndUniqueKeys(this.migrationContext.OriginalTableName)
+	this.migrationContext.OriginalTableColumns, this.migrationContext.OriginalTableVirtualColumns, this.migrationContext.OriginalTableUniqueKeys, err = this.InspectTableColumnsAndUniqueKeys(this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName)
 	if err != nil {
 		return err
 	}
@@ -116,6 +159,13 @@ func (this *Inspector) InspectOriginalTable() (err error) {
 	if err != nil {
 		return err
 	}
+	this.migrationContext.OriginalTableHasGIPK, err = this.hasGeneratedInvisiblePrimaryKey(this.migrationContext.OriginalTableName)
+	if err != nil {
+		return err
+	}
+	if this.migrationContext.OriginalTableHasGIPK {
+		this.migrationContext.Log.Infof("Table %s has a generated invisible primary key (%s)", sql.EscapeName(this.migrationContext.OriginalTableName), sql.EscapeName(mysql.GeneratedInvisiblePrimaryKeyColumnName))
+	}
 	return nil
 }
 
@@ -128,42 +178,61 @@ func (this *Inspector) inspectOriginalAndGhostTables() (err error) {
 		return fmt.Errorf("It seems like table structure is not identical between master and replica. This scenario is not supported.")
 	}
 
-	this.migrationContext.GhostTableColumns, this.migrationContext.GhostTableVirtualColumns, this.migrationContext.GhostTableUniqueKeys, err = this.InspectTableColumnsAndUniqueKeys(this.migrationContext.GetGhostTableName())
+	this.migrationContext.GhostTableColumns, this.migrationContext.GhostTableVirtualColumns, this.migrationContext.GhostTableUniqueKeys, err = this.InspectTableColumnsAndUniqueKeys(this.migrationContext.GetTargetDatabaseName(), this.migrationContext.GetGhostTableName())
 	if err != nil {
 		return err
 	}
+	if err := this.validateExplicitColumnRenames(); err != nil {
+		return err
+	}
 	sharedUniqueKeys, err := this.getSharedUniqueKeys(this.migrationContext.OriginalTableUniqueKeys, this.migrationContext.GhostTableUniqueKeys)
 	if err != nil {
 		return err
 	}
-	for i, sharedUniqueKey := range sharedUniqueKeys {
-		this.applyColumnTypes(this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName, &sharedUniqueKey.Columns)
-		uniqueKeyIsValid := true
-		for _, column := range sharedUniqueKey.Columns.Columns() {
-			switch column.Type {
-			case sql.FloatColumnType:
-				{
-					this.migrationContext.Log.Warning("Will not use %+v as shared key due to FLOAT data type", sharedUniqueKey.Name)
-					uniqueKeyIsValid = false
-				}
-			case sql.JSONColumnType:
-				{
-					// Noteworthy that at this time MySQL does not allow JSON indexing anyhow, but this code
-					// will remain in place to potentially handle the future case where JSON is supported in indexes.
-					this.migrationContext.Log.Warning("Will not use %+v as shared key due to JSON data type", sharedUniqueKey.Name)
-					uniqueKeyIsValid = false
-				}
-			}
+	if this.migrationContext.ChunkIndex != "" {
+		if this.migrationContext.UniqueKey, err = this.getChunkIndexUniqueKey(sharedUniqueKeys); err != nil {
+			return err
 		}
-		if uniqueKeyIsValid {
+		this.migrationContext.Log.Infof("Chosen shared unique key is %s, per --chunk-index", this.migrationContext.UniqueKey.Name)
+	} else {
+		for i, sharedUniqueKey := range sharedUniqueKeys {
+			if sharedUniqueKey.IsFunctional() {
+				this.migrationContext.Log.Infof("Will not use %s as shared key: row copy cannot iterate its functional key parts (%s)", sql.EscapeName(sharedUniqueKey.Name), strings.Join(sharedUniqueKey.Expressions, ", "))
+				continue
+			}
+			this.applyColumnTypes(this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName, &sharedUniqueKey.Columns)
+			if unusableType := getUnusableUniqueKeyColumnType(sharedUniqueKey); unusableType != "" {
+				this.migrationContext.Log.Warning("Will not use %+v as shared key due to %s data type", sharedUniqueKey.Name, unusableType)
+				continue
+			}
 			this.migrationContext.UniqueKey = sharedUniqueKeys[i]
 			break
 		}
+		if this.migrationContext.UniqueKey == nil {
+			if functionalUniqueKeys := getFunctionalUniqueKeyNames(sharedUniqueKeys); len(functionalUniqueKeys) > 0 && !this.migrationContext.SurrogateKey {
+				return fmt.Errorf("The only shared unique keys are functional (%s), and row copy cannot iterate functional key parts. Bailing out. Supply --chunk-index naming a unique key on plain columns shared by the ghost table, add one (e.g. on a STORED generated column computing the expression), or see --surrogate-key", strings.Join(functionalUniqueKeys, ", "))
+			}
+			if !this.migrationContext.SurrogateKey {
+				return fmt.Errorf("No shared unique key can be found after ALTER! Bailing out. See --surrogate-key for migrating tables without one")
+			}
+			if this.migrationContext.UniqueKey, err = this.getSurrogateUniqueKey(); err != nil {
+				return err
+			}
+			this.migrationContext.UsingSurrogateKey = true
+			this.migrationContext.CopyConflictStrategy = sql.SkipExistingCopyConflictStrategy
+			this.migrationContext.Log.Infof("No shared unique key found; per --surrogate-key, rows are matched by all shared columns and row copy iterates by (%s)", this.migrationContext.UniqueKey.Columns.String())
+		} else {
+			this.migrationContext.Log.Infof("Chosen shared unique key is %s", this.migrationContext.UniqueKey.Name)
+		}
 	}
-	if this.migrationContext.UniqueKey == nil {
-		return fmt.Errorf("No shared unique key can be found after ALTER! Bailing out")
+	if this.migrationContext.OriginalBinlogRowImage == "MINIMAL" && this.migrationContext.UniqueKey.Name != "PRIMARY" {
+		// With a PRIMARY KEY, minimal row images only identify rows by the PRIMARY KEY columns
+		for _, uniqueKey := range this.migrationContext.OriginalTableUniqueKeys {
+			if uniqueKey.Name == "PRIMARY" {
+				return fmt.Errorf("%s has 'MINIMAL' binlog_row_image, which identifies rows by PRIMARY KEY columns only, but the chosen shared key is %s. Make sure the PRIMARY KEY is shared by the original and ghost tables, or `set global binlog_row_image='full'` and try again", this.connectionConfig.Key.String(), this.migrationContext.UniqueKey.Name)
+			}
+		}
 	}
-	this.migrationContext.Log.Infof("Chosen shared unique key is %s", this.migrationContext.UniqueKey.Name)
 	if this.migrationContext.UniqueKey.HasNullable {
 		if this.migrationContext.NullableUniqueKeyAllowed {
 			this.migrationContext.Log.Warningf("Chosen key (%s) has nullable columns. You have supplied with --allow-nullable-unique-key and so this migration proceeds. As long as there aren't NULL values in this key's column, migration should be fine. NULL values will corrupt migration's data", this.migrationContext.UniqueKey)
@@ -172,7 +241,18 @@ func (this *Inspector) inspectOriginalAndGhostTables() (err error) {
 		}
 	}
 
-	this.migrationContext.SharedColumns, this.migrationContext.MappedSharedColumns = this.getSharedColumns(this.migrationContext.OriginalTableColumns, this.migrationContext.GhostTableColumns, this.migrationContext.OriginalTableVirtualColumns, this.migrationContext.GhostTableVirtualColumns, this.migrationContext.ColumnRenameMap)
+	for _, foreignKey := range this.migrationContext.OriginalTableForeignKeys {
+		for _, columnName := range foreignKey.Columns.Names() {
+			if mappedName, ok := this.migrationContext.ColumnRenameMap[columnName]; ok {
+				columnName = mappedName
+			}
+			if this.migrationContext.GhostTableColumns.GetColumn(columnName) == nil {
+				return fmt.Errorf("Column %s of foreign key %s is not found on the ghost table. Foreign key columns cannot be dropped with --preserve-foreign-keys. Bailing out", sql.EscapeName(columnName), sql.EscapeName(foreignKey.Name))
+			}
+		}
+	}
+
+	this.migrationContext.SharedColumns, this.migrationContext.MappedSharedColumns = this.getSharedColumns(this.migrationContext.OriginalTableColumns, this.migrationContext.GhostTableColumns, this.migrationContext.ColumnRenameMap)
 	this.migrationContext.Log.Infof("Shared columns are %s", this.migrationContext.SharedColumns)
 	// By fact that a non-empty unique key exists we also know the shared columns are non-empty
 
@@ -180,30 +260,242 @@ func (this *Inspector) inspectOriginalAndGhostTables() (err error) {
 	// the `getTableColumns()` function, but it's a later patch and introduces some complexity; I feel
 	// comfortable in doing this as a separate step.
 	this.applyColumnTypes(this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName, this.migrationContext.OriginalTableColumns, this.migrationContext.SharedColumns, &this.migrationContext.UniqueKey.Columns)
-	this.applyColumnTypes(this.migrationContext.DatabaseName, this.migrationContext.GetGhostTableName(), this.migrationContext.GhostTableColumns, this.migrationContext.MappedSharedColumns)
+	this.applyColumnTypes(this.migrationContext.GetTargetDatabaseName(), this.migrationContext.GetGhostTableName(), this.migrationContext.GhostTableColumns, this.migrationContext.MappedSharedColumns)
 
 	for i := range this.migrationContext.SharedColumns.Columns() {
 		column := this.migrationContext.SharedColumns.Columns()[i]
 		mappedColumn := this.migrationContext.MappedSharedColumns.Columns()[i]
 		if column.Name == mappedColumn.Name && column.Type == sql.DateTimeColumnType && mappedColumn.Type == sql.TimestampColumnType {
-			this.migrationContext.MappedSharedColumns.SetConvertDatetimeToTimestamp(column.Name, this.migrationContext.ApplierTimeZone)
+			this.migrationContext.MappedSharedColumns.SetConvertDatetimeToTimestamp(column.Name, this.migrationContext.ApplierTimeZone, this.migrationContext.ConnectionTimeZone)
+			this.warnTimezoneConversion(column.Name)
+		}
+		if column.Name == mappedColumn.Name && column.Type == sql.TimestampColumnType && mappedColumn.Type == sql.DateTimeColumnType {
+			this.migrationContext.MappedSharedColumns.SetConvertTimestampToDatetime(column.Name, this.migrationContext.ApplierTimeZone, this.migrationContext.ConnectionTimeZone)
+			this.warnTimezoneConversion(column.Name)
+		}
+		if mappedColumn.Type == sql.GeometryColumnType && mappedColumn.HasSRID && (!column.HasSRID || column.SRID != mappedColumn.SRID) {
+			// Values of a column newly restricted to an SRID are given that SRID, keeping their coordinates
+			this.migrationContext.Log.Infof("Column %s is restricted to SRID %d on the ghost table. Copied and applied values take on that SRID", sql.EscapeName(mappedColumn.Name), mappedColumn.SRID)
+			this.migrationContext.MappedSharedColumns.SetGeometrySRIDConversion(mappedColumn.Name, mappedColumn.SRID)
+		}
+		if conversion := sql.NewTypeConversion(&column, &mappedColumn); conversion != nil {
+			// ENUM, SET and BIT values are given in the binlog as numbers, which the ghost column may take
+			// differently than the row copy's cast does
+			this.migrationContext.Log.Infof("Column %s changes type from %s to %s. Applied values are converted", sql.EscapeName(column.Name), column.MySQLType, mappedColumn.MySQLType)
+			this.migrationContext.SharedColumns.SetTypeConversion(column.Name, conversion)
+			if this.migrationContext.UniqueKey.Columns.GetColumn(column.Name) != nil {
+				this.migrationContext.UniqueKey.Columns.SetTypeConversion(column.Name, conversion)
+			}
 		}
-		if column.Name == mappedColumn.Name && column.Type == sql.EnumColumnType && mappedColumn.Charset != "" {
-			this.migrationContext.MappedSharedColumns.SetEnumToTextConversion(column.Name)
-			this.migrationContext.MappedSharedColumns.SetEnumValues(column.Name, column.EnumValues)
+		if column.Charset != "" && mappedColumn.Charset != "" && column.Charset != mappedColumn.Charset {
+			// String column changes charset: binlog values need decoding with the original charset and
+			// conversion into the ghost column's charset, both for the written values and the key lookup
+			this.migrationContext.Log.Infof("Column %s changes charset from %s to %s", sql.EscapeName(column.Name), column.Charset, mappedColumn.Charset)
+			this.migrationContext.SharedColumns.SetCharsetConversion(column.Name, mappedColumn.Charset)
+			if this.migrationContext.UniqueKey.Columns.GetColumn(column.Name) != nil {
+				this.migrationContext.UniqueKey.Columns.SetCharsetConversion(column.Name, mappedColumn.Charset)
+			}
+			if !sql.IsDecodableCharset(column.Charset) && column.Type != sql.EnumColumnType && column.Type != sql.SetColumnType {
+				// Values are written as binary strings, which the server converts from the original charset
+				if this.migrationContext.UniqueKey.Columns.GetColumn(column.Name) != nil {
+					return fmt.Errorf("No support at this time for converting a column from charset %s when it is also part of the chosen unique key. Column: %s, key: %s", column.Charset, column.Name, this.migrationContext.UniqueKey.Name)
+				}
+				this.migrationContext.MappedSharedColumns.SetServerCharsetConversion(mappedColumn.Name, column.Charset)
+			}
 		}
 	}
 
+	if err := this.validateColumnVisibility(); err != nil {
+		return err
+	}
+	if err := this.validateGhostTableSpatialIndexes(); err != nil {
+		return err
+	}
+	if err := this.inspectZeroDates(); err != nil {
+		return err
+	}
+	if err := this.validateRowFilter(); err != nil {
+		return err
+	}
+	if err := this.inspectCheckConstraints(); err != nil {
+		return err
+	}
+
 	for _, column := range this.migrationContext.UniqueKey.Columns.Columns() {
 		if this.migrationContext.GhostTableVirtualColumns.GetColumn(column.Name) != nil {
 			// this is a virtual column
 			continue
 		}
 		if this.migrationContext.MappedSharedColumns.HasTimezoneConversion(column.Name) {
-			return fmt.Errorf("No support at this time for converting a column from DATETIME to TIMESTAMP that is also part of the chosen unique key. Column: %s, key: %s", column.Name, this.migrationContext.UniqueKey.Name)
+			return fmt.Errorf("No support at this time for converting a column between DATETIME and TIMESTAMP that is also part of the chosen unique key. Column: %s, key: %s", column.Name, this.migrationContext.UniqueKey.Name)
+		}
+	}
+
+	return nil
+}
+
+// warnTimezoneConversion warns when a column converted between DATETIME and TIMESTAMP has its DATETIME
+// values taken as wall clock time in a time zone that may observe DST: the server's system time zone, or
+// a named time zone. Such values within the hour repeated as DST ends are ambiguous, and values within
+// the hour skipped as DST starts do not exist.
+func (this *Inspector) warnTimezoneConversion(columnName string) {
+	timeZone := this.migrationContext.ApplierTimeZone
+	if strings.EqualFold(timeZone, "SYSTEM") {
+		timeZone = fmt.Sprintf("SYSTEM (system_time_zone='%s')", this.migrationContext.ApplierSystemTimeZone)
+	} else if mysql.IsTimeZoneOffset(timeZone) {
+		// A fixed offset has no DST
+		return
+	}
+	this.migrationContext.Log.Warningf("Column %s converts between DATETIME and TIMESTAMP, taking DATETIME values as wall clock time in the applier's time_zone=%s. Should that time zone observe DST, values around DST changes may shift by an hour. Consider setting a fixed offset as the server's time_zone", sql.EscapeName(columnName), timeZone)
+}
+
+// isZeroDateColumn tells whether given column may hold zero dates: a DATE, DATETIME or TIMESTAMP column
+func isZeroDateColumn(column *sql.Column) bool {
+	return strings.HasPrefix(column.MySQLType, "date") || strings.HasPrefix(column.MySQLType, "timestamp")
+}
+
+// inspectZeroDates validates the ghost table's date columns can take the original table's zero dates: as copied,
+// as rewritten per --zero-date-rewrite, or as implicitly given to NOT NULL columns the ALTER adds. Given
+// --allow-zero-dates or --zero-date-rewrite, it counts the original table's rows with zero dates, per column.
+func (this *Inspector) inspectZeroDates() error {
+	rewrite := this.migrationContext.ZeroDateRewrite
+	var zeroDateColumnNames []string
+	for i := range this.migrationContext.SharedColumns.Columns() {
+		column := &this.migrationContext.SharedColumns.Columns()[i]
+		mappedColumn := &this.migrationContext.MappedSharedColumns.Columns()[i]
+		if column.IsGenerated() || !isZeroDateColumn(column) || !isZeroDateColumn(mappedColumn) {
+			continue
+		}
+		zeroDateColumnNames = append(zeroDateColumnNames, column.Name)
+		if rewrite == nil {
+			continue
+		}
+		if rewrite.ToNull && !mappedColumn.Nullable {
+			return fmt.Errorf("--zero-date-rewrite=NULL: column %s is NOT NULL on the ghost table, and cannot take rewritten zero dates. Bailing out", sql.EscapeName(mappedColumn.Name))
+		}
+		if this.migrationContext.UniqueKey.Columns.GetColumn(column.Name) != nil {
+			return fmt.Errorf("--zero-date-rewrite: column %s is part of the chosen unique key %s, and distinct rows may not be rewritten into the same value. Bailing out", sql.EscapeName(column.Name), sql.EscapeName(this.migrationContext.UniqueKey.Name))
+		}
+		this.migrationContext.SharedColumns.SetZeroDateRewrite(column.Name, rewrite)
+		this.migrationContext.MappedSharedColumns.SetZeroDateRewrite(mappedColumn.Name, rewrite)
+	}
+	if err := this.validateAddedZeroDateColumns(); err != nil {
+		return err
+	}
+
+	if len(zeroDateColumnNames) == 0 || (rewrite == nil && !this.migrationContext.AllowZeroInDate) {
+		return nil
+	}
+	countExpressions := make([]string, len(zeroDateColumnNames))
+	for i, columnName := range zeroDateColumnNames {
+		columnName = sql.EscapeName(columnName)
+		countExpressions[i] = fmt.Sprintf("coalesce(sum(month(%s) = 0 or dayofmonth(%s) = 0), 0)", columnName, columnName)
+	}
+	query := fmt.Sprintf(`select /* gh-ost */ %s from %s.%s`,
+		strings.Join(countExpressions, ", "),
+		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(this.migrationContext.OriginalTableName),
+	)
+	counts := make([]int64, len(zeroDateColumnNames))
+	countsPointers := make([]interface{}, len(counts))
+	for i := range counts {
+		countsPointers[i] = &counts[i]
+	}
+	if err := this.db.QueryRow(query).Scan(countsPointers...); err != nil {
+		return err
+	}
+	for i, columnName := range zeroDateColumnNames {
+		if rewrite != nil {
+			this.migrationContext.Log.Infof("Column %s has %d rows with zero dates, to be rewritten as %s", sql.EscapeName(columnName), counts[i], rewrite)
+		} else {
+			this.migrationContext.Log.Infof("Column %s has %d rows with zero dates, to be copied as is", sql.EscapeName(columnName), counts[i])
+		}
+	}
+	return nil
+}
+
+// validateAddedZeroDateColumns checks date columns which the ALTER adds as NOT NULL without a DEFAULT. Copied rows
+// take the implicit zero date on such columns, which strict sql_mode rejects.
+func (this *Inspector) validateAddedZeroDateColumns() error {
+	sqlMode := strings.ToUpper(this.migrationContext.ApplierSQLMode)
+	if !strings.Contains(sqlMode, "STRICT_ALL_TABLES") && !strings.Contains(sqlMode, "STRICT_TRANS_TABLES") {
+		return nil
+	}
+	query := `
+		select
+				COLUMN_NAME
+			from
+				information_schema.columns
+			where
+				table_schema=?
+				and table_name=?
+				and data_type in ('date', 'datetime', 'timestamp')
+				and is_nullable='NO'
+				and column_default is null
+				and extra not like '%GENERATED%'
+		`
+	return sqlutils.QueryRowsMap(this.db, query, func(m sqlutils.RowMap) error {
+		columnName := m.GetString("COLUMN_NAME")
+		if this.migrationContext.MappedSharedColumns.GetColumn(columnName) != nil {
+			return nil
+		}
+		return fmt.Errorf("Column %s is added by the ALTER as NOT NULL without a DEFAULT. Copied rows would take the zero date, which sql_mode '%s' rejects. Add a DEFAULT, or provide --skip-strict-mode along with --allow-zero-dates", sql.EscapeName(columnName), this.migrationContext.ApplierSQLMode)
+	}, this.migrationContext.GetTargetDatabaseName(), this.migrationContext.GetGhostTableName())
+}
+
+// validateRowFilter validates --row-filter against both tables: row copy reads the original table's rows
+// by the filter, and binlog event apply removes the ghost table's rows not matching it. Events are applied
+// as whole rows, hence the filter requires full row images.
+func (this *Inspector) validateRowFilter() error {
+	if this.migrationContext.RowFilter == "" {
+		return nil
+	}
+	if this.migrationContext.OriginalBinlogRowImage != "FULL" {
+		return fmt.Errorf("--row-filter applies binlog events as whole rows, hence requires binlog_row_image=FULL, but %s has '%s'", this.connectionConfig.Key.String(), this.migrationContext.OriginalBinlogRowImage)
+	}
+	for _, table := range [][2]string{
+		{this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName},
+		{this.migrationContext.GetTargetDatabaseName(), this.migrationContext.GetGhostTableName()},
+	} {
+		query := fmt.Sprintf(`select /* gh-ost */ 1 from %s.%s where (%s) limit 1`,
+			sql.EscapeName(table[0]),
+			sql.EscapeName(table[1]),
+			this.migrationContext.RowFilter,
+		)
+		rows, err := this.db.Query(query)
+		if err != nil {
+			return fmt.Errorf("--row-filter is invalid on %s.%s: %+v. The filter must be valid on both the original and the ghost table", sql.EscapeName(table[0]), sql.EscapeName(table[1]), err)
 		}
+		rows.Close()
 	}
+	this.migrationContext.Log.Infof("Migrating only rows matching --row-filter: %s", this.migrationContext.RowFilter)
+	return nil
+}
 
+// validateGhostTableSpatialIndexes warns about SPATIAL indexes on the ghost table, which are supported, and yet
+// make for a slower row copy
+func (this *Inspector) validateGhostTableSpatialIndexes() error {
+	query := `
+		select
+				distinct INDEX_NAME
+			from
+				information_schema.statistics
+			where
+				table_schema=?
+				and table_name=?
+				and index_type='SPATIAL'
+		`
+	var spatialIndexes []string
+	err := sqlutils.QueryRowsMap(this.db, query, func(m sqlutils.RowMap) error {
+		spatialIndexes = append(spatialIndexes, sql.EscapeName(m.GetString("INDEX_NAME")))
+		return nil
+	}, this.migrationContext.GetTargetDatabaseName(), this.migrationContext.GetGhostTableName())
+	if err != nil {
+		return err
+	}
+	if len(spatialIndexes) > 0 {
+		this.migrationContext.Log.Warningf("Ghost table has SPATIAL index(es) %s. Row copy maintains these R-tree indexes row by row, and may be considerably slower; consider a smaller --chunk-size", strings.Join(spatialIndexes, ", "))
+	}
 	return nil
 }
 
@@ -218,6 +510,26 @@ func (this *Inspector) validateConnection() error {
 	return err
 }
 
+// detectMySQLFlavor figures out whether the inspected server is MySQL or MariaDB, unless
+// explicitly provided via --mysql-flavor
+func (this *Inspector) detectMySQLFlavor() error {
+	if this.migrationContext.MySQLFlavor != "" {
+		this.migrationContext.Log.Infof("MySQL flavor explicitly provided: %s", this.migrationContext.MySQLFlavor)
+		return nil
+	}
+	query := `select @@global.version, @@global.version_comment`
+	var version, versionComment string
+	if err := this.db.QueryRow(query).Scan(&version, &versionComment); err != nil {
+		return err
+	}
+	this.migrationContext.MySQLFlavor = "mysql"
+	if strings.Contains(strings.ToLower(version), "mariadb") || strings.Contains(strings.ToLower(versionComment), "mariadb") {
+		this.migrationContext.MySQLFlavor = "mariadb"
+	}
+	this.migrationContext.Log.Infof("MySQL flavor detected on %s: %s", this.connectionConfig.Key.String(), this.migrationContext.MySQLFlavor)
+	return nil
+}
+
 // validateGrants verifies the user by which we're executing has necessary grants
 // to do its thing.
 func (this *Inspector) validateGrants() error {
@@ -227,6 +539,7 @@ func (this *Inspector) validateGrants() error {
 	foundReplicationClient := false
 	foundReplicationSlave := false
 	foundDBAll := false
+	foundTargetDBAll := !this.migrationContext.IsCrossSchemaMigration()
 
 	err := sqlutils.QueryRowsMap(this.db, query, func(rowMap sqlutils.RowMap) error {
 		for _, grantData := range rowMap {
@@ -243,17 +556,11 @@ func (this *Inspector) validateGrants() error {
 			if strings.Contains(grant, `REPLICATION SLAVE`) && strings.Contains(grant, ` ON *.*`) {
 				foundReplicationSlave = true
 			}
-			if strings.Contains(grant, fmt.Sprintf("GRANT ALL PRIVILEGES ON `%s`.*", this.migrationContext.DatabaseName)) {
-				foundDBAll = true
-			}
-			if strings.Contains(grant, fmt.Sprintf("GRANT ALL PRIVILEGES ON `%s`.*", strings.Replace(this.migrationContext.DatabaseName, "_", "\\_", -1))) {
-				foundDBAll = true
-			}
-			if base.StringContainsAll(grant, `ALTER`, `CREATE`, `DELETE`, `DROP`, `INDEX`, `INSERT`, `LOCK TABLES`, `SELECT`, `TRIGGER`, `UPDATE`, ` ON *.*`) {
+			if grantsAllOnDatabase(grant, this.migrationContext.DatabaseName) {
 				foundDBAll = true
 			}
-			if base.StringContainsAll(grant, `ALTER`, `CREATE`, `DELETE`, `DROP`, `INDEX`, `INSERT`, `LOCK TABLES`, `SELECT`, `TRIGGER`, `UPDATE`, fmt.Sprintf(" ON `%s`.*", this.migrationContext.DatabaseName)) {
-				foundDBAll = true
+			if grantsAllOnDatabase(grant, this.migrationContext.GetTargetDatabaseName()) {
+				foundTargetDBAll = true
 			}
 		}
 		return nil
@@ -267,16 +574,37 @@ func (this *Inspector) validateGrants() error {
 		this.migrationContext.Log.Infof("User has ALL privileges")
 		return nil
 	}
-	if foundSuper && foundReplicationSlave && foundDBAll {
-		this.migrationContext.Log.Infof("User has SUPER, REPLICATION SLAVE privileges, and has ALL privileges on %s.*", sql.EscapeName(this.migrationContext.DatabaseName))
+	databaseNames := sql.EscapeName(this.migrationContext.DatabaseName)
+	if this.migrationContext.IsCrossSchemaMigration() {
+		databaseNames = fmt.Sprintf("%s.* and %s", databaseNames, sql.EscapeName(this.migrationContext.GetTargetDatabaseName()))
+	}
+	if foundSuper && foundReplicationSlave && foundDBAll && foundTargetDBAll {
+		this.migrationContext.Log.Infof("User has SUPER, REPLICATION SLAVE privileges, and has ALL privileges on %s.*", databaseNames)
 		return nil
 	}
-	if foundReplicationClient && foundReplicationSlave && foundDBAll {
-		this.migrationContext.Log.Infof("User has REPLICATION CLIENT, REPLICATION SLAVE privileges, and has ALL privileges on %s.*", sql.EscapeName(this.migrationContext.DatabaseName))
+	if foundReplicationClient && foundReplicationSlave && foundDBAll && foundTargetDBAll {
+		this.migrationContext.Log.Infof("User has REPLICATION CLIENT, REPLICATION SLAVE privileges, and has ALL privileges on %s.*", databaseNames)
 		return nil
 	}
-	this.migrationContext.Log.Debugf("Privileges: Super: %t, REPLICATION CLIENT: %t, REPLICATION SLAVE: %t, ALL on *.*: %t, ALL on %s.*: %t", foundSuper, foundReplicationClient, foundReplicationSlave, foundAll, sql.EscapeName(this.migrationContext.DatabaseName), foundDBAll)
-	return this.migrationContext.Log.Errorf("User has insufficient privileges for migration. Needed: SUPER|REPLICATION CLIENT, REPLICATION SLAVE and ALL on %s.*", sql.EscapeName(this.migrationContext.DatabaseName))
+	this.migrationContext.Log.Debugf("Privileges: Super: %t, REPLICATION CLIENT: %t, REPLICATION SLAVE: %t, ALL on *.*: %t, ALL on %s.*: %t, ALL on %s.*: %t", foundSuper, foundReplicationClient, foundReplicationSlave, foundAll, sql.EscapeName(this.migrationContext.DatabaseName), foundDBAll, sql.EscapeName(this.migrationContext.GetTargetDatabaseName()), foundTargetDBAll)
+	return this.migrationContext.Log.Errorf("User has insufficient privileges for migration. Needed: SUPER|REPLICATION CLIENT, REPLICATION SLAVE and ALL on %s.*", databaseNames)
+}
+
+// grantsAllOnDatabase tells whether given grant covers the privileges gh-ost requires on given database
+func grantsAllOnDatabase(grant string, databaseName string) bool {
+	if strings.Contains(grant, fmt.Sprintf("GRANT ALL PRIVILEGES ON `%s`.*", databaseName)) {
+		return true
+	}
+	if strings.Contains(grant, fmt.Sprintf("GRANT ALL PRIVILEGES ON `%s`.*", strings.Replace(databaseName, "_", "\\_", -1))) {
+		return true
+	}
+	if base.StringContainsAll(grant, `ALTER`, `CREATE`, `DELETE`, `DROP`, `INDEX`, `INSERT`, `LOCK TABLES`, `SELECT`, `TRIGGER`, `UPDATE`, ` ON *.*`) {
+		return true
+	}
+	if base.StringContainsAll(grant, `ALTER`, `CREATE`, `DELETE`, `DROP`, `INDEX`, `INSERT`, `LOCK TABLES`, `SELECT`, `TRIGGER`, `UPDATE`, fmt.Sprintf(" ON `%s`.*", databaseName)) {
+		return true
+	}
+	return false
 }
 
 // restartReplication is required so that we are _certain_ the binlog format and
@@ -369,8 +697,53 @@ func (this *Inspector) validateBinlogs() error {
 		this.migrationContext.OriginalBinlogRowImage = "FULL"
 	}
 	this.migrationContext.OriginalBinlogRowImage = strings.ToUpper(this.migrationContext.OriginalBinlogRowImage)
-	if this.migrationContext.OriginalBinlogRowImage != "FULL" {
-		return fmt.Errorf("%s has '%s' binlog_row_image, and only 'FULL' is supported. This operation cannot proceed. You may `set global binlog_row_image='full'` and try again", this.connectionConfig.Key.String(), this.migrationContext.OriginalBinlogRowImage)
+	switch this.migrationContext.OriginalBinlogRowImage {
+	case "FULL":
+	case "MINIMAL":
+		this.migrationContext.Log.Infof("%s has 'MINIMAL' binlog_row_image. DML events will only apply the columns present in row images", this.connectionConfig.Key.String())
+	default:
+		return fmt.Errorf("%s has '%s' binlog_row_image, and only 'FULL' and 'MINIMAL' are supported. This operation cannot proceed. You may `set global binlog_row_image='full'` and try again", this.connectionConfig.Key.String(), this.migrationContext.OriginalBinlogRowImage)
+	}
+	query = `select @@global.gtid_mode`
+	var gtidMode string
+	if err := this.db.QueryRow(query).Scan(&gtidMode); err != nil {
+		// Only as of 5.6. Servers without gtid_mode stream by file/pos
+		gtidMode = "OFF"
+	}
+	this.migrationContext.IsGTIDModeEnabled = strings.ToUpper(gtidMode) == "ON"
+	if this.migrationContext.IsGTIDModeEnabled {
+		this.migrationContext.Log.Infof("gtid_mode is ON on %s, binlog streaming will use GTID coordinates", this.connectionConfig.Key.String())
+	}
+	query = `select @@global.binlog_transaction_compression`
+	var binlogTransactionCompression bool
+	if err := this.db.QueryRow(query).Scan(&binlogTransactionCompression); err != nil {
+		// Only as of 8.0.20
+		binlogTransactionCompression = false
+	}
+	if binlogTransactionCompression {
+		this.migrationContext.Log.Infof("binlog_transaction_compression is enabled on %s, compressed transaction payloads will be decompressed while streaming", this.connectionConfig.Key.String())
+	}
+	query = `select @@global.binlog_row_value_options`
+	var binlogRowValueOptions string
+	if err := this.db.QueryRow(query).Scan(&binlogRowValueOptions); err != nil {
+		// Only as of 8.0.3
+		binlogRowValueOptions = ""
+	}
+	if strings.Contains(strings.ToUpper(binlogRowValueOptions), "PARTIAL_JSON") {
+		if !this.migrationContext.AllowPartialJSONUpdates {
+			return fmt.Errorf("%s has binlog_row_value_options=PARTIAL_JSON. This operation cannot proceed unless partial JSON updates are allowed with --allow-partial-json-updates. You may also `set global binlog_row_value_options=''` and try again", this.connectionConfig.Key.String())
+		}
+		this.migrationContext.Log.Infof("%s has binlog_row_value_options=PARTIAL_JSON; partial JSON updates are applied onto the ghost table as JSON_SET/JSON_ARRAY_INSERT/JSON_REMOVE", this.connectionConfig.Key.String())
+	}
+	if this.migrationContext.AuditDMLFile != "" {
+		rowsQueryVariable := "binlog_rows_query_log_events"
+		if this.migrationContext.IsMariaDB() {
+			rowsQueryVariable = "binlog_annotate_row_events"
+		}
+		var logRowsQueries bool
+		if err := this.db.QueryRow(fmt.Sprintf(`select @@global.%s`, rowsQueryVariable)).Scan(&logRowsQueries); err != nil || !logRowsQueries {
+			this.migrationContext.Log.Warningf("%s is not enabled on %s; --audit-dml-file will lack originating queries", rowsQueryVariable, this.connectionConfig.Key.String())
+		}
 	}
 
 	this.migrationContext.Log.Infof("binary logs validated on %s", this.connectionConfig.Key.String())
@@ -416,6 +789,7 @@ func (this *Inspector) validateTable() error {
 		this.migrationContext.TableEngine = rowMap.GetString("Engine")
 		this.migrationContext.RowsEstimate = rowMap.GetInt64("Rows")
 		this.migrationContext.UsedRowsEstimateMethod = base.TableStatusRowsEstimate
+		this.migrationContext.TableAvgRowLength = rowMap.GetInt64("Avg_row_length")
 		if rowMap.GetString("Comment") == "VIEW" {
 			return fmt.Errorf("%s.%s is a VIEW, not a real table. Bailing out", sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName))
 		}
@@ -431,6 +805,7 @@ func (this *Inspector) validateTable() error {
 	}
 	this.migrationContext.Log.Infof("Table found. Engine=%s", this.migrationContext.TableEngine)
 	this.migrationContext.Log.Debugf("Estimated number of rows via STATUS: %d", this.migrationContext.RowsEstimate)
+	this.migrationContext.Log.Debugf("Average row length via STATUS: %d", this.migrationContext.TableAvgRowLength)
 	return nil
 }
 
@@ -471,98 +846,550 @@ func (this *Inspector) validateTableForeignKeys(allowChildForeignKeys bool) erro
 		return err
 	}
 	if numParentForeignKeys > 0 {
-		return this.migrationContext.Log.Errorf("Found %d parent-side foreign keys on %s.%s. Parent-side foreign keys are not supported. Bailing out", numParentForeignKeys, sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName))
+		parentSideForeignKeys, err := this.getParentSideForeignKeyNames()
+		if err != nil {
+			return err
+		}
+		return this.migrationContext.Log.Errorf("Found %d parent-side foreign keys on %s.%s: %s. Parent-side foreign keys are not supported, as the referencing tables would keep referencing the original table after cut-over. Bailing out", numParentForeignKeys, sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName), strings.Join(parentSideForeignKeys, ", "))
 	}
 	if numChildForeignKeys > 0 {
 		if allowChildForeignKeys {
 			this.migrationContext.Log.Debugf("Foreign keys found and will be dropped, as per given --discard-foreign-keys flag")
 			return nil
 		}
-		return this.migrationContext.Log.Errorf("Found %d child-side foreign keys on %s.%s. Child-side foreign keys are not supported. Bailing out", numChildForeignKeys, sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName))
+		if this.migrationContext.PreserveForeignKeys {
+			return this.validateChildSideForeignKeys()
+		}
+		return this.migrationContext.Log.Errorf("Found %d child-side foreign keys on %s.%s. Child-side foreign keys are not supported. Bailing out. Supply --preserve-foreign-keys to have them created on the ghost table", numChildForeignKeys, sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName))
 	}
 	this.migrationContext.Log.Debugf("Validated no foreign keys exist on table")
 	return nil
 }
 
-// validateTableTriggers makes sure no triggers exist on the migrated table
-func (this *Inspector) validateTableTriggers() error {
+// getParentSideForeignKeyNames lists the foreign keys referencing the migrated table, as `schema`.`table`.`constraint`
+func (this *Inspector) getParentSideForeignKeyNames() (names []string, err error) {
 	query := `
-		SELECT COUNT(*) AS num_triggers
-			FROM INFORMATION_SCHEMA.TRIGGERS
-			WHERE
-				TRIGGER_SCHEMA=?
-				AND EVENT_OBJECT_TABLE=?
+		SELECT DISTINCT
+			TABLE_SCHEMA,
+			TABLE_NAME,
+			CONSTRAINT_NAME
+		FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE
+		WHERE
+			REFERENCED_TABLE_SCHEMA=?
+			AND REFERENCED_TABLE_NAME=?
+		ORDER BY TABLE_SCHEMA, TABLE_NAME, CONSTRAINT_NAME
 	`
-	numTriggers := 0
-	err := sqlutils.QueryRowsMap(this.db, query, func(rowMap sqlutils.RowMap) error {
-		numTriggers = rowMap.GetInt("num_triggers")
-
+	err = sqlutils.QueryRowsMap(this.db, query, func(m sqlutils.RowMap) error {
+		names = append(names, fmt.Sprintf("%s.%s.%s", sql.EscapeName(m.GetString("TABLE_SCHEMA")), sql.EscapeName(m.GetString("TABLE_NAME")), sql.EscapeName(m.GetString("CONSTRAINT_NAME"))))
 		return nil
-	},
-		this.migrationContext.DatabaseName,
-		this.migrationContext.OriginalTableName,
-	)
-	if err != nil {
-		return err
-	}
-	if numTriggers > 0 {
-		return this.migrationContext.Log.Errorf("Found triggers on %s.%s. Triggers are not supported at this time. Bailing out", sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName))
-	}
-	this.migrationContext.Log.Debugf("Validated no triggers exist on table")
-	return nil
+	}, this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName)
+	return names, err
 }
 
-// estimateTableRowsViaExplain estimates number of rows on original table
-func (this *Inspector) estimateTableRowsViaExplain() error {
-	query := fmt.Sprintf(`explain select /* gh-ost */ * from %s.%s where 1=1`, sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName))
-
-	outputFound := false
-	err := sqlutils.QueryRowsMap(this.db, query, func(rowMap sqlutils.RowMap) error {
-		this.migrationContext.RowsEstimate = rowMap.GetInt64("rows")
-		this.migrationContext.UsedRowsEstimateMethod = base.ExplainRowsEstimate
-		outputFound = true
-
+// getChildSideForeignKeys reads the foreign keys of the migrated table, along with their referential actions
+func (this *Inspector) getChildSideForeignKeys() (foreignKeys [](*sql.ForeignKey), err error) {
+	query := `
+		SELECT
+			KEY_COLUMN_USAGE.CONSTRAINT_NAME,
+			GROUP_CONCAT(KEY_COLUMN_USAGE.COLUMN_NAME ORDER BY KEY_COLUMN_USAGE.ORDINAL_POSITION ASC) AS COLUMN_NAMES,
+			KEY_COLUMN_USAGE.REFERENCED_TABLE_SCHEMA,
+			KEY_COLUMN_USAGE.REFERENCED_TABLE_NAME,
+			GROUP_CONCAT(KEY_COLUMN_USAGE.REFERENCED_COLUMN_NAME ORDER BY KEY_COLUMN_USAGE.ORDINAL_POSITION ASC) AS REFERENCED_COLUMN_NAMES,
+			REFERENTIAL_CONSTRAINTS.UPDATE_RULE,
+			REFERENTIAL_CONSTRAINTS.DELETE_RULE
+		FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE INNER JOIN INFORMATION_SCHEMA.REFERENTIAL_CONSTRAINTS
+		ON (
+			REFERENTIAL_CONSTRAINTS.CONSTRAINT_SCHEMA = KEY_COLUMN_USAGE.CONSTRAINT_SCHEMA
+			AND REFERENTIAL_CONSTRAINTS.CONSTRAINT_NAME = KEY_COLUMN_USAGE.CONSTRAINT_NAME
+			AND REFERENTIAL_CONSTRAINTS.TABLE_NAME = KEY_COLUMN_USAGE.TABLE_NAME
+		)
+		WHERE
+			KEY_COLUMN_USAGE.TABLE_SCHEMA=?
+			AND KEY_COLUMN_USAGE.TABLE_NAME=?
+			AND KEY_COLUMN_USAGE.REFERENCED_TABLE_NAME IS NOT NULL
+		GROUP BY
+			KEY_COLUMN_USAGE.CONSTRAINT_NAME,
+			KEY_COLUMN_USAGE.REFERENCED_TABLE_SCHEMA,
+			KEY_COLUMN_USAGE.REFERENCED_TABLE_NAME,
+			REFERENTIAL_CONSTRAINTS.UPDATE_RULE,
+			REFERENTIAL_CONSTRAINTS.DELETE_RULE
+		ORDER BY KEY_COLUMN_USAGE.CONSTRAINT_NAME
+	`
+	err = sqlutils.QueryRowsMap(this.db, query, func(m sqlutils.RowMap) error {
+		foreignKey := &sql.ForeignKey{
+			Name:              m.GetString("CONSTRAINT_NAME"),
+			Columns:           *sql.ParseColumnList(m.GetString("COLUMN_NAMES")),
+			ReferencedSchema:  m.GetString("REFERENCED_TABLE_SCHEMA"),
+			ReferencedTable:   m.GetString("REFERENCED_TABLE_NAME"),
+			ReferencedColumns: *sql.ParseColumnList(m.GetString("REFERENCED_COLUMN_NAMES")),
+			UpdateRule:        m.GetString("UPDATE_RULE"),
+			DeleteRule:        m.GetString("DELETE_RULE"),
+		}
+		foreignKeys = append(foreignKeys, foreignKey)
 		return nil
-	})
-	if err != nil {
+	}, this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName)
+	return foreignKeys, err
+}
+
+// validateChildSideForeignKeys reads the foreign keys of the migrated table and makes sure they can
+// be created on the ghost table, which is what --preserve-foreign-keys does just before cut-over
+func (this *Inspector) validateChildSideForeignKeys() (err error) {
+	if this.migrationContext.OriginalTableForeignKeys, err = this.getChildSideForeignKeys(); err != nil {
 		return err
 	}
-	if !outputFound {
-		return this.migrationContext.Log.Errorf("Cannot run EXPLAIN on %s.%s!", sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName))
+	for _, foreignKey := range this.migrationContext.OriginalTableForeignKeys {
+		if foreignKey.HasCascadingRules() {
+			return this.migrationContext.Log.Errorf("Foreign key %s has referential actions other than RESTRICT/NO ACTION (on update %s; on delete %s). Cascaded changes are not written to the binary logs and would not apply onto the ghost table. Bailing out", sql.EscapeName(foreignKey.Name), foreignKey.UpdateRule, foreignKey.DeleteRule)
+		}
+		if len(foreignKey.GhostName()) > sql.MaxForeignKeyNameLength {
+			return this.migrationContext.Log.Errorf("Foreign key %s would be named %s on the ghost table, which exceeds %d characters. Bailing out", sql.EscapeName(foreignKey.Name), sql.EscapeName(foreignKey.GhostName()), sql.MaxForeignKeyNameLength)
+		}
+		query := `
+			SELECT TABLE_NAME
+				FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS
+				WHERE
+					CONSTRAINT_SCHEMA=?
+					AND CONSTRAINT_NAME=?
+		`
+		numConstraints := 0
+		err := sqlutils.QueryRowsMap(this.db, query, func(m sqlutils.RowMap) error {
+			if m.GetString("TABLE_NAME") == this.migrationContext.GetGhostTableName() && this.migrationContext.InitiallyDropGhostTable {
+				// A leftover ghost table, about to be dropped
+				return nil
+			}
+			numConstraints++
+			return nil
+		}, this.migrationContext.GetTargetDatabaseName(), foreignKey.GhostName())
+		if err != nil {
+			return err
+		}
+		if numConstraints > 0 {
+			return this.migrationContext.Log.Errorf("Foreign key %s would be named %s on the ghost table, but a constraint by that name already exists in %s. Bailing out", sql.EscapeName(foreignKey.Name), sql.EscapeName(foreignKey.GhostName()), sql.EscapeName(this.migrationContext.GetTargetDatabaseName()))
+		}
 	}
-	this.migrationContext.Log.Infof("Estimated number of rows via EXPLAIN: %d", this.migrationContext.RowsEstimate)
+	this.migrationContext.Log.Infof("Foreign keys will be created on the ghost table before cut-over: %+v", this.migrationContext.OriginalTableForeignKeys)
 	return nil
 }
 
-// Kill kills a query for connectionID.
-// - @amason: this should go somewhere _other_ than `logic`, but I couldn't decide
-// between `base`, `sql`, or `mysql`.
-func Kill(db *gosql.DB, connectionID string) error {
-	_, err := db.Exec(`KILL QUERY %s`, connectionID)
-	return err
+// getCheckConstraints reads the CHECK constraints of given table. Servers predating MySQL 8.0.16 have none.
+func (this *Inspector) getCheckConstraints(databaseName, tableName string) (checkConstraints [](*sql.CheckConstraint), err error) {
+	query := `
+		SELECT
+			TABLE_CONSTRAINTS.CONSTRAINT_NAME,
+			CHECK_CONSTRAINTS.CHECK_CLAUSE,
+			TABLE_CONSTRAINTS.ENFORCED
+		FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS INNER JOIN INFORMATION_SCHEMA.CHECK_CONSTRAINTS
+		ON (
+			CHECK_CONSTRAINTS.CONSTRAINT_SCHEMA = TABLE_CONSTRAINTS.CONSTRAINT_SCHEMA
+			AND CHECK_CONSTRAINTS.CONSTRAINT_NAME = TABLE_CONSTRAINTS.CONSTRAINT_NAME
+		)
+		WHERE
+			TABLE_CONSTRAINTS.TABLE_SCHEMA=?
+			AND TABLE_CONSTRAINTS.TABLE_NAME=?
+			AND TABLE_CONSTRAINTS.CONSTRAINT_TYPE='CHECK'
+		ORDER BY TABLE_CONSTRAINTS.CONSTRAINT_NAME
+	`
+	err = sqlutils.QueryRowsMap(this.db, query, func(m sqlutils.RowMap) error {
+		checkConstraints = append(checkConstraints, &sql.CheckConstraint{
+			Name:     m.GetString("CONSTRAINT_NAME"),
+			Clause:   m.GetString("CHECK_CLAUSE"),
+			Enforced: strings.EqualFold(m.GetString("ENFORCED"), "YES"),
+		})
+		return nil
+	}, databaseName, tableName)
+	if mysql.IsUnknownTableOrColumnError(err) {
+		return nil, nil
+	}
+	return checkConstraints, err
 }
 
-// CountTableRows counts exact number of rows on the original table
-func (this *Inspector) CountTableRows(ctx context.Context) error {
-	atomic.StoreInt64(&this.migrationContext.CountingRowsFlag, 1)
-	defer atomic.StoreInt64(&this.migrationContext.CountingRowsFlag, 0)
-
-	this.migrationContext.Log.Infof("As instructed, I'm issuing a SELECT COUNT(*) on the table. This may take a while")
-
-	conn, err := this.db.Conn(ctx)
-	if err != nil {
+// inspectCheckConstraints compares the CHECK constraints of the original and ghost tables, following the ALTER.
+// See validateCheckConstraints. Ghost table constraints are then renamed onto the original constraints' ghost
+// names, unless a constraint by that name already exists in the schema.
+func (this *Inspector) inspectCheckConstraints() (err error) {
+	if this.migrationContext.OriginalTableCheckConstraints, err = this.getCheckConstraints(this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName); err != nil {
 		return err
 	}
-	defer conn.Close()
-
-	var connectionID string
-	if err := conn.QueryRowContext(ctx, `SELECT /* gh-ost */ CONNECTION_ID()`).Scan(&connectionID); err != nil {
+	if this.migrationContext.GhostTableCheckConstraints, err = this.getCheckConstraints(this.migrationContext.GetTargetDatabaseName(), this.migrationContext.GetGhostTableName()); err != nil {
 		return err
 	}
-
-	query := fmt.Sprintf(`select /* gh-ost */ count(*) as count_rows from %s.%s`, sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName))
-	var rowsEstimate int64
-	if err := conn.QueryRowContext(ctx, query).Scan(&rowsEstimate); err != nil {
+	if err := this.validateCheckConstraints(); err != nil {
+		return err
+	}
+	for ghostName, newName := range this.migrationContext.GhostCheckConstraintRenames {
+		query := `
+			SELECT COUNT(*) AS num_constraints
+				FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS
+				WHERE
+					CONSTRAINT_SCHEMA=?
+					AND CONSTRAINT_NAME=?
+		`
+		numConstraints := 0
+		err := sqlutils.QueryRowsMap(this.db, query, func(m sqlutils.RowMap) error {
+			numConstraints = m.GetInt("num_constraints")
+			return nil
+		}, this.migrationContext.GetTargetDatabaseName(), newName)
+		if err != nil {
+			return err
+		}
+		if numConstraints > 0 {
+			this.migrationContext.Log.Warningf("CHECK constraint %s of the ghost table cannot be renamed to %s, since a constraint by that name already exists in %s. Its name is generated following cut-over", sql.EscapeName(ghostName), sql.EscapeName(newName), sql.EscapeName(this.migrationContext.GetTargetDatabaseName()))
+			delete(this.migrationContext.GhostCheckConstraintRenames, ghostName)
+		}
+	}
+	return nil
+}
+
+// validateCheckConstraints matches the CHECK constraints of the ghost table against those of the original table, by
+// their clauses. It bails out when the ALTER drops enforcement of an original constraint, unless
+// --allow-dropped-check-constraints. Constraints newly enforced by the ghost table are noted for row copy to verify:
+// INSERT IGNORE would otherwise silently skip rows violating them. CREATE TABLE ... LIKE generates the ghost table's
+// constraint names, as these are unique per schema; user named constraints are noted for renaming, lest they lose
+// their names by cut-over.
+func (this *Inspector) validateCheckConstraints() error {
+	originalConstraints := this.migrationContext.OriginalTableCheckConstraints
+	ghostConstraints := this.migrationContext.GhostTableCheckConstraints
+	this.migrationContext.GhostCheckConstraintRenames = map[string]string{}
+	this.migrationContext.NewCheckConstraints = nil
+
+	matches := map[*sql.CheckConstraint]*sql.CheckConstraint{}
+	matched := map[*sql.CheckConstraint]bool{}
+	for _, matchEnforcement := range []bool{true, false} {
+		for _, originalConstraint := range originalConstraints {
+			if matches[originalConstraint] != nil {
+				continue
+			}
+			mappedClause := originalConstraint.MapClause(this.migrationContext.ColumnRenameMap)
+			for _, ghostConstraint := range ghostConstraints {
+				if matched[ghostConstraint] || ghostConstraint.Clause != mappedClause {
+					continue
+				}
+				if matchEnforcement && ghostConstraint.Enforced != originalConstraint.Enforced {
+					continue
+				}
+				matches[originalConstraint] = ghostConstraint
+				matched[ghostConstraint] = true
+				break
+			}
+		}
+	}
+
+	droppedEnforcement := []string{}
+	for _, originalConstraint := range originalConstraints {
+		ghostConstraint := matches[originalConstraint]
+		if originalConstraint.Enforced {
+			if ghostConstraint == nil {
+				droppedEnforcement = append(droppedEnforcement, fmt.Sprintf("%s: not found on ghost table", originalConstraint))
+				continue
+			}
+			if !ghostConstraint.Enforced {
+				droppedEnforcement = append(droppedEnforcement, fmt.Sprintf("%s: NOT ENFORCED on ghost table as %s", originalConstraint, sql.EscapeName(ghostConstraint.Name)))
+			}
+		}
+		if ghostConstraint == nil || originalConstraint.HasGeneratedName(this.migrationContext.OriginalTableName) {
+			// Generated names are renamed along with the table by cut-over
+			continue
+		}
+		if ghostName := originalConstraint.GhostName(); ghostConstraint.Name != ghostName {
+			if len(ghostName) > sql.MaxForeignKeyNameLength {
+				this.migrationContext.Log.Warningf("CHECK constraint %s would be named %s on the ghost table, which exceeds %d characters. Its name is generated following cut-over", sql.EscapeName(originalConstraint.Name), sql.EscapeName(ghostName), sql.MaxForeignKeyNameLength)
+				continue
+			}
+			this.migrationContext.GhostCheckConstraintRenames[ghostConstraint.Name] = ghostName
+		}
+	}
+	if len(droppedEnforcement) > 0 {
+		if !this.migrationContext.AllowDroppedCheckConstraints {
+			return this.migrationContext.Log.Errorf("The ALTER drops enforcement of CHECK constraints on %s.%s: %s. Bailing out. Supply --allow-dropped-check-constraints if this is intended", sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName), strings.Join(droppedEnforcement, "; "))
+		}
+		this.migrationContext.Log.Warningf("The ALTER drops enforcement of CHECK constraints, per --allow-dropped-check-constraints: %s", strings.Join(droppedEnforcement, "; "))
+	}
+
+	// Columns of newly enforced constraints are mapped back onto the original table's
+	ghostColumnsMap := map[string]string{}
+	for i, column := range this.migrationContext.MappedSharedColumns.Names() {
+		ghostColumnsMap[column] = this.migrationContext.SharedColumns.Names()[i]
+	}
+	for _, ghostConstraint := range ghostConstraints {
+		if !ghostConstraint.Enforced {
+			continue
+		}
+		isNew := !matched[ghostConstraint]
+		for originalConstraint, match := range matches {
+			if match == ghostConstraint && !originalConstraint.Enforced {
+				isNew = true
+			}
+		}
+		if !isNew {
+			continue
+		}
+		checkable := true
+		for _, column := range ghostConstraint.ColumnNames() {
+			if _, ok := ghostColumnsMap[column]; !ok {
+				checkable = false
+			}
+		}
+		if !checkable {
+			this.migrationContext.Log.Warningf("CHECK constraint %s is newly enforced on the ghost table, and checks columns not copied from the original table. Row copy cannot verify it", ghostConstraint)
+			continue
+		}
+		this.migrationContext.NewCheckConstraints = append(this.migrationContext.NewCheckConstraints, &sql.CheckConstraint{
+			Name:     ghostConstraint.Name,
+			Clause:   ghostConstraint.MapClause(ghostColumnsMap),
+			Enforced: true,
+		})
+		this.migrationContext.Log.Infof("CHECK constraint %s is newly enforced on the ghost table. Row copy verifies copied rows satisfy it", ghostConstraint)
+	}
+	return nil
+}
+
+// validateTableTriggers makes sure no triggers exist on the migrated table
+func (this *Inspector) validateTableTriggers() error {
+	query := `
+		SELECT COUNT(*) AS num_triggers
+			FROM INFORMATION_SCHEMA.TRIGGERS
+			WHERE
+				TRIGGER_SCHEMA=?
+				AND EVENT_OBJECT_TABLE=?
+	`
+	numTriggers := 0
+	err := sqlutils.QueryRowsMap(this.db, query, func(rowMap sqlutils.RowMap) error {
+		numTriggers = rowMap.GetInt("num_triggers")
+
+		return nil
+	},
+		this.migrationContext.DatabaseName,
+		this.migrationContext.OriginalTableName,
+	)
+	if err != nil {
+		return err
+	}
+	if numTriggers > 0 {
+		if this.migrationContext.IncludeTriggers {
+			return this.validateIncludedTriggers()
+		}
+		return this.migrationContext.Log.Errorf("Found triggers on %s.%s. Triggers are not supported at this time, unless --include-triggers is given. Bailing out", sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName))
+	}
+	this.migrationContext.Log.Debugf("Validated no triggers exist on table")
+	return nil
+}
+
+// validateNewTableName makes sure the migrated table can take the --new-table-name at cut-over: no table or
+// view by that name exists, and no foreign key or view we can detect already references that name, which
+// would start referencing the migrated table
+func (this *Inspector) validateNewTableName() error {
+	if !this.migrationContext.IsTableRenamed() {
+		return nil
+	}
+	databaseName := this.migrationContext.GetTargetDatabaseName()
+	tableName := this.migrationContext.GetMigratedTableName()
+	query := `
+		SELECT
+			(SELECT COUNT(*) FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA=? AND TABLE_NAME=?) AS num_tables,
+			(SELECT COUNT(*) FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE WHERE REFERENCED_TABLE_SCHEMA=? AND REFERENCED_TABLE_NAME=?) AS num_foreign_keys,
+			(SELECT COUNT(*) FROM INFORMATION_SCHEMA.VIEWS WHERE LOCATE(?, VIEW_DEFINITION) > 0) AS num_views
+	`
+	numTables, numForeignKeys, numViews := 0, 0, 0
+	err := sqlutils.QueryRowsMap(this.db, query, func(rowMap sqlutils.RowMap) error {
+		numTables = rowMap.GetInt("num_tables")
+		numForeignKeys = rowMap.GetInt("num_foreign_keys")
+		numViews = rowMap.GetInt("num_views")
+		return nil
+	},
+		databaseName, tableName,
+		databaseName, tableName,
+		fmt.Sprintf("%s.%s", sql.EscapeName(databaseName), sql.EscapeName(tableName)),
+	)
+	if err != nil {
+		return err
+	}
+	if numTables > 0 {
+		return this.migrationContext.Log.Errorf("--new-table-name: %s.%s already exists. Bailing out", sql.EscapeName(databaseName), sql.EscapeName(tableName))
+	}
+	if numForeignKeys > 0 {
+		return this.migrationContext.Log.Errorf("--new-table-name: found %d foreign keys referencing %s.%s, which would reference the migrated table following cut-over. Bailing out", numForeignKeys, sql.EscapeName(databaseName), sql.EscapeName(tableName))
+	}
+	if numViews > 0 {
+		return this.migrationContext.Log.Errorf("--new-table-name: found %d views referencing %s.%s, which would reference the migrated table following cut-over. Bailing out", numViews, sql.EscapeName(databaseName), sql.EscapeName(tableName))
+	}
+	this.migrationContext.Log.Debugf("Validated %s.%s is free to take as the migrated table's name", sql.EscapeName(databaseName), sql.EscapeName(tableName))
+	return nil
+}
+
+// getTableTriggers reads the definitions of the migrated table's triggers, in order of execution
+func (this *Inspector) getTableTriggers() (triggers [](*sql.Trigger), err error) {
+	query := `
+		SELECT
+			TRIGGER_NAME,
+			ACTION_TIMING,
+			EVENT_MANIPULATION,
+			ACTION_STATEMENT,
+			SQL_MODE
+		FROM INFORMATION_SCHEMA.TRIGGERS
+		WHERE
+			TRIGGER_SCHEMA=?
+			AND EVENT_OBJECT_TABLE=?
+		ORDER BY ACTION_TIMING, EVENT_MANIPULATION, ACTION_ORDER
+	`
+	err = sqlutils.QueryRowsMap(this.db, query, func(m sqlutils.RowMap) error {
+		trigger := &sql.Trigger{
+			Name:      m.GetString("TRIGGER_NAME"),
+			Timing:    m.GetString("ACTION_TIMING"),
+			Event:     m.GetString("EVENT_MANIPULATION"),
+			Statement: m.GetString("ACTION_STATEMENT"),
+			SQLMode:   m.GetString("SQL_MODE"),
+		}
+		triggers = append(triggers, trigger)
+		return nil
+	}, this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName)
+	return triggers, err
+}
+
+// getTablePartitions reads the migrated table's partitions, in order. Subpartitions are accounted for by their
+// partition: a PARTITION clause naming a partition covers its subpartitions.
+func (this *Inspector) getTablePartitions() (partitions [](*sql.Partition), err error) {
+	query := `
+		SELECT
+			PARTITION_NAME,
+			SUM(TABLE_ROWS) AS TABLE_ROWS
+		FROM INFORMATION_SCHEMA.PARTITIONS
+		WHERE
+			TABLE_SCHEMA=?
+			AND TABLE_NAME=?
+			AND PARTITION_NAME IS NOT NULL
+		GROUP BY PARTITION_NAME
+		ORDER BY MIN(PARTITION_ORDINAL_POSITION)
+	`
+	err = sqlutils.QueryRowsMap(this.db, query, func(m sqlutils.RowMap) error {
+		partition := &sql.Partition{
+			Name:         m.GetString("PARTITION_NAME"),
+			RowsEstimate: m.GetInt64("TABLE_ROWS"),
+		}
+		partitions = append(partitions, partition)
+		return nil
+	}, this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName)
+	return partitions, err
+}
+
+// inspectTablePartitions has row copy iterate a partitioned table one partition at a time, such that
+// chunk queries only scan the partition they copy. The rows estimate is then the partitions' sum.
+func (this *Inspector) inspectTablePartitions() (err error) {
+	partitions, err := this.getTablePartitions()
+	if err != nil {
+		return err
+	}
+	if len(partitions) == 0 {
+		return nil
+	}
+	this.migrationContext.OriginalTablePartitions = partitions
+	var rowsEstimate int64
+	for _, partition := range partitions {
+		rowsEstimate += partition.RowsEstimate
+	}
+	this.migrationContext.RowsEstimate = rowsEstimate
+	this.migrationContext.Log.Infof("Table has %d partitions; row copy iterates per partition. Estimated number of rows via partitions: %d", len(partitions), rowsEstimate)
+	return nil
+}
+
+// validateIncludedTriggers reads the migrated table's triggers and makes sure they can be created on
+// the ghost table, which is what --include-triggers does during cut-over
+func (this *Inspector) validateIncludedTriggers() (err error) {
+	if this.migrationContext.OriginalTableTriggers, err = this.getTableTriggers(); err != nil {
+		return err
+	}
+	for _, trigger := range this.migrationContext.OriginalTableTriggers {
+		if len(trigger.GhostName()) > sql.MaxTriggerNameLength {
+			return this.migrationContext.Log.Errorf("Trigger %s would be named %s on the ghost table, which exceeds %d characters. Bailing out", sql.EscapeName(trigger.Name), sql.EscapeName(trigger.GhostName()), sql.MaxTriggerNameLength)
+		}
+		if trigger.ReferencesTable(this.migrationContext.OriginalTableName) && !this.migrationContext.AllowSelfReferencingTriggers {
+			return this.migrationContext.Log.Errorf("Trigger %s seems to reference %s, the migrated table. During cut-over the trigger is created on the ghost table, and such references resolve to the table by name at execution time. Supply --allow-self-referencing-triggers if you have reviewed the trigger's body. Bailing out", sql.EscapeName(trigger.Name), sql.EscapeName(this.migrationContext.OriginalTableName))
+		}
+		query := `
+			SELECT EVENT_OBJECT_TABLE
+				FROM INFORMATION_SCHEMA.TRIGGERS
+				WHERE
+					TRIGGER_SCHEMA=?
+					AND TRIGGER_NAME=?
+		`
+		numTriggers := 0
+		err := sqlutils.QueryRowsMap(this.db, query, func(m sqlutils.RowMap) error {
+			if m.GetString("EVENT_OBJECT_TABLE") == this.migrationContext.GetGhostTableName() && this.migrationContext.InitiallyDropGhostTable {
+				// A leftover ghost table, about to be dropped
+				return nil
+			}
+			numTriggers++
+			return nil
+		}, this.migrationContext.DatabaseName, trigger.GhostName())
+		if err != nil {
+			return err
+		}
+		if numTriggers > 0 {
+			return this.migrationContext.Log.Errorf("Trigger %s would be named %s on the ghost table, but a trigger by that name already exists in %s. Bailing out", sql.EscapeName(trigger.Name), sql.EscapeName(trigger.GhostName()), sql.EscapeName(this.migrationContext.DatabaseName))
+		}
+	}
+	this.migrationContext.Log.Infof("Triggers will be created on the ghost table during cut-over: %+v", this.migrationContext.OriginalTableTriggers)
+	return nil
+}
+
+// estimateTableRowsViaExplain estimates number of rows on original table
+func (this *Inspector) estimateTableRowsViaExplain() error {
+	rowFilter := "1=1"
+	if this.migrationContext.RowFilter != "" {
+		rowFilter = this.migrationContext.RowFilter
+	}
+	query := fmt.Sprintf(`explain select /* gh-ost */ * from %s.%s where %s`, sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName), rowFilter)
+
+	outputFound := false
+	err := sqlutils.QueryRowsMap(this.db, query, func(rowMap sqlutils.RowMap) error {
+		this.migrationContext.RowsEstimate = rowMap.GetInt64("rows")
+		this.migrationContext.UsedRowsEstimateMethod = base.ExplainRowsEstimate
+		outputFound = true
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !outputFound {
+		return this.migrationContext.Log.Errorf("Cannot run EXPLAIN on %s.%s!", sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName))
+	}
+	this.migrationContext.Log.Infof("Estimated number of rows via EXPLAIN: %d", this.migrationContext.RowsEstimate)
+	return nil
+}
+
+// Kill kills a query for connectionID.
+// - @amason: this should go somewhere _other_ than `logic`, but I couldn't decide
+// between `base`, `sql`, or `mysql`.
+func Kill(db *gosql.DB, connectionID string) error {
+	_, err := db.Exec(`KILL QUERY %s`, connectionID)
+	return err
+}
+
+// CountTableRows counts exact number of rows on the original table
+func (this *Inspector) CountTableRows(ctx context.Context) error {
+	atomic.StoreInt64(&this.migrationContext.CountingRowsFlag, 1)
+	defer atomic.StoreInt64(&this.migrationContext.CountingRowsFlag, 0)
+
+	this.migrationContext.Log.Infof("As instructed, I'm issuing a SELECT COUNT(*) on the table. This may take a while")
+
+	conn, err := this.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var connectionID string
+	if err := conn.QueryRowContext(ctx, `SELECT /* gh-ost */ CONNECTION_ID()`).Scan(&connectionID); err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(`select /* gh-ost */ count(*) as count_rows from %s.%s`, sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName))
+	if this.migrationContext.RowFilter != "" {
+		// Only rows matching the filter are migrated
+		query = fmt.Sprintf(`%s where (%s)`, query, this.migrationContext.RowFilter)
+	}
+	var rowsEstimate int64
+	if err := conn.QueryRowContext(ctx, query).Scan(&rowsEstimate); err != nil {
 		switch err {
 		case context.Canceled, context.DeadlineExceeded:
 			this.migrationContext.Log.Infof("exact row count cancelled (%s), likely because I'm about to cut over. I'm going to kill that query.", ctx.Err())
@@ -605,6 +1432,7 @@ func (this *Inspector) applyColumnTypes(databaseName, tableName string, columnsL
 				continue
 			}
 
+			column.MySQLType = columnType
 			if strings.Contains(columnType, "unsigned") {
 				column.IsUnsigned = true
 			}
@@ -627,6 +1455,14 @@ func (this *Inspector) applyColumnTypes(databaseName, tableName string, columnsL
 				column.Type = sql.EnumColumnType
 				column.EnumValues = sql.ParseEnumValues(m.GetString("COLUMN_TYPE"))
 			}
+			if strings.HasPrefix(columnType, "set") {
+				column.Type = sql.SetColumnType
+				column.EnumValues = sql.ParseEnumValues(m.GetString("COLUMN_TYPE"))
+			}
+			if strings.HasPrefix(columnType, "bit") {
+				column.Type = sql.BitColumnType
+				column.BitLength = m.GetUint("NUMERIC_PRECISION")
+			}
 			if strings.HasPrefix(columnType, "binary") {
 				column.Type = sql.BinaryColumnType
 				column.BinaryOctetLength = columnOctetLength
@@ -634,12 +1470,48 @@ func (this *Inspector) applyColumnTypes(databaseName, tableName string, columnsL
 			if charset := m.GetString("CHARACTER_SET_NAME"); charset != "" {
 				column.Charset = charset
 			}
+			column.Generated = sql.ParseGeneratedColumnType(m.GetString("EXTRA"))
+			column.Nullable = m.GetString("IS_NULLABLE") == "YES"
+			// Invisible columns are listed, in their ordinal position, as they are found in binlog row images
+			column.Invisible = strings.Contains(strings.ToUpper(m.GetString("EXTRA")), "INVISIBLE")
+			switch m.GetString("DATA_TYPE") {
+			case "binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob":
+				column.Charset = "binary"
+			case "geometry", "point", "linestring", "polygon", "multipoint", "multilinestring", "multipolygon", "geometrycollection", "geomcollection":
+				column.Type = sql.GeometryColumnType
+				// SRS_ID is only found as of MySQL 8.0, and is NULL for columns not restricted to an SRID
+				if srid := m.GetNullInt64("SRS_ID"); srid.Valid {
+					column.HasSRID = true
+					column.SRID = uint32(srid.Int64)
+				}
+			}
 		}
 		return nil
 	}, databaseName, tableName)
 	return err
 }
 
+// hasGeneratedInvisiblePrimaryKey checks whether given table's primary key is a generated invisible
+// primary key (MySQL 8.0.30+)
+func (this *Inspector) hasGeneratedInvisiblePrimaryKey(tableName string) (hasGIPK bool, err error) {
+	query := `
+		SELECT
+			COUNT(*) > 0 AS has_gipk
+		FROM INFORMATION_SCHEMA.COLUMNS
+		WHERE
+			TABLE_SCHEMA = ?
+			AND TABLE_NAME = ?
+			AND COLUMN_NAME = ?
+			AND COLUMN_KEY = 'PRI'
+			AND EXTRA LIKE '%INVISIBLE%'
+	`
+	err = sqlutils.QueryRowsMap(this.db, query, func(m sqlutils.RowMap) error {
+		hasGIPK = m.GetBool("has_gipk")
+		return nil
+	}, this.migrationContext.DatabaseName, tableName, mysql.GeneratedInvisiblePrimaryKeyColumnName)
+	return hasGIPK, err
+}
+
 // getAutoIncrementValue get's the original table's AUTO_INCREMENT value, if exists (0 value if not exists)
 func (this *Inspector) getAutoIncrementValue(tableName string) (autoIncrement uint64, err error) {
 	query := `
@@ -660,7 +1532,7 @@ func (this *Inspector) getAutoIncrementValue(tableName string) (autoIncrement ui
 
 // getCandidateUniqueKeys investigates a table and returns the list of unique keys
 // candidate for chunking
-func (this *Inspector) getCandidateUniqueKeys(tableName string) (uniqueKeys [](*sql.UniqueKey), err error) {
+func (this *Inspector) getCandidateUniqueKeys(databaseName, tableName string) (uniqueKeys [](*sql.UniqueKey), err error) {
 	query := `
     SELECT
       COLUMNS.TABLE_SCHEMA,
@@ -668,6 +1540,7 @@ func (this *Inspector) getCandidateUniqueKeys(tableName string) (uniqueKeys [](*
       COLUMNS.COLUMN_NAME,
       UNIQUES.INDEX_NAME,
       UNIQUES.COLUMN_NAMES,
+      UNIQUES.DESCENDING_COLUMN_NAMES,
       UNIQUES.COUNT_COLUMN_IN_INDEX,
       COLUMNS.DATA_TYPE,
       COLUMNS.CHARACTER_SET_NAME,
@@ -680,6 +1553,7 @@ func (this *Inspector) getCandidateUniqueKeys(tableName string) (uniqueKeys [](*
         INDEX_NAME,
         COUNT(*) AS COUNT_COLUMN_IN_INDEX,
         GROUP_CONCAT(COLUMN_NAME ORDER BY SEQ_IN_INDEX ASC) AS COLUMN_NAMES,
+        GROUP_CONCAT(IF(COLLATION = 'D', COLUMN_NAME, NULL) ORDER BY SEQ_IN_INDEX ASC) AS DESCENDING_COLUMN_NAMES,
         SUBSTRING_INDEX(GROUP_CONCAT(COLUMN_NAME ORDER BY SEQ_IN_INDEX ASC), ',', 1) AS FIRST_COLUMN_NAME,
         SUM(NULLABLE='YES') > 0 AS has_nullable
       FROM INFORMATION_SCHEMA.STATISTICS
@@ -725,24 +1599,504 @@ func (this *Inspector) getCandidateUniqueKeys(tableName string) (uniqueKeys [](*
 			HasNullable:     m.GetBool("has_nullable"),
 			IsAutoIncrement: m.GetBool("is_auto_increment"),
 		}
+		// DESC key parts (MySQL 8.0+) have 'D' collation; prior versions parse yet ignore DESC
+		if descendingColumnNames := m.GetString("DESCENDING_COLUMN_NAMES"); descendingColumnNames != "" {
+			for _, columnName := range sql.ParseColumnList(descendingColumnNames).Names() {
+				uniqueKey.Columns.SetDescending(columnName)
+			}
+		}
 		uniqueKeys = append(uniqueKeys, uniqueKey)
 		return nil
-	}, this.migrationContext.DatabaseName, tableName, this.migrationContext.DatabaseName, tableName)
+	}, databaseName, tableName, databaseName, tableName)
 	if err != nil {
 		return uniqueKeys, err
 	}
+	// Functional key parts have no column name: the above lists a key's column parts only, and does not
+	// list keys made of functional key parts only
+	expressions, expressionPositions, expressionKeyNames, err := this.getUniqueKeyExpressions(databaseName, tableName)
+	if err != nil {
+		return uniqueKeys, err
+	}
+	for _, keyName := range expressionKeyNames {
+		var uniqueKey *sql.UniqueKey
+		for _, candidate := range uniqueKeys {
+			if candidate.Name == keyName {
+				uniqueKey = candidate
+			}
+		}
+		if uniqueKey == nil {
+			uniqueKey = &sql.UniqueKey{Name: keyName, Columns: *sql.NewColumnList([]string{})}
+			uniqueKeys = append(uniqueKeys, uniqueKey)
+		}
+		uniqueKey.Expressions = expressions[keyName]
+		uniqueKey.ExpressionPositions = expressionPositions[keyName]
+	}
 	this.migrationContext.Log.Debugf("Potential unique keys in %+v: %+v", tableName, uniqueKeys)
 	return uniqueKeys, nil
 }
 
+// getUniqueKeyExpressions reads the functional key parts of given table's unique keys (MySQL 8.0.13+), and their
+// positions among the key parts, by key name, along with the names of these keys in order. Servers lacking
+// functional key parts have none.
+func (this *Inspector) getUniqueKeyExpressions(databaseName, tableName string) (expressions map[string][]string, positions map[string][]int, keyNames []string, err error) {
+	query := `
+		SELECT
+			INDEX_NAME,
+			SEQ_IN_INDEX,
+			EXPRESSION
+		FROM INFORMATION_SCHEMA.STATISTICS
+		WHERE
+			NON_UNIQUE=0
+			AND TABLE_SCHEMA=?
+			AND TABLE_NAME=?
+			AND EXPRESSION IS NOT NULL
+		ORDER BY INDEX_NAME, SEQ_IN_INDEX
+	`
+	expressions = map[string][]string{}
+	positions = map[string][]int{}
+	err = sqlutils.QueryRowsMap(this.db, query, func(m sqlutils.RowMap) error {
+		keyName := m.GetString("INDEX_NAME")
+		if _, ok := expressions[keyName]; !ok {
+			keyNames = append(keyNames, keyName)
+		}
+		expressions[keyName] = append(expressions[keyName], m.GetString("EXPRESSION"))
+		positions[keyName] = append(positions[keyName], m.GetInt("SEQ_IN_INDEX"))
+		return nil
+	}, databaseName, tableName)
+	if mysql.IsUnknownTableOrColumnError(err) {
+		return expressions, positions, nil, nil
+	}
+	return expressions, positions, keyNames, err
+}
+
+// getUnusableUniqueKeyColumnType returns the data type, if any, for which given unique key cannot be
+// iterated by row copy. Unique key column types are expected to have been applied.
+func getUnusableUniqueKeyColumnType(uniqueKey *sql.UniqueKey) string {
+	for _, column := range uniqueKey.Columns.Columns() {
+		switch column.Type {
+		case sql.FloatColumnType:
+			return "FLOAT"
+		case sql.JSONColumnType:
+			// Noteworthy that at this time MySQL does not allow JSON indexing anyhow, but this code
+			// will remain in place to potentially handle the future case where JSON is supported in indexes.
+			return "JSON"
+		}
+	}
+	return ""
+}
+
+// getSurrogateKeyColumnDataType returns the column's data type, e.g. "varchar" for "varchar(32)"
+func getSurrogateKeyColumnDataType(column *sql.Column) string {
+	dataType := strings.SplitN(column.MySQLType, "(", 2)[0]
+	return strings.ToLower(strings.SplitN(dataType, " ", 2)[0])
+}
+
+// getSurrogateKeyUnmatchableColumnType returns the data type of given column in case its values cannot
+// reliably identify a row, or an empty string otherwise
+func getSurrogateKeyUnmatchableColumnType(column *sql.Column) string {
+	switch getSurrogateKeyColumnDataType(column) {
+	case "float", "double", "real", "json":
+		return strings.ToUpper(getSurrogateKeyColumnDataType(column))
+	}
+	return ""
+}
+
+// isSurrogateKeyIterableColumn tells whether row copy can iterate by given column, ranging over its values
+// in the same order MySQL sorts them
+func isSurrogateKeyIterableColumn(column *sql.Column) bool {
+	if column.Nullable || column.IsGenerated() {
+		return false
+	}
+	switch getSurrogateKeyColumnDataType(column) {
+	case "tinytext", "text", "mediumtext", "longtext", "tinyblob", "blob", "mediumblob", "longblob",
+		"enum", "set", "bit",
+		"geometry", "point", "linestring", "polygon", "multipoint", "multilinestring", "multipolygon", "geometrycollection", "geomcollection":
+		return false
+	}
+	return true
+}
+
+// getSurrogateUniqueKey returns the key by which row copy iterates a table lacking a shared unique key,
+// per --surrogate-key. It is made of the shared NOT NULL columns of orderable types. Being non-unique,
+// it only serves to split the table into chunks; rows are matched by all shared columns.
+func (this *Inspector) getSurrogateUniqueKey() (*sql.UniqueKey, error) {
+	if this.migrationContext.OriginalBinlogRowImage != "FULL" {
+		return nil, fmt.Errorf("--surrogate-key matches rows by all their columns, hence requires binlog_row_image=FULL, but %s has '%s'", this.connectionConfig.Key.String(), this.migrationContext.OriginalBinlogRowImage)
+	}
+	surrogateKeyColumnName := this.migrationContext.SurrogateKeyColumnName
+	if this.migrationContext.OriginalTableColumns.GetColumn(surrogateKeyColumnName) != nil || this.migrationContext.GhostTableColumns.GetColumn(surrogateKeyColumnName) != nil {
+		return nil, fmt.Errorf("--surrogate-key: column %s already exists. Choose another name via --surrogate-key-column", sql.EscapeName(surrogateKeyColumnName))
+	}
+
+	sharedColumns, mappedSharedColumns := this.getSharedColumns(this.migrationContext.OriginalTableColumns, this.migrationContext.GhostTableColumns, this.migrationContext.ColumnRenameMap)
+	if err := this.applyColumnTypes(this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName, sharedColumns); err != nil {
+		return nil, err
+	}
+	if err := this.applyColumnTypes(this.migrationContext.GetTargetDatabaseName(), this.migrationContext.GetGhostTableName(), mappedSharedColumns); err != nil {
+		return nil, err
+	}
+	var keyColumnNames, matchColumnNames []string
+	for i := range sharedColumns.Columns() {
+		column := &sharedColumns.Columns()[i]
+		mappedColumn := &mappedSharedColumns.Columns()[i]
+		for _, c := range []*sql.Column{column, mappedColumn} {
+			if unmatchableType := getSurrogateKeyUnmatchableColumnType(c); unmatchableType != "" {
+				return nil, fmt.Errorf("--surrogate-key matches rows by all their columns, and cannot match by %s column %s. Bailing out", unmatchableType, sql.EscapeName(c.Name))
+			}
+		}
+		if column.IsGenerated() {
+			continue
+		}
+		matchColumnNames = append(matchColumnNames, column.Name)
+		if isSurrogateKeyIterableColumn(column) && isSurrogateKeyIterableColumn(mappedColumn) && column.MySQLType == mappedColumn.MySQLType {
+			keyColumnNames = append(keyColumnNames, column.Name)
+		}
+	}
+	if len(keyColumnNames) == 0 {
+		return nil, fmt.Errorf("--surrogate-key: no shared NOT NULL column found to iterate row copy by. Bailing out")
+	}
+	if err := this.validateSurrogateKeyDuplicates(matchColumnNames); err != nil {
+		return nil, err
+	}
+	uniqueKey := &sql.UniqueKey{Columns: *sql.NewColumnList(keyColumnNames)}
+	if err := this.applyColumnTypes(this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName, &uniqueKey.Columns); err != nil {
+		return nil, err
+	}
+	return uniqueKey, nil
+}
+
+// validateSurrogateKeyDuplicates looks for identical rows, which --surrogate-key cannot tell apart
+func (this *Inspector) validateSurrogateKeyDuplicates(matchColumnNames []string) error {
+	escapedColumnNames := make([]string, len(matchColumnNames))
+	for i, columnName := range matchColumnNames {
+		escapedColumnNames[i] = sql.EscapeName(columnName)
+	}
+	query := fmt.Sprintf(`select /* gh-ost */ 1 from %s.%s group by %s having count(*) > 1 limit 1`,
+		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(this.migrationContext.OriginalTableName),
+		strings.Join(escapedColumnNames, ", "),
+	)
+	var hasDuplicates int
+	err := this.db.QueryRow(query).Scan(&hasDuplicates)
+	if err == gosql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !this.migrationContext.ImSureAboutDuplicates {
+		return fmt.Errorf("--surrogate-key: %s.%s has duplicate identical rows, which cannot be told apart when applying binlog events. Bailing out. To proceed, provide --im-sure-about-duplicates", sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName))
+	}
+	this.migrationContext.Log.Warningf("--surrogate-key: %s.%s has duplicate identical rows. You have supplied with --im-sure-about-duplicates and so this migration proceeds; changes to identical rows may not apply faithfully onto the migrated table", sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName))
+	return nil
+}
+
+// collationRiskSampleSize is the maximum number of sample values collation risk analysis reads per unique key
+// or column
+const collationRiskSampleSize = 10
+
+// characterColumn is a character column's charset, collation and maximum lengths, per information_schema
+type characterColumn struct {
+	Charset     string
+	Collation   string
+	MaxLength   int64
+	OctetLength int64
+}
+
+// getCharacterColumns reads given table's character columns, by name. Binary columns have no charset and
+// are not listed.
+func (this *Inspector) getCharacterColumns(databaseName, tableName string) (columns map[string]*characterColumn, err error) {
+	query := `
+		select
+				COLUMN_NAME,
+				CHARACTER_SET_NAME,
+				COLLATION_NAME,
+				CHARACTER_MAXIMUM_LENGTH,
+				CHARACTER_OCTET_LENGTH
+			from
+				information_schema.columns
+			where
+				table_schema=?
+				and table_name=?
+				and CHARACTER_SET_NAME is not null
+	`
+	columns = map[string]*characterColumn{}
+	err = sqlutils.QueryRowsMap(this.db, query, func(m sqlutils.RowMap) error {
+		columns[m.GetString("COLUMN_NAME")] = &characterColumn{
+			Charset:     m.GetString("CHARACTER_SET_NAME"),
+			Collation:   m.GetString("COLLATION_NAME"),
+			MaxLength:   m.GetInt64("CHARACTER_MAXIMUM_LENGTH"),
+			OctetLength: m.GetInt64("CHARACTER_OCTET_LENGTH"),
+		}
+		return nil
+	}, databaseName, tableName)
+	return columns, err
+}
+
+// isNarrowing tells whether values of this column may not fit into given column: its charset differs, such
+// that characters may not be representable, or its maximum length, in characters or bytes, is shorter
+func (this *characterColumn) isNarrowing(target *characterColumn) bool {
+	return this.Charset != target.Charset || target.MaxLength < this.MaxLength || target.OctetLength < this.OctetLength
+}
+
+// buildCollationTargetValue returns the value of given original table column as converted onto given ghost table
+// column, in the ghost column's charset and collation
+func buildCollationTargetValue(columnName string, target *characterColumn) string {
+	return fmt.Sprintf("convert(%s using %s) collate %s", sql.EscapeName(columnName), target.Charset, target.Collation)
+}
+
+// buildRowFilterCondition returns the --row-filter condition, if any, to AND with a WHERE clause
+func buildRowFilterCondition(rowFilter string) string {
+	if rowFilter == "" {
+		return ""
+	}
+	return fmt.Sprintf(" and (%s)", rowFilter)
+}
+
+// buildCollationCollisionsQueries returns queries reading the values of given original table's rows which collide
+// in a ghost table unique key: sample values, up to given limit, along with their number of rows, and the overall
+// number of colliding values. keyValues are the key's columns as converted onto the ghost table. Rows with NULLs
+// in the key never collide.
+func buildCollationCollisionsQueries(databaseName, tableName string, keyValues []string, keyColumnNames []string, rowFilter string, limit int) (samplesQuery string, countQuery string) {
+	notNullConditions := make([]string, len(keyColumnNames))
+	for i, columnName := range keyColumnNames {
+		notNullConditions[i] = fmt.Sprintf("%s is not null", sql.EscapeName(columnName))
+	}
+	fromClause := fmt.Sprintf(`from %s.%s where %s%s group by %s having count(*) > 1`,
+		sql.EscapeName(databaseName), sql.EscapeName(tableName),
+		strings.Join(notNullConditions, " and "), buildRowFilterCondition(rowFilter),
+		strings.Join(keyValues, ", "),
+	)
+	samplesQuery = fmt.Sprintf(`select /* gh-ost */ %s, count(*) %s limit %d`, strings.Join(keyValues, ", "), fromClause, limit)
+	countQuery = fmt.Sprintf(`select /* gh-ost */ count(*) from (select 1 %s) collisions`, fromClause)
+	return samplesQuery, countQuery
+}
+
+// buildNarrowedValuesQueries returns queries reading the values of given original table column which do not fit
+// into given ghost table column: sample values, up to given limit and cut to 64 characters, and the overall number
+// of such values. A value does not fit if it is longer than the ghost column, or does not convert back unchanged
+// from the ghost column's charset.
+func buildNarrowedValuesQueries(databaseName, tableName string, columnName string, column, target *characterColumn, rowFilter string, limit int) (samplesQuery string, countQuery string) {
+	escapedColumnName := sql.EscapeName(columnName)
+	targetValue := fmt.Sprintf("convert(%s using %s)", escapedColumnName, target.Charset)
+	conditions := []string{
+		fmt.Sprintf("char_length(%s) > %d", escapedColumnName, target.MaxLength),
+		fmt.Sprintf("length(%s) > %d", targetValue, target.OctetLength),
+	}
+	if column.Charset != target.Charset {
+		conditions = append(conditions, fmt.Sprintf("cast(convert(%s using %s) as binary) != cast(%s as binary)", targetValue, column.Charset, escapedColumnName))
+	}
+	whereClause := fmt.Sprintf(`where %s is not null and (%s)%s`, escapedColumnName, strings.Join(conditions, " or "), buildRowFilterCondition(rowFilter))
+	samplesQuery = fmt.Sprintf(`select /* gh-ost */ left(%s, 64) from %s.%s %s limit %d`,
+		escapedColumnName, sql.EscapeName(databaseName), sql.EscapeName(tableName), whereClause, limit,
+	)
+	countQuery = fmt.Sprintf(`select /* gh-ost */ count(*) from %s.%s %s`, sql.EscapeName(databaseName), sql.EscapeName(tableName), whereClause)
+	return samplesQuery, countQuery
+}
+
+// readCollationRiskSamples runs given samples query, and, should samples reach given limit, given count query.
+// It returns the number of risky values along with formatted samples.
+func (this *Inspector) readCollationRiskSamples(samplesQuery, countQuery string, limit int) (count int64, samples []string, err error) {
+	rows, err := this.db.Query(samplesQuery)
+	if err != nil {
+		return count, samples, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return count, samples, err
+	}
+	for rows.Next() {
+		values := make([]gosql.NullString, len(columns))
+		scanArgs := make([]interface{}, len(columns))
+		for i := range values {
+			scanArgs[i] = &values[i]
+		}
+		if err := rows.Scan(scanArgs...); err != nil {
+			return count, samples, err
+		}
+		sample := make([]string, len(values))
+		for i, value := range values {
+			sample[i] = fmt.Sprintf("%q", value.String)
+		}
+		samples = append(samples, fmt.Sprintf("(%s)", strings.Join(sample, ", ")))
+	}
+	if err := rows.Err(); err != nil {
+		return count, samples, err
+	}
+	count = int64(len(samples))
+	if len(samples) >= limit {
+		if err := this.db.QueryRow(countQuery).Scan(&count); err != nil {
+			return count, samples, err
+		}
+	}
+	return count, samples, nil
+}
+
+// validateCollationRisks analyzes the original table, ahead of row copy, for values which the ALTER's charset,
+// collation or length changes to character columns make collide in a ghost table unique key, or not fit into
+// their ghost column. Such values fail row copy hours into the migration, or are silently mangled. It bails
+// out on any, unless --approve-collation-risks. Each (full scan) query first waits on given throttle function.
+func (this *Inspector) validateCollationRisks(throttle func()) error {
+	originalColumns, err := this.getCharacterColumns(this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName)
+	if err != nil {
+		return err
+	}
+	ghostColumns, err := this.getCharacterColumns(this.migrationContext.GetTargetDatabaseName(), this.migrationContext.GetGhostTableName())
+	if err != nil {
+		return err
+	}
+	// ghost column name => original column name, of shared columns
+	originalColumnNames := map[string]string{}
+	for i, column := range this.migrationContext.SharedColumns.Columns() {
+		originalColumnNames[this.migrationContext.MappedSharedColumns.Columns()[i].Name] = column.Name
+	}
+	risks := 0
+	reportRisk := func(description string, count int64, samples []string) {
+		risks++
+		this.migrationContext.Log.Warningf("%s: %d values; samples: %s", description, count, strings.Join(samples, ", "))
+	}
+
+	for _, uniqueKey := range this.migrationContext.GhostTableUniqueKeys {
+		if uniqueKey.IsFunctional() {
+			continue
+		}
+		keyValues := []string{}
+		keyColumnNames := []string{}
+		changedColumnNames := []string{}
+		for _, ghostColumnName := range uniqueKey.Columns.Names() {
+			columnName, ok := originalColumnNames[ghostColumnName]
+			if !ok {
+				// A new column: its values are not found on the original table
+				keyValues = nil
+				break
+			}
+			keyColumnNames = append(keyColumnNames, columnName)
+			column, ghostColumn := originalColumns[columnName], ghostColumns[ghostColumnName]
+			if column != nil && ghostColumn != nil && column.Collation != ghostColumn.Collation {
+				keyValues = append(keyValues, buildCollationTargetValue(columnName, ghostColumn))
+				changedColumnNames = append(changedColumnNames, sql.EscapeName(columnName))
+			} else {
+				keyValues = append(keyValues, sql.EscapeName(columnName))
+			}
+		}
+		if len(keyValues) == 0 || len(changedColumnNames) == 0 {
+			continue
+		}
+		this.migrationContext.Log.Infof("Analyzing %s.%s for values colliding in unique key %s, due to collation changes to %s", sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName), sql.EscapeName(uniqueKey.Name), strings.Join(changedColumnNames, ", "))
+		throttle()
+		samplesQuery, countQuery := buildCollationCollisionsQueries(this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName, keyValues, keyColumnNames, this.migrationContext.RowFilter, collationRiskSampleSize)
+		count, samples, err := this.readCollationRiskSamples(samplesQuery, countQuery, collationRiskSampleSize)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			reportRisk(fmt.Sprintf("Unique key %s would have duplicate entries on the ghost table; samples are (%s, rows)", sql.EscapeName(uniqueKey.Name), strings.Join(keyColumnNames, ", ")), count, samples)
+		}
+	}
+
+	for i, sharedColumn := range this.migrationContext.SharedColumns.Columns() {
+		ghostColumnName := this.migrationContext.MappedSharedColumns.Columns()[i].Name
+		column, ghostColumn := originalColumns[sharedColumn.Name], ghostColumns[ghostColumnName]
+		if column == nil || ghostColumn == nil || !column.isNarrowing(ghostColumn) {
+			continue
+		}
+		this.migrationContext.Log.Infof("Analyzing %s.%s for values not fitting into %s %s(%d)", sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName), sql.EscapeName(ghostColumnName), ghostColumn.Charset, ghostColumn.MaxLength)
+		throttle()
+		samplesQuery, countQuery := buildNarrowedValuesQueries(this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName, sharedColumn.Name, column, ghostColumn, this.migrationContext.RowFilter, collationRiskSampleSize)
+		count, samples, err := this.readCollationRiskSamples(samplesQuery, countQuery, collationRiskSampleSize)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			reportRisk(fmt.Sprintf("Column %s has values which would be truncated or mangled on the ghost table (%s to %s, %d characters, %d bytes)", sql.EscapeName(sharedColumn.Name), column.Charset, ghostColumn.Charset, ghostColumn.MaxLength, ghostColumn.OctetLength), count, samples)
+		}
+	}
+
+	if risks == 0 {
+		return nil
+	}
+	if !this.migrationContext.ApproveCollationRisks {
+		return fmt.Errorf("Found %d unique keys or columns at risk due to charset, collation or length changes; see above. Bailing out. To proceed, provide --approve-collation-risks", risks)
+	}
+	this.migrationContext.Log.Warningf("Found %d unique keys or columns at risk due to charset, collation or length changes. You have supplied with --approve-collation-risks and so this migration proceeds; row copy may fail on duplicate entries, or truncate values", risks)
+	return nil
+}
+
+// getChunkIndexUniqueKey returns the shared unique key named by --chunk-index, or an error explaining
+// why that index cannot be used for row copy
+func (this *Inspector) getChunkIndexUniqueKey(sharedUniqueKeys [](*sql.UniqueKey)) (*sql.UniqueKey, error) {
+	chunkIndex := this.migrationContext.ChunkIndex
+	var originalUniqueKey *sql.UniqueKey
+	for _, uniqueKey := range this.migrationContext.OriginalTableUniqueKeys {
+		if strings.EqualFold(uniqueKey.Name, chunkIndex) {
+			originalUniqueKey = uniqueKey
+		}
+	}
+	if originalUniqueKey == nil {
+		indexExists, err := this.indexExists(this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName, chunkIndex)
+		if err != nil {
+			return nil, err
+		}
+		if indexExists {
+			return nil, fmt.Errorf("--chunk-index: %s is not a unique key on %s.%s. Row copy iterates a unique key", sql.EscapeName(chunkIndex), sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName))
+		}
+		return nil, fmt.Errorf("--chunk-index: no index named %s on %s.%s", sql.EscapeName(chunkIndex), sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName))
+	}
+	if originalUniqueKey.IsFunctional() {
+		return nil, fmt.Errorf("--chunk-index: %s has functional key parts (%s), which row copy cannot iterate", sql.EscapeName(chunkIndex), strings.Join(originalUniqueKey.Expressions, ", "))
+	}
+	var chunkIndexUniqueKey *sql.UniqueKey
+	for _, uniqueKey := range sharedUniqueKeys {
+		if uniqueKey == originalUniqueKey {
+			chunkIndexUniqueKey = uniqueKey
+		}
+	}
+	if chunkIndexUniqueKey == nil {
+		return nil, fmt.Errorf("--chunk-index: %s is not shared by the ghost table: no unique key on the ghost table has its columns (%s). The ALTER drops or changes it", sql.EscapeName(chunkIndex), originalUniqueKey.Columns.String())
+	}
+	this.applyColumnTypes(this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName, &chunkIndexUniqueKey.Columns)
+	if unusableType := getUnusableUniqueKeyColumnType(chunkIndexUniqueKey); unusableType != "" {
+		return nil, fmt.Errorf("--chunk-index: %s cannot be iterated by row copy due to %s data type", sql.EscapeName(chunkIndex), unusableType)
+	}
+	return chunkIndexUniqueKey, nil
+}
+
+// indexExists checks whether given table has an index by given name, unique or not
+func (this *Inspector) indexExists(databaseName, tableName, indexName string) (exists bool, err error) {
+	query := `
+		select
+				count(*) > 0
+			from
+				information_schema.statistics
+			where
+				table_schema=?
+				and table_name=?
+				and index_name=?
+	`
+	err = this.db.QueryRow(query, databaseName, tableName, indexName).Scan(&exists)
+	return exists, err
+}
+
+// getFunctionalUniqueKeyNames returns the names of given unique keys which have functional key parts
+func getFunctionalUniqueKeyNames(uniqueKeys [](*sql.UniqueKey)) (names []string) {
+	for _, uniqueKey := range uniqueKeys {
+		if uniqueKey.IsFunctional() {
+			names = append(names, sql.EscapeName(uniqueKey.Name))
+		}
+	}
+	return names
+}
+
 // getSharedUniqueKeys returns the intersection of two given unique keys,
-// testing by list of columns
+// testing by list of columns, key part directions and functional key parts. A key whose key part
+// direction changes, e.g. from ASC to DESC, is not shared: its index order differs between the tables.
 func (this *Inspector) getSharedUniqueKeys(originalUniqueKeys, ghostUniqueKeys [](*sql.UniqueKey)) (uniqueKeys [](*sql.UniqueKey), err error) {
 	// We actually do NOT rely on key name, just on the set of columns. This is because maybe
 	// the ALTER is on the name itself...
 	for _, originalUniqueKey := range originalUniqueKeys {
 		for _, ghostUniqueKey := range ghostUniqueKeys {
-			if originalUniqueKey.Columns.EqualsByNames(&ghostUniqueKey.Columns) {
+			if originalUniqueKey.EqualsByParts(ghostUniqueKey) {
 				uniqueKeys = append(uniqueKeys, originalUniqueKey)
 			}
 		}
@@ -750,8 +2104,89 @@ func (this *Inspector) getSharedUniqueKeys(originalUniqueKeys, ghostUniqueKeys [
 	return uniqueKeys, nil
 }
 
-// getSharedColumns returns the intersection of two lists of columns in same order as the first list
-func (this *Inspector) getSharedColumns(originalColumns, ghostColumns *sql.ColumnList, originalVirtualColumns, ghostVirtualColumns *sql.ColumnList, columnRenameMap map[string]string) (*sql.ColumnList, *sql.ColumnList) {
+// validateColumnVisibility makes sure the ghost table keeps the visibility of shared columns (MySQL 8.0.23+),
+// unless the ALTER explicitly states it: MODIFY and CHANGE COLUMN make an invisible column visible, unless
+// restating INVISIBLE
+func (this *Inspector) validateColumnVisibility() error {
+	for i, column := range this.migrationContext.SharedColumns.Columns() {
+		mappedColumn := this.migrationContext.MappedSharedColumns.Columns()[i]
+		if column.Invisible == mappedColumn.Invisible {
+			continue
+		}
+		visibility := func(invisible bool) string {
+			if invisible {
+				return "invisible"
+			}
+			return "visible"
+		}
+		statedInvisible, stated := false, false
+		for columnName, invisible := range this.migrationContext.ColumnVisibilityMap {
+			if strings.EqualFold(columnName, mappedColumn.Name) {
+				statedInvisible, stated = invisible, true
+			}
+		}
+		if !stated || statedInvisible != mappedColumn.Invisible {
+			return fmt.Errorf("Column %s is %s on the original table, and %s on the ghost table, though the ALTER does not state so. MODIFY and CHANGE COLUMN make a column visible unless stating INVISIBLE. State %s on the column in the ALTER. Bailing out",
+				sql.EscapeName(column.Name), visibility(column.Invisible), visibility(mappedColumn.Invisible), strings.ToUpper(visibility(column.Invisible)))
+		}
+		this.migrationContext.Log.Infof("Column %s is made %s, per the ALTER", sql.EscapeName(mappedColumn.Name), visibility(mappedColumn.Invisible))
+	}
+	return nil
+}
+
+// validateExplicitColumnRenames validates the --rename-column renames against the original and ghost tables:
+// the renamed column must exist on the original table, and the column it is renamed onto on the ghost table,
+// where no other original column maps onto it. Renames are then keyed by the columns' actual names.
+func (this *Inspector) validateExplicitColumnRenames() error {
+	findColumnName := func(columns *sql.ColumnList, name string) string {
+		for _, columnName := range columns.Names() {
+			if strings.EqualFold(columnName, name) {
+				return columnName
+			}
+		}
+		return ""
+	}
+	for oldName, newName := range this.migrationContext.ExplicitColumnRenameMap {
+		originalColumnName := findColumnName(this.migrationContext.OriginalTableColumns, oldName)
+		if originalColumnName == "" {
+			return fmt.Errorf("--rename-column %s:%s: column %s is not found on the original table", oldName, newName, sql.EscapeName(oldName))
+		}
+		ghostColumnName := findColumnName(this.migrationContext.GhostTableColumns, newName)
+		if ghostColumnName == "" {
+			return fmt.Errorf("--rename-column %s:%s: column %s is not found on the ghost table", oldName, newName, sql.EscapeName(newName))
+		}
+		for droppedColumn := range this.migrationContext.DroppedColumnsMap {
+			if strings.EqualFold(droppedColumn, oldName) {
+				return fmt.Errorf("--rename-column %s:%s: column %s is dropped by the ALTER statement", oldName, newName, sql.EscapeName(oldName))
+			}
+		}
+		delete(this.migrationContext.ColumnRenameMap, oldName)
+		this.migrationContext.ColumnRenameMap[originalColumnName] = ghostColumnName
+	}
+	for oldName, newName := range this.migrationContext.ExplicitColumnRenameMap {
+		// An original column named as the rename's target, and neither renamed nor dropped, maps onto that same ghost column
+		sameNamedColumn := findColumnName(this.migrationContext.OriginalTableColumns, newName)
+		if sameNamedColumn == "" {
+			continue
+		}
+		if _, isRenamed := this.migrationContext.ColumnRenameMap[sameNamedColumn]; isRenamed {
+			continue
+		}
+		isDropped := false
+		for droppedColumn := range this.migrationContext.DroppedColumnsMap {
+			isDropped = isDropped || strings.EqualFold(droppedColumn, sameNamedColumn)
+		}
+		if !isDropped {
+			return fmt.Errorf("Conflicting column renames: both %s and %s map onto ghost column %s. Consider --rename-column for %s as well", sql.EscapeName(oldName), sql.EscapeName(sameNamedColumn), sql.EscapeName(newName), sql.EscapeName(sameNamedColumn))
+		}
+	}
+	return nil
+}
+
+// getSharedColumns returns the intersection of two lists of columns in same order as the first list.
+// Shared columns include generated columns, which may take part in the unique key; columns generated
+// on the ghost table are never written onto.
+func (this *Inspector) getSharedColumns(originalColumns, ghostColumns *sql.ColumnList, columnRenameMap map[string]string) (*sql.ColumnList, *sql.ColumnList) {
 	sharedColumnNames := []string{}
 	for _, originalColumn := range originalColumns.Names() {
 		isSharedColumn := false
@@ -771,16 +2206,6 @@ func (this *Inspector) getSharedColumns(originalColumns, ghostColumns *sql.Colum
 				break
 			}
 		}
-		for _, virtualColumn := range originalVirtualColumns.Names() {
-			if strings.EqualFold(originalColumn, virtualColumn) {
-				isSharedColumn = false
-			}
-		}
-		for _, virtualColumn := range ghostVirtualColumns.Names() {
-			if strings.EqualFold(originalColumn, virtualColumn) {
-				isSharedColumn = false
-			}
-		}
 		if isSharedColumn {
 			sharedColumnNames = append(sharedColumnNames, originalColumn)
 		}
@@ -797,9 +2222,9 @@ func (this *Inspector) getSharedColumns(originalColumns, ghostColumns *sql.Colum
 }
 
 // showCreateTable returns the `show create table` statement for given table
-func (this *Inspector) showCreateTable(tableName string) (createTableStatement string, err error) {
+func (this *Inspector) showCreateTable(databaseName, tableName string) (createTableStatement string, err error) {
 	var dummy string
-	query := fmt.Sprintf(`show /* gh-ost */ create table %s.%s`, sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(tableName))
+	query := fmt.Sprintf(`show /* gh-ost */ create table %s.%s`, sql.EscapeName(databaseName), sql.EscapeName(tableName))
 	err = this.db.QueryRow(query).Scan(&dummy, &createTableStatement)
 	return createTableStatement, err
 }
@@ -0,0 +1,265 @@
+/*
+   Copyright 2022 GitHub Inc.
+         See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package logic
+
+import (
+	"strings"
+	"testing"
+
+	test "github.com/openark/golib/tests"
+
+	"github.com/github/gh-ost/go/base"
+	"github.com/github/gh-ost/go/sql"
+)
+
+func TestInspectorValidateExplicitColumnRenames(t *testing.T) {
+	newInspector := func(explicitRenames ...string) *Inspector {
+		migrationContext := base.NewMigrationContext()
+		migrationContext.OriginalTableColumns = sql.NewColumnList([]string{"id", "Name", "age", "city"})
+		migrationContext.GhostTableColumns = sql.NewColumnList([]string{"id", "full_name", "years", "city"})
+		for _, rename := range explicitRenames {
+			test.S(t).ExpectNil(migrationContext.AddExplicitColumnRename(rename))
+		}
+		for oldName, newName := range migrationContext.ExplicitColumnRenameMap {
+			migrationContext.ColumnRenameMap[oldName] = newName
+		}
+		return NewInspector(migrationContext)
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		inspector := newInspector("name:FULL_NAME", "age:years")
+		test.S(t).ExpectNil(inspector.validateExplicitColumnRenames())
+		// keyed by actual column names
+		test.S(t).ExpectEquals(len(inspector.migrationContext.ColumnRenameMap), 2)
+		test.S(t).ExpectEquals(inspector.migrationContext.ColumnRenameMap["Name"], "full_name")
+		test.S(t).ExpectEquals(inspector.migrationContext.ColumnRenameMap["age"], "years")
+	})
+
+	t.Run("no such original column", func(t *testing.T) {
+		inspector := newInspector("title:full_name")
+		test.S(t).ExpectNotNil(inspector.validateExplicitColumnRenames())
+	})
+
+	t.Run("no such ghost column", func(t *testing.T) {
+		inspector := newInspector("name:title")
+		test.S(t).ExpectNotNil(inspector.validateExplicitColumnRenames())
+	})
+
+	t.Run("dropped column", func(t *testing.T) {
+		inspector := newInspector("name:full_name")
+		inspector.migrationContext.DroppedColumnsMap = map[string]bool{"name": true}
+		test.S(t).ExpectNotNil(inspector.validateExplicitColumnRenames())
+	})
+
+	t.Run("onto a shared column", func(t *testing.T) {
+		inspector := newInspector("age:city")
+		test.S(t).ExpectNotNil(inspector.validateExplicitColumnRenames())
+	})
+
+	t.Run("onto a column renamed away", func(t *testing.T) {
+		inspector := newInspector("age:city", "city:years")
+		test.S(t).ExpectNil(inspector.validateExplicitColumnRenames())
+	})
+}
+
+func TestInspectorValidateCheckConstraints(t *testing.T) {
+	newInspector := func(originalConstraints, ghostConstraints [](*sql.CheckConstraint)) *Inspector {
+		migrationContext := base.NewMigrationContext()
+		migrationContext.OriginalTableName = "tbl"
+		migrationContext.SharedColumns = sql.NewColumnList([]string{"id", "amount", "discount"})
+		migrationContext.MappedSharedColumns = sql.NewColumnList([]string{"id", "total", "discount"})
+		migrationContext.ColumnRenameMap = map[string]string{"amount": "total"}
+		migrationContext.OriginalTableCheckConstraints = originalConstraints
+		migrationContext.GhostTableCheckConstraints = ghostConstraints
+		return NewInspector(migrationContext)
+	}
+
+	t.Run("matched", func(t *testing.T) {
+		inspector := newInspector(
+			[](*sql.CheckConstraint){
+				{Name: "positive_amount", Clause: "(`amount` > 0)", Enforced: true},
+				{Name: "tbl_chk_1", Clause: "(`discount` >= 0)", Enforced: true},
+				{Name: "_capped", Clause: "(`discount` < 100)", Enforced: true},
+			},
+			[](*sql.CheckConstraint){
+				{Name: "_tbl_gho_chk_1", Clause: "(`total` > 0)", Enforced: true},
+				{Name: "_tbl_gho_chk_2", Clause: "(`discount` >= 0)", Enforced: true},
+				{Name: "capped", Clause: "(`discount` < 100)", Enforced: true},
+			},
+		)
+		test.S(t).ExpectNil(inspector.validateCheckConstraints())
+		// generated names follow the table; "capped" is already the ghost name of "_capped"
+		test.S(t).ExpectEquals(len(inspector.migrationContext.GhostCheckConstraintRenames), 1)
+		test.S(t).ExpectEquals(inspector.migrationContext.GhostCheckConstraintRenames["_tbl_gho_chk_1"], "_positive_amount")
+		test.S(t).ExpectEquals(len(inspector.migrationContext.NewCheckConstraints), 0)
+	})
+
+	t.Run("dropped enforcement", func(t *testing.T) {
+		originalConstraints := [](*sql.CheckConstraint){
+			{Name: "positive_amount", Clause: "(`amount` > 0)", Enforced: true},
+			{Name: "tbl_chk_1", Clause: "(`discount` >= 0)", Enforced: true},
+		}
+		ghostConstraints := [](*sql.CheckConstraint){
+			{Name: "_tbl_gho_chk_2", Clause: "(`discount` >= 0)", Enforced: false},
+		}
+		inspector := newInspector(originalConstraints, ghostConstraints)
+		test.S(t).ExpectNotNil(inspector.validateCheckConstraints())
+
+		inspector = newInspector(originalConstraints, ghostConstraints)
+		inspector.migrationContext.AllowDroppedCheckConstraints = true
+		test.S(t).ExpectNil(inspector.validateCheckConstraints())
+	})
+
+	t.Run("newly enforced", func(t *testing.T) {
+		inspector := newInspector(
+			[](*sql.CheckConstraint){
+				{Name: "positive_amount", Clause: "(`amount` > 0)", Enforced: false},
+			},
+			[](*sql.CheckConstraint){
+				{Name: "_tbl_gho_chk_1", Clause: "(`total` > 0)", Enforced: true},
+				{Name: "discount_le_total", Clause: "(`discount` <= `total`)", Enforced: true},
+				{Name: "new_column_chk", Clause: "(`added` > 0)", Enforced: true},
+			},
+		)
+		test.S(t).ExpectNil(inspector.validateCheckConstraints())
+		newConstraints := inspector.migrationContext.NewCheckConstraints
+		test.S(t).ExpectEquals(len(newConstraints), 2)
+		test.S(t).ExpectEquals(newConstraints[0].Name, "_tbl_gho_chk_1")
+		test.S(t).ExpectEquals(newConstraints[0].Clause, "(`amount` > 0)")
+		test.S(t).ExpectEquals(newConstraints[1].Name, "discount_le_total")
+		test.S(t).ExpectEquals(newConstraints[1].Clause, "(`discount` <= `amount`)")
+	})
+}
+
+func TestInspectorValidateColumnVisibility(t *testing.T) {
+	newInspector := func(ghostInvisible bool, columnVisibilityMap map[string]bool) *Inspector {
+		migrationContext := base.NewMigrationContext()
+		migrationContext.SharedColumns = sql.NewColumnList([]string{"id", "uuid", "name"})
+		migrationContext.SharedColumns.GetColumn("uuid").Invisible = true
+		migrationContext.MappedSharedColumns = sql.NewColumnList([]string{"id", "uuid", "name"})
+		migrationContext.MappedSharedColumns.GetColumn("uuid").Invisible = ghostInvisible
+		migrationContext.ColumnVisibilityMap = columnVisibilityMap
+		return NewInspector(migrationContext)
+	}
+
+	t.Run("preserved", func(t *testing.T) {
+		inspector := newInspector(true, map[string]bool{})
+		test.S(t).ExpectNil(inspector.validateColumnVisibility())
+	})
+
+	t.Run("made visible implicitly", func(t *testing.T) {
+		inspector := newInspector(false, map[string]bool{})
+		test.S(t).ExpectNotNil(inspector.validateColumnVisibility())
+	})
+
+	t.Run("made visible per the ALTER", func(t *testing.T) {
+		inspector := newInspector(false, map[string]bool{"UUID": false})
+		test.S(t).ExpectNil(inspector.validateColumnVisibility())
+	})
+
+	t.Run("invisible column in shared unique key", func(t *testing.T) {
+		inspector := newInspector(true, map[string]bool{})
+		originalUniqueKeys := [](*sql.UniqueKey){
+			{Name: "PRIMARY", Columns: *sql.NewColumnList([]string{"id"})},
+			{Name: "uuid_uidx", Columns: *sql.NewColumnList([]string{"uuid"})},
+		}
+		ghostUniqueKeys := [](*sql.UniqueKey){
+			{Name: "uuid_uidx", Columns: *sql.NewColumnList([]string{"uuid"})},
+		}
+		sharedUniqueKeys, err := inspector.getSharedUniqueKeys(originalUniqueKeys, ghostUniqueKeys)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(len(sharedUniqueKeys), 1)
+		test.S(t).ExpectEquals(sharedUniqueKeys[0].Name, "uuid_uidx")
+		test.S(t).ExpectNil(inspector.validateColumnVisibility())
+	})
+}
+
+func TestInspectorGetSharedUniqueKeysFunctional(t *testing.T) {
+	inspector := NewInspector(base.NewMigrationContext())
+	originalUniqueKeys := [](*sql.UniqueKey){
+		{Name: "PRIMARY", Columns: *sql.NewColumnList([]string{"id"})},
+		{Name: "email_ci_uidx", Columns: *sql.NewColumnList([]string{}), Expressions: []string{"lower(`email`)"}},
+		{Name: "tenant_email_ci_uidx", Columns: *sql.NewColumnList([]string{"tenant_id"}), Expressions: []string{"lower(`email`)"}},
+	}
+	ghostUniqueKeys := [](*sql.UniqueKey){
+		{Name: "email_ci_uidx", Columns: *sql.NewColumnList([]string{}), Expressions: []string{"lower(`email`)"}},
+		// same column parts, no longer functional: not the same key
+		{Name: "tenant_uidx", Columns: *sql.NewColumnList([]string{"tenant_id"})},
+	}
+	sharedUniqueKeys, err := inspector.getSharedUniqueKeys(originalUniqueKeys, ghostUniqueKeys)
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectEquals(len(sharedUniqueKeys), 1)
+	test.S(t).ExpectEquals(sharedUniqueKeys[0].Name, "email_ci_uidx")
+	test.S(t).ExpectTrue(sharedUniqueKeys[0].IsFunctional())
+
+	test.S(t).ExpectEquals(strings.Join(getFunctionalUniqueKeyNames(originalUniqueKeys), ", "), "`email_ci_uidx`, `tenant_email_ci_uidx`")
+}
+
+func TestInspectorGetSharedUniqueKeysDescending(t *testing.T) {
+	inspector := NewInspector(base.NewMigrationContext())
+	descendingKey := func(name string, columns []string, descendingColumns ...string) *sql.UniqueKey {
+		uniqueKey := &sql.UniqueKey{Name: name, Columns: *sql.NewColumnList(columns)}
+		for _, column := range descendingColumns {
+			uniqueKey.Columns.SetDescending(column)
+		}
+		return uniqueKey
+	}
+	originalUniqueKeys := [](*sql.UniqueKey){
+		descendingKey("PRIMARY", []string{"id"}),
+		descendingKey("tenant_created_uidx", []string{"tenant_id", "created_at"}, "created_at"),
+		descendingKey("tenant_name_uidx", []string{"tenant_id", "name"}),
+	}
+	ghostUniqueKeys := [](*sql.UniqueKey){
+		descendingKey("tenant_created_uidx", []string{"tenant_id", "created_at"}, "created_at"),
+		// same columns, now DESC: not the same key
+		descendingKey("tenant_name_uidx", []string{"tenant_id", "name"}, "name"),
+	}
+	sharedUniqueKeys, err := inspector.getSharedUniqueKeys(originalUniqueKeys, ghostUniqueKeys)
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectEquals(len(sharedUniqueKeys), 1)
+	test.S(t).ExpectEquals(sharedUniqueKeys[0].Name, "tenant_created_uidx")
+	test.S(t).ExpectTrue(sharedUniqueKeys[0].Columns.GetColumn("created_at").Descending)
+}
+
+func TestCharacterColumnIsNarrowing(t *testing.T) {
+	utf8Column := &characterColumn{Charset: "utf8mb3", Collation: "utf8mb3_general_ci", MaxLength: 64, OctetLength: 192}
+	utf8mb4Column := &characterColumn{Charset: "utf8mb4", Collation: "utf8mb4_0900_ai_ci", MaxLength: 64, OctetLength: 256}
+	test.S(t).ExpectTrue(utf8Column.isNarrowing(utf8mb4Column))
+	test.S(t).ExpectTrue(utf8mb4Column.isNarrowing(utf8Column))
+	test.S(t).ExpectFalse(utf8mb4Column.isNarrowing(&characterColumn{Charset: "utf8mb4", Collation: "utf8mb4_bin", MaxLength: 64, OctetLength: 256}))
+	test.S(t).ExpectTrue(utf8mb4Column.isNarrowing(&characterColumn{Charset: "utf8mb4", Collation: "utf8mb4_0900_ai_ci", MaxLength: 32, OctetLength: 128}))
+	test.S(t).ExpectFalse(utf8mb4Column.isNarrowing(&characterColumn{Charset: "utf8mb4", Collation: "utf8mb4_0900_ai_ci", MaxLength: 128, OctetLength: 512}))
+}
+
+func TestBuildCollationCollisionsQueries(t *testing.T) {
+	target := &characterColumn{Charset: "utf8mb4", Collation: "utf8mb4_0900_ai_ci"}
+	keyValues := []string{"`tenant_id`", buildCollationTargetValue("email", target)}
+	{
+		samplesQuery, countQuery := buildCollationCollisionsQueries("mydb", "tbl", keyValues, []string{"tenant_id", "email"}, "", 10)
+		test.S(t).ExpectEquals(samplesQuery, "select /* gh-ost */ `tenant_id`, convert(`email` using utf8mb4) collate utf8mb4_0900_ai_ci, count(*) from `mydb`.`tbl` where `tenant_id` is not null and `email` is not null group by `tenant_id`, convert(`email` using utf8mb4) collate utf8mb4_0900_ai_ci having count(*) > 1 limit 10")
+		test.S(t).ExpectEquals(countQuery, "select /* gh-ost */ count(*) from (select 1 from `mydb`.`tbl` where `tenant_id` is not null and `email` is not null group by `tenant_id`, convert(`email` using utf8mb4) collate utf8mb4_0900_ai_ci having count(*) > 1) collisions")
+	}
+	{
+		samplesQuery, _ := buildCollationCollisionsQueries("mydb", "tbl", keyValues, []string{"tenant_id", "email"}, "deleted_at is null", 10)
+		test.S(t).ExpectTrue(strings.Contains(samplesQuery, "where `tenant_id` is not null and `email` is not null and (deleted_at is null) group by"))
+	}
+}
+
+func TestBuildNarrowedValuesQueries(t *testing.T) {
+	column := &characterColumn{Charset: "utf8mb4", Collation: "utf8mb4_0900_ai_ci", MaxLength: 64, OctetLength: 256}
+	{
+		target := &characterColumn{Charset: "utf8mb4", Collation: "utf8mb4_0900_ai_ci", MaxLength: 32, OctetLength: 128}
+		samplesQuery, countQuery := buildNarrowedValuesQueries("mydb", "tbl", "name", column, target, "", 10)
+		test.S(t).ExpectEquals(samplesQuery, "select /* gh-ost */ left(`name`, 64) from `mydb`.`tbl` where `name` is not null and (char_length(`name`) > 32 or length(convert(`name` using utf8mb4)) > 128) limit 10")
+		test.S(t).ExpectEquals(countQuery, "select /* gh-ost */ count(*) from `mydb`.`tbl` where `name` is not null and (char_length(`name`) > 32 or length(convert(`name` using utf8mb4)) > 128)")
+	}
+	{
+		// Characters may not be representable in the ghost column's charset
+		target := &characterColumn{Charset: "utf8mb3", Collation: "utf8mb3_general_ci", MaxLength: 64, OctetLength: 192}
+		samplesQuery, _ := buildNarrowedValuesQueries("mydb", "tbl", "name", column, target, "id > 100", 10)
+		test.S(t).ExpectEquals(samplesQuery, "select /* gh-ost */ left(`name`, 64) from `mydb`.`tbl` where `name` is not null and (char_length(`name`) > 64 or length(convert(`name` using utf8mb3)) > 192 or cast(convert(convert(`name` using utf8mb3) using utf8mb4) as binary) != cast(`name` as binary)) and (id > 100) limit 10")
+	}
+}
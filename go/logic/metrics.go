@@ -0,0 +1,102 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package logic
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"github.com/github/gh-ost/go/metrics"
+)
+
+// chunkDurationBuckets are the upper bounds, in seconds, of the ghost_chunk_duration_seconds histogram buckets
+var chunkDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// throttleReasonLabel maps a throttle reason onto one of few stable values, suitable for a metric label.
+// Reasons themselves carry values, such as the lag, which would make for ever new label values.
+func throttleReasonLabel(reason string) string {
+	switch {
+	case reason == "commanded by user":
+		return "user-command"
+	case reason == "flag-file", strings.HasPrefix(reason, "flag-file "):
+		return "flag-file"
+	case reason == "throttle-query":
+		return "throttle-query"
+	case strings.HasPrefix(reason, "schedule: "):
+		return "schedule"
+	case strings.HasPrefix(reason, "max-load"):
+		return "max-load"
+	case strings.HasPrefix(reason, "critical-load"), reason == "leaving hibernation":
+		return "critical-load"
+	case strings.HasPrefix(reason, "free-disk-space"):
+		return "free-disk-space"
+	case strings.HasPrefix(reason, "history-list-length"):
+		return "history-list-length"
+	case strings.HasPrefix(reason, "lag="):
+		return "lag"
+	case strings.Contains(reason, "replica-lag="):
+		return "control-replicas-lag"
+	case strings.HasPrefix(reason, "binlog events buffer"):
+		return "binlog-events-buffer"
+	case strings.HasPrefix(reason, "http="), strings.Contains(reason, "(http="):
+		return "http"
+	}
+	return "other"
+}
+
+// Collect provides the migration's metrics, labeled by database and table, to the metrics registry
+func (this *Migrator) Collect() []*metrics.Family {
+	labels := map[string]string{
+		"database": this.migrationContext.DatabaseName,
+		"table":    this.migrationContext.OriginalTableName,
+	}
+	withType := func(dmlType string) map[string]string {
+		return map[string]string{"database": labels["database"], "table": labels["table"], "type": dmlType}
+	}
+	boolValue := func(b bool) float64 {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	isThrottled, throttleReason, _ := this.migrationContext.IsThrottled()
+	estimatedRows := atomic.LoadInt64(&this.migrationContext.RowsEstimate) + atomic.LoadInt64(&this.migrationContext.RowsDeltaEstimate)
+
+	families := []*metrics.Family{
+		metrics.NewFamily("ghost_rows_copied_total", "Rows copied from the original table onto the ghost table", metrics.CounterType).
+			Add(labels, float64(this.migrationContext.GetTotalRowsCopied())),
+		metrics.NewFamily("ghost_rows_estimated", "Estimated number of rows to copy", metrics.GaugeType).
+			Add(labels, float64(estimatedRows)),
+		metrics.NewFamily("ghost_copy_progress_percent", "Row copy progress, in percent", metrics.GaugeType).
+			Add(labels, this.migrationContext.GetProgressPct()),
+		metrics.NewFamily("ghost_heartbeat_lag_seconds", "Time since the last heartbeat was read off the changelog table", metrics.GaugeType).
+			Add(labels, this.migrationContext.TimeSinceLastHeartbeatOnChangelog().Seconds()),
+		metrics.NewFamily("ghost_binlog_backlog_events", "DML events read from the binary log and not yet applied", metrics.GaugeType).
+			Add(labels, float64(len(this.applyEventsQueue))),
+		metrics.NewFamily("ghost_dml_events_applied_total", "DML events applied onto the ghost table, by type", metrics.CounterType).
+			Add(withType("insert"), float64(atomic.LoadInt64(&this.migrationContext.DMLInsertsApplied))).
+			Add(withType("update"), float64(atomic.LoadInt64(&this.migrationContext.DMLUpdatesApplied))).
+			Add(withType("delete"), float64(atomic.LoadInt64(&this.migrationContext.DMLDeletesApplied))),
+		metrics.NewFamily("ghost_history_list_length", "InnoDB history list length on the applier host, as last read by the throttler", metrics.GaugeType).
+			Add(labels, float64(atomic.LoadInt64(&this.migrationContext.CurrentHistoryListLength))),
+		metrics.NewFamily("ghost_throttled", "Whether the migration is throttled", metrics.GaugeType).
+			Add(labels, boolValue(isThrottled)),
+		this.chunkDurations.AddTo(metrics.NewFamily("ghost_chunk_duration_seconds", "Duration of row copy chunks", metrics.HistogramType), labels),
+		metrics.NewFamily("ghost_cut_over_attempts_total", "Cut-over attempts", metrics.CounterType).
+			Add(labels, float64(atomic.LoadInt64(&this.migrationContext.CutOverAttempts))),
+	}
+	if this.migrationContext.IsDiskSpaceCheckEnabled() {
+		families = append(families,
+			metrics.NewFamily("ghost_free_disk_space_bytes", "Free disk space of the applier's data directory, as last read by the throttler", metrics.GaugeType).
+				Add(labels, float64(atomic.LoadInt64(&this.migrationContext.CurrentFreeDiskSpace))),
+		)
+	}
+	throttleReasonFamily := metrics.NewFamily("ghost_throttle_reason", "Set to 1 for the reason the migration is throttled by, while throttled", metrics.GaugeType)
+	if isThrottled {
+		throttleReasonFamily.Add(map[string]string{"database": labels["database"], "table": labels["table"], "reason": throttleReasonLabel(throttleReason)}, 1)
+	}
+	return append(families, throttleReasonFamily)
+}
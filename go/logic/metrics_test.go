@@ -0,0 +1,62 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package logic
+
+import (
+	"bytes"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	test "github.com/openark/golib/tests"
+
+	"github.com/github/gh-ost/go/base"
+	"github.com/github/gh-ost/go/metrics"
+)
+
+func TestThrottleReasonLabel(t *testing.T) {
+	test.S(t).ExpectEquals(throttleReasonLabel("commanded by user"), "user-command")
+	test.S(t).ExpectEquals(throttleReasonLabel("flag-file"), "flag-file")
+	test.S(t).ExpectEquals(throttleReasonLabel("flag-file /etc/gh-ost/throttle.d/backup"), "flag-file")
+	test.S(t).ExpectEquals(throttleReasonLabel("max-load Threads_running=120 >= 100"), "max-load")
+	test.S(t).ExpectEquals(throttleReasonLabel("lag=2.500000s"), "lag")
+	test.S(t).ExpectEquals(throttleReasonLabel("replica1:3306 replica-lag=2.500000s"), "control-replicas-lag")
+	test.S(t).ExpectEquals(throttleReasonLabel("critical-load-hibernate until 2022-01-01 00:00:00"), "critical-load")
+	test.S(t).ExpectEquals(throttleReasonLabel("history-list-length=12,345,678"), "history-list-length")
+	test.S(t).ExpectEquals(throttleReasonLabel("free-disk-space=95.2 GB < 100GB"), "free-disk-space")
+	test.S(t).ExpectEquals(throttleReasonLabel("http=503"), "http")
+	test.S(t).ExpectEquals(throttleReasonLabel("maintenance (http=429)"), "http")
+	test.S(t).ExpectEquals(throttleReasonLabel("schedule: business hours"), "schedule")
+	test.S(t).ExpectEquals(throttleReasonLabel("something else"), "other")
+}
+
+func TestMigratorCollect(t *testing.T) {
+	migrationContext := base.NewMigrationContext()
+	migrationContext.DatabaseName = "test"
+	migrationContext.OriginalTableName = "orders"
+	atomic.StoreInt64(&migrationContext.TotalRowsCopied, 1000)
+	atomic.StoreInt64(&migrationContext.DMLUpdatesApplied, 7)
+	migrationContext.SetThrottled(true, "lag=3.000000s", base.NoThrottleReasonHint)
+
+	migrator := NewMigrator(migrationContext, "1.2.3")
+	migrator.chunkDurations.Observe(0.02)
+	registry := metrics.NewRegistry()
+	registry.Register(migrator)
+
+	var buf bytes.Buffer
+	test.S(t).ExpectNil(registry.WriteText(&buf))
+	text := buf.String()
+	for _, line := range []string{
+		`ghost_rows_copied_total{database="test",table="orders"} 1000`,
+		`ghost_dml_events_applied_total{database="test",table="orders",type="update"} 7`,
+		`ghost_throttled{database="test",table="orders"} 1`,
+		`ghost_throttle_reason{database="test",reason="lag",table="orders"} 1`,
+		`ghost_chunk_duration_seconds_bucket{database="test",le="0.025",table="orders"} 1`,
+		`ghost_chunk_duration_seconds_count{database="test",table="orders"} 1`,
+	} {
+		test.S(t).ExpectTrue(strings.Contains(text, line+"\n"))
+	}
+}
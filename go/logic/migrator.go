@@ -7,16 +7,20 @@ package logic
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math"
 	"os"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/github/gh-ost/go/base"
 	"github.com/github/gh-ost/go/binlog"
+	"github.com/github/gh-ost/go/metrics"
 	"github.com/github/gh-ost/go/mysql"
 	"github.com/github/gh-ost/go/sql"
 )
@@ -36,8 +40,9 @@ func ReadChangelogState(s string) ChangelogState {
 type tableWriteFunc func() error
 
 type applyEventStruct struct {
-	writeFunc *tableWriteFunc
-	dmlEvent  *binlog.BinlogDMLEvent
+	writeFunc        *tableWriteFunc
+	dmlEvent         *binlog.BinlogDMLEvent
+	isTransactionEnd bool
 }
 
 func newApplyEventStructByFunc(writeFunc *tableWriteFunc) *applyEventStruct {
@@ -50,6 +55,14 @@ func newApplyEventStructByDML(dmlEvent *binlog.BinlogDMLEvent) *applyEventStruct
 	return result
 }
 
+func newApplyEventStructByTransactionEnd() *applyEventStruct {
+	result := &applyEventStruct{isTransactionEnd: true}
+	return result
+}
+
+// applierKeepaliveInterval is how often applier connections are pinged while postponing cut-over
+const applierKeepaliveInterval = time.Minute
+
 type PrintStatusRule int
 
 const (
@@ -70,6 +83,8 @@ type Migrator struct {
 	server           *Server
 	throttler        *Throttler
 	hooksExecutor    *HooksExecutor
+	dmlAuditor       *DMLAuditor
+	dmlApplyWorkers  *dmlApplyWorkers
 	migrationContext *base.MigrationContext
 
 	firstThrottlingCollected   chan bool
@@ -82,10 +97,37 @@ type Migrator struct {
 	//  excessive work happens at the end of the iteration as new copy-jobs arrive before realizing the copy is complete
 	copyRowsQueue    chan tableWriteFunc
 	applyEventsQueue chan *applyEventStruct
+	// bufferedTransactions counts whole transactions in applyEventsQueue, not yet applied
+	bufferedTransactions int64
+	// applyingEventTimestamp is the binlog timestamp, in unix seconds, of the oldest event of the batch being, or
+	// last, applied: the oldest unapplied event, for as long as applyEventsQueue is not empty
+	applyingEventTimestamp int64
 
 	handledChangelogStates map[string]bool
 
+	// cancelStreaming stops binlog streaming, interrupting a read blocked on an idle binlog
+	cancelStreaming context.CancelFunc
+
 	finishedMigrating int64
+
+	// cutOverMutex serializes the cut-over and cut-over rehearsals
+	cutOverMutex *sync.Mutex
+
+	// ghostForeignKeysCreated is set once --preserve-foreign-keys created the foreign keys on the ghost table
+	ghostForeignKeysCreated bool
+
+	// resumeCheckpoint is the checkpoint --resume continues from
+	resumeCheckpoint *Checkpoint
+
+	// scheduledDrop is the --drop-old-table-after drop of the old table, recorded at final cleanup
+	scheduledDrop *ScheduledDrop
+
+	// chunkDurations is the histogram of row copy chunk durations, served as ghost_chunk_duration_seconds
+	chunkDurations *metrics.Histogram
+
+	// checkpointBinlogCoordinates are the coordinates of the last DML event handed over for applying,
+	// or where streaming began. Only accessed by executeWriteFuncs().
+	checkpointBinlogCoordinates mysql.BinlogCoordinates
 }
 
 func NewMigrator(context *base.MigrationContext, appVersion string) *Migrator {
@@ -94,7 +136,7 @@ func NewMigrator(context *base.MigrationContext, appVersion string) *Migrator {
 		migrationContext:           context,
 		parser:                     sql.NewAlterTableParser(),
 		ghostTableMigrated:         make(chan bool),
-		firstThrottlingCollected:   make(chan bool, 3),
+		firstThrottlingCollected:   make(chan bool, 5),
 		rowCopyComplete:            make(chan error),
 		allEventsUpToLockProcessed: make(chan string),
 
@@ -102,6 +144,8 @@ func NewMigrator(context *base.MigrationContext, appVersion string) *Migrator {
 		applyEventsQueue:       make(chan *applyEventStruct, base.MaxEventsBatchSize),
 		handledChangelogStates: make(map[string]bool),
 		finishedMigrating:      0,
+		cutOverMutex:           &sync.Mutex{},
+		chunkDurations:         metrics.NewHistogram(chunkDurationBuckets),
 	}
 	return migrator
 }
@@ -115,6 +159,20 @@ func (this *Migrator) initiateHooksExecutor() (err error) {
 	return nil
 }
 
+// initiateDMLAuditor opens --audit-dml-file, if provided
+func (this *Migrator) initiateDMLAuditor() (err error) {
+	if this.migrationContext.AuditDMLFile == "" {
+		return nil
+	}
+	dmlAuditor := NewDMLAuditor(this.migrationContext)
+	if err := dmlAuditor.Open(); err != nil {
+		return err
+	}
+	this.dmlAuditor = dmlAuditor
+	this.migrationContext.Log.Infof("Auditing applied DML events onto %s", this.migrationContext.AuditDMLFile)
+	return nil
+}
+
 // sleepWhileTrue sleeps indefinitely until the given function returns 'false'
 // (or fails with error)
 func (this *Migrator) sleepWhileTrue(operation func() (bool, error)) error {
@@ -151,34 +209,97 @@ func (this *Migrator) retryOperation(operation func() error, notFatalHint ...boo
 	return err
 }
 
-// `retryOperationWithExponentialBackoff` attempts running given function, waiting 2^(n-1)
-// seconds between each attempt, where `n` is the running number of attempts. Exits
-// as soon as the function returns with non-error, or as soon as `MaxRetries`
-// attempts are reached. Wait intervals between attempts obey a maximum of
-// `ExponentialBackoffMaxInterval`.
-func (this *Migrator) retryOperationWithExponentialBackoff(operation func() error, notFatalHint ...bool) (err error) {
-	var interval int64
-	maxRetries := int(this.migrationContext.MaxRetries())
-	maxInterval := this.migrationContext.ExponentialBackoffMaxInterval
-	for i := 0; i < maxRetries; i++ {
-		newInterval := int64(math.Exp2(float64(i - 1)))
-		if newInterval <= maxInterval {
-			interval = newInterval
+var errCutOverVetoed = errors.New("cut-over vetoed by gh-ost-on-before-cut-over hook")
+
+// cutOverError is the error of a failed cut-over attempt, classified by the step at which it failed
+type cutOverError struct {
+	reason base.CutOverFailureReason
+	err    error
+}
+
+func newCutOverError(reason base.CutOverFailureReason, err error) error {
+	return &cutOverError{reason: reason, err: err}
+}
+
+func (this *cutOverError) Error() string {
+	return this.err.Error()
+}
+
+func (this *cutOverError) Unwrap() error {
+	return this.err
+}
+
+// cutOverFailureReason classifies the error of a failed cut-over attempt
+func cutOverFailureReason(err error) base.CutOverFailureReason {
+	var cutOverErr *cutOverError
+	if errors.As(err, &cutOverErr) {
+		return cutOverErr.reason
+	}
+	if mysql.IsLockWaitTimeoutError(err) {
+		return base.CutOverLockTimeoutFailure
+	}
+	return base.CutOverUnclassifiedFailure
+}
+
+// retryCutOver attempts cut-over up to --cut-over-retry-attempts times, waiting --cut-over-retry-interval
+// between attempts, or exponentially longer intervals with --cut-over-exponential-backoff. Each failed
+// attempt is recorded and fires the onFailure hook. Once attempts are exhausted, and with
+// --postpone-cut-over-on-failure, cut-over is postponed anew rather than the migration aborted.
+// An attempt vetoed by the onBeforeCutOver hook is not a failure, and postpones cut-over as well.
+func (this *Migrator) retryCutOver() (err error) {
+	maxRetries := this.migrationContext.CutOverMaxRetries()
+	for {
+		for failures := int64(0); failures < maxRetries; {
+			if failures > 0 {
+				time.Sleep(this.migrationContext.CutOverRetryWait(failures))
+			}
+			attempt := atomic.AddInt64(&this.migrationContext.CutOverAttempts, 1)
+			err = this.cutOver()
+			if err == nil {
+				return nil
+			}
+			if errors.Is(err, errCutOverVetoed) || errors.Is(err, errCutOverRowCountMismatch) {
+				atomic.AddInt64(&this.migrationContext.CutOverVetoes, 1)
+				this.migrationContext.Log.Warningf("Cut-over attempt %d vetoed: %+v", attempt, err)
+				this.onCutOverRowCountMismatch(err)
+				if this.migrationContext.PostponeCutOverFlagFile == "" {
+					time.Sleep(this.migrationContext.CutOverRetryInterval)
+				} else if postponeErr := this.postponeCutOver(); postponeErr != nil {
+					this.migrationContext.Log.Errore(postponeErr)
+				}
+				continue
+			}
+			failures++
+			reason := cutOverFailureReason(err)
+			this.migrationContext.AddCutOverFailure(reason)
+			this.migrationContext.Log.Warningf("Cut-over attempt %d (%d/%d) failed, %s: %+v", attempt, failures, maxRetries, reason, err)
+			if hookErr := this.hooksExecutor.onCutOverAttemptFailure(attempt, failures, maxRetries, reason, err); hookErr != nil {
+				this.migrationContext.Log.Errore(hookErr)
+			}
 		}
-		if i != 0 {
-			time.Sleep(time.Duration(interval) * time.Second)
+		if !this.migrationContext.PostponeCutOverOnFailure {
+			break
 		}
-		err = operation()
-		if err == nil {
-			return nil
+		this.migrationContext.Log.Warningf("Cut-over failed %d times in a row", maxRetries)
+		if postponeErr := this.postponeCutOver(); postponeErr != nil {
+			this.migrationContext.Log.Errore(postponeErr)
+			break
 		}
 	}
-	if len(notFatalHint) == 0 {
-		this.migrationContext.PanicAbort <- err
-	}
+	err = fmt.Errorf("%w; %s", err, this.migrationContext.CutOverState.Summary())
+	this.migrationContext.PanicAbort <- err
 	return err
 }
 
+// postponeCutOver re-creates the postpone flag file, such that cut-over waits till it is deleted anew
+func (this *Migrator) postponeCutOver() error {
+	if err := base.TouchFile(this.migrationContext.PostponeCutOverFlagFile); err != nil {
+		return fmt.Errorf("Cannot postpone cut-over: %+v", err)
+	}
+	this.migrationContext.Log.Infof("Postponing cut-over. Delete %s to proceed", this.migrationContext.PostponeCutOverFlagFile)
+	return nil
+}
+
 // consumeRowCopyComplete blocks on the rowCopyComplete channel once, and then
 // consumes and drops any further incoming events that may be left hanging.
 func (this *Migrator) consumeRowCopyComplete() {
@@ -196,8 +317,11 @@ func (this *Migrator) consumeRowCopyComplete() {
 	}()
 }
 
-func (this *Migrator) canStopStreaming() bool {
-	return atomic.LoadInt64(&this.migrationContext.CutOverCompleteFlag) != 0
+// stopStreaming stops binlog streaming, if it was ever started
+func (this *Migrator) stopStreaming() {
+	if this.cancelStreaming != nil {
+		this.cancelStreaming()
+	}
 }
 
 // onChangelogEvent is called when a binlog event operation on the changelog table is intercepted.
@@ -217,15 +341,29 @@ func (this *Migrator) onChangelogStateEvent(dmlEvent *binlog.BinlogDMLEvent) (er
 	changelogStateString := dmlEvent.NewColumnValues.StringColumn(3)
 	changelogState := ReadChangelogState(changelogStateString)
 	this.migrationContext.Log.Infof("Intercepted changelog state %s", changelogState)
+	if this.migrationContext.BinlogFile != "" {
+		// States found in a binlog file were not written by this migration; none are awaited
+		return nil
+	}
 	switch changelogState {
 	case GhostTableMigrated:
 		{
+			if this.migrationContext.Resume {
+				// Streamed anew from the interrupted migration; not awaited
+				return nil
+			}
 			this.ghostTableMigrated <- true
 		}
 	case AllEventsUpToLockProcessed:
 		{
 			var applyEventFunc tableWriteFunc = func() error {
-				this.allEventsUpToLockProcessed <- changelogStateString
+				// A state no longer awaited, following a timed out cut-over attempt or rehearsal, is dropped
+				// rather than block the applying of events
+				select {
+				case this.allEventsUpToLockProcessed <- changelogStateString:
+				case <-time.After(time.Duration(this.migrationContext.CutOverLockTimeoutSeconds) * time.Second):
+					this.migrationContext.Log.Warningf("Dropping changelog state %s: not awaited", changelogStateString)
+				}
 				return nil
 			}
 			// at this point we know all events up to lock have been read from the streamer,
@@ -272,19 +410,48 @@ func (this *Migrator) listenOnPanicAbort() {
 // - no table rename allowed
 func (this *Migrator) validateStatement() (err error) {
 	if this.parser.IsRenameTable() {
-		return fmt.Errorf("ALTER statement seems to RENAME the table. This is not supported, and you should run your RENAME outside gh-ost.")
+		return fmt.Errorf("ALTER statement seems to RENAME the table. This is not supported; use --new-table-name to rename the table at cut-over.")
 	}
-	if this.parser.HasNonTrivialRenames() && !this.migrationContext.SkipRenamedColumns {
-		this.migrationContext.ColumnRenameMap = this.parser.GetNonTrivialRenames()
+	if inferredRenames := this.inferredColumnRenames(); len(inferredRenames) > 0 && !this.migrationContext.SkipRenamedColumns {
+		this.migrationContext.ColumnRenameMap = inferredRenames
 		if !this.migrationContext.ApproveRenamedColumns {
-			return fmt.Errorf("gh-ost believes the ALTER statement renames columns, as follows: %v; as precaution, you are asked to confirm gh-ost is correct, and provide with `--approve-renamed-columns`, and we're all happy. Or you can skip renamed columns via `--skip-renamed-columns`, in which case column data may be lost", this.parser.GetNonTrivialRenames())
+			return fmt.Errorf("gh-ost believes the ALTER statement renames columns, as follows: %v; as precaution, you are asked to confirm gh-ost is correct, and provide with `--approve-renamed-columns`, and we're all happy. Or you can skip renamed columns via `--skip-renamed-columns`, in which case column data may be lost. You may also state renames via `--rename-column`", inferredRenames)
+		}
+		this.migrationContext.Log.Infof("Alter statement has column(s) renamed. gh-ost finds the following renames: %v; --approve-renamed-columns is given and so migration proceeds.", inferredRenames)
+	}
+	if len(this.migrationContext.ExplicitColumnRenameMap) > 0 {
+		for oldName, newName := range this.migrationContext.ExplicitColumnRenameMap {
+			this.migrationContext.ColumnRenameMap[oldName] = newName
 		}
-		this.migrationContext.Log.Infof("Alter statement has column(s) renamed. gh-ost finds the following renames: %v; --approve-renamed-columns is given and so migration proceeds.", this.parser.GetNonTrivialRenames())
+		this.migrationContext.Log.Infof("Column renames per --rename-column: %v", this.migrationContext.ExplicitColumnRenameMap)
 	}
 	this.migrationContext.DroppedColumnsMap = this.parser.DroppedColumnsMap()
+	this.migrationContext.ColumnVisibilityMap = this.parser.ColumnVisibilityMap()
+	if this.migrationContext.PreserveForeignKeys && this.parser.HasForeignKeyChanges() {
+		return fmt.Errorf("ALTER statement seems to add or drop foreign keys. This is not supported with --preserve-foreign-keys, which creates the original table's foreign keys on the ghost table")
+	}
 	return nil
 }
 
+// inferredColumnRenames returns the column renames the parser infers from the ALTER statement, less those
+// overridden by --rename-column: renames of the same column, or onto the same column
+func (this *Migrator) inferredColumnRenames() map[string]string {
+	inferredRenames := make(map[string]string)
+	for oldName, newName := range this.parser.GetNonTrivialRenames() {
+		overridden := false
+		for explicitOldName, explicitNewName := range this.migrationContext.ExplicitColumnRenameMap {
+			if strings.EqualFold(oldName, explicitOldName) || strings.EqualFold(newName, explicitNewName) {
+				overridden = true
+				break
+			}
+		}
+		if !overridden {
+			inferredRenames[oldName] = newName
+		}
+	}
+	return inferredRenames
+}
+
 func (this *Migrator) countTableRows() (err error) {
 	if !this.migrationContext.CountTableRows {
 		// Not counting; we stay with an estimate
@@ -357,10 +524,19 @@ func (this *Migrator) Migrate() (err error) {
 	// After this point, we'll need to teardown anything that's been started
 	//   so we don't leave things hanging around
 	defer this.teardown()
+	metrics.DefaultRegistry.Register(this)
 
 	if err := this.initiateInspector(); err != nil {
 		return err
 	}
+	if err := this.initiateDMLAuditor(); err != nil {
+		return err
+	}
+	if this.migrationContext.Resume {
+		if err := this.readResumeCheckpoint(); err != nil {
+			return err
+		}
+	}
 	if err := this.initiateStreaming(); err != nil {
 		return err
 	}
@@ -372,7 +548,7 @@ func (this *Migrator) Migrate() (err error) {
 	}
 	// In MySQL 8.0 (and possibly earlier) some DDL statements can be applied instantly.
 	// Attempt to do this if AttemptInstantDDL is set.
-	if this.migrationContext.AttemptInstantDDL {
+	if this.migrationContext.AttemptInstantDDL && !this.migrationContext.Resume {
 		this.migrationContext.Log.Infof("Attempting to execute alter with ALGORITHM=INSTANT")
 		if err := this.applier.AttemptInstantDDL(); err == nil {
 			this.migrationContext.Log.Infof("Success! table %s.%s migrated instantly", sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName))
@@ -383,8 +559,16 @@ func (this *Migrator) Migrate() (err error) {
 	}
 
 	initialLag, _ := this.inspector.getReplicationLag()
-	this.migrationContext.Log.Infof("Waiting for ghost table to be migrated. Current lag is %+v", initialLag)
-	<-this.ghostTableMigrated
+	if this.migrationContext.BinlogFile != "" {
+		// This migration's changelog writes are not to be found in the binlog file
+		this.migrationContext.Log.Warningf("--binlog-file: not waiting for ghost table to be migrated")
+	} else if this.migrationContext.Resume {
+		// The ghost table was migrated by the interrupted migration
+		this.migrationContext.Log.Infof("--resume: not waiting for ghost table to be migrated")
+	} else {
+		this.migrationContext.Log.Infof("Waiting for ghost table to be migrated. Current lag is %+v", initialLag)
+		<-this.ghostTableMigrated
+	}
 	this.migrationContext.Log.Debugf("ghost table migrated")
 	// Yay! We now know the Ghost and Changelog tables are good to examine!
 	// When running on replica, this means the replica has those tables. When running
@@ -393,6 +577,22 @@ func (this *Migrator) Migrate() (err error) {
 	if err := this.inspector.inspectOriginalAndGhostTables(); err != nil {
 		return err
 	}
+	if this.migrationContext.UsingSurrogateKey {
+		if err := this.applier.AddSurrogateKeyColumn(); err != nil {
+			return err
+		}
+	}
+	if !this.migrationContext.Resume {
+		// The interrupted migration's ghost table already has its constraints renamed, and rows copied
+		if err := this.applier.RenameGhostCheckConstraints(); err != nil {
+			return err
+		}
+	}
+	if this.resumeCheckpoint != nil {
+		if err := this.resumeCheckpoint.ValidateTables(this.migrationContext); err != nil {
+			return err
+		}
+	}
 	// Validation complete! We're good to execute this migration
 	if err := this.hooksExecutor.onValidated(); err != nil {
 		return err
@@ -403,21 +603,36 @@ func (this *Migrator) Migrate() (err error) {
 	}
 	defer this.server.RemoveSocketFile()
 
-	if err := this.countTableRows(); err != nil {
+	if err := this.initiateThrottler(); err != nil {
 		return err
 	}
-	if err := this.addDMLEventsListener(); err != nil {
-		return err
+	if !this.migrationContext.Resume {
+		// The interrupted migration has analyzed the original table, and rows are partly copied
+		throttle := func() { this.throttler.throttle(nil) }
+		if err := this.inspector.validateCollationRisks(throttle); err != nil {
+			return err
+		}
 	}
-	if err := this.applier.ReadMigrationRangeValues(); err != nil {
+	if err := this.countTableRows(); err != nil {
 		return err
 	}
-	if err := this.initiateThrottler(); err != nil {
-		return err
+	if this.resumeCheckpoint != nil {
+		// The DML events listener is added as streaming begins
+		if err := this.applier.ResumeMigrationRangeValues(this.resumeCheckpoint); err != nil {
+			return err
+		}
+	} else {
+		if err := this.addDMLEventsListener(); err != nil {
+			return err
+		}
+		if err := this.applier.ReadMigrationRangeValues(); err != nil {
+			return err
+		}
 	}
 	if err := this.hooksExecutor.onBeforeRowCopy(); err != nil {
 		return err
 	}
+	this.initiateDMLApplyWorkers()
 	go this.executeWriteFuncs()
 	go this.iterateChunks()
 	this.migrationContext.MarkRowCopyStartTime()
@@ -435,19 +650,17 @@ func (this *Migrator) Migrate() (err error) {
 		this.migrationContext.Log.Info("stopping query for exact row count, because that can accidentally lock out the cut over")
 		this.migrationContext.CancelTableRowsCount()
 	}
-	if err := this.hooksExecutor.onBeforeCutOver(); err != nil {
+	this.rehearseCutOverTimes()
+	if err := this.retryCutOver(); err != nil {
 		return err
 	}
-	var retrier func(func() error, ...bool) error
-	if this.migrationContext.CutOverExponentialBackoff {
-		retrier = this.retryOperationWithExponentialBackoff
-	} else {
-		retrier = this.retryOperation
-	}
-	if err := retrier(this.cutOver); err != nil {
+	atomic.StoreInt64(&this.migrationContext.CutOverCompleteFlag, 1)
+	if err := this.repointForeignKeys(); err != nil {
 		return err
 	}
-	atomic.StoreInt64(&this.migrationContext.CutOverCompleteFlag, 1)
+	this.renameTriggers()
+	this.reportCheckConstraintNames()
+	this.stopStreaming()
 
 	if err := this.finalCleanup(); err != nil {
 		return nil
@@ -455,7 +668,22 @@ func (this *Migrator) Migrate() (err error) {
 	if err := this.hooksExecutor.onSuccess(); err != nil {
 		return err
 	}
-	this.migrationContext.Log.Infof("Done migrating %s.%s", sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName))
+	if this.migrationContext.IsTableRenamed() {
+		this.migrationContext.Log.Infof("Done migrating %s.%s into %s.%s, applying with sql_mode '%s'", sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName), sql.EscapeName(this.migrationContext.GetTargetDatabaseName()), sql.EscapeName(this.migrationContext.GetMigratedTableName()), this.migrationContext.ApplierSQLMode)
+	} else {
+		this.migrationContext.Log.Infof("Done migrating %s.%s, applying with sql_mode '%s'", sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName), this.migrationContext.ApplierSQLMode)
+	}
+	this.migrationContext.Log.Infof("Applied %d DML events in %d transactions; average transaction size: %s",
+		atomic.LoadInt64(&this.migrationContext.TotalDMLEventsApplied),
+		atomic.LoadInt64(&this.migrationContext.DMLApplyTransactions),
+		base.PrettifyBytes(this.migrationContext.GetDMLApplyAverageTransactionSize()),
+	)
+	if totalThrottled, throttleDurations := this.migrationContext.GetThrottleDurations(); totalThrottled > 0 {
+		this.migrationContext.Log.Infof("Throttled for %+v in total; by reason: %s", totalThrottled.Round(time.Second), base.DescribeThrottleDurations(throttleDurations, 0))
+	}
+	if this.migrationContext.CompactUpdates {
+		this.migrationContext.Log.Infof("Compact updates saved %s of column values written", base.PrettifyBytes(float64(atomic.LoadInt64(&this.migrationContext.CompactUpdatesBytesSaved))))
+	}
 	return nil
 }
 
@@ -509,6 +737,17 @@ func (this *Migrator) cutOver() (err error) {
 
 	this.migrationContext.MarkPointOfInterest()
 	this.migrationContext.Log.Debugf("checking for cut-over postpone")
+	lastApplierKeepalive := time.Now()
+	keepalive := func() {
+		if time.Since(lastApplierKeepalive) >= applierKeepaliveInterval {
+			// Cut-over may be postponed for long. Keep connections alive, so that it does not start with dead ones
+			if err := this.applier.Keepalive(); err != nil {
+				this.migrationContext.Log.Warningf("Applier keepalive failed: %+v", err)
+			}
+			lastApplierKeepalive = time.Now()
+		}
+	}
+	var autoUnpostponeStableSince time.Time
 	this.sleepWhileTrue(
 		func() (bool, error) {
 			heartbeatLag := this.migrationContext.TimeSinceLastHeartbeatOnChangelog()
@@ -518,6 +757,19 @@ func (this *Migrator) cutOver() (err error) {
 				this.migrationContext.Log.Debugf("current HeartbeatLag (%.2fs) is too high, it needs to be less than both --max-lag-millis (%.2fs) and --cut-over-lock-timeout-seconds (%.2fs) to continue", heartbeatLag.Seconds(), maxLagMillisecondsThrottle.Seconds(), cutOverLockTimeout.Seconds())
 				return true, nil
 			}
+			if window := this.migrationContext.CutOverWindow; window != nil {
+				// The window and the postpone flag file must both clear. A window missed, e.g. while throttled,
+				// is waited for on the next day
+				if !window.Contains(time.Now()) {
+					if atomic.LoadInt64(&this.migrationContext.IsWaitingForCutOverWindow) == 0 {
+						this.migrationContext.Log.Infof("Ready to cut-over; waiting for cut-over window %s, next window in %s", window, base.PrettifyDurationOutput(window.Until(time.Now())))
+					}
+					atomic.StoreInt64(&this.migrationContext.IsWaitingForCutOverWindow, 1)
+					keepalive()
+					return true, nil
+				}
+				atomic.StoreInt64(&this.migrationContext.IsWaitingForCutOverWindow, 0)
+			}
 			if this.migrationContext.PostponeCutOverFlagFile == "" {
 				return false, nil
 			}
@@ -533,15 +785,33 @@ func (this *Migrator) cutOver() (err error) {
 					}
 				}
 				atomic.StoreInt64(&this.migrationContext.IsPostponingCutOver, 1)
+				if unpostpone, err := this.autoUnpostpone(&autoUnpostponeStableSince); unpostpone {
+					return false, err
+				}
+				keepalive()
 				return true, nil
 			}
 			return false, nil
 		},
 	)
 	atomic.StoreInt64(&this.migrationContext.IsPostponingCutOver, 0)
+	atomic.StoreInt64(&this.migrationContext.IsWaitingForCutOverWindow, 0)
 	this.migrationContext.MarkPointOfInterest()
 	this.migrationContext.Log.Debugf("checking for cut-over postpone: complete")
 
+	// The hook runs ahead of any locks, and so cannot extend the time queries are blocked
+	binlogCoordinates := this.eventsStreamer.GetCurrentBinlogCoordinates().DisplayString()
+	if err := this.hooksExecutor.onBeforeCutOver(atomic.LoadInt64(&this.migrationContext.CutOverAttempts), binlogCoordinates); err != nil {
+		return fmt.Errorf("%w: %+v", errCutOverVetoed, err)
+	}
+
+	if err := this.checkCutOverRowCount(base.CutOverRowCountEstimate); err != nil {
+		return err
+	}
+	if err := this.createGhostForeignKeys(); err != nil {
+		return err
+	}
+
 	if this.migrationContext.TestOnReplica {
 		// With `--test-on-replica` we stop replication thread, and then proceed to use
 		// the same cut-over phase as the master would use. That means we take locks
@@ -560,20 +830,179 @@ func (this *Migrator) cutOver() (err error) {
 		}
 	}
 
+	this.cutOverMutex.Lock()
+	defer this.cutOverMutex.Unlock()
+	this.migrationContext.CutOverState.Begin()
 	switch this.migrationContext.CutOverType {
 	case base.CutOverAtomic:
 		// Atomic solution: we use low timeout and multiple attempts. But for
 		// each failed attempt, we throttle until replication lag is back to normal
+		this.migrationContext.Log.Infof("Proceeding to atomic cut-over; lock wait timeout: %ds, rename timeout: %ds",
+			this.migrationContext.GetCutOverLockWaitTimeoutSeconds(), this.migrationContext.GetCutOverRenameTimeoutSeconds())
 		err = this.atomicCutOver()
 	case base.CutOverTwoStep:
 		err = this.cutOverTwoStep()
 	default:
 		return this.migrationContext.Log.Fatalf("Unknown cut-over type: %d; should never get here!", this.migrationContext.CutOverType)
 	}
+	if err != nil {
+		this.migrationContext.TransitionCutOverPhase(base.CutOverPhaseRolledBack)
+		this.migrationContext.Log.Infof("Cut-over attempt rolled back; %s", this.migrationContext.CutOverState.Summary())
+	} else {
+		this.migrationContext.TransitionCutOverPhase(base.CutOverPhaseDone)
+	}
 	this.handleCutOverResult(err)
 	return err
 }
 
+// autoUnpostpone tells whether cut-over may proceed despite the postpone flag file, as per --auto-unpostpone-when:
+// the criteria must be continuously met for --auto-unpostpone-stable-duration. Should the flag file be re-created
+// in the meantime, it wins, and auto-unpostpone is canceled.
+func (this *Migrator) autoUnpostpone(stableSince *time.Time) (bool, error) {
+	criteria := this.migrationContext.AutoUnpostponeCriteria
+	if criteria == nil || atomic.LoadInt64(&this.migrationContext.AutoUnpostponeCanceledFlag) > 0 {
+		return false, nil
+	}
+	if !stableSince.IsZero() {
+		if fileInfo, err := os.Stat(this.migrationContext.PostponeCutOverFlagFile); err == nil && fileInfo.ModTime().After(*stableSince) {
+			atomic.StoreInt64(&this.migrationContext.AutoUnpostponeCanceledFlag, 1)
+			this.migrationContext.Log.Infof("Auto-unpostpone canceled: %s re-created", this.migrationContext.PostponeCutOverFlagFile)
+			return false, nil
+		}
+	}
+	isThrottled, _, _ := this.migrationContext.IsThrottled()
+	metrics := &base.UnpostponeMetrics{
+		HeartbeatLag:    this.migrationContext.TimeSinceLastHeartbeatOnChangelog(),
+		Backlog:         int64(len(this.applyEventsQueue)),
+		CopyProgressPct: this.migrationContext.GetProgressPct(),
+		Throttled:       isThrottled,
+	}
+	if unmet := criteria.UnmetCriterion(metrics); unmet != nil {
+		if !stableSince.IsZero() {
+			this.migrationContext.Log.Infof("Auto-unpostpone: %s no longer met", unmet)
+		}
+		*stableSince = time.Time{}
+		return false, nil
+	}
+	if stableSince.IsZero() {
+		*stableSince = time.Now()
+		this.migrationContext.Log.Infof("Auto-unpostpone: %s met; cut-over proceeds once met for %+v", criteria, this.migrationContext.AutoUnpostponeStableDuration)
+	}
+	if time.Since(*stableSince) < this.migrationContext.AutoUnpostponeStableDuration {
+		return false, nil
+	}
+	this.migrationContext.Log.Infof("Auto-unpostpone: %s met for %+v; proceeding to cut-over as if %s were deleted", criteria, this.migrationContext.AutoUnpostponeStableDuration, this.migrationContext.PostponeCutOverFlagFile)
+	return true, this.hooksExecutor.onAutoUnpostponed()
+}
+
+// createGhostForeignKeys creates the original table's foreign keys on the ghost table, as per
+// --preserve-foreign-keys. This takes place just before the first cut-over attempt: up till then
+// the ghost table lags behind, and its foreign keys could fail deletes/updates on the parent tables.
+func (this *Migrator) createGhostForeignKeys() error {
+	if len(this.migrationContext.OriginalTableForeignKeys) == 0 || this.ghostForeignKeysCreated {
+		return nil
+	}
+	if err := this.hooksExecutor.onBeforeCreateForeignKeys(); err != nil {
+		return err
+	}
+	if err := this.applier.CreateGhostForeignKeys(); err != nil {
+		return err
+	}
+	this.ghostForeignKeysCreated = true
+	return nil
+}
+
+// reportCheckConstraintNames tells, following cut-over, which CHECK constraints of the migrated table are named
+// by their ghost names. MySQL cannot rename a constraint in place, and re-adding an enforced constraint validates
+// all rows; the statement restoring a name is given for the user to run at their discretion.
+func (this *Migrator) reportCheckConstraintNames() {
+	for _, originalConstraint := range this.migrationContext.OriginalTableCheckConstraints {
+		ghostName := originalConstraint.GhostName()
+		for _, newName := range this.migrationContext.GhostCheckConstraintRenames {
+			if newName != ghostName {
+				continue
+			}
+			restoreQuery, err := sql.BuildRenameCheckConstraintsQuery(this.migrationContext.GetTargetDatabaseName(), this.migrationContext.GetMigratedTableName(),
+				[](*sql.CheckConstraint){{Name: ghostName, Clause: originalConstraint.MapClause(this.migrationContext.ColumnRenameMap), Enforced: originalConstraint.Enforced}},
+				map[string]string{ghostName: originalConstraint.Name})
+			if err != nil {
+				continue
+			}
+			this.migrationContext.Log.Infof("CHECK constraint %s is named %s on the migrated table. Migrating the table again restores its name, as would, once the old table is dropped: %s",
+				sql.EscapeName(originalConstraint.Name), sql.EscapeName(ghostName), restoreQuery)
+		}
+	}
+}
+
+// repointForeignKeys completes --preserve-foreign-keys following cut-over: the migrated table now
+// has the foreign keys, and the old table's foreign keys are dropped.
+func (this *Migrator) repointForeignKeys() error {
+	if !this.ghostForeignKeysCreated {
+		return nil
+	}
+	if this.migrationContext.TestOnReplica {
+		// Tables were swapped back; the original table keeps its foreign keys
+		return nil
+	}
+	if err := this.retryOperation(this.applier.DropOldTableForeignKeys); err != nil {
+		this.migrationContext.Log.Errorf("Failed dropping foreign keys off old table %s.%s. Until dropped, its foreign keys restrict changes to the parent tables", sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.GetOldTableName()))
+		return err
+	}
+	return this.hooksExecutor.onForeignKeysRepointed()
+}
+
+// syncGhostAutoIncrement bumps the ghost table's AUTO_INCREMENT up to the original table's, within the
+// cut-over lock. An AUTO_INCREMENT value explicitly given by the -alter statement is kept as is.
+func (this *Migrator) syncGhostAutoIncrement() error {
+	if this.migrationContext.SkipAutoIncrementSync || this.parser.IsAutoIncrementDefined() {
+		return nil
+	}
+	return this.applier.SyncGhostAutoIncrement()
+}
+
+// createGhostTriggers creates the original table's triggers on the ghost table, as per --include-triggers.
+// This takes place within the cut-over lock, once all events up to the lock are applied.
+func (this *Migrator) createGhostTriggers() error {
+	if len(this.migrationContext.OriginalTableTriggers) == 0 {
+		return nil
+	}
+	if err := this.applier.CreateGhostTriggers(); err != nil {
+		this.dropGhostTriggers()
+		return err
+	}
+	return nil
+}
+
+// dropGhostTriggers drops the triggers off the ghost table following a failed cut-over: events
+// applied onto the ghost table must not fire them.
+func (this *Migrator) dropGhostTriggers() {
+	if len(this.migrationContext.OriginalTableTriggers) == 0 {
+		return
+	}
+	if err := this.retryOperation(this.applier.DropGhostTriggers); err != nil {
+		this.migrationContext.Log.Errore(err)
+	}
+}
+
+// renameTriggers gives the migrated table's triggers their original names back following cut-over.
+// Failing that, the triggers keep working under their ghost names, and the migration is still a success.
+func (this *Migrator) renameTriggers() {
+	if len(this.migrationContext.OriginalTableTriggers) == 0 {
+		return
+	}
+	if this.migrationContext.TestOnReplica {
+		// Tables were swapped back; the original table keeps its triggers
+		return
+	}
+	if err := this.retryOperation(this.applier.DropOldTableTriggers); err != nil {
+		this.migrationContext.Log.Warningf("Failed dropping triggers off old table %s.%s; the migrated table's triggers keep their ghost names: %+v", sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.GetOldTableName()), err)
+		return
+	}
+	if err := this.retryOperation(this.applier.RenameMigratedTableTriggers); err != nil {
+		this.migrationContext.Log.Warningf("Failed renaming triggers; the migrated table's triggers may keep their ghost names: %+v", err)
+	}
+}
+
 // Inject the "AllEventsUpToLockProcessed" state hint, wait for it to appear in the binary logs,
 // make sure the queue is drained.
 func (this *Migrator) waitForEventsUpToLock() (err error) {
@@ -623,16 +1052,40 @@ func (this *Migrator) cutOverTwoStep() (err error) {
 	defer atomic.StoreInt64(&this.migrationContext.InCutOverCriticalSectionFlag, 0)
 	atomic.StoreInt64(&this.migrationContext.AllEventsUpToLockProcessedInjectedFlag, 0)
 
+	if this.migrationContext.CutOverKillBlockingQueriesAfter > 0 {
+		// The singleton session locks and renames the tables alike
+		sessionId, err := this.applier.GetSingletonSessionId()
+		if err != nil {
+			return err
+		}
+		defer this.killBlockingQueries(sessionId)()
+	}
+	this.migrationContext.TransitionCutOverPhase(base.CutOverPhaseLocking)
 	if err := this.retryOperation(this.applier.LockOriginalTable); err != nil {
-		return err
+		return newCutOverError(base.CutOverLockTimeoutFailure, err)
 	}
 
+	this.migrationContext.TransitionCutOverPhase(base.CutOverPhaseWaitingForEvents)
 	if err := this.retryOperation(this.waitForEventsUpToLock); err != nil {
+		return newCutOverError(base.CutOverLockTimeoutFailure, err)
+	}
+	if err := this.checkCutOverRowCount(base.CutOverRowCountExact); err != nil {
+		if unlockErr := this.retryOperation(this.applier.UnlockTables); unlockErr != nil {
+			return unlockErr
+		}
 		return err
 	}
-	if err := this.retryOperation(this.applier.SwapTablesQuickAndBumpy); err != nil {
+	if err := this.syncGhostAutoIncrement(); err != nil {
+		return err
+	}
+	if err := this.createGhostTriggers(); err != nil {
 		return err
 	}
+	this.migrationContext.TransitionCutOverPhase(base.CutOverPhaseRenaming)
+	if err := this.retryOperation(this.applier.SwapTablesQuickAndBumpy); err != nil {
+		this.dropGhostTriggers()
+		return newCutOverError(base.CutOverRenameBlockedFailure, err)
+	}
 	if err := this.retryOperation(this.applier.UnlockTables); err != nil {
 		return err
 	}
@@ -655,6 +1108,7 @@ func (this *Migrator) atomicCutOver() (err error) {
 
 	atomic.StoreInt64(&this.migrationContext.AllEventsUpToLockProcessedInjectedFlag, 0)
 
+	this.migrationContext.TransitionCutOverPhase(base.CutOverPhaseCreatingSentry)
 	lockOriginalSessionIdChan := make(chan int64, 2)
 	tableLocked := make(chan error, 2)
 	tableUnlocked := make(chan error, 2)
@@ -663,20 +1117,63 @@ func (this *Migrator) atomicCutOver() (err error) {
 			this.migrationContext.Log.Errore(err)
 		}
 	}()
-	if err := <-tableLocked; err != nil {
-		return this.migrationContext.Log.Errore(err)
+	var lockOriginalSessionId int64
+	sessionIdKnown := false
+	if this.migrationContext.CutOverKillBlockingQueriesAfter > 0 {
+		// The session id is known ahead of the lock, such that sessions blocking the lock may be killed.
+		// The magic lock may fail before having a session id, hence the select.
+		select {
+		case lockOriginalSessionId = <-lockOriginalSessionIdChan:
+			sessionIdKnown = true
+			if lockOriginalSessionId > 0 {
+				stopKillingBlockingQueries := this.killBlockingQueries(lockOriginalSessionId)
+				err = <-tableLocked
+				stopKillingBlockingQueries()
+			} else {
+				err = <-tableLocked
+			}
+		case err = <-tableLocked:
+		}
+	} else {
+		err = <-tableLocked
+	}
+	if err != nil {
+		return newCutOverError(base.CutOverLockTimeoutFailure, this.migrationContext.Log.Errore(err))
+	}
+	if !sessionIdKnown {
+		lockOriginalSessionId = <-lockOriginalSessionIdChan
 	}
-	lockOriginalSessionId := <-lockOriginalSessionIdChan
 	this.migrationContext.Log.Infof("Session locking original & magic tables is %+v", lockOriginalSessionId)
 	// At this point we know the original table is locked.
 	// We know any newly incoming DML on original table is blocked.
+	this.migrationContext.TransitionCutOverPhase(base.CutOverPhaseWaitingForEvents)
 	if err := this.waitForEventsUpToLock(); err != nil {
+		return newCutOverError(base.CutOverLockTimeoutFailure, this.migrationContext.Log.Errore(err))
+	}
+	if err := this.checkCutOverRowCount(base.CutOverRowCountExact); err != nil {
+		// Release the lock ahead of returning, and so ahead of the mismatch hook
+		okToUnlockTable <- true
+		if unlockErr := <-tableUnlocked; unlockErr != nil {
+			this.migrationContext.Log.Errore(unlockErr)
+		}
+		return err
+	}
+	if err := this.syncGhostAutoIncrement(); err != nil {
 		return this.migrationContext.Log.Errore(err)
 	}
+	if err := this.createGhostTriggers(); err != nil {
+		return this.migrationContext.Log.Errore(err)
+	}
+	defer func() {
+		if err != nil {
+			this.dropGhostTriggers()
+		}
+	}()
 
 	// Step 2
 	// We now attempt an atomic RENAME on original & ghost tables, and expect it to block.
 	this.migrationContext.RenameTablesStartTime = time.Now()
+	this.migrationContext.TransitionCutOverPhase(base.CutOverPhaseRenaming)
 
 	var tableRenameKnownToHaveFailed int64
 	renameSessionIdChan := make(chan int64, 2)
@@ -690,6 +1187,8 @@ func (this *Migrator) atomicCutOver() (err error) {
 	}()
 	renameSessionId := <-renameSessionIdChan
 	this.migrationContext.Log.Infof("Session renaming tables is %+v", renameSessionId)
+	// Verify the RENAME is blocked on the lock, which is still held; then release the lock, upon which the RENAME completes
+	this.migrationContext.TransitionCutOverPhase(base.CutOverPhaseVerifying)
 
 	waitForRename := func() error {
 		if atomic.LoadInt64(&tableRenameKnownToHaveFailed) == 1 {
@@ -703,14 +1202,14 @@ func (this *Migrator) atomicCutOver() (err error) {
 	if err := this.retryOperation(waitForRename, true); err != nil {
 		// Abort! Release the lock
 		okToUnlockTable <- true
-		return err
+		return newCutOverError(base.CutOverSessionsNotFoundFailure, err)
 	}
 	if atomic.LoadInt64(&tableRenameKnownToHaveFailed) == 0 {
 		this.migrationContext.Log.Infof("Found atomic RENAME to be blocking, as expected. Double checking the lock is still in place (though I don't strictly have to)")
 	}
 	if err := this.applier.ExpectUsedLock(lockOriginalSessionId); err != nil {
 		// Abort operation. Just make sure to drop the magic table.
-		return this.migrationContext.Log.Errore(err)
+		return newCutOverError(base.CutOverSessionsNotFoundFailure, this.migrationContext.Log.Errore(err))
 	}
 	this.migrationContext.Log.Infof("Connection holding lock on original table still exists")
 
@@ -724,7 +1223,7 @@ func (this *Migrator) atomicCutOver() (err error) {
 		return this.migrationContext.Log.Errore(err)
 	}
 	if err := <-tablesRenamed; err != nil {
-		return this.migrationContext.Log.Errore(err)
+		return newCutOverError(base.CutOverRenameBlockedFailure, this.migrationContext.Log.Errore(err))
 	}
 	this.migrationContext.RenameTablesEndTime = time.Now()
 
@@ -739,7 +1238,7 @@ func (this *Migrator) initiateServer() (err error) {
 	var f printStatusFunc = func(rule PrintStatusRule, writer io.Writer) {
 		this.printStatus(rule, writer)
 	}
-	this.server = NewServer(this.migrationContext, this.hooksExecutor, f)
+	this.server = NewServer(this.migrationContext, this.hooksExecutor, f, this.rehearseCutOver)
 	if err := this.server.BindSocketFile(); err != nil {
 		return err
 	}
@@ -771,6 +1270,22 @@ func (this *Migrator) initiateInspector() (err error) {
 	}
 	// So far so good, table is accessible and valid.
 	// Let's get master connection config
+	if err := this.initiateApplierConnectionConfig(); err != nil {
+		return err
+	}
+	if this.migrationContext.InspectorIsAlsoApplier() && !this.migrationContext.AllowedRunningOnMaster && !this.migrationContext.TestOnReplica && !this.migrationContext.MigrateOnReplica {
+		return fmt.Errorf("It seems like this migration attempt to run directly on master. Preferably it would be executed on a replica (and this reduces load from the master). To proceed please provide --allow-on-master. Inspector config=%+v, applier config=%+v", this.migrationContext.InspectorConnectionConfig, this.migrationContext.ApplierConnectionConfig)
+	}
+	if err := this.inspector.validateLogSlaveUpdates(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// initiateApplierConnectionConfig detects the master, or uses the one given, as the applier; or, with
+// --test-on-replica and --migrate-on-replica, the replica we connect to
+func (this *Migrator) initiateApplierConnectionConfig() (err error) {
 	if this.migrationContext.AssumeMasterHostname == "" {
 		// No forced master host; detect master
 		if this.migrationContext.ApplierConnectionConfig, err = this.inspector.getMasterConnectionConfig(); err != nil {
@@ -804,13 +1319,7 @@ func (this *Migrator) initiateInspector() (err error) {
 		if this.migrationContext.GetThrottleControlReplicaKeys().Len() == 0 {
 			this.migrationContext.AddThrottleControlReplicaKey(this.migrationContext.InspectorConnectionConfig.Key)
 		}
-	} else if this.migrationContext.InspectorIsAlsoApplier() && !this.migrationContext.AllowedRunningOnMaster {
-		return fmt.Errorf("It seems like this migration attempt to run directly on master. Preferably it would be executed on a replica (and this reduces load from the master). To proceed please provide --allow-on-master. Inspector config=%+v, applier config=%+v", this.migrationContext.InspectorConnectionConfig, this.migrationContext.ApplierConnectionConfig)
-	}
-	if err := this.inspector.validateLogSlaveUpdates(); err != nil {
-		return err
 	}
-
 	return nil
 }
 
@@ -837,9 +1346,17 @@ func (this *Migrator) printMigrationStatusHint(writers ...io.Writer) {
 	fmt.Fprintf(w, "# Migrating %s.%s; Ghost table is %s.%s\n",
 		sql.EscapeName(this.migrationContext.DatabaseName),
 		sql.EscapeName(this.migrationContext.OriginalTableName),
-		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(this.migrationContext.GetTargetDatabaseName()),
 		sql.EscapeName(this.migrationContext.GetGhostTableName()),
 	)
+	if this.migrationContext.IsTableRenamed() {
+		fmt.Fprintf(w, "# Renaming at cut-over: %s.%s becomes %s.%s\n",
+			sql.EscapeName(this.migrationContext.DatabaseName),
+			sql.EscapeName(this.migrationContext.OriginalTableName),
+			sql.EscapeName(this.migrationContext.GetTargetDatabaseName()),
+			sql.EscapeName(this.migrationContext.GetMigratedTableName()),
+		)
+	}
 	fmt.Fprintf(w, "# Migrating %+v; inspecting %+v; executing on %+v\n",
 		*this.applier.connectionConfig.ImpliedKey,
 		*this.inspector.connectionConfig.ImpliedKey,
@@ -848,6 +1365,9 @@ func (this *Migrator) printMigrationStatusHint(writers ...io.Writer) {
 	fmt.Fprintf(w, "# Migration started at %+v\n",
 		this.migrationContext.StartTime.Format(time.RubyDate),
 	)
+	fmt.Fprintf(w, "# Applier sql_mode: '%s'\n",
+		this.migrationContext.ApplierSQLMode,
+	)
 	maxLoad := this.migrationContext.GetMaxLoad()
 	criticalLoad := this.migrationContext.GetCriticalLoad()
 	fmt.Fprintf(w, "# chunk-size: %+v; max-lag-millis: %+vms; dml-batch-size: %+v; max-load: %s; critical-load: %s; nice-ratio: %f\n",
@@ -858,6 +1378,49 @@ func (this *Migrator) printMigrationStatusHint(writers ...io.Writer) {
 		criticalLoad.String(),
 		this.migrationContext.GetNiceRatio(),
 	)
+	if maxHistoryListLength := atomic.LoadInt64(&this.migrationContext.MaxHistoryListLength); maxHistoryListLength > 0 {
+		fmt.Fprintf(w, "# max-history-list-length: %s; current: %s\n",
+			base.PrettifyThousands(maxHistoryListLength),
+			base.PrettifyThousands(atomic.LoadInt64(&this.migrationContext.CurrentHistoryListLength)),
+		)
+	}
+	if this.migrationContext.IsDiskSpaceCheckEnabled() {
+		fmt.Fprintf(w, "# free-disk-space: %s; min-free-disk-space: %s; critical-free-disk-space: %s\n",
+			base.DescribeFreeDiskSpace(atomic.LoadInt64(&this.migrationContext.CurrentFreeDiskSpace), atomic.LoadInt64(&this.migrationContext.CurrentTotalDiskSpace)),
+			this.migrationContext.MinFreeDiskSpace,
+			this.migrationContext.CriticalFreeDiskSpace,
+		)
+	}
+	if this.migrationContext.MaxDMLBacklog > 0 || this.migrationContext.MaxEventsLag > 0 {
+		fmt.Fprintf(w, "# max-dml-backlog: %d; max-events-lag: %+v; events-lag: %+v\n",
+			this.migrationContext.MaxDMLBacklog, this.migrationContext.MaxEventsLag, this.getEventsLag().Round(time.Second),
+		)
+	}
+	if throttleSchedule := this.migrationContext.GetThrottleSchedule(); len(throttleSchedule) > 0 {
+		fmt.Fprintf(w, "# throttle-schedule: %s (%s); apply events: %t\n",
+			throttleSchedule, this.migrationContext.ThrottleScheduleLocation, this.migrationContext.ThrottleScheduleApplyEvents,
+		)
+	}
+	if rampUpDuration := this.migrationContext.ThrottleRampUp.GetDuration(); rampUpDuration > 0 {
+		fmt.Fprintf(w, "# throttle-ramp-up-duration: %+v; throttle-ramp-up-threshold: %+v\n",
+			rampUpDuration,
+			this.migrationContext.ThrottleRampUp.GetThreshold(),
+		)
+	}
+	if copyRate, dmlApplyRate := this.migrationContext.CopyRateLimiter.GetRate(), this.migrationContext.DMLApplyRateLimiter.GetRate(); copyRate > 0 || dmlApplyRate > 0 {
+		fmt.Fprintf(w, "# max-copy-rows-per-second: %+v; max-dml-apply-rows-per-second: %+v\n",
+			copyRate,
+			dmlApplyRate,
+		)
+	}
+	if this.migrationContext.AdaptiveChunkSize {
+		chunkSizeMin, chunkSizeMax := this.migrationContext.GetChunkSizeBounds()
+		fmt.Fprintf(w, "# adaptive-chunk-size: chunk-latency-target: %+v; chunk-size-min: %+v; chunk-size-max: %+v\n",
+			this.migrationContext.GetChunkLatencyTarget(),
+			chunkSizeMin,
+			chunkSizeMax,
+		)
+	}
 	if this.migrationContext.ThrottleFlagFile != "" {
 		setIndicator := ""
 		if base.FileExists(this.migrationContext.ThrottleFlagFile) {
@@ -867,13 +1430,30 @@ func (this *Migrator) printMigrationStatusHint(writers ...io.Writer) {
 			this.migrationContext.ThrottleFlagFile, setIndicator,
 		)
 	}
-	if this.migrationContext.ThrottleAdditionalFlagFile != "" {
+	for _, flagFile := range this.migrationContext.GetThrottleAdditionalFlagFiles() {
 		setIndicator := ""
-		if base.FileExists(this.migrationContext.ThrottleAdditionalFlagFile) {
+		if base.FileExists(flagFile) {
 			setIndicator = "[set]"
 		}
 		fmt.Fprintf(w, "# throttle-additional-flag-file: %+v %+v\n",
-			this.migrationContext.ThrottleAdditionalFlagFile, setIndicator,
+			flagFile, setIndicator,
+		)
+	}
+	if this.migrationContext.ThrottleFlagFileDir != "" {
+		setFiles := ""
+		if entries, err := ioutil.ReadDir(this.migrationContext.ThrottleFlagFileDir); err == nil {
+			names := []string{}
+			for _, entry := range entries {
+				if !entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+					names = append(names, entry.Name())
+				}
+			}
+			if len(names) > 0 {
+				setFiles = fmt.Sprintf("[set: %s]", strings.Join(names, ", "))
+			}
+		}
+		fmt.Fprintf(w, "# throttle-flag-file-dir: %+v %+v\n",
+			this.migrationContext.ThrottleFlagFileDir, setFiles,
 		)
 	}
 	if throttleQuery := this.migrationContext.GetThrottleQuery(); throttleQuery != "" {
@@ -881,10 +1461,30 @@ func (this *Migrator) printMigrationStatusHint(writers ...io.Writer) {
 			throttleQuery,
 		)
 	}
+	if totalThrottled, throttleDurations := this.migrationContext.GetThrottleDurations(); totalThrottled > 0 {
+		fmt.Fprintf(w, "# throttled: %+v; top reasons: %s\n",
+			totalThrottled.Round(time.Second), base.DescribeThrottleDurations(throttleDurations, 3),
+		)
+	}
 	if throttleControlReplicaKeys := this.migrationContext.GetThrottleControlReplicaKeys(); throttleControlReplicaKeys.Len() > 0 {
-		fmt.Fprintf(w, "# throttle-control-replicas count: %+v\n",
+		fmt.Fprintf(w, "# throttle-control-replicas count: %+v; check-load-on-replicas: %t\n",
 			throttleControlReplicaKeys.Len(),
+			this.migrationContext.CheckLoadOnReplicas,
 		)
+		for _, lagResult := range this.migrationContext.GetControlReplicasLagResults() {
+			lag := fmt.Sprintf("%.3fs", lagResult.Lag.Seconds())
+			if lagResult.Err != nil {
+				lag = fmt.Sprintf("error: %+v", lagResult.Err)
+			} else if lagResult.SkipReason != "" {
+				lag = fmt.Sprintf("skipped, %s", lagResult.SkipReason)
+			}
+			fmt.Fprintf(w, "# throttle-control-replica %+v: lag: %s; max-lag: %+v\n",
+				lagResult.Key, lag, this.migrationContext.GetThrottleControlReplicaMaxLag(lagResult.Key),
+			)
+		}
+		if ignored := this.migrationContext.DescribeIgnoredThrottleControlReplicas(); ignored != "" {
+			fmt.Fprintf(w, "# throttle-control-replicas ignored: %s\n", ignored)
+		}
 	}
 
 	if this.migrationContext.PostponeCutOverFlagFile != "" {
@@ -896,11 +1496,89 @@ func (this *Migrator) printMigrationStatusHint(writers ...io.Writer) {
 			this.migrationContext.PostponeCutOverFlagFile, setIndicator,
 		)
 	}
+	if this.migrationContext.CutOverWindow != nil {
+		fmt.Fprintf(w, "# cut-over-window: %s\n", this.migrationContext.CutOverWindow)
+	}
+	if this.migrationContext.AutoUnpostponeCriteria != nil {
+		canceledIndicator := ""
+		if atomic.LoadInt64(&this.migrationContext.AutoUnpostponeCanceledFlag) > 0 {
+			canceledIndicator = "[canceled]"
+		}
+		fmt.Fprintf(w, "# auto-unpostpone-when: %s; stable duration: %+v %s\n",
+			this.migrationContext.AutoUnpostponeCriteria, this.migrationContext.AutoUnpostponeStableDuration, canceledIndicator,
+		)
+	}
+	if cutOverAttempts := atomic.LoadInt64(&this.migrationContext.CutOverAttempts); cutOverAttempts > 0 {
+		failures := []string{}
+		for _, reason := range this.migrationContext.GetCutOverFailures() {
+			failures = append(failures, string(reason))
+		}
+		fmt.Fprintf(w, "# cut-over attempts: %d; vetoed: %d; failed: %d (%s)\n",
+			cutOverAttempts, atomic.LoadInt64(&this.migrationContext.CutOverVetoes), len(failures), strings.Join(failures, ", "),
+		)
+		fmt.Fprintf(w, "# %s\n", this.migrationContext.CutOverState.Summary())
+	}
+	if this.migrationContext.CutOverKillBlockingQueriesAfter > 0 {
+		mode := "kill"
+		if this.migrationContext.CutOverKillBlockingQueriesDryRun {
+			mode = "dry run"
+		}
+		fmt.Fprintf(w, "# cut-over-kill-blocking-queries-after: %+v (%s); killed: %d\n",
+			this.migrationContext.CutOverKillBlockingQueriesAfter, mode, atomic.LoadInt64(&this.migrationContext.CutOverKilledBlockingQueries),
+		)
+	}
 	if this.migrationContext.PanicFlagFile != "" {
 		fmt.Fprintf(w, "# panic-flag-file: %+v\n",
 			this.migrationContext.PanicFlagFile,
 		)
 	}
+	if this.migrationContext.ChunkChecksumSampleRatio > 0 {
+		fmt.Fprintf(w, "# verify-chunk-checksums: %+v; chunks verified: %d; mismatched: %d\n",
+			this.migrationContext.ChunkChecksumSampleRatio,
+			atomic.LoadInt64(&this.migrationContext.ChunkChecksumsVerified),
+			atomic.LoadInt64(&this.migrationContext.ChunkChecksumMismatches),
+		)
+	}
+	fmt.Fprintf(w, "# Rows events: %d streamed, %d skipped (other tables)\n",
+		atomic.LoadInt64(&this.migrationContext.StreamedRowsEvents),
+		atomic.LoadInt64(&this.migrationContext.SkippedRowsEvents),
+	)
+	if this.migrationContext.AuditDMLFile != "" {
+		fmt.Fprintf(w, "# audit-dml-file: %+v; dropped lines: %d\n",
+			this.migrationContext.AuditDMLFile,
+			atomic.LoadInt64(&this.migrationContext.AuditDMLDroppedLines),
+		)
+	}
+	eventsBufferDepth, eventsBufferCapacity := this.eventsStreamer.GetEventsBufferDepth()
+	fmt.Fprintf(w, "# Binlog events buffer: %d/%d; high-water mark: %d\n",
+		eventsBufferDepth, eventsBufferCapacity,
+		atomic.LoadInt64(&this.migrationContext.BinlogEventsBufferHighWaterMark),
+	)
+	streamerThroughput := this.migrationContext.GetStreamerThroughput()
+	fmt.Fprintf(w, "# Streamer: %s events/s, %s/s, %s rows/s, blocked %.0f%%\n",
+		base.PrettifyCount(streamerThroughput.EventsPerSecond),
+		base.PrettifyBytes(streamerThroughput.BytesPerSecond),
+		base.PrettifyCount(streamerThroughput.RowsPerSecond),
+		streamerThroughput.BlockedRatio*100,
+	)
+	var applyTimePerEvent time.Duration
+	if appliedEvents := atomic.LoadInt64(&this.migrationContext.TotalDMLEventsApplied); appliedEvents > 0 {
+		applyTimePerEvent = time.Duration(atomic.LoadInt64(&this.migrationContext.DMLEventsApplyNanos) / appliedEvents)
+	}
+	dmlRetries := atomic.LoadInt64(&this.migrationContext.DMLRetries)
+	if this.applier != nil && this.applier.dmlStatements != nil {
+		hits := atomic.LoadInt64(&this.migrationContext.DMLPreparedStatementHits)
+		misses := atomic.LoadInt64(&this.migrationContext.DMLPreparedStatementMisses)
+		var hitRatio float64
+		if hits+misses > 0 {
+			hitRatio = float64(hits) / float64(hits+misses)
+		}
+		fmt.Fprintf(w, "# Applier using prepared statements: %d cached, hit rate %.1f%%; %+v per applied event; DML retries: %d\n",
+			this.applier.dmlStatements.size(), hitRatio*100, applyTimePerEvent, dmlRetries,
+		)
+	} else {
+		fmt.Fprintf(w, "# Applier not using prepared statements; %+v per applied event; DML retries: %d\n", applyTimePerEvent, dmlRetries)
+	}
 	fmt.Fprintf(w, "# Serving on unix socket: %+v\n",
 		this.migrationContext.ServeSocketFile,
 	)
@@ -977,11 +1655,23 @@ func (this *Migrator) printStatus(rule PrintStatusRule, writers ...io.Writer) {
 	state := "migrating"
 	if atomic.LoadInt64(&this.migrationContext.CountingRowsFlag) > 0 && !this.migrationContext.ConcurrentCountTableRows {
 		state = "counting rows"
+	} else if atomic.LoadInt64(&this.migrationContext.IsWaitingForCutOverWindow) > 0 {
+		eta = "due"
+		state = fmt.Sprintf("ready to cut-over, waiting for window, next window in %s", base.PrettifyDurationOutput(this.migrationContext.CutOverWindow.Until(time.Now())))
 	} else if atomic.LoadInt64(&this.migrationContext.IsPostponingCutOver) > 0 {
 		eta = "due"
 		state = "postponing cut-over"
-	} else if isThrottled, throttleReason, _ := this.migrationContext.IsThrottled(); isThrottled {
+	} else if isThrottled, throttleReason, throttleReasonHint := this.migrationContext.IsThrottled(); isThrottled && throttleReasonHint == base.ScheduleThrottleReasonHint {
+		state = fmt.Sprintf("throttled (%s)", throttleReason)
+	} else if isThrottled {
 		state = fmt.Sprintf("throttled, %s", throttleReason)
+	} else if pauseReason := this.rowCopyPauseReason(); pauseReason != "" && atomic.LoadInt64(&this.rowCopyCompleteFlag) == 0 {
+		state = fmt.Sprintf("copy paused, draining backlog (%s)", pauseReason)
+	} else if factor, rampingUp := this.migrationContext.ThrottleRampUp.Factor(time.Now()); rampingUp {
+		state = fmt.Sprintf("ramping up, %.0f%%", factor*100)
+	}
+	if escalation := this.migrationContext.CriticalLoadEscalation.Describe(time.Now()); escalation != "" {
+		state = fmt.Sprintf("%s; %s", state, escalation)
 	}
 
 	shouldPrintStatus := false
@@ -1008,15 +1698,45 @@ func (this *Migrator) printStatus(rule PrintStatusRule, writers ...io.Writer) {
 	}
 
 	currentBinlogCoordinates := *this.eventsStreamer.GetCurrentBinlogCoordinates()
+	streamerStatus := currentBinlogCoordinates.DisplayString()
+	if len(this.migrationContext.StreamerFailoverKeys) > 0 {
+		streamerStatus = fmt.Sprintf("%s %s", this.eventsStreamer.GetStreamingKey().DisplayString(), streamerStatus)
+	}
+	if currentBinlogCoordinates.HasExecutedGtidSet() {
+		streamerStatus = fmt.Sprintf("%s (gtid: %s)", streamerStatus, currentBinlogCoordinates.ExecutedGtidSet)
+	}
 
-	status := fmt.Sprintf("Copy: %d/%d %.1f%%; Applied: %d; Backlog: %d/%d; Time: %+v(total), %+v(copy); streamer: %+v; Lag: %.2fs, HeartbeatLag: %.2fs, State: %s; ETA: %s",
+	chunkIndexName := ""
+	if this.migrationContext.UsingSurrogateKey {
+		chunkIndexName = "(surrogate)"
+	} else if this.migrationContext.UniqueKey != nil {
+		chunkIndexName = this.migrationContext.UniqueKey.Name
+	}
+	rateLimitStatus := func(limiter *base.RateLimiter) string {
+		if rate := limiter.GetRate(); rate > 0 {
+			return fmt.Sprintf(", rate limited to %d rows/s (currently %.0f)", rate, limiter.GetCurrentRate())
+		}
+		return ""
+	}
+	examinedStatus := ""
+	if this.migrationContext.ShouldCountRowsExamined() {
+		examinedStatus = fmt.Sprintf("; Examined: %d", this.migrationContext.GetTotalRowsExamined())
+	}
+	status := fmt.Sprintf("Copy: %d/%d %.1f%%%s%s; Index: %s; Chunk: %d; Applied: %d%s; Backlog: %d/%d (%d trx); Pool: %s; Time: %+v(total), %+v(copy); streamer: %+v; Lag: %.2fs, HeartbeatLag: %.2fs, StreamerLag: %.2fs, State: %s; ETA: %s",
 		totalRowsCopied, rowsEstimate, progressPct,
+		rateLimitStatus(this.migrationContext.CopyRateLimiter),
+		examinedStatus,
+		chunkIndexName,
+		atomic.LoadInt64(&this.migrationContext.ChunkSize),
 		atomic.LoadInt64(&this.migrationContext.TotalDMLEventsApplied),
-		len(this.applyEventsQueue), cap(this.applyEventsQueue),
+		rateLimitStatus(this.migrationContext.DMLApplyRateLimiter),
+		len(this.applyEventsQueue), cap(this.applyEventsQueue), atomic.LoadInt64(&this.bufferedTransactions),
+		this.applier.GetPoolStats(),
 		base.PrettifyDurationOutput(elapsedTime), base.PrettifyDurationOutput(this.migrationContext.ElapsedRowCopyTime()),
-		currentBinlogCoordinates,
+		streamerStatus,
 		this.migrationContext.GetCurrentLagDuration().Seconds(),
 		this.migrationContext.TimeSinceLastHeartbeatOnChangelog().Seconds(),
+		this.eventsStreamer.GetStreamerLag().Seconds(),
 		state,
 		eta,
 	)
@@ -1047,11 +1767,24 @@ func (this *Migrator) initiateStreaming() error {
 		func(dmlEvent *binlog.BinlogDMLEvent) error {
 			return this.onChangelogEvent(dmlEvent)
 		},
+		nil,
 	)
 
+	if this.migrationContext.Resume {
+		// Events following the checkpoint are applied, however early they are streamed
+		if err := this.addDMLEventsListener(); err != nil {
+			return err
+		}
+		this.checkpointBinlogCoordinates = this.resumeCheckpoint.GetBinlogCoordinates()
+	} else if this.migrationContext.StartGtidSet == "" {
+		this.checkpointBinlogCoordinates = *this.eventsStreamer.initialBinlogCoordinates
+	}
+
+	streamingContext, cancelStreaming := context.WithCancel(context.Background())
+	this.cancelStreaming = cancelStreaming
 	go func() {
 		this.migrationContext.Log.Debugf("Beginning streaming")
-		err := this.eventsStreamer.StreamEvents(this.canStopStreaming)
+		err := this.eventsStreamer.StreamEvents(streamingContext)
 		if err != nil {
 			this.migrationContext.PanicAbort <- err
 		}
@@ -1065,6 +1798,9 @@ func (this *Migrator) initiateStreaming() error {
 				return
 			}
 			this.migrationContext.SetRecentBinlogCoordinates(*this.eventsStreamer.GetCurrentBinlogCoordinates())
+			this.migrationContext.SampleStreamerThroughput()
+			this.eventsStreamer.warnOnBlockedReader()
+			this.eventsStreamer.detectStalledStream()
 		}
 	}()
 	return nil
@@ -1081,19 +1817,26 @@ func (this *Migrator) addDMLEventsListener() error {
 			this.applyEventsQueue <- newApplyEventStructByDML(dmlEvent)
 			return nil
 		},
+		func() error {
+			atomic.AddInt64(&this.bufferedTransactions, 1)
+			this.applyEventsQueue <- newApplyEventStructByTransactionEnd()
+			return nil
+		},
 	)
 	return err
 }
 
 // initiateThrottler kicks in the throttling collection and the throttling checks.
 func (this *Migrator) initiateThrottler() error {
-	this.throttler = NewThrottler(this.migrationContext, this.applier, this.inspector, this.appVersion)
+	this.throttler = NewThrottler(this.migrationContext, this.applier, this.inspector, this.eventsStreamer, this.hooksExecutor, this.appVersion)
 
 	go this.throttler.initiateThrottlerCollection(this.firstThrottlingCollected)
 	this.migrationContext.Log.Infof("Waiting for first throttle metrics to be collected")
 	<-this.firstThrottlingCollected // replication lag
+	<-this.firstThrottlingCollected // control replicas lag
 	<-this.firstThrottlingCollected // HTTP status
-	<-this.firstThrottlingCollected // other, general metrics
+	<-this.firstThrottlingCollected // load
+	<-this.firstThrottlingCollected // flag files
 	this.migrationContext.Log.Infof("First throttle metrics collected")
 	go this.throttler.initiateThrottlerChecks()
 
@@ -1108,6 +1851,16 @@ func (this *Migrator) initiateApplier() error {
 	if err := this.applier.ValidateOrDropExistingTables(); err != nil {
 		return err
 	}
+	if this.migrationContext.CutOverKillBlockingQueriesAfter > 0 {
+		if err := this.applier.ValidateMetadataLocksInstrumented(); err != nil {
+			return err
+		}
+	}
+	if this.migrationContext.Resume {
+		// Ghost and changelog tables are those of the interrupted migration
+		go this.applier.InitiateHeartbeat()
+		return nil
+	}
 	if err := this.applier.CreateChangelogTable(); err != nil {
 		this.migrationContext.Log.Errorf("Unable to create changelog table, see further error details. Perhaps a previous migration failed without dropping the table? OR is there a running migration? Bailing out")
 		return err
@@ -1135,6 +1888,40 @@ func (this *Migrator) initiateApplier() error {
 	return nil
 }
 
+// initiateDMLApplyWorkers starts concurrent workers applying DML events, as per --dml-apply-concurrency.
+// Events are sharded by their unique key values, and so the unique key must be the sole unique key
+// of the ghost table, or else events of different rows might conflict. Textual columns are excluded
+// from sharding, since their collation may equate different values.
+func (this *Migrator) initiateDMLApplyWorkers() {
+	concurrency := this.migrationContext.DMLApplyConcurrency
+	if concurrency <= 1 {
+		return
+	}
+	if this.migrationContext.UsingSurrogateKey {
+		this.migrationContext.Log.Warningf("--dml-apply-concurrency: rows are matched by all columns, per --surrogate-key. Applying DML events serially")
+		return
+	}
+	if len(this.migrationContext.GhostTableUniqueKeys) > 1 {
+		this.migrationContext.Log.Warningf("--dml-apply-concurrency: ghost table has %d unique keys, on which concurrently applied events may conflict. Applying DML events serially", len(this.migrationContext.GhostTableUniqueKeys))
+		return
+	}
+	var shardColumnNames []string
+	var shardOrdinals []int
+	for _, column := range this.migrationContext.UniqueKey.Columns.Columns() {
+		if column.Charset != "" && column.Charset != "binary" {
+			continue
+		}
+		shardColumnNames = append(shardColumnNames, column.Name)
+		shardOrdinals = append(shardOrdinals, this.migrationContext.OriginalTableColumns.Ordinals[column.Name])
+	}
+	if len(shardOrdinals) == 0 {
+		this.migrationContext.Log.Warningf("--dml-apply-concurrency: unique key %s only has textual columns. Applying DML events serially", this.migrationContext.UniqueKey.Name)
+		return
+	}
+	this.migrationContext.Log.Infof("Applying DML events with %d workers, sharded by %s", concurrency, strings.Join(shardColumnNames, ", "))
+	this.dmlApplyWorkers = newDMLApplyWorkers(this.migrationContext, int(concurrency), shardOrdinals, this.applyDMLEvents)
+}
+
 // iterateChunks iterates the existing table rows, and generates a copy task of
 // a chunk of rows onto the ghost table.
 func (this *Migrator) iterateChunks() error {
@@ -1175,11 +1962,25 @@ func (this *Migrator) iterateChunks() error {
 			}); err != nil {
 				return terminateRowIteration(err)
 			}
+			if !hasFurtherRange && len(this.migrationContext.OriginalTablePartitions) > 0 {
+				// Done with this partition; the next one, if any, is copied by the next copy task
+				hasFurtherPartition := false
+				if err := this.retryOperation(func() (e error) {
+					hasFurtherPartition, e = this.applier.ReadNextPartitionRangeValues()
+					return e
+				}); err != nil {
+					return terminateRowIteration(err)
+				}
+				if hasFurtherPartition {
+					return nil
+				}
+			}
 			if !hasFurtherRange {
 				atomic.StoreInt64(&hasNoFurtherRangeFlag, 1)
 				return terminateRowIteration(nil)
 			}
 			// Copy task:
+			var abortErr error
 			applyCopyRowsFunc := func() error {
 				if atomic.LoadInt64(&this.rowCopyCompleteFlag) == 1 {
 					// No need for more writes.
@@ -1192,26 +1993,213 @@ func (this *Migrator) iterateChunks() error {
 					// _ghost_ table, which no longer exists. So, bothering error messages and all, but no damage.
 					return nil
 				}
-				_, rowsAffected, _, err := this.applier.ApplyIterationInsertQuery()
+				verifyChecksum := this.migrationContext.ShouldVerifyChunkChecksum(this.migrationContext.GetIteration())
+				if isThrottled, _, _ := this.migrationContext.IsThrottled(); verifyChecksum && isThrottled {
+					// Verification adds load; not while throttled
+					this.migrationContext.Log.Debugf("Skipping chunk checksum verification while throttled")
+					verifyChecksum = false
+				}
+				_, rowsAffected, rowsExamined, duration, err := this.applier.ApplyIterationInsertQuery(verifyChecksum)
+				var mismatch *ChunkChecksumMismatchError
+				if errors.As(err, &mismatch) {
+					// The chunk is copied nonetheless
+					if this.migrationContext.ChunkChecksumWarnOnly {
+						this.migrationContext.Log.Warningf("%s", mismatch)
+					} else {
+						abortErr = fmt.Errorf("%s; per --verify-chunk-checksums", mismatch)
+					}
+					err = nil
+				}
 				if err != nil {
+					if this.migrationContext.CopyConflictStrategy == sql.FailCopyConflictStrategy && mysql.IsDuplicateKeyError(err) {
+						// A conflict fails again on retry
+						abortErr = fmt.Errorf("Row copy found a conflicting row on the ghost table, per --copy-conflict-strategy=fail: %+v", err)
+						return nil
+					}
 					return err // wrapping call will retry
 				}
-				atomic.AddInt64(&this.migrationContext.TotalRowsCopied, rowsAffected)
+				rowsCopied := rowsAffected
+				if this.migrationContext.CopyConflictStrategy == sql.ReplaceCopyConflictStrategy {
+					// A replaced row counts as two affected rows
+					rowsCopied = rowsExamined
+				}
+				atomic.AddInt64(&this.migrationContext.TotalRowsCopied, rowsCopied)
+				this.migrationContext.CopyRateLimiter.Take(rowsCopied)
+				atomic.AddInt64(&this.migrationContext.TotalRowsExamined, rowsExamined)
 				atomic.AddInt64(&this.migrationContext.Iteration, 1)
+				this.chunkDurations.Observe(duration.Seconds())
+				if this.migrationContext.AdaptiveChunkSize && !verifyChecksum {
+					// duration is that of the copy's transaction alone: neither throttling nor retries count.
+					// A verified chunk's duration includes its checksum, and does not count either.
+					this.migrationContext.AdjustChunkSize(duration)
+				}
+				if interval := this.migrationContext.CheckpointIntervalChunks; interval > 0 && this.migrationContext.GetIteration()%interval == 0 {
+					this.writeCheckpoint()
+				}
 				return nil
 			}
 			if err := this.retryOperation(applyCopyRowsFunc); err != nil {
 				return terminateRowIteration(err)
 			}
+			if abortErr != nil {
+				return terminateRowIteration(abortErr)
+			}
 			return nil
 		}
+		// Per --max-copy-rows-per-second. Waiting here rather than within executeWriteFuncs() keeps applying DML events meanwhile
+		this.migrationContext.CopyRateLimiter.Wait()
 		// Enqueue copy operation; to be executed by executeWriteFuncs()
 		this.copyRowsQueue <- copyRowsFunc
 	}
 }
 
+// readResumeCheckpoint reads the checkpoint of the interrupted migration --resume continues, and
+// validates this migration is that same migration
+func (this *Migrator) readResumeCheckpoint() error {
+	value, err := this.inspector.readChangelogState(checkpointChangelogHint)
+	if err != nil {
+		return fmt.Errorf("--resume: cannot read checkpoint from changelog table %s: %+v", sql.EscapeName(this.migrationContext.GetChangelogTableName()), err)
+	}
+	if value == "" {
+		return fmt.Errorf("--resume: no checkpoint found on changelog table %s. The interrupted migration copied no rows, or ran with --checkpoint-interval-chunks=0", sql.EscapeName(this.migrationContext.GetChangelogTableName()))
+	}
+	checkpoint, err := ParseCheckpoint(value)
+	if err != nil {
+		return err
+	}
+	if err := checkpoint.ValidateAlterStatement(this.migrationContext); err != nil {
+		return err
+	}
+	this.migrationContext.Log.Infof("Resuming from checkpoint: %s", checkpoint)
+	this.resumeCheckpoint = checkpoint
+	coordinates := checkpoint.GetBinlogCoordinates()
+	this.migrationContext.ResumeBinlogCoordinates = &coordinates
+	return nil
+}
+
+// writeCheckpoint writes row copy progress onto the changelog table, up to the chunk just copied.
+// It runs within executeWriteFuncs(), in between applying DML events, so that all DML events handed
+// over for applying are applied once apply workers are flushed. A failed checkpoint is not fatal.
+func (this *Migrator) writeCheckpoint() {
+	if this.checkpointBinlogCoordinates.IsEmpty() || this.migrationContext.BinlogFile != "" {
+		return
+	}
+	if this.migrationContext.UsingSurrogateKey {
+		// --resume does not support --surrogate-key
+		return
+	}
+	if this.dmlApplyWorkers != nil {
+		if err := this.dmlApplyWorkers.flush(); err != nil {
+			this.migrationContext.Log.Warningf("Cannot write checkpoint: %+v", err)
+			return
+		}
+	}
+	checkpoint, err := NewCheckpoint(this.migrationContext, this.checkpointBinlogCoordinates)
+	if err != nil {
+		this.migrationContext.Log.Warningf("Cannot write checkpoint: %+v", err)
+		return
+	}
+	value, err := checkpoint.ToJSON()
+	if err == nil {
+		_, err = this.applier.WriteChangelog(checkpointChangelogHint, value)
+	}
+	if err != nil {
+		this.migrationContext.Log.Warningf("Cannot write checkpoint: %+v", err)
+		return
+	}
+	this.migrationContext.Log.Debugf("Wrote checkpoint: %s", checkpoint)
+}
+
+// nextApplyEventStruct waits for the next struct in applyEventsQueue, unless the migration is finished
+func (this *Migrator) nextApplyEventStruct() (*applyEventStruct, error) {
+	for {
+		select {
+		case eventStruct := <-this.applyEventsQueue:
+			return eventStruct, nil
+		case <-time.After(time.Second):
+			if atomic.LoadInt64(&this.finishedMigrating) > 0 {
+				return nil, fmt.Errorf("Migration finished while waiting on the end of a binlog transaction")
+			}
+		}
+	}
+}
+
+// collectDMLTransactions collects the DML events of whole source transactions, starting with the transaction
+// of given event, so that they apply onto the ghost table in a single transaction. A source transaction is
+// never split: its remaining events are waited for, even beyond --dml-batch-size or --dml-batch-max-bytes.
+// Further transactions, already fully buffered, join in as long as the batch size and bytes allow. Non-DML
+// structs pulled from within a transaction are returned for handling after the DML events; one pulled between
+// transactions ends the collection. The events are returned grouped by source transaction.
+func (this *Migrator) collectDMLTransactions(eventStruct *applyEventStruct) (dmlTransactions [][](*binlog.BinlogDMLEvent), nonDmlStructs [](*applyEventStruct), err error) {
+	batchSize := int(atomic.LoadInt64(&this.migrationContext.DMLBatchSize))
+	batchMaxBytes := atomic.LoadInt64(&this.migrationContext.DMLBatchMaxBytes)
+	dmlTransactions = [][](*binlog.BinlogDMLEvent){{eventStruct.dmlEvent}}
+	numEvents := 1
+	batchBytes := dmlEventsSize(dmlTransactions[0])
+	inTransaction := true
+	for {
+		if !inTransaction && len(this.applyEventsQueue) == 0 {
+			return dmlTransactions, nonDmlStructs, nil
+		}
+		additionalStruct, err := this.nextApplyEventStruct()
+		if err != nil {
+			return dmlTransactions, nonDmlStructs, err
+		}
+		if additionalStruct.isTransactionEnd {
+			atomic.AddInt64(&this.bufferedTransactions, -1)
+			inTransaction = false
+			if numEvents >= batchSize || (batchMaxBytes > 0 && batchBytes >= batchMaxBytes) || atomic.LoadInt64(&this.bufferedTransactions) == 0 {
+				// Either the batch is full, or the next transaction is yet incomplete
+				return dmlTransactions, nonDmlStructs, nil
+			}
+			continue
+		}
+		if additionalStruct.dmlEvent == nil {
+			// Not a DML. We don't group this
+			nonDmlStructs = append(nonDmlStructs, additionalStruct)
+			if !inTransaction {
+				return dmlTransactions, nonDmlStructs, nil
+			}
+			continue
+		}
+		if !inTransaction {
+			dmlTransactions = append(dmlTransactions, nil)
+		}
+		current := len(dmlTransactions) - 1
+		dmlTransactions[current] = append(dmlTransactions[current], additionalStruct.dmlEvent)
+		numEvents++
+		batchBytes += dmlEventsSize([](*binlog.BinlogDMLEvent){additionalStruct.dmlEvent})
+		inTransaction = true
+	}
+}
+
+// applyDMLEvents applies a batch of DML events onto the ghost table, in a single transaction
+func (this *Migrator) applyDMLEvents(dmlEvents [](*binlog.BinlogDMLEvent)) error {
+	// Create a task to apply the DML event; this will be execute by executeWriteFuncs()
+	var applyEventFunc tableWriteFunc = func() error {
+		return this.applier.ApplyDMLEventQueries(dmlEvents)
+	}
+	if err := this.retryOperation(applyEventFunc); err != nil {
+		return err
+	}
+	for _, dmlEvent := range dmlEvents {
+		if this.dmlAuditor != nil {
+			this.dmlAuditor.Audit(dmlEvent)
+		}
+		// Applied; nothing reads the events anymore
+		dmlEvent.Release()
+	}
+	return nil
+}
+
 func (this *Migrator) onApplyEventStruct(eventStruct *applyEventStruct) error {
 	handleNonDMLEventStruct := func(eventStruct *applyEventStruct) error {
+		if this.dmlApplyWorkers != nil {
+			// Non-DML structs, such as changelog states, mark the point where all prior events are applied
+			if err := this.dmlApplyWorkers.flush(); err != nil {
+				return this.migrationContext.Log.Errore(err)
+			}
+		}
 		if eventStruct.writeFunc != nil {
 			if err := this.retryOperation(*eventStruct.writeFunc); err != nil {
 				return this.migrationContext.Log.Errore(err)
@@ -1219,38 +2207,45 @@ func (this *Migrator) onApplyEventStruct(eventStruct *applyEventStruct) error {
 		}
 		return nil
 	}
+	if eventStruct.isTransactionEnd {
+		atomic.AddInt64(&this.bufferedTransactions, -1)
+		return nil
+	}
 	if eventStruct.dmlEvent == nil {
 		return handleNonDMLEventStruct(eventStruct)
 	}
 	if eventStruct.dmlEvent != nil {
-		dmlEvents := [](*binlog.BinlogDMLEvent){}
-		dmlEvents = append(dmlEvents, eventStruct.dmlEvent)
-		var nonDmlStructToApply *applyEventStruct
-
-		availableEvents := len(this.applyEventsQueue)
-		batchSize := int(atomic.LoadInt64(&this.migrationContext.DMLBatchSize))
-		if availableEvents > batchSize-1 {
-			// The "- 1" is because we already consumed one event: the original event that led to this function getting called.
-			// So, if DMLBatchSize==1 we wish to not process any further events
-			availableEvents = batchSize - 1
-		}
-		for i := 0; i < availableEvents; i++ {
-			additionalStruct := <-this.applyEventsQueue
-			if additionalStruct.dmlEvent == nil {
-				// Not a DML. We don't group this, and we don't batch any further
-				nonDmlStructToApply = additionalStruct
-				break
-			}
-			dmlEvents = append(dmlEvents, additionalStruct.dmlEvent)
+		dmlTransactions, nonDmlStructsToApply, err := this.collectDMLTransactions(eventStruct)
+		if err != nil {
+			return this.migrationContext.Log.Errore(err)
 		}
-		// Create a task to apply the DML event; this will be execute by executeWriteFuncs()
-		var applyEventFunc tableWriteFunc = func() error {
-			return this.applier.ApplyDMLEventQueries(dmlEvents)
+		var dmlEvents [](*binlog.BinlogDMLEvent)
+		for _, transactionEvents := range dmlTransactions {
+			dmlEvents = append(dmlEvents, transactionEvents...)
+		}
+		// Read before applying; applied events are released
+		lastEventCoordinates := dmlEvents[len(dmlEvents)-1].Coordinates
+		atomic.StoreInt64(&this.applyingEventTimestamp, dmlEvents[0].Timestamp.Unix())
+		applyStartTime := time.Now()
+		if this.dmlApplyWorkers != nil {
+			err = this.dmlApplyWorkers.apply(dmlTransactions)
+		} else {
+			err = this.applyDMLEvents(dmlEvents)
 		}
-		if err := this.retryOperation(applyEventFunc); err != nil {
+		if err != nil {
 			return this.migrationContext.Log.Errore(err)
 		}
-		if nonDmlStructToApply != nil {
+		this.checkpointBinlogCoordinates = lastEventCoordinates
+		// Per --max-dml-apply-rows-per-second. As with throttling, not while cut-over holds table locks
+		this.migrationContext.DMLApplyRateLimiter.Take(int64(len(dmlEvents)))
+		if atomic.LoadInt64(&this.migrationContext.InCutOverCriticalSectionFlag) == 0 {
+			this.migrationContext.DMLApplyRateLimiter.Wait()
+			// Per --throttle-ramp-up-duration. A rate limited apply rather ramps up its rate limit
+			if factor, rampingUp := this.migrationContext.ThrottleRampUp.Factor(time.Now()); rampingUp && this.migrationContext.DMLApplyRateLimiter.GetRate() == 0 {
+				time.Sleep(time.Duration(base.RampUpNiceRatio(0, factor) * float64(time.Since(applyStartTime))))
+			}
+		}
+		for _, nonDmlStructToApply := range nonDmlStructsToApply {
 			// We pulled DML events from the queue, and then we hit a non-DML event. Wait!
 			// We need to handle it!
 			if err := handleNonDMLEventStruct(nonDmlStructToApply); err != nil {
@@ -1261,8 +2256,38 @@ func (this *Migrator) onApplyEventStruct(eventStruct *applyEventStruct) error {
 	return nil
 }
 
+// getEventsLag returns the age of the oldest binlog event not yet applied, as per binlog event timestamps,
+// with a precision of seconds; or 0 when the backlog is empty
+func (this *Migrator) getEventsLag() time.Duration {
+	if len(this.applyEventsQueue) == 0 {
+		return 0
+	}
+	applyingEventTimestamp := atomic.LoadInt64(&this.applyingEventTimestamp)
+	if applyingEventTimestamp == 0 {
+		return 0
+	}
+	return time.Since(time.Unix(applyingEventTimestamp, 0))
+}
+
+// rowCopyPauseReason returns why row copy should pause while binlog events keep applying, per --max-dml-backlog
+// and --max-events-lag; or an empty string when row copy may proceed
+func (this *Migrator) rowCopyPauseReason() string {
+	if maxDMLBacklog := this.migrationContext.MaxDMLBacklog; maxDMLBacklog > 0 {
+		if backlog := int64(len(this.applyEventsQueue)); backlog >= maxDMLBacklog {
+			return fmt.Sprintf("backlog=%d >= %d", backlog, maxDMLBacklog)
+		}
+	}
+	if maxEventsLag := this.migrationContext.MaxEventsLag; maxEventsLag > 0 {
+		if eventsLag := this.getEventsLag(); eventsLag >= maxEventsLag {
+			return fmt.Sprintf("events-lag=%+v >= %+v", eventsLag.Round(time.Second), maxEventsLag)
+		}
+	}
+	return ""
+}
+
 // executeWriteFuncs writes data via applier: both the rowcopy and the events backlog.
-// This is where the ghost table gets the data. The function fills the data single-threaded.
+// This is where the ghost table gets the data. The function fills the data single-threaded, though
+// it may hand DML events over to concurrent apply workers.
 // Both event backlog and rowcopy events are polled; the backlog events have precedence.
 func (this *Migrator) executeWriteFuncs() error {
 	if this.migrationContext.Noop {
@@ -1274,7 +2299,7 @@ func (this *Migrator) executeWriteFuncs() error {
 			return nil
 		}
 
-		this.throttler.throttle(nil)
+		rowCopyThrottled := this.throttler.throttleAllowingEvents()
 
 		// We give higher priority to event processing, then secondary priority to
 		// rowcopy
@@ -1287,6 +2312,11 @@ func (this *Migrator) executeWriteFuncs() error {
 			}
 		default:
 			{
+				if rowCopyThrottled || this.rowCopyPauseReason() != "" {
+					// Throttled by --throttle-schedule, or draining the backlog: only events apply
+					time.Sleep(250 * time.Millisecond)
+					continue
+				}
 				select {
 				case copyRowsFunc := <-this.copyRowsQueue:
 					{
@@ -1295,7 +2325,12 @@ func (this *Migrator) executeWriteFuncs() error {
 						if err := copyRowsFunc(); err != nil {
 							return this.migrationContext.Log.Errore(err)
 						}
-						if niceRatio := this.migrationContext.GetNiceRatio(); niceRatio > 0 {
+						niceRatio := this.migrationContext.GetNiceRatio()
+						// Per --throttle-ramp-up-duration. A rate limited copy rather ramps up its rate limit
+						if factor, rampingUp := this.migrationContext.ThrottleRampUp.Factor(time.Now()); rampingUp && this.migrationContext.CopyRateLimiter.GetRate() == 0 {
+							niceRatio = base.RampUpNiceRatio(niceRatio, factor)
+						}
+						if niceRatio > 0 {
 							copyRowsDuration := time.Since(copyRowsStartTime)
 							sleepTimeNanosecondFloat64 := niceRatio * float64(copyRowsDuration.Nanoseconds())
 							sleepTime := time.Duration(time.Duration(int64(sleepTimeNanosecondFloat64)) * time.Nanosecond)
@@ -1320,7 +2355,7 @@ func (this *Migrator) finalCleanup() error {
 	atomic.StoreInt64(&this.migrationContext.CleanupImminentFlag, 1)
 
 	if this.migrationContext.Noop {
-		if createTableStatement, err := this.inspector.showCreateTable(this.migrationContext.GetGhostTableName()); err == nil {
+		if createTableStatement, err := this.inspector.showCreateTable(this.migrationContext.GetTargetDatabaseName(), this.migrationContext.GetGhostTableName()); err == nil {
 			this.migrationContext.Log.Infof("New table structure follows")
 			fmt.Println(createTableStatement)
 		} else {
@@ -1331,6 +2366,10 @@ func (this *Migrator) finalCleanup() error {
 		this.migrationContext.Log.Errore(err)
 	}
 
+	if this.migrationContext.DropOldTableAfter > 0 && !this.migrationContext.Noop {
+		// The changelog table is kept, recording the scheduled drop, until the old table is dropped
+		return this.scheduleOldTableDrop()
+	}
 	if err := this.retryOperation(this.applier.DropChangelogTable); err != nil {
 		return err
 	}
@@ -1355,12 +2394,18 @@ func (this *Migrator) finalCleanup() error {
 
 func (this *Migrator) teardown() {
 	atomic.StoreInt64(&this.finishedMigrating, 1)
+	metrics.DefaultRegistry.Unregister(this)
+	this.stopStreaming()
 
 	if this.inspector != nil {
 		this.migrationContext.Log.Infof("Tearing down inspector")
 		this.inspector.Teardown()
 	}
 
+	if this.dmlApplyWorkers != nil {
+		this.dmlApplyWorkers.close()
+	}
+
 	if this.applier != nil {
 		this.migrationContext.Log.Infof("Tearing down applier")
 		this.applier.Teardown()
@@ -1375,4 +2420,11 @@ func (this *Migrator) teardown() {
 		this.migrationContext.Log.Infof("Tearing down throttler")
 		this.throttler.Teardown()
 	}
+
+	if this.dmlAuditor != nil {
+		this.migrationContext.Log.Infof("Closing DML audit file")
+		if err := this.dmlAuditor.Close(); err != nil {
+			this.migrationContext.Log.Errore(err)
+		}
+	}
 }
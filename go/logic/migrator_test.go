@@ -0,0 +1,243 @@
+/*
+   Copyright 2022 GitHub Inc.
+         See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	test "github.com/openark/golib/tests"
+
+	"github.com/github/gh-ost/go/base"
+	"github.com/github/gh-ost/go/binlog"
+	"github.com/github/gh-ost/go/mysql"
+	"github.com/github/gh-ost/go/sql"
+	drivermysql "github.com/go-sql-driver/mysql"
+)
+
+func TestMigratorCollectDMLTransactions(t *testing.T) {
+	newMigrator := func(dmlBatchSize int64) *Migrator {
+		migrationContext := base.NewMigrationContext()
+		migrationContext.SetDMLBatchSize(dmlBatchSize)
+		return NewMigrator(migrationContext, "0.0.0")
+	}
+	countEvents := func(dmlTransactions [][](*binlog.BinlogDMLEvent)) (count int) {
+		for _, dmlEvents := range dmlTransactions {
+			count += len(dmlEvents)
+		}
+		return count
+	}
+	// enqueue mimics the DML events listener: a source transaction's events, optionally followed by its end
+	enqueue := func(migrator *Migrator, transactionSize int, transactionEnd bool) {
+		for i := 0; i < transactionSize; i++ {
+			migrator.applyEventsQueue <- newApplyEventStructByDML(&binlog.BinlogDMLEvent{DML: binlog.InsertDML})
+		}
+		if transactionEnd {
+			atomic.AddInt64(&migrator.bufferedTransactions, 1)
+			migrator.applyEventsQueue <- newApplyEventStructByTransactionEnd()
+		}
+	}
+
+	t.Run("whole buffered transactions", func(t *testing.T) {
+		migrator := newMigrator(10)
+		enqueue(migrator, 3, true)
+		enqueue(migrator, 4, true)
+		// Incomplete transaction
+		enqueue(migrator, 2, false)
+
+		dmlTransactions, nonDmlStructs, err := migrator.collectDMLTransactions(<-migrator.applyEventsQueue)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(countEvents(dmlTransactions), 7)
+		test.S(t).ExpectEquals(len(dmlTransactions), 2)
+		test.S(t).ExpectEquals(len(dmlTransactions[0]), 3)
+		test.S(t).ExpectEquals(len(dmlTransactions[1]), 4)
+		test.S(t).ExpectEquals(len(nonDmlStructs), 0)
+		test.S(t).ExpectEquals(len(migrator.applyEventsQueue), 2)
+		test.S(t).ExpectEquals(atomic.LoadInt64(&migrator.bufferedTransactions), int64(0))
+	})
+
+	t.Run("transaction larger than batch", func(t *testing.T) {
+		migrator := newMigrator(2)
+		enqueue(migrator, 3, true)
+		enqueue(migrator, 4, true)
+
+		dmlTransactions, _, err := migrator.collectDMLTransactions(<-migrator.applyEventsQueue)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(countEvents(dmlTransactions), 3)
+		test.S(t).ExpectEquals(atomic.LoadInt64(&migrator.bufferedTransactions), int64(1))
+	})
+
+	t.Run("transactions larger than batch max bytes", func(t *testing.T) {
+		migrator := newMigrator(10)
+		migrator.migrationContext.DMLBatchMaxBytes = 1000
+		// Each event's row image takes 500 bytes
+		value := strings.Repeat("x", 500)
+		enqueueRows := func(transactionSize int) {
+			for i := 0; i < transactionSize; i++ {
+				migrator.applyEventsQueue <- newApplyEventStructByDML(&binlog.BinlogDMLEvent{DML: binlog.InsertDML, NewColumnValues: sql.ToColumnValues([]interface{}{value})})
+			}
+			atomic.AddInt64(&migrator.bufferedTransactions, 1)
+			migrator.applyEventsQueue <- newApplyEventStructByTransactionEnd()
+		}
+		enqueueRows(1)
+		enqueueRows(3)
+		enqueueRows(1)
+
+		// The second transaction is not split, and fills the batch
+		dmlTransactions, _, err := migrator.collectDMLTransactions(<-migrator.applyEventsQueue)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(countEvents(dmlTransactions), 4)
+		test.S(t).ExpectEquals(atomic.LoadInt64(&migrator.bufferedTransactions), int64(1))
+	})
+
+	t.Run("waits on transaction end", func(t *testing.T) {
+		migrator := newMigrator(10)
+		enqueue(migrator, 2, false)
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			enqueue(migrator, 2, true)
+		}()
+
+		dmlTransactions, _, err := migrator.collectDMLTransactions(<-migrator.applyEventsQueue)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(countEvents(dmlTransactions), 4)
+	})
+
+	t.Run("non-DML structs", func(t *testing.T) {
+		migrator := newMigrator(10)
+		var writeFunc tableWriteFunc = func() error { return nil }
+		enqueue(migrator, 2, false)
+		// Pulled within a transaction: handled once the transaction is applied
+		migrator.applyEventsQueue <- newApplyEventStructByFunc(&writeFunc)
+		enqueue(migrator, 1, true)
+		// Pulled between transactions: nothing is collected beyond
+		migrator.applyEventsQueue <- newApplyEventStructByFunc(&writeFunc)
+		enqueue(migrator, 1, true)
+
+		dmlTransactions, nonDmlStructs, err := migrator.collectDMLTransactions(<-migrator.applyEventsQueue)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(countEvents(dmlTransactions), 3)
+		test.S(t).ExpectEquals(len(nonDmlStructs), 2)
+		test.S(t).ExpectEquals(len(migrator.applyEventsQueue), 2)
+	})
+}
+
+func TestMigratorRowCopyPauseReason(t *testing.T) {
+	migrationContext := base.NewMigrationContext()
+	migrator := NewMigrator(migrationContext, "0.0.0")
+	for i := 0; i < 5; i++ {
+		migrator.applyEventsQueue <- newApplyEventStructByDML(&binlog.BinlogDMLEvent{DML: binlog.InsertDML})
+	}
+	test.S(t).ExpectEquals(migrator.rowCopyPauseReason(), "")
+
+	migrationContext.MaxDMLBacklog = 5
+	test.S(t).ExpectEquals(migrator.rowCopyPauseReason(), "backlog=5 >= 5")
+	migrationContext.MaxDMLBacklog = 6
+	test.S(t).ExpectEquals(migrator.rowCopyPauseReason(), "")
+
+	migrationContext.MaxEventsLag = 10 * time.Second
+	atomic.StoreInt64(&migrator.applyingEventTimestamp, time.Now().Add(-time.Minute).Unix())
+	test.S(t).ExpectTrue(strings.HasPrefix(migrator.rowCopyPauseReason(), "events-lag="))
+	atomic.StoreInt64(&migrator.applyingEventTimestamp, time.Now().Unix())
+	test.S(t).ExpectEquals(migrator.rowCopyPauseReason(), "")
+
+	// An empty backlog has no lag
+	atomic.StoreInt64(&migrator.applyingEventTimestamp, time.Now().Add(-time.Minute).Unix())
+	for len(migrator.applyEventsQueue) > 0 {
+		<-migrator.applyEventsQueue
+	}
+	test.S(t).ExpectEquals(migrator.getEventsLag(), time.Duration(0))
+	test.S(t).ExpectEquals(migrator.rowCopyPauseReason(), "")
+}
+
+func TestMigratorValidateStatementColumnRenames(t *testing.T) {
+	newMigrator := func(alterStatement string, explicitRenames ...string) *Migrator {
+		migrationContext := base.NewMigrationContext()
+		migrationContext.AlterStatement = alterStatement
+		for _, rename := range explicitRenames {
+			test.S(t).ExpectNil(migrationContext.AddExplicitColumnRename(rename))
+		}
+		migrator := NewMigrator(migrationContext, "0.0.0")
+		test.S(t).ExpectNil(migrator.parser.ParseAlterStatement(alterStatement))
+		return migrator
+	}
+
+	t.Run("inferred renames need approval", func(t *testing.T) {
+		migrator := newMigrator("change column name full_name varchar(64)")
+		test.S(t).ExpectNotNil(migrator.validateStatement())
+	})
+
+	t.Run("explicit renames need no approval", func(t *testing.T) {
+		migrator := newMigrator("change column name full_name varchar(64)", "name:full_name")
+		test.S(t).ExpectNil(migrator.validateStatement())
+		test.S(t).ExpectEquals(len(migrator.migrationContext.ColumnRenameMap), 1)
+		test.S(t).ExpectEquals(migrator.migrationContext.ColumnRenameMap["name"], "full_name")
+	})
+
+	t.Run("explicit renames override inferred renames", func(t *testing.T) {
+		migrator := newMigrator("change column a b int, change column b a int", "a:a2", "b:b2")
+		test.S(t).ExpectNil(migrator.validateStatement())
+		test.S(t).ExpectEquals(len(migrator.migrationContext.ColumnRenameMap), 2)
+		test.S(t).ExpectEquals(migrator.migrationContext.ColumnRenameMap["a"], "a2")
+		test.S(t).ExpectEquals(migrator.migrationContext.ColumnRenameMap["b"], "b2")
+	})
+
+	t.Run("explicit renames supplement approved inferred renames", func(t *testing.T) {
+		migrator := newMigrator("change column name full_name varchar(64), modify age smallint", "age:years")
+		migrator.migrationContext.ApproveRenamedColumns = true
+		test.S(t).ExpectNil(migrator.validateStatement())
+		test.S(t).ExpectEquals(len(migrator.migrationContext.ColumnRenameMap), 2)
+		test.S(t).ExpectEquals(migrator.migrationContext.ColumnRenameMap["name"], "full_name")
+		test.S(t).ExpectEquals(migrator.migrationContext.ColumnRenameMap["age"], "years")
+	})
+}
+
+func TestCutOverFailureReason(t *testing.T) {
+	lockWaitTimeout := &drivermysql.MySQLError{Number: mysql.ErrLockWaitTimeout, Message: "Lock wait timeout exceeded; try restarting transaction"}
+
+	test.S(t).ExpectEquals(cutOverFailureReason(newCutOverError(base.CutOverSessionsNotFoundFailure, errors.New("Cannot find process"))), base.CutOverSessionsNotFoundFailure)
+	test.S(t).ExpectEquals(cutOverFailureReason(newCutOverError(base.CutOverRenameBlockedFailure, lockWaitTimeout)), base.CutOverRenameBlockedFailure)
+	test.S(t).ExpectEquals(cutOverFailureReason(fmt.Errorf("%w; query=lock tables", lockWaitTimeout)), base.CutOverLockTimeoutFailure)
+	test.S(t).ExpectEquals(cutOverFailureReason(errors.New("Unexpected error")), base.CutOverUnclassifiedFailure)
+
+	err := newCutOverError(base.CutOverLockTimeoutFailure, lockWaitTimeout)
+	test.S(t).ExpectEquals(err.Error(), lockWaitTimeout.Error())
+	test.S(t).ExpectTrue(errors.Is(err, lockWaitTimeout))
+}
+
+func TestCutOverRowCountMismatchError(t *testing.T) {
+	var err error = &cutOverRowCountMismatchError{mode: base.CutOverRowCountExact, originalTableRows: 100, ghostTableRows: 90, maxRowDelta: "5"}
+	test.S(t).ExpectTrue(errors.Is(err, errCutOverRowCountMismatch))
+	test.S(t).ExpectEquals(err.Error(), "cut-over vetoed by row count check (exact): original table has 100 rows, ghost table has 90 rows, exceeding --cut-over-max-row-delta=5")
+	test.S(t).ExpectTrue(errors.Is(fmt.Errorf("wrapped: %w", err), errCutOverRowCountMismatch))
+}
+
+func TestMigratorRehearseCutOver(t *testing.T) {
+	{
+		migrationContext := base.NewMigrationContext()
+		migrationContext.CutOverType = base.CutOverTwoStep
+		_, err := NewMigrator(migrationContext, "0.0.0").rehearseCutOver()
+		test.S(t).ExpectNotNil(err)
+	}
+	{
+		migrationContext := base.NewMigrationContext()
+		atomic.StoreInt64(&migrationContext.CutOverCompleteFlag, 1)
+		_, err := NewMigrator(migrationContext, "0.0.0").rehearseCutOver()
+		test.S(t).ExpectNotNil(err)
+		test.S(t).ExpectEquals(atomic.LoadInt64(&migrationContext.IsRehearsingCutOver), int64(0))
+	}
+	rehearsal := &CutOverRehearsal{
+		LockAcquireDuration: 15 * time.Millisecond,
+		SyncDuration:        120 * time.Millisecond,
+		LockHoldDuration:    130 * time.Millisecond,
+		BlockedSessions:     4,
+	}
+	test.S(t).ExpectEquals(rehearsal.String(), "lock acquired in 15ms; events up to lock synced in 120ms; lock held for 130ms; queries blocked: 4, max wait: 0s")
+}
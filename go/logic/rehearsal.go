@@ -0,0 +1,98 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package logic
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/github/gh-ost/go/base"
+)
+
+// CutOverRehearsal are the timings of a cut-over rehearsal: the lock-and-wait portion of the atomic cut-over,
+// without the RENAME
+type CutOverRehearsal struct {
+	LockAcquireDuration time.Duration
+	SyncDuration        time.Duration
+	LockHoldDuration    time.Duration
+	BlockedSessions     int64
+	MaxBlockedWait      time.Duration
+}
+
+func (this *CutOverRehearsal) String() string {
+	return fmt.Sprintf("lock acquired in %+v; events up to lock synced in %+v; lock held for %+v; queries blocked: %d, max wait: %+v",
+		this.LockAcquireDuration, this.SyncDuration, this.LockHoldDuration, this.BlockedSessions, this.MaxBlockedWait,
+	)
+}
+
+type rehearseCutOverFunc func() (*CutOverRehearsal, error)
+
+// rehearseCutOver locks the original and magic tables as the atomic cut-over does, waits for the events up to
+// the lock to be applied, and then drops the magic table and unlocks. No RENAME is issued; the applier refuses
+// to rename tables while rehearsing.
+func (this *Migrator) rehearseCutOver() (rehearsal *CutOverRehearsal, err error) {
+	this.cutOverMutex.Lock()
+	defer this.cutOverMutex.Unlock()
+
+	if atomic.LoadInt64(&this.migrationContext.CutOverCompleteFlag) > 0 {
+		return nil, fmt.Errorf("Cut-over is complete; nothing to rehearse")
+	}
+	if this.migrationContext.CutOverType != base.CutOverAtomic {
+		return nil, fmt.Errorf("Only the atomic cut-over may be rehearsed")
+	}
+	atomic.StoreInt64(&this.migrationContext.IsRehearsingCutOver, 1)
+	defer atomic.StoreInt64(&this.migrationContext.IsRehearsingCutOver, 0)
+	defer atomic.StoreInt64(&this.migrationContext.AllEventsUpToLockProcessedInjectedFlag, 0)
+
+	okToUnlockTable := make(chan bool, 2)
+	defer func() {
+		okToUnlockTable <- true
+	}()
+	lockOriginalSessionIdChan := make(chan int64, 2)
+	tableLocked := make(chan error, 2)
+	tableUnlocked := make(chan error, 2)
+	this.migrationContext.Log.Infof("Rehearsing cut-over")
+	go func() {
+		if err := this.applier.AtomicCutOverMagicLock(lockOriginalSessionIdChan, tableLocked, okToUnlockTable, tableUnlocked); err != nil {
+			this.migrationContext.Log.Errore(err)
+		}
+	}()
+	if err := <-tableLocked; err != nil {
+		return nil, err
+	}
+	lockAcquiredTime := time.Now()
+	rehearsal = &CutOverRehearsal{LockAcquireDuration: lockAcquiredTime.Sub(this.migrationContext.LockTablesStartTime)}
+
+	if err := this.waitForEventsUpToLock(); err != nil {
+		return nil, err
+	}
+	rehearsal.SyncDuration = time.Since(lockAcquiredTime)
+	if rehearsal.BlockedSessions, rehearsal.MaxBlockedWait, err = this.applier.GetBlockedSessions(); err != nil {
+		this.migrationContext.Log.Warningf("Rehearsing cut-over: cannot read blocked sessions: %+v", err)
+	}
+
+	okToUnlockTable <- true
+	if err := <-tableUnlocked; err != nil {
+		return nil, err
+	}
+	rehearsal.LockHoldDuration = time.Since(lockAcquiredTime)
+	this.migrationContext.Log.Infof("Cut-over rehearsal: %s", rehearsal)
+	return rehearsal, nil
+}
+
+// rehearseCutOverTimes runs --cut-over-rehearsals rehearsals once the migration is ready to cut-over. A failed
+// rehearsal is reported, and does not fail the migration.
+func (this *Migrator) rehearseCutOverTimes() {
+	for i := int64(1); i <= this.migrationContext.CutOverRehearsals; i++ {
+		if i > 1 {
+			time.Sleep(this.migrationContext.CutOverRetryInterval)
+		}
+		if _, err := this.rehearseCutOver(); err != nil {
+			this.migrationContext.Log.Warningf("Cut-over rehearsal %d/%d failed: %+v", i, this.migrationContext.CutOverRehearsals, err)
+		}
+	}
+}
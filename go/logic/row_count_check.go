@@ -0,0 +1,102 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/github/gh-ost/go/base"
+)
+
+var errCutOverRowCountMismatch = errors.New("cut-over vetoed by row count check")
+
+// cutOverRowCountMismatchError is a cut-over refused by the row count check
+type cutOverRowCountMismatchError struct {
+	mode              base.CutOverRowCountMode
+	originalTableRows int64
+	ghostTableRows    int64
+	maxRowDelta       string
+}
+
+func (this *cutOverRowCountMismatchError) Error() string {
+	return fmt.Sprintf("%s (%s): original table has %d rows, ghost table has %d rows, exceeding --cut-over-max-row-delta=%s",
+		errCutOverRowCountMismatch, this.mode, this.originalTableRows, this.ghostTableRows, this.maxRowDelta,
+	)
+}
+
+func (this *cutOverRowCountMismatchError) Unwrap() error {
+	return errCutOverRowCountMismatch
+}
+
+// checkCutOverRowCount compares the original and ghost tables' row counts, as per --cut-over-max-row-delta,
+// and refuses cut-over should they differ by too much. In estimate mode the counts are information_schema
+// estimates, read ahead of the cut-over lock; in exact mode rows are counted within the lock, once all events
+// up to the lock are applied, and both counts together are bounded by --cut-over-lock-timeout-seconds.
+// The mismatch hook is not run here, since the lock may be held: see onCutOverRowCountMismatch().
+func (this *Migrator) checkCutOverRowCount(mode base.CutOverRowCountMode) error {
+	if !this.migrationContext.IsCutOverRowCountChecked() || this.migrationContext.CutOverRowCountMode != mode {
+		return nil
+	}
+	var originalTableRows, ghostTableRows int64
+	var err error
+	switch mode {
+	case base.CutOverRowCountEstimate:
+		if originalTableRows, err = this.applier.EstimateTableRows(this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName); err != nil {
+			return err
+		}
+		if ghostTableRows, err = this.applier.EstimateTableRows(this.migrationContext.GetTargetDatabaseName(), this.migrationContext.GetGhostTableName()); err != nil {
+			return err
+		}
+	case base.CutOverRowCountExact:
+		deadline := time.Now().Add(time.Duration(this.migrationContext.CutOverLockTimeoutSeconds) * time.Second)
+		// MAX_EXECUTION_TIME(0) is unbounded
+		remaining := func() (time.Duration, error) {
+			if timeout := time.Until(deadline); timeout >= time.Millisecond {
+				return timeout, nil
+			}
+			return 0, fmt.Errorf("--cut-over-lock-timeout-seconds exhausted")
+		}
+		timeout, err := remaining()
+		if err == nil {
+			originalTableRows, err = this.applier.CountOriginalTableRowsWithinLock(timeout)
+		}
+		if err != nil {
+			return fmt.Errorf("Cannot count original table rows within --cut-over-lock-timeout-seconds: %+v", err)
+		}
+		timeout, err = remaining()
+		if err == nil {
+			ghostTableRows, err = this.applier.CountGhostTableRows(timeout)
+		}
+		if err != nil {
+			return fmt.Errorf("Cannot count ghost table rows within --cut-over-lock-timeout-seconds: %+v", err)
+		}
+	}
+	if !this.migrationContext.CutOverRowDeltaExceeded(originalTableRows, ghostTableRows) {
+		this.migrationContext.Log.Infof("Cut-over row count check (%s): original table: %d rows, ghost table: %d rows", mode, originalTableRows, ghostTableRows)
+		return nil
+	}
+	return &cutOverRowCountMismatchError{
+		mode:              mode,
+		originalTableRows: originalTableRows,
+		ghostTableRows:    ghostTableRows,
+		maxRowDelta:       this.migrationContext.GetCutOverMaxRowDelta(),
+	}
+}
+
+// onCutOverRowCountMismatch runs the mismatch hook for a cut-over attempt refused by the row count check.
+// It runs once the attempt is over and its locks released, so that the hook cannot extend the time queries
+// are blocked.
+func (this *Migrator) onCutOverRowCountMismatch(err error) {
+	var mismatchErr *cutOverRowCountMismatchError
+	if !errors.As(err, &mismatchErr) {
+		return
+	}
+	if hookErr := this.hooksExecutor.onCutOverRowCountMismatch(mismatchErr.mode, mismatchErr.originalTableRows, mismatchErr.ghostTableRows); hookErr != nil {
+		this.migrationContext.Log.Errore(hookErr)
+	}
+}
@@ -14,8 +14,10 @@ import (
 	"strconv"
 	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/github/gh-ost/go/base"
+	"github.com/github/gh-ost/go/mysql"
 )
 
 type printStatusFunc func(PrintStatusRule, io.Writer)
@@ -27,13 +29,15 @@ type Server struct {
 	tcpListener      net.Listener
 	hooksExecutor    *HooksExecutor
 	printStatus      printStatusFunc
+	rehearseCutOver  rehearseCutOverFunc
 }
 
-func NewServer(migrationContext *base.MigrationContext, hooksExecutor *HooksExecutor, printStatus printStatusFunc) *Server {
+func NewServer(migrationContext *base.MigrationContext, hooksExecutor *HooksExecutor, printStatus printStatusFunc, rehearseCutOver rehearseCutOverFunc) *Server {
 	return &Server{
 		migrationContext: migrationContext,
 		hooksExecutor:    hooksExecutor,
 		printStatus:      printStatus,
+		rehearseCutOver:  rehearseCutOver,
 	}
 }
 
@@ -104,14 +108,29 @@ func (this *Server) handleConnection(conn net.Conn) (err error) {
 	if err != nil {
 		return err
 	}
-	return this.onServerCommand(string(command), bufio.NewWriter(conn))
+	return this.onServerCommand(string(command), this.describeClient(conn), bufio.NewWriter(conn))
+}
+
+// describeClient describes the client of given connection, for audit logs: its remote address over TCP,
+// or the socket file over a unix socket, along with the peer process's uid and pid where available
+func (this *Server) describeClient(conn net.Conn) string {
+	if _, ok := conn.(*net.UnixConn); !ok {
+		if addr := conn.RemoteAddr(); addr != nil {
+			return fmt.Sprintf("%s %s", addr.Network(), addr.String())
+		}
+	}
+	// A unix socket client's address is unnamed
+	if peerCredentials := describePeerCredentials(conn); peerCredentials != "" {
+		return fmt.Sprintf("unix %s %s", this.migrationContext.ServeSocketFile, peerCredentials)
+	}
+	return fmt.Sprintf("unix %s", this.migrationContext.ServeSocketFile)
 }
 
 // onServerCommand responds to a user's interactive command
-func (this *Server) onServerCommand(command string, writer *bufio.Writer) (err error) {
+func (this *Server) onServerCommand(command string, client string, writer *bufio.Writer) (err error) {
 	defer writer.Flush()
 
-	printStatusRule, err := this.applyServerCommand(command, writer)
+	printStatusRule, err := this.applyServerCommand(command, client, writer)
 	if err == nil {
 		this.printStatus(printStatusRule, writer)
 	} else {
@@ -120,8 +139,27 @@ func (this *Server) onServerCommand(command string, writer *bufio.Writer) (err e
 	return this.migrationContext.Log.Errore(err)
 }
 
-// applyServerCommand parses and executes commands by user
-func (this *Server) applyServerCommand(command string, writer *bufio.Writer) (printStatusRule PrintStatusRule, err error) {
+// parseIgnoredThrottleControlReplica parses the argument of the throttle-control-replicas-ignore command,
+// host:port optionally followed by ;ttl=<duration>
+func parseIgnoredThrottleControlReplica(arg string) (key *mysql.InstanceKey, ttl time.Duration, err error) {
+	tokens := strings.Split(arg, ";")
+	if key, err = mysql.ParseInstanceKey(strings.TrimSpace(tokens[0])); err != nil {
+		return nil, ttl, err
+	}
+	for _, option := range tokens[1:] {
+		optionTokens := strings.SplitN(option, "=", 2)
+		if len(optionTokens) != 2 || strings.TrimSpace(optionTokens[0]) != "ttl" {
+			return nil, ttl, fmt.Errorf("Cannot parse %s. Expected host:port;ttl=<duration>", arg)
+		}
+		if ttl, err = time.ParseDuration(strings.TrimSpace(optionTokens[1])); err != nil || ttl <= 0 {
+			return nil, ttl, fmt.Errorf("Cannot parse %s. Invalid ttl; expected a positive duration, e.g. 2h", arg)
+		}
+	}
+	return key, ttl, nil
+}
+
+// applyServerCommand parses and executes commands by user, connected as given client
+func (this *Server) applyServerCommand(command string, client string, writer *bufio.Writer) (printStatusRule PrintStatusRule, err error) {
 	printStatusRule = NoPrintStatusRule
 
 	tokens := strings.SplitN(command, "=", 2)
@@ -144,26 +182,40 @@ func (this *Server) applyServerCommand(command string, writer *bufio.Writer) (pr
 	case "help":
 		{
 			fmt.Fprint(writer, `available commands:
-status                               # Print a detailed status message
-sup                                  # Print a short status message
-coordinates                          # Print the currently inspected coordinates
-applier                              # Print the hostname of the applier
-inspector                            # Print the hostname of the inspector
-chunk-size=<newsize>                 # Set a new chunk-size
-dml-batch-size=<newsize>             # Set a new dml-batch-size
-nice-ratio=<ratio>                   # Set a new nice-ratio, immediate sleep after each row-copy operation, float (examples: 0 is aggressive, 0.7 adds 70% runtime, 1.0 doubles runtime, 2.0 triples runtime, ...)
-critical-load=<load>                 # Set a new set of max-load thresholds
-max-lag-millis=<max-lag>             # Set a new replication lag threshold
-replication-lag-query=<query>        # Set a new query that determines replication lag (no quotes)
-max-load=<load>                      # Set a new set of max-load thresholds
-throttle-query=<query>               # Set a new throttle-query (no quotes)
-throttle-http=<URL>                  # Set a new throttle URL
-throttle-control-replicas=<replicas> # Set a new comma delimited list of throttle control replicas
-throttle                             # Force throttling
-no-throttle                          # End forced throttling (other throttling may still apply)
-unpostpone                           # Bail out a cut-over postpone; proceed to cut-over
-panic                                # panic and quit without cleanup
-help                                 # This message
+status                                # Print a detailed status message
+sup                                   # Print a short status message
+coordinates                           # Print the currently inspected coordinates
+applier                               # Print the hostname of the applier
+inspector                             # Print the hostname of the inspector
+chunk-size=<newsize>                  # Set a new chunk-size
+chunk-size-min=<newsize>              # Set a new minimal chunk-size, with --adaptive-chunk-size
+chunk-size-max=<newsize>              # Set a new maximal chunk-size, with --adaptive-chunk-size
+chunk-latency-target=<duration>       # Set a new target chunk latency, with --adaptive-chunk-size (example: 500ms)
+max-copy-rows-per-second=<rate>       # Set a new row copy rate limit, rows per second (0 disables the limit)
+max-dml-apply-rows-per-second=<rate>  # Set a new DML events apply rate limit, rows per second (0 disables the limit)
+throttle-ramp-up-duration=<duration>  # Set a new ramp-up duration following throttle periods, e.g. 60s (0 disables ramp-up)
+throttle-ramp-up-threshold=<duration> # Set the minimal throttle period followed by a ramp-up, e.g. 30s
+dml-batch-size=<newsize>              # Set a new dml-batch-size
+nice-ratio=<ratio>                    # Set a new nice-ratio, immediate sleep after each row-copy operation, float (examples: 0 is aggressive, 0.7 adds 70% runtime, 1.0 doubles runtime, 2.0 triples runtime, ...)
+critical-load=<load>                  # Set a new set of max-load thresholds
+max-lag-millis=<max-lag>              # Set a new replication lag threshold
+max-history-list-length=<length>      # Set a new InnoDB history list length threshold (0 disables the check)
+replication-lag-query=<query>         # Set a new query that determines replication lag (no quotes)
+max-load=<load>                       # Set a new set of max-load thresholds
+throttle-query=<query>                # Set a new throttle-query (no quotes)
+throttle-http=<URL>                   # Set a new throttle URL
+throttle-control-replicas=<replicas>  # Set a new comma delimited list of throttle control replicas; host:port;maxlag=<duration> overrides max-lag-millis
+throttle-control-replicas-ignore=<replica>   # Stop checking a throttle control replica, keeping it listed; host:port;ttl=<duration> ignores it for a while
+throttle-control-replicas-unignore=<replica> # Resume checking an ignored throttle control replica
+throttle-schedule=<windows>           # Set new semicolon delimited throttle schedule windows, e.g. Mon-Fri 08:00-20:00 (empty disables the schedule)
+throttle                              # Force throttling
+no-throttle                           # End forced throttling (other throttling may still apply)
+unpostpone                            # Bail out a cut-over postpone; proceed to cut-over
+rehearse-cut-over                     # Lock the tables and sync as the cut-over would, then unlock without renaming; print timings
+cancel-auto-unpostpone                # Cancel --auto-unpostpone-when; cut-over remains postponed until the postpone flag file is deleted
+skip-cut-over-row-count-check         # Skip the --cut-over-max-row-delta row count check ahead of cut-over
+panic                                 # panic and quit without cleanup
+help                                  # This message
 - use '?' (question mark) as argument to get info rather than set. e.g. "max-load=?" will just print out current max-load.
 `)
 		}
@@ -208,6 +260,87 @@ help                                 # This message
 				return ForcePrintStatusAndHintRule, nil
 			}
 		}
+	case "chunk-size-min", "chunk-size-max":
+		{
+			chunkSizeMin, chunkSizeMax := this.migrationContext.GetChunkSizeBounds()
+			if argIsQuestion {
+				if command == "chunk-size-min" {
+					fmt.Fprintf(writer, "%+v\n", chunkSizeMin)
+				} else {
+					fmt.Fprintf(writer, "%+v\n", chunkSizeMax)
+				}
+				return NoPrintStatusRule, nil
+			}
+			chunkSize, err := strconv.Atoi(arg)
+			if err != nil {
+				return NoPrintStatusRule, err
+			}
+			if command == "chunk-size-min" {
+				chunkSizeMin = int64(chunkSize)
+			} else {
+				chunkSizeMax = int64(chunkSize)
+			}
+			if err := this.migrationContext.SetChunkSizeBounds(chunkSizeMin, chunkSizeMax); err != nil {
+				return NoPrintStatusRule, err
+			}
+			return ForcePrintStatusAndHintRule, nil
+		}
+	case "chunk-latency-target":
+		{
+			if argIsQuestion {
+				fmt.Fprintf(writer, "%+v\n", this.migrationContext.GetChunkLatencyTarget())
+				return NoPrintStatusRule, nil
+			}
+			chunkLatencyTarget, err := time.ParseDuration(arg)
+			if err != nil {
+				return NoPrintStatusRule, err
+			}
+			if err := this.migrationContext.SetChunkLatencyTarget(chunkLatencyTarget); err != nil {
+				return NoPrintStatusRule, err
+			}
+			return ForcePrintStatusAndHintRule, nil
+		}
+	case "max-copy-rows-per-second", "max-dml-apply-rows-per-second":
+		{
+			limiter := this.migrationContext.CopyRateLimiter
+			if command == "max-dml-apply-rows-per-second" {
+				limiter = this.migrationContext.DMLApplyRateLimiter
+			}
+			if argIsQuestion {
+				fmt.Fprintf(writer, "%+v\n", limiter.GetRate())
+				return NoPrintStatusRule, nil
+			}
+			if rate, err := strconv.ParseInt(arg, 10, 64); err != nil {
+				return NoPrintStatusRule, err
+			} else if rate < 0 {
+				return NoPrintStatusRule, fmt.Errorf("%s must be non-negative", command)
+			} else {
+				limiter.SetRate(rate)
+				return ForcePrintStatusAndHintRule, nil
+			}
+		}
+	case "throttle-ramp-up-duration", "throttle-ramp-up-threshold":
+		{
+			rampUp := this.migrationContext.ThrottleRampUp
+			if argIsQuestion {
+				if command == "throttle-ramp-up-duration" {
+					fmt.Fprintf(writer, "%+v\n", rampUp.GetDuration())
+				} else {
+					fmt.Fprintf(writer, "%+v\n", rampUp.GetThreshold())
+				}
+				return NoPrintStatusRule, nil
+			}
+			if duration, err := time.ParseDuration(arg); err != nil {
+				return NoPrintStatusRule, err
+			} else if duration < 0 {
+				return NoPrintStatusRule, fmt.Errorf("%s must be non-negative", command)
+			} else if command == "throttle-ramp-up-duration" {
+				rampUp.SetDuration(duration)
+			} else {
+				rampUp.SetThreshold(duration)
+			}
+			return ForcePrintStatusAndHintRule, nil
+		}
 	case "dml-batch-size":
 		{
 			if argIsQuestion {
@@ -234,6 +367,21 @@ help                                 # This message
 				return ForcePrintStatusAndHintRule, nil
 			}
 		}
+	case "max-history-list-length":
+		{
+			if argIsQuestion {
+				fmt.Fprintf(writer, "%+v\n", atomic.LoadInt64(&this.migrationContext.MaxHistoryListLength))
+				return NoPrintStatusRule, nil
+			}
+			if maxHistoryListLength, err := strconv.ParseInt(arg, 10, 64); err != nil {
+				return NoPrintStatusRule, err
+			} else if maxHistoryListLength < 0 {
+				return NoPrintStatusRule, fmt.Errorf("max-history-list-length must be non-negative")
+			} else {
+				atomic.StoreInt64(&this.migrationContext.MaxHistoryListLength, maxHistoryListLength)
+				return ForcePrintStatusAndHintRule, nil
+			}
+		}
 	case "replication-lag-query":
 		{
 			return NoPrintStatusRule, fmt.Errorf("replication-lag-query is deprecated. gh-ost uses an internal, subsecond resolution query")
@@ -295,16 +443,63 @@ help                                 # This message
 			fmt.Fprintf(writer, throttleHint)
 			return ForcePrintStatusAndHintRule, nil
 		}
+	case "throttle-schedule":
+		{
+			if argIsQuestion {
+				fmt.Fprintf(writer, "%s\n", this.migrationContext.GetThrottleSchedule())
+				return NoPrintStatusRule, nil
+			}
+			if err := this.migrationContext.ReadThrottleSchedule(arg); err != nil {
+				return NoPrintStatusRule, err
+			}
+			fmt.Fprintf(writer, "%s\n", this.migrationContext.GetThrottleSchedule())
+			return ForcePrintStatusAndHintRule, nil
+		}
 	case "throttle-control-replicas":
 		{
 			if argIsQuestion {
-				fmt.Fprintf(writer, "%s\n", this.migrationContext.GetThrottleControlReplicaKeys().ToCommaDelimitedList())
+				fmt.Fprintf(writer, "%s\n", this.migrationContext.GetThrottleControlReplicas())
 				return NoPrintStatusRule, nil
 			}
 			if err := this.migrationContext.ReadThrottleControlReplicaKeys(arg); err != nil {
 				return NoPrintStatusRule, err
 			}
-			fmt.Fprintf(writer, "%s\n", this.migrationContext.GetThrottleControlReplicaKeys().ToCommaDelimitedList())
+			fmt.Fprintf(writer, "%s\n", this.migrationContext.GetThrottleControlReplicas())
+			return ForcePrintStatusAndHintRule, nil
+		}
+	case "throttle-control-replicas-ignore":
+		{
+			if argIsQuestion {
+				fmt.Fprintf(writer, "%s\n", this.migrationContext.DescribeIgnoredThrottleControlReplicas())
+				return NoPrintStatusRule, nil
+			}
+			key, ttl, err := parseIgnoredThrottleControlReplica(arg)
+			if err != nil {
+				return NoPrintStatusRule, err
+			}
+			until, err := this.migrationContext.IgnoreThrottleControlReplica(*key, ttl)
+			if err != nil {
+				return NoPrintStatusRule, err
+			}
+			if until.IsZero() {
+				this.migrationContext.Log.Infof("Ignoring throttle control replica %s until unignored, as commanded by %s", key.DisplayString(), client)
+			} else {
+				this.migrationContext.Log.Infof("Ignoring throttle control replica %s until %+v, as commanded by %s", key.DisplayString(), until.Format(time.RFC3339), client)
+			}
+			fmt.Fprintf(writer, "%s\n", this.migrationContext.DescribeIgnoredThrottleControlReplicas())
+			return ForcePrintStatusAndHintRule, nil
+		}
+	case "throttle-control-replicas-unignore":
+		{
+			key, err := mysql.ParseInstanceKey(arg)
+			if err != nil {
+				return NoPrintStatusRule, err
+			}
+			if err := this.migrationContext.UnignoreThrottleControlReplica(*key); err != nil {
+				return NoPrintStatusRule, err
+			}
+			this.migrationContext.Log.Infof("No longer ignoring throttle control replica %s, as commanded by %s", key.DisplayString(), client)
+			fmt.Fprintf(writer, "%s\n", this.migrationContext.DescribeIgnoredThrottleControlReplicas())
 			return ForcePrintStatusAndHintRule, nil
 		}
 	case "throttle", "pause", "suspend":
@@ -347,6 +542,42 @@ help                                 # This message
 			fmt.Fprintf(writer, "You may only invoke this when gh-ost is actively postponing migration. At this time it is not.\n")
 			return NoPrintStatusRule, nil
 		}
+	case "rehearse-cut-over":
+		{
+			if arg != "" && arg != this.migrationContext.OriginalTableName {
+				// User explicitly provided table name. This is a courtesy protection mechanism
+				err := fmt.Errorf("User commanded 'rehearse-cut-over' on %s, but migrated table is %s; ignoring request.", arg, this.migrationContext.OriginalTableName)
+				return NoPrintStatusRule, err
+			}
+			rehearsal, err := this.rehearseCutOver()
+			if err != nil {
+				return NoPrintStatusRule, err
+			}
+			fmt.Fprintf(writer, "Cut-over rehearsal: %s\n", rehearsal)
+			return NoPrintStatusRule, nil
+		}
+	case "cancel-auto-unpostpone":
+		{
+			if this.migrationContext.AutoUnpostponeCriteria == nil {
+				fmt.Fprintf(writer, "--auto-unpostpone-when is not set\n")
+				return NoPrintStatusRule, nil
+			}
+			atomic.StoreInt64(&this.migrationContext.AutoUnpostponeCanceledFlag, 1)
+			this.migrationContext.Log.Infof("Auto-unpostpone canceled by user command")
+			fmt.Fprintf(writer, "Auto-unpostpone canceled\n")
+			return ForcePrintStatusAndHintRule, nil
+		}
+	case "skip-cut-over-row-count-check":
+		{
+			if !this.migrationContext.CutOverRowCountCheck {
+				fmt.Fprintf(writer, "--cut-over-max-row-delta is not set\n")
+				return NoPrintStatusRule, nil
+			}
+			atomic.StoreInt64(&this.migrationContext.SkipCutOverRowCountCheckFlag, 1)
+			this.migrationContext.Log.Infof("Cut-over row count check skipped by user command")
+			fmt.Fprintf(writer, "Cut-over row count check skipped\n")
+			return ForcePrintStatusAndHintRule, nil
+		}
 	case "panic":
 		{
 			if arg == "" && this.migrationContext.ForceNamedPanicCommand {
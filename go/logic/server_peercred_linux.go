@@ -0,0 +1,39 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package logic
+
+import (
+	"fmt"
+	"net"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// describePeerCredentials describes the process at the other end of a unix socket connection, per
+// SO_PEERCRED: its uid, user name, and pid. It returns empty when credentials cannot be read.
+func describePeerCredentials(conn net.Conn) string {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return ""
+	}
+	rawConn, err := unixConn.SyscallConn()
+	if err != nil {
+		return ""
+	}
+	var ucred *syscall.Ucred
+	var ucredErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		ucred, ucredErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil || ucredErr != nil {
+		return ""
+	}
+	uid := strconv.FormatUint(uint64(ucred.Uid), 10)
+	if peerUser, err := user.LookupId(uid); err == nil {
+		return fmt.Sprintf("uid=%s(%s) pid=%d", uid, peerUser.Username, ucred.Pid)
+	}
+	return fmt.Sprintf("uid=%s pid=%d", uid, ucred.Pid)
+}
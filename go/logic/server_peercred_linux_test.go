@@ -0,0 +1,41 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package logic
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	test "github.com/openark/golib/tests"
+
+	"github.com/github/gh-ost/go/base"
+)
+
+func TestDescribeClientPeerCredentials(t *testing.T) {
+	migrationContext := base.NewMigrationContext()
+	migrationContext.ServeSocketFile = filepath.Join(t.TempDir(), "gh-ost.sock")
+	server := NewServer(migrationContext, nil, nil, nil)
+
+	listener, err := net.Listen("unix", migrationContext.ServeSocketFile)
+	test.S(t).ExpectNil(err)
+	defer listener.Close()
+	client, err := net.Dial("unix", migrationContext.ServeSocketFile)
+	test.S(t).ExpectNil(err)
+	defer client.Close()
+	conn, err := listener.Accept()
+	test.S(t).ExpectNil(err)
+	defer conn.Close()
+
+	description := server.describeClient(conn)
+	test.S(t).ExpectTrue(strings.HasPrefix(description, fmt.Sprintf("unix %s uid=%d", migrationContext.ServeSocketFile, os.Getuid())))
+	test.S(t).ExpectTrue(strings.HasSuffix(description, fmt.Sprintf(" pid=%d", os.Getpid())))
+
+	test.S(t).ExpectEquals(describePeerCredentials(&net.TCPConn{}), "")
+}
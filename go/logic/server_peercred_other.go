@@ -0,0 +1,18 @@
+//go:build !linux
+
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package logic
+
+import (
+	"net"
+)
+
+// describePeerCredentials describes the process at the other end of a unix socket connection. Peer
+// credentials are read per SO_PEERCRED, on Linux only: elsewhere it returns empty.
+func describePeerCredentials(conn net.Conn) string {
+	return ""
+}
@@ -0,0 +1,84 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package logic
+
+import (
+	gosql "database/sql"
+	"sync"
+	"sync/atomic"
+
+	"github.com/github/gh-ost/go/base"
+)
+
+// dmlStatementCache holds prepared statements of the DML queries applying binlog events onto the
+// ghost table. Statements are keyed by query text, which is determined by the DML kind and by the
+// columns signature; values are always passed as arguments. The cache is bounded in size: once
+// full, the earliest prepared statement is closed.
+type dmlStatementCache struct {
+	migrationContext *base.MigrationContext
+	db               *gosql.DB
+	maxSize          int
+	statements       map[string]*gosql.Stmt
+	queries          []string
+	mutex            sync.Mutex
+}
+
+func newDMLStatementCache(migrationContext *base.MigrationContext, db *gosql.DB, maxSize int) *dmlStatementCache {
+	return &dmlStatementCache{
+		migrationContext: migrationContext,
+		db:               db,
+		maxSize:          maxSize,
+		statements:       make(map[string]*gosql.Stmt),
+	}
+}
+
+// prepare returns the prepared statement for given query, preparing it if needed
+func (this *dmlStatementCache) prepare(query string) (*gosql.Stmt, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if stmt, ok := this.statements[query]; ok {
+		atomic.AddInt64(&this.migrationContext.DMLPreparedStatementHits, 1)
+		return stmt, nil
+	}
+	atomic.AddInt64(&this.migrationContext.DMLPreparedStatementMisses, 1)
+	stmt, err := this.db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(this.queries) >= this.maxSize {
+		evictedQuery := this.queries[0]
+		this.queries = this.queries[1:]
+		this.statements[evictedQuery].Close()
+		delete(this.statements, evictedQuery)
+	}
+	this.statements[query] = stmt
+	this.queries = append(this.queries, query)
+	return stmt, nil
+}
+
+// invalidate closes all prepared statements. It is called when the ghost table definition changes,
+// and upon failure to apply statements.
+func (this *dmlStatementCache) invalidate() {
+	if this == nil {
+		return
+	}
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	for _, stmt := range this.statements {
+		stmt.Close()
+	}
+	this.statements = make(map[string]*gosql.Stmt)
+	this.queries = nil
+}
+
+// size returns the number of cached statements
+func (this *dmlStatementCache) size() int {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return len(this.queries)
+}
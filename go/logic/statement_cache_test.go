@@ -0,0 +1,94 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package logic
+
+import (
+	gosql "database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	test "github.com/openark/golib/tests"
+
+	"github.com/github/gh-ost/go/base"
+)
+
+// testPrepareDriver accepts statements for preparation, counting the ones open
+type testPrepareDriver struct {
+	openStatements int64
+}
+
+type testPrepareConn struct {
+	driver *testPrepareDriver
+}
+
+type testPrepareStmt struct {
+	driver *testPrepareDriver
+}
+
+func (this *testPrepareDriver) Open(name string) (driver.Conn, error) {
+	return &testPrepareConn{driver: this}, nil
+}
+
+func (this *testPrepareConn) Prepare(query string) (driver.Stmt, error) {
+	atomic.AddInt64(&this.driver.openStatements, 1)
+	return &testPrepareStmt{driver: this.driver}, nil
+}
+
+func (this *testPrepareConn) Close() error              { return nil }
+func (this *testPrepareConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+func (this *testPrepareStmt) Close() error {
+	atomic.AddInt64(&this.driver.openStatements, -1)
+	return nil
+}
+func (this *testPrepareStmt) NumInput() int { return -1 }
+func (this *testPrepareStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+func (this *testPrepareStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func TestDMLStatementCache(t *testing.T) {
+	testDriver := &testPrepareDriver{}
+	gosql.Register("gh-ost-test-prepare", testDriver)
+	db, err := gosql.Open("gh-ost-test-prepare", "")
+	test.S(t).ExpectNil(err)
+	defer db.Close()
+
+	migrationContext := base.NewMigrationContext()
+	cache := newDMLStatementCache(migrationContext, db, 2)
+
+	stmt, err := cache.prepare("delete from t where id = ?")
+	test.S(t).ExpectNil(err)
+	cachedStmt, err := cache.prepare("delete from t where id = ?")
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectTrue(stmt == cachedStmt)
+	test.S(t).ExpectEquals(cache.size(), 1)
+	test.S(t).ExpectEquals(migrationContext.DMLPreparedStatementHits, int64(1))
+	test.S(t).ExpectEquals(migrationContext.DMLPreparedStatementMisses, int64(1))
+
+	// The earliest statement is evicted once the cache is full
+	_, err = cache.prepare("delete from t where id in (?, ?)")
+	test.S(t).ExpectNil(err)
+	_, err = cache.prepare("replace into t (id) values (?)")
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectEquals(cache.size(), 2)
+	test.S(t).ExpectEquals(atomic.LoadInt64(&testDriver.openStatements), int64(2))
+	_, err = cache.prepare("delete from t where id = ?")
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectEquals(migrationContext.DMLPreparedStatementMisses, int64(4))
+
+	cache.invalidate()
+	test.S(t).ExpectEquals(cache.size(), 0)
+	test.S(t).ExpectEquals(atomic.LoadInt64(&testDriver.openStatements), int64(0))
+
+	// A disabled cache is nil, and safe to invalidate
+	var disabledCache *dmlStatementCache
+	disabledCache.invalidate()
+}
@@ -6,10 +6,14 @@
 package logic
 
 import (
+	"context"
 	gosql "database/sql"
 	"fmt"
+	"math/rand"
+	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/github/gh-ost/go/base"
@@ -17,19 +21,28 @@ import (
 	"github.com/github/gh-ost/go/mysql"
 
 	"github.com/outbrain/golib/sqlutils"
+	gomysql "github.com/siddontang/go-mysql/mysql"
 )
 
 type BinlogEventListener struct {
-	async        bool
-	databaseName string
-	tableName    string
-	onDmlEvent   func(event *binlog.BinlogDMLEvent) error
+	async            bool
+	databaseName     string
+	tableName        string
+	onDmlEvent       func(event *binlog.BinlogDMLEvent) error
+	onTransactionEnd func() error
+	inTransaction    bool
 }
 
-const (
-	EventsChannelBufferSize       = 1
-	ReconnectStreamerSleepSeconds = 5
-)
+// BinlogStreamStalledError indicates no binlog event was read for longer than --binlog-stall-timeout,
+// even though the applier writes heartbeat events onto the changelog table
+type BinlogStreamStalledError struct {
+	StalledDuration time.Duration
+	Coordinates     mysql.BinlogCoordinates
+}
+
+func (this *BinlogStreamStalledError) Error() string {
+	return fmt.Sprintf("binlog stream stalled for %.0fs at coordinates %s", this.StalledDuration.Seconds(), this.Coordinates.DisplayString())
+}
 
 // EventsStreamer reads data from binary logs and streams it on. It acts as a publisher,
 // and interested parties may subscribe for per-table events.
@@ -41,7 +54,12 @@ type EventsStreamer struct {
 	listeners                [](*BinlogEventListener)
 	listenersMutex           *sync.Mutex
 	eventsChannel            chan *binlog.BinlogEntry
-	binlogReader             *binlog.GoMySQLReader
+	binlogReader             binlog.BinlogReader
+	binlogReaderMutex        *sync.Mutex
+	failoverKeysIndex        int
+	lastBlockedWarningTime   time.Time
+	stalledStreamError       *BinlogStreamStalledError
+	stalledStreamMutex       *sync.Mutex
 	name                     string
 }
 
@@ -50,15 +68,19 @@ func NewEventsStreamer(migrationContext *base.MigrationContext) *EventsStreamer
 		connectionConfig: migrationContext.InspectorConnectionConfig,
 		migrationContext: migrationContext,
 		listeners:        [](*BinlogEventListener){},
-		listenersMutex:   &sync.Mutex{},
-		eventsChannel:    make(chan *binlog.BinlogEntry, EventsChannelBufferSize),
-		name:             "streamer",
+		listenersMutex:     &sync.Mutex{},
+		eventsChannel:      make(chan *binlog.BinlogEntry, migrationContext.BinlogEventsBufferSize),
+		stalledStreamMutex: &sync.Mutex{},
+		binlogReaderMutex:  &sync.Mutex{},
+		name:               "streamer",
 	}
 }
 
-// AddListener registers a new listener for binlog events, on a per-table basis
+// AddListener registers a new listener for binlog events, on a per-table basis.
+// onTransactionEnd is optional, and is called upon commit of a transaction in which
+// the listener was notified of at least one DML event.
 func (this *EventsStreamer) AddListener(
-	async bool, databaseName string, tableName string, onDmlEvent func(event *binlog.BinlogDMLEvent) error) (err error) {
+	async bool, databaseName string, tableName string, onDmlEvent func(event *binlog.BinlogDMLEvent) error, onTransactionEnd func() error) (err error) {
 
 	this.listenersMutex.Lock()
 	defer this.listenersMutex.Unlock()
@@ -70,10 +92,11 @@ func (this *EventsStreamer) AddListener(
 		return fmt.Errorf("Empty table name in AddListener")
 	}
 	listener := &BinlogEventListener{
-		async:        async,
-		databaseName: databaseName,
-		tableName:    tableName,
-		onDmlEvent:   onDmlEvent,
+		async:            async,
+		databaseName:     databaseName,
+		tableName:        tableName,
+		onDmlEvent:       onDmlEvent,
+		onTransactionEnd: onTransactionEnd,
 	}
 	this.listeners = append(this.listeners, listener)
 	return nil
@@ -93,6 +116,7 @@ func (this *EventsStreamer) notifyListeners(binlogEvent *binlog.BinlogDMLEvent)
 		if strings.ToLower(listener.tableName) != strings.ToLower(binlogEvent.TableName) {
 			continue
 		}
+		listener.inTransaction = true
 		if listener.async {
 			go func() {
 				listener.onDmlEvent(binlogEvent)
@@ -103,6 +127,31 @@ func (this *EventsStreamer) notifyListeners(binlogEvent *binlog.BinlogDMLEvent)
 	}
 }
 
+// notifyTransactionEnd will notify listeners which were notified of DML events within
+// the transaction that has just ended.
+func (this *EventsStreamer) notifyTransactionEnd() {
+	this.listenersMutex.Lock()
+	defer this.listenersMutex.Unlock()
+
+	for _, listener := range this.listeners {
+		listener := listener
+		if !listener.inTransaction {
+			continue
+		}
+		listener.inTransaction = false
+		if listener.onTransactionEnd == nil {
+			continue
+		}
+		if listener.async {
+			go func() {
+				listener.onTransactionEnd()
+			}()
+		} else {
+			listener.onTransactionEnd()
+		}
+	}
+}
+
 func (this *EventsStreamer) InitDBConnections() (err error) {
 	EventsStreamerUri := this.connectionConfig.GetDBUri(this.migrationContext.DatabaseName)
 	if this.db, _, err = mysql.GetDB(this.migrationContext.Uuid, EventsStreamerUri); err != nil {
@@ -111,32 +160,429 @@ func (this *EventsStreamer) InitDBConnections() (err error) {
 	if _, err := base.ValidateConnection(this.db, this.connectionConfig, this.migrationContext, this.name); err != nil {
 		return err
 	}
-	if err := this.readCurrentBinlogCoordinates(); err != nil {
+	if err := this.validateReplicationAuthentication(); err != nil {
+		return err
+	}
+	if err := this.readInitialBinlogCoordinates(); err != nil {
+		return err
+	}
+	if err := this.ensureUniqueReplicaServerId(false); err != nil {
 		return err
 	}
 	if err := this.initBinlogReader(this.initialBinlogCoordinates); err != nil {
 		return err
 	}
+	if resumeCoordinates := this.migrationContext.ResumeBinlogCoordinates; resumeCoordinates != nil {
+		// The rows event at the checkpoint's coordinates may have been applied in part only
+		this.getBinlogReader().SetLastAppliedRowsEventHint(mysql.BinlogCoordinates{LogFile: resumeCoordinates.LogFile, LogPos: resumeCoordinates.LogPos - 1})
+	}
+
+	return nil
+}
+
+// validateReplicationAuthentication verifies a caching_sha2_password user is able to fully
+// authenticate the binlog replication connection. Full authentication takes place whenever the
+// server's authentication cache is cold, e.g. after a restart or FLUSH PRIVILEGES, and so possibly
+// only upon reconnecting hours into the migration. Over a non-TLS connection it requires the
+// server's RSA key pair.
+func (this *EventsStreamer) validateReplicationAuthentication() error {
+	if this.connectionConfig.TLSConfig() != nil {
+		return nil
+	}
+	var plugin string
+	query := `select /* gh-ost */ plugin from mysql.user where concat(user, '@', host) = current_user()`
+	if err := this.db.QueryRow(query).Scan(&plugin); err != nil {
+		// Likely no privileges on mysql.user. The replication handshake is verified regardless.
+		this.migrationContext.Log.Debugf("Cannot read authentication plugin of %s: %+v", this.connectionConfig.User, err)
+		return nil
+	}
+	if plugin != gomysql.AUTH_CACHING_SHA2_PASSWORD {
+		return nil
+	}
+	var variableName, publicKey string
+	err := this.db.QueryRow(`show /* gh-ost */ global status like 'Caching_sha2_password_rsa_public_key'`).Scan(&variableName, &publicKey)
+	if err != nil && err != gosql.ErrNoRows {
+		return err
+	}
+	if publicKey == "" {
+		return fmt.Errorf("%s authenticates by caching_sha2_password, which over a non-TLS connection requires an RSA key pair on %s, and none is loaded. Use --ssl, or configure caching_sha2_password_private_key_path and caching_sha2_password_public_key_path on the server", this.connectionConfig.User, this.connectionConfig.Key.String())
+	}
+	return nil
+}
 
+// readReplicaServerIds reads the server_ids in use on the streamed host: its own, and those
+// of registered replication clients
+func (this *EventsStreamer) readReplicaServerIds() (map[uint]bool, error) {
+	serverIds := make(map[uint]bool)
+	var serverId uint
+	if err := this.db.QueryRow(`select /* gh-ost */ @@global.server_id`).Scan(&serverId); err != nil {
+		return serverIds, err
+	}
+	serverIds[serverId] = true
+	err := sqlutils.QueryRowsMap(this.db, `show /* gh-ost */ slave hosts`, func(m sqlutils.RowMap) error {
+		serverIds[m.GetUint("Server_id")] = true
+		return nil
+	})
+	return serverIds, err
+}
+
+// ensureUniqueReplicaServerId picks a random server_id within --replica-server-id-range if
+// ours is in use on the streamed host, or if forced to (our server_id being known to be taken)
+func (this *EventsStreamer) ensureUniqueReplicaServerId(force bool) error {
+	serverIds, err := this.readReplicaServerIds()
+	if err != nil {
+		return err
+	}
+	if !force && !serverIds[this.migrationContext.ReplicaServerId] {
+		return nil
+	}
+	serverIds[this.migrationContext.ReplicaServerId] = true
+	serverId, err := pickReplicaServerId(this.migrationContext.ReplicaServerIdRangeMin, this.migrationContext.ReplicaServerIdRangeMax, serverIds)
+	if err != nil {
+		return err
+	}
+	this.migrationContext.Log.Warningf("replica server_id %d is in use by another replication client on %s. Using server_id %d", this.migrationContext.ReplicaServerId, this.connectionConfig.Key.String(), serverId)
+	this.migrationContext.ReplicaServerId = serverId
 	return nil
 }
 
+// pickReplicaServerId returns a random server_id within given range, which is not in use
+func pickReplicaServerId(rangeMin, rangeMax uint, serverIds map[uint]bool) (uint, error) {
+	for i := 0; i < 100; i++ {
+		serverId := rangeMin + uint(rand.Int63n(int64(rangeMax-rangeMin)+1))
+		if !serverIds[serverId] {
+			return serverId, nil
+		}
+	}
+	return 0, fmt.Errorf("Cannot find an unused server_id within --replica-server-id-range %d-%d", rangeMin, rangeMax)
+}
+
 // initBinlogReader creates and connects the reader: we hook up to a MySQL server as a replica
 func (this *EventsStreamer) initBinlogReader(binlogCoordinates *mysql.BinlogCoordinates) error {
-	goMySQLReader := binlog.NewGoMySQLReader(this.migrationContext)
+	atomic.StoreInt64(&this.migrationContext.StreamerProgressTime, time.Now().UnixNano())
+	if this.migrationContext.BinlogFile != "" {
+		binlogFileReader := binlog.NewBinlogFileReader(this.migrationContext, this.migrationContext.BinlogFile)
+		if err := binlogFileReader.ConnectBinlogStreamer(*binlogCoordinates); err != nil {
+			return err
+		}
+		this.setBinlogReader(binlogFileReader)
+		return nil
+	}
+	goMySQLReader := binlog.NewGoMySQLReader(this.migrationContext, this.connectionConfig)
+	if err := goMySQLReader.VerifyReplicationHandshake(); err != nil {
+		return err
+	}
 	if err := goMySQLReader.ConnectBinlogStreamer(*binlogCoordinates); err != nil {
 		return err
 	}
-	this.binlogReader = goMySQLReader
+	this.setBinlogReader(goMySQLReader)
+	return nil
+}
+
+// getBinlogReader returns the current binlog reader, which initBinlogReader replaces upon reconnecting
+// or failing over, while detectStalledStream and status reporting read it concurrently
+func (this *EventsStreamer) getBinlogReader() binlog.BinlogReader {
+	this.binlogReaderMutex.Lock()
+	defer this.binlogReaderMutex.Unlock()
+	return this.binlogReader
+}
+
+func (this *EventsStreamer) setBinlogReader(binlogReader binlog.BinlogReader) {
+	this.binlogReaderMutex.Lock()
+	defer this.binlogReaderMutex.Unlock()
+	this.binlogReader = binlogReader
+}
+
+// failover re-points binlog streaming to the next viable host of --streamer-failover-hosts,
+// resuming at given coordinates
+func (this *EventsStreamer) failover(binlogCoordinates *mysql.BinlogCoordinates) error {
+	failoverKeys := this.migrationContext.StreamerFailoverKeys
+	for this.failoverKeysIndex < len(failoverKeys) {
+		key := failoverKeys[this.failoverKeysIndex]
+		this.failoverKeysIndex++
+
+		connectionConfig := this.migrationContext.InspectorConnectionConfig.DuplicateCredentials(key)
+		if err := this.validateFailoverHost(connectionConfig, binlogCoordinates); err != nil {
+			this.migrationContext.Log.Errorf("Cannot fail over binlog streaming to %s: %+v", key.DisplayString(), err)
+			continue
+		}
+		this.migrationContext.Log.Infof("Failing over binlog streaming from %s to %s at %+v", this.connectionConfig.Key.DisplayString(), key.DisplayString(), *binlogCoordinates)
+		this.getBinlogReader().Close()
+		previousConnectionConfig := this.connectionConfig
+		this.connectionConfig = connectionConfig
+		if err := this.initBinlogReader(binlogCoordinates); err != nil {
+			this.migrationContext.Log.Errorf("Failed failing over binlog streaming to %s: %+v", key.DisplayString(), err)
+			this.connectionConfig = previousConnectionConfig
+			continue
+		}
+		return nil
+	}
+	return fmt.Errorf("No viable host left in --streamer-failover-hosts")
+}
+
+// validateFailoverHost checks that given host serves the binlog events we stream. With GTID,
+// streaming resumes from the executed GTID set. Without GTID, binlog coordinates only carry over
+// to a host writing the very same binary log files, such as a binlog server.
+func (this *EventsStreamer) validateFailoverHost(connectionConfig *mysql.ConnectionConfig, binlogCoordinates *mysql.BinlogCoordinates) error {
+	db, _, err := mysql.GetDB(this.migrationContext.Uuid, connectionConfig.GetDBUri(this.migrationContext.DatabaseName))
+	if err != nil {
+		return err
+	}
+	var logBin, logSlaveUpdates bool
+	var binlogFormat string
+	query := `select /* gh-ost */ @@global.log_bin, @@global.log_slave_updates, @@global.binlog_format`
+	if err := db.QueryRow(query).Scan(&logBin, &logSlaveUpdates, &binlogFormat); err != nil {
+		return err
+	}
+	if !logBin {
+		return fmt.Errorf("log_bin is disabled")
+	}
+	if binlogFormat != "ROW" {
+		return fmt.Errorf("binlog_format is %s, expected ROW", binlogFormat)
+	}
+	if !logSlaveUpdates {
+		masterKey, err := mysql.GetMasterKeyFromSlaveStatus(connectionConfig)
+		if err != nil {
+			return err
+		}
+		if masterKey != nil {
+			return fmt.Errorf("log_slave_updates is disabled, hence replicated changes are missing from its binary logs")
+		}
+	}
+
+	if this.migrationContext.IsGTIDModeEnabled && binlogCoordinates.HasExecutedGtidSet() {
+		if this.migrationContext.IsMariaDB() {
+			return nil
+		}
+		var gtidMode string
+		if err := db.QueryRow(`select /* gh-ost */ @@global.gtid_mode`).Scan(&gtidMode); err != nil {
+			return err
+		}
+		if gtidMode != "ON" {
+			return fmt.Errorf("gtid_mode is %s, expected ON", gtidMode)
+		}
+		var isExecuted bool
+		if err := db.QueryRow(`select /* gh-ost */ gtid_subset(?, @@global.gtid_executed)`, binlogCoordinates.ExecutedGtidSet).Scan(&isExecuted); err != nil {
+			return err
+		}
+		if !isExecuted {
+			return fmt.Errorf("host has not yet executed GTID set %s", binlogCoordinates.ExecutedGtidSet)
+		}
+		return nil
+	}
+
+	foundBinlogFile := false
+	err = sqlutils.QueryRowsMap(db, `show /* gh-ost */ binary logs`, func(m sqlutils.RowMap) error {
+		if m.GetString("Log_name") == binlogCoordinates.LogFile {
+			foundBinlogFile = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !foundBinlogFile {
+		return fmt.Errorf("binary log %s not found. Without GTID, failover requires a host with the same binary log files, such as a binlog server", binlogCoordinates.LogFile)
+	}
 	return nil
 }
 
+// GetStreamingKey returns the host currently streamed from
+func (this *EventsStreamer) GetStreamingKey() *mysql.InstanceKey {
+	return &this.connectionConfig.Key
+}
+
 func (this *EventsStreamer) GetCurrentBinlogCoordinates() *mysql.BinlogCoordinates {
-	return this.binlogReader.GetCurrentBinlogCoordinates()
+	return this.getBinlogReader().GetCurrentBinlogCoordinates()
+}
+
+// GetStreamerLag returns the time elapsed since the most recently read binlog event was written,
+// or zero if no such event is known
+func (this *EventsStreamer) GetStreamerLag() time.Duration {
+	lastEventTimestamp := this.getBinlogReader().GetLastEventTimestamp()
+	if lastEventTimestamp.IsZero() {
+		return 0
+	}
+	return time.Since(lastEventTimestamp)
+}
+
+// GetEventsBufferDepth returns the number of binlog entries read but not yet handed to
+// listeners, and the capacity of the buffer holding them
+func (this *EventsStreamer) GetEventsBufferDepth() (depth int64, capacity int64) {
+	return int64(len(this.eventsChannel)), int64(cap(this.eventsChannel))
+}
+
+// warnOnBlockedReader logs a warning while the binlog reader has been blocked on a full
+// events buffer for longer than --binlog-events-blocked-warning, at most once per that interval.
+// It is expected to be called periodically.
+func (this *EventsStreamer) warnOnBlockedReader() {
+	warningInterval := time.Duration(this.migrationContext.BinlogEventsBlockedWarningSeconds) * time.Second
+	blockedSince := atomic.LoadInt64(&this.migrationContext.StreamerBlockedSince)
+	if warningInterval <= 0 || blockedSince == 0 {
+		return
+	}
+	blockedDuration := time.Since(time.Unix(0, blockedSince))
+	if blockedDuration < warningInterval || time.Since(this.lastBlockedWarningTime) < warningInterval {
+		return
+	}
+	this.lastBlockedWarningTime = time.Now()
+	depth, capacity := this.GetEventsBufferDepth()
+	this.migrationContext.Log.Warningf("Binlog reader blocked on a full events buffer (%d/%d) for %+v; the applier is falling behind. Consider increasing --binlog-events-buffer-size", depth, capacity, blockedDuration.Round(time.Second))
+}
+
+// detectStalledStream interrupts binlog streaming once no binlog event was read for longer than
+// --binlog-stall-timeout. A healthy stream sees the heartbeat events the applier writes; a reader
+// blocked on a full events buffer, or idle while heartbeats are paused (hibernation, throttling by
+// user command), is not stalled. It is expected to be called periodically.
+func (this *EventsStreamer) detectStalledStream() {
+	stallTimeout := time.Duration(this.migrationContext.BinlogStallTimeoutSeconds) * time.Second
+	if stallTimeout <= 0 || this.migrationContext.BinlogFile != "" {
+		return
+	}
+	now := time.Now()
+	isPaused := atomic.LoadInt64(&this.migrationContext.StreamerBlockedSince) > 0 || atomic.LoadInt64(&this.migrationContext.HibernateUntil) > 0
+	if throttle, _, reasonHint := this.migrationContext.IsThrottled(); throttle && reasonHint == base.UserCommandThrottleReasonHint {
+		isPaused = true
+	}
+	if isPaused {
+		atomic.StoreInt64(&this.migrationContext.StreamerProgressTime, now.UnixNano())
+		return
+	}
+	stalledDuration := now.Sub(time.Unix(0, atomic.LoadInt64(&this.migrationContext.StreamerProgressTime)))
+	if stalledDuration < stallTimeout {
+		return
+	}
+	stalledStreamError := &BinlogStreamStalledError{StalledDuration: stalledDuration, Coordinates: *this.GetCurrentBinlogCoordinates()}
+	this.migrationContext.Log.Errore(stalledStreamError)
+	// Grace period for the stream to resume
+	atomic.StoreInt64(&this.migrationContext.StreamerProgressTime, now.UnixNano())
+
+	this.stalledStreamMutex.Lock()
+	this.stalledStreamError = stalledStreamError
+	this.stalledStreamMutex.Unlock()
+	// Interrupts StreamEvents, which then reports the stall
+	this.getBinlogReader().Close()
+}
+
+// takeStalledStreamError returns and clears the stall detected on the stream, if any
+func (this *EventsStreamer) takeStalledStreamError() (stalledStreamError *BinlogStreamStalledError) {
+	this.stalledStreamMutex.Lock()
+	defer this.stalledStreamMutex.Unlock()
+	stalledStreamError, this.stalledStreamError = this.stalledStreamError, nil
+	return stalledStreamError
 }
 
 func (this *EventsStreamer) GetReconnectBinlogCoordinates() *mysql.BinlogCoordinates {
-	return &mysql.BinlogCoordinates{LogFile: this.GetCurrentBinlogCoordinates().LogFile, LogPos: 4}
+	currentCoordinates := this.GetCurrentBinlogCoordinates()
+	return &mysql.BinlogCoordinates{LogFile: currentCoordinates.LogFile, LogPos: 4, ExecutedGtidSet: currentCoordinates.ExecutedGtidSet}
+}
+
+// readInitialBinlogCoordinates determines where streaming begins: at --start-binlog-coordinates
+// or --start-gtid-set when provided, or else at the current master status
+func (this *EventsStreamer) readInitialBinlogCoordinates() error {
+	if this.migrationContext.BinlogFile != "" {
+		coordinates := mysql.BinlogCoordinates{LogFile: filepath.Base(this.migrationContext.BinlogFile), LogPos: 4}
+		if this.migrationContext.StartBinlogCoordinates != nil {
+			coordinates = *this.migrationContext.StartBinlogCoordinates
+		}
+		this.initialBinlogCoordinates = &coordinates
+		this.migrationContext.Log.Infof("Streaming replays --binlog-file %s", this.migrationContext.BinlogFile)
+		return nil
+	}
+	if resumeCoordinates := this.migrationContext.ResumeBinlogCoordinates; resumeCoordinates != nil {
+		// Rows events up to the checkpoint's coordinates are skipped, per the reader's applied rows event hint
+		coordinates := mysql.BinlogCoordinates{LogFile: resumeCoordinates.LogFile, LogPos: 4}
+		if err := this.validateStartBinlogCoordinates(&coordinates); err != nil {
+			return fmt.Errorf("--resume: cannot stream from the checkpoint's binary log: %+v", err)
+		}
+		this.initialBinlogCoordinates = &coordinates
+		this.migrationContext.Log.Infof("Streaming resumes at checkpoint %s", resumeCoordinates.DisplayString())
+		return nil
+	}
+	if this.migrationContext.StartBinlogCoordinates != nil {
+		coordinates := *this.migrationContext.StartBinlogCoordinates
+		if err := this.validateStartBinlogCoordinates(&coordinates); err != nil {
+			return err
+		}
+		this.initialBinlogCoordinates = &coordinates
+		this.migrationContext.Log.Infof("Streaming begins at --start-binlog-coordinates %s", coordinates.DisplayString())
+		return nil
+	}
+	if err := this.readCurrentBinlogCoordinates(); err != nil {
+		return err
+	}
+	if this.migrationContext.StartGtidSet != "" {
+		if !this.migrationContext.IsGTIDModeEnabled {
+			return fmt.Errorf("--start-gtid-set requires gtid_mode=ON on %s", this.connectionConfig.Key.String())
+		}
+		if err := this.validateStartGtidSet(this.migrationContext.StartGtidSet); err != nil {
+			return err
+		}
+		// The streamer's file/pos follow once the server rotates us onto the binary log holding the GTID set
+		this.initialBinlogCoordinates.ExecutedGtidSet = this.migrationContext.StartGtidSet
+		this.migrationContext.Log.Infof("Streaming begins at --start-gtid-set %s", this.migrationContext.StartGtidSet)
+	}
+	return nil
+}
+
+// readBinaryLogs returns the names of the binary logs available on the streamed host, oldest first,
+// along with their sizes
+func (this *EventsStreamer) readBinaryLogs() (binaryLogs []string, fileSizes map[string]int64, err error) {
+	fileSizes = make(map[string]int64)
+	err = sqlutils.QueryRowsMap(this.db, `show /* gh-ost */ binary logs`, func(m sqlutils.RowMap) error {
+		logName := m.GetString("Log_name")
+		binaryLogs = append(binaryLogs, logName)
+		fileSizes[logName] = m.GetInt64("File_size")
+		return nil
+	})
+	return binaryLogs, fileSizes, err
+}
+
+// validateStartBinlogCoordinates verifies given coordinates are within the binary logs available
+// on the streamed host
+func (this *EventsStreamer) validateStartBinlogCoordinates(coordinates *mysql.BinlogCoordinates) error {
+	binaryLogs, fileSizes, err := this.readBinaryLogs()
+	if err != nil {
+		return err
+	}
+	if len(binaryLogs) == 0 {
+		return fmt.Errorf("No binary logs found on %s", this.connectionConfig.Key.String())
+	}
+	fileSize, found := fileSizes[coordinates.LogFile]
+	if !found {
+		oldest := mysql.BinlogCoordinates{LogFile: binaryLogs[0]}
+		if coordinates.FileSmallerThan(&oldest) {
+			return fmt.Errorf("--start-binlog-coordinates %s are older than the oldest binary log on %s, which has likely been purged. Available binary logs: %s", coordinates.DisplayString(), this.connectionConfig.Key.String(), strings.Join(binaryLogs, ", "))
+		}
+		return fmt.Errorf("--start-binlog-coordinates %s: binary log %s not found on %s. Available binary logs: %s", coordinates.DisplayString(), coordinates.LogFile, this.connectionConfig.Key.String(), strings.Join(binaryLogs, ", "))
+	}
+	if coordinates.LogPos > fileSize {
+		return fmt.Errorf("--start-binlog-coordinates %s are beyond the end of %s, which has %d bytes", coordinates.DisplayString(), coordinates.LogFile, fileSize)
+	}
+	return nil
+}
+
+// validateStartGtidSet verifies the transactions following given GTID set are all available
+// in the binary logs of the streamed host: none have been purged.
+func (this *EventsStreamer) validateStartGtidSet(gtidSet string) error {
+	if this.migrationContext.IsMariaDB() {
+		return nil
+	}
+	var isExecuted, isAvailable bool
+	query := `select /* gh-ost */ gtid_subset(?, @@global.gtid_executed), gtid_subset(@@global.gtid_purged, ?)`
+	if err := this.db.QueryRow(query, gtidSet, gtidSet).Scan(&isExecuted, &isAvailable); err != nil {
+		return fmt.Errorf("Invalid --start-gtid-set %s: %+v", gtidSet, err)
+	}
+	if !isExecuted {
+		return fmt.Errorf("--start-gtid-set %s is not executed on %s", gtidSet, this.connectionConfig.Key.String())
+	}
+	if !isAvailable {
+		var gtidPurged string
+		this.db.QueryRow(`select /* gh-ost */ @@global.gtid_purged`).Scan(&gtidPurged)
+		binaryLogs, _, _ := this.readBinaryLogs()
+		return fmt.Errorf("--start-gtid-set %s is older than the binary logs on %s: transactions it lacks were purged (gtid_purged: %s). Available binary logs: %s", gtidSet, this.connectionConfig.Key.String(), gtidPurged, strings.Join(binaryLogs, ", "))
+	}
+	return nil
 }
 
 // readCurrentBinlogCoordinates reads master status from hooked server
@@ -148,6 +594,10 @@ func (this *EventsStreamer) readCurrentBinlogCoordinates() error {
 			LogFile: m.GetString("File"),
 			LogPos:  m.GetInt64("Position"),
 		}
+		if this.migrationContext.IsGTIDModeEnabled {
+			// Executed_Gtid_Set may span multiple lines, one per server UUID
+			this.initialBinlogCoordinates.ExecutedGtidSet = strings.Replace(m.GetString("Executed_Gtid_Set"), "\n", "", -1)
+		}
 		foundMasterStatus = true
 
 		return nil
@@ -163,54 +613,186 @@ func (this *EventsStreamer) readCurrentBinlogCoordinates() error {
 }
 
 // StreamEvents will begin streaming events. It will be blocking, so should be
-// executed by a goroutine
-func (this *EventsStreamer) StreamEvents(canStopStreaming func() bool) error {
+// executed by a goroutine. Streaming stops when given context is canceled.
+func (this *EventsStreamer) StreamEvents(ctx context.Context) error {
 	go func() {
 		for binlogEntry := range this.eventsChannel {
 			if binlogEntry.DmlEvent != nil {
 				this.notifyListeners(binlogEntry.DmlEvent)
 			}
+			if binlogEntry.IsTransactionEnd {
+				this.notifyTransactionEnd()
+			}
+			// Listeners own the DML event from here on
+			binlogEntry.Release()
 		}
 	}()
 	// The next should block and execute forever, unless there's a serious error
 	var successiveFailures int64
 	var lastAppliedRowsEventHint mysql.BinlogCoordinates
 	for {
-		if canStopStreaming() {
+		if ctx.Err() != nil {
 			return nil
 		}
-		if err := this.binlogReader.StreamEvents(canStopStreaming, this.eventsChannel); err != nil {
-			if canStopStreaming() {
+		err := this.getBinlogReader().StreamEvents(ctx, this.eventsChannel)
+		if stalledStreamError := this.takeStalledStreamError(); stalledStreamError != nil {
+			err = stalledStreamError
+		}
+		if err != nil {
+			if ctx.Err() != nil {
 				return nil
 			}
+			if _, ok := err.(*BinlogStreamStalledError); ok && this.migrationContext.AbortOnBinlogStall {
+				return err
+			}
+			if _, ok := err.(*binlog.ConcurrentDDLError); ok {
+				return err
+			}
+			if _, ok := err.(*binlog.SchemaDriftError); ok {
+				return err
+			}
+			if fatalBinlogReadError, ok := err.(*binlog.FatalBinlogReadError); ok {
+				// Retrying at the same coordinates is futile
+				if err := this.recoverFromBinlogGap(fatalBinlogReadError); err != nil {
+					return err
+				}
+				continue
+			}
+			if undecodableEventError, ok := err.(*binlog.UndecodableEventError); ok {
+				if !this.migrationContext.SkipUndecodableBinlogEvents {
+					return err
+				}
+				if err := this.skipUndecodableEvent(undecodableEventError); err != nil {
+					return err
+				}
+				continue
+			}
+			if _, ok := err.(*binlog.ReplicaServerIdConflictError); ok {
+				// Reconnecting with the same server_id would in turn kick the other client off
+				this.migrationContext.Log.Errore(err)
+				if err := this.ensureUniqueReplicaServerId(true); err != nil {
+					return err
+				}
+			}
 
 			this.migrationContext.Log.Infof("StreamEvents encountered unexpected error: %+v", err)
 			this.migrationContext.MarkPointOfInterest()
-			time.Sleep(ReconnectStreamerSleepSeconds * time.Second)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(time.Duration(this.migrationContext.StreamerReconnectIntervalSeconds) * time.Second):
+			}
 
 			// See if there's retry overflow
-			if this.binlogReader.LastAppliedRowsEventHint.Equals(&lastAppliedRowsEventHint) {
+			if readerLastAppliedRowsEventHint := this.getBinlogReader().GetLastAppliedRowsEventHint(); readerLastAppliedRowsEventHint.Equals(&lastAppliedRowsEventHint) {
 				successiveFailures += 1
 			} else {
 				successiveFailures = 0
 			}
-			if successiveFailures > this.migrationContext.MaxRetries() {
-				return fmt.Errorf("%d successive failures in streamer reconnect at coordinates %+v", successiveFailures, this.GetReconnectBinlogCoordinates())
+			reconnectCoordinates := this.GetReconnectBinlogCoordinates()
+			if successiveFailures > this.migrationContext.StreamerMaxReconnectRetries() {
+				err := this.migrationContext.Log.Errorf("%d successive failures in streamer reconnect at coordinates %+v, last applied rows event at %+v", successiveFailures, *reconnectCoordinates, this.getBinlogReader().GetLastAppliedRowsEventHint())
+				if len(this.migrationContext.StreamerFailoverKeys) == 0 {
+					return err
+				}
+				lastAppliedRowsEventHint = this.getBinlogReader().GetLastAppliedRowsEventHint()
+				if failoverErr := this.failover(reconnectCoordinates); failoverErr != nil {
+					return fmt.Errorf("%+v; %+v", err, failoverErr)
+				}
+				successiveFailures = 0
+				if this.migrationContext.IsGTIDModeEnabled && reconnectCoordinates.HasExecutedGtidSet() {
+					// Binlog file names and positions differ on the new host
+					lastAppliedRowsEventHint = mysql.BinlogCoordinates{}
+				}
+				this.getBinlogReader().SetLastAppliedRowsEventHint(lastAppliedRowsEventHint)
+				continue
 			}
 
-			// Reposition at same binlog file.
-			lastAppliedRowsEventHint = this.binlogReader.LastAppliedRowsEventHint
+			// Reposition at same binlog file. The previous reader is closed so that we do not leave
+			// a dangling replication connection behind.
+			lastAppliedRowsEventHint = this.getBinlogReader().GetLastAppliedRowsEventHint()
+			this.getBinlogReader().Close()
 			this.migrationContext.Log.Infof("Reconnecting... Will resume at %+v", lastAppliedRowsEventHint)
-			if err := this.initBinlogReader(this.GetReconnectBinlogCoordinates()); err != nil {
-				return err
+			if err := this.initBinlogReader(reconnectCoordinates); err != nil {
+				// The closed reader fails fast on next iteration, which counts as another failure
+				this.migrationContext.Log.Errorf("Failed reconnecting streamer at %+v: %+v", *reconnectCoordinates, err)
+				continue
 			}
-			this.binlogReader.LastAppliedRowsEventHint = lastAppliedRowsEventHint
+			this.getBinlogReader().SetLastAppliedRowsEventHint(lastAppliedRowsEventHint)
 		}
 	}
 }
 
+// skipUndecodableEvent reconnects the binlog reader at the event following the undecodable one.
+// Resuming by GTID would stream the offending transaction anew, hence the reader resumes by
+// file & position.
+func (this *EventsStreamer) skipUndecodableEvent(undecodableEventError *binlog.UndecodableEventError) error {
+	this.migrationContext.Log.Errorf("--skip-undecodable-binlog-events: skipping event. The migrated table may be missing changes. %+v", undecodableEventError)
+	nextCoordinates := undecodableEventError.NextCoordinates()
+	lastAppliedRowsEventHint := this.getBinlogReader().GetLastAppliedRowsEventHint()
+	this.getBinlogReader().Close()
+	if err := this.initBinlogReader(&nextCoordinates); err != nil {
+		return err
+	}
+	this.getBinlogReader().SetLastAppliedRowsEventHint(lastAppliedRowsEventHint)
+	return nil
+}
+
+// describeBinaryLogs lists the binary logs available on the streamed host, for diagnostics
+func (this *EventsStreamer) describeBinaryLogs() string {
+	binaryLogs, fileSizes, err := this.readBinaryLogs()
+	if err != nil {
+		return fmt.Sprintf("cannot read binary logs: %+v", err)
+	}
+	descriptions := []string{}
+	for _, binaryLog := range binaryLogs {
+		descriptions = append(descriptions, fmt.Sprintf("%s (%s)", binaryLog, base.PrettifyBytes(float64(fileSizes[binaryLog]))))
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+// recoverFromBinlogGap handles a fatal error reading binary logs. Unless --allow-binlog-gap-recovery
+// applies, it returns a detailed diagnostic. Otherwise it resumes streaming at the earliest available
+// binary log, i.e. after the purged GTID set, skipping whatever purged events we had not yet read.
+func (this *EventsStreamer) recoverFromBinlogGap(fatalBinlogReadError *binlog.FatalBinlogReadError) error {
+	coordinates := fatalBinlogReadError.Coordinates
+	diagnostic := fmt.Errorf("%+v. Requested %s on %s; available binary logs: %s. Binary logs were likely purged while gh-ost was throttled or lagging; consider increasing binlog_expire_logs_seconds on the streamed host",
+		fatalBinlogReadError, coordinates.DisplayString(), this.connectionConfig.Key.String(), this.describeBinaryLogs())
+	if !fatalBinlogReadError.IsPurged() {
+		return diagnostic
+	}
+	if !this.migrationContext.AllowBinlogGapRecovery {
+		return fmt.Errorf("%+v. With GTID, --allow-binlog-gap-recovery resumes at the earliest available binary log, at the cost of skipping the purged events", diagnostic)
+	}
+	if !this.migrationContext.IsGTIDModeEnabled || !coordinates.HasExecutedGtidSet() || this.migrationContext.IsMariaDB() {
+		return fmt.Errorf("%+v. --allow-binlog-gap-recovery requires MySQL GTID", diagnostic)
+	}
+	this.migrationContext.Log.Errore(diagnostic)
+
+	var gtidPurged, skippedGtidSet string
+	query := `select /* gh-ost */ @@global.gtid_purged, gtid_subtract(@@global.gtid_purged, ?)`
+	if err := this.db.QueryRow(query, coordinates.ExecutedGtidSet).Scan(&gtidPurged, &skippedGtidSet); err != nil {
+		return err
+	}
+	recoveredGtidSet, err := gomysql.ParseMysqlGTIDSet(strings.Replace(coordinates.ExecutedGtidSet+","+gtidPurged, "\n", "", -1))
+	if err != nil {
+		return err
+	}
+	this.migrationContext.Log.Warningf("--allow-binlog-gap-recovery: skipping purged GTID set %s. The migrated table may be missing changes from these transactions, and should be verified before cut-over", strings.Replace(skippedGtidSet, "\n", "", -1))
+
+	recoveredCoordinates := coordinates
+	recoveredCoordinates.ExecutedGtidSet = recoveredGtidSet.String()
+	this.getBinlogReader().Close()
+	if err := this.initBinlogReader(&recoveredCoordinates); err != nil {
+		return err
+	}
+	// Binlog file and position move on beyond the purged binary logs
+	this.getBinlogReader().SetLastAppliedRowsEventHint(mysql.BinlogCoordinates{})
+	return nil
+}
+
 func (this *EventsStreamer) Close() (err error) {
-	err = this.binlogReader.Close()
+	err = this.getBinlogReader().Close()
 	this.migrationContext.Log.Infof("Closed streamer connection. err=%+v", err)
 	return err
 }
@@ -0,0 +1,116 @@
+/*
+   Copyright 2022 GitHub Inc.
+         See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package logic
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	test "github.com/openark/golib/tests"
+
+	"github.com/github/gh-ost/go/base"
+	"github.com/github/gh-ost/go/binlog"
+	"github.com/github/gh-ost/go/mysql"
+)
+
+func TestPickReplicaServerId(t *testing.T) {
+	{
+		serverIds := map[uint]bool{99999: true, 100001: true}
+		for i := 0; i < 100; i++ {
+			serverId, err := pickReplicaServerId(100000, 100002, serverIds)
+			test.S(t).ExpectNil(err)
+			test.S(t).ExpectTrue(serverId == 100000 || serverId == 100002)
+		}
+	}
+	{
+		serverIds := map[uint]bool{7: true}
+		_, err := pickReplicaServerId(7, 7, serverIds)
+		test.S(t).ExpectNotNil(err)
+	}
+}
+
+func TestGetEventsBufferDepth(t *testing.T) {
+	migrationContext := base.NewMigrationContext()
+	migrationContext.BinlogEventsBufferSize = 100
+	eventsStreamer := NewEventsStreamer(migrationContext)
+	eventsStreamer.eventsChannel <- binlog.NewTransactionEndBinlogEntryAt(mysql.BinlogCoordinates{}, 0)
+
+	depth, capacity := eventsStreamer.GetEventsBufferDepth()
+	test.S(t).ExpectEquals(depth, int64(1))
+	test.S(t).ExpectEquals(capacity, int64(100))
+}
+
+// stalledBinlogReader is a binlog reader which reads nothing
+type stalledBinlogReader struct {
+	binlog.BinlogReader
+	closed bool
+}
+
+func (this *stalledBinlogReader) GetCurrentBinlogCoordinates() *mysql.BinlogCoordinates {
+	return &mysql.BinlogCoordinates{LogFile: "mysql-bin.000017", LogPos: 1000}
+}
+
+func (this *stalledBinlogReader) Close() error {
+	this.closed = true
+	return nil
+}
+
+func TestDetectStalledStream(t *testing.T) {
+	migrationContext := base.NewMigrationContext()
+	eventsStreamer := NewEventsStreamer(migrationContext)
+	binlogReader := &stalledBinlogReader{}
+	eventsStreamer.setBinlogReader(binlogReader)
+	stalledSince := time.Now().Add(-time.Minute).UnixNano()
+	atomic.StoreInt64(&migrationContext.StreamerProgressTime, stalledSince)
+
+	// Disabled
+	eventsStreamer.detectStalledStream()
+	test.S(t).ExpectFalse(binlogReader.closed)
+
+	// Blocked on a full events buffer: not stalled
+	migrationContext.BinlogStallTimeoutSeconds = 30
+	atomic.StoreInt64(&migrationContext.StreamerBlockedSince, stalledSince)
+	eventsStreamer.detectStalledStream()
+	test.S(t).ExpectFalse(binlogReader.closed)
+	atomic.StoreInt64(&migrationContext.StreamerBlockedSince, 0)
+	// ...and the time blocked does not count
+	eventsStreamer.detectStalledStream()
+	test.S(t).ExpectFalse(binlogReader.closed)
+
+	atomic.StoreInt64(&migrationContext.StreamerProgressTime, stalledSince)
+	eventsStreamer.detectStalledStream()
+	test.S(t).ExpectTrue(binlogReader.closed)
+	stalledStreamError := eventsStreamer.takeStalledStreamError()
+	test.S(t).ExpectTrue(stalledStreamError != nil)
+	test.S(t).ExpectTrue(strings.HasPrefix(stalledStreamError.Error(), "binlog stream stalled for 60s at coordinates mysql-bin.000017:1000"))
+	test.S(t).ExpectTrue(eventsStreamer.takeStalledStreamError() == nil)
+
+	// A grace period follows
+	binlogReader.closed = false
+	eventsStreamer.detectStalledStream()
+	test.S(t).ExpectFalse(binlogReader.closed)
+}
+
+func TestBinlogReaderReplacedConcurrently(t *testing.T) {
+	migrationContext := base.NewMigrationContext()
+	eventsStreamer := NewEventsStreamer(migrationContext)
+	eventsStreamer.setBinlogReader(&stalledBinlogReader{})
+
+	// As initBinlogReader does upon reconnecting, while status is reported
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			eventsStreamer.setBinlogReader(&stalledBinlogReader{})
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		test.S(t).ExpectEquals(eventsStreamer.GetCurrentBinlogCoordinates().DisplayString(), "mysql-bin.000017:1000")
+	}
+	<-done
+}
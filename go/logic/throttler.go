@@ -6,10 +6,18 @@
 package logic
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -40,6 +48,132 @@ var (
 
 const frenoMagicHint = "freno"
 
+// maxThrottleHTTPResponseBodySize is the most of a --throttle-http response body read; a reason is a short text
+const maxThrottleHTTPResponseBodySize = 64 * 1024
+
+// maxThrottleHTTPReasonLength is the most of a --throttle-http response's reason shown in status
+const maxThrottleHTTPReasonLength = 200
+
+// newThrottleHTTPClient returns a client keeping its connection to the --throttle-http endpoint alive across checks
+func newThrottleHTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = 1
+	transport.IdleConnTimeout = 5 * time.Minute
+	return &http.Client{Transport: transport}
+}
+
+// parseThrottleHTTPReason reads a throttle reason off a --throttle-http response body: the reason field
+// of a JSON object, or else the first line of text
+func parseThrottleHTTPReason(body []byte) string {
+	var document struct {
+		Reason string `json:"reason"`
+	}
+	reason := ""
+	if err := json.Unmarshal(body, &document); err == nil {
+		reason = document.Reason
+	} else {
+		reason = strings.SplitN(string(body), "\n", 2)[0]
+	}
+	reason = strings.TrimSpace(reason)
+	if len(reason) > maxThrottleHTTPReasonLength {
+		reason = reason[:maxThrottleHTTPReasonLength] + "..."
+	}
+	return reason
+}
+
+// throttleCheckStaleIntervals is the number of its own intervals after which an evaluation of a throttle check
+// still running, e.g. due to a hanging query, makes its last known result stale, and throttles
+const throttleCheckStaleIntervals = 5
+
+// throttleCheck is a throttle criterion, evaluated on its own interval. Its last known result stands in between
+// evaluations, and is combined with those of other checks into the throttle decision.
+type throttleCheck struct {
+	name     string
+	interval func() time.Duration
+	// timeout bounds an evaluation, e.g. --throttle-http-timeout-millis, or is 0 when unbounded
+	timeout func() time.Duration
+	// evaluate returns the check's result, or nil to keep the last known result, e.g. while hibernating
+	evaluate        func() *base.ThrottleCheckResult
+	mutex           *sync.Mutex
+	result          *base.ThrottleCheckResult
+	checkedAt       time.Time
+	evaluatingSince time.Time
+}
+
+func newThrottleCheck(name string, interval func() time.Duration, timeout func() time.Duration, evaluate func() *base.ThrottleCheckResult) *throttleCheck {
+	return &throttleCheck{
+		name:     name,
+		interval: interval,
+		timeout:  timeout,
+		evaluate: evaluate,
+		mutex:    &sync.Mutex{},
+		result:   base.NewThrottleCheckResult(false, "", base.NoThrottleReasonHint),
+	}
+}
+
+// staleAfter is how long an evaluation may run before the last known result is stale: throttleCheckStaleIntervals
+// intervals, or the evaluation's timeout and an interval, whichever is longer
+func (this *throttleCheck) staleAfter() time.Duration {
+	staleAfter := throttleCheckStaleIntervals * this.interval()
+	if timeoutStaleAfter := this.timeout() + this.interval(); timeoutStaleAfter > staleAfter {
+		staleAfter = timeoutStaleAfter
+	}
+	return staleAfter
+}
+
+// startEvaluation records the start of an evaluation as of now
+func (this *throttleCheck) startEvaluation() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.evaluatingSince = time.Now()
+}
+
+// setResult records given result as of now, ending the evaluation; a nil result refreshes the last known result
+func (this *throttleCheck) setResult(result *base.ThrottleCheckResult) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if result != nil {
+		this.result = result
+	}
+	this.checkedAt = time.Now()
+	this.evaluatingSince = time.Time{}
+}
+
+// getResult returns the last known result. A check whose evaluation has been running for longer than
+// staleAfter() throttles. The time between evaluations, including their own runtime, does not count:
+// a slow check, which completes within its timeout, is not stale.
+func (this *throttleCheck) getResult() *base.ThrottleCheckResult {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if this.checkedAt.IsZero() {
+		return newThrottlingResult(fmt.Sprintf("%s-check-pending", this.name), fmt.Sprintf("%s check pending", this.name))
+	}
+	if !this.evaluatingSince.IsZero() && time.Since(this.evaluatingSince) > this.staleAfter() {
+		sinceChecked := time.Since(this.checkedAt)
+		return newThrottlingResult(fmt.Sprintf("%s-check-stale", this.name), fmt.Sprintf("%s check stale, last checked %+v ago", this.name, sinceChecked.Round(time.Millisecond)))
+	}
+	return this.result
+}
+
+// evaluateOnce evaluates the check, recording its result
+func (this *throttleCheck) evaluateOnce() {
+	this.startEvaluation()
+	this.setResult(this.evaluate())
+}
+
+// run evaluates the check once, signals firstCollected, then evaluates the check every interval until done
+func (this *throttleCheck) run(firstCollected chan<- bool, isDone func() bool) {
+	this.evaluateOnce()
+	firstCollected <- true
+	for {
+		time.Sleep(this.interval())
+		if isDone() {
+			return
+		}
+		this.evaluateOnce()
+	}
+}
+
 // Throttler collects metrics related to throttling and makes informed decision
 // whether throttling should take place.
 type Throttler struct {
@@ -49,19 +183,94 @@ type Throttler struct {
 	httpClient        *http.Client
 	httpClientTimeout time.Duration
 	inspector         *Inspector
+	eventsStreamer    *EventsStreamer
+	hooksExecutor     *HooksExecutor
 	finishedMigrating int64
+
+	lagCheck      *throttleCheck
+	loadCheck     *throttleCheck
+	httpCheck     *throttleCheck
+	flagFileCheck *throttleCheck
+
+	replicasDiscovery *throttleReplicasDiscovery
+	flagFiles         *throttleFlagFiles
 }
 
-func NewThrottler(migrationContext *base.MigrationContext, applier *Applier, inspector *Inspector, appVersion string) *Throttler {
-	return &Throttler{
+func NewThrottler(migrationContext *base.MigrationContext, applier *Applier, inspector *Inspector, eventsStreamer *EventsStreamer, hooksExecutor *HooksExecutor, appVersion string) *Throttler {
+	throttler := &Throttler{
 		appVersion:        appVersion,
 		migrationContext:  migrationContext,
 		applier:           applier,
-		httpClient:        &http.Client{},
+		httpClient:        newThrottleHTTPClient(),
 		httpClientTimeout: time.Duration(migrationContext.ThrottleHTTPTimeoutMillis) * time.Millisecond,
 		inspector:         inspector,
+		eventsStreamer:    eventsStreamer,
+		hooksExecutor:     hooksExecutor,
 		finishedMigrating: 0,
 	}
+	mysqlTimeout := time.Duration(migrationContext.InspectorConnectionConfig.Timeout * float64(time.Second))
+	throttler.lagCheck = newThrottleCheck("lag", throttler.lagCheckInterval, constantInterval(mysqlTimeout), throttler.newControlReplicasLagCheck())
+	throttler.loadCheck = newThrottleCheck("load", constantInterval(migrationContext.ThrottleLoadCheckInterval), constantInterval(mysqlTimeout), throttler.checkLoad)
+	throttler.httpCheck = newThrottleCheck("http", throttler.httpCheckInterval, constantInterval(throttler.httpClientTimeout), throttler.checkThrottleHTTP)
+	throttler.flagFileCheck = newThrottleCheck("flag-file", constantInterval(migrationContext.ThrottleFlagFileCheckInterval), constantInterval(0), throttler.checkFlagFiles)
+	throttler.replicasDiscovery = newThrottleReplicasDiscovery(migrationContext.DiscoverThrottleReplicasDropAfter)
+	throttler.flagFiles = newThrottleFlagFiles(migrationContext)
+	return throttler
+}
+
+// newThrottlingResult returns a check result which throttles, accounted for by given criterion
+func newThrottlingResult(criterion string, reason string) *base.ThrottleCheckResult {
+	result := base.NewThrottleCheckResult(true, reason, base.NoThrottleReasonHint)
+	result.Criterion = criterion
+	return result
+}
+
+// mergeThrottlingResults combines the throttling results of a check's criteria into the check's result: the
+// first result's reason stands, and the criteria of all are accounted for. It does not throttle given none.
+func mergeThrottlingResults(results []*base.ThrottleCheckResult) *base.ThrottleCheckResult {
+	if len(results) == 0 {
+		return base.NewThrottleCheckResult(false, "", base.NoThrottleReasonHint)
+	}
+	merged := *results[0]
+	merged.OtherCriteria = append([]string{}, merged.OtherCriteria...)
+	for _, result := range results[1:] {
+		merged.OtherCriteria = append(merged.OtherCriteria, result.AllCriteria()...)
+	}
+	return &merged
+}
+
+// loadCriterion returns the throttle criterion of a load variable, as returned by criticalLoadIsMet and
+// controlReplicasLoadIsMet, which prefix the variables of replicas by the replica, e.g. max-load:replica1:3306:Threads_running
+func loadCriterion(load string, variableName string) string {
+	return strings.Join(append([]string{load}, strings.Fields(variableName)...), ":")
+}
+
+func constantInterval(interval time.Duration) func() time.Duration {
+	return func() time.Duration { return interval }
+}
+
+// lagCheckInterval is --throttle-lag-check-interval. By default, lag is checked every second, or every 100ms
+// when --max-lag-millis is sub-second.
+func (this *Throttler) lagCheckInterval() time.Duration {
+	if this.migrationContext.ThrottleLagCheckInterval > 0 {
+		return this.migrationContext.ThrottleLagCheckInterval
+	}
+	if this.migrationContext.GetMinThrottleControlReplicaMaxLag() < time.Second {
+		return 100 * time.Millisecond
+	}
+	return time.Second
+}
+
+// httpCheckInterval is --throttle-http-check-interval, defaulting to --throttle-http-interval-millis
+func (this *Throttler) httpCheckInterval() time.Duration {
+	if this.migrationContext.ThrottleHTTPCheckInterval > 0 {
+		return this.migrationContext.ThrottleHTTPCheckInterval
+	}
+	return time.Duration(this.migrationContext.ThrottleHTTPIntervalMillis) * time.Millisecond
+}
+
+func (this *Throttler) isHibernating() bool {
+	return atomic.LoadInt64(&this.migrationContext.HibernateUntil) > 0
 }
 
 func (this *Throttler) throttleHttpMessage(statusCode int) string {
@@ -75,45 +284,53 @@ func (this *Throttler) throttleHttpMessage(statusCode int) string {
 	return fmt.Sprintf("http=%d", statusCode)
 }
 
-// shouldThrottle performs checks to see whether we should currently be throttling.
-// It merely observes the metrics collected by other components, it does not issue
-// its own metric collection.
-func (this *Throttler) shouldThrottle() (result bool, reason string, reasonHint base.ThrottleReasonHint) {
+// throttlingResults lists the results of all criteria currently throttling, by precedence: the first is
+// the reason the migration is throttled by.
+// It merely observes the last known results of the throttle checks, each evaluated on its own interval,
+// and in-memory state; it does not issue its own metric collection.
+func (this *Throttler) throttlingResults() (results []*base.ThrottleCheckResult) {
 	if hibernateUntil := atomic.LoadInt64(&this.migrationContext.HibernateUntil); hibernateUntil > 0 {
 		hibernateUntilTime := time.Unix(0, hibernateUntil)
-		return true, fmt.Sprintf("critical-load-hibernate until %+v", hibernateUntilTime), base.NoThrottleReasonHint
+		return []*base.ThrottleCheckResult{newThrottlingResult("critical-load-hibernate", fmt.Sprintf("critical-load-hibernate until %+v", hibernateUntilTime))}
 	}
-	generalCheckResult := this.migrationContext.GetThrottleGeneralCheckResult()
-	if generalCheckResult.ShouldThrottle {
-		return generalCheckResult.ShouldThrottle, generalCheckResult.Reason, generalCheckResult.ReasonHint
+	// User-based throttle
+	if atomic.LoadInt64(&this.migrationContext.ThrottleCommandedByUser) > 0 {
+		userCommand := base.NewThrottleCheckResult(true, "commanded by user", base.UserCommandThrottleReasonHint)
+		userCommand.Criterion = "user-command"
+		results = append(results, userCommand)
+	}
+	if checkResult := this.flagFileCheck.getResult(); checkResult.ShouldThrottle {
+		results = append(results, checkResult)
+	}
+	if throttleDepth := atomic.LoadInt64(&this.migrationContext.BinlogEventsBufferThrottleDepth); throttleDepth > 0 {
+		if depth, _ := this.eventsStreamer.GetEventsBufferDepth(); depth >= throttleDepth {
+			results = append(results, newThrottlingResult("binlog-events-buffer", fmt.Sprintf("binlog events buffer %d >= %d", depth, throttleDepth)))
+		}
+	}
+	if checkResult := this.loadCheck.getResult(); checkResult.ShouldThrottle {
+		results = append(results, checkResult)
 	}
 	// HTTP throttle
-	statusCode := atomic.LoadInt64(&this.migrationContext.ThrottleHTTPStatusCode)
-	if statusCode != 0 && statusCode != http.StatusOK {
-		return true, this.throttleHttpMessage(int(statusCode)), base.NoThrottleReasonHint
+	if checkResult := this.httpCheck.getResult(); checkResult.ShouldThrottle {
+		results = append(results, checkResult)
 	}
 
 	// Replication lag throttle
 	maxLagMillisecondsThrottleThreshold := atomic.LoadInt64(&this.migrationContext.MaxLagMillisecondsThrottleThreshold)
 	lag := atomic.LoadInt64(&this.migrationContext.CurrentLag)
 	if time.Duration(lag) > time.Duration(maxLagMillisecondsThrottleThreshold)*time.Millisecond {
-		return true, fmt.Sprintf("lag=%fs", time.Duration(lag).Seconds()), base.NoThrottleReasonHint
+		results = append(results, newThrottlingResult("lag", fmt.Sprintf("lag=%fs", time.Duration(lag).Seconds())))
 	}
-	checkThrottleControlReplicas := true
-	if (this.migrationContext.TestOnReplica || this.migrationContext.MigrateOnReplica) && (atomic.LoadInt64(&this.migrationContext.AllEventsUpToLockProcessedInjectedFlag) > 0) {
-		checkThrottleControlReplicas = false
+	if checkResult := this.lagCheck.getResult(); checkResult.ShouldThrottle {
+		results = append(results, checkResult)
 	}
-	if checkThrottleControlReplicas {
-		lagResult := this.migrationContext.GetControlReplicasLagResult()
-		if lagResult.Err != nil {
-			return true, fmt.Sprintf("%+v %+v", lagResult.Key, lagResult.Err), base.NoThrottleReasonHint
-		}
-		if lagResult.Lag > time.Duration(maxLagMillisecondsThrottleThreshold)*time.Millisecond {
-			return true, fmt.Sprintf("%+v replica-lag=%fs", lagResult.Key, lagResult.Lag.Seconds()), base.NoThrottleReasonHint
-		}
+	// Scheduled throttle comes last, such that it is the reason throttled by only when no other criterion throttles
+	if window := this.migrationContext.GetThrottleSchedule().ActiveWindow(time.Now()); window != nil {
+		scheduled := base.NewThrottleCheckResult(true, fmt.Sprintf("schedule: %s", window.Label), base.ScheduleThrottleReasonHint)
+		scheduled.Criterion = "schedule"
+		results = append(results, scheduled)
 	}
-	// Got here? No metrics indicates we need throttling.
-	return false, "", base.NoThrottleReasonHint
+	return results
 }
 
 // parseChangelogHeartbeat parses a string timestamp and deduces replication lag
@@ -177,13 +394,70 @@ func (this *Throttler) collectReplicationLag(firstThrottlingCollected chan<- boo
 	}
 }
 
-// collectControlReplicasLag polls all the control replicas to get maximum lag value
-func (this *Throttler) collectControlReplicasLag() {
+// maxReplicationLagResult returns the result of the most lagging replica. A replica whose lag cannot be read,
+// e.g. with broken replication, is infinitely lagging.
+func maxReplicationLagResult(lagResults [](*mysql.ReplicationLagResult)) (result *mysql.ReplicationLagResult) {
+	for _, lagResult := range lagResults {
+		if lagResult.SkipReason != "" {
+			continue
+		}
+		if result == nil {
+			result = lagResult
+		} else if result.Err != nil {
+			continue
+		} else if lagResult.Err != nil {
+			result = lagResult
+		} else if lagResult.Lag.Nanoseconds() > result.Lag.Nanoseconds() {
+			result = lagResult
+		}
+	}
+	return result
+}
 
-	if atomic.LoadInt64(&this.migrationContext.HibernateUntil) > 0 {
-		return
+// exceedingReplicationLagResult returns the first lag result which failed, or else the one exceeding its max lag the
+// most, relative to that max lag; nil when all replicas are within their max lag
+func exceedingReplicationLagResult(lagResults [](*mysql.ReplicationLagResult), maxLag func(mysql.InstanceKey) time.Duration) (result *mysql.ReplicationLagResult) {
+	var resultExcess float64
+	for _, lagResult := range lagResults {
+		if lagResult.SkipReason != "" {
+			continue
+		}
+		if lagResult.Err != nil {
+			return lagResult
+		}
+		replicaMaxLag := maxLag(lagResult.Key)
+		if lagResult.Lag <= replicaMaxLag {
+			continue
+		}
+		excess := float64(lagResult.Lag) / float64(replicaMaxLag)
+		if result == nil || excess > resultExcess {
+			result, resultExcess = lagResult, excess
+		}
+	}
+	return result
+}
+
+// exceedingReplicationLagResults returns all lag results which failed or exceed their max lag, that of
+// exceedingReplicationLagResult() first
+func exceedingReplicationLagResults(lagResults [](*mysql.ReplicationLagResult), maxLag func(mysql.InstanceKey) time.Duration) (results [](*mysql.ReplicationLagResult)) {
+	first := exceedingReplicationLagResult(lagResults, maxLag)
+	if first == nil {
+		return nil
 	}
+	results = append(results, first)
+	for _, lagResult := range lagResults {
+		if lagResult == first || lagResult.SkipReason != "" {
+			continue
+		}
+		if lagResult.Err != nil || lagResult.Lag > maxLag(lagResult.Key) {
+			results = append(results, lagResult)
+		}
+	}
+	return results
+}
 
+// newControlReplicasLagCheck returns the lag check, which polls all the control replicas to get maximum lag value
+func (this *Throttler) newControlReplicasLagCheck() func() *base.ThrottleCheckResult {
 	replicationLagQuery := fmt.Sprintf(`
 		select value from %s.%s where hint = 'heartbeat' and id <= 255
 		`,
@@ -194,12 +468,22 @@ func (this *Throttler) collectControlReplicasLag() {
 	readReplicaLag := func(connectionConfig *mysql.ConnectionConfig) (lag time.Duration, err error) {
 		dbUri := connectionConfig.GetDBUri("information_schema")
 
-		var heartbeatValue string
 		db, _, err := mysql.GetDB(this.migrationContext.Uuid, dbUri)
 		if err != nil {
 			return lag, err
 		}
 
+		source := this.migrationContext.GetReplicaLagSource(connectionConfig.Key)
+		switch source.Type {
+		case mysql.ReplicaStatusReplicaLagSource:
+			return mysql.GetReplicationLagFromReplicaStatus(db)
+		case mysql.PerformanceSchemaReplicaLagSource:
+			return mysql.GetReplicationLagFromPerformanceSchema(db)
+		case mysql.PtHeartbeatReplicaLagSource:
+			return mysql.GetReplicationLagFromPtHeartbeat(db, source.Table)
+		}
+
+		var heartbeatValue string
 		if err := db.QueryRow(replicationLagQuery).Scan(&heartbeatValue); err != nil {
 			return lag, err
 		}
@@ -208,11 +492,56 @@ func (this *Throttler) collectControlReplicasLag() {
 		return lag, err
 	}
 
-	readControlReplicasLag := func() (result *mysql.ReplicationLagResult) {
-		instanceKeyMap := this.migrationContext.GetThrottleControlReplicaKeys()
-		if instanceKeyMap.Len() == 0 {
-			return result
+	// readIntentionalLagReason tells why a replica lags intentionally, per --skip-lag-check-if-delayed: a delayed
+	// replica, or one whose replication is stopped without error. A replica whose replication is broken fails.
+	readIntentionalLagReason := func(connectionConfig *mysql.ConnectionConfig) (reason string, err error) {
+		db, _, err := mysql.GetDB(this.migrationContext.Uuid, connectionConfig.GetDBUri("information_schema"))
+		if err != nil {
+			return reason, err
+		}
+		replicaState, err := mysql.GetReplicaState(db)
+		if err != nil {
+			return reason, err
+		}
+		if replicaState.IsBroken() {
+			return reason, replicaState.BrokenError()
+		}
+		return replicaState.IntentionalLagReason(), nil
+	}
+	// skippedReplicas are the replicas whose lag checks were last skipped, and why
+	skippedReplicas := make(map[mysql.InstanceKey]string)
+
+	// auroraReaders are the Aurora readers found on the writer's replica status thus far
+	auroraReaders := make(map[string]bool)
+	readAuroraReplicasLag := func() (lagResults [](*mysql.ReplicationLagResult)) {
+		writerKey := this.migrationContext.ApplierConnectionConfig.Key
+		replicasLag, err := mysql.GetAuroraReplicasLag(this.applier.db)
+		if err != nil {
+			return append(lagResults, &mysql.ReplicationLagResult{Key: writerKey, Err: fmt.Errorf("aurora readers lag: %+v", err)})
+		}
+		foundReaders := make(map[string]bool)
+		for _, replicaLag := range replicasLag {
+			foundReaders[replicaLag.ServerId] = true
+			if !auroraReaders[replicaLag.ServerId] {
+				this.migrationContext.Log.Infof("Aurora reader %s found; throttling on its lag", replicaLag.ServerId)
+			}
+			lagResults = append(lagResults, &mysql.ReplicationLagResult{
+				Key: mysql.InstanceKey{Hostname: replicaLag.ServerId, Port: writerKey.Port},
+				Lag: replicaLag.Lag,
+			})
 		}
+		for serverId := range auroraReaders {
+			if !foundReaders[serverId] {
+				// Gone: its lag is unknown, rather than zero
+				this.migrationContext.Log.Warningf("Aurora reader %s is gone from the writer's replica status; no longer throttling on its lag", serverId)
+			}
+		}
+		auroraReaders = foundReaders
+		return lagResults
+	}
+
+	readControlReplicasLag := func() [](*mysql.ReplicationLagResult) {
+		instanceKeyMap := this.migrationContext.GetCheckedThrottleControlReplicaKeys()
 		lagResults := make(chan *mysql.ReplicationLagResult, instanceKeyMap.Len())
 		for replicaKey := range *instanceKeyMap {
 			connectionConfig := this.migrationContext.InspectorConnectionConfig.Duplicate()
@@ -220,51 +549,61 @@ func (this *Throttler) collectControlReplicasLag() {
 
 			lagResult := &mysql.ReplicationLagResult{Key: connectionConfig.Key}
 			go func() {
-				lagResult.Lag, lagResult.Err = readReplicaLag(connectionConfig)
+				if this.migrationContext.SkipLagCheckIfDelayed {
+					lagResult.SkipReason, lagResult.Err = readIntentionalLagReason(connectionConfig)
+				}
+				if lagResult.SkipReason == "" && lagResult.Err == nil {
+					if lagResult.Lag, lagResult.Err = readReplicaLag(connectionConfig); lagResult.Err != nil {
+						lagResult.Err = fmt.Errorf("%s lag: %+v", this.migrationContext.GetReplicaLagSource(lagResult.Key), lagResult.Err)
+					}
+				}
 				lagResults <- lagResult
 			}()
 		}
+		var allLagResults [](*mysql.ReplicationLagResult)
+		checkedReplicas := make(map[mysql.InstanceKey]bool)
 		for range *instanceKeyMap {
 			lagResult := <-lagResults
-			if result == nil {
-				result = lagResult
-			} else if lagResult.Err != nil {
-				result = lagResult
-			} else if lagResult.Lag.Nanoseconds() > result.Lag.Nanoseconds() {
-				result = lagResult
+			checkedReplicas[lagResult.Key] = true
+			if lagResult.SkipReason != "" && lagResult.SkipReason != skippedReplicas[lagResult.Key] {
+				this.migrationContext.Log.Warningf("Skipping lag checks of throttle control replica %+v, which lags intentionally: %s", lagResult.Key, lagResult.SkipReason)
+			} else if lagResult.SkipReason == "" && skippedReplicas[lagResult.Key] != "" {
+				this.migrationContext.Log.Infof("Resuming lag checks of throttle control replica %+v", lagResult.Key)
 			}
+			skippedReplicas[lagResult.Key] = lagResult.SkipReason
+			allLagResults = append(allLagResults, lagResult)
 		}
-		return result
-	}
-
-	checkControlReplicasLag := func() {
-		if (this.migrationContext.TestOnReplica || this.migrationContext.MigrateOnReplica) && (atomic.LoadInt64(&this.migrationContext.AllEventsUpToLockProcessedInjectedFlag) > 0) {
-			// No need to read lag
-			return
+		for key := range skippedReplicas {
+			if !checkedReplicas[key] {
+				delete(skippedReplicas, key)
+			}
 		}
-		this.migrationContext.SetControlReplicasLagResult(readControlReplicasLag())
+		if this.migrationContext.AuroraLagThrottle {
+			allLagResults = append(allLagResults, readAuroraReplicasLag()...)
+		}
+		return allLagResults
 	}
-	aggressiveTicker := time.Tick(100 * time.Millisecond)
-	relaxedFactor := 10
-	counter := 0
-	shouldReadLagAggressively := false
 
-	for range aggressiveTicker {
-		if atomic.LoadInt64(&this.finishedMigrating) > 0 {
-			return
+	return func() *base.ThrottleCheckResult {
+		if this.isHibernating() {
+			return nil
 		}
-		if counter%relaxedFactor == 0 {
-			// we only check if we wish to be aggressive once per second. The parameters for being aggressive
-			// do not typically change at all throughout the migration, but nonetheless we check them.
-			counter = 0
-			maxLagMillisecondsThrottleThreshold := atomic.LoadInt64(&this.migrationContext.MaxLagMillisecondsThrottleThreshold)
-			shouldReadLagAggressively = (maxLagMillisecondsThrottleThreshold < 1000)
+		if (this.migrationContext.TestOnReplica || this.migrationContext.MigrateOnReplica) && (atomic.LoadInt64(&this.migrationContext.AllEventsUpToLockProcessedInjectedFlag) > 0) {
+			// No need to read lag
+			return base.NewThrottleCheckResult(false, "", base.NoThrottleReasonHint)
 		}
-		if counter == 0 || shouldReadLagAggressively {
-			// We check replication lag every so often, or if we wish to be aggressive
-			checkControlReplicasLag()
+		lagResults := readControlReplicasLag()
+		this.migrationContext.SetControlReplicasLagResults(lagResults)
+		this.migrationContext.SetControlReplicasLagResult(maxReplicationLagResult(lagResults))
+		var results []*base.ThrottleCheckResult
+		for _, lagResult := range exceedingReplicationLagResults(lagResults, this.migrationContext.GetThrottleControlReplicaMaxLag) {
+			if lagResult.Err != nil {
+				results = append(results, newThrottlingResult(fmt.Sprintf("replica-lag:%+v", lagResult.Key), fmt.Sprintf("%+v %+v", lagResult.Key, lagResult.Err)))
+			} else {
+				results = append(results, newThrottlingResult(fmt.Sprintf("replica-lag:%+v", lagResult.Key), fmt.Sprintf("%+v replica-lag=%fs", lagResult.Key, lagResult.Lag.Seconds())))
+			}
 		}
-		counter++
+		return mergeThrottlingResults(results)
 	}
 }
 
@@ -279,76 +618,167 @@ func (this *Throttler) criticalLoadIsMet() (met bool, variableName string, value
 			return true, variableName, value, threshold, nil
 		}
 	}
+	if this.migrationContext.CheckLoadOnReplicas {
+		return this.controlReplicasLoadIsMet(criticalLoad)
+	}
 	return false, variableName, value, threshold, nil
 }
 
-// collectReplicationLag reads the latest changelog heartbeat value
-func (this *Throttler) collectThrottleHTTPStatus(firstThrottlingCollected chan<- bool) {
-	collectFunc := func() (sleep bool, err error) {
-		if atomic.LoadInt64(&this.migrationContext.HibernateUntil) > 0 {
-			return true, nil
+// controlReplicaLoad is a load variable read on a control replica, per --check-load-on-replicas. Its variable name
+// is prefixed by the replica, e.g. replica1:3306 Threads_running.
+type controlReplicaLoad struct {
+	met          bool
+	variableName string
+	value        int64
+	threshold    int64
+	err          error
+}
+
+// readControlReplicasLoad checks given load thresholds on all control replicas, per --check-load-on-replicas. It
+// returns all thresholds met, and all replicas which could not be read, ordered by variable name.
+func (this *Throttler) readControlReplicasLoad(loadMap base.LoadMap) (loads [](*controlReplicaLoad)) {
+	if len(loadMap) == 0 {
+		return nil
+	}
+	if (this.migrationContext.TestOnReplica || this.migrationContext.MigrateOnReplica) && (atomic.LoadInt64(&this.migrationContext.AllEventsUpToLockProcessedInjectedFlag) > 0) {
+		// No need to read load, as with lag
+		return nil
+	}
+	readReplicaLoad := func(connectionConfig *mysql.ConnectionConfig) (replicaLoads [](*controlReplicaLoad)) {
+		db, _, err := mysql.GetDB(this.migrationContext.Uuid, connectionConfig.GetDBUri("information_schema"))
+		if err != nil {
+			return append(replicaLoads, &controlReplicaLoad{variableName: fmt.Sprintf("%+v", connectionConfig.Key), err: err})
 		}
-		url := this.migrationContext.GetThrottleHTTP()
-		if url == "" {
-			return true, nil
+		for variableName, threshold := range loadMap {
+			load := &controlReplicaLoad{variableName: fmt.Sprintf("%+v %s", connectionConfig.Key, variableName), threshold: threshold}
+			if load.value, load.err = mysql.GetStatusVariable(db, variableName); load.err != nil {
+				// The replica cannot be read
+				return [](*controlReplicaLoad){load}
+			}
+			if load.value >= load.threshold {
+				load.met = true
+				replicaLoads = append(replicaLoads, load)
+			}
 		}
+		return replicaLoads
+	}
 
-		ctx, cancel := context.WithTimeout(context.Background(), this.httpClientTimeout)
-		defer cancel()
+	instanceKeyMap := this.migrationContext.GetCheckedThrottleControlReplicaKeys()
+	loadResults := make(chan [](*controlReplicaLoad), instanceKeyMap.Len())
+	for replicaKey := range *instanceKeyMap {
+		connectionConfig := this.migrationContext.InspectorConnectionConfig.Duplicate()
+		connectionConfig.Key = replicaKey
+		go func() {
+			loadResults <- readReplicaLoad(connectionConfig)
+		}()
+	}
+	for range *instanceKeyMap {
+		loads = append(loads, <-loadResults...)
+	}
+	sort.Slice(loads, func(i, j int) bool {
+		return loads[i].variableName < loads[j].variableName
+	})
+	return loads
+}
 
-		req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
-		if err != nil {
-			return false, err
+// controlReplicasLoadIsMet checks given load thresholds on all control replicas, per --check-load-on-replicas.
+// The variable name it returns is prefixed by the replica which met a threshold, or whose load could not be
+// read. As with lag checks, a replica which cannot be read fails the check.
+func (this *Throttler) controlReplicasLoadIsMet(loadMap base.LoadMap) (met bool, variableName string, value int64, threshold int64, err error) {
+	loads := this.readControlReplicasLoad(loadMap)
+	for _, load := range loads {
+		if load.err != nil {
+			return false, load.variableName, 0, 0, load.err
 		}
-		req.Header.Set("User-Agent", fmt.Sprintf("gh-ost/%s", this.appVersion))
-
-		resp, err := this.httpClient.Do(req)
-		if err != nil {
-			return false, err
+	}
+	for _, load := range loads {
+		if load.met {
+			return true, load.variableName, load.value, load.threshold, nil
 		}
-		atomic.StoreInt64(&this.migrationContext.ThrottleHTTPStatusCode, int64(resp.StatusCode))
-		return false, nil
+	}
+	return false, variableName, value, threshold, nil
+}
+
+// checkThrottleHTTP issues a request on --throttle-http, throttling on any response other than 200. An unreachable
+// endpoint throttles, is ignored, or aborts the migration, as per --throttle-http-unreachable-policy.
+func (this *Throttler) checkThrottleHTTP() *base.ThrottleCheckResult {
+	if this.isHibernating() {
+		return nil
+	}
+	url := this.migrationContext.GetThrottleHTTP()
+	if url == "" {
+		atomic.StoreInt64(&this.migrationContext.ThrottleHTTPStatusCode, 0)
+		return base.NewThrottleCheckResult(false, "", base.NoThrottleReasonHint)
 	}
 
-	_, err := collectFunc()
+	statusCode, reason, err := this.readThrottleHTTPStatus(url)
 	if err != nil {
-		// If not told to ignore errors, we'll throttle on HTTP connection issues
-		if !this.migrationContext.IgnoreHTTPErrors {
-			atomic.StoreInt64(&this.migrationContext.ThrottleHTTPStatusCode, int64(-1))
+		switch this.migrationContext.ThrottleHTTPUnreachablePolicy {
+		case base.ThrottleHTTPUnreachableIgnore:
+			return nil
+		case base.ThrottleHTTPUnreachablePanic:
+			this.migrationContext.PanicAbort <- fmt.Errorf("throttle-http %s unreachable: %+v", url, err)
+			return nil
 		}
+		// We throttle on HTTP connection issues
+		statusCode = -1
 	}
-
-	firstThrottlingCollected <- true
-
-	collectInterval := time.Duration(this.migrationContext.ThrottleHTTPIntervalMillis) * time.Millisecond
-	ticker := time.Tick(collectInterval)
-	for range ticker {
-		if atomic.LoadInt64(&this.finishedMigrating) > 0 {
-			return
+	atomic.StoreInt64(&this.migrationContext.ThrottleHTTPStatusCode, int64(statusCode))
+	if statusCode != http.StatusOK {
+		message := this.throttleHttpMessage(statusCode)
+		if reason != "" {
+			message = fmt.Sprintf("%s: %s", message, reason)
 		}
+		return newThrottlingResult("http", message)
+	}
+	return base.NewThrottleCheckResult(false, "", base.NoThrottleReasonHint)
+}
 
-		sleep, err := collectFunc()
-		if err != nil {
-			// If not told to ignore errors, we'll throttle on HTTP connection issues
-			if !this.migrationContext.IgnoreHTTPErrors {
-				atomic.StoreInt64(&this.migrationContext.ThrottleHTTPStatusCode, int64(-1))
-			}
-		}
+// readThrottleHTTPStatus issues a --throttle-http request, returning the response's status code, and, with
+// --throttle-http-reason-from-body, the reason read off the response body
+func (this *Throttler) readThrottleHTTPStatus(url string) (statusCode int, reason string, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), this.httpClientTimeout)
+	defer cancel()
 
-		if sleep {
-			time.Sleep(1 * time.Second)
+	var body io.Reader
+	if this.migrationContext.ThrottleHTTPMethod == http.MethodPost {
+		requestBody, err := this.migrationContext.NewThrottleHTTPRequestBody().Render(this.migrationContext.ThrottleHTTPBodyTemplate)
+		if err != nil {
+			return 0, "", fmt.Errorf("Cannot render --throttle-http-body: %+v", err)
 		}
+		body = bytes.NewReader(requestBody)
 	}
-}
+	req, err := http.NewRequestWithContext(ctx, this.migrationContext.ThrottleHTTPMethod, url, body)
+	if err != nil {
+		return 0, "", err
+	}
+	for name, values := range this.migrationContext.ThrottleHTTPHeaders {
+		req.Header[name] = values
+	}
+	if body != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("User-Agent", fmt.Sprintf("gh-ost/%s", this.appVersion))
 
-// collectGeneralThrottleMetrics reads the once-per-sec metrics, and stores them onto this.migrationContext
-func (this *Throttler) collectGeneralThrottleMetrics() error {
-	if atomic.LoadInt64(&this.migrationContext.HibernateUntil) > 0 {
-		return nil
+	resp, err := this.httpClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	// Reading the body through lets the connection be reused
+	responseBody, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxThrottleHTTPResponseBodySize))
+	if err != nil {
+		return 0, "", err
 	}
+	if this.migrationContext.ThrottleHTTPReasonFromBody && resp.StatusCode != http.StatusOK {
+		reason = parseThrottleHTTPReason(responseBody)
+	}
+	return resp.StatusCode, reason, nil
+}
 
-	setThrottle := func(throttle bool, reason string, reasonHint base.ThrottleReasonHint) error {
-		this.migrationContext.SetThrottleGeneralCheckResult(base.NewThrottleCheckResult(throttle, reason, reasonHint))
+// checkFlagFiles throttles while a throttle flag file exists
+func (this *Throttler) checkFlagFiles() *base.ThrottleCheckResult {
+	if this.isHibernating() {
 		return nil
 	}
 
@@ -359,28 +789,168 @@ func (this *Throttler) collectGeneralThrottleMetrics() error {
 		}
 	}
 
+	// All existing flag files are accounted for; the first one found is the reason
+	var results []*base.ThrottleCheckResult
+	if this.migrationContext.ThrottleFlagFile != "" {
+		if this.flagFiles.exists(this.migrationContext.ThrottleFlagFile) {
+			// Throttle file defined and exists!
+			results = append(results, newThrottlingResult(fmt.Sprintf("flag-file:%s", this.migrationContext.ThrottleFlagFile), "flag-file"))
+		}
+	}
+	for _, flagFile := range this.migrationContext.GetThrottleAdditionalFlagFiles() {
+		if this.flagFiles.exists(flagFile) {
+			// Additional throttle file defined and exists!
+			results = append(results, newThrottlingResult(fmt.Sprintf("flag-file:%s", flagFile), fmt.Sprintf("flag-file %s", flagFile)))
+		}
+	}
+	if this.migrationContext.ThrottleFlagFileDir != "" {
+		for _, flagFile := range this.flagFiles.listDir(this.migrationContext.ThrottleFlagFileDir) {
+			results = append(results, newThrottlingResult(fmt.Sprintf("flag-file:%s", flagFile), fmt.Sprintf("flag-file %s", flagFile)))
+		}
+	}
+	return mergeThrottlingResults(results)
+}
+
+// throttleFlagFiles checks for throttle flag files, remembering what it last found: a stat error other
+// than the file not existing, e.g. an NFS hiccup, neither throttles nor ends throttling
+type throttleFlagFiles struct {
+	migrationContext *base.MigrationContext
+	found            map[string]bool
+	listed           map[string][]string
+	erred            map[string]bool
+}
+
+func newThrottleFlagFiles(migrationContext *base.MigrationContext) *throttleFlagFiles {
+	return &throttleFlagFiles{
+		migrationContext: migrationContext,
+		found:            make(map[string]bool),
+		listed:           make(map[string][]string),
+		erred:            make(map[string]bool),
+	}
+}
+
+// onError logs the first of consecutive errors on given path
+func (this *throttleFlagFiles) onError(path string, err error) {
+	if err == nil {
+		delete(this.erred, path)
+		return
+	}
+	if !this.erred[path] {
+		this.migrationContext.Log.Warningf("Cannot check throttle flag file %s; keeping its last known state: %+v", path, err)
+	}
+	this.erred[path] = true
+}
+
+// exists tells whether given flag file exists, or, upon error, whether it existed when last checked
+func (this *throttleFlagFiles) exists(path string) bool {
+	_, err := os.Stat(path)
+	if err == nil || os.IsNotExist(err) {
+		this.found[path] = (err == nil)
+		err = nil
+	}
+	this.onError(path, err)
+	return this.found[path]
+}
+
+// listDir returns the flag files within given directory, skipping subdirectories and hidden files; or, upon
+// error, those found when last listed. A missing directory holds no flag files.
+func (this *throttleFlagFiles) listDir(dir string) []string {
+	entries, err := ioutil.ReadDir(dir)
+	if err == nil || os.IsNotExist(err) {
+		flagFiles := []string{}
+		for _, entry := range entries {
+			if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			flagFiles = append(flagFiles, filepath.Join(dir, entry.Name()))
+		}
+		this.listed[dir] = flagFiles
+		err = nil
+	}
+	this.onError(dir, err)
+	return this.listed[dir]
+}
+
+// hibernate stops the migration from reading from or writing to any server for given duration, and returns when
+// hibernation ends. Upon leaving hibernation the migration throttles until the next load check.
+func (this *Throttler) hibernate(hibernateDuration time.Duration) (hibernateUntilTime time.Time) {
+	hibernateUntilTime = time.Now().Add(hibernateDuration)
+	atomic.StoreInt64(&this.migrationContext.HibernateUntil, hibernateUntilTime.UnixNano())
+	go func() {
+		time.Sleep(hibernateDuration)
+		leavingHibernation := base.NewThrottleCheckResult(true, "leaving hibernation", base.LeavingHibernationThrottleReasonHint)
+		leavingHibernation.Criterion = "critical-load-hibernate"
+		this.loadCheck.setResult(leavingHibernation)
+		atomic.StoreInt64(&this.migrationContext.HibernateUntil, 0)
+	}()
+	return hibernateUntilTime
+}
+
+// escalateCriticalLoad responds to a critical-load check per the escalation policy of --critical-load-throttle-duration,
+// --critical-load-hibernate-seconds and --critical-load-abort-after: it hibernates, or aborts the migration, as the
+// policy calls for, logging and running the on-critical-load hooks upon each escalation. It returns the policy's stage.
+func (this *Throttler) escalateCriticalLoad(met bool, variableName string, value int64, threshold int64) base.CriticalLoadStage {
+	escalation := this.migrationContext.CriticalLoadEscalation
+	stage, changed := escalation.Evaluate(met, time.Now())
+	if !met {
+		if changed {
+			this.migrationContext.Log.Infof("critical-load no longer met; ending critical-load escalation")
+		}
+		return stage
+	}
+	metFor := escalation.MetFor(time.Now())
+	criticalLoad := fmt.Sprintf("%s=%d, >=%d", variableName, value, threshold)
+	if changed {
+		this.migrationContext.Log.Errorf("critical-load met for %+v: %s. Escalating to %s", metFor.Round(time.Second), criticalLoad, stage)
+		if this.hooksExecutor != nil {
+			if stage == base.CriticalLoadAbortStage {
+				// The migration aborts right after; the hooks run first
+				this.hooksExecutor.onCriticalLoad(stage, criticalLoad, metFor)
+			} else {
+				go this.hooksExecutor.onCriticalLoad(stage, criticalLoad, metFor)
+			}
+		}
+	}
+	switch stage {
+	case base.CriticalLoadHibernateStage:
+		hibernateDuration := time.Duration(this.migrationContext.CriticalLoadHibernateSeconds) * time.Second
+		hibernateUntilTime := this.hibernate(hibernateDuration)
+		this.migrationContext.Log.Errorf("critical-load met: %s. Will hibernate for the duration of %+v, until %+v", criticalLoad, hibernateDuration, hibernateUntilTime)
+	case base.CriticalLoadAbortStage:
+		if changed {
+			this.migrationContext.PanicAbort <- fmt.Errorf("critical-load met for %+v, beyond --critical-load-abort-after: %s", metFor.Round(time.Second), criticalLoad)
+		}
+	}
+	return stage
+}
+
+// checkLoad checks critical-load, which may abort or hibernate the migration, and throttles on max-load,
+// the history list length, free disk space and the throttle query
+func (this *Throttler) checkLoad() *base.ThrottleCheckResult {
+	if this.isHibernating() {
+		return nil
+	}
+
 	criticalLoadMet, variableName, value, threshold, err := this.criticalLoadIsMet()
 	if err != nil {
-		return setThrottle(true, fmt.Sprintf("%s %s", variableName, err), base.NoThrottleReasonHint)
+		return newThrottlingResult(loadCriterion("critical-load", variableName), fmt.Sprintf("%s %s", variableName, err))
 	}
 
-	if criticalLoadMet && this.migrationContext.CriticalLoadHibernateSeconds > 0 {
+	if this.migrationContext.CriticalLoadEscalation.IsEnabled() {
+		switch this.escalateCriticalLoad(criticalLoadMet, variableName, value, threshold) {
+		case base.CriticalLoadHibernateStage:
+			return nil
+		case base.CriticalLoadThrottleStage, base.CriticalLoadAbortStage:
+			return newThrottlingResult(loadCriterion("critical-load", variableName), fmt.Sprintf("critical-load %s=%d >= %d", variableName, value, threshold))
+		}
+	} else if criticalLoadMet && this.migrationContext.CriticalLoadHibernateSeconds > 0 {
 		hibernateDuration := time.Duration(this.migrationContext.CriticalLoadHibernateSeconds) * time.Second
-		hibernateUntilTime := time.Now().Add(hibernateDuration)
-		atomic.StoreInt64(&this.migrationContext.HibernateUntil, hibernateUntilTime.UnixNano())
+		hibernateUntilTime := this.hibernate(hibernateDuration)
 		this.migrationContext.Log.Errorf("critical-load met: %s=%d, >=%d. Will hibernate for the duration of %+v, until %+v", variableName, value, threshold, hibernateDuration, hibernateUntilTime)
-		go func() {
-			time.Sleep(hibernateDuration)
-			this.migrationContext.SetThrottleGeneralCheckResult(base.NewThrottleCheckResult(true, "leaving hibernation", base.LeavingHibernationThrottleReasonHint))
-			atomic.StoreInt64(&this.migrationContext.HibernateUntil, 0)
-		}()
 		return nil
-	}
-
-	if criticalLoadMet && this.migrationContext.CriticalLoadIntervalMilliseconds == 0 {
+	} else if criticalLoadMet && this.migrationContext.CriticalLoadIntervalMilliseconds == 0 {
 		this.migrationContext.PanicAbort <- fmt.Errorf("critical-load met: %s=%d, >=%d", variableName, value, threshold)
-	}
-	if criticalLoadMet && this.migrationContext.CriticalLoadIntervalMilliseconds > 0 {
+	} else if criticalLoadMet && this.migrationContext.CriticalLoadIntervalMilliseconds > 0 {
 		this.migrationContext.Log.Errorf("critical-load met once: %s=%d, >=%d. Will check again in %d millis", variableName, value, threshold, this.migrationContext.CriticalLoadIntervalMilliseconds)
 		go func() {
 			timer := time.NewTimer(time.Millisecond * time.Duration(this.migrationContext.CriticalLoadIntervalMilliseconds))
@@ -391,74 +961,234 @@ func (this *Throttler) collectGeneralThrottleMetrics() error {
 		}()
 	}
 
-	// Back to throttle considerations
+	// Back to throttle considerations. All criteria met are accounted for; the first one met is the reason
 
-	// User-based throttle
-	if atomic.LoadInt64(&this.migrationContext.ThrottleCommandedByUser) > 0 {
-		return setThrottle(true, "commanded by user", base.UserCommandThrottleReasonHint)
+	var results []*base.ThrottleCheckResult
+	maxLoad := this.migrationContext.GetMaxLoad()
+	maxLoadVariableNames := []string{}
+	for variableName := range maxLoad {
+		maxLoadVariableNames = append(maxLoadVariableNames, variableName)
 	}
-	if this.migrationContext.ThrottleFlagFile != "" {
-		if base.FileExists(this.migrationContext.ThrottleFlagFile) {
-			// Throttle file defined and exists!
-			return setThrottle(true, "flag-file", base.NoThrottleReasonHint)
+	sort.Strings(maxLoadVariableNames)
+	for _, variableName := range maxLoadVariableNames {
+		threshold := maxLoad[variableName]
+		value, err := this.applier.ShowStatusVariable(variableName)
+		if err != nil {
+			results = append(results, newThrottlingResult(loadCriterion("max-load", variableName), fmt.Sprintf("%s %s", variableName, err)))
+		} else if value >= threshold {
+			results = append(results, newThrottlingResult(loadCriterion("max-load", variableName), fmt.Sprintf("max-load %s=%d >= %d", variableName, value, threshold)))
 		}
 	}
-	if this.migrationContext.ThrottleAdditionalFlagFile != "" {
-		if base.FileExists(this.migrationContext.ThrottleAdditionalFlagFile) {
-			// 2nd Throttle file defined and exists!
-			return setThrottle(true, "flag-file", base.NoThrottleReasonHint)
+	if this.migrationContext.CheckLoadOnReplicas {
+		for _, load := range this.readControlReplicasLoad(maxLoad) {
+			if load.err != nil {
+				results = append(results, newThrottlingResult(loadCriterion("max-load", load.variableName), fmt.Sprintf("%s %s", load.variableName, load.err)))
+			} else if load.met {
+				results = append(results, newThrottlingResult(loadCriterion("max-load", load.variableName), fmt.Sprintf("max-load %s=%d >= %d", load.variableName, load.value, load.threshold)))
+			}
 		}
 	}
-
-	maxLoad := this.migrationContext.GetMaxLoad()
-	for variableName, threshold := range maxLoad {
-		value, err := this.applier.ShowStatusVariable(variableName)
+	if maxHistoryListLength := atomic.LoadInt64(&this.migrationContext.MaxHistoryListLength); maxHistoryListLength > 0 {
+		historyListLength, err := this.applier.ShowHistoryListLength()
 		if err != nil {
-			return setThrottle(true, fmt.Sprintf("%s %s", variableName, err), base.NoThrottleReasonHint)
+			results = append(results, newThrottlingResult("history-list-length", fmt.Sprintf("history-list-length %s", err)))
+		} else {
+			atomic.StoreInt64(&this.migrationContext.CurrentHistoryListLength, historyListLength)
+			if historyListLength > maxHistoryListLength {
+				results = append(results, newThrottlingResult("history-list-length", fmt.Sprintf("history-list-length=%s", base.PrettifyThousands(historyListLength))))
+			}
 		}
-		if value >= threshold {
-			return setThrottle(true, fmt.Sprintf("max-load %s=%d >= %d", variableName, value, threshold), base.NoThrottleReasonHint)
+	}
+	if this.migrationContext.IsDiskSpaceCheckEnabled() {
+		if checkResult := this.checkFreeDiskSpace(); checkResult.ShouldThrottle {
+			results = append(results, checkResult)
 		}
 	}
 	if this.migrationContext.GetThrottleQuery() != "" {
 		if res, _ := this.applier.ExecuteThrottleQuery(); res > 0 {
-			return setThrottle(true, "throttle-query", base.NoThrottleReasonHint)
+			results = append(results, newThrottlingResult("throttle-query", "throttle-query"))
 		}
 	}
+	return mergeThrottlingResults(results)
+}
 
-	return setThrottle(false, "", base.NoThrottleReasonHint)
+// checkFreeDiskSpace throttles while the applier's free disk space is below --min-free-disk-space, and aborts the
+// migration, as critical-load does, once below --critical-free-disk-space
+func (this *Throttler) checkFreeDiskSpace() *base.ThrottleCheckResult {
+	freeBytes, totalBytes, err := this.applier.ShowFreeDiskSpace()
+	if err != nil {
+		return newThrottlingResult("free-disk-space", fmt.Sprintf("free-disk-space %s", err))
+	}
+	if threshold := this.migrationContext.CriticalFreeDiskSpace; threshold != nil {
+		if met, _ := threshold.IsMet(freeBytes, totalBytes); met {
+			this.migrationContext.PanicAbort <- fmt.Errorf("critical free disk space met: free-disk-space=%s < %s", base.DescribeFreeDiskSpace(freeBytes, totalBytes), threshold)
+			return newThrottlingResult("free-disk-space", fmt.Sprintf("free-disk-space=%s < %s", base.DescribeFreeDiskSpace(freeBytes, totalBytes), threshold))
+		}
+	}
+	if threshold := this.migrationContext.MinFreeDiskSpace; threshold != nil {
+		met, err := threshold.IsMet(freeBytes, totalBytes)
+		if err != nil {
+			return newThrottlingResult("free-disk-space", fmt.Sprintf("free-disk-space %s", err))
+		}
+		if met {
+			return newThrottlingResult("free-disk-space", fmt.Sprintf("free-disk-space=%s < %s", base.DescribeFreeDiskSpace(freeBytes, totalBytes), threshold))
+		}
+	}
+	return base.NewThrottleCheckResult(false, "", base.NoThrottleReasonHint)
 }
 
-// initiateThrottlerMetrics initiates the various processes that collect measurements
-// that may affect throttling. There are several components, all running independently,
-// that collect such metrics.
-func (this *Throttler) initiateThrottlerCollection(firstThrottlingCollected chan<- bool) {
-	go this.collectReplicationLag(firstThrottlingCollected)
-	go this.collectControlReplicasLag()
-	go this.collectThrottleHTTPStatus(firstThrottlingCollected)
+// throttleReplicasDiscovery tracks the throttle control replicas added by --discover-throttle-replicas: for each,
+// the number of consecutive discovery rounds it has been missing from. Replicas given via --throttle-control-replicas
+// are never tracked, hence never dropped.
+type throttleReplicasDiscovery struct {
+	dropAfter    int64
+	missedRounds map[mysql.InstanceKey]int64
+	// removed are discovered replicas since removed via the throttle-control-replicas interactive command,
+	// and which discovery therefore no longer adds
+	removed map[mysql.InstanceKey]bool
+}
 
-	go func() {
-		this.collectGeneralThrottleMetrics()
-		firstThrottlingCollected <- true
+func newThrottleReplicasDiscovery(dropAfter int64) *throttleReplicasDiscovery {
+	return &throttleReplicasDiscovery{
+		dropAfter:    dropAfter,
+		missedRounds: make(map[mysql.InstanceKey]int64),
+		removed:      make(map[mysql.InstanceKey]bool),
+	}
+}
 
-		throttlerMetricsTick := time.Tick(1 * time.Second)
-		for range throttlerMetricsTick {
-			if atomic.LoadInt64(&this.finishedMigrating) > 0 {
-				return
-			}
+// apply accounts for the replicas found in a discovery round, given the current throttle control replicas, and
+// returns the replicas to add, and the discovered replicas to drop, having been missing for dropAfter rounds
+func (this *throttleReplicasDiscovery) apply(found []mysql.InstanceKey, controlReplicas *mysql.InstanceKeyMap) (added, dropped []mysql.InstanceKey) {
+	for key := range this.missedRounds {
+		if !controlReplicas.HasKey(key) {
+			delete(this.missedRounds, key)
+			this.removed[key] = true
+		}
+	}
+	isFound := make(map[mysql.InstanceKey]bool)
+	for _, key := range found {
+		isFound[key] = true
+		if _, ok := this.missedRounds[key]; ok {
+			this.missedRounds[key] = 0
+		} else if !controlReplicas.HasKey(key) && !this.removed[key] {
+			this.missedRounds[key] = 0
+			added = append(added, key)
+		}
+	}
+	for key := range this.missedRounds {
+		if isFound[key] {
+			continue
+		}
+		this.missedRounds[key]++
+		if this.missedRounds[key] >= this.dropAfter {
+			delete(this.missedRounds, key)
+			dropped = append(dropped, key)
+		}
+	}
+	return added, dropped
+}
 
-			this.collectGeneralThrottleMetrics()
+// discoverThrottleReplicas runs a discovery round: it lists the replicas of the applier host, one level deep,
+// and updates the throttle control replicas accordingly. A failed listing does not count as a missed round.
+func (this *Throttler) discoverThrottleReplicas() {
+	replicaHosts, err := this.applier.ShowReplicaHosts()
+	if err != nil {
+		this.migrationContext.Log.Warningf("discover-throttle-replicas: cannot list replicas of %s: %+v", this.migrationContext.ApplierConnectionConfig.Key.String(), err)
+		return
+	}
+	var found []mysql.InstanceKey
+	for _, replicaHost := range replicaHosts {
+		if replicaHost.ServerId == this.migrationContext.ReplicaServerId {
+			// gh-ost's own binlog reader
+			continue
 		}
-	}()
+		if replicaHost.Key.Hostname == "" {
+			this.migrationContext.Log.Debugf("discover-throttle-replicas: skipping replica with server_id %d, which does not set report_host", replicaHost.ServerId)
+			continue
+		}
+		if replicaHost.Key.Port == 0 {
+			replicaHost.Key.Port = mysql.DefaultInstancePort
+		}
+		if this.migrationContext.IsThrottleReplicaDiscoveryExcluded(replicaHost.Key) {
+			continue
+		}
+		found = append(found, replicaHost.Key)
+	}
+	added, dropped := this.replicasDiscovery.apply(found, this.migrationContext.GetThrottleControlReplicaKeys())
+	for _, key := range added {
+		this.migrationContext.Log.Infof("discover-throttle-replicas: discovered replica %s; adding to throttle control replicas", key.String())
+		this.migrationContext.AddThrottleControlReplicaKey(key)
+	}
+	for _, key := range dropped {
+		this.migrationContext.Log.Infof("discover-throttle-replicas: replica %s missing for %d discovery rounds; dropping from throttle control replicas", key.String(), this.migrationContext.DiscoverThrottleReplicasDropAfter)
+		this.migrationContext.RemoveThrottleControlReplicaKey(key)
+	}
+}
+
+// collectThrottleReplicas runs discovery rounds, per --discover-throttle-replicas-interval, until the migration completes
+func (this *Throttler) collectThrottleReplicas() {
+	ticker := time.NewTicker(this.migrationContext.DiscoverThrottleReplicasInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if atomic.LoadInt64(&this.finishedMigrating) > 0 {
+			return
+		}
+		this.discoverThrottleReplicas()
+	}
+}
+
+// initiateThrottlerCollection initiates the various processes that collect measurements
+// that may affect throttling. There are several components, all running independently,
+// each on its own interval, that collect such metrics. Each signals firstThrottlingCollected
+// once upon its first collection.
+func (this *Throttler) initiateThrottlerCollection(firstThrottlingCollected chan<- bool) {
+	isDone := func() bool {
+		return atomic.LoadInt64(&this.finishedMigrating) > 0
+	}
+	if this.migrationContext.DiscoverThrottleReplicas {
+		// A first round, so that the first lag check already covers discovered replicas
+		this.discoverThrottleReplicas()
+		go this.collectThrottleReplicas()
+	}
+	go this.collectReplicationLag(firstThrottlingCollected)
+	go this.lagCheck.run(firstThrottlingCollected, isDone)
+	go this.httpCheck.run(firstThrottlingCollected, isDone)
+	go this.loadCheck.run(firstThrottlingCollected, isDone)
+	go this.flagFileCheck.run(firstThrottlingCollected, isDone)
+}
+
+// throttlingCriteria lists the criteria of given throttling results, including those overlapping within a check
+func throttlingCriteria(results []*base.ThrottleCheckResult) (criteria []string) {
+	for _, result := range results {
+		criteria = append(criteria, result.AllCriteria()...)
+	}
+	return criteria
 }
 
 // initiateThrottlerChecks initiates the throttle ticker and sets the basic behavior of throttling.
 func (this *Throttler) initiateThrottlerChecks() error {
 	throttlerTick := time.Tick(100 * time.Millisecond)
 
+	lastThrottlerTime := time.Now()
 	throttlerFunction := func() {
 		alreadyThrottling, currentReason, _ := this.migrationContext.IsThrottled()
-		shouldThrottle, throttleReason, throttleReasonHint := this.shouldThrottle()
+		throttlingResults := this.throttlingResults()
+		shouldThrottle, throttleReason, throttleReasonHint := false, "", base.NoThrottleReasonHint
+		if len(throttlingResults) > 0 {
+			shouldThrottle, throttleReason, throttleReasonHint = true, throttlingResults[0].Reason, throttlingResults[0].ReasonHint
+		}
+		// Attribute the time since the last evaluation to all criteria throttling now, such that
+		// overlapping criteria are each accounted for
+		now := time.Now()
+		if shouldThrottle {
+			this.migrationContext.AddThrottleDurations(throttlingCriteria(throttlingResults), now.Sub(lastThrottlerTime))
+		}
+		lastThrottlerTime = now
+		// Per --throttle-ramp-up-duration: scale rate limits while ramping up
+		this.migrationContext.ThrottleRampUp.SetThrottled(shouldThrottle, now)
+		rampUpFactor, _ := this.migrationContext.ThrottleRampUp.Factor(now)
+		this.migrationContext.CopyRateLimiter.SetScale(rampUpFactor)
+		this.migrationContext.DMLApplyRateLimiter.SetScale(rampUpFactor)
 		if shouldThrottle && !alreadyThrottling {
 			// New throttling
 			this.applier.WriteAndLogChangelog("throttle", throttleReason)
@@ -498,6 +1228,22 @@ func (this *Throttler) throttle(onThrottled func()) {
 	}
 }
 
+// throttleAllowingEvents is as throttle(), except that while throttled by --throttle-schedule alone, and with
+// --throttle-schedule-apply-events, it does not block, and returns true: binlog events keep applying, while
+// row copy waits
+func (this *Throttler) throttleAllowingEvents() (rowCopyThrottled bool) {
+	for {
+		shouldThrottle, _, reasonHint := this.migrationContext.IsThrottled()
+		if !shouldThrottle {
+			return false
+		}
+		if reasonHint == base.ScheduleThrottleReasonHint && this.migrationContext.ThrottleScheduleApplyEvents {
+			return true
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+}
+
 func (this *Throttler) Teardown() {
 	this.migrationContext.Log.Debugf("Tearing down...")
 	atomic.StoreInt64(&this.finishedMigrating, 1)
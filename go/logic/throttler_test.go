@@ -0,0 +1,338 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	test "github.com/openark/golib/tests"
+
+	"github.com/github/gh-ost/go/base"
+	"github.com/github/gh-ost/go/mysql"
+)
+
+func TestMaxReplicationLagResult(t *testing.T) {
+	replica1 := &mysql.ReplicationLagResult{Key: mysql.InstanceKey{Hostname: "replica1", Port: 3306}, Lag: time.Second}
+	replica2 := &mysql.ReplicationLagResult{Key: mysql.InstanceKey{Hostname: "replica2", Port: 3306}, Lag: 3 * time.Second}
+	broken := &mysql.ReplicationLagResult{Key: mysql.InstanceKey{Hostname: "replica3", Port: 3306}, Err: errors.New("replication not running")}
+
+	test.S(t).ExpectTrue(maxReplicationLagResult(nil) == nil)
+	test.S(t).ExpectEquals(maxReplicationLagResult([](*mysql.ReplicationLagResult){replica1, replica2}), replica2)
+	test.S(t).ExpectEquals(maxReplicationLagResult([](*mysql.ReplicationLagResult){replica2, replica1}), replica2)
+	// A replica whose lag cannot be read wins over any lag, regardless of order
+	test.S(t).ExpectEquals(maxReplicationLagResult([](*mysql.ReplicationLagResult){broken, replica2}), broken)
+	test.S(t).ExpectEquals(maxReplicationLagResult([](*mysql.ReplicationLagResult){replica1, broken, replica2}), broken)
+	// A replica whose lag check is skipped does not count
+	delayed := &mysql.ReplicationLagResult{Key: mysql.InstanceKey{Hostname: "delayed1", Port: 3306}, SkipReason: "delayed replica; SQL_Delay=1h0m0s"}
+	test.S(t).ExpectEquals(maxReplicationLagResult([](*mysql.ReplicationLagResult){delayed, replica1}), replica1)
+	test.S(t).ExpectTrue(maxReplicationLagResult([](*mysql.ReplicationLagResult){delayed}) == nil)
+}
+
+func TestExceedingReplicationLagResult(t *testing.T) {
+	replica1 := &mysql.ReplicationLagResult{Key: mysql.InstanceKey{Hostname: "replica1", Port: 3306}, Lag: 2 * time.Second}
+	analytics1 := &mysql.ReplicationLagResult{Key: mysql.InstanceKey{Hostname: "analytics1", Port: 3306}, Lag: 20 * time.Second}
+	broken := &mysql.ReplicationLagResult{Key: mysql.InstanceKey{Hostname: "replica3", Port: 3306}, Err: errors.New("replication not running")}
+	maxLag := func(maxLags map[string]time.Duration) func(mysql.InstanceKey) time.Duration {
+		return func(key mysql.InstanceKey) time.Duration {
+			if maxLag, ok := maxLags[key.Hostname]; ok {
+				return maxLag
+			}
+			return time.Second
+		}
+	}
+
+	test.S(t).ExpectTrue(exceedingReplicationLagResult(nil, maxLag(nil)) == nil)
+	// analytics1 lags 20s, but within its own max lag; replica1 lags 2s beyond the 1s default
+	test.S(t).ExpectEquals(exceedingReplicationLagResult([](*mysql.ReplicationLagResult){analytics1, replica1}, maxLag(map[string]time.Duration{"analytics1": 30 * time.Second})), replica1)
+	test.S(t).ExpectTrue(exceedingReplicationLagResult([](*mysql.ReplicationLagResult){analytics1}, maxLag(map[string]time.Duration{"analytics1": 30 * time.Second})) == nil)
+	// The replica exceeding its max lag the most is reported, relative to that max lag
+	test.S(t).ExpectEquals(exceedingReplicationLagResult([](*mysql.ReplicationLagResult){analytics1, replica1}, maxLag(map[string]time.Duration{"analytics1": 5 * time.Second})), analytics1)
+	test.S(t).ExpectEquals(exceedingReplicationLagResult([](*mysql.ReplicationLagResult){analytics1, replica1}, maxLag(map[string]time.Duration{"analytics1": 15 * time.Second})), replica1)
+	test.S(t).ExpectEquals(exceedingReplicationLagResult([](*mysql.ReplicationLagResult){analytics1, broken}, maxLag(map[string]time.Duration{"analytics1": 30 * time.Second})), broken)
+	skipped := &mysql.ReplicationLagResult{Key: mysql.InstanceKey{Hostname: "backup1", Port: 3306}, SkipReason: "replication stopped", Lag: time.Hour}
+	test.S(t).ExpectTrue(exceedingReplicationLagResult([](*mysql.ReplicationLagResult){skipped}, maxLag(nil)) == nil)
+}
+
+func TestExceedingReplicationLagResults(t *testing.T) {
+	replica1 := &mysql.ReplicationLagResult{Key: mysql.InstanceKey{Hostname: "replica1", Port: 3306}, Lag: 2 * time.Second}
+	replica2 := &mysql.ReplicationLagResult{Key: mysql.InstanceKey{Hostname: "replica2", Port: 3306}, Lag: 5 * time.Second}
+	replica3 := &mysql.ReplicationLagResult{Key: mysql.InstanceKey{Hostname: "replica3", Port: 3306}, Lag: 500 * time.Millisecond}
+	broken := &mysql.ReplicationLagResult{Key: mysql.InstanceKey{Hostname: "replica4", Port: 3306}, Err: errors.New("replication not running")}
+	maxLag := func(mysql.InstanceKey) time.Duration { return time.Second }
+
+	test.S(t).ExpectEquals(len(exceedingReplicationLagResults([](*mysql.ReplicationLagResult){replica3}, maxLag)), 0)
+	// All lagging replicas, the one exceeding its max lag the most first
+	results := exceedingReplicationLagResults([](*mysql.ReplicationLagResult){replica1, replica3, replica2}, maxLag)
+	test.S(t).ExpectEquals(len(results), 2)
+	test.S(t).ExpectEquals(results[0], replica2)
+	test.S(t).ExpectEquals(results[1], replica1)
+	results = exceedingReplicationLagResults([](*mysql.ReplicationLagResult){replica1, broken}, maxLag)
+	test.S(t).ExpectEquals(len(results), 2)
+	test.S(t).ExpectEquals(results[0], broken)
+	test.S(t).ExpectEquals(results[1], replica1)
+}
+
+func TestMergeThrottlingResults(t *testing.T) {
+	test.S(t).ExpectFalse(mergeThrottlingResults(nil).ShouldThrottle)
+
+	maxLoad := newThrottlingResult("max-load:Threads_running", "max-load Threads_running=80 >= 50")
+	merged := mergeThrottlingResults([]*base.ThrottleCheckResult{
+		maxLoad,
+		newThrottlingResult("max-load:Threads_connected", "max-load Threads_connected=900 >= 500"),
+		newThrottlingResult("throttle-query", "throttle-query"),
+	})
+	test.S(t).ExpectTrue(merged.ShouldThrottle)
+	test.S(t).ExpectEquals(merged.Reason, "max-load Threads_running=80 >= 50")
+	test.S(t).ExpectEquals(strings.Join(merged.AllCriteria(), ","), "max-load:Threads_running,max-load:Threads_connected,throttle-query")
+	// The merged results are left as is
+	test.S(t).ExpectEquals(len(maxLoad.OtherCriteria), 0)
+}
+
+func TestThrottleCheckGetResult(t *testing.T) {
+	check := newThrottleCheck("load", constantInterval(time.Second), constantInterval(0), nil)
+	// Not yet evaluated
+	test.S(t).ExpectTrue(check.getResult().ShouldThrottle)
+	test.S(t).ExpectEquals(check.getResult().Reason, "load check pending")
+
+	check.setResult(base.NewThrottleCheckResult(true, "throttle-query", base.NoThrottleReasonHint))
+	test.S(t).ExpectEquals(check.getResult().Reason, "throttle-query")
+	// A nil result keeps the last known result
+	check.setResult(nil)
+	test.S(t).ExpectEquals(check.getResult().Reason, "throttle-query")
+	check.setResult(base.NewThrottleCheckResult(false, "", base.NoThrottleReasonHint))
+	test.S(t).ExpectFalse(check.getResult().ShouldThrottle)
+
+	// Slow evaluations do not make for a stale result, as long as each completes in time
+	check.checkedAt = time.Now().Add(-throttleCheckStaleIntervals*time.Second - time.Second)
+	test.S(t).ExpectFalse(check.getResult().ShouldThrottle)
+	check.evaluatingSince = time.Now().Add(-throttleCheckStaleIntervals * time.Second / 2)
+	test.S(t).ExpectFalse(check.getResult().ShouldThrottle)
+	// A hanging evaluation does
+	check.evaluatingSince = time.Now().Add(-throttleCheckStaleIntervals*time.Second - time.Millisecond)
+	test.S(t).ExpectTrue(check.getResult().ShouldThrottle)
+	test.S(t).ExpectTrue(strings.HasPrefix(check.getResult().Reason, "load check stale, last checked 6"))
+	check.setResult(nil)
+	test.S(t).ExpectFalse(check.getResult().ShouldThrottle)
+}
+
+func TestThrottleCheckStaleAfter(t *testing.T) {
+	// The default --throttle-http-interval-millis and --throttle-http-timeout-millis
+	httpCheck := newThrottleCheck("http", constantInterval(100*time.Millisecond), constantInterval(time.Second), nil)
+	test.S(t).ExpectEquals(httpCheck.staleAfter(), 1100*time.Millisecond)
+
+	lagCheck := newThrottleCheck("lag", constantInterval(time.Second), constantInterval(0), nil)
+	test.S(t).ExpectEquals(lagCheck.staleAfter(), throttleCheckStaleIntervals*time.Second)
+
+	// A slow endpoint, responding within the timeout, is never stale
+	httpCheck.setResult(base.NewThrottleCheckResult(false, "", base.NoThrottleReasonHint))
+	httpCheck.evaluatingSince = time.Now().Add(-900 * time.Millisecond)
+	test.S(t).ExpectFalse(httpCheck.getResult().ShouldThrottle)
+	httpCheck.evaluatingSince = time.Now().Add(-1200 * time.Millisecond)
+	test.S(t).ExpectTrue(httpCheck.getResult().ShouldThrottle)
+	test.S(t).ExpectEquals(httpCheck.getResult().Criterion, "http-check-stale")
+}
+
+func TestLoadCriterion(t *testing.T) {
+	test.S(t).ExpectEquals(loadCriterion("max-load", "Threads_running"), "max-load:Threads_running")
+	test.S(t).ExpectEquals(loadCriterion("critical-load", "replica1:3306 Threads_running"), "critical-load:replica1:3306:Threads_running")
+}
+
+func TestThrottleReplicasDiscoveryApply(t *testing.T) {
+	static := mysql.InstanceKey{Hostname: "static", Port: 3306}
+	replica1 := mysql.InstanceKey{Hostname: "replica1", Port: 3306}
+	replica2 := mysql.InstanceKey{Hostname: "replica2", Port: 3306}
+	controlReplicas := mysql.NewInstanceKeyMap()
+	controlReplicas.AddKey(static)
+	discovery := newThrottleReplicasDiscovery(2)
+
+	added, dropped := discovery.apply([]mysql.InstanceKey{static, replica1, replica2}, controlReplicas)
+	test.S(t).ExpectEquals(len(added), 2)
+	test.S(t).ExpectEquals(len(dropped), 0)
+	controlReplicas.AddKeys(added)
+
+	// replica1 misses a single round, then shows again: it is kept
+	added, dropped = discovery.apply([]mysql.InstanceKey{replica2}, controlReplicas)
+	test.S(t).ExpectEquals(len(added), 0)
+	test.S(t).ExpectEquals(len(dropped), 0)
+	added, dropped = discovery.apply([]mysql.InstanceKey{replica1, replica2}, controlReplicas)
+	test.S(t).ExpectEquals(len(added), 0)
+	test.S(t).ExpectEquals(len(dropped), 0)
+
+	// replica1 misses two consecutive rounds: it is dropped. static is never dropped
+	discovery.apply([]mysql.InstanceKey{replica2}, controlReplicas)
+	added, dropped = discovery.apply([]mysql.InstanceKey{replica2}, controlReplicas)
+	test.S(t).ExpectEquals(len(added), 0)
+	test.S(t).ExpectEquals(len(dropped), 1)
+	test.S(t).ExpectEquals(dropped[0], replica1)
+	delete(*controlReplicas, replica1)
+
+	// replica1 shows again: it is added again
+	added, _ = discovery.apply([]mysql.InstanceKey{replica1, replica2}, controlReplicas)
+	test.S(t).ExpectEquals(len(added), 1)
+	test.S(t).ExpectEquals(added[0], replica1)
+	controlReplicas.AddKeys(added)
+
+	// replica2 is removed by interactive command: it is not added again
+	delete(*controlReplicas, replica2)
+	added, dropped = discovery.apply([]mysql.InstanceKey{replica1, replica2}, controlReplicas)
+	test.S(t).ExpectEquals(len(added), 0)
+	test.S(t).ExpectEquals(len(dropped), 0)
+}
+
+func TestCheckFlagFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	sharedFlagFile := filepath.Join(tmpDir, "shared.throttle")
+	flagFileDir := filepath.Join(tmpDir, "throttle.d")
+	test.S(t).ExpectNil(os.Mkdir(flagFileDir, 0755))
+
+	migrationContext := base.NewMigrationContext()
+	migrationContext.ThrottleAdditionalFlagFile = filepath.Join(tmpDir, "gh-ost.throttle") + ", " + sharedFlagFile
+	migrationContext.ThrottleFlagFileDir = flagFileDir
+	throttler := NewThrottler(migrationContext, nil, nil, nil, nil, "1.2.3")
+	test.S(t).ExpectFalse(throttler.checkFlagFiles().ShouldThrottle)
+
+	test.S(t).ExpectNil(os.WriteFile(sharedFlagFile, nil, 0644))
+	result := throttler.checkFlagFiles()
+	test.S(t).ExpectTrue(result.ShouldThrottle)
+	test.S(t).ExpectEquals(result.Reason, "flag-file "+sharedFlagFile)
+	test.S(t).ExpectEquals(result.Criterion, "flag-file:"+sharedFlagFile)
+	test.S(t).ExpectNil(os.Remove(sharedFlagFile))
+
+	// hidden files do not throttle; removing one of two flag files keeps throttling
+	test.S(t).ExpectNil(os.WriteFile(filepath.Join(flagFileDir, ".keep"), nil, 0644))
+	test.S(t).ExpectFalse(throttler.checkFlagFiles().ShouldThrottle)
+	test.S(t).ExpectNil(os.WriteFile(filepath.Join(flagFileDir, "backup"), nil, 0644))
+	test.S(t).ExpectNil(os.WriteFile(filepath.Join(flagFileDir, "maintenance"), nil, 0644))
+	result = throttler.checkFlagFiles()
+	test.S(t).ExpectEquals(result.Reason, "flag-file "+filepath.Join(flagFileDir, "backup"))
+	test.S(t).ExpectEquals(strings.Join(result.AllCriteria(), ","), "flag-file:"+filepath.Join(flagFileDir, "backup")+",flag-file:"+filepath.Join(flagFileDir, "maintenance"))
+	test.S(t).ExpectNil(os.Remove(filepath.Join(flagFileDir, "backup")))
+	test.S(t).ExpectEquals(throttler.checkFlagFiles().Reason, "flag-file "+filepath.Join(flagFileDir, "maintenance"))
+
+	// a directory which cannot be read keeps its last known flag files
+	test.S(t).ExpectNil(os.RemoveAll(flagFileDir))
+	test.S(t).ExpectNil(os.WriteFile(flagFileDir, nil, 0644))
+	migrationContext.ThrottleFlagFileDir = filepath.Join(flagFileDir, "nested")
+	throttler.flagFiles.listed[migrationContext.ThrottleFlagFileDir] = []string{"previously-found"}
+	test.S(t).ExpectEquals(throttler.checkFlagFiles().Reason, "flag-file previously-found")
+}
+
+// TestThrottlerOverlappingCriteria validates that all criteria throttling at once, within a check and across
+// checks, are accounted for
+func TestThrottlerOverlappingCriteria(t *testing.T) {
+	tmpDir := t.TempDir()
+	flagFile := filepath.Join(tmpDir, "gh-ost.throttle")
+	additionalFlagFile := filepath.Join(tmpDir, "shared.throttle")
+	test.S(t).ExpectNil(os.WriteFile(flagFile, nil, 0644))
+	test.S(t).ExpectNil(os.WriteFile(additionalFlagFile, nil, 0644))
+
+	migrationContext := base.NewMigrationContext()
+	migrationContext.ThrottleFlagFile = flagFile
+	migrationContext.ThrottleAdditionalFlagFile = additionalFlagFile
+	migrationContext.MaxLagMillisecondsThrottleThreshold = 1500
+	throttler := NewThrottler(migrationContext, nil, nil, nil, nil, "1.2.3")
+	throttler.flagFileCheck.evaluateOnce()
+	throttler.loadCheck.setResult(mergeThrottlingResults([]*base.ThrottleCheckResult{
+		newThrottlingResult("max-load:Threads_running", "max-load Threads_running=80 >= 50"),
+		newThrottlingResult("max-load:Threads_connected", "max-load Threads_connected=900 >= 500"),
+	}))
+	throttler.httpCheck.setResult(base.NewThrottleCheckResult(false, "", base.NoThrottleReasonHint))
+	throttler.lagCheck.setResult(mergeThrottlingResults([]*base.ThrottleCheckResult{
+		newThrottlingResult("replica-lag:replica2:3306", "replica2:3306 replica-lag=5.000000s"),
+		newThrottlingResult("replica-lag:replica1:3306", "replica1:3306 replica-lag=2.000000s"),
+	}))
+
+	results := throttler.throttlingResults()
+	test.S(t).ExpectEquals(len(results), 3)
+	test.S(t).ExpectEquals(results[0].Reason, "flag-file")
+	criteria := throttlingCriteria(results)
+	test.S(t).ExpectEquals(strings.Join(criteria, ","), strings.Join([]string{
+		"flag-file:" + flagFile, "flag-file:" + additionalFlagFile,
+		"max-load:Threads_running", "max-load:Threads_connected",
+		"replica-lag:replica2:3306", "replica-lag:replica1:3306",
+	}, ","))
+
+	migrationContext.AddThrottleDurations(criteria, time.Minute)
+	total, durations := migrationContext.GetThrottleDurations()
+	test.S(t).ExpectEquals(total, time.Minute)
+	test.S(t).ExpectEquals(len(durations), 6)
+	for _, duration := range durations {
+		test.S(t).ExpectEquals(duration.Duration, time.Minute)
+	}
+}
+
+func TestThrottleFlagFilesExists(t *testing.T) {
+	tmpDir := t.TempDir()
+	mountDir := filepath.Join(tmpDir, "mnt")
+	flagFile := filepath.Join(mountDir, "gh-ost.throttle")
+	test.S(t).ExpectNil(os.Mkdir(mountDir, 0755))
+
+	flagFiles := newThrottleFlagFiles(base.NewMigrationContext())
+	test.S(t).ExpectFalse(flagFiles.exists(flagFile))
+	test.S(t).ExpectNil(os.WriteFile(flagFile, nil, 0644))
+	test.S(t).ExpectTrue(flagFiles.exists(flagFile))
+
+	// stat fails with other than the file not existing: the flag file keeps its last known state
+	test.S(t).ExpectNil(os.RemoveAll(mountDir))
+	test.S(t).ExpectNil(os.WriteFile(mountDir, nil, 0644))
+	test.S(t).ExpectTrue(flagFiles.exists(flagFile))
+	test.S(t).ExpectTrue(flagFiles.erred[flagFile])
+
+	test.S(t).ExpectNil(os.Remove(mountDir))
+	test.S(t).ExpectFalse(flagFiles.exists(flagFile))
+	test.S(t).ExpectFalse(flagFiles.erred[flagFile])
+}
+
+func TestParseThrottleHTTPReason(t *testing.T) {
+	test.S(t).ExpectEquals(parseThrottleHTTPReason([]byte(`{"reason": "replica lag on db-3", "lag": 4.2}`)), "replica lag on db-3")
+	test.S(t).ExpectEquals(parseThrottleHTTPReason([]byte("maintenance window\nuntil 04:00")), "maintenance window")
+	test.S(t).ExpectEquals(parseThrottleHTTPReason(nil), "")
+	test.S(t).ExpectEquals(len(parseThrottleHTTPReason([]byte(strings.Repeat("x", 500)))), maxThrottleHTTPReasonLength+len("..."))
+}
+
+func TestCheckThrottleHTTP(t *testing.T) {
+	var requestBody map[string]interface{}
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&requestBody)
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"reason": "replica lag on db-3"}`))
+	}))
+	defer server.Close()
+
+	migrationContext := base.NewMigrationContext()
+	migrationContext.DatabaseName = "test"
+	migrationContext.OriginalTableName = "orders"
+	migrationContext.ThrottleHTTPTimeoutMillis = 1000
+	migrationContext.ThrottleHTTPMethod = http.MethodPost
+	migrationContext.ThrottleHTTPReasonFromBody = true
+	test.S(t).ExpectNil(migrationContext.AddThrottleHTTPHeader("Authorization: Bearer secret"))
+	migrationContext.SetThrottleHTTP(server.URL)
+	throttler := NewThrottler(migrationContext, nil, nil, nil, nil, "1.2.3")
+
+	result := throttler.checkThrottleHTTP()
+	test.S(t).ExpectTrue(result.ShouldThrottle)
+	test.S(t).ExpectEquals(result.Reason, "Too many requests (http=429): replica lag on db-3")
+	test.S(t).ExpectEquals(authorization, "Bearer secret")
+	test.S(t).ExpectEquals(requestBody["table"], "orders")
+
+	// Unreachable
+	server.Close()
+	result = throttler.checkThrottleHTTP()
+	test.S(t).ExpectTrue(result.ShouldThrottle)
+	test.S(t).ExpectEquals(result.Reason, "Connection error (http=-1)")
+	migrationContext.ThrottleHTTPUnreachablePolicy = base.ThrottleHTTPUnreachableIgnore
+	test.S(t).ExpectTrue(throttler.checkThrottleHTTP() == nil)
+}
@@ -0,0 +1,245 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type Type string
+
+const (
+	GaugeType     Type = "gauge"
+	CounterType   Type = "counter"
+	HistogramType Type = "histogram"
+)
+
+// Sample is a single value of a metric family. Histograms have several samples per label set:
+// the _bucket, _sum and _count series.
+type Sample struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+}
+
+// Family is a metric, e.g. ghost_rows_copied_total, along with its samples
+type Family struct {
+	Name    string
+	Help    string
+	Type    Type
+	Samples []Sample
+}
+
+func NewFamily(name string, help string, metricType Type) *Family {
+	return &Family{Name: name, Help: help, Type: metricType}
+}
+
+// Add adds a sample onto the family, named after the family
+func (this *Family) Add(labels map[string]string, value float64) *Family {
+	this.Samples = append(this.Samples, Sample{Name: this.Name, Labels: labels, Value: value})
+	return this
+}
+
+// Collector provides metrics upon each scrape. A migration is a collector; several migrations may share a registry,
+// their samples told apart by labels.
+type Collector interface {
+	Collect() []*Family
+}
+
+// Registry serves the metrics of its collectors, in the Prometheus text exposition format
+type Registry struct {
+	mutex      *sync.Mutex
+	collectors []Collector
+}
+
+// DefaultRegistry is where migrations register their metrics, and what --prometheus-listen-addr serves
+var DefaultRegistry = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{
+		mutex: &sync.Mutex{},
+	}
+}
+
+func (this *Registry) Register(collector Collector) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.collectors = append(this.collectors, collector)
+}
+
+func (this *Registry) Unregister(collector Collector) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	for i, registered := range this.collectors {
+		if registered == collector {
+			this.collectors = append(this.collectors[:i], this.collectors[i+1:]...)
+			return
+		}
+	}
+}
+
+// Gather collects the metrics of all collectors, merging families of the same name, sorted by name
+func (this *Registry) Gather() []*Family {
+	this.mutex.Lock()
+	collectors := append([]Collector{}, this.collectors...)
+	this.mutex.Unlock()
+
+	familiesMap := make(map[string]*Family)
+	for _, collector := range collectors {
+		for _, family := range collector.Collect() {
+			if merged, ok := familiesMap[family.Name]; ok {
+				merged.Samples = append(merged.Samples, family.Samples...)
+				continue
+			}
+			familiesMap[family.Name] = &Family{Name: family.Name, Help: family.Help, Type: family.Type, Samples: append([]Sample{}, family.Samples...)}
+		}
+	}
+	families := make([]*Family, 0, len(familiesMap))
+	for _, family := range familiesMap {
+		families = append(families, family)
+	}
+	sort.Slice(families, func(i, j int) bool { return families[i].Name < families[j].Name })
+	return families
+}
+
+// WriteText writes the metrics of all collectors in the Prometheus text exposition format
+func (this *Registry) WriteText(writer io.Writer) error {
+	w := bufio.NewWriter(writer)
+	for _, family := range this.Gather() {
+		fmt.Fprintf(w, "# HELP %s %s\n", family.Name, escapeHelp(family.Help))
+		fmt.Fprintf(w, "# TYPE %s %s\n", family.Name, family.Type)
+		for _, sample := range family.Samples {
+			fmt.Fprintf(w, "%s%s %s\n", sample.Name, formatLabels(sample.Labels), formatValue(sample.Value))
+		}
+	}
+	return w.Flush()
+}
+
+// ServeHTTP serves the metrics on GET and HEAD requests; the endpoint is read-only
+func (this *Registry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if r.Method == http.MethodHead {
+		return
+	}
+	this.WriteText(w)
+}
+
+// Serve listens on given address, and serves the registry's metrics on /metrics in the background.
+// It only returns an error should listening fail.
+func Serve(addr string, registry *Registry) (net.Listener, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", registry)
+	go http.Serve(listener, mux)
+	return listener, nil
+}
+
+// Histogram counts observations into cumulative buckets
+type Histogram struct {
+	mutex   *sync.Mutex
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     float64
+}
+
+// NewHistogram returns a histogram with given bucket upper bounds, in increasing order. The +Inf bucket is implied.
+func NewHistogram(buckets []float64) *Histogram {
+	return &Histogram{
+		mutex:   &sync.Mutex{},
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+func (this *Histogram) Observe(value float64) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	for i, bucket := range this.buckets {
+		if value <= bucket {
+			this.counts[i]++
+		}
+	}
+	this.count++
+	this.sum += value
+}
+
+// AddTo adds the histogram's _bucket, _sum and _count samples onto given family
+func (this *Histogram) AddTo(family *Family, labels map[string]string) *Family {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	for i, bucket := range this.buckets {
+		family.Samples = append(family.Samples, Sample{Name: family.Name + "_bucket", Labels: withLabel(labels, "le", formatValue(bucket)), Value: float64(this.counts[i])})
+	}
+	family.Samples = append(family.Samples,
+		Sample{Name: family.Name + "_bucket", Labels: withLabel(labels, "le", "+Inf"), Value: float64(this.count)},
+		Sample{Name: family.Name + "_sum", Labels: labels, Value: this.sum},
+		Sample{Name: family.Name + "_count", Labels: labels, Value: float64(this.count)},
+	)
+	return family
+}
+
+func withLabel(labels map[string]string, name string, value string) map[string]string {
+	result := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		result[k] = v
+	}
+	result[name] = value
+	return result
+}
+
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tokens := make([]string, len(names))
+	for i, name := range names {
+		tokens[i] = fmt.Sprintf(`%s="%s"`, name, escapeLabelValue(labels[name]))
+	}
+	return fmt.Sprintf("{%s}", strings.Join(tokens, ","))
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func escapeHelp(help string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(value)
+}
@@ -0,0 +1,98 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/outbrain/golib/log"
+	test "github.com/outbrain/golib/tests"
+)
+
+func init() {
+	log.SetLevel(log.ERROR)
+}
+
+type testCollector struct {
+	table string
+	rows  float64
+}
+
+func (this *testCollector) Collect() []*Family {
+	labels := map[string]string{"database": "test", "table": this.table}
+	return []*Family{
+		NewFamily("ghost_rows_copied_total", "Rows copied", CounterType).Add(labels, this.rows),
+	}
+}
+
+func TestRegistryWriteText(t *testing.T) {
+	registry := NewRegistry()
+	orders := &testCollector{table: "orders", rows: 1500}
+	registry.Register(orders)
+	registry.Register(&testCollector{table: `we"ird`, rows: 2})
+
+	var buf bytes.Buffer
+	test.S(t).ExpectNil(registry.WriteText(&buf))
+	test.S(t).ExpectEquals(buf.String(), `# HELP ghost_rows_copied_total Rows copied
+# TYPE ghost_rows_copied_total counter
+ghost_rows_copied_total{database="test",table="orders"} 1500
+ghost_rows_copied_total{database="test",table="we\"ird"} 2
+`)
+
+	registry.Unregister(orders)
+	buf.Reset()
+	test.S(t).ExpectNil(registry.WriteText(&buf))
+	test.S(t).ExpectEquals(buf.String(), `# HELP ghost_rows_copied_total Rows copied
+# TYPE ghost_rows_copied_total counter
+ghost_rows_copied_total{database="test",table="we\"ird"} 2
+`)
+}
+
+func TestHistogram(t *testing.T) {
+	histogram := NewHistogram([]float64{0.1, 1})
+	histogram.Observe(0.05)
+	histogram.Observe(0.5)
+	histogram.Observe(3)
+
+	family := histogram.AddTo(NewFamily("ghost_chunk_duration_seconds", "Chunk duration", HistogramType), map[string]string{"table": "t"})
+	registry := NewRegistry()
+	var buf bytes.Buffer
+	registry.Register(collectorFunc(func() []*Family { return []*Family{family} }))
+	test.S(t).ExpectNil(registry.WriteText(&buf))
+	test.S(t).ExpectEquals(buf.String(), `# HELP ghost_chunk_duration_seconds Chunk duration
+# TYPE ghost_chunk_duration_seconds histogram
+ghost_chunk_duration_seconds_bucket{le="0.1",table="t"} 1
+ghost_chunk_duration_seconds_bucket{le="1",table="t"} 2
+ghost_chunk_duration_seconds_bucket{le="+Inf",table="t"} 3
+ghost_chunk_duration_seconds_sum{table="t"} 3.55
+ghost_chunk_duration_seconds_count{table="t"} 3
+`)
+}
+
+func TestRegistryServeHTTP(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(&testCollector{table: "orders", rows: 1})
+	{
+		recorder := httptest.NewRecorder()
+		registry.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		test.S(t).ExpectEquals(recorder.Code, http.StatusOK)
+		test.S(t).ExpectTrue(bytes.Contains(recorder.Body.Bytes(), []byte(`ghost_rows_copied_total{database="test",table="orders"} 1`)))
+	}
+	{
+		recorder := httptest.NewRecorder()
+		registry.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+		test.S(t).ExpectEquals(recorder.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+type collectorFunc func() []*Family
+
+func (this collectorFunc) Collect() []*Family {
+	return this()
+}
@@ -27,10 +27,12 @@ const (
 )
 
 // BinlogCoordinates described binary log coordinates in the form of log file & log position.
+// On GTID enabled servers, coordinates may also carry the executed GTID set at that position.
 type BinlogCoordinates struct {
-	LogFile string
-	LogPos  int64
-	Type    BinlogType
+	LogFile         string
+	LogPos          int64
+	Type            BinlogType
+	ExecutedGtidSet string
 }
 
 // ParseInstanceKey will parse an InstanceKey from a string representation such as 127.0.0.1:3306
@@ -70,6 +72,11 @@ func (this *BinlogCoordinates) IsEmpty() bool {
 	return this.LogFile == ""
 }
 
+// HasExecutedGtidSet returns true if these coordinates carry an executed GTID set
+func (this *BinlogCoordinates) HasExecutedGtidSet() bool {
+	return this.ExecutedGtidSet != ""
+}
+
 // SmallerThan returns true if this coordinate is strictly smaller than the other.
 func (this *BinlogCoordinates) SmallerThan(other *BinlogCoordinates) bool {
 	if this.LogFile < other.LogFile {
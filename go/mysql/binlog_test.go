@@ -37,6 +37,16 @@ func TestBinlogCoordinates(t *testing.T) {
 	test.S(t).ExpectTrue(c1.SmallerThanOrEquals(&c3))
 }
 
+func TestBinlogCoordinatesExecutedGtidSet(t *testing.T) {
+	c1 := BinlogCoordinates{LogFile: "mysql-bin.00017", LogPos: 104}
+	c2 := BinlogCoordinates{LogFile: "mysql-bin.00017", LogPos: 104, ExecutedGtidSet: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"}
+
+	test.S(t).ExpectFalse(c1.HasExecutedGtidSet())
+	test.S(t).ExpectTrue(c2.HasExecutedGtidSet())
+	test.S(t).ExpectTrue(c1.Equals(&c2))
+	test.S(t).ExpectEquals(c2.DisplayString(), "mysql-bin.00017:104")
+}
+
 func TestBinlogNext(t *testing.T) {
 	c1 := BinlogCoordinates{LogFile: "mysql-bin.00017", LogPos: 104}
 	cres, err := c1.NextFileCoordinates()
@@ -12,14 +12,65 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-sql-driver/mysql"
 )
 
 const (
 	TLS_CONFIG_KEY = "ghost"
+	// DefaultTimeZone is the time_zone gh-ost sessions run in, unless configured otherwise
+	DefaultTimeZone = "+00:00"
 )
 
+var timeZoneOffsetRegexp = regexp.MustCompile(`^([+-])([0-9]{1,2}):([0-9]{2})$`)
+
+var sessionVariableNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// reservedSessionVariables are set on connections by gh-ost itself, and may not be overridden
+var reservedSessionVariables = map[string]string{
+	"time_zone":  "use --connection-time-zone",
+	"sql_mode":   "use --sql-mode",
+	"autocommit": "gh-ost sessions autocommit",
+}
+
+// SessionVariable is a variable set on each new connection, e.g. innodb_lock_wait_timeout=10
+type SessionVariable struct {
+	Name  string
+	Value string
+}
+
+// ParseSessionVariable parses a `name=value` token. The value is an SQL expression, used as is:
+// string values are to be quoted, e.g. optimizer_switch='index_merge=off'
+func ParseSessionVariable(token string) (*SessionVariable, error) {
+	tokens := strings.SplitN(token, "=", 2)
+	if len(tokens) != 2 {
+		return nil, fmt.Errorf("Expected name=value, got: %s", token)
+	}
+	variable := &SessionVariable{
+		Name:  strings.ToLower(strings.TrimSpace(tokens[0])),
+		Value: strings.TrimSpace(tokens[1]),
+	}
+	if !sessionVariableNameRegexp.MatchString(variable.Name) {
+		return nil, fmt.Errorf("Invalid session variable name: %s", tokens[0])
+	}
+	if reason, ok := reservedSessionVariables[variable.Name]; ok {
+		return nil, fmt.Errorf("Session variable %s may not be overridden: %s", variable.Name, reason)
+	}
+	if variable.Value == "" {
+		return nil, fmt.Errorf("Empty value for session variable %s", variable.Name)
+	}
+	return variable, nil
+}
+
+func (this SessionVariable) String() string {
+	return fmt.Sprintf("%s=%s", this.Name, this.Value)
+}
+
 // ConnectionConfig is the minimal configuration required to connect to a MySQL server
 type ConnectionConfig struct {
 	Key        InstanceKey
@@ -28,6 +79,60 @@ type ConnectionConfig struct {
 	ImpliedKey *InstanceKey
 	tlsConfig  *tls.Config
 	Timeout    float64
+	// ShowGeneratedInvisiblePrimaryKeys makes generated invisible primary keys (MySQL 8.0.30+)
+	// visible to SHOW COLUMNS and information_schema on this connection
+	ShowGeneratedInvisiblePrimaryKeys bool
+	// SQLMode is an expression, set as the sql_mode of each new connection
+	SQLMode string
+	// SessionVariables are set on each new connection
+	SessionVariables []SessionVariable
+	// TimeZone is set as the time_zone of each new connection; empty means DefaultTimeZone
+	TimeZone string
+}
+
+// IsTimeZoneOffset tells whether given time_zone value is a fixed offset, such as '+00:00', which has no DST
+func IsTimeZoneOffset(timeZone string) bool {
+	return timeZoneOffsetRegexp.MatchString(timeZone)
+}
+
+// hasOffsetChanges tells whether given location's UTC offset changes within a year of given time, e.g. on DST
+func hasOffsetChanges(location *time.Location, since time.Time) bool {
+	_, offset := since.In(location).Zone()
+	for day := 1; day <= 366; day++ {
+		if _, dayOffset := since.AddDate(0, 0, day).In(location).Zone(); dayOffset != offset {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseTimeZone parses a time_zone value, either an offset such as '+00:00' or a named time zone of a
+// fixed offset such as 'UTC', into the location TIMESTAMP values read in that time zone are in. Named time
+// zones observing DST are rejected: TIMESTAMP values in the hour repeated as DST ends are ambiguous.
+func ParseTimeZone(timeZone string) (*time.Location, error) {
+	if submatch := timeZoneOffsetRegexp.FindStringSubmatch(timeZone); submatch != nil {
+		hours, _ := strconv.Atoi(submatch[2])
+		minutes, _ := strconv.Atoi(submatch[3])
+		if hours > 14 || minutes > 59 {
+			return nil, fmt.Errorf("Invalid time zone offset: %s", timeZone)
+		}
+		offset := hours*3600 + minutes*60
+		if submatch[1] == "-" {
+			offset = -offset
+		}
+		return time.FixedZone(timeZone, offset), nil
+	}
+	if timeZone == "" || strings.EqualFold(timeZone, "SYSTEM") || strings.ContainsAny(timeZone, "'\\\" ") {
+		return nil, fmt.Errorf("Invalid time zone: '%s'. Expecting an offset such as '+00:00', or a named time zone", timeZone)
+	}
+	location, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid time zone: '%s': %+v", timeZone, err)
+	}
+	if hasOffsetChanges(location, time.Now()) {
+		return nil, fmt.Errorf("Invalid time zone: '%s' observes DST, making TIMESTAMP values in the hour repeated as DST ends ambiguous. Expecting an offset such as '+00:00', or a named time zone of a fixed offset", timeZone)
+	}
+	return location, nil
 }
 
 func NewConnectionConfig() *ConnectionConfig {
@@ -46,6 +151,7 @@ func (this *ConnectionConfig) DuplicateCredentials(key InstanceKey) *ConnectionC
 		Password:  this.Password,
 		tlsConfig: this.tlsConfig,
 		Timeout:   this.Timeout,
+		TimeZone:  this.TimeZone,
 	}
 	config.ImpliedKey = &config.Key
 	return config
@@ -119,5 +225,23 @@ func (this *ConnectionConfig) GetDBUri(databaseName string) string {
 	if this.tlsConfig != nil {
 		tlsOption = TLS_CONFIG_KEY
 	}
-	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?timeout=%fs&readTimeout=%fs&writeTimeout=%fs&interpolateParams=%t&autocommit=true&charset=utf8mb4,utf8,latin1&tls=%s", this.User, this.Password, hostname, this.Key.Port, databaseName, this.Timeout, this.Timeout, this.Timeout, interpolateParams, tlsOption)
+	uri := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?timeout=%fs&readTimeout=%fs&writeTimeout=%fs&interpolateParams=%t&autocommit=true&charset=utf8mb4,utf8,latin1&tls=%s", this.User, this.Password, hostname, this.Key.Port, databaseName, this.Timeout, this.Timeout, this.Timeout, interpolateParams, tlsOption)
+	// Sessions run in UTC by default, so that TIMESTAMP values read as strings (e.g. unique key range values)
+	// are unambiguous, even across a DST change, and match the UTC values the driver writes time.Time as.
+	timeZone := this.TimeZone
+	if timeZone == "" {
+		timeZone = DefaultTimeZone
+	}
+	uri = fmt.Sprintf("%s&time_zone=%s", uri, url.QueryEscape(fmt.Sprintf("'%s'", timeZone)))
+	if this.ShowGeneratedInvisiblePrimaryKeys {
+		// Set as a session variable on each new connection
+		uri = fmt.Sprintf("%s&show_gipk_in_create_table_and_information_schema=ON", uri)
+	}
+	if this.SQLMode != "" {
+		uri = fmt.Sprintf("%s&sql_mode=%s", uri, url.QueryEscape(this.SQLMode))
+	}
+	for _, variable := range this.SessionVariables {
+		uri = fmt.Sprintf("%s&%s=%s", uri, variable.Name, url.QueryEscape(variable.Value))
+	}
+	return uri
 }
@@ -8,6 +8,7 @@ package mysql
 import (
 	"crypto/tls"
 	"testing"
+	"time"
 
 	"github.com/outbrain/golib/log"
 	test "github.com/outbrain/golib/tests"
@@ -69,7 +70,7 @@ func TestGetDBUri(t *testing.T) {
 	c.Password = "penguin"
 
 	uri := c.GetDBUri("test")
-	test.S(t).ExpectEquals(uri, "gromit:penguin@tcp(myhost:3306)/test?timeout=0.000000s&readTimeout=0.000000s&writeTimeout=0.000000s&interpolateParams=true&autocommit=true&charset=utf8mb4,utf8,latin1&tls=false")
+	test.S(t).ExpectEquals(uri, "gromit:penguin@tcp(myhost:3306)/test?timeout=0.000000s&readTimeout=0.000000s&writeTimeout=0.000000s&interpolateParams=true&autocommit=true&charset=utf8mb4,utf8,latin1&tls=false&time_zone=%27%2B00%3A00%27")
 }
 
 func TestGetDBUriWithTLSSetup(t *testing.T) {
@@ -80,5 +81,129 @@ func TestGetDBUriWithTLSSetup(t *testing.T) {
 	c.tlsConfig = &tls.Config{}
 
 	uri := c.GetDBUri("test")
-	test.S(t).ExpectEquals(uri, "gromit:penguin@tcp(myhost:3306)/test?timeout=0.000000s&readTimeout=0.000000s&writeTimeout=0.000000s&interpolateParams=true&autocommit=true&charset=utf8mb4,utf8,latin1&tls=ghost")
+	test.S(t).ExpectEquals(uri, "gromit:penguin@tcp(myhost:3306)/test?timeout=0.000000s&readTimeout=0.000000s&writeTimeout=0.000000s&interpolateParams=true&autocommit=true&charset=utf8mb4,utf8,latin1&tls=ghost&time_zone=%27%2B00%3A00%27")
+}
+
+func TestGetDBUriShowingGeneratedInvisiblePrimaryKeys(t *testing.T) {
+	c := NewConnectionConfig()
+	c.Key = InstanceKey{Hostname: "myhost", Port: 3306}
+	c.User = "gromit"
+	c.Password = "penguin"
+	c.ShowGeneratedInvisiblePrimaryKeys = true
+
+	uri := c.GetDBUri("test")
+	test.S(t).ExpectEquals(uri, "gromit:penguin@tcp(myhost:3306)/test?timeout=0.000000s&readTimeout=0.000000s&writeTimeout=0.000000s&interpolateParams=true&autocommit=true&charset=utf8mb4,utf8,latin1&tls=false&time_zone=%27%2B00%3A00%27&show_gipk_in_create_table_and_information_schema=ON")
+	// Other hosts need not support the variable
+	dup := c.DuplicateCredentials(InstanceKey{Hostname: "otherhost", Port: 3310})
+	test.S(t).ExpectFalse(dup.ShowGeneratedInvisiblePrimaryKeys)
+}
+
+func TestGetDBUriWithSQLMode(t *testing.T) {
+	c := NewConnectionConfig()
+	c.Key = InstanceKey{Hostname: "myhost", Port: 3306}
+	c.User = "gromit"
+	c.Password = "penguin"
+	c.SQLMode = "CONCAT('NO_ENGINE_SUBSTITUTION', ',NO_AUTO_VALUE_ON_ZERO')"
+
+	uri := c.GetDBUri("test")
+	test.S(t).ExpectEquals(uri, "gromit:penguin@tcp(myhost:3306)/test?timeout=0.000000s&readTimeout=0.000000s&writeTimeout=0.000000s&interpolateParams=true&autocommit=true&charset=utf8mb4,utf8,latin1&tls=false&time_zone=%27%2B00%3A00%27&sql_mode=CONCAT%28%27NO_ENGINE_SUBSTITUTION%27%2C+%27%2CNO_AUTO_VALUE_ON_ZERO%27%29")
+	dup := c.DuplicateCredentials(InstanceKey{Hostname: "otherhost", Port: 3310})
+	test.S(t).ExpectEquals(dup.SQLMode, "")
+}
+
+func TestGetDBUriWithTimeZone(t *testing.T) {
+	c := NewConnectionConfig()
+	c.Key = InstanceKey{Hostname: "myhost", Port: 3306}
+	c.User = "gromit"
+	c.Password = "penguin"
+	c.TimeZone = "-05:00"
+
+	uri := c.GetDBUri("test")
+	test.S(t).ExpectEquals(uri, "gromit:penguin@tcp(myhost:3306)/test?timeout=0.000000s&readTimeout=0.000000s&writeTimeout=0.000000s&interpolateParams=true&autocommit=true&charset=utf8mb4,utf8,latin1&tls=false&time_zone=%27-05%3A00%27")
+	dup := c.DuplicateCredentials(InstanceKey{Hostname: "otherhost", Port: 3310})
+	test.S(t).ExpectEquals(dup.TimeZone, "-05:00")
+}
+
+func TestParseTimeZone(t *testing.T) {
+	{
+		location, err := ParseTimeZone("+00:00")
+		test.S(t).ExpectNil(err)
+		_, offset := time.Date(2023, 1, 1, 0, 0, 0, 0, location).Zone()
+		test.S(t).ExpectEquals(offset, 0)
+	}
+	{
+		location, err := ParseTimeZone("-05:30")
+		test.S(t).ExpectNil(err)
+		_, offset := time.Date(2023, 1, 1, 0, 0, 0, 0, location).Zone()
+		test.S(t).ExpectEquals(offset, -(5*3600 + 30*60))
+	}
+	{
+		location, err := ParseTimeZone("UTC")
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(location.String(), "UTC")
+	}
+	{
+		location, err := ParseTimeZone("Asia/Kolkata")
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(location.String(), "Asia/Kolkata")
+	}
+	{
+		_, err := ParseTimeZone("SYSTEM")
+		test.S(t).ExpectNotNil(err)
+		_, err = ParseTimeZone("+15:00")
+		test.S(t).ExpectNotNil(err)
+		_, err = ParseTimeZone("Europe/Berlin")
+		test.S(t).ExpectNotNil(err)
+		_, err = ParseTimeZone("America/New_York")
+		test.S(t).ExpectNotNil(err)
+		_, err = ParseTimeZone("Mars/Olympus_Mons")
+		test.S(t).ExpectNotNil(err)
+		_, err = ParseTimeZone("UTC' or '1")
+		test.S(t).ExpectNotNil(err)
+		_, err = ParseTimeZone("")
+		test.S(t).ExpectNotNil(err)
+	}
+	test.S(t).ExpectTrue(IsTimeZoneOffset("+05:30"))
+	test.S(t).ExpectFalse(IsTimeZoneOffset("Europe/Berlin"))
+}
+
+func TestParseSessionVariable(t *testing.T) {
+	{
+		variable, err := ParseSessionVariable("innodb_lock_wait_timeout=10")
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(variable.Name, "innodb_lock_wait_timeout")
+		test.S(t).ExpectEquals(variable.Value, "10")
+	}
+	{
+		variable, err := ParseSessionVariable(" Optimizer_Switch = 'index_merge=off'")
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(variable.Name, "optimizer_switch")
+		test.S(t).ExpectEquals(variable.Value, "'index_merge=off'")
+	}
+	{
+		_, err := ParseSessionVariable("lock_wait_timeout")
+		test.S(t).ExpectNotNil(err)
+		_, err = ParseSessionVariable("lock_wait_timeout=")
+		test.S(t).ExpectNotNil(err)
+		_, err = ParseSessionVariable("lock-wait-timeout=3")
+		test.S(t).ExpectNotNil(err)
+		_, err = ParseSessionVariable("time_zone='SYSTEM'")
+		test.S(t).ExpectNotNil(err)
+		_, err = ParseSessionVariable("sql_mode=''")
+		test.S(t).ExpectNotNil(err)
+	}
+}
+
+func TestGetDBUriWithSessionVariables(t *testing.T) {
+	c := NewConnectionConfig()
+	c.Key = InstanceKey{Hostname: "myhost", Port: 3306}
+	c.User = "gromit"
+	c.Password = "penguin"
+	c.SessionVariables = []SessionVariable{
+		{Name: "innodb_lock_wait_timeout", Value: "10"},
+		{Name: "optimizer_switch", Value: "'index_merge=off'"},
+	}
+
+	uri := c.GetDBUri("test")
+	test.S(t).ExpectEquals(uri, "gromit:penguin@tcp(myhost:3306)/test?timeout=0.000000s&readTimeout=0.000000s&writeTimeout=0.000000s&interpolateParams=true&autocommit=true&charset=utf8mb4,utf8,latin1&tls=false&time_zone=%27%2B00%3A00%27&innodb_lock_wait_timeout=10&optimizer_switch=%27index_merge%3Doff%27")
 }
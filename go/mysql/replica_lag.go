@@ -0,0 +1,320 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package mysql
+
+import (
+	gosql "database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/github/gh-ost/go/sql"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/outbrain/golib/sqlutils"
+)
+
+const errParseError = 1064
+
+// ReplicaLagSourceType is how the lag of a control replica is measured
+type ReplicaLagSourceType string
+
+const (
+	// HeartbeatReplicaLagSource reads gh-ost's own heartbeat off the replicated changelog table
+	HeartbeatReplicaLagSource ReplicaLagSourceType = "heartbeat"
+	// ReplicaStatusReplicaLagSource reads Seconds_Behind_Source off SHOW REPLICA STATUS
+	ReplicaStatusReplicaLagSource ReplicaLagSourceType = "replica_status"
+	// PerformanceSchemaReplicaLagSource reads the age of the transactions being applied off
+	// performance_schema.replication_applier_status_by_worker (MySQL 8.0)
+	PerformanceSchemaReplicaLagSource ReplicaLagSourceType = "performance_schema"
+	// PtHeartbeatReplicaLagSource reads the heartbeat pt-heartbeat writes, in UTC, onto a given table
+	PtHeartbeatReplicaLagSource ReplicaLagSourceType = "pt-heartbeat"
+)
+
+// ReplicaLagSource is how the lag of a control replica is measured, per --replica-lag-source
+type ReplicaLagSource struct {
+	Type ReplicaLagSourceType
+	// Table is the pt-heartbeat table, as schema.table
+	Table string
+}
+
+// ParseReplicaLagSource parses a lag source: heartbeat, replica_status, performance_schema or pt-heartbeat:schema.table
+func ParseReplicaLagSource(source string) (*ReplicaLagSource, error) {
+	source = strings.TrimSpace(source)
+	switch ReplicaLagSourceType(source) {
+	case HeartbeatReplicaLagSource, ReplicaStatusReplicaLagSource, PerformanceSchemaReplicaLagSource:
+		return &ReplicaLagSource{Type: ReplicaLagSourceType(source)}, nil
+	}
+	if strings.HasPrefix(source, string(PtHeartbeatReplicaLagSource)+":") {
+		table := strings.TrimPrefix(source, string(PtHeartbeatReplicaLagSource)+":")
+		if tokens := strings.Split(table, "."); len(tokens) != 2 || tokens[0] == "" || tokens[1] == "" {
+			return nil, fmt.Errorf("Cannot parse replica lag source: %s. Expected pt-heartbeat:schema.table", source)
+		}
+		return &ReplicaLagSource{Type: PtHeartbeatReplicaLagSource, Table: table}, nil
+	}
+	return nil, fmt.Errorf("Unknown replica lag source: %s. Expected heartbeat, replica_status, performance_schema or pt-heartbeat:schema.table", source)
+}
+
+func (this *ReplicaLagSource) String() string {
+	if this.Type == PtHeartbeatReplicaLagSource {
+		return fmt.Sprintf("%s:%s", this.Type, this.Table)
+	}
+	return string(this.Type)
+}
+
+// GetReplicationLagFromReplicaStatus returns the replication lag of a replica via SHOW REPLICA STATUS, falling back
+// to SHOW SLAVE STATUS on servers which do not support the former. Lag has a precision of seconds. A replica with
+// broken replication, or one which isn't a replica, returns an error.
+func GetReplicationLagFromReplicaStatus(db *gosql.DB) (replicationLag time.Duration, err error) {
+	found := false
+	readStatus := func(m sqlutils.RowMap) error {
+		found = true
+		secondsBehindSource := m.GetNullInt64("Seconds_Behind_Source")
+		if _, ok := m["Seconds_Behind_Master"]; ok {
+			secondsBehindSource = m.GetNullInt64("Seconds_Behind_Master")
+		}
+		if !secondsBehindSource.Valid {
+			return fmt.Errorf("replication not running; Replica_IO_Running=%+v, Replica_SQL_Running=%+v",
+				m.GetStringD("Replica_IO_Running", m.GetString("Slave_IO_Running")),
+				m.GetStringD("Replica_SQL_Running", m.GetString("Slave_SQL_Running")),
+			)
+		}
+		if lag := time.Duration(secondsBehindSource.Int64) * time.Second; lag > replicationLag {
+			replicationLag = lag
+		}
+		return nil
+	}
+	err = sqlutils.QueryRowsMap(db, `show /* gh-ost */ replica status`, readStatus)
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == errParseError {
+		err = sqlutils.QueryRowsMap(db, `show /* gh-ost */ slave status`, readStatus)
+	}
+	if err == nil && !found {
+		err = fmt.Errorf("no replication status found; not a replica")
+	}
+	return replicationLag, err
+}
+
+// ReplicaState is the replication state of a replica, as read off SHOW REPLICA STATUS. On a multi-source replica,
+// it combines all channels: the longest delay, any stopped thread, and the first error.
+type ReplicaState struct {
+	IORunning  bool
+	SQLRunning bool
+	// IOConnecting is an IO thread neither running nor stopped, e.g. Replica_IO_Running=Connecting while the
+	// source is unreachable, possibly ahead of any error
+	IOConnecting bool
+	SQLDelay     time.Duration
+	LastIOErrno  int
+	LastIOError  string
+	LastSQLErrno int
+	LastSQLError string
+}
+
+// read combines the replication state of a SHOW REPLICA STATUS row onto this state; SHOW SLAVE STATUS column
+// names are supported
+func (this *ReplicaState) read(m sqlutils.RowMap) {
+	column := func(name string, legacyName string) string {
+		return m.GetStringD(name, m.GetString(legacyName))
+	}
+	ioRunning := column("Replica_IO_Running", "Slave_IO_Running")
+	this.IORunning = this.IORunning && ioRunning == "Yes"
+	this.IOConnecting = this.IOConnecting || (ioRunning != "Yes" && ioRunning != "No")
+	this.SQLRunning = this.SQLRunning && column("Replica_SQL_Running", "Slave_SQL_Running") == "Yes"
+	if sqlDelay := time.Duration(m.GetInt64("SQL_Delay")) * time.Second; sqlDelay > this.SQLDelay {
+		this.SQLDelay = sqlDelay
+	}
+	if this.LastIOErrno == 0 {
+		this.LastIOErrno, this.LastIOError = m.GetInt("Last_IO_Errno"), m.GetString("Last_IO_Error")
+	}
+	if this.LastSQLErrno == 0 {
+		this.LastSQLErrno, this.LastSQLError = m.GetInt("Last_SQL_Errno"), m.GetString("Last_SQL_Error")
+	}
+}
+
+// IsBroken tells whether replication failed, on either the IO or the SQL thread
+func (this *ReplicaState) IsBroken() bool {
+	return this.LastIOErrno != 0 || this.LastSQLErrno != 0
+}
+
+// BrokenError describes why replication failed
+func (this *ReplicaState) BrokenError() error {
+	if this.LastSQLErrno != 0 {
+		return fmt.Errorf("replication broken: Last_SQL_Errno=%d, %s", this.LastSQLErrno, this.LastSQLError)
+	}
+	return fmt.Errorf("replication broken: Last_IO_Errno=%d, %s", this.LastIOErrno, this.LastIOError)
+}
+
+// IntentionalLagReason tells why the replica lags intentionally: it is configured with a replication delay
+// (SOURCE_DELAY, formerly MASTER_DELAY), or its replication is stopped without error. Empty when it isn't.
+// A broken replica does not lag intentionally, nor does one whose IO thread is connecting: replication was
+// not stopped, and the replica may have lost its source.
+func (this *ReplicaState) IntentionalLagReason() string {
+	if this.IsBroken() || this.IOConnecting {
+		return ""
+	}
+	if !this.IORunning || !this.SQLRunning {
+		running := map[bool]string{true: "Yes", false: "No"}
+		return fmt.Sprintf("replication stopped; Replica_IO_Running=%s, Replica_SQL_Running=%s", running[this.IORunning], running[this.SQLRunning])
+	}
+	if this.SQLDelay > 0 {
+		return fmt.Sprintf("delayed replica; SQL_Delay=%+v", this.SQLDelay)
+	}
+	return ""
+}
+
+// GetReplicaState returns the replication state of a replica via SHOW REPLICA STATUS, falling back to SHOW SLAVE
+// STATUS on servers which do not support the former. A server which isn't a replica returns an error.
+func GetReplicaState(db *gosql.DB) (replicaState *ReplicaState, err error) {
+	replicaState = &ReplicaState{IORunning: true, SQLRunning: true}
+	found := false
+	readState := func(m sqlutils.RowMap) error {
+		found = true
+		replicaState.read(m)
+		return nil
+	}
+	err = sqlutils.QueryRowsMap(db, `show /* gh-ost */ replica status`, readState)
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == errParseError {
+		err = sqlutils.QueryRowsMap(db, `show /* gh-ost */ slave status`, readState)
+	}
+	if err == nil && !found {
+		err = fmt.Errorf("no replication status found; not a replica")
+	}
+	return replicaState, err
+}
+
+// GetReplicationLagFromPerformanceSchema returns the replication lag of a MySQL 8.0 replica as the age of the oldest
+// transaction its workers are applying, with a precision of microseconds. Idle workers are caught up. A replica with
+// a stopped receiver or applier, or one which isn't a replica, returns an error.
+func GetReplicationLagFromPerformanceSchema(db *gosql.DB) (replicationLag time.Duration, err error) {
+	query := `
+		SELECT
+			(SELECT COUNT(*) FROM performance_schema.replication_applier_status) AS num_channels,
+			(SELECT COUNT(*) FROM performance_schema.replication_applier_status WHERE SERVICE_STATE != 'ON')
+				+ (SELECT COUNT(*) FROM performance_schema.replication_connection_status WHERE SERVICE_STATE != 'ON') AS num_stopped,
+			(SELECT MAX(IF(APPLYING_TRANSACTION = '', 0, TIMESTAMPDIFF(MICROSECOND, APPLYING_TRANSACTION_ORIGINAL_COMMIT_TIMESTAMP, NOW(6))))
+				FROM performance_schema.replication_applier_status_by_worker) AS lag_microseconds
+	`
+	var numChannels, numStopped int64
+	var lagMicroseconds gosql.NullInt64
+	if err := db.QueryRow(query).Scan(&numChannels, &numStopped, &lagMicroseconds); err != nil {
+		return replicationLag, err
+	}
+	if numChannels == 0 {
+		return replicationLag, fmt.Errorf("no replication channels found; not a replica")
+	}
+	if numStopped > 0 {
+		return replicationLag, fmt.Errorf("replication not running; %d receiver or applier threads are stopped", numStopped)
+	}
+	if !lagMicroseconds.Valid {
+		return replicationLag, fmt.Errorf("no replication applier workers found")
+	}
+	if lagMicroseconds.Int64 > 0 {
+		replicationLag = time.Duration(lagMicroseconds.Int64) * time.Microsecond
+	}
+	return replicationLag, nil
+}
+
+// GetReplicationLagFromPtHeartbeat returns the replication lag of a replica as the age of the latest heartbeat
+// pt-heartbeat wrote onto given schema.table, with a precision of microseconds. pt-heartbeat must run with --utc.
+func GetReplicationLagFromPtHeartbeat(db *gosql.DB, table string) (replicationLag time.Duration, err error) {
+	tokens := strings.SplitN(table, ".", 2)
+	if len(tokens) != 2 {
+		return replicationLag, fmt.Errorf("Invalid pt-heartbeat table: %s. Expected schema.table", table)
+	}
+	query := fmt.Sprintf(`select /* gh-ost */ timestampdiff(microsecond, max(ts), utc_timestamp(6)) from %s.%s`,
+		sql.EscapeName(tokens[0]),
+		sql.EscapeName(tokens[1]),
+	)
+	var lagMicroseconds gosql.NullInt64
+	if err := db.QueryRow(query).Scan(&lagMicroseconds); err != nil {
+		return replicationLag, err
+	}
+	if !lagMicroseconds.Valid {
+		return replicationLag, fmt.Errorf("no pt-heartbeat found on %s", table)
+	}
+	if lagMicroseconds.Int64 > 0 {
+		replicationLag = time.Duration(lagMicroseconds.Int64) * time.Microsecond
+	}
+	return replicationLag, nil
+}
+
+// ReplicaHost is a replica registered with its source, as listed by SHOW REPLICAS
+type ReplicaHost struct {
+	ServerId uint
+	Key      InstanceKey
+}
+
+// GetReplicaHosts lists the replicas registered with given server, one level deep, via SHOW REPLICAS, falling back
+// to SHOW SLAVE HOSTS on servers which do not support the former. Replicas list with an empty hostname unless they
+// set report_host.
+func GetReplicaHosts(db *gosql.DB) (replicaHosts []ReplicaHost, err error) {
+	readHost := func(m sqlutils.RowMap) error {
+		replicaHosts = append(replicaHosts, ReplicaHost{
+			ServerId: uint(m.GetInt64("Server_id")),
+			Key:      InstanceKey{Hostname: m.GetString("Host"), Port: m.GetInt("Port")},
+		})
+		return nil
+	}
+	err = sqlutils.QueryRowsMap(db, `show /* gh-ost */ replicas`, readHost)
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == errParseError {
+		replicaHosts = nil
+		err = sqlutils.QueryRowsMap(db, `show /* gh-ost */ slave hosts`, readHost)
+	}
+	return replicaHosts, err
+}
+
+// AuroraReplicaStatusStaleAfter is the age beyond which a reader's row on the Aurora replica status table is
+// considered a leftover of a reader since gone
+const AuroraReplicaStatusStaleAfter = time.Minute
+
+// AuroraReplicaLag is the lag of an Amazon Aurora reader, as reported by the writer
+type AuroraReplicaLag struct {
+	ServerId string
+	Lag      time.Duration
+}
+
+// GetAuroraReplicasLag returns the lag of the readers of an Amazon Aurora cluster, read on the writer off
+// information_schema.replica_host_status, falling back to mysql.ro_replica_status. Readers whose status was
+// not updated within AuroraReplicaStatusStaleAfter are gone, and not returned.
+func GetAuroraReplicasLag(db *gosql.DB) (replicasLag []AuroraReplicaLag, err error) {
+	staleAfterSeconds := int64(AuroraReplicaStatusStaleAfter.Seconds())
+	queries := []string{
+		fmt.Sprintf(`
+			SELECT SERVER_ID AS server_id, REPLICA_LAG_IN_MILLISECONDS AS lag_milliseconds
+			FROM information_schema.replica_host_status
+			WHERE SESSION_ID != 'MASTER_SESSION_ID'
+				AND LAST_UPDATE_TIMESTAMP >= UTC_TIMESTAMP() - INTERVAL %d SECOND
+		`, staleAfterSeconds),
+		fmt.Sprintf(`
+			SELECT Server_id AS server_id, Replica_lag_in_msec AS lag_milliseconds
+			FROM mysql.ro_replica_status
+			WHERE Session_id != 'MASTER_SESSION_ID'
+				AND Last_update_timestamp >= UTC_TIMESTAMP() - INTERVAL %d SECOND
+		`, staleAfterSeconds),
+	}
+	for _, query := range queries {
+		replicasLag = nil
+		err = sqlutils.QueryRowsMap(db, query, func(m sqlutils.RowMap) error {
+			lagMilliseconds, err := strconv.ParseFloat(m.GetString("lag_milliseconds"), 64)
+			if err != nil {
+				return fmt.Errorf("Cannot read lag of Aurora reader %s: %+v", m.GetString("server_id"), err)
+			}
+			replicasLag = append(replicasLag, AuroraReplicaLag{
+				ServerId: m.GetString("server_id"),
+				Lag:      time.Duration(lagMilliseconds * float64(time.Millisecond)),
+			})
+			return nil
+		})
+		if err == nil {
+			return replicasLag, nil
+		}
+	}
+	return nil, err
+}
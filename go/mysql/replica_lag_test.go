@@ -0,0 +1,103 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package mysql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/outbrain/golib/sqlutils"
+	test "github.com/outbrain/golib/tests"
+)
+
+func TestParseReplicaLagSource(t *testing.T) {
+	{
+		source, err := ParseReplicaLagSource("replica_status")
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(source.Type, ReplicaStatusReplicaLagSource)
+		test.S(t).ExpectEquals(source.String(), "replica_status")
+	}
+	{
+		source, err := ParseReplicaLagSource(" performance_schema ")
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(source.Type, PerformanceSchemaReplicaLagSource)
+	}
+	{
+		source, err := ParseReplicaLagSource("pt-heartbeat:percona.heartbeat")
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(source.Type, PtHeartbeatReplicaLagSource)
+		test.S(t).ExpectEquals(source.Table, "percona.heartbeat")
+		test.S(t).ExpectEquals(source.String(), "pt-heartbeat:percona.heartbeat")
+	}
+	{
+		_, err := ParseReplicaLagSource("pt-heartbeat:heartbeat")
+		test.S(t).ExpectNotNil(err)
+	}
+	{
+		_, err := ParseReplicaLagSource("seconds_behind_master")
+		test.S(t).ExpectNotNil(err)
+	}
+}
+
+func TestReplicaState(t *testing.T) {
+	row := func(columns map[string]string) sqlutils.RowMap {
+		m := sqlutils.RowMap{}
+		for column, value := range columns {
+			m[column] = sqlutils.CellData{String: value, Valid: true}
+		}
+		return m
+	}
+	{
+		replicaState := &ReplicaState{IORunning: true, SQLRunning: true}
+		replicaState.read(row(map[string]string{"Replica_IO_Running": "Yes", "Replica_SQL_Running": "Yes", "SQL_Delay": "0", "Last_IO_Errno": "0", "Last_SQL_Errno": "0"}))
+		test.S(t).ExpectFalse(replicaState.IsBroken())
+		test.S(t).ExpectEquals(replicaState.IntentionalLagReason(), "")
+	}
+	{
+		replicaState := &ReplicaState{IORunning: true, SQLRunning: true}
+		replicaState.read(row(map[string]string{"Slave_IO_Running": "Yes", "Slave_SQL_Running": "Yes", "SQL_Delay": "3600", "Last_IO_Errno": "0", "Last_SQL_Errno": "0"}))
+		test.S(t).ExpectEquals(replicaState.SQLDelay, time.Hour)
+		test.S(t).ExpectEquals(replicaState.IntentionalLagReason(), "delayed replica; SQL_Delay=1h0m0s")
+	}
+	{
+		replicaState := &ReplicaState{IORunning: true, SQLRunning: true}
+		replicaState.read(row(map[string]string{"Replica_IO_Running": "Yes", "Replica_SQL_Running": "No", "SQL_Delay": "0", "Last_IO_Errno": "0", "Last_SQL_Errno": "0"}))
+		test.S(t).ExpectFalse(replicaState.IsBroken())
+		test.S(t).ExpectEquals(replicaState.IntentionalLagReason(), "replication stopped; Replica_IO_Running=Yes, Replica_SQL_Running=No")
+	}
+	{
+		// A broken replica does not lag intentionally, even if delayed
+		replicaState := &ReplicaState{IORunning: true, SQLRunning: true}
+		replicaState.read(row(map[string]string{"Replica_IO_Running": "Yes", "Replica_SQL_Running": "No", "SQL_Delay": "3600", "Last_IO_Errno": "0", "Last_SQL_Errno": "1062", "Last_SQL_Error": "Duplicate entry"}))
+		test.S(t).ExpectTrue(replicaState.IsBroken())
+		test.S(t).ExpectEquals(replicaState.IntentionalLagReason(), "")
+		test.S(t).ExpectEquals(replicaState.BrokenError().Error(), "replication broken: Last_SQL_Errno=1062, Duplicate entry")
+	}
+	{
+		// A stopped IO thread
+		replicaState := &ReplicaState{IORunning: true, SQLRunning: true}
+		replicaState.read(row(map[string]string{"Replica_IO_Running": "No", "Replica_SQL_Running": "Yes", "SQL_Delay": "0", "Last_IO_Errno": "0", "Last_SQL_Errno": "0"}))
+		test.S(t).ExpectFalse(replicaState.IOConnecting)
+		test.S(t).ExpectEquals(replicaState.IntentionalLagReason(), "replication stopped; Replica_IO_Running=No, Replica_SQL_Running=Yes")
+	}
+	{
+		// A replica which lost its source, ahead of any error, does not lag intentionally
+		replicaState := &ReplicaState{IORunning: true, SQLRunning: true}
+		replicaState.read(row(map[string]string{"Replica_IO_Running": "Connecting", "Replica_SQL_Running": "Yes", "SQL_Delay": "3600", "Last_IO_Errno": "0", "Last_SQL_Errno": "0"}))
+		test.S(t).ExpectFalse(replicaState.IsBroken())
+		test.S(t).ExpectTrue(replicaState.IOConnecting)
+		test.S(t).ExpectEquals(replicaState.IntentionalLagReason(), "")
+	}
+	{
+		// Multiple channels combine
+		replicaState := &ReplicaState{IORunning: true, SQLRunning: true}
+		replicaState.read(row(map[string]string{"Replica_IO_Running": "Yes", "Replica_SQL_Running": "Yes", "SQL_Delay": "60", "Last_IO_Errno": "0", "Last_SQL_Errno": "0"}))
+		replicaState.read(row(map[string]string{"Replica_IO_Running": "Connecting", "Replica_SQL_Running": "Yes", "SQL_Delay": "0", "Last_IO_Errno": "2003", "Last_IO_Error": "Can't connect", "Last_SQL_Errno": "0"}))
+		test.S(t).ExpectEquals(replicaState.SQLDelay, time.Minute)
+		test.S(t).ExpectFalse(replicaState.IORunning)
+		test.S(t).ExpectEquals(replicaState.BrokenError().Error(), "replication broken: Last_IO_Errno=2003, Can't connect")
+	}
+}
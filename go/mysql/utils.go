@@ -7,13 +7,17 @@ package mysql
 
 import (
 	gosql "database/sql"
+	"errors"
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/github/gh-ost/go/sql"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/outbrain/golib/log"
 	"github.com/outbrain/golib/sqlutils"
 )
@@ -22,12 +26,24 @@ const (
 	MaxTableNameLength           = 64
 	MaxReplicationPasswordLength = 32
 	MaxDBPoolConnections         = 3
+
+	// GeneratedInvisiblePrimaryKeyColumnName is the column of a generated invisible primary key
+	GeneratedInvisiblePrimaryKeyColumnName = "my_row_id"
+
+	ErrLockWaitTimeout = 1205
+	ErrLockDeadlock    = 1213
+	ErrDupEntry        = 1062
+	ErrPacketTooLarge  = 1153
+	ErrBadField        = 1054
+	ErrUnknownTable    = 1109
 )
 
 type ReplicationLagResult struct {
 	Key InstanceKey
 	Lag time.Duration
 	Err error
+	// SkipReason tells why the replica's lag is not checked, e.g. a delayed replica, per --skip-lag-check-if-delayed
+	SkipReason string
 }
 
 func NewNoReplicationLagResult() *ReplicationLagResult {
@@ -38,6 +54,76 @@ func (this *ReplicationLagResult) HasLag() bool {
 	return this.Lag > 0
 }
 
+// IsLockContentionError returns true when given error, possibly wrapped, is a lock wait timeout
+// or a deadlock. Either rolls back the statement or the transaction, which may then be retried.
+func IsLockContentionError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return false
+	}
+	return mysqlErr.Number == ErrLockWaitTimeout || mysqlErr.Number == ErrLockDeadlock
+}
+
+// IsLockWaitTimeoutError returns true when given error, possibly wrapped, is a lock wait timeout
+func IsLockWaitTimeoutError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return false
+	}
+	return mysqlErr.Number == ErrLockWaitTimeout
+}
+
+// IsPacketTooLargeError returns true when given error, possibly wrapped, tells a statement exceeded
+// max_allowed_packet, whether rejected by the server or by the driver before being sent
+func IsPacketTooLargeError(err error) bool {
+	if errors.Is(err, mysql.ErrPktTooLarge) {
+		return true
+	}
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return false
+	}
+	return mysqlErr.Number == ErrPacketTooLarge
+}
+
+// IsDuplicateKeyError returns true when given error, possibly wrapped, is a duplicate key error
+func IsDuplicateKeyError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return false
+	}
+	return mysqlErr.Number == ErrDupEntry
+}
+
+// IsUnknownTableOrColumnError returns true when given error, possibly wrapped, tells a queried table or column
+// does not exist, as is the case with INFORMATION_SCHEMA tables or columns introduced by later server versions
+func IsUnknownTableOrColumnError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return false
+	}
+	return mysqlErr.Number == ErrUnknownTable || mysqlErr.Number == ErrBadField
+}
+
+// SetDBPoolLimits overrides the connection limits and lifetime of given pool, where given non-zero values
+func SetDBPoolLimits(db *gosql.DB, maxOpenConns int, maxIdleConns int, connMaxLifetime time.Duration) {
+	if maxOpenConns > 0 {
+		db.SetMaxOpenConns(maxOpenConns)
+	}
+	if maxIdleConns > 0 {
+		db.SetMaxIdleConns(maxIdleConns)
+	}
+	if connMaxLifetime > 0 {
+		db.SetConnMaxLifetime(connMaxLifetime)
+	}
+}
+
+// DBPoolStats returns a short description of given pool's usage
+func DBPoolStats(db *gosql.DB) string {
+	stats := db.Stats()
+	return fmt.Sprintf("%d/%d in use", stats.InUse, stats.MaxOpenConnections)
+}
+
 // knownDBs is a DB cache by uri
 var knownDBs map[string]*gosql.DB = make(map[string]*gosql.DB)
 var knownDBsMutex = &sync.Mutex{}
@@ -175,6 +261,42 @@ func GetInstanceKey(db *gosql.DB) (instanceKey *InstanceKey, err error) {
 	return instanceKey, err
 }
 
+// HidesGeneratedInvisiblePrimaryKeys returns true when the server hides generated invisible primary
+// keys (MySQL 8.0.30+, sql_generate_invisible_primary_key) from SHOW COLUMNS and information_schema
+// on given connection. Such a key's column is nonetheless present in binlog row images.
+func HidesGeneratedInvisiblePrimaryKeys(db *gosql.DB) bool {
+	var showGIPK int64
+	if err := db.QueryRow(`select @@session.show_gipk_in_create_table_and_information_schema`).Scan(&showGIPK); err != nil {
+		// Unknown variable: the server does not generate invisible primary keys
+		return false
+	}
+	return showGIPK == 0
+}
+
+// ValidateSessionVariables validates given variables may be set per session, on a connection not setting them.
+// Global only variables, as well as unknown ones, are rejected.
+func ValidateSessionVariables(db *gosql.DB, variables []SessionVariable) error {
+	for _, variable := range variables {
+		if _, err := ReadSessionVariables(db, []SessionVariable{variable}); err != nil {
+			return fmt.Errorf("Session variable %s cannot be set per session: %+v", variable.Name, err)
+		}
+	}
+	return nil
+}
+
+// ReadSessionVariables reads the effective values of given variables on a connection
+func ReadSessionVariables(db *gosql.DB, variables []SessionVariable) (effective []SessionVariable, err error) {
+	for _, variable := range variables {
+		var value gosql.NullString
+		query := fmt.Sprintf(`select @@session.%s`, variable.Name)
+		if err := db.QueryRow(query).Scan(&value); err != nil {
+			return effective, err
+		}
+		effective = append(effective, SessionVariable{Name: variable.Name, Value: value.String})
+	}
+	return effective, nil
+}
+
 // GetTableColumns reads column list from given table
 func GetTableColumns(db *gosql.DB, databaseName, tableName string) (*sql.ColumnList, *sql.ColumnList, error) {
 	query := fmt.Sprintf(`
@@ -205,3 +327,89 @@ func GetTableColumns(db *gosql.DB, databaseName, tableName string) (*sql.ColumnL
 	}
 	return sql.NewColumnList(columnNames), sql.NewColumnList(virtualColumnNames), nil
 }
+
+// GetStatusVariable returns the value of a numeric global status variable, e.g. Threads_running
+func GetStatusVariable(db *gosql.DB, variableName string) (value int64, err error) {
+	query := fmt.Sprintf(`show global status like '%s'`, variableName)
+	if err := db.QueryRow(query).Scan(&variableName, &value); err != nil {
+		return 0, err
+	}
+	return value, nil
+}
+
+// GetFreeDiskSpace runs given disk space query, which returns the free bytes of the data directory's disk, and
+// optionally, as a second column, the disk's total bytes. Total bytes are 0 when not returned.
+func GetFreeDiskSpace(db *gosql.DB, query string) (freeBytes int64, totalBytes int64, err error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(columns) < 1 || len(columns) > 2 {
+		return 0, 0, fmt.Errorf("disk space query returned %d columns; expected free bytes, and optionally total bytes", len(columns))
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return 0, 0, err
+		}
+		return 0, 0, fmt.Errorf("disk space query returned no rows")
+	}
+	values := make([]gosql.NullString, len(columns))
+	scanArgs := make([]interface{}, len(columns))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+	if err := rows.Scan(scanArgs...); err != nil {
+		return 0, 0, err
+	}
+	bytes := make([]int64, len(values))
+	for i, value := range values {
+		if !value.Valid {
+			return 0, 0, fmt.Errorf("disk space query returned NULL %s", columns[i])
+		}
+		parsed, err := strconv.ParseFloat(value.String, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("disk space query returned non-numeric %s: %s", columns[i], value.String)
+		}
+		bytes[i] = int64(parsed)
+	}
+	freeBytes = bytes[0]
+	if len(bytes) > 1 {
+		totalBytes = bytes[1]
+	}
+	return freeBytes, totalBytes, nil
+}
+
+var historyListLengthRegexp = regexp.MustCompile(`History list length ([0-9]+)`)
+
+// GetHistoryListLength returns the InnoDB history list length, i.e. the number of undo log records not yet purged,
+// via information_schema.innodb_metrics; or, with the trx_rseg_history_len metric disabled, via SHOW ENGINE INNODB STATUS
+func GetHistoryListLength(db *gosql.DB) (historyListLength int64, err error) {
+	query := `select /* gh-ost */ status, count from information_schema.innodb_metrics where name = 'trx_rseg_history_len'`
+	var status string
+	err = db.QueryRow(query).Scan(&status, &historyListLength)
+	if err == nil && strings.EqualFold(status, "enabled") {
+		return historyListLength, nil
+	}
+	if err != nil && err != gosql.ErrNoRows {
+		return 0, err
+	}
+	var engineType, engineName, engineStatus string
+	if err := db.QueryRow(`show /* gh-ost */ engine innodb status`).Scan(&engineType, &engineName, &engineStatus); err != nil {
+		return 0, err
+	}
+	return ParseHistoryListLength(engineStatus)
+}
+
+// ParseHistoryListLength reads the history list length off SHOW ENGINE INNODB STATUS output
+func ParseHistoryListLength(engineStatus string) (int64, error) {
+	submatch := historyListLengthRegexp.FindStringSubmatch(engineStatus)
+	if len(submatch) == 0 {
+		return 0, fmt.Errorf("History list length not found in SHOW ENGINE INNODB STATUS output")
+	}
+	return strconv.ParseInt(submatch[1], 10, 64)
+}
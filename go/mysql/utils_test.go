@@ -0,0 +1,104 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package mysql
+
+import (
+	gosql "database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	test "github.com/outbrain/golib/tests"
+)
+
+func TestIsLockContentionError(t *testing.T) {
+	deadlock := &mysql.MySQLError{Number: ErrLockDeadlock, Message: "Deadlock found when trying to get lock; try restarting transaction"}
+	lockWaitTimeout := &mysql.MySQLError{Number: ErrLockWaitTimeout, Message: "Lock wait timeout exceeded; try restarting transaction"}
+	duplicateKey := &mysql.MySQLError{Number: ErrDupEntry, Message: "Duplicate entry '1' for key 'PRIMARY'"}
+
+	test.S(t).ExpectTrue(IsLockContentionError(deadlock))
+	test.S(t).ExpectTrue(IsLockContentionError(lockWaitTimeout))
+	test.S(t).ExpectTrue(IsLockContentionError(fmt.Errorf("%w; query=delete from t where id = ?", deadlock)))
+	test.S(t).ExpectFalse(IsLockContentionError(duplicateKey))
+	test.S(t).ExpectFalse(IsLockContentionError(errors.New(deadlock.Error())))
+	test.S(t).ExpectFalse(IsLockContentionError(nil))
+}
+
+func TestIsLockWaitTimeoutError(t *testing.T) {
+	lockWaitTimeout := &mysql.MySQLError{Number: ErrLockWaitTimeout, Message: "Lock wait timeout exceeded; try restarting transaction"}
+	deadlock := &mysql.MySQLError{Number: ErrLockDeadlock, Message: "Deadlock found when trying to get lock; try restarting transaction"}
+
+	test.S(t).ExpectTrue(IsLockWaitTimeoutError(lockWaitTimeout))
+	test.S(t).ExpectTrue(IsLockWaitTimeoutError(fmt.Errorf("%w; query=rename table", lockWaitTimeout)))
+	test.S(t).ExpectFalse(IsLockWaitTimeoutError(deadlock))
+	test.S(t).ExpectFalse(IsLockWaitTimeoutError(nil))
+}
+
+func TestParseHistoryListLength(t *testing.T) {
+	engineStatus := `
+------------
+TRANSACTIONS
+------------
+Trx id counter 12345678
+Purge done for trx's n:o < 12345600 undo n:o < 0 state: running but idle
+History list length 12345678
+LIST OF TRANSACTIONS FOR EACH SESSION:
+`
+	historyListLength, err := ParseHistoryListLength(engineStatus)
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectEquals(historyListLength, int64(12345678))
+
+	_, err = ParseHistoryListLength("no transactions section")
+	test.S(t).ExpectNotNil(err)
+}
+
+func TestIsDuplicateKeyError(t *testing.T) {
+	duplicateKey := &mysql.MySQLError{Number: ErrDupEntry, Message: "Duplicate entry '1' for key 'PRIMARY'"}
+	deadlock := &mysql.MySQLError{Number: ErrLockDeadlock, Message: "Deadlock found when trying to get lock; try restarting transaction"}
+
+	test.S(t).ExpectTrue(IsDuplicateKeyError(duplicateKey))
+	test.S(t).ExpectTrue(IsDuplicateKeyError(fmt.Errorf("%w; query=insert into t values (?)", duplicateKey)))
+	test.S(t).ExpectFalse(IsDuplicateKeyError(deadlock))
+	test.S(t).ExpectFalse(IsDuplicateKeyError(nil))
+}
+
+func TestIsUnknownTableOrColumnError(t *testing.T) {
+	unknownTable := &mysql.MySQLError{Number: ErrUnknownTable, Message: "Unknown table 'CHECK_CONSTRAINTS' in information_schema"}
+	badField := &mysql.MySQLError{Number: ErrBadField, Message: "Unknown column 'ENFORCED' in 'field list'"}
+
+	test.S(t).ExpectTrue(IsUnknownTableOrColumnError(unknownTable))
+	test.S(t).ExpectTrue(IsUnknownTableOrColumnError(fmt.Errorf("%w; query=select 1", badField)))
+	test.S(t).ExpectFalse(IsUnknownTableOrColumnError(&mysql.MySQLError{Number: ErrDupEntry}))
+	test.S(t).ExpectFalse(IsUnknownTableOrColumnError(nil))
+}
+
+func TestIsPacketTooLargeError(t *testing.T) {
+	packetTooLarge := &mysql.MySQLError{Number: ErrPacketTooLarge, Message: "Got a packet bigger than 'max_allowed_packet' bytes"}
+	duplicateKey := &mysql.MySQLError{Number: ErrDupEntry, Message: "Duplicate entry '1' for key 'PRIMARY'"}
+
+	test.S(t).ExpectTrue(IsPacketTooLargeError(packetTooLarge))
+	test.S(t).ExpectTrue(IsPacketTooLargeError(mysql.ErrPktTooLarge))
+	test.S(t).ExpectTrue(IsPacketTooLargeError(fmt.Errorf("%w; query=insert into t values (?)", mysql.ErrPktTooLarge)))
+	test.S(t).ExpectFalse(IsPacketTooLargeError(duplicateKey))
+	test.S(t).ExpectFalse(IsPacketTooLargeError(nil))
+}
+
+func TestSetDBPoolLimits(t *testing.T) {
+	db, err := gosql.Open("mysql", "gh-ost:gh-ost@tcp(127.0.0.1:3306)/test")
+	test.S(t).ExpectNil(err)
+	defer db.Close()
+
+	db.SetMaxOpenConns(MaxDBPoolConnections)
+	SetDBPoolLimits(db, 0, 0, 0)
+	test.S(t).ExpectEquals(db.Stats().MaxOpenConnections, MaxDBPoolConnections)
+	test.S(t).ExpectEquals(DBPoolStats(db), "0/3 in use")
+
+	SetDBPoolLimits(db, 10, 5, time.Hour)
+	test.S(t).ExpectEquals(db.Stats().MaxOpenConnections, 10)
+	test.S(t).ExpectEquals(DBPoolStats(db), "0/10 in use")
+}
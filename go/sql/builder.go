@@ -20,6 +20,27 @@ const (
 	GreaterThanOrEqualsComparisonSign ValueComparisonSign = ">="
 	GreaterThanComparisonSign         ValueComparisonSign = ">"
 	NotEqualsComparisonSign           ValueComparisonSign = "!="
+	NullSafeEqualsComparisonSign      ValueComparisonSign = "<=>"
+)
+
+// CopyConflictStrategy is how row copy handles rows already found on the ghost table
+type CopyConflictStrategy string
+
+const (
+	IgnoreCopyConflictStrategy  CopyConflictStrategy = "ignore"
+	ReplaceCopyConflictStrategy CopyConflictStrategy = "replace"
+	FailCopyConflictStrategy    CopyConflictStrategy = "fail"
+	// SkipExistingCopyConflictStrategy only copies rows not found on the ghost table, matching rows by
+	// all their columns. It serves tables without a shared unique key, see --surrogate-key
+	SkipExistingCopyConflictStrategy CopyConflictStrategy = "skip-existing"
+)
+
+// CopyOrder is the order in which row copy iterates the unique key
+type CopyOrder string
+
+const (
+	AscendingCopyOrder  CopyOrder = "asc"
+	DescendingCopyOrder CopyOrder = "desc"
 )
 
 // EscapeName will escape a db/table/column/... name by wrapping with backticks.
@@ -37,9 +58,12 @@ func buildColumnsPreparedValues(columns *ColumnList) []string {
 	for i, column := range columns.Columns() {
 		var token string
 		if column.timezoneConversion != nil {
-			token = fmt.Sprintf("convert_tz(?, '%s', '%s')", column.timezoneConversion.ToTimezone, "+00:00")
-		} else if column.enumToTextConversion {
-			token = fmt.Sprintf("ELT(?, %s)", column.EnumValues)
+			token = fmt.Sprintf("convert_tz(?, '%s', '%s')", column.timezoneConversion.FromTimezone, column.timezoneConversion.ToTimezone)
+		} else if column.geometrySRIDConversion != nil {
+			// Binlog values are in MySQL's internal geometry format: a 4 byte SRID followed by WKB
+			token = fmt.Sprintf("st_srid(st_geomfromwkb(substring(?, 5)), %d)", column.geometrySRIDConversion.ToSRID)
+		} else if column.serverCharsetConversion != nil {
+			token = fmt.Sprintf("convert(? using %s)", column.serverCharsetConversion.FromCharset)
 		} else if column.Type == JSONColumnType {
 			token = "convert(? using utf8mb4)"
 		} else {
@@ -107,22 +131,92 @@ func BuildSetPreparedClause(columns *ColumnList) (result string, err error) {
 	}
 	setTokens := []string{}
 	for _, column := range columns.Columns() {
-		var setToken string
-		if column.timezoneConversion != nil {
-			setToken = fmt.Sprintf("%s=convert_tz(?, '%s', '%s')", EscapeName(column.Name), column.timezoneConversion.ToTimezone, "+00:00")
-		} else if column.enumToTextConversion {
-			setToken = fmt.Sprintf("%s=ELT(?, %s)", EscapeName(column.Name), column.EnumValues)
-		} else if column.Type == JSONColumnType {
-			setToken = fmt.Sprintf("%s=convert(? using utf8mb4)", EscapeName(column.Name))
-		} else {
-			setToken = fmt.Sprintf("%s=?", EscapeName(column.Name))
-		}
-		setTokens = append(setTokens, setToken)
+		setTokens = append(setTokens, buildSetPreparedToken(&column))
 	}
 	return strings.Join(setTokens, ", "), nil
 }
 
+// buildSetPreparedToken returns the assignment of a prepared value to given column
+func buildSetPreparedToken(column *Column) string {
+	if column.timezoneConversion != nil {
+		return fmt.Sprintf("%s=convert_tz(?, '%s', '%s')", EscapeName(column.Name), column.timezoneConversion.FromTimezone, column.timezoneConversion.ToTimezone)
+	} else if column.geometrySRIDConversion != nil {
+		return fmt.Sprintf("%s=st_srid(st_geomfromwkb(substring(?, 5)), %d)", EscapeName(column.Name), column.geometrySRIDConversion.ToSRID)
+	} else if column.serverCharsetConversion != nil {
+		return fmt.Sprintf("%s=convert(? using %s)", EscapeName(column.Name), column.serverCharsetConversion.FromCharset)
+	} else if column.Type == JSONColumnType {
+		return fmt.Sprintf("%s=convert(? using utf8mb4)", EscapeName(column.Name))
+	}
+	return fmt.Sprintf("%s=?", EscapeName(column.Name))
+}
+
+// buildSetPreparedClauseWithArgs is BuildSetPreparedClause for given args, where partial JSON values (see JSONDiffs)
+// apply onto the columns' current values. It returns the args of the clause.
+func buildSetPreparedClauseWithArgs(columns *ColumnList, args []interface{}) (result string, explodedArgs []interface{}, err error) {
+	if columns.Len() == 0 {
+		return "", explodedArgs, fmt.Errorf("Got 0 columns in buildSetPreparedClauseWithArgs")
+	}
+	setTokens := []string{}
+	for i, column := range columns.Columns() {
+		if diffs, ok := args[i].(JSONDiffs); ok {
+			expression, diffsArgs := diffs.Expression(EscapeName(column.Name))
+			setTokens = append(setTokens, fmt.Sprintf("%s=%s", EscapeName(column.Name), expression))
+			explodedArgs = append(explodedArgs, diffsArgs...)
+			continue
+		}
+		setTokens = append(setTokens, buildSetPreparedToken(&column))
+		explodedArgs = append(explodedArgs, args[i])
+	}
+	return strings.Join(setTokens, ", "), explodedArgs, nil
+}
+
 func BuildRangeComparison(columns []string, values []string, args []interface{}, comparisonSign ValueComparisonSign) (result string, explodedArgs []interface{}, err error) {
+	return buildRangeComparison(columns, nil, nil, values, args, comparisonSign)
+}
+
+// reverseComparisonSign returns the comparison sign of the reverse order, e.g. "<" for ">". Equality is kept.
+func reverseComparisonSign(comparisonSign ValueComparisonSign) ValueComparisonSign {
+	switch comparisonSign {
+	case GreaterThanComparisonSign:
+		return LessThanComparisonSign
+	case LessThanComparisonSign:
+		return GreaterThanComparisonSign
+	case GreaterThanOrEqualsComparisonSign:
+		return LessThanOrEqualsComparisonSign
+	case LessThanOrEqualsComparisonSign:
+		return GreaterThanOrEqualsComparisonSign
+	}
+	return comparisonSign
+}
+
+// buildNullSafeValueComparison compares a nullable column with a value in index order, where NULL sorts before
+// any value: NULL is greater than nothing, and less than any value. It returns the comparison's args, as the
+// value may be referenced twice.
+func buildNullSafeValueComparison(column string, value string, arg interface{}, comparisonSign ValueComparisonSign) (result string, explodedArgs []interface{}, err error) {
+	if comparisonSign == EqualsComparisonSign {
+		comparison, err := BuildValueComparison(column, value, NullSafeEqualsComparisonSign)
+		return comparison, []interface{}{arg}, err
+	}
+	comparison, err := BuildValueComparison(column, value, comparisonSign)
+	if err != nil {
+		return "", explodedArgs, err
+	}
+	switch comparisonSign {
+	case GreaterThanComparisonSign:
+		result = fmt.Sprintf("(%s or ((%s is not null) and (%s is null)))", comparison, EscapeName(column), value)
+	case LessThanComparisonSign:
+		result = fmt.Sprintf("(%s or ((%s is null) and (%s is not null)))", comparison, EscapeName(column), value)
+	default:
+		return "", explodedArgs, fmt.Errorf("Unsupported comparison sign %s in buildNullSafeValueComparison", comparisonSign)
+	}
+	return result, []interface{}{arg, arg}, nil
+}
+
+// buildRangeComparison expands a tuple comparison, e.g. (c1, c2) > (v1, v2), into per column comparisons.
+// Columns flagged in nullable (which may be nil) are compared NULL-safe, as per buildNullSafeValueComparison,
+// and columns flagged in descending (which may be nil) are compared in reverse, such that the comparison
+// agrees with the unique key's index order: with c2 descending, (c1 > v1) or ((c1 = v1) and (c2 < v2)).
+func buildRangeComparison(columns []string, nullable []bool, descending []bool, values []string, args []interface{}, comparisonSign ValueComparisonSign) (result string, explodedArgs []interface{}, err error) {
 	if len(columns) == 0 {
 		return "", explodedArgs, fmt.Errorf("Got 0 columns in GetRangeComparison")
 	}
@@ -141,36 +235,58 @@ func BuildRangeComparison(columns []string, values []string, args []interface{},
 		comparisonSign = GreaterThanComparisonSign
 		includeEquals = true
 	}
+	valueComparison := func(i int, comparisonSign ValueComparisonSign) (string, []interface{}, error) {
+		if i < len(descending) && descending[i] {
+			comparisonSign = reverseComparisonSign(comparisonSign)
+		}
+		if i < len(nullable) && nullable[i] {
+			return buildNullSafeValueComparison(columns[i], values[i], args[i], comparisonSign)
+		}
+		comparison, err := BuildValueComparison(columns[i], values[i], comparisonSign)
+		return comparison, []interface{}{args[i]}, err
+	}
+	equalsComparison := func(length int) (string, []interface{}, error) {
+		equalities := []string{}
+		equalitiesArgs := []interface{}{}
+		for i := 0; i < length; i++ {
+			equality, equalityArgs, err := valueComparison(i, EqualsComparisonSign)
+			if err != nil {
+				return "", nil, err
+			}
+			equalities = append(equalities, equality)
+			equalitiesArgs = append(equalitiesArgs, equalityArgs...)
+		}
+		return fmt.Sprintf("(%s)", strings.Join(equalities, " and ")), equalitiesArgs, nil
+	}
 	comparisons := []string{}
 
-	for i, column := range columns {
-		value := values[i]
-		rangeComparison, err := BuildValueComparison(column, value, comparisonSign)
+	for i := range columns {
+		rangeComparison, rangeArgs, err := valueComparison(i, comparisonSign)
 		if err != nil {
 			return "", explodedArgs, err
 		}
 		if i > 0 {
-			equalitiesComparison, err := BuildEqualsComparison(columns[0:i], values[0:i])
+			equalitiesComparison, equalitiesArgs, err := equalsComparison(i)
 			if err != nil {
 				return "", explodedArgs, err
 			}
 			comparison := fmt.Sprintf("(%s AND %s)", equalitiesComparison, rangeComparison)
 			comparisons = append(comparisons, comparison)
-			explodedArgs = append(explodedArgs, args[0:i]...)
-			explodedArgs = append(explodedArgs, args[i])
+			explodedArgs = append(explodedArgs, equalitiesArgs...)
+			explodedArgs = append(explodedArgs, rangeArgs...)
 		} else {
 			comparisons = append(comparisons, rangeComparison)
-			explodedArgs = append(explodedArgs, args[i])
+			explodedArgs = append(explodedArgs, rangeArgs...)
 		}
 	}
 
 	if includeEquals {
-		comparison, err := BuildEqualsComparison(columns, values)
+		comparison, equalitiesArgs, err := equalsComparison(len(columns))
 		if err != nil {
-			return "", explodedArgs, nil
+			return "", explodedArgs, err
 		}
 		comparisons = append(comparisons, comparison)
-		explodedArgs = append(explodedArgs, args...)
+		explodedArgs = append(explodedArgs, equalitiesArgs...)
 	}
 	result = strings.Join(comparisons, " or ")
 	result = fmt.Sprintf("(%s)", result)
@@ -182,102 +298,412 @@ func BuildRangePreparedComparison(columns *ColumnList, args []interface{}, compa
 	return BuildRangeComparison(columns.Names(), values, args, comparisonSign)
 }
 
-func BuildRangeInsertQuery(databaseName, originalTableName, ghostTableName string, sharedColumns []string, mappedSharedColumns []string, uniqueKey string, uniqueKeyColumns *ColumnList, rangeStartValues, rangeEndValues []string, rangeStartArgs, rangeEndArgs []interface{}, includeRangeStartValues bool, transactionalTable bool) (result string, explodedArgs []interface{}, err error) {
+// buildRangeComparisons compares unique key columns with the start and end values of a row copy range, given in
+// copy order: ascending, from the start values up to the end values, or descending, from the start values down to
+// the end values. Start values are exclusive, unless includeRangeStartValues; end values are inclusive.
+// Nullable columns are compared NULL-safe, NULL sorting first, and DESC key parts are compared in reverse, as in
+// the unique key's index.
+func buildRangeComparisons(uniqueKeyColumns *ColumnList, rangeStartValues, rangeEndValues []string, rangeStartArgs, rangeEndArgs []interface{}, includeRangeStartValues bool, copyOrder CopyOrder) (rangeStartComparison string, rangeEndComparison string, explodedArgs []interface{}, err error) {
+	var startRangeComparisonSign ValueComparisonSign = GreaterThanComparisonSign
+	var endRangeComparisonSign ValueComparisonSign = LessThanOrEqualsComparisonSign
+	if includeRangeStartValues {
+		startRangeComparisonSign = GreaterThanOrEqualsComparisonSign
+	}
+	if copyOrder == DescendingCopyOrder {
+		startRangeComparisonSign = LessThanComparisonSign
+		endRangeComparisonSign = GreaterThanOrEqualsComparisonSign
+		if includeRangeStartValues {
+			startRangeComparisonSign = LessThanOrEqualsComparisonSign
+		}
+	}
+	nullable := make([]bool, uniqueKeyColumns.Len())
+	descending := make([]bool, uniqueKeyColumns.Len())
+	for i, column := range uniqueKeyColumns.Columns() {
+		nullable[i] = column.Nullable
+		descending[i] = column.Descending
+	}
+	rangeStartComparison, rangeExplodedArgs, err := buildRangeComparison(uniqueKeyColumns.Names(), nullable, descending, rangeStartValues, uniqueKeyColumns.rangeArgs(rangeStartArgs), startRangeComparisonSign)
+	if err != nil {
+		return "", "", explodedArgs, err
+	}
+	explodedArgs = append(explodedArgs, rangeExplodedArgs...)
+	rangeEndComparison, rangeExplodedArgs, err = buildRangeComparison(uniqueKeyColumns.Names(), nullable, descending, rangeEndValues, uniqueKeyColumns.rangeArgs(rangeEndArgs), endRangeComparisonSign)
+	if err != nil {
+		return "", "", explodedArgs, err
+	}
+	explodedArgs = append(explodedArgs, rangeExplodedArgs...)
+	return rangeStartComparison, rangeEndComparison, explodedArgs, nil
+}
+
+// buildRangePreparedComparisons is buildRangeComparisons with prepared start and end values
+func buildRangePreparedComparisons(uniqueKeyColumns *ColumnList, rangeStartArgs, rangeEndArgs []interface{}, includeRangeStartValues bool, copyOrder CopyOrder) (rangeStartComparison string, rangeEndComparison string, explodedArgs []interface{}, err error) {
+	values := buildColumnsPreparedValues(uniqueKeyColumns)
+	return buildRangeComparisons(uniqueKeyColumns, values, values, rangeStartArgs, rangeEndArgs, includeRangeStartValues, copyOrder)
+}
+
+// buildUniqueKeyColumnOrder returns the ORDER BY direction of given unique key column, iterating the key
+// in given index order: "asc" follows the index, "desc" runs against it. DESC key parts reverse the direction.
+func buildUniqueKeyColumnOrder(column *Column, order string) string {
+	if !column.Descending {
+		return order
+	}
+	if order == "asc" {
+		return "desc"
+	}
+	return "asc"
+}
+
+// buildUniqueKeyOrderings returns the ORDER BY terms iterating given unique key columns in copy order,
+// and in reverse order
+func buildUniqueKeyOrderings(uniqueKeyColumnNames []string, uniqueKeyColumns *ColumnList, copyOrder CopyOrder) (ordering []string, reverseOrdering []string) {
+	order, reverseOrder := "asc", "desc"
+	if copyOrder == DescendingCopyOrder {
+		order, reverseOrder = "desc", "asc"
+	}
+	ordering = make([]string, len(uniqueKeyColumnNames))
+	reverseOrdering = make([]string, len(uniqueKeyColumnNames))
+	for i, column := range uniqueKeyColumns.Columns() {
+		orderedColumn := uniqueKeyColumnNames[i]
+		if column.Type == EnumColumnType {
+			orderedColumn = fmt.Sprintf("concat(%s)", orderedColumn)
+		}
+		ordering[i] = fmt.Sprintf("%s %s", orderedColumn, buildUniqueKeyColumnOrder(&column, order))
+		reverseOrdering[i] = fmt.Sprintf("%s %s", orderedColumn, buildUniqueKeyColumnOrder(&column, reverseOrder))
+	}
+	return ordering, reverseOrdering
+}
+
+// buildPartitionClause returns a PARTITION clause restricting a query to given partition, if any
+func buildPartitionClause(partitionName string) string {
+	if partitionName == "" {
+		return ""
+	}
+	return fmt.Sprintf(" partition (%s)", EscapeName(partitionName))
+}
+
+// buildForceIndexClause returns a FORCE INDEX clause for given index, if any
+func buildForceIndexClause(indexName string) string {
+	if indexName == "" {
+		return ""
+	}
+	return fmt.Sprintf(" force index (%s)", EscapeName(indexName))
+}
+
+// buildRowMatchComparison matches a row by all given columns against given values, NULL-safe
+func buildRowMatchComparison(columns []string, values []string) (result string, err error) {
+	if len(columns) == 0 {
+		return "", fmt.Errorf("Got 0 columns in buildRowMatchComparison")
+	}
+	if len(columns) != len(values) {
+		return "", fmt.Errorf("Got %d columns but %d values in buildRowMatchComparison", len(columns), len(values))
+	}
+	comparisons := make([]string, len(columns))
+	for i, column := range columns {
+		comparisons[i] = fmt.Sprintf("(%s <=> %s)", column, values[i])
+	}
+	return strings.Join(comparisons, " and "), nil
+}
+
+// buildCopiedColumnValue returns the expression by which row copy reads an original table column into
+// given ghost table column, converting its values as needed
+func buildCopiedColumnValue(columnExpression string, mappedColumn *Column) string {
+	if rewrite := mappedColumn.zeroDateRewrite; rewrite != nil {
+		columnExpression = fmt.Sprintf("if(month(%s) = 0 or dayofmonth(%s) = 0, %s, %s)", columnExpression, columnExpression, rewrite.rewrittenLiteral(), columnExpression)
+	}
+	if conversion := mappedColumn.timezoneConversion; conversion != nil {
+		return fmt.Sprintf("convert_tz(%s, '%s', '%s')", columnExpression, conversion.FromTimezone, conversion.ToTimezone)
+	}
+	if conversion := mappedColumn.geometrySRIDConversion; conversion != nil {
+		return fmt.Sprintf("st_srid(%s, %d)", columnExpression, conversion.ToSRID)
+	}
+	return columnExpression
+}
+
+// buildRowFilterClause returns a condition, to follow others, restricting rows to those matching given
+// row filter (see --row-filter). An empty row filter restricts nothing.
+func buildRowFilterClause(rowFilter string) string {
+	if rowFilter == "" {
+		return ""
+	}
+	return fmt.Sprintf(" and (%s)", rowFilter)
+}
+
+func BuildRangeInsertQuery(databaseName, originalTableName, partitionName, ghostDatabaseName, ghostTableName string, sharedColumns []string, mappedSharedColumns *ColumnList, uniqueKey string, uniqueKeyColumns *ColumnList, rangeStartValues, rangeEndValues []string, rangeStartArgs, rangeEndArgs []interface{}, includeRangeStartValues bool, copyOrder CopyOrder, transactionalTable bool, conflictStrategy CopyConflictStrategy, rowFilter string) (result string, explodedArgs []interface{}, err error) {
 	if len(sharedColumns) == 0 {
 		return "", explodedArgs, fmt.Errorf("Got 0 shared columns in BuildRangeInsertQuery")
 	}
 	databaseName = EscapeName(databaseName)
 	originalTableName = EscapeName(originalTableName)
+	ghostDatabaseName = EscapeName(ghostDatabaseName)
 	ghostTableName = EscapeName(ghostTableName)
 
-	mappedSharedColumns = duplicateNames(mappedSharedColumns)
-	for i := range mappedSharedColumns {
-		mappedSharedColumns[i] = EscapeName(mappedSharedColumns[i])
+	if len(sharedColumns) != mappedSharedColumns.Len() {
+		return "", explodedArgs, fmt.Errorf("Got %d shared columns and %d mapped shared columns in BuildRangeInsertQuery", len(sharedColumns), mappedSharedColumns.Len())
+	}
+	// Columns generated on the ghost table are computed by the server, not copied
+	writableSharedColumns, mappedSharedColumns := filterWritableColumns(NewColumnList(sharedColumns), mappedSharedColumns)
+	if writableSharedColumns.Len() == 0 {
+		return "", explodedArgs, fmt.Errorf("Got 0 writable shared columns in BuildRangeInsertQuery")
+	}
+	mappedSharedColumnNames := duplicateNames(mappedSharedColumns.Names())
+	for i := range mappedSharedColumnNames {
+		mappedSharedColumnNames[i] = EscapeName(mappedSharedColumnNames[i])
 	}
-	mappedSharedColumnsListing := strings.Join(mappedSharedColumns, ", ")
+	mappedSharedColumnsListing := strings.Join(mappedSharedColumnNames, ", ")
 
-	sharedColumns = duplicateNames(sharedColumns)
+	sharedColumns = writableSharedColumns.Names()
 	for i := range sharedColumns {
-		sharedColumns[i] = EscapeName(sharedColumns[i])
+		sharedColumns[i] = buildCopiedColumnValue(EscapeName(sharedColumns[i]), &mappedSharedColumns.Columns()[i])
 	}
 	sharedColumnsListing := strings.Join(sharedColumns, ", ")
 
-	uniqueKey = EscapeName(uniqueKey)
-	var minRangeComparisonSign ValueComparisonSign = GreaterThanComparisonSign
-	if includeRangeStartValues {
-		minRangeComparisonSign = GreaterThanOrEqualsComparisonSign
-	}
-	rangeStartComparison, rangeExplodedArgs, err := BuildRangeComparison(uniqueKeyColumns.Names(), rangeStartValues, rangeStartArgs, minRangeComparisonSign)
-	if err != nil {
-		return "", explodedArgs, err
-	}
-	explodedArgs = append(explodedArgs, rangeExplodedArgs...)
-	rangeEndComparison, rangeExplodedArgs, err := BuildRangeComparison(uniqueKeyColumns.Names(), rangeEndValues, rangeEndArgs, LessThanOrEqualsComparisonSign)
+	rangeStartComparison, rangeEndComparison, explodedArgs, err := buildRangeComparisons(uniqueKeyColumns, rangeStartValues, rangeEndValues, rangeStartArgs, rangeEndArgs, includeRangeStartValues, copyOrder)
 	if err != nil {
 		return "", explodedArgs, err
 	}
-	explodedArgs = append(explodedArgs, rangeExplodedArgs...)
 	transactionalClause := ""
 	if transactionalTable {
 		transactionalClause = "lock in share mode"
 	}
+	var insertStatement string
+	missingRowsClause := ""
+	switch conflictStrategy {
+	case IgnoreCopyConflictStrategy, "":
+		insertStatement = fmt.Sprintf("insert /* gh-ost %s.%s */ ignore", databaseName, originalTableName)
+	case ReplaceCopyConflictStrategy:
+		insertStatement = fmt.Sprintf("replace /* gh-ost %s.%s */", databaseName, originalTableName)
+	case FailCopyConflictStrategy:
+		insertStatement = fmt.Sprintf("insert /* gh-ost %s.%s */", databaseName, originalTableName)
+	case SkipExistingCopyConflictStrategy:
+		insertStatement = fmt.Sprintf("insert /* gh-ost %s.%s */", databaseName, originalTableName)
+		// Within the subquery, unqualified columns are the ghost table's
+		qualifiedSharedColumns := make([]string, len(sharedColumns))
+		for i, column := range writableSharedColumns.Names() {
+			qualifiedSharedColumns[i] = buildCopiedColumnValue(fmt.Sprintf("%s.%s.%s", databaseName, originalTableName, EscapeName(column)), &mappedSharedColumns.Columns()[i])
+		}
+		rowMatchComparison, err := buildRowMatchComparison(mappedSharedColumnNames, qualifiedSharedColumns)
+		if err != nil {
+			return "", explodedArgs, err
+		}
+		missingRowsClause = fmt.Sprintf(" and not exists (select 1 from %s.%s where %s)", ghostDatabaseName, ghostTableName, rowMatchComparison)
+	default:
+		return "", explodedArgs, fmt.Errorf("Unknown copy conflict strategy: %s", conflictStrategy)
+	}
 	result = fmt.Sprintf(`
-      insert /* gh-ost %s.%s */ ignore into %s.%s (%s)
-      (select %s from %s.%s force index (%s)
-        where (%s and %s) %s
+      %s into %s.%s (%s)
+      (select %s from %s.%s%s%s
+        where (%s and %s)%s%s %s
       )
-    `, databaseName, originalTableName, databaseName, ghostTableName, mappedSharedColumnsListing,
-		sharedColumnsListing, databaseName, originalTableName, uniqueKey,
-		rangeStartComparison, rangeEndComparison, transactionalClause)
+    `, insertStatement, ghostDatabaseName, ghostTableName, mappedSharedColumnsListing,
+		sharedColumnsListing, databaseName, originalTableName, buildPartitionClause(partitionName), buildForceIndexClause(uniqueKey),
+		rangeStartComparison, rangeEndComparison, buildRowFilterClause(rowFilter), missingRowsClause, transactionalClause)
 	return result, explodedArgs, nil
 }
 
-func BuildRangeInsertPreparedQuery(databaseName, originalTableName, ghostTableName string, sharedColumns []string, mappedSharedColumns []string, uniqueKey string, uniqueKeyColumns *ColumnList, rangeStartArgs, rangeEndArgs []interface{}, includeRangeStartValues bool, transactionalTable bool) (result string, explodedArgs []interface{}, err error) {
+func BuildRangeInsertPreparedQuery(databaseName, originalTableName, partitionName, ghostDatabaseName, ghostTableName string, sharedColumns []string, mappedSharedColumns *ColumnList, uniqueKey string, uniqueKeyColumns *ColumnList, rangeStartArgs, rangeEndArgs []interface{}, includeRangeStartValues bool, copyOrder CopyOrder, transactionalTable bool, conflictStrategy CopyConflictStrategy, rowFilter string) (result string, explodedArgs []interface{}, err error) {
 	rangeStartValues := buildColumnsPreparedValues(uniqueKeyColumns)
 	rangeEndValues := buildColumnsPreparedValues(uniqueKeyColumns)
-	return BuildRangeInsertQuery(databaseName, originalTableName, ghostTableName, sharedColumns, mappedSharedColumns, uniqueKey, uniqueKeyColumns, rangeStartValues, rangeEndValues, rangeStartArgs, rangeEndArgs, includeRangeStartValues, transactionalTable)
+	return BuildRangeInsertQuery(databaseName, originalTableName, partitionName, ghostDatabaseName, ghostTableName, sharedColumns, mappedSharedColumns, uniqueKey, uniqueKeyColumns, rangeStartValues, rangeEndValues, rangeStartArgs, rangeEndArgs, includeRangeStartValues, copyOrder, transactionalTable, conflictStrategy, rowFilter)
 }
 
-func BuildUniqueKeyRangeEndPreparedQueryViaOffset(databaseName, tableName string, uniqueKeyColumns *ColumnList, rangeStartArgs, rangeEndArgs []interface{}, chunkSize int64, includeRangeStartValues bool, hint string) (result string, explodedArgs []interface{}, err error) {
+// BuildRangeCountPreparedQuery counts the rows of a row copy chunk, i.e. the rows BuildRangeInsertPreparedQuery reads
+func BuildRangeCountPreparedQuery(databaseName, tableName, partitionName, uniqueKey string, uniqueKeyColumns *ColumnList, rangeStartArgs, rangeEndArgs []interface{}, includeRangeStartValues bool, copyOrder CopyOrder, transactionalTable bool, rowFilter string) (result string, explodedArgs []interface{}, err error) {
 	if uniqueKeyColumns.Len() == 0 {
-		return "", explodedArgs, fmt.Errorf("Got 0 columns in BuildUniqueKeyRangeEndPreparedQuery")
+		return "", explodedArgs, fmt.Errorf("Got 0 columns in BuildRangeCountPreparedQuery")
 	}
 	databaseName = EscapeName(databaseName)
 	tableName = EscapeName(tableName)
 
-	var startRangeComparisonSign ValueComparisonSign = GreaterThanComparisonSign
-	if includeRangeStartValues {
-		startRangeComparisonSign = GreaterThanOrEqualsComparisonSign
+	rangeStartComparison, rangeEndComparison, explodedArgs, err := buildRangePreparedComparisons(uniqueKeyColumns, rangeStartArgs, rangeEndArgs, includeRangeStartValues, copyOrder)
+	if err != nil {
+		return "", explodedArgs, err
+	}
+	transactionalClause := ""
+	if transactionalTable {
+		transactionalClause = "lock in share mode"
+	}
+	result = fmt.Sprintf(`
+      select /* gh-ost %s.%s */ count(*)
+        from %s.%s%s%s
+        where (%s and %s)%s %s
+    `, databaseName, tableName,
+		databaseName, tableName, buildPartitionClause(partitionName), buildForceIndexClause(uniqueKey),
+		rangeStartComparison, rangeEndComparison, buildRowFilterClause(rowFilter), transactionalClause)
+	return result, explodedArgs, nil
+}
+
+// BuildRangeCheckConstraintsViolationPreparedQuery looks for a row of a row copy chunk which violates any of the
+// given CHECK constraints, whose clauses are in terms of the given table's columns. A constraint is only violated
+// when its clause evaluates to false; NULL satisfies it. The query returns the row's unique key values, followed
+// by a flag per constraint telling whether the row violates it.
+func BuildRangeCheckConstraintsViolationPreparedQuery(databaseName, tableName, partitionName, uniqueKey string, uniqueKeyColumns *ColumnList, rangeStartArgs, rangeEndArgs []interface{}, includeRangeStartValues bool, copyOrder CopyOrder, transactionalTable bool, rowFilter string, checkConstraints [](*CheckConstraint)) (result string, explodedArgs []interface{}, err error) {
+	if uniqueKeyColumns.Len() == 0 {
+		return "", explodedArgs, fmt.Errorf("Got 0 columns in BuildRangeCheckConstraintsViolationPreparedQuery")
+	}
+	if len(checkConstraints) == 0 {
+		return "", explodedArgs, fmt.Errorf("No check constraints found in BuildRangeCheckConstraintsViolationPreparedQuery")
 	}
-	rangeStartComparison, rangeExplodedArgs, err := BuildRangePreparedComparison(uniqueKeyColumns, rangeStartArgs, startRangeComparisonSign)
+	databaseName = EscapeName(databaseName)
+	tableName = EscapeName(tableName)
+
+	rangeStartComparison, rangeEndComparison, explodedArgs, err := buildRangePreparedComparisons(uniqueKeyColumns, rangeStartArgs, rangeEndArgs, includeRangeStartValues, copyOrder)
 	if err != nil {
 		return "", explodedArgs, err
 	}
-	explodedArgs = append(explodedArgs, rangeExplodedArgs...)
-	rangeEndComparison, rangeExplodedArgs, err := BuildRangePreparedComparison(uniqueKeyColumns, rangeEndArgs, LessThanOrEqualsComparisonSign)
+	selectColumns := []string{}
+	for _, column := range uniqueKeyColumns.Names() {
+		selectColumns = append(selectColumns, EscapeName(column))
+	}
+	violations := []string{}
+	for _, checkConstraint := range checkConstraints {
+		violations = append(violations, fmt.Sprintf("(%s) is false", checkConstraint.Clause))
+	}
+	selectColumns = append(selectColumns, violations...)
+	transactionalClause := ""
+	if transactionalTable {
+		transactionalClause = "lock in share mode"
+	}
+	result = fmt.Sprintf(`
+      select /* gh-ost %s.%s */ %s
+        from %s.%s%s%s
+        where (%s and %s)%s and (%s)
+        limit 1 %s
+    `, databaseName, tableName, strings.Join(selectColumns, ", "),
+		databaseName, tableName, buildPartitionClause(partitionName), buildForceIndexClause(uniqueKey),
+		rangeStartComparison, rangeEndComparison, buildRowFilterClause(rowFilter), strings.Join(violations, " or "), transactionalClause)
+	return result, explodedArgs, nil
+}
+
+// isApproximateMySQLType tells whether given column type is FLOAT or DOUBLE, whose values do not
+// convert exactly between types
+func isApproximateMySQLType(mysqlType string) bool {
+	mysqlType = strings.ToLower(mysqlType)
+	return strings.HasPrefix(mysqlType, "float") || strings.HasPrefix(mysqlType, "double") || strings.HasPrefix(mysqlType, "real")
+}
+
+// buildChecksumCastType returns the type to cast an original table's value into, such that it checksums
+// as the value row copy writes onto the ghost table's column. An empty result means no cast is required.
+func buildChecksumCastType(column, mappedColumn *Column) string {
+	if column.MySQLType == mappedColumn.MySQLType && column.Charset == mappedColumn.Charset {
+		return ""
+	}
+	mysqlType := strings.ToLower(mappedColumn.MySQLType)
+	// fractional seconds precision, or DECIMAL precision and scale, e.g. "(6)" or "(10,2)"
+	precision := ""
+	if start := strings.Index(mysqlType, "("); start >= 0 {
+		if end := strings.Index(mysqlType[start:], ")"); end >= 0 {
+			precision = mysqlType[start : start+end+1]
+		}
+	}
+	switch {
+	case mappedColumn.Charset == "binary":
+		return "binary"
+	case mappedColumn.Charset != "":
+		// Lengths are deliberately not cast to: a value truncated by row copy must not checksum the same
+		return fmt.Sprintf("char character set %s", mappedColumn.Charset)
+	case strings.HasPrefix(mysqlType, "decimal"):
+		return "decimal" + precision
+	case strings.HasPrefix(mysqlType, "tinyint"), strings.HasPrefix(mysqlType, "smallint"), strings.HasPrefix(mysqlType, "mediumint"),
+		strings.HasPrefix(mysqlType, "int"), strings.HasPrefix(mysqlType, "bigint"):
+		if mappedColumn.IsUnsigned {
+			return "unsigned"
+		}
+		return "signed"
+	case strings.HasPrefix(mysqlType, "datetime"), strings.HasPrefix(mysqlType, "timestamp"):
+		return "datetime" + precision
+	case strings.HasPrefix(mysqlType, "date"):
+		return "date"
+	case strings.HasPrefix(mysqlType, "time"):
+		return "time" + precision
+	case strings.HasPrefix(mysqlType, "json"):
+		return "json"
+	}
+	return ""
+}
+
+// BuildChecksumColumnExpressions returns the per column expressions BuildRangeChecksumPreparedQuery checksums on the
+// original and on the ghost table. Original table values are cast to the ghost table's column types where these differ.
+// Columns generated on the ghost table, and FLOAT/DOUBLE columns, are not checksummed.
+func BuildChecksumColumnExpressions(sharedColumns, mappedSharedColumns *ColumnList) (originalExpressions, ghostExpressions []string, err error) {
+	if sharedColumns.Len() != mappedSharedColumns.Len() {
+		return originalExpressions, ghostExpressions, fmt.Errorf("Got %d shared columns and %d mapped shared columns in BuildChecksumColumnExpressions", sharedColumns.Len(), mappedSharedColumns.Len())
+	}
+	sharedColumns, mappedSharedColumns = filterWritableColumns(sharedColumns, mappedSharedColumns)
+	for i, column := range sharedColumns.Columns() {
+		mappedColumn := mappedSharedColumns.Columns()[i]
+		if isApproximateMySQLType(column.MySQLType) || isApproximateMySQLType(mappedColumn.MySQLType) {
+			continue
+		}
+		originalExpression := buildCopiedColumnValue(EscapeName(column.Name), &mappedColumn)
+		if castType := buildChecksumCastType(&column, &mappedColumn); castType != "" {
+			originalExpression = fmt.Sprintf("cast(%s as %s)", originalExpression, castType)
+		}
+		originalExpressions = append(originalExpressions, originalExpression)
+		ghostExpressions = append(ghostExpressions, EscapeName(mappedColumn.Name))
+	}
+	if len(originalExpressions) == 0 {
+		return originalExpressions, ghostExpressions, fmt.Errorf("Got 0 checksummable columns in BuildChecksumColumnExpressions")
+	}
+	return originalExpressions, ghostExpressions, nil
+}
+
+// BuildRangeChecksumPreparedQuery reads the number of rows of a row copy chunk, and their checksum, computed over
+// given column expressions as returned by BuildChecksumColumnExpressions. An empty unique key name means no index is forced.
+func BuildRangeChecksumPreparedQuery(databaseName, tableName, partitionName, uniqueKey string, uniqueKeyColumns *ColumnList, checksumExpressions []string, rangeStartArgs, rangeEndArgs []interface{}, includeRangeStartValues bool, copyOrder CopyOrder, transactionalTable bool, rowFilter string) (result string, explodedArgs []interface{}, err error) {
+	if uniqueKeyColumns.Len() == 0 {
+		return "", explodedArgs, fmt.Errorf("Got 0 columns in BuildRangeChecksumPreparedQuery")
+	}
+	if len(checksumExpressions) == 0 {
+		return "", explodedArgs, fmt.Errorf("Got 0 checksum expressions in BuildRangeChecksumPreparedQuery")
+	}
+	databaseName = EscapeName(databaseName)
+	tableName = EscapeName(tableName)
+
+	rangeStartComparison, rangeEndComparison, explodedArgs, err := buildRangePreparedComparisons(uniqueKeyColumns, rangeStartArgs, rangeEndArgs, includeRangeStartValues, copyOrder)
+	if err != nil {
+		return "", explodedArgs, err
+	}
+	transactionalClause := ""
+	if transactionalTable {
+		transactionalClause = "lock in share mode"
+	}
+	// A NULL checksums apart from any value
+	columnChecksums := make([]string, len(checksumExpressions))
+	for i, expression := range checksumExpressions {
+		columnChecksums[i] = fmt.Sprintf("ifnull(crc32(%s), 'NULL')", expression)
+	}
+	result = fmt.Sprintf(`
+      select /* gh-ost %s.%s */ count(*), coalesce(bit_xor(crc32(concat_ws('#', %s))), 0)
+        from %s.%s%s%s
+        where (%s and %s)%s %s
+    `, databaseName, tableName, strings.Join(columnChecksums, ", "),
+		databaseName, tableName, buildPartitionClause(partitionName), buildForceIndexClause(uniqueKey),
+		rangeStartComparison, rangeEndComparison, buildRowFilterClause(rowFilter), transactionalClause)
+	return result, explodedArgs, nil
+}
+
+func BuildUniqueKeyRangeEndPreparedQueryViaOffset(databaseName, tableName, partitionName, uniqueKey string, uniqueKeyColumns *ColumnList, rangeStartArgs, rangeEndArgs []interface{}, chunkSize int64, includeRangeStartValues bool, copyOrder CopyOrder, hint string) (result string, explodedArgs []interface{}, err error) {
+	if uniqueKeyColumns.Len() == 0 {
+		return "", explodedArgs, fmt.Errorf("Got 0 columns in BuildUniqueKeyRangeEndPreparedQuery")
+	}
+	databaseName = EscapeName(databaseName)
+	tableName = EscapeName(tableName)
+
+	rangeStartComparison, rangeEndComparison, explodedArgs, err := buildRangePreparedComparisons(uniqueKeyColumns, rangeStartArgs, rangeEndArgs, includeRangeStartValues, copyOrder)
 	if err != nil {
 		return "", explodedArgs, err
 	}
-	explodedArgs = append(explodedArgs, rangeExplodedArgs...)
 
 	uniqueKeyColumnNames := duplicateNames(uniqueKeyColumns.Names())
-	uniqueKeyColumnAscending := make([]string, len(uniqueKeyColumnNames))
-	uniqueKeyColumnDescending := make([]string, len(uniqueKeyColumnNames))
-	for i, column := range uniqueKeyColumns.Columns() {
+	for i := range uniqueKeyColumnNames {
 		uniqueKeyColumnNames[i] = EscapeName(uniqueKeyColumnNames[i])
-		if column.Type == EnumColumnType {
-			uniqueKeyColumnAscending[i] = fmt.Sprintf("concat(%s) asc", uniqueKeyColumnNames[i])
-			uniqueKeyColumnDescending[i] = fmt.Sprintf("concat(%s) desc", uniqueKeyColumnNames[i])
-		} else {
-			uniqueKeyColumnAscending[i] = fmt.Sprintf("%s asc", uniqueKeyColumnNames[i])
-			uniqueKeyColumnDescending[i] = fmt.Sprintf("%s desc", uniqueKeyColumnNames[i])
-		}
 	}
+	uniqueKeyColumnOrdering, _ := buildUniqueKeyOrderings(uniqueKeyColumnNames, uniqueKeyColumns, copyOrder)
 	result = fmt.Sprintf(`
 				select  /* gh-ost %s.%s %s */
 						%s
 					from
-						%s.%s
+						%s.%s%s%s
 					where %s and %s
 					order by
 						%s
@@ -285,56 +711,38 @@ func BuildUniqueKeyRangeEndPreparedQueryViaOffset(databaseName, tableName string
 					offset %d
     `, databaseName, tableName, hint,
 		strings.Join(uniqueKeyColumnNames, ", "),
-		databaseName, tableName,
+		databaseName, tableName, buildPartitionClause(partitionName), buildForceIndexClause(uniqueKey),
 		rangeStartComparison, rangeEndComparison,
-		strings.Join(uniqueKeyColumnAscending, ", "),
+		strings.Join(uniqueKeyColumnOrdering, ", "),
 		(chunkSize - 1),
 	)
 	return result, explodedArgs, nil
 }
 
-func BuildUniqueKeyRangeEndPreparedQueryViaTemptable(databaseName, tableName string, uniqueKeyColumns *ColumnList, rangeStartArgs, rangeEndArgs []interface{}, chunkSize int64, includeRangeStartValues bool, hint string) (result string, explodedArgs []interface{}, err error) {
+func BuildUniqueKeyRangeEndPreparedQueryViaTemptable(databaseName, tableName, partitionName, uniqueKey string, uniqueKeyColumns *ColumnList, rangeStartArgs, rangeEndArgs []interface{}, chunkSize int64, includeRangeStartValues bool, copyOrder CopyOrder, hint string) (result string, explodedArgs []interface{}, err error) {
 	if uniqueKeyColumns.Len() == 0 {
 		return "", explodedArgs, fmt.Errorf("Got 0 columns in BuildUniqueKeyRangeEndPreparedQuery")
 	}
 	databaseName = EscapeName(databaseName)
 	tableName = EscapeName(tableName)
 
-	var startRangeComparisonSign ValueComparisonSign = GreaterThanComparisonSign
-	if includeRangeStartValues {
-		startRangeComparisonSign = GreaterThanOrEqualsComparisonSign
-	}
-	rangeStartComparison, rangeExplodedArgs, err := BuildRangePreparedComparison(uniqueKeyColumns, rangeStartArgs, startRangeComparisonSign)
-	if err != nil {
-		return "", explodedArgs, err
-	}
-	explodedArgs = append(explodedArgs, rangeExplodedArgs...)
-	rangeEndComparison, rangeExplodedArgs, err := BuildRangePreparedComparison(uniqueKeyColumns, rangeEndArgs, LessThanOrEqualsComparisonSign)
+	rangeStartComparison, rangeEndComparison, explodedArgs, err := buildRangePreparedComparisons(uniqueKeyColumns, rangeStartArgs, rangeEndArgs, includeRangeStartValues, copyOrder)
 	if err != nil {
 		return "", explodedArgs, err
 	}
-	explodedArgs = append(explodedArgs, rangeExplodedArgs...)
 
 	uniqueKeyColumnNames := duplicateNames(uniqueKeyColumns.Names())
-	uniqueKeyColumnAscending := make([]string, len(uniqueKeyColumnNames))
-	uniqueKeyColumnDescending := make([]string, len(uniqueKeyColumnNames))
-	for i, column := range uniqueKeyColumns.Columns() {
+	for i := range uniqueKeyColumnNames {
 		uniqueKeyColumnNames[i] = EscapeName(uniqueKeyColumnNames[i])
-		if column.Type == EnumColumnType {
-			uniqueKeyColumnAscending[i] = fmt.Sprintf("concat(%s) asc", uniqueKeyColumnNames[i])
-			uniqueKeyColumnDescending[i] = fmt.Sprintf("concat(%s) desc", uniqueKeyColumnNames[i])
-		} else {
-			uniqueKeyColumnAscending[i] = fmt.Sprintf("%s asc", uniqueKeyColumnNames[i])
-			uniqueKeyColumnDescending[i] = fmt.Sprintf("%s desc", uniqueKeyColumnNames[i])
-		}
 	}
+	uniqueKeyColumnOrdering, uniqueKeyColumnReverseOrdering := buildUniqueKeyOrderings(uniqueKeyColumnNames, uniqueKeyColumns, copyOrder)
 	result = fmt.Sprintf(`
       select /* gh-ost %s.%s %s */ %s
 				from (
 					select
 							%s
 						from
-							%s.%s
+							%s.%s%s%s
 						where %s and %s
 						order by
 							%s
@@ -344,23 +752,25 @@ func BuildUniqueKeyRangeEndPreparedQueryViaTemptable(databaseName, tableName str
 				%s
 			limit 1
     `, databaseName, tableName, hint, strings.Join(uniqueKeyColumnNames, ", "),
-		strings.Join(uniqueKeyColumnNames, ", "), databaseName, tableName,
+		strings.Join(uniqueKeyColumnNames, ", "), databaseName, tableName, buildPartitionClause(partitionName), buildForceIndexClause(uniqueKey),
 		rangeStartComparison, rangeEndComparison,
-		strings.Join(uniqueKeyColumnAscending, ", "), chunkSize,
-		strings.Join(uniqueKeyColumnDescending, ", "),
+		strings.Join(uniqueKeyColumnOrdering, ", "), chunkSize,
+		strings.Join(uniqueKeyColumnReverseOrdering, ", "),
 	)
 	return result, explodedArgs, nil
 }
 
-func BuildUniqueKeyMinValuesPreparedQuery(databaseName, tableName string, uniqueKeyColumns *ColumnList) (string, error) {
-	return buildUniqueKeyMinMaxValuesPreparedQuery(databaseName, tableName, uniqueKeyColumns, "asc")
+func BuildUniqueKeyMinValuesPreparedQuery(databaseName, tableName, partitionName, uniqueKey string, uniqueKeyColumns *ColumnList) (string, error) {
+	return buildUniqueKeyMinMaxValuesPreparedQuery(databaseName, tableName, partitionName, uniqueKey, uniqueKeyColumns, "asc")
 }
 
-func BuildUniqueKeyMaxValuesPreparedQuery(databaseName, tableName string, uniqueKeyColumns *ColumnList) (string, error) {
-	return buildUniqueKeyMinMaxValuesPreparedQuery(databaseName, tableName, uniqueKeyColumns, "desc")
+func BuildUniqueKeyMaxValuesPreparedQuery(databaseName, tableName, partitionName, uniqueKey string, uniqueKeyColumns *ColumnList) (string, error) {
+	return buildUniqueKeyMinMaxValuesPreparedQuery(databaseName, tableName, partitionName, uniqueKey, uniqueKeyColumns, "desc")
 }
 
-func buildUniqueKeyMinMaxValuesPreparedQuery(databaseName, tableName string, uniqueKeyColumns *ColumnList, order string) (string, error) {
+// buildUniqueKeyMinMaxValuesPreparedQuery reads the min or max unique key values, in index order. Values of nullable
+// unique key columns may be NULL: NULL sorts first, and range comparisons are NULL-safe (see buildRangeComparisons).
+func buildUniqueKeyMinMaxValuesPreparedQuery(databaseName, tableName, partitionName, uniqueKey string, uniqueKeyColumns *ColumnList, order string) (string, error) {
 	if uniqueKeyColumns.Len() == 0 {
 		return "", fmt.Errorf("Got 0 columns in BuildUniqueKeyMinMaxValuesPreparedQuery")
 	}
@@ -371,21 +781,22 @@ func buildUniqueKeyMinMaxValuesPreparedQuery(databaseName, tableName string, uni
 	uniqueKeyColumnOrder := make([]string, len(uniqueKeyColumnNames))
 	for i, column := range uniqueKeyColumns.Columns() {
 		uniqueKeyColumnNames[i] = EscapeName(uniqueKeyColumnNames[i])
+		columnOrder := buildUniqueKeyColumnOrder(&column, order)
 		if column.Type == EnumColumnType {
-			uniqueKeyColumnOrder[i] = fmt.Sprintf("concat(%s) %s", uniqueKeyColumnNames[i], order)
+			uniqueKeyColumnOrder[i] = fmt.Sprintf("concat(%s) %s", uniqueKeyColumnNames[i], columnOrder)
 		} else {
-			uniqueKeyColumnOrder[i] = fmt.Sprintf("%s %s", uniqueKeyColumnNames[i], order)
+			uniqueKeyColumnOrder[i] = fmt.Sprintf("%s %s", uniqueKeyColumnNames[i], columnOrder)
 		}
 	}
 	query := fmt.Sprintf(`
       select /* gh-ost %s.%s */ %s
 				from
-					%s.%s
+					%s.%s%s%s
 				order by
 					%s
 				limit 1
     `, databaseName, tableName, strings.Join(uniqueKeyColumnNames, ", "),
-		databaseName, tableName,
+		databaseName, tableName, buildPartitionClause(partitionName), buildForceIndexClause(uniqueKey),
 		strings.Join(uniqueKeyColumnOrder, ", "),
 	)
 	return query, nil
@@ -422,6 +833,88 @@ func BuildDMLDeleteQuery(databaseName, tableName string, tableColumns, uniqueKey
 	return result, uniqueKeyArgs, nil
 }
 
+// BuildDMLMultiDeleteQuery builds a single DELETE statement for multiple rows, identified by their unique key values
+// buildUniqueKeyInComparison builds a comparison matching given rows by unique key values
+func buildUniqueKeyInComparison(funcName string, tableColumns, uniqueKeyColumns *ColumnList, rowsArgs [][]interface{}) (result string, uniqueKeyArgs []interface{}, err error) {
+	if len(rowsArgs) == 0 {
+		return result, uniqueKeyArgs, fmt.Errorf("Got 0 rows in %s", funcName)
+	}
+	if uniqueKeyColumns.Len() == 0 {
+		return result, uniqueKeyArgs, fmt.Errorf("No unique key columns found in %s", funcName)
+	}
+	rowValues := buildPreparedValues(uniqueKeyColumns.Len())
+	rowsValues := make([]string, len(rowsArgs))
+	for i, args := range rowsArgs {
+		if len(args) != tableColumns.Len() {
+			return result, uniqueKeyArgs, fmt.Errorf("args count differs from table column count in %s", funcName)
+		}
+		for _, column := range uniqueKeyColumns.Columns() {
+			tableOrdinal := tableColumns.Ordinals[column.Name]
+			arg := column.convertArg(args[tableOrdinal], true)
+			uniqueKeyArgs = append(uniqueKeyArgs, arg)
+		}
+		rowsValues[i] = strings.Join(rowValues, ", ")
+	}
+	uniqueKeyColumnNames := duplicateNames(uniqueKeyColumns.Names())
+	for i := range uniqueKeyColumnNames {
+		uniqueKeyColumnNames[i] = EscapeName(uniqueKeyColumnNames[i])
+	}
+	result = fmt.Sprintf("%s in (%s)", uniqueKeyColumnNames[0], strings.Join(rowsValues, ", "))
+	if uniqueKeyColumns.Len() > 1 {
+		result = fmt.Sprintf("(%s) in ((%s))", strings.Join(uniqueKeyColumnNames, ", "), strings.Join(rowsValues, "), ("))
+	}
+	return result, uniqueKeyArgs, nil
+}
+
+func BuildDMLMultiDeleteQuery(databaseName, tableName string, tableColumns, uniqueKeyColumns *ColumnList, rowsArgs [][]interface{}) (result string, uniqueKeyArgs []interface{}, err error) {
+	inComparison, uniqueKeyArgs, err := buildUniqueKeyInComparison("BuildDMLMultiDeleteQuery", tableColumns, uniqueKeyColumns, rowsArgs)
+	if err != nil {
+		return result, uniqueKeyArgs, err
+	}
+	databaseName = EscapeName(databaseName)
+	tableName = EscapeName(tableName)
+
+	result = fmt.Sprintf(`
+			delete /* gh-ost %s.%s */
+				from
+					%s.%s
+				where
+					%s
+		`, databaseName, tableName,
+		databaseName, tableName,
+		inComparison,
+	)
+	return result, uniqueKeyArgs, nil
+}
+
+// BuildDMLRowFilterDeleteQuery deletes given rows, identified by unique key values, unless they match given
+// row filter (see --row-filter). Rows for which the filter evaluates as NULL do not match.
+func BuildDMLRowFilterDeleteQuery(databaseName, tableName, rowFilter string, tableColumns, uniqueKeyColumns *ColumnList, rowsArgs [][]interface{}) (result string, uniqueKeyArgs []interface{}, err error) {
+	if rowFilter == "" {
+		return result, uniqueKeyArgs, fmt.Errorf("Got empty row filter in BuildDMLRowFilterDeleteQuery")
+	}
+	inComparison, uniqueKeyArgs, err := buildUniqueKeyInComparison("BuildDMLRowFilterDeleteQuery", tableColumns, uniqueKeyColumns, rowsArgs)
+	if err != nil {
+		return result, uniqueKeyArgs, err
+	}
+	databaseName = EscapeName(databaseName)
+	tableName = EscapeName(tableName)
+
+	result = fmt.Sprintf(`
+			delete /* gh-ost %s.%s */
+				from
+					%s.%s
+				where
+					%s
+					and ((%s) is not true)
+		`, databaseName, tableName,
+		databaseName, tableName,
+		inComparison,
+		rowFilter,
+	)
+	return result, uniqueKeyArgs, nil
+}
+
 func BuildDMLInsertQuery(databaseName, tableName string, tableColumns, sharedColumns, mappedSharedColumns *ColumnList, args []interface{}) (result string, sharedArgs []interface{}, err error) {
 	if len(args) != tableColumns.Len() {
 		return result, args, fmt.Errorf("args count differs from table column count in BuildDMLInsertQuery")
@@ -429,7 +922,7 @@ func BuildDMLInsertQuery(databaseName, tableName string, tableColumns, sharedCol
 	if !sharedColumns.IsSubsetOf(tableColumns) {
 		return result, args, fmt.Errorf("shared columns is not a subset of table columns in BuildDMLInsertQuery")
 	}
-	if sharedColumns.Len() == 0 {
+	if sharedColumns, mappedSharedColumns = filterWritableColumns(sharedColumns, mappedSharedColumns); sharedColumns.Len() == 0 {
 		return result, args, fmt.Errorf("No shared columns found in BuildDMLInsertQuery")
 	}
 	databaseName = EscapeName(databaseName)
@@ -461,6 +954,55 @@ func BuildDMLInsertQuery(databaseName, tableName string, tableColumns, sharedCol
 	return result, sharedArgs, nil
 }
 
+// BuildDMLMultiInsertQuery builds a single multi-row REPLACE statement. All rows share the same columns.
+func BuildDMLMultiInsertQuery(databaseName, tableName string, tableColumns, sharedColumns, mappedSharedColumns *ColumnList, rowsArgs [][]interface{}) (result string, sharedArgs []interface{}, err error) {
+	if len(rowsArgs) == 0 {
+		return result, sharedArgs, fmt.Errorf("Got 0 rows in BuildDMLMultiInsertQuery")
+	}
+	if !sharedColumns.IsSubsetOf(tableColumns) {
+		return result, sharedArgs, fmt.Errorf("shared columns is not a subset of table columns in BuildDMLMultiInsertQuery")
+	}
+	if sharedColumns, mappedSharedColumns = filterWritableColumns(sharedColumns, mappedSharedColumns); sharedColumns.Len() == 0 {
+		return result, sharedArgs, fmt.Errorf("No shared columns found in BuildDMLMultiInsertQuery")
+	}
+	databaseName = EscapeName(databaseName)
+	tableName = EscapeName(tableName)
+
+	for _, args := range rowsArgs {
+		if len(args) != tableColumns.Len() {
+			return result, sharedArgs, fmt.Errorf("args count differs from table column count in BuildDMLMultiInsertQuery")
+		}
+		for _, column := range sharedColumns.Columns() {
+			tableOrdinal := tableColumns.Ordinals[column.Name]
+			arg := column.convertArg(args[tableOrdinal], false)
+			sharedArgs = append(sharedArgs, arg)
+		}
+	}
+
+	mappedSharedColumnNames := duplicateNames(mappedSharedColumns.Names())
+	for i := range mappedSharedColumnNames {
+		mappedSharedColumnNames[i] = EscapeName(mappedSharedColumnNames[i])
+	}
+	preparedValues := strings.Join(buildColumnsPreparedValues(mappedSharedColumns), ", ")
+	rowsValues := make([]string, len(rowsArgs))
+	for i := range rowsValues {
+		rowsValues[i] = fmt.Sprintf("(%s)", preparedValues)
+	}
+
+	result = fmt.Sprintf(`
+			replace /* gh-ost %s.%s */ into
+				%s.%s
+					(%s)
+				values
+					%s
+		`, databaseName, tableName,
+		databaseName, tableName,
+		strings.Join(mappedSharedColumnNames, ", "),
+		strings.Join(rowsValues, ", "),
+	)
+	return result, sharedArgs, nil
+}
+
 func BuildDMLUpdateQuery(databaseName, tableName string, tableColumns, sharedColumns, mappedSharedColumns, uniqueKeyColumns *ColumnList, valueArgs, whereArgs []interface{}) (result string, sharedArgs, uniqueKeyArgs []interface{}, err error) {
 	if len(valueArgs) != tableColumns.Len() {
 		return result, sharedArgs, uniqueKeyArgs, fmt.Errorf("value args count differs from table column count in BuildDMLUpdateQuery")
@@ -474,7 +1016,8 @@ func BuildDMLUpdateQuery(databaseName, tableName string, tableColumns, sharedCol
 	if !uniqueKeyColumns.IsSubsetOf(sharedColumns) {
 		return result, sharedArgs, uniqueKeyArgs, fmt.Errorf("unique key columns is not a subset of shared columns in BuildDMLUpdateQuery")
 	}
-	if sharedColumns.Len() == 0 {
+	// Unique key columns may be generated, and yet only written columns are set
+	if sharedColumns, mappedSharedColumns = filterWritableColumns(sharedColumns, mappedSharedColumns); sharedColumns.Len() == 0 {
 		return result, sharedArgs, uniqueKeyArgs, fmt.Errorf("No shared columns found in BuildDMLUpdateQuery")
 	}
 	if uniqueKeyColumns.Len() == 0 {
@@ -495,7 +1038,7 @@ func BuildDMLUpdateQuery(databaseName, tableName string, tableColumns, sharedCol
 		uniqueKeyArgs = append(uniqueKeyArgs, arg)
 	}
 
-	setClause, err := BuildSetPreparedClause(mappedSharedColumns)
+	setClause, sharedArgs, err := buildSetPreparedClauseWithArgs(mappedSharedColumns, sharedArgs)
 	if err != nil {
 		return "", sharedArgs, uniqueKeyArgs, err
 	}
@@ -515,3 +1058,218 @@ func BuildDMLUpdateQuery(databaseName, tableName string, tableColumns, sharedCol
 	)
 	return result, sharedArgs, uniqueKeyArgs, nil
 }
+
+// buildDMLRowMatch builds the comparisons matching a row by all of its writable shared columns, on both the
+// original table and the ghost table, along with the args of each
+func buildDMLRowMatch(tableColumns, sharedColumns, mappedSharedColumns *ColumnList, args []interface{}) (originalComparison string, originalArgs []interface{}, ghostComparison string, ghostArgs []interface{}, err error) {
+	if len(args) != tableColumns.Len() {
+		return "", nil, "", nil, fmt.Errorf("args count differs from table column count in buildDMLRowMatch")
+	}
+	if sharedColumns, mappedSharedColumns = filterWritableColumns(sharedColumns, mappedSharedColumns); sharedColumns.Len() == 0 {
+		return "", nil, "", nil, fmt.Errorf("No shared columns found in buildDMLRowMatch")
+	}
+	for _, column := range sharedColumns.Columns() {
+		tableOrdinal := tableColumns.Ordinals[column.Name]
+		arg := column.convertArg(args[tableOrdinal], true)
+		originalArgs = append(originalArgs, arg)
+		ghostArgs = append(ghostArgs, arg)
+	}
+	sharedColumnNames := sharedColumns.Names()
+	for i := range sharedColumnNames {
+		sharedColumnNames[i] = EscapeName(sharedColumnNames[i])
+	}
+	mappedSharedColumnNames := mappedSharedColumns.Names()
+	for i := range mappedSharedColumnNames {
+		mappedSharedColumnNames[i] = EscapeName(mappedSharedColumnNames[i])
+	}
+	if originalComparison, err = buildRowMatchComparison(sharedColumnNames, buildPreparedValues(sharedColumns.Len())); err != nil {
+		return "", nil, "", nil, err
+	}
+	if ghostComparison, err = buildRowMatchComparison(mappedSharedColumnNames, buildColumnsPreparedValues(mappedSharedColumns)); err != nil {
+		return "", nil, "", nil, err
+	}
+	return originalComparison, originalArgs, ghostComparison, ghostArgs, nil
+}
+
+// BuildDMLRowMatchDeleteQuery deletes a single row, matched by all of its columns, off a table that has no shared
+// unique key (see --surrogate-key). Given originalTableName, the row is only deleted while not found on the original
+// table, such that deleting converges onto the original table's current rows however often it is repeated.
+func BuildDMLRowMatchDeleteQuery(originalDatabaseName, originalTableName, databaseName, tableName string, tableColumns, sharedColumns, mappedSharedColumns *ColumnList, args []interface{}) (result string, explodedArgs []interface{}, err error) {
+	originalComparison, originalArgs, ghostComparison, ghostArgs, err := buildDMLRowMatch(tableColumns, sharedColumns, mappedSharedColumns, args)
+	if err != nil {
+		return result, explodedArgs, err
+	}
+	databaseName = EscapeName(databaseName)
+	tableName = EscapeName(tableName)
+
+	explodedArgs = append(explodedArgs, ghostArgs...)
+	originalRowClause := ""
+	if originalTableName != "" {
+		originalRowClause = fmt.Sprintf(" and not exists (select 1 from %s.%s where %s)", EscapeName(originalDatabaseName), EscapeName(originalTableName), originalComparison)
+		explodedArgs = append(explodedArgs, originalArgs...)
+	}
+	result = fmt.Sprintf(`
+			delete /* gh-ost %s.%s */
+				from
+					%s.%s
+				where
+					%s%s
+				limit 1
+		`, databaseName, tableName,
+		databaseName, tableName,
+		ghostComparison, originalRowClause,
+	)
+	return result, explodedArgs, nil
+}
+
+// BuildDMLRowMatchInsertQuery inserts a row onto a table that has no shared unique key (see --surrogate-key), unless
+// found there already, matching by all of its columns. Given originalTableName, the row is only inserted while found
+// on the original table, such that inserting converges onto the original table's current rows however often it is repeated.
+func BuildDMLRowMatchInsertQuery(originalDatabaseName, originalTableName, databaseName, tableName string, tableColumns, sharedColumns, mappedSharedColumns *ColumnList, args []interface{}) (result string, explodedArgs []interface{}, err error) {
+	originalComparison, originalArgs, ghostComparison, ghostArgs, err := buildDMLRowMatch(tableColumns, sharedColumns, mappedSharedColumns, args)
+	if err != nil {
+		return result, explodedArgs, err
+	}
+	sharedColumns, mappedSharedColumns = filterWritableColumns(sharedColumns, mappedSharedColumns)
+	databaseName = EscapeName(databaseName)
+	tableName = EscapeName(tableName)
+
+	for _, column := range sharedColumns.Columns() {
+		tableOrdinal := tableColumns.Ordinals[column.Name]
+		explodedArgs = append(explodedArgs, column.convertArg(args[tableOrdinal], false))
+	}
+	mappedSharedColumnNames := mappedSharedColumns.Names()
+	for i := range mappedSharedColumnNames {
+		mappedSharedColumnNames[i] = EscapeName(mappedSharedColumnNames[i])
+	}
+	originalRowClause := ""
+	if originalTableName != "" {
+		originalRowClause = fmt.Sprintf("exists (select 1 from %s.%s where %s) and ", EscapeName(originalDatabaseName), EscapeName(originalTableName), originalComparison)
+		explodedArgs = append(explodedArgs, originalArgs...)
+	}
+	explodedArgs = append(explodedArgs, ghostArgs...)
+	result = fmt.Sprintf(`
+			insert /* gh-ost %s.%s */ into
+				%s.%s
+					(%s)
+				select
+					%s
+				from dual
+				where
+					%snot exists (select 1 from %s.%s where %s)
+		`, databaseName, tableName,
+		databaseName, tableName,
+		strings.Join(mappedSharedColumnNames, ", "),
+		strings.Join(buildColumnsPreparedValues(mappedSharedColumns), ", "),
+		originalRowClause, databaseName, tableName, ghostComparison,
+	)
+	return result, explodedArgs, nil
+}
+
+// BuildAddForeignKeysQuery returns an ALTER TABLE statement adding the given foreign keys, under their
+// ghost names, onto the given table. Renamed child columns are mapped via columnRenameMap.
+func BuildAddForeignKeysQuery(databaseName, tableName string, foreignKeys [](*ForeignKey), columnRenameMap map[string]string) (result string, err error) {
+	if len(foreignKeys) == 0 {
+		return result, fmt.Errorf("No foreign keys found in BuildAddForeignKeysQuery")
+	}
+	clauses := []string{}
+	for _, foreignKey := range foreignKeys {
+		if foreignKey.Columns.Len() == 0 || foreignKey.Columns.Len() != foreignKey.ReferencedColumns.Len() {
+			return result, fmt.Errorf("Foreign key %s has mismatching column counts in BuildAddForeignKeysQuery", foreignKey.Name)
+		}
+		ghostName := foreignKey.GhostName()
+		if len(ghostName) > MaxForeignKeyNameLength {
+			return result, fmt.Errorf("Foreign key %s cannot be renamed to %s, which exceeds %d characters", foreignKey.Name, ghostName, MaxForeignKeyNameLength)
+		}
+		columnNames := []string{}
+		for _, column := range foreignKey.Columns.Names() {
+			if mappedName, ok := columnRenameMap[column]; ok {
+				column = mappedName
+			}
+			columnNames = append(columnNames, EscapeName(column))
+		}
+		referencedColumnNames := []string{}
+		for _, column := range foreignKey.ReferencedColumns.Names() {
+			referencedColumnNames = append(referencedColumnNames, EscapeName(column))
+		}
+		clauses = append(clauses, fmt.Sprintf("add constraint %s foreign key (%s) references %s.%s (%s) on update %s on delete %s",
+			EscapeName(ghostName),
+			strings.Join(columnNames, ", "),
+			EscapeName(foreignKey.ReferencedSchema), EscapeName(foreignKey.ReferencedTable),
+			strings.Join(referencedColumnNames, ", "),
+			foreignKey.UpdateRule,
+			foreignKey.DeleteRule,
+		))
+	}
+	result = fmt.Sprintf(`alter /* gh-ost %s.%s */ table %s.%s %s`,
+		EscapeName(databaseName), EscapeName(tableName),
+		EscapeName(databaseName), EscapeName(tableName),
+		strings.Join(clauses, ", "),
+	)
+	return result, nil
+}
+
+// BuildDropForeignKeysQuery returns an ALTER TABLE statement dropping the given constraints off the given table
+func BuildDropForeignKeysQuery(databaseName, tableName string, constraintNames []string) (result string, err error) {
+	if len(constraintNames) == 0 {
+		return result, fmt.Errorf("No foreign keys found in BuildDropForeignKeysQuery")
+	}
+	clauses := []string{}
+	for _, constraintName := range constraintNames {
+		clauses = append(clauses, fmt.Sprintf("drop foreign key %s", EscapeName(constraintName)))
+	}
+	result = fmt.Sprintf(`alter /* gh-ost %s.%s */ table %s.%s %s`,
+		EscapeName(databaseName), EscapeName(tableName),
+		EscapeName(databaseName), EscapeName(tableName),
+		strings.Join(clauses, ", "),
+	)
+	return result, nil
+}
+
+// BuildRenameCheckConstraintsQuery returns an ALTER TABLE statement renaming the given table's CHECK constraints
+// per the given map, by dropping and re-adding each. MySQL has no means to rename a constraint in place.
+func BuildRenameCheckConstraintsQuery(databaseName, tableName string, checkConstraints [](*CheckConstraint), renames map[string]string) (result string, err error) {
+	clauses := []string{}
+	for _, checkConstraint := range checkConstraints {
+		newName, ok := renames[checkConstraint.Name]
+		if !ok {
+			continue
+		}
+		if len(newName) > MaxForeignKeyNameLength {
+			return result, fmt.Errorf("Check constraint %s cannot be renamed to %s, which exceeds %d characters", checkConstraint.Name, newName, MaxForeignKeyNameLength)
+		}
+		enforcement := "enforced"
+		if !checkConstraint.Enforced {
+			enforcement = "not enforced"
+		}
+		clauses = append(clauses,
+			fmt.Sprintf("drop check %s", EscapeName(checkConstraint.Name)),
+			fmt.Sprintf("add constraint %s check (%s) %s", EscapeName(newName), checkConstraint.Clause, enforcement),
+		)
+	}
+	if len(clauses) == 0 {
+		return result, fmt.Errorf("No check constraints found in BuildRenameCheckConstraintsQuery")
+	}
+	result = fmt.Sprintf(`alter /* gh-ost %s.%s */ table %s.%s %s`,
+		EscapeName(databaseName), EscapeName(tableName),
+		EscapeName(databaseName), EscapeName(tableName),
+		strings.Join(clauses, ", "),
+	)
+	return result, nil
+}
+
+// BuildCreateTriggerQuery returns a CREATE TRIGGER statement, creating the given trigger's definition
+// onto the given table under the given name
+func BuildCreateTriggerQuery(databaseName, tableName, triggerName string, trigger *Trigger) string {
+	return fmt.Sprintf(`create /* gh-ost */ trigger %s.%s %s %s on %s.%s for each row %s`,
+		EscapeName(databaseName), EscapeName(triggerName),
+		trigger.Timing, trigger.Event,
+		EscapeName(databaseName), EscapeName(tableName),
+		trigger.Statement,
+	)
+}
+
+// BuildDropTriggerQuery returns a DROP TRIGGER statement
+func BuildDropTriggerQuery(databaseName, triggerName string) string {
+	return fmt.Sprintf(`drop /* gh-ost */ trigger if exists %s.%s`, EscapeName(databaseName), EscapeName(triggerName))
+}
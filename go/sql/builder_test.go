@@ -8,8 +8,11 @@ package sql
 import (
 	"testing"
 
+	"fmt"
 	"reflect"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/outbrain/golib/log"
@@ -90,6 +93,21 @@ func TestBuildSetPreparedClause(t *testing.T) {
 		test.S(t).ExpectNil(err)
 		test.S(t).ExpectEquals(clause, "`c1`=?, `c2`=?")
 	}
+	{
+		columns := NewColumnList([]string{"c1", "c2"})
+		columns.SetConvertDatetimeToTimestamp("c1", "SYSTEM", "+00:00")
+		columns.SetConvertTimestampToDatetime("c2", "SYSTEM", "+00:00")
+		clause, err := BuildSetPreparedClause(columns)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(clause, "`c1`=convert_tz(?, 'SYSTEM', '+00:00'), `c2`=convert_tz(?, '+00:00', 'SYSTEM')")
+	}
+	{
+		columns := NewColumnList([]string{"c1", "location"})
+		columns.SetGeometrySRIDConversion("location", 4326)
+		clause, err := BuildSetPreparedClause(columns)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(clause, "`c1`=?, `location`=st_srid(st_geomfromwkb(substring(?, 5)), 4326)")
+	}
 	{
 		columns := NewColumnList([]string{})
 		_, err := BuildSetPreparedClause(columns)
@@ -159,6 +177,307 @@ func TestBuildRangeComparison(t *testing.T) {
 	}
 }
 
+func TestBuildRangeComparisonNullable(t *testing.T) {
+	columns := []string{"c1", "c2"}
+	values := []string{"?", "?"}
+	{
+		comparison, explodedArgs, err := buildRangeComparison(columns, []bool{false, true}, nil, values, []interface{}{3, nil}, GreaterThanComparisonSign)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(comparison, "((`c1` > ?) or (((`c1` = ?)) AND ((`c2` > ?) or ((`c2` is not null) and (? is null)))))")
+		test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{3, 3, nil, nil}))
+	}
+	{
+		comparison, explodedArgs, err := buildRangeComparison(columns, []bool{true, false}, nil, values, []interface{}{nil, 17}, LessThanOrEqualsComparisonSign)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(comparison, "(((`c1` < ?) or ((`c1` is null) and (? is not null))) or (((`c1` <=> ?)) AND (`c2` < ?)) or ((`c1` <=> ?) and (`c2` = ?)))")
+		test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{nil, nil, nil, 17, nil, 17}))
+	}
+	{
+		// No nullable columns: same as BuildRangeComparison
+		comparison, explodedArgs, err := buildRangeComparison(columns, []bool{false, false}, nil, values, []interface{}{3, 17}, LessThanOrEqualsComparisonSign)
+		test.S(t).ExpectNil(err)
+		expectedComparison, expectedArgs, _ := BuildRangeComparison(columns, values, []interface{}{3, 17}, LessThanOrEqualsComparisonSign)
+		test.S(t).ExpectEquals(comparison, expectedComparison)
+		test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, expectedArgs))
+	}
+}
+
+var comparisonTokenRegexp = regexp.MustCompile("`[^`]+`|<=>|<=|>=|!=|[<>=()?]|[A-Za-z]+|[0-9]+")
+
+// comparisonEvaluator evaluates a comparison, as built by the range comparison builders, over a row, in SQL three
+// valued logic: 1 is true, 0 is false, -1 is unknown. NULL is nil.
+type comparisonEvaluator struct {
+	tokens []string
+	args   []interface{}
+	row    map[string]interface{}
+}
+
+func evalComparison(comparison string, explodedArgs []interface{}, row map[string]interface{}) int {
+	evaluator := &comparisonEvaluator{tokens: comparisonTokenRegexp.FindAllString(comparison, -1), args: explodedArgs, row: row}
+	result := evaluator.expr()
+	if len(evaluator.tokens) > 0 || len(evaluator.args) > 0 {
+		panic(fmt.Sprintf("unexpected trailing tokens %+v or args %+v in %s", evaluator.tokens, evaluator.args, comparison))
+	}
+	return result
+}
+
+func (this *comparisonEvaluator) peek() string {
+	if len(this.tokens) == 0 {
+		return ""
+	}
+	return strings.ToLower(this.tokens[0])
+}
+
+func (this *comparisonEvaluator) next() string {
+	token := this.peek()
+	this.tokens = this.tokens[1:]
+	return token
+}
+
+func (this *comparisonEvaluator) expr() int {
+	result := this.term()
+	for this.peek() == "or" {
+		this.next()
+		right := this.term()
+		switch {
+		case result == 1 || right == 1:
+			result = 1
+		case result == 0 && right == 0:
+			result = 0
+		default:
+			result = -1
+		}
+	}
+	return result
+}
+
+func (this *comparisonEvaluator) term() int {
+	result := this.factor()
+	for this.peek() == "and" {
+		this.next()
+		right := this.factor()
+		switch {
+		case result == 0 || right == 0:
+			result = 0
+		case result == 1 && right == 1:
+			result = 1
+		default:
+			result = -1
+		}
+	}
+	return result
+}
+
+func (this *comparisonEvaluator) factor() int {
+	if this.peek() == "(" {
+		this.next()
+		result := this.expr()
+		if this.next() != ")" {
+			panic("expected )")
+		}
+		return result
+	}
+	left := this.operand()
+	operator := this.next()
+	if operator == "is" {
+		expectNull := true
+		if this.peek() == "not" {
+			this.next()
+			expectNull = false
+		}
+		if this.next() != "null" {
+			panic("expected null")
+		}
+		if (left == nil) == expectNull {
+			return 1
+		}
+		return 0
+	}
+	right := this.operand()
+	if operator == "<=>" {
+		if left == nil || right == nil {
+			if left == nil && right == nil {
+				return 1
+			}
+			return 0
+		}
+		operator = "="
+	}
+	if left == nil || right == nil {
+		return -1
+	}
+	l, r := left.(int), right.(int)
+	var result bool
+	switch operator {
+	case "<":
+		result = l < r
+	case ">":
+		result = l > r
+	case "=":
+		result = l == r
+	default:
+		panic(fmt.Sprintf("unexpected operator %s", operator))
+	}
+	if result {
+		return 1
+	}
+	return 0
+}
+
+func (this *comparisonEvaluator) operand() interface{} {
+	token := this.next()
+	switch {
+	case token == "?":
+		arg := this.args[0]
+		this.args = this.args[1:]
+		return arg
+	case strings.HasPrefix(token, "`"):
+		return this.row[strings.Trim(token, "`")]
+	}
+	value, err := strconv.Atoi(token)
+	if err != nil {
+		panic(fmt.Sprintf("unexpected token %s", token))
+	}
+	return value
+}
+
+// compareIndexOrder compares unique key tuples in ascending index order, where NULL is the least value.
+// Columns flagged in descending (which may be nil) are DESC key parts, sorting greater values first.
+func compareIndexOrder(a, b []interface{}, descending []bool) int {
+	for i := range a {
+		c := 0
+		switch {
+		case a[i] == nil && b[i] == nil:
+			continue
+		case a[i] == nil:
+			c = -1
+		case b[i] == nil:
+			c = 1
+		case a[i].(int) < b[i].(int):
+			c = -1
+		case a[i].(int) > b[i].(int):
+			c = 1
+		}
+		if i < len(descending) && descending[i] {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// directionPatterns returns all ASC/DESC key part combinations of given length, as descending flags
+func directionPatterns(length int) (patterns [][]bool) {
+	if length == 0 {
+		return [][]bool{{}}
+	}
+	for _, pattern := range directionPatterns(length - 1) {
+		for _, descending := range []bool{false, true} {
+			patterns = append(patterns, append(append([]bool{}, pattern...), descending))
+		}
+	}
+	return patterns
+}
+
+// nullableTuples returns all tuples of given length over NULL, 1 and 2
+func nullableTuples(length int) (tuples [][]interface{}) {
+	if length == 0 {
+		return [][]interface{}{{}}
+	}
+	for _, tuple := range nullableTuples(length - 1) {
+		for _, value := range []interface{}{nil, 1, 2} {
+			tuples = append(tuples, append(append([]interface{}{}, tuple...), value))
+		}
+	}
+	return tuples
+}
+
+func TestBuildRangeComparisonExhaustive(t *testing.T) {
+	for _, length := range []int{2, 3} {
+		columns := make([]string, length)
+		nullable := make([]bool, length)
+		values := make([]string, length)
+		for i := range columns {
+			columns[i] = fmt.Sprintf("c%d", i+1)
+			nullable[i] = true
+			values[i] = "?"
+		}
+		for _, descending := range directionPatterns(length) {
+			tuples := nullableTuples(length)
+			toRow := func(tuple []interface{}) map[string]interface{} {
+				row := map[string]interface{}{}
+				for i, column := range columns {
+					row[column] = tuple[i]
+				}
+				return row
+			}
+			signs := map[ValueComparisonSign]func(int) bool{
+				LessThanComparisonSign:            func(c int) bool { return c < 0 },
+				LessThanOrEqualsComparisonSign:    func(c int) bool { return c <= 0 },
+				GreaterThanComparisonSign:         func(c int) bool { return c > 0 },
+				GreaterThanOrEqualsComparisonSign: func(c int) bool { return c >= 0 },
+			}
+			for sign, expectMatch := range signs {
+				for _, bound := range tuples {
+					comparison, explodedArgs, err := buildRangeComparison(columns, nullable, descending, values, bound, sign)
+					test.S(t).ExpectNil(err)
+					for _, tuple := range tuples {
+						matches := evalComparison(comparison, explodedArgs, toRow(tuple)) == 1
+						if matches != expectMatch(compareIndexOrder(tuple, bound, descending)) {
+							t.Errorf("%+v %s %+v (descending: %+v): expected %t, got %t; comparison: %s", tuple, sign, bound, descending, !matches, matches, comparison)
+						}
+					}
+				}
+			}
+
+			// Row copy over all tuples, in chunks, copies each row exactly once, in either copy order and whatever
+			// the key part directions
+			for _, copyOrder := range []CopyOrder{AscendingCopyOrder, DescendingCopyOrder} {
+				uniqueKeyColumns := NewColumnList(columns)
+				for i, column := range columns {
+					uniqueKeyColumns.GetColumn(column).Nullable = true
+					uniqueKeyColumns.GetColumn(column).Descending = descending[i]
+				}
+				sorted := append([][]interface{}{}, tuples...)
+				sort.Slice(sorted, func(i, j int) bool {
+					if copyOrder == DescendingCopyOrder {
+						return compareIndexOrder(sorted[i], sorted[j], descending) > 0
+					}
+					return compareIndexOrder(sorted[i], sorted[j], descending) < 0
+				})
+				copied := make([]int, len(sorted))
+				chunkSize := 4
+				for start := 0; start < len(sorted); start += chunkSize {
+					end := start + chunkSize - 1
+					if end >= len(sorted) {
+						end = len(sorted) - 1
+					}
+					rangeStart := sorted[0]
+					if start > 0 {
+						rangeStart = sorted[start-1]
+					}
+					startComparison, endComparison, explodedArgs, err := buildRangePreparedComparisons(uniqueKeyColumns, rangeStart, sorted[end], start == 0, copyOrder)
+					test.S(t).ExpectNil(err)
+					startArgsCount := strings.Count(startComparison, "?")
+					for i, tuple := range sorted {
+						row := toRow(tuple)
+						if evalComparison(startComparison, explodedArgs[:startArgsCount], row) == 1 && evalComparison(endComparison, explodedArgs[startArgsCount:], row) == 1 {
+							copied[i]++
+						}
+					}
+				}
+				for i, count := range copied {
+					if count != 1 {
+						t.Errorf("%s copy order (descending: %+v): row %+v copied %d times", copyOrder, descending, sorted[i], count)
+					}
+				}
+			}
+		}
+	}
+}
+
 func TestBuildRangeInsertQuery(t *testing.T) {
 	databaseName := "mydb"
 	originalTableName := "tbl"
@@ -172,7 +491,7 @@ func TestBuildRangeInsertQuery(t *testing.T) {
 		rangeStartArgs := []interface{}{3}
 		rangeEndArgs := []interface{}{103}
 
-		query, explodedArgs, err := BuildRangeInsertQuery(databaseName, originalTableName, ghostTableName, sharedColumns, sharedColumns, uniqueKey, uniqueKeyColumns, rangeStartValues, rangeEndValues, rangeStartArgs, rangeEndArgs, true, false)
+		query, explodedArgs, err := BuildRangeInsertQuery(databaseName, originalTableName, "", databaseName, ghostTableName, sharedColumns, NewColumnList(sharedColumns), uniqueKey, uniqueKeyColumns, rangeStartValues, rangeEndValues, rangeStartArgs, rangeEndArgs, true, AscendingCopyOrder, false, IgnoreCopyConflictStrategy, "")
 		test.S(t).ExpectNil(err)
 		expected := `
 				insert /* gh-ost mydb.tbl */ ignore into mydb.ghost (id, name, position)
@@ -191,7 +510,7 @@ func TestBuildRangeInsertQuery(t *testing.T) {
 		rangeStartArgs := []interface{}{3, 17}
 		rangeEndArgs := []interface{}{103, 117}
 
-		query, explodedArgs, err := BuildRangeInsertQuery(databaseName, originalTableName, ghostTableName, sharedColumns, sharedColumns, uniqueKey, uniqueKeyColumns, rangeStartValues, rangeEndValues, rangeStartArgs, rangeEndArgs, true, false)
+		query, explodedArgs, err := BuildRangeInsertQuery(databaseName, originalTableName, "", databaseName, ghostTableName, sharedColumns, NewColumnList(sharedColumns), uniqueKey, uniqueKeyColumns, rangeStartValues, rangeEndValues, rangeStartArgs, rangeEndArgs, true, AscendingCopyOrder, false, IgnoreCopyConflictStrategy, "")
 		test.S(t).ExpectNil(err)
 		expected := `
 				insert /* gh-ost mydb.tbl */ ignore into mydb.ghost (id, name, position)
@@ -209,7 +528,7 @@ func TestBuildRangeInsertQueryRenameMap(t *testing.T) {
 	originalTableName := "tbl"
 	ghostTableName := "ghost"
 	sharedColumns := []string{"id", "name", "position"}
-	mappedSharedColumns := []string{"id", "name", "location"}
+	mappedSharedColumns := NewColumnList([]string{"id", "name", "location"})
 	{
 		uniqueKey := "PRIMARY"
 		uniqueKeyColumns := NewColumnList([]string{"id"})
@@ -218,7 +537,7 @@ func TestBuildRangeInsertQueryRenameMap(t *testing.T) {
 		rangeStartArgs := []interface{}{3}
 		rangeEndArgs := []interface{}{103}
 
-		query, explodedArgs, err := BuildRangeInsertQuery(databaseName, originalTableName, ghostTableName, sharedColumns, mappedSharedColumns, uniqueKey, uniqueKeyColumns, rangeStartValues, rangeEndValues, rangeStartArgs, rangeEndArgs, true, false)
+		query, explodedArgs, err := BuildRangeInsertQuery(databaseName, originalTableName, "", databaseName, ghostTableName, sharedColumns, mappedSharedColumns, uniqueKey, uniqueKeyColumns, rangeStartValues, rangeEndValues, rangeStartArgs, rangeEndArgs, true, AscendingCopyOrder, false, IgnoreCopyConflictStrategy, "")
 		test.S(t).ExpectNil(err)
 		expected := `
 				insert /* gh-ost mydb.tbl */ ignore into mydb.ghost (id, name, location)
@@ -237,7 +556,7 @@ func TestBuildRangeInsertQueryRenameMap(t *testing.T) {
 		rangeStartArgs := []interface{}{3, 17}
 		rangeEndArgs := []interface{}{103, 117}
 
-		query, explodedArgs, err := BuildRangeInsertQuery(databaseName, originalTableName, ghostTableName, sharedColumns, mappedSharedColumns, uniqueKey, uniqueKeyColumns, rangeStartValues, rangeEndValues, rangeStartArgs, rangeEndArgs, true, false)
+		query, explodedArgs, err := BuildRangeInsertQuery(databaseName, originalTableName, "", databaseName, ghostTableName, sharedColumns, mappedSharedColumns, uniqueKey, uniqueKeyColumns, rangeStartValues, rangeEndValues, rangeStartArgs, rangeEndArgs, true, AscendingCopyOrder, false, IgnoreCopyConflictStrategy, "")
 		test.S(t).ExpectNil(err)
 		expected := `
 				insert /* gh-ost mydb.tbl */ ignore into mydb.ghost (id, name, location)
@@ -250,6 +569,92 @@ func TestBuildRangeInsertQueryRenameMap(t *testing.T) {
 	}
 }
 
+func TestBuildRangeInsertQueryTimezoneConversion(t *testing.T) {
+	databaseName := "mydb"
+	originalTableName := "tbl"
+	ghostTableName := "ghost"
+	sharedColumns := []string{"id", "created_at", "updated_at"}
+	mappedSharedColumns := NewColumnList(sharedColumns)
+	mappedSharedColumns.SetConvertDatetimeToTimestamp("created_at", "Europe/Berlin", "+00:00")
+	mappedSharedColumns.SetConvertTimestampToDatetime("updated_at", "Europe/Berlin", "+00:00")
+	uniqueKeyColumns := NewColumnList([]string{"id"})
+
+	query, explodedArgs, err := BuildRangeInsertPreparedQuery(databaseName, originalTableName, "", databaseName, ghostTableName, sharedColumns, mappedSharedColumns, "PRIMARY", uniqueKeyColumns, []interface{}{3}, []interface{}{103}, true, AscendingCopyOrder, false, IgnoreCopyConflictStrategy, "")
+	test.S(t).ExpectNil(err)
+	expected := `
+			insert /* gh-ost mydb.tbl */ ignore into mydb.ghost (id, created_at, updated_at)
+			(select id, convert_tz(created_at, 'Europe/Berlin', '+00:00'), convert_tz(updated_at, '+00:00', 'Europe/Berlin') from mydb.tbl force index (PRIMARY)
+				where (((id > ?) or ((id = ?))) and ((id < ?) or ((id = ?))))
+			)
+	`
+	test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+	test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{3, 3, 103, 103}))
+
+	_, _, err = BuildRangeInsertPreparedQuery(databaseName, originalTableName, "", databaseName, ghostTableName, sharedColumns, NewColumnList([]string{"id"}), "PRIMARY", uniqueKeyColumns, []interface{}{3}, []interface{}{103}, true, AscendingCopyOrder, false, IgnoreCopyConflictStrategy, "")
+	test.S(t).ExpectNotNil(err)
+}
+
+func TestBuildRangeInsertQueryGeometrySRIDConversion(t *testing.T) {
+	sharedColumns := []string{"id", "location"}
+	mappedSharedColumns := NewColumnList(sharedColumns)
+	mappedSharedColumns.SetGeometrySRIDConversion("location", 4326)
+	uniqueKeyColumns := NewColumnList([]string{"id"})
+
+	query, explodedArgs, err := BuildRangeInsertPreparedQuery("mydb", "tbl", "", "mydb", "ghost", sharedColumns, mappedSharedColumns, "PRIMARY", uniqueKeyColumns, []interface{}{3}, []interface{}{103}, true, AscendingCopyOrder, false, IgnoreCopyConflictStrategy, "")
+	test.S(t).ExpectNil(err)
+	expected := `
+			insert /* gh-ost mydb.tbl */ ignore into mydb.ghost (id, location)
+			(select id, st_srid(location, 4326) from mydb.tbl force index (PRIMARY)
+				where (((id > ?) or ((id = ?))) and ((id < ?) or ((id = ?))))
+			)
+	`
+	test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+	test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{3, 3, 103, 103}))
+}
+
+func TestBuildRangeInsertQueryZeroDateRewrite(t *testing.T) {
+	sharedColumns := []string{"id", "d", "ts"}
+	mappedSharedColumns := NewColumnList(sharedColumns)
+	rewrite, _ := ParseZeroDateRewrite("NULL")
+	mappedSharedColumns.SetZeroDateRewrite("d", rewrite)
+	mappedSharedColumns.SetZeroDateRewrite("ts", rewrite)
+	mappedSharedColumns.SetConvertDatetimeToTimestamp("ts", "+02:00", "+00:00")
+	uniqueKeyColumns := NewColumnList([]string{"id"})
+
+	query, _, err := BuildRangeInsertPreparedQuery("mydb", "tbl", "", "mydb", "ghost", sharedColumns, mappedSharedColumns, "PRIMARY", uniqueKeyColumns, []interface{}{3}, []interface{}{103}, true, AscendingCopyOrder, false, IgnoreCopyConflictStrategy, "")
+	test.S(t).ExpectNil(err)
+	expected := `
+			insert /* gh-ost mydb.tbl */ ignore into mydb.ghost (id, d, ts)
+			(select id, if(month(d) = 0 or dayofmonth(d) = 0, NULL, d), convert_tz(if(month(ts) = 0 or dayofmonth(ts) = 0, NULL, ts), '+02:00', '+00:00') from mydb.tbl force index (PRIMARY)
+				where (((id > ?) or ((id = ?))) and ((id < ?) or ((id = ?))))
+			)
+	`
+	test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+}
+
+func TestBuildRangeInsertQueryGeneratedColumns(t *testing.T) {
+	sharedColumns := []string{"id", "a", "sum_ab", "b"}
+	mappedSharedColumns := NewColumnList(sharedColumns)
+	mappedSharedColumns.SetGenerated("sum_ab", StoredGeneratedColumn)
+	uniqueKeyColumns := NewColumnList([]string{"id", "sum_ab"})
+
+	query, explodedArgs, err := BuildRangeInsertQuery("mydb", "tbl", "", "mydb", "ghost", sharedColumns, mappedSharedColumns, "id_sum_uidx", uniqueKeyColumns, []string{"@v1s", "@v2s"}, []string{"@v1e", "@v2e"}, []interface{}{3, 5}, []interface{}{103, 105}, true, AscendingCopyOrder, false, IgnoreCopyConflictStrategy, "")
+	test.S(t).ExpectNil(err)
+	expected := `
+		insert /* gh-ost mydb.tbl */ ignore into mydb.ghost (id, a, b)
+		(select id, a, b from mydb.tbl force index (id_sum_uidx)
+			where (((id > @v1s) or (((id = @v1s)) AND (sum_ab > @v2s)) or ((id = @v1s) and (sum_ab = @v2s))) and ((id < @v1e) or (((id = @v1e)) AND (sum_ab < @v2e)) or ((id = @v1e) and (sum_ab = @v2e))))
+		)
+	`
+	test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+	test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{3, 3, 5, 3, 5, 103, 103, 105, 103, 105}))
+
+	mappedSharedColumns = NewColumnList([]string{"sum_ab"})
+	mappedSharedColumns.SetGenerated("sum_ab", VirtualGeneratedColumn)
+	_, _, err = BuildRangeInsertQuery("mydb", "tbl", "", "mydb", "ghost", []string{"sum_ab"}, mappedSharedColumns, "id_sum_uidx", uniqueKeyColumns, []string{"@v1s", "@v2s"}, []string{"@v1e", "@v2e"}, []interface{}{3, 5}, []interface{}{103, 105}, true, AscendingCopyOrder, false, IgnoreCopyConflictStrategy, "")
+	test.S(t).ExpectNotNil(err)
+}
+
 func TestBuildRangeInsertPreparedQuery(t *testing.T) {
 	databaseName := "mydb"
 	originalTableName := "tbl"
@@ -261,7 +666,7 @@ func TestBuildRangeInsertPreparedQuery(t *testing.T) {
 		rangeStartArgs := []interface{}{3, 17}
 		rangeEndArgs := []interface{}{103, 117}
 
-		query, explodedArgs, err := BuildRangeInsertPreparedQuery(databaseName, originalTableName, ghostTableName, sharedColumns, sharedColumns, uniqueKey, uniqueKeyColumns, rangeStartArgs, rangeEndArgs, true, true)
+		query, explodedArgs, err := BuildRangeInsertPreparedQuery(databaseName, originalTableName, "", databaseName, ghostTableName, sharedColumns, NewColumnList(sharedColumns), uniqueKey, uniqueKeyColumns, rangeStartArgs, rangeEndArgs, true, AscendingCopyOrder, true, IgnoreCopyConflictStrategy, "")
 		test.S(t).ExpectNil(err)
 		expected := `
 				insert /* gh-ost mydb.tbl */ ignore into mydb.ghost (id, name, position)
@@ -272,6 +677,268 @@ func TestBuildRangeInsertPreparedQuery(t *testing.T) {
 		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
 		test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{3, 3, 17, 3, 17, 103, 103, 117, 103, 117}))
 	}
+	{
+		uniqueKeyColumns := NewColumnList([]string{"id"})
+		query, explodedArgs, err := BuildRangeInsertPreparedQuery(databaseName, originalTableName, "p2023", databaseName, ghostTableName, sharedColumns, NewColumnList(sharedColumns), "PRIMARY", uniqueKeyColumns, []interface{}{3}, []interface{}{103}, true, AscendingCopyOrder, true, IgnoreCopyConflictStrategy, "")
+		test.S(t).ExpectNil(err)
+		expected := `
+				insert /* gh-ost mydb.tbl */ ignore into mydb.ghost (id, name, position)
+				(select id, name, position from mydb.tbl partition (p2023) force index (PRIMARY)
+				  where (((id > ?) or ((id = ?))) and ((id < ?) or ((id = ?))))
+				lock in share mode )
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+		test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{3, 3, 103, 103}))
+	}
+	{
+		uniqueKeyColumns := NewColumnList([]string{"name", "position"})
+		query, explodedArgs, err := BuildRangeInsertPreparedQuery(databaseName, originalTableName, "", databaseName, ghostTableName, sharedColumns, NewColumnList(sharedColumns), "name_position_uidx", uniqueKeyColumns, []interface{}{103, 117}, []interface{}{3, 17}, false, DescendingCopyOrder, true, IgnoreCopyConflictStrategy, "")
+		test.S(t).ExpectNil(err)
+		expected := `
+				insert /* gh-ost mydb.tbl */ ignore into mydb.ghost (id, name, position)
+				(select id, name, position from mydb.tbl force index (name_position_uidx)
+				  where (((name < ?) or (((name = ?)) AND (position < ?))) and ((name > ?) or (((name = ?)) AND (position > ?)) or ((name = ?) and (position = ?))))
+				lock in share mode )
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+		test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{103, 103, 117, 3, 3, 17, 3, 17}))
+	}
+}
+
+func TestBuildRangeInsertQueryConflictStrategy(t *testing.T) {
+	sharedColumns := []string{"id", "name"}
+	uniqueKeyColumns := NewColumnList([]string{"id"})
+	{
+		query, _, err := BuildRangeInsertPreparedQuery("mydb", "tbl", "", "mydb", "ghost", sharedColumns, NewColumnList(sharedColumns), "PRIMARY", uniqueKeyColumns, []interface{}{3}, []interface{}{103}, true, AscendingCopyOrder, false, ReplaceCopyConflictStrategy, "")
+		test.S(t).ExpectNil(err)
+		expected := `
+				replace /* gh-ost mydb.tbl */ into mydb.ghost (id, name)
+				(select id, name from mydb.tbl force index (PRIMARY)
+				  where (((id > ?) or ((id = ?))) and ((id < ?) or ((id = ?))))
+				)
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+	}
+	{
+		query, _, err := BuildRangeInsertPreparedQuery("mydb", "tbl", "", "mydb", "ghost", sharedColumns, NewColumnList(sharedColumns), "PRIMARY", uniqueKeyColumns, []interface{}{3}, []interface{}{103}, true, AscendingCopyOrder, false, FailCopyConflictStrategy, "")
+		test.S(t).ExpectNil(err)
+		expected := `
+				insert /* gh-ost mydb.tbl */ into mydb.ghost (id, name)
+				(select id, name from mydb.tbl force index (PRIMARY)
+				  where (((id > ?) or ((id = ?))) and ((id < ?) or ((id = ?))))
+				)
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+	}
+	{
+		query, _, err := BuildRangeInsertPreparedQuery("mydb", "tbl", "", "mydb", "ghost", sharedColumns, NewColumnList([]string{"id", "title"}), "", uniqueKeyColumns, []interface{}{3}, []interface{}{103}, true, AscendingCopyOrder, false, SkipExistingCopyConflictStrategy, "")
+		test.S(t).ExpectNil(err)
+		expected := `
+				insert /* gh-ost mydb.tbl */ into mydb.ghost (id, title)
+				(select id, name from mydb.tbl
+				  where (((id > ?) or ((id = ?))) and ((id < ?) or ((id = ?))))
+				    and not exists (select 1 from mydb.ghost where (id <=> mydb.tbl.id) and (title <=> mydb.tbl.name))
+				)
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+	}
+	{
+		_, _, err := BuildRangeInsertPreparedQuery("mydb", "tbl", "", "mydb", "ghost", sharedColumns, NewColumnList(sharedColumns), "PRIMARY", uniqueKeyColumns, []interface{}{3}, []interface{}{103}, true, AscendingCopyOrder, false, "overwrite", "")
+		test.S(t).ExpectNotNil(err)
+	}
+}
+
+func TestBuildRangeInsertQueryRowFilter(t *testing.T) {
+	sharedColumns := []string{"id", "name", "created_at"}
+	uniqueKeyColumns := NewColumnList([]string{"id"})
+	query, explodedArgs, err := BuildRangeInsertPreparedQuery("mydb", "tbl", "", "mydb", "ghost", sharedColumns, NewColumnList(sharedColumns), "PRIMARY", uniqueKeyColumns, []interface{}{3}, []interface{}{103}, true, AscendingCopyOrder, true, IgnoreCopyConflictStrategy, "created_at >= '2023-01-01'")
+	test.S(t).ExpectNil(err)
+	expected := `
+			insert /* gh-ost mydb.tbl */ ignore into mydb.ghost (id, name, created_at)
+			(select id, name, created_at from mydb.tbl force index (PRIMARY)
+			  where (((id > ?) or ((id = ?))) and ((id < ?) or ((id = ?))))
+			    and (created_at >= '2023-01-01')
+			  lock in share mode
+			)
+	`
+	test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+	test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{3, 3, 103, 103}))
+}
+
+func TestBuildRangeInsertQueryTargetDatabase(t *testing.T) {
+	sharedColumns := []string{"id", "name"}
+	uniqueKeyColumns := NewColumnList([]string{"id"})
+	{
+		query, _, err := BuildRangeInsertPreparedQuery("mydb", "tbl", "", "targetdb", "ghost", sharedColumns, NewColumnList(sharedColumns), "PRIMARY", uniqueKeyColumns, []interface{}{3}, []interface{}{103}, true, AscendingCopyOrder, false, IgnoreCopyConflictStrategy, "")
+		test.S(t).ExpectNil(err)
+		expected := `
+			insert /* gh-ost mydb.tbl */ ignore into targetdb.ghost (id, name)
+			(select id, name from mydb.tbl force index (PRIMARY)
+			  where (((id > ?) or ((id = ?))) and ((id < ?) or ((id = ?))))
+			)
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+	}
+	{
+		query, _, err := BuildRangeInsertPreparedQuery("mydb", "tbl", "", "targetdb", "ghost", sharedColumns, NewColumnList(sharedColumns), "", uniqueKeyColumns, []interface{}{3}, []interface{}{103}, true, AscendingCopyOrder, false, SkipExistingCopyConflictStrategy, "")
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectTrue(strings.Contains(normalizeQuery(query), "not exists (select 1 from targetdb.ghost where"))
+	}
+}
+
+func TestBuildRangeCountPreparedQuery(t *testing.T) {
+	uniqueKeyColumns := NewColumnList([]string{"name", "position"})
+	query, explodedArgs, err := BuildRangeCountPreparedQuery("mydb", "tbl", "p1", "name_position_uidx", uniqueKeyColumns, []interface{}{3, 17}, []interface{}{103, 117}, false, AscendingCopyOrder, true, "")
+	test.S(t).ExpectNil(err)
+	expected := `
+			select /* gh-ost mydb.tbl */ count(*)
+			  from mydb.tbl partition (p1) force index (name_position_uidx)
+			  where (((name > ?) or (((name = ?)) AND (position > ?))) and ((name < ?) or (((name = ?)) AND (position < ?)) or ((name = ?) and (position = ?))))
+			lock in share mode
+	`
+	test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+	test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{3, 3, 17, 103, 103, 117, 103, 117}))
+
+	query, _, err = BuildRangeCountPreparedQuery("mydb", "tbl", "", "PRIMARY", NewColumnList([]string{"id"}), []interface{}{3}, []interface{}{103}, true, AscendingCopyOrder, false, "status = 'active'")
+	test.S(t).ExpectNil(err)
+	expected = `
+			select /* gh-ost mydb.tbl */ count(*)
+			  from mydb.tbl force index (PRIMARY)
+			  where (((id > ?) or ((id = ?))) and ((id < ?) or ((id = ?)))) and (status = 'active')
+	`
+	test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+
+	query, explodedArgs, err = BuildRangeCountPreparedQuery("mydb", "tbl", "", "name_position_uidx", uniqueKeyColumns, []interface{}{103, 117}, []interface{}{3, 17}, false, DescendingCopyOrder, false, "")
+	test.S(t).ExpectNil(err)
+	expected = `
+			select /* gh-ost mydb.tbl */ count(*)
+			  from mydb.tbl force index (name_position_uidx)
+			  where (((name < ?) or (((name = ?)) AND (position < ?))) and ((name > ?) or (((name = ?)) AND (position > ?)) or ((name = ?) and (position = ?))))
+	`
+	test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+	test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{103, 103, 117, 3, 3, 17, 3, 17}))
+}
+
+func TestBuildRangeCheckConstraintsViolationPreparedQuery(t *testing.T) {
+	checkConstraints := [](*CheckConstraint){
+		{Name: "positive_amount", Clause: "(`amount` > 0)", Enforced: true},
+		{Name: "tbl_chk_1", Clause: "(`discount` <= `amount`)", Enforced: true},
+	}
+	query, explodedArgs, err := BuildRangeCheckConstraintsViolationPreparedQuery("mydb", "tbl", "", "PRIMARY", NewColumnList([]string{"id"}), []interface{}{3}, []interface{}{103}, true, AscendingCopyOrder, true, "", checkConstraints)
+	test.S(t).ExpectNil(err)
+	expected := `
+			select /* gh-ost mydb.tbl */ id, ((amount > 0)) is false, ((discount <= amount)) is false
+			  from mydb.tbl force index (PRIMARY)
+			  where (((id > ?) or ((id = ?))) and ((id < ?) or ((id = ?)))) and (((amount > 0)) is false or ((discount <= amount)) is false)
+			  limit 1 lock in share mode
+	`
+	test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+	test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{3, 3, 103, 103}))
+
+	query, _, err = BuildRangeCheckConstraintsViolationPreparedQuery("mydb", "tbl", "p1", "PRIMARY", NewColumnList([]string{"id"}), []interface{}{3}, []interface{}{103}, false, AscendingCopyOrder, false, "status = 'active'", checkConstraints[:1])
+	test.S(t).ExpectNil(err)
+	expected = `
+			select /* gh-ost mydb.tbl */ id, ((amount > 0)) is false
+			  from mydb.tbl partition (p1) force index (PRIMARY)
+			  where (((id > ?)) and ((id < ?) or ((id = ?)))) and (status = 'active') and (((amount > 0)) is false)
+			  limit 1
+	`
+	test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+
+	_, _, err = BuildRangeCheckConstraintsViolationPreparedQuery("mydb", "tbl", "", "PRIMARY", NewColumnList([]string{"id"}), []interface{}{3}, []interface{}{103}, true, AscendingCopyOrder, false, "", nil)
+	test.S(t).ExpectNotNil(err)
+}
+
+func TestBuildChecksumColumnExpressions(t *testing.T) {
+	{
+		sharedColumns := NewColumnList([]string{"id", "name", "score"})
+		mappedSharedColumns := NewColumnList([]string{"id", "name", "score"})
+		for _, columns := range []*ColumnList{sharedColumns, mappedSharedColumns} {
+			columns.GetColumn("id").MySQLType = "int(11)"
+			columns.GetColumn("name").MySQLType = "varchar(32)"
+			columns.GetColumn("name").Charset = "utf8mb4"
+			columns.GetColumn("score").MySQLType = "double"
+		}
+		originalExpressions, ghostExpressions, err := BuildChecksumColumnExpressions(sharedColumns, mappedSharedColumns)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectTrue(reflect.DeepEqual(originalExpressions, []string{"`id`", "`name`"}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(ghostExpressions, []string{"`id`", "`name`"}))
+	}
+	{
+		sharedColumns := NewColumnList([]string{"id", "name", "price", "created_at", "ts", "doc", "data"})
+		sharedColumns.GetColumn("id").MySQLType = "int(11)"
+		sharedColumns.GetColumn("name").MySQLType = "varchar(32)"
+		sharedColumns.GetColumn("name").Charset = "latin1"
+		sharedColumns.GetColumn("price").MySQLType = "decimal(10,2)"
+		sharedColumns.GetColumn("created_at").MySQLType = "datetime"
+		sharedColumns.GetColumn("ts").MySQLType = "timestamp"
+		sharedColumns.GetColumn("doc").MySQLType = "text"
+		sharedColumns.GetColumn("doc").Charset = "utf8mb4"
+		sharedColumns.GetColumn("data").MySQLType = "varchar(32)"
+		sharedColumns.GetColumn("data").Charset = "utf8mb4"
+		mappedSharedColumns := NewColumnList([]string{"id", "title", "price", "created_at", "ts", "doc", "data"})
+		mappedSharedColumns.GetColumn("id").MySQLType = "bigint(20) unsigned"
+		mappedSharedColumns.GetColumn("id").IsUnsigned = true
+		mappedSharedColumns.GetColumn("title").MySQLType = "varchar(64)"
+		mappedSharedColumns.GetColumn("title").Charset = "utf8mb4"
+		mappedSharedColumns.GetColumn("price").MySQLType = "decimal(12,4)"
+		mappedSharedColumns.GetColumn("created_at").MySQLType = "timestamp(3)"
+		mappedSharedColumns.SetConvertDatetimeToTimestamp("created_at", "+02:00", "+00:00")
+		mappedSharedColumns.GetColumn("ts").MySQLType = "datetime"
+		mappedSharedColumns.SetConvertTimestampToDatetime("ts", "+02:00", "+00:00")
+		mappedSharedColumns.GetColumn("doc").MySQLType = "json"
+		mappedSharedColumns.GetColumn("data").MySQLType = "varbinary(32)"
+		mappedSharedColumns.GetColumn("data").Charset = "binary"
+		originalExpressions, ghostExpressions, err := BuildChecksumColumnExpressions(sharedColumns, mappedSharedColumns)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectTrue(reflect.DeepEqual(originalExpressions, []string{
+			"cast(`id` as unsigned)",
+			"cast(`name` as char character set utf8mb4)",
+			"cast(`price` as decimal(12,4))",
+			"cast(convert_tz(`created_at`, '+02:00', '+00:00') as datetime(3))",
+			"cast(convert_tz(`ts`, '+00:00', '+02:00') as datetime)",
+			"cast(`doc` as json)",
+			"cast(`data` as binary)",
+		}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(ghostExpressions, []string{"`id`", "`title`", "`price`", "`created_at`", "`ts`", "`doc`", "`data`"}))
+	}
+	{
+		sharedColumns := NewColumnList([]string{"id", "name"})
+		mappedSharedColumns := NewColumnList([]string{"id"})
+		_, _, err := BuildChecksumColumnExpressions(sharedColumns, mappedSharedColumns)
+		test.S(t).ExpectNotNil(err)
+	}
+}
+
+func TestBuildRangeChecksumPreparedQuery(t *testing.T) {
+	uniqueKeyColumns := NewColumnList([]string{"name", "position"})
+	checksumExpressions := []string{"`name`", "`position`", "cast(`price` as decimal(12,4))"}
+	{
+		query, explodedArgs, err := BuildRangeChecksumPreparedQuery("mydb", "tbl", "p1", "name_position_uidx", uniqueKeyColumns, checksumExpressions, []interface{}{3, 17}, []interface{}{103, 117}, false, AscendingCopyOrder, true, "")
+		test.S(t).ExpectNil(err)
+		expected := `
+			select /* gh-ost mydb.tbl */ count(*), coalesce(bit_xor(crc32(concat_ws('#', ifnull(crc32(name), 'NULL'), ifnull(crc32(position), 'NULL'), ifnull(crc32(cast(price as decimal(12,4))), 'NULL')))), 0)
+			  from mydb.tbl partition (p1) force index (name_position_uidx)
+			  where (((name > ?) or (((name = ?)) AND (position > ?))) and ((name < ?) or (((name = ?)) AND (position < ?)) or ((name = ?) and (position = ?))))
+			lock in share mode
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+		test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{3, 3, 17, 103, 103, 117, 103, 117}))
+	}
+	{
+		query, _, err := BuildRangeChecksumPreparedQuery("mydb", "_tbl_gho", "", "", uniqueKeyColumns, checksumExpressions[:2], []interface{}{3, 17}, []interface{}{103, 117}, true, AscendingCopyOrder, false, "")
+		test.S(t).ExpectNil(err)
+		expected := `
+			select /* gh-ost mydb._tbl_gho */ count(*), coalesce(bit_xor(crc32(concat_ws('#', ifnull(crc32(name), 'NULL'), ifnull(crc32(position), 'NULL')))), 0)
+			  from mydb._tbl_gho
+			  where (((name > ?) or (((name = ?)) AND (position > ?)) or ((name = ?) and (position = ?))) and ((name < ?) or (((name = ?)) AND (position < ?)) or ((name = ?) and (position = ?))))
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+	}
+	{
+		_, _, err := BuildRangeChecksumPreparedQuery("mydb", "tbl", "", "", uniqueKeyColumns, nil, []interface{}{3, 17}, []interface{}{103, 117}, false, AscendingCopyOrder, false, "")
+		test.S(t).ExpectNotNil(err)
+	}
 }
 
 func TestBuildUniqueKeyRangeEndPreparedQuery(t *testing.T) {
@@ -283,7 +950,7 @@ func TestBuildUniqueKeyRangeEndPreparedQuery(t *testing.T) {
 		rangeStartArgs := []interface{}{3, 17}
 		rangeEndArgs := []interface{}{103, 117}
 
-		query, explodedArgs, err := BuildUniqueKeyRangeEndPreparedQueryViaTemptable(databaseName, originalTableName, uniqueKeyColumns, rangeStartArgs, rangeEndArgs, chunkSize, false, "test")
+		query, explodedArgs, err := BuildUniqueKeyRangeEndPreparedQueryViaTemptable(databaseName, originalTableName, "", "name_position_uidx", uniqueKeyColumns, rangeStartArgs, rangeEndArgs, chunkSize, false, AscendingCopyOrder, "test")
 		test.S(t).ExpectNil(err)
 		expected := `
 				select /* gh-ost mydb.tbl test */ name, position
@@ -291,7 +958,7 @@ func TestBuildUniqueKeyRangeEndPreparedQuery(t *testing.T) {
 				    select
 				        name, position
 				      from
-				        mydb.tbl
+				        mydb.tbl force index (name_position_uidx)
 				      where ((name > ?) or (((name = ?)) AND (position > ?))) and ((name < ?) or (((name = ?)) AND (position < ?)) or ((name = ?) and (position = ?)))
 				      order by
 				        name asc, position asc
@@ -304,6 +971,100 @@ func TestBuildUniqueKeyRangeEndPreparedQuery(t *testing.T) {
 		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
 		test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{3, 3, 17, 103, 103, 117, 103, 117}))
 	}
+	{
+		uniqueKeyColumns := NewColumnList([]string{"id"})
+		query, explodedArgs, err := BuildUniqueKeyRangeEndPreparedQueryViaOffset(databaseName, originalTableName, "p2023", "PRIMARY", uniqueKeyColumns, []interface{}{3}, []interface{}{103}, chunkSize, true, AscendingCopyOrder, "test")
+		test.S(t).ExpectNil(err)
+		expected := `
+				select /* gh-ost mydb.tbl test */ id
+				  from
+				    mydb.tbl partition (p2023) force index (PRIMARY)
+				  where ((id > ?) or ((id = ?))) and ((id < ?) or ((id = ?)))
+				  order by
+				    id asc
+				  limit 1
+				  offset 499
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+		test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{3, 3, 103, 103}))
+	}
+	{
+		uniqueKeyColumns := NewColumnList([]string{"name", "position"})
+		query, explodedArgs, err := BuildUniqueKeyRangeEndPreparedQueryViaTemptable(databaseName, originalTableName, "", "name_position_uidx", uniqueKeyColumns, []interface{}{103, 117}, []interface{}{3, 17}, chunkSize, false, DescendingCopyOrder, "test")
+		test.S(t).ExpectNil(err)
+		expected := `
+				select /* gh-ost mydb.tbl test */ name, position
+				  from (
+				    select
+				        name, position
+				      from
+				        mydb.tbl force index (name_position_uidx)
+				      where ((name < ?) or (((name = ?)) AND (position < ?))) and ((name > ?) or (((name = ?)) AND (position > ?)) or ((name = ?) and (position = ?)))
+				      order by
+				        name desc, position desc
+				      limit 500
+				  ) select_osc_chunk
+				order by
+				  name asc, position asc
+				limit 1
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+		test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{103, 103, 117, 3, 3, 17, 3, 17}))
+	}
+	{
+		uniqueKeyColumns := NewColumnList([]string{"name", "position"})
+		query, explodedArgs, err := BuildUniqueKeyRangeEndPreparedQueryViaOffset(databaseName, originalTableName, "", "name_position_uidx", uniqueKeyColumns, []interface{}{103, 117}, []interface{}{3, 17}, chunkSize, true, DescendingCopyOrder, "test")
+		test.S(t).ExpectNil(err)
+		expected := `
+				select /* gh-ost mydb.tbl test */ name, position
+				  from
+				    mydb.tbl force index (name_position_uidx)
+				  where ((name < ?) or (((name = ?)) AND (position < ?)) or ((name = ?) and (position = ?))) and ((name > ?) or (((name = ?)) AND (position > ?)) or ((name = ?) and (position = ?)))
+				  order by
+				    name desc, position desc
+				  limit 1
+				  offset 499
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+		test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{103, 103, 117, 103, 117, 3, 3, 17, 3, 17}))
+	}
+}
+
+func TestBuildUniqueKeyRangeEndPreparedQueryUnsignedBigint(t *testing.T) {
+	uniqueKeyColumns := NewColumnList([]string{"id"})
+	uniqueKeyColumns.SetUnsigned("id")
+	{
+		// Range across 2^63, as scanned off the database
+		query, explodedArgs, err := BuildUniqueKeyRangeEndPreparedQueryViaOffset("mydb", "tbl", "", "PRIMARY", uniqueKeyColumns, []interface{}{int64(9223372036854775807)}, []interface{}{"18446744073709551615"}, 500, false, AscendingCopyOrder, "test")
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectTrue(strings.Contains(normalizeQuery(query), "where ((id > ?)) and ((id < ?) or ((id = ?)))"))
+		test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{uint64(9223372036854775807), uint64(18446744073709551615), uint64(18446744073709551615)}))
+	}
+	{
+		query, explodedArgs, err := BuildUniqueKeyRangeEndPreparedQueryViaTemptable("mydb", "tbl", "", "PRIMARY", uniqueKeyColumns, []interface{}{[]byte("9223372036854775808")}, []interface{}{[]byte("9223372036854775809")}, 500, true, AscendingCopyOrder, "test")
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectTrue(strings.Contains(normalizeQuery(query), "where ((id > ?) or ((id = ?))) and ((id < ?) or ((id = ?)))"))
+		test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{uint64(9223372036854775808), uint64(9223372036854775808), uint64(9223372036854775809), uint64(9223372036854775809)}))
+	}
+	{
+		// Final chunk, up to and including 2^64-1
+		_, explodedArgs, err := BuildRangeInsertPreparedQuery("mydb", "tbl", "", "mydb", "ghost", []string{"id"}, uniqueKeyColumns, "PRIMARY", uniqueKeyColumns, []interface{}{"18446744073709551614"}, []interface{}{"18446744073709551615"}, false, AscendingCopyOrder, true, IgnoreCopyConflictStrategy, "")
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{uint64(18446744073709551614), uint64(18446744073709551615), uint64(18446744073709551615)}))
+	}
+	{
+		// Descending copy order, down from 2^64-1
+		_, explodedArgs, err := BuildRangeCountPreparedQuery("mydb", "tbl", "", "PRIMARY", uniqueKeyColumns, []interface{}{"18446744073709551615"}, []interface{}{int64(9223372036854775807)}, true, DescendingCopyOrder, true, "")
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{uint64(18446744073709551615), uint64(18446744073709551615), uint64(9223372036854775807), uint64(9223372036854775807)}))
+	}
+	{
+		// Signed columns are left as is
+		signedColumns := NewColumnList([]string{"id"})
+		_, explodedArgs, err := BuildUniqueKeyRangeEndPreparedQueryViaOffset("mydb", "tbl", "", "PRIMARY", signedColumns, []interface{}{[]byte("-3")}, []interface{}{[]byte("17")}, 500, false, AscendingCopyOrder, "test")
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{[]byte("-3"), []byte("17"), []byte("17")}))
+	}
 }
 
 func TestBuildUniqueKeyMinValuesPreparedQuery(t *testing.T) {
@@ -311,7 +1072,7 @@ func TestBuildUniqueKeyMinValuesPreparedQuery(t *testing.T) {
 	originalTableName := "tbl"
 	uniqueKeyColumns := NewColumnList([]string{"name", "position"})
 	{
-		query, err := BuildUniqueKeyMinValuesPreparedQuery(databaseName, originalTableName, uniqueKeyColumns)
+		query, err := BuildUniqueKeyMinValuesPreparedQuery(databaseName, originalTableName, "", "", uniqueKeyColumns)
 		test.S(t).ExpectNil(err)
 		expected := `
 			select /* gh-ost mydb.tbl */ name, position
@@ -324,12 +1085,110 @@ func TestBuildUniqueKeyMinValuesPreparedQuery(t *testing.T) {
 		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
 	}
 	{
-		query, err := BuildUniqueKeyMaxValuesPreparedQuery(databaseName, originalTableName, uniqueKeyColumns)
+		query, err := BuildUniqueKeyMaxValuesPreparedQuery(databaseName, originalTableName, "", "name_position_uidx", uniqueKeyColumns)
 		test.S(t).ExpectNil(err)
 		expected := `
 			select /* gh-ost mydb.tbl */ name, position
 			  from
-			    mydb.tbl
+			    mydb.tbl force index (name_position_uidx)
+			  order by
+			    name desc, position desc
+			  limit 1
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+	}
+	{
+		query, err := BuildUniqueKeyMinValuesPreparedQuery(databaseName, originalTableName, "p0", "", uniqueKeyColumns)
+		test.S(t).ExpectNil(err)
+		expected := `
+			select /* gh-ost mydb.tbl */ name, position
+			  from
+			    mydb.tbl partition (p0)
+			  order by
+			    name asc, position asc
+			  limit 1
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+	}
+}
+
+func TestBuildQueriesMixedKeyPartDirections(t *testing.T) {
+	// unique key (a, b desc)
+	uniqueKeyColumns := NewColumnList([]string{"a", "b"})
+	uniqueKeyColumns.SetDescending("b")
+	{
+		query, explodedArgs, err := BuildRangeCountPreparedQuery("mydb", "tbl", "", "a_b_uidx", uniqueKeyColumns, []interface{}{1, 2}, []interface{}{3, 4}, false, AscendingCopyOrder, false, "")
+		test.S(t).ExpectNil(err)
+		expected := `
+			select /* gh-ost mydb.tbl */ count(*) from mydb.tbl force index (a_b_uidx)
+				where (((a > ?) or (((a = ?)) AND (b < ?))) and ((a < ?) or (((a = ?)) AND (b > ?)) or ((a = ?) and (b = ?))))
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+		test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{1, 1, 2, 3, 3, 4, 3, 4}))
+	}
+	{
+		query, _, err := BuildUniqueKeyRangeEndPreparedQueryViaOffset("mydb", "tbl", "", "a_b_uidx", uniqueKeyColumns, []interface{}{1, 2}, []interface{}{3, 4}, 500, false, AscendingCopyOrder, "test")
+		test.S(t).ExpectNil(err)
+		expected := `
+			select /* gh-ost mydb.tbl test */ a, b from mydb.tbl force index (a_b_uidx)
+				where ((a > ?) or (((a = ?)) AND (b < ?))) and ((a < ?) or (((a = ?)) AND (b > ?)) or ((a = ?) and (b = ?)))
+				order by a asc, b desc
+				limit 1
+				offset 499
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+	}
+	{
+		query, _, err := BuildUniqueKeyRangeEndPreparedQueryViaTemptable("mydb", "tbl", "", "a_b_uidx", uniqueKeyColumns, []interface{}{1, 2}, []interface{}{3, 4}, 500, false, DescendingCopyOrder, "test")
+		test.S(t).ExpectNil(err)
+		expected := `
+			select /* gh-ost mydb.tbl test */ a, b from (
+				select a, b from mydb.tbl force index (a_b_uidx)
+					where ((a < ?) or (((a = ?)) AND (b > ?))) and ((a > ?) or (((a = ?)) AND (b < ?)) or ((a = ?) and (b = ?)))
+					order by a desc, b asc
+					limit 500
+			) select_osc_chunk
+			order by a asc, b desc
+			limit 1
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+	}
+	{
+		query, err := BuildUniqueKeyMinValuesPreparedQuery("mydb", "tbl", "", "a_b_uidx", uniqueKeyColumns)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectTrue(strings.Contains(normalizeQuery(query), "order by a asc, b desc limit 1"))
+	}
+	{
+		query, err := BuildUniqueKeyMaxValuesPreparedQuery("mydb", "tbl", "", "a_b_uidx", uniqueKeyColumns)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectTrue(strings.Contains(normalizeQuery(query), "order by a desc, b asc limit 1"))
+	}
+}
+func TestBuildUniqueKeyMinMaxValuesPreparedQueryNullable(t *testing.T) {
+	// NULLs sort first ascending and last descending; range comparisons are NULL-safe, and so bounds may be NULL
+	uniqueKeyColumns := NewColumnList([]string{"name", "position"})
+	uniqueKeyColumns.GetColumn("name").Nullable = true
+	uniqueKeyColumns.GetColumn("position").Nullable = true
+	{
+		query, err := BuildUniqueKeyMinValuesPreparedQuery("mydb", "tbl", "", "name_position_uidx", uniqueKeyColumns)
+		test.S(t).ExpectNil(err)
+		expected := `
+			select /* gh-ost mydb.tbl */ name, position
+			  from
+			    mydb.tbl force index (name_position_uidx)
+			  order by
+			    name asc, position asc
+			  limit 1
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+	}
+	{
+		query, err := BuildUniqueKeyMaxValuesPreparedQuery("mydb", "tbl", "", "name_position_uidx", uniqueKeyColumns)
+		test.S(t).ExpectNil(err)
+		expected := `
+			select /* gh-ost mydb.tbl */ name, position
+			  from
+			    mydb.tbl force index (name_position_uidx)
 			  order by
 			    name desc, position desc
 			  limit 1
@@ -356,82 +1215,154 @@ func TestBuildDMLDeleteQuery(t *testing.T) {
 					((position = ?))
 		`
 		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{17}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{17}))
+	}
+	{
+		uniqueKeyColumns := NewColumnList([]string{"name", "position"})
+
+		query, uniqueKeyArgs, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, uniqueKeyColumns, args)
+		test.S(t).ExpectNil(err)
+		expected := `
+			delete /* gh-ost mydb.tbl */
+				from
+					mydb.tbl
+				where
+					((name = ?) and (position = ?))
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{"testname", 17}))
+	}
+	{
+		uniqueKeyColumns := NewColumnList([]string{"position", "name"})
+
+		query, uniqueKeyArgs, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, uniqueKeyColumns, args)
+		test.S(t).ExpectNil(err)
+		expected := `
+			delete /* gh-ost mydb.tbl */
+				from
+					mydb.tbl
+				where
+					((position = ?) and (name = ?))
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{17, "testname"}))
+	}
+	{
+		uniqueKeyColumns := NewColumnList([]string{"position", "name"})
+		args := []interface{}{"first", 17}
+
+		_, _, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, uniqueKeyColumns, args)
+		test.S(t).ExpectNotNil(err)
+	}
+}
+
+func TestBuildDMLDeleteQuerySignedUnsigned(t *testing.T) {
+	databaseName := "mydb"
+	tableName := "tbl"
+	tableColumns := NewColumnList([]string{"id", "name", "rank", "position", "age"})
+	uniqueKeyColumns := NewColumnList([]string{"position"})
+	{
+		// test signed (expect no change)
+		args := []interface{}{3, "testname", "first", -1, 23}
+		query, uniqueKeyArgs, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, uniqueKeyColumns, args)
+		test.S(t).ExpectNil(err)
+		expected := `
+			delete /* gh-ost mydb.tbl */
+				from
+					mydb.tbl
+				where
+					((position = ?))
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{-1}))
+	}
+	{
+		// test unsigned
+		args := []interface{}{3, "testname", "first", int8(-1), 23}
+		uniqueKeyColumns.SetUnsigned("position")
+		query, uniqueKeyArgs, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, uniqueKeyColumns, args)
+		test.S(t).ExpectNil(err)
+		expected := `
+			delete /* gh-ost mydb.tbl */
+				from
+					mydb.tbl
+				where
+					((position = ?))
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{uint8(255)}))
 	}
+}
+
+func TestBuildDMLMultiDeleteQuery(t *testing.T) {
+	databaseName := "mydb"
+	tableName := "tbl"
+	tableColumns := NewColumnList([]string{"id", "name", "rank", "position", "age"})
+	rowsArgs := [][]interface{}{{3, "testname", "first", 17, 23}, {4, "othername", "second", 19, 29}}
 	{
-		uniqueKeyColumns := NewColumnList([]string{"name", "position"})
+		uniqueKeyColumns := NewColumnList([]string{"position"})
 
-		query, uniqueKeyArgs, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, uniqueKeyColumns, args)
+		query, uniqueKeyArgs, err := BuildDMLMultiDeleteQuery(databaseName, tableName, tableColumns, uniqueKeyColumns, rowsArgs)
 		test.S(t).ExpectNil(err)
 		expected := `
 			delete /* gh-ost mydb.tbl */
 				from
 					mydb.tbl
 				where
-					((name = ?) and (position = ?))
+					position in (?, ?)
 		`
 		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{"testname", 17}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{17, 19}))
 	}
 	{
-		uniqueKeyColumns := NewColumnList([]string{"position", "name"})
+		uniqueKeyColumns := NewColumnList([]string{"name", "position"})
 
-		query, uniqueKeyArgs, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, uniqueKeyColumns, args)
+		query, uniqueKeyArgs, err := BuildDMLMultiDeleteQuery(databaseName, tableName, tableColumns, uniqueKeyColumns, rowsArgs)
 		test.S(t).ExpectNil(err)
 		expected := `
 			delete /* gh-ost mydb.tbl */
 				from
 					mydb.tbl
 				where
-					((position = ?) and (name = ?))
+					(name, position) in ((?, ?), (?, ?))
 		`
 		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{17, "testname"}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{"testname", 17, "othername", 19}))
 	}
 	{
-		uniqueKeyColumns := NewColumnList([]string{"position", "name"})
-		args := []interface{}{"first", 17}
-
-		_, _, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, uniqueKeyColumns, args)
+		uniqueKeyColumns := NewColumnList([]string{"position"})
+		_, _, err := BuildDMLMultiDeleteQuery(databaseName, tableName, tableColumns, uniqueKeyColumns, [][]interface{}{{3, "testname"}})
+		test.S(t).ExpectNotNil(err)
+		_, _, err = BuildDMLMultiDeleteQuery(databaseName, tableName, tableColumns, uniqueKeyColumns, [][]interface{}{})
 		test.S(t).ExpectNotNil(err)
 	}
 }
 
-func TestBuildDMLDeleteQuerySignedUnsigned(t *testing.T) {
-	databaseName := "mydb"
-	tableName := "tbl"
+func TestBuildDMLRowFilterDeleteQuery(t *testing.T) {
 	tableColumns := NewColumnList([]string{"id", "name", "rank", "position", "age"})
-	uniqueKeyColumns := NewColumnList([]string{"position"})
+	rowsArgs := [][]interface{}{{3, "testname", "first", 17, 23}, {4, "othername", "second", 19, 29}}
 	{
-		// test signed (expect no change)
-		args := []interface{}{3, "testname", "first", -1, 23}
-		query, uniqueKeyArgs, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, uniqueKeyColumns, args)
+		uniqueKeyColumns := NewColumnList([]string{"position"})
+
+		query, uniqueKeyArgs, err := BuildDMLRowFilterDeleteQuery("mydb", "tbl", "age > 25", tableColumns, uniqueKeyColumns, rowsArgs)
 		test.S(t).ExpectNil(err)
 		expected := `
 			delete /* gh-ost mydb.tbl */
 				from
 					mydb.tbl
 				where
-					((position = ?))
+					position in (?, ?)
+					and ((age > 25) is not true)
 		`
 		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{-1}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{17, 19}))
 	}
 	{
-		// test unsigned
-		args := []interface{}{3, "testname", "first", int8(-1), 23}
-		uniqueKeyColumns.SetUnsigned("position")
-		query, uniqueKeyArgs, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, uniqueKeyColumns, args)
-		test.S(t).ExpectNil(err)
-		expected := `
-			delete /* gh-ost mydb.tbl */
-				from
-					mydb.tbl
-				where
-					((position = ?))
-		`
-		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
-		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{uint8(255)}))
+		uniqueKeyColumns := NewColumnList([]string{"position"})
+		_, _, err := BuildDMLRowFilterDeleteQuery("mydb", "tbl", "", tableColumns, uniqueKeyColumns, rowsArgs)
+		test.S(t).ExpectNotNil(err)
+		_, _, err = BuildDMLRowFilterDeleteQuery("mydb", "tbl", "age > 25", tableColumns, uniqueKeyColumns, [][]interface{}{})
+		test.S(t).ExpectNotNil(err)
 	}
 }
 
@@ -480,6 +1411,158 @@ func TestBuildDMLInsertQuery(t *testing.T) {
 	}
 }
 
+func TestBuildDMLMultiInsertQuery(t *testing.T) {
+	databaseName := "mydb"
+	tableName := "tbl"
+	tableColumns := NewColumnList([]string{"id", "name", "rank", "position", "age"})
+	rowsArgs := [][]interface{}{{3, "testname", "first", 17, 23}, {4, "othername", "second", 19, 29}}
+	{
+		sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
+		query, sharedArgs, err := BuildDMLMultiInsertQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, rowsArgs)
+		test.S(t).ExpectNil(err)
+		expected := `
+			replace /* gh-ost mydb.tbl */
+				into mydb.tbl
+					(id, name, position, age)
+				values
+					(?, ?, ?, ?), (?, ?, ?, ?)
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "testname", 17, 23, 4, "othername", 19, 29}))
+	}
+	{
+		sharedColumns := NewColumnList([]string{"id", "position"})
+		mappedSharedColumns := NewColumnList([]string{"id", "location"})
+		mappedSharedColumns.SetColumnType("location", JSONColumnType)
+		query, sharedArgs, err := BuildDMLMultiInsertQuery(databaseName, tableName, tableColumns, sharedColumns, mappedSharedColumns, rowsArgs)
+		test.S(t).ExpectNil(err)
+		expected := `
+			replace /* gh-ost mydb.tbl */
+				into mydb.tbl
+					(id, location)
+				values
+					(?, convert(? using utf8mb4)), (?, convert(? using utf8mb4))
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, 17, 4, 19}))
+	}
+	{
+		sharedColumns := NewColumnList([]string{"id", "position"})
+		mappedSharedColumns := NewColumnList([]string{"id", "location"})
+		mappedSharedColumns.SetGeometrySRIDConversion("location", 4326)
+		query, sharedArgs, err := BuildDMLMultiInsertQuery(databaseName, tableName, tableColumns, sharedColumns, mappedSharedColumns, rowsArgs)
+		test.S(t).ExpectNil(err)
+		expected := `
+			replace /* gh-ost mydb.tbl */
+				into mydb.tbl
+					(id, location)
+				values
+					(?, st_srid(st_geomfromwkb(substring(?, 5)), 4326)), (?, st_srid(st_geomfromwkb(substring(?, 5)), 4326))
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, 17, 4, 19}))
+	}
+	{
+		sharedColumns := NewColumnList([]string{"position", "name", "surprise", "id"})
+		_, _, err := BuildDMLMultiInsertQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, rowsArgs)
+		test.S(t).ExpectNotNil(err)
+	}
+	{
+		sharedColumns := NewColumnList([]string{"id", "name"})
+		_, _, err := BuildDMLMultiInsertQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, [][]interface{}{{3, "testname"}})
+		test.S(t).ExpectNotNil(err)
+	}
+}
+
+func TestBuildDMLUpdateQueryTypeConversion(t *testing.T) {
+	databaseName := "mydb"
+	tableName := "tbl"
+	tableColumns := NewColumnList([]string{"id", "color", "flags"})
+	sharedColumns := NewColumnList([]string{"id", "color", "flags"})
+	sharedColumns.GetColumn("color").Type = EnumColumnType
+	sharedColumns.GetColumn("color").MySQLType = "enum('red','green')"
+	sharedColumns.GetColumn("color").EnumValues = "'red','green'"
+	sharedColumns.GetColumn("flags").Type = SetColumnType
+	sharedColumns.GetColumn("flags").MySQLType = "set('x','y')"
+	sharedColumns.GetColumn("flags").EnumValues = "'x','y'"
+	toText := &Column{MySQLType: "varchar(16)", Charset: "utf8mb4"}
+	sharedColumns.SetTypeConversion("color", NewTypeConversion(sharedColumns.GetColumn("color"), toText))
+	sharedColumns.SetTypeConversion("flags", NewTypeConversion(sharedColumns.GetColumn("flags"), toText))
+	uniqueKeyColumns := NewColumnList([]string{"id", "color"})
+	*uniqueKeyColumns.GetColumn("color") = *sharedColumns.GetColumn("color")
+
+	valueArgs := []interface{}{3, int64(2), int64(3)}
+	whereArgs := []interface{}{3, int64(2), int64(1)}
+	query, sharedArgs, uniqueKeyArgs, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, uniqueKeyColumns, valueArgs, whereArgs)
+	test.S(t).ExpectNil(err)
+	expected := `
+			update /* gh-ost mydb.tbl */
+				mydb.tbl
+			set id=?, color=?, flags=?
+			where
+				((id = ?) and (color = ?))
+		`
+	test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+	test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "green", "x,y"}))
+	test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{3, "green"}))
+}
+
+func TestBuildDMLUpdateQueryJSONDiffs(t *testing.T) {
+	tableColumns := NewColumnList([]string{"id", "doc", "name"})
+	sharedColumns := NewColumnList([]string{"id", "doc", "name"})
+	sharedColumns.SetColumnType("doc", JSONColumnType)
+	mappedSharedColumns := NewColumnList([]string{"id", "document", "name"})
+	mappedSharedColumns.SetColumnType("document", JSONColumnType)
+	uniqueKeyColumns := NewColumnList([]string{"id"})
+	diffs := JSONDiffs{
+		{Operation: JSONDiffReplace, Path: "$.a.b[2].c", Value: `{"d": [1, 2]}`},
+		{Operation: JSONDiffRemove, Path: "$.arr[1]"},
+	}
+	valueArgs := []interface{}{3, diffs, "new"}
+	whereArgs := []interface{}{3, `{"a": {"b": [0, 1, {"c": 2}]}, "arr": [1, 2, 3]}`, "old"}
+	query, sharedArgs, uniqueKeyArgs, err := BuildDMLUpdateQuery("mydb", "tbl", tableColumns, sharedColumns, mappedSharedColumns, uniqueKeyColumns, valueArgs, whereArgs)
+	test.S(t).ExpectNil(err)
+	expected := `
+			update /* gh-ost mydb.tbl */
+				mydb.tbl
+			set id=?, document=json_remove(json_set(document, ?, cast(convert(? using utf8mb4) as json)), ?), name=?
+			where
+				((id = ?))
+		`
+	test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+	test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "$.a.b[2].c", `{"d": [1, 2]}`, "$.arr[1]", "new"}))
+	test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{3}))
+}
+
+func TestBuildDMLInsertQueryServerCharsetConversion(t *testing.T) {
+	databaseName := "mydb"
+	tableName := "tbl"
+	tableColumns := NewColumnList([]string{"id", "name"})
+	sharedColumns := NewColumnList([]string{"id", "name"})
+	sharedColumns.SetCharset("name", "ucs2")
+	sharedColumns.SetCharsetConversion("name", "utf8mb4")
+	mappedSharedColumns := NewColumnList([]string{"id", "full_name"})
+	mappedSharedColumns.SetCharset("full_name", "utf8mb4")
+	mappedSharedColumns.SetServerCharsetConversion("full_name", "ucs2")
+
+	ucs2Value := []byte{0x00, 'c', 0x00, 0xe9}
+	query, sharedArgs, err := BuildDMLInsertQuery(databaseName, tableName, tableColumns, sharedColumns, mappedSharedColumns, []interface{}{3, ucs2Value})
+	test.S(t).ExpectNil(err)
+	expected := `
+			replace /* gh-ost mydb.tbl */
+				into mydb.tbl
+					(id, full_name)
+				values
+					(?, convert(? using ucs2))
+		`
+	test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+	test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, ucs2Value}))
+
+	setClause, err := BuildSetPreparedClause(mappedSharedColumns)
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectEquals(setClause, "`id`=?, `full_name`=convert(? using ucs2)")
+}
+
 func TestBuildDMLInsertQuerySignedUnsigned(t *testing.T) {
 	databaseName := "mydb"
 	tableName := "tbl"
@@ -535,6 +1618,43 @@ func TestBuildDMLInsertQuerySignedUnsigned(t *testing.T) {
 	}
 }
 
+func TestBuildDMLQueriesInvisibleUniqueKeyColumn(t *testing.T) {
+	// Invisible columns are part of binlog row images, in their ordinal position
+	tableColumns := NewColumnList([]string{"id", "uuid", "name"})
+	tableColumns.GetColumn("uuid").Invisible = true
+	sharedColumns := NewColumnList([]string{"id", "uuid", "name"})
+	sharedColumns.GetColumn("uuid").Invisible = true
+	uniqueKeyColumns := NewColumnList([]string{"uuid"})
+	uniqueKeyColumns.GetColumn("uuid").Invisible = true
+	{
+		query, sharedArgs, uniqueKeyArgs, err := BuildDMLUpdateQuery("mydb", "tbl", tableColumns, sharedColumns, sharedColumns, uniqueKeyColumns, []interface{}{3, "u-3", "newname"}, []interface{}{3, "u-3", "oldname"})
+		test.S(t).ExpectNil(err)
+		expected := `
+			update /* gh-ost mydb.tbl */
+			  mydb.tbl
+					set id=?, uuid=?, name=?
+				where
+					((uuid = ?))
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, "u-3", "newname"}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{"u-3"}))
+	}
+	{
+		query, uniqueKeyArgs, err := BuildDMLDeleteQuery("mydb", "tbl", tableColumns, uniqueKeyColumns, []interface{}{3, "u-3", "oldname"})
+		test.S(t).ExpectNil(err)
+		expected := `
+			delete /* gh-ost mydb.tbl */
+				from
+					mydb.tbl
+				where
+					((uuid = ?))
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{"u-3"}))
+	}
+}
+
 func TestBuildDMLUpdateQuery(t *testing.T) {
 	databaseName := "mydb"
 	tableName := "tbl"
@@ -636,6 +1756,74 @@ func TestBuildDMLUpdateQuery(t *testing.T) {
 	}
 }
 
+func TestBuildDMLQueriesGeneratedColumns(t *testing.T) {
+	databaseName := "mydb"
+	tableName := "tbl"
+	tableColumns := NewColumnList([]string{"id", "a", "b", "sum_ab", "doubled_a"})
+	sharedColumns := NewColumnList([]string{"id", "a", "b", "sum_ab", "doubled_a"})
+	// sum_ab is generated on both tables, doubled_a is generated on the original table only
+	sharedColumns.SetGenerated("sum_ab", StoredGeneratedColumn)
+	sharedColumns.SetGenerated("doubled_a", VirtualGeneratedColumn)
+	mappedSharedColumns := NewColumnList([]string{"id", "a", "b", "sum_ab", "doubled_a"})
+	mappedSharedColumns.SetGenerated("sum_ab", StoredGeneratedColumn)
+	uniqueKeyColumns := NewColumnList([]string{"id", "sum_ab"})
+	args := []interface{}{3, 5, 7, 12, 10}
+	{
+		query, sharedArgs, err := BuildDMLInsertQuery(databaseName, tableName, tableColumns, sharedColumns, mappedSharedColumns, args)
+		test.S(t).ExpectNil(err)
+		expected := `
+			replace /* gh-ost mydb.tbl */
+				into mydb.tbl
+					(id, a, b, doubled_a)
+				values
+					(?, ?, ?, ?)
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, 5, 7, 10}))
+	}
+	{
+		query, sharedArgs, err := BuildDMLMultiInsertQuery(databaseName, tableName, tableColumns, sharedColumns, mappedSharedColumns, [][]interface{}{args, args})
+		test.S(t).ExpectNil(err)
+		expected := `
+			replace /* gh-ost mydb.tbl */
+				into mydb.tbl
+					(id, a, b, doubled_a)
+				values
+					(?, ?, ?, ?), (?, ?, ?, ?)
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, 5, 7, 10, 3, 5, 7, 10}))
+	}
+	{
+		// The generated column is not set, yet identifies the row
+		whereArgs := []interface{}{3, 4, 7, 11, 8}
+		query, sharedArgs, uniqueKeyArgs, err := BuildDMLUpdateQuery(databaseName, tableName, tableColumns, sharedColumns, mappedSharedColumns, uniqueKeyColumns, args, whereArgs)
+		test.S(t).ExpectNil(err)
+		expected := `
+			update /* gh-ost mydb.tbl */
+			  mydb.tbl
+					set id=?, a=?, b=?, doubled_a=?
+				where
+					((id = ?) and (sum_ab = ?))
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+		test.S(t).ExpectTrue(reflect.DeepEqual(sharedArgs, []interface{}{3, 5, 7, 10}))
+		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{3, 11}))
+	}
+	{
+		query, uniqueKeyArgs, err := BuildDMLDeleteQuery(databaseName, tableName, tableColumns, uniqueKeyColumns, args)
+		test.S(t).ExpectNil(err)
+		expected := `
+			delete /* gh-ost mydb.tbl */
+				from mydb.tbl
+				where
+					((id = ?) and (sum_ab = ?))
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{3, 12}))
+	}
+}
+
 func TestBuildDMLUpdateQuerySignedUnsigned(t *testing.T) {
 	databaseName := "mydb"
 	tableName := "tbl"
@@ -677,3 +1865,171 @@ func TestBuildDMLUpdateQuerySignedUnsigned(t *testing.T) {
 		test.S(t).ExpectTrue(reflect.DeepEqual(uniqueKeyArgs, []interface{}{uint8(253)}))
 	}
 }
+
+func TestBuildDMLRowMatchDeleteQuery(t *testing.T) {
+	tableColumns := NewColumnList([]string{"id", "name", "position"})
+	sharedColumns := NewColumnList([]string{"id", "name"})
+	mappedSharedColumns := NewColumnList([]string{"id", "title"})
+	args := []interface{}{3, "testname", 17}
+	{
+		query, explodedArgs, err := BuildDMLRowMatchDeleteQuery("mydb", "tbl", "mydb", "ghost", tableColumns, sharedColumns, mappedSharedColumns, args)
+		test.S(t).ExpectNil(err)
+		expected := `
+			delete /* gh-ost mydb.ghost */
+				from mydb.ghost
+				where (id <=> ?) and (title <=> ?)
+					and not exists (select 1 from mydb.tbl where (id <=> ?) and (name <=> ?))
+				limit 1
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+		test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{3, "testname", 3, "testname"}))
+	}
+	{
+		query, explodedArgs, err := BuildDMLRowMatchDeleteQuery("mydb", "", "mydb", "ghost", tableColumns, sharedColumns, mappedSharedColumns, args)
+		test.S(t).ExpectNil(err)
+		expected := `
+			delete /* gh-ost mydb.ghost */
+				from mydb.ghost
+				where (id <=> ?) and (title <=> ?)
+				limit 1
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+		test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{3, "testname"}))
+	}
+	{
+		_, _, err := BuildDMLRowMatchDeleteQuery("mydb", "tbl", "mydb", "ghost", tableColumns, sharedColumns, mappedSharedColumns, []interface{}{3})
+		test.S(t).ExpectNotNil(err)
+	}
+}
+
+func TestBuildDMLRowMatchInsertQuery(t *testing.T) {
+	tableColumns := NewColumnList([]string{"id", "name", "position"})
+	sharedColumns := NewColumnList([]string{"id", "name"})
+	mappedSharedColumns := NewColumnList([]string{"id", "title"})
+	args := []interface{}{3, "testname", 17}
+	{
+		query, explodedArgs, err := BuildDMLRowMatchInsertQuery("mydb", "tbl", "mydb", "ghost", tableColumns, sharedColumns, mappedSharedColumns, args)
+		test.S(t).ExpectNil(err)
+		expected := `
+			insert /* gh-ost mydb.ghost */ into mydb.ghost (id, title)
+				select ?, ? from dual
+				where exists (select 1 from mydb.tbl where (id <=> ?) and (name <=> ?))
+					and not exists (select 1 from mydb.ghost where (id <=> ?) and (title <=> ?))
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+		test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{3, "testname", 3, "testname", 3, "testname"}))
+	}
+	{
+		query, explodedArgs, err := BuildDMLRowMatchInsertQuery("mydb", "", "mydb", "ghost", tableColumns, sharedColumns, mappedSharedColumns, args)
+		test.S(t).ExpectNil(err)
+		expected := `
+			insert /* gh-ost mydb.ghost */ into mydb.ghost (id, title)
+				select ?, ? from dual
+				where not exists (select 1 from mydb.ghost where (id <=> ?) and (title <=> ?))
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+		test.S(t).ExpectTrue(reflect.DeepEqual(explodedArgs, []interface{}{3, "testname", 3, "testname"}))
+	}
+}
+
+func TestBuildAddForeignKeysQuery(t *testing.T) {
+	foreignKeys := [](*ForeignKey){
+		{
+			Name:              "fk_parent",
+			Columns:           *NewColumnList([]string{"parent_id"}),
+			ReferencedSchema:  "mydb",
+			ReferencedTable:   "parent",
+			ReferencedColumns: *NewColumnList([]string{"id"}),
+			UpdateRule:        "NO ACTION",
+			DeleteRule:        "RESTRICT",
+		},
+		{
+			Name:              "_fk_other",
+			Columns:           *NewColumnList([]string{"other_id", "other_ts"}),
+			ReferencedSchema:  "otherdb",
+			ReferencedTable:   "other",
+			ReferencedColumns: *NewColumnList([]string{"id", "ts"}),
+			UpdateRule:        "RESTRICT",
+			DeleteRule:        "RESTRICT",
+		},
+	}
+	{
+		query, err := BuildAddForeignKeysQuery("mydb", "_tbl_gho", foreignKeys, map[string]string{})
+		test.S(t).ExpectNil(err)
+		expected := `
+			alter /* gh-ost mydb._tbl_gho */ table mydb._tbl_gho
+				add constraint _fk_parent foreign key (parent_id) references mydb.parent (id) on update NO ACTION on delete RESTRICT,
+				add constraint fk_other foreign key (other_id, other_ts) references otherdb.other (id, ts) on update RESTRICT on delete RESTRICT
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+	}
+	{
+		// renamed column
+		query, err := BuildAddForeignKeysQuery("mydb", "_tbl_gho", foreignKeys[0:1], map[string]string{"parent_id": "parent_ref"})
+		test.S(t).ExpectNil(err)
+		expected := `
+			alter /* gh-ost mydb._tbl_gho */ table mydb._tbl_gho
+				add constraint _fk_parent foreign key (parent_ref) references mydb.parent (id) on update NO ACTION on delete RESTRICT
+		`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+	}
+	{
+		_, err := BuildAddForeignKeysQuery("mydb", "_tbl_gho", nil, map[string]string{})
+		test.S(t).ExpectNotNil(err)
+	}
+	{
+		longName := &ForeignKey{Name: strings.Repeat("x", MaxForeignKeyNameLength), Columns: *NewColumnList([]string{"parent_id"}), ReferencedColumns: *NewColumnList([]string{"id"})}
+		_, err := BuildAddForeignKeysQuery("mydb", "_tbl_gho", [](*ForeignKey){longName}, map[string]string{})
+		test.S(t).ExpectNotNil(err)
+	}
+}
+
+func TestBuildDropForeignKeysQuery(t *testing.T) {
+	{
+		query, err := BuildDropForeignKeysQuery("mydb", "_tbl_del", []string{"fk_parent", "fk_other"})
+		test.S(t).ExpectNil(err)
+		expected := `alter /* gh-ost mydb._tbl_del */ table mydb._tbl_del drop foreign key fk_parent, drop foreign key fk_other`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+	}
+	{
+		_, err := BuildDropForeignKeysQuery("mydb", "_tbl_del", []string{})
+		test.S(t).ExpectNotNil(err)
+	}
+}
+
+func TestBuildRenameCheckConstraintsQuery(t *testing.T) {
+	checkConstraints := [](*CheckConstraint){
+		{Name: "_tbl_gho_chk_1", Clause: "(`amount` > 0)", Enforced: true},
+		{Name: "_tbl_gho_chk_2", Clause: "(`discount` <= `amount`)", Enforced: false},
+		{Name: "_tbl_gho_chk_3", Clause: "(`id` > 0)", Enforced: true},
+	}
+	{
+		query, err := BuildRenameCheckConstraintsQuery("mydb", "_tbl_gho", checkConstraints, map[string]string{"_tbl_gho_chk_1": "_positive_amount", "_tbl_gho_chk_2": "_discount_le_amount"})
+		test.S(t).ExpectNil(err)
+		expected := `alter /* gh-ost mydb._tbl_gho */ table mydb._tbl_gho
+			drop check _tbl_gho_chk_1, add constraint _positive_amount check ((amount > 0)) enforced,
+			drop check _tbl_gho_chk_2, add constraint _discount_le_amount check ((discount <= amount)) not enforced`
+		test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+	}
+	{
+		_, err := BuildRenameCheckConstraintsQuery("mydb", "_tbl_gho", checkConstraints, map[string]string{})
+		test.S(t).ExpectNotNil(err)
+	}
+}
+
+func TestBuildCreateTriggerQuery(t *testing.T) {
+	trigger := &Trigger{
+		Name:      "tbl_audit",
+		Timing:    "AFTER",
+		Event:     "INSERT",
+		Statement: "insert into tbl_audit (id, action) values (NEW.id, 'insert')",
+	}
+	query := BuildCreateTriggerQuery("mydb", "_tbl_gho", trigger.GhostName(), trigger)
+	expected := `create /* gh-ost */ trigger mydb._tbl_audit_gho AFTER INSERT on mydb._tbl_gho for each row insert into tbl_audit (id, action) values (NEW.id, 'insert')`
+	test.S(t).ExpectEquals(normalizeQuery(query), normalizeQuery(expected))
+}
+
+func TestBuildDropTriggerQuery(t *testing.T) {
+	query := BuildDropTriggerQuery("mydb", "_tbl_audit_gho")
+	test.S(t).ExpectEquals(normalizeQuery(query), "drop /* gh-ost */ trigger if exists mydb._tbl_audit_gho")
+}
@@ -8,9 +8,16 @@ package sql
 import (
 	"golang.org/x/text/encoding"
 	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
 	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
 )
 
+// binaryCharset is the pseudo charset of binary string columns (BINARY, VARBINARY, BLOB).
+// Values of such columns are raw bytes and must never be decoded.
+const binaryCharset = "binary"
+
 type charsetEncoding map[string]encoding.Encoding
 
 var charsetEncodingMap charsetEncoding
@@ -19,5 +26,34 @@ func init() {
 	charsetEncodingMap = make(map[string]encoding.Encoding)
 	// Begin mappings
 	charsetEncodingMap["latin1"] = charmap.Windows1252
+	charsetEncodingMap["latin2"] = charmap.ISO8859_2
+	charsetEncodingMap["latin7"] = charmap.ISO8859_13
+	charsetEncodingMap["greek"] = charmap.ISO8859_7
+	charsetEncodingMap["hebrew"] = charmap.ISO8859_8
+	charsetEncodingMap["cp850"] = charmap.CodePage850
+	charsetEncodingMap["cp852"] = charmap.CodePage852
+	charsetEncodingMap["cp866"] = charmap.CodePage866
+	charsetEncodingMap["cp1250"] = charmap.Windows1250
+	charsetEncodingMap["cp1251"] = charmap.Windows1251
+	charsetEncodingMap["cp1256"] = charmap.Windows1256
+	charsetEncodingMap["cp1257"] = charmap.Windows1257
+	charsetEncodingMap["koi8r"] = charmap.KOI8R
+	charsetEncodingMap["koi8u"] = charmap.KOI8U
 	charsetEncodingMap["gbk"] = simplifiedchinese.GBK
+	charsetEncodingMap["gb18030"] = simplifiedchinese.GB18030
+	charsetEncodingMap["big5"] = traditionalchinese.Big5
+	charsetEncodingMap["euckr"] = korean.EUCKR
+	charsetEncodingMap["sjis"] = japanese.ShiftJIS
+	charsetEncodingMap["ujis"] = japanese.EUCJP
+}
+
+// IsDecodableCharset tells whether string values in given charset can be decoded into UTF-8 by gh-ost.
+// Binary strings are never decoded, and UTF-8 and ASCII strings need no decoding.
+func IsDecodableCharset(charset string) bool {
+	switch charset {
+	case binaryCharset, "utf8", "utf8mb3", "utf8mb4", "ascii":
+		return true
+	}
+	_, ok := charsetEncodingMap[charset]
+	return ok
 }
@@ -17,6 +17,10 @@ var (
 	dropColumnRegexp                     = regexp.MustCompile(`(?i)\bdrop\s+(column\s+|)([\S]+)$`)
 	renameTableRegexp                    = regexp.MustCompile(`(?i)\brename\s+(to|as)\s+`)
 	autoIncrementRegexp                  = regexp.MustCompile(`(?i)\bauto_increment[\s]*=[\s]*([0-9]+)`)
+	foreignKeyRegexp                     = regexp.MustCompile(`(?i)\bforeign\s+key\b`)
+	alterColumnVisibilityRegexp          = regexp.MustCompile(`(?i)\balter\s+(column\s+|)([\S]+)\s+set\s+(visible|invisible)\b`)
+	modifyColumnRegexp                   = regexp.MustCompile(`(?i)\bmodify\s+(column\s+|)([\S]+)\s+`)
+	columnVisibilityRegexp               = regexp.MustCompile(`(?i)\b(visible|invisible)\b`)
 	alterTableExplicitSchemaTableRegexps = []*regexp.Regexp{
 		// ALTER TABLE `scm`.`tbl` something
 		regexp.MustCompile(`(?i)\balter\s+table\s+` + "`" + `([^` + "`" + `]+)` + "`" + `[.]` + "`" + `([^` + "`" + `]+)` + "`" + `\s+(.*$)`),
@@ -33,14 +37,70 @@ var (
 		// ALTER TABLE tbl something
 		regexp.MustCompile(`(?i)\balter\s+table\s+([\S]+)\s+(.*$)`),
 	}
-	enumValuesRegexp = regexp.MustCompile("^enum[(](.*)[)]$")
+	enumValuesRegexp = regexp.MustCompile("^(?:enum|set)[(](.*)[)]$")
+
+	statementCommentsRegexp = regexp.MustCompile(`(?s)/[*].*?[*]/`)
+	ddlStatementRegexp      = regexp.MustCompile(`(?is)^\s*(alter|truncate|drop|rename)\s+(.*)$`)
+	ddlTableKeywordRegexp   = regexp.MustCompile(`(?is)^(online\s+|ignore\s+|temporary\s+)*table\s+(if\s+exists\s+)?(.*)$`)
+	ddlTableNameRegexp      = regexp.MustCompile("^(`[^`]+`|[^\\s.,`;]+)([.](`[^`]+`|[^\\s.,`;]+))?\\s*")
+	ddlTableSeparatorRegexp = regexp.MustCompile(`(?is)^(,|to\s)\s*`)
 )
 
+// DDLTable is a table referenced by a DDL statement. Schema is empty when not explicitly given.
+type DDLTable struct {
+	Schema string
+	Name   string
+}
+
+// ParseDDLStatementTables returns the type (ALTER, TRUNCATE, DROP, RENAME) of a table DDL statement,
+// and the tables it operates on. Other statements, including DDL on non-table objects, return no tables.
+func ParseDDLStatementTables(statement string) (ddl string, tables []DDLTable) {
+	statement = statementCommentsRegexp.ReplaceAllString(statement, " ")
+	submatch := ddlStatementRegexp.FindStringSubmatch(statement)
+	if len(submatch) == 0 {
+		return "", tables
+	}
+	ddl = strings.ToUpper(submatch[1])
+	remainder := submatch[2]
+	if tableSubmatch := ddlTableKeywordRegexp.FindStringSubmatch(remainder); len(tableSubmatch) > 0 {
+		remainder = tableSubmatch[3]
+	} else if ddl != "TRUNCATE" {
+		// TABLE keyword is only optional for TRUNCATE
+		return "", tables
+	}
+	unquote := func(name string) string {
+		return strings.Trim(name, "`")
+	}
+	for {
+		nameSubmatch := ddlTableNameRegexp.FindStringSubmatch(remainder)
+		if len(nameSubmatch) == 0 {
+			break
+		}
+		if nameSubmatch[3] == "" {
+			tables = append(tables, DDLTable{Name: unquote(nameSubmatch[1])})
+		} else {
+			tables = append(tables, DDLTable{Schema: unquote(nameSubmatch[1]), Name: unquote(nameSubmatch[3])})
+		}
+		remainder = remainder[len(nameSubmatch[0]):]
+		if ddl == "ALTER" || ddl == "TRUNCATE" {
+			break
+		}
+		separator := ddlTableSeparatorRegexp.FindString(remainder)
+		if separator == "" {
+			break
+		}
+		remainder = remainder[len(separator):]
+	}
+	return ddl, tables
+}
+
 type AlterTableParser struct {
 	columnRenameMap        map[string]string
 	droppedColumns         map[string]bool
+	columnVisibility       map[string]bool
 	isRenameTable          bool
 	isAutoIncrementDefined bool
+	hasForeignKeyChanges   bool
 
 	alterStatementOptions string
 	alterTokens           []string
@@ -51,8 +111,9 @@ type AlterTableParser struct {
 
 func NewAlterTableParser() *AlterTableParser {
 	return &AlterTableParser{
-		columnRenameMap: make(map[string]string),
-		droppedColumns:  make(map[string]bool),
+		columnRenameMap:  make(map[string]string),
+		droppedColumns:   make(map[string]bool),
+		columnVisibility: make(map[string]bool),
 	}
 }
 
@@ -119,6 +180,29 @@ func (this *AlterTableParser) parseAlterToken(alterToken string) (err error) {
 			this.droppedColumns[submatch[2]] = true
 		}
 	}
+	{
+		// column visibility, as explicitly stated. MODIFY and CHANGE COLUMN otherwise make a column visible.
+		if submatch := alterColumnVisibilityRegexp.FindStringSubmatch(alterToken); len(submatch) > 0 {
+			if unquoted, err := strconv.Unquote(submatch[2]); err == nil {
+				submatch[2] = unquoted
+			}
+			this.columnVisibility[submatch[2]] = strings.EqualFold(submatch[3], "invisible")
+		}
+		columnName := ""
+		if submatch := renameColumnRegexp.FindStringSubmatch(alterToken); len(submatch) > 0 {
+			columnName = submatch[3]
+		} else if submatch := modifyColumnRegexp.FindStringSubmatch(alterToken); len(submatch) > 0 {
+			columnName = submatch[2]
+		}
+		if columnName != "" {
+			if unquoted, err := strconv.Unquote(columnName); err == nil {
+				columnName = unquoted
+			}
+			if submatch := columnVisibilityRegexp.FindStringSubmatch(alterToken); len(submatch) > 0 {
+				this.columnVisibility[columnName] = strings.EqualFold(submatch[1], "invisible")
+			}
+		}
+	}
 	{
 		// rename table
 		if renameTableRegexp.MatchString(alterToken) {
@@ -131,6 +215,12 @@ func (this *AlterTableParser) parseAlterToken(alterToken string) (err error) {
 			this.isAutoIncrementDefined = true
 		}
 	}
+	{
+		// add/drop foreign key
+		if foreignKeyRegexp.MatchString(alterToken) {
+			this.hasForeignKeyChanges = true
+		}
+	}
 	return nil
 }
 
@@ -179,6 +269,12 @@ func (this *AlterTableParser) DroppedColumnsMap() map[string]bool {
 	return this.droppedColumns
 }
 
+// ColumnVisibilityMap returns the columns whose visibility the ALTER explicitly states, by their name on the
+// altered table, mapped to whether they are invisible
+func (this *AlterTableParser) ColumnVisibilityMap() map[string]bool {
+	return this.columnVisibility
+}
+
 func (this *AlterTableParser) IsRenameTable() bool {
 	return this.isRenameTable
 }
@@ -187,6 +283,10 @@ func (this *AlterTableParser) IsAutoIncrementDefined() bool {
 	return this.isAutoIncrementDefined
 }
 
+func (this *AlterTableParser) HasForeignKeyChanges() bool {
+	return this.hasForeignKeyChanges
+}
+
 func (this *AlterTableParser) GetExplicitSchema() string {
 	return this.explicitSchema
 }
@@ -213,3 +313,74 @@ func ParseEnumValues(enumColumnType string) string {
 	}
 	return enumColumnType
 }
+
+// ParseEnumLabels splits the values of an ENUM or SET definition, as returned by ParseEnumValues,
+// into their unquoted labels, in definition order
+func ParseEnumLabels(enumValues string) (labels []string) {
+	var label strings.Builder
+	quoted := false
+	for i := 0; i < len(enumValues); i++ {
+		c := enumValues[i]
+		switch {
+		case c == '\'' && quoted && i+1 < len(enumValues) && enumValues[i+1] == '\'':
+			// Quotes within a label are doubled
+			label.WriteByte(c)
+			i++
+		case c == '\'':
+			quoted = !quoted
+			if !quoted {
+				labels = append(labels, label.String())
+				label.Reset()
+			}
+		case quoted:
+			label.WriteByte(c)
+		}
+	}
+	return labels
+}
+
+// parseCreateTableOptions returns the table options of a SHOW CREATE TABLE statement: what follows the
+// closing parenthesis of the column & index definitions, including any partitioning clause. Quoted strings,
+// such as COMMENTs, and quoted identifiers are left out, as are parenthesized partition definitions, such
+// that neither their content nor their parentheses are taken for table options.
+func parseCreateTableOptions(createTableStatement string) string {
+	var tableOptions strings.Builder
+	var quote byte
+	depth := 0
+	definitionsClosed := false
+	for i := 0; i < len(createTableStatement); i++ {
+		c := createTableStatement[i]
+		switch {
+		case quote != 0:
+			// Quotes within quoted strings and identifiers are doubled, or else escaped within strings
+			if c == '\\' && quote != '`' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				definitionsClosed = true
+			}
+		case definitionsClosed && depth == 0:
+			tableOptions.WriteByte(c)
+		}
+	}
+	return tableOptions.String()
+}
+
+// ParseCreateTableAutoIncrement returns the AUTO_INCREMENT table option of a SHOW CREATE TABLE
+// statement, or 0 when the statement has none (no AUTO_INCREMENT column, or the counter is still 1)
+func ParseCreateTableAutoIncrement(createTableStatement string) uint64 {
+	submatch := autoIncrementRegexp.FindStringSubmatch(parseCreateTableOptions(createTableStatement))
+	if len(submatch) == 0 {
+		return 0
+	}
+	autoIncrement, _ := strconv.ParseUint(submatch[1], 10, 64)
+	return autoIncrement
+}
@@ -229,6 +229,25 @@ func TestParseAlterStatementRenameTable(t *testing.T) {
 	}
 }
 
+func TestParseAlterStatementForeignKeyChanges(t *testing.T) {
+	{
+		parser := NewParserFromAlterStatement("add column parent_id int, add key parent_idx (parent_id)")
+		test.S(t).ExpectFalse(parser.HasForeignKeyChanges())
+	}
+	{
+		parser := NewParserFromAlterStatement("add column c varchar(64) default 'foreign key'")
+		test.S(t).ExpectFalse(parser.HasForeignKeyChanges())
+	}
+	{
+		parser := NewParserFromAlterStatement("add constraint fk_parent foreign key (parent_id) references parent (id)")
+		test.S(t).ExpectTrue(parser.HasForeignKeyChanges())
+	}
+	{
+		parser := NewParserFromAlterStatement("engine=innodb, DROP FOREIGN KEY fk_parent")
+		test.S(t).ExpectTrue(parser.HasForeignKeyChanges())
+	}
+}
+
 func TestParseAlterStatementExplicitTable(t *testing.T) {
 
 	{
@@ -323,6 +342,23 @@ func TestParseAlterStatementExplicitTable(t *testing.T) {
 	}
 }
 
+func TestParseAlterStatementColumnVisibility(t *testing.T) {
+	{
+		parser := NewAlterTableParser()
+		statement := "alter column a set invisible, ALTER `b` SET VISIBLE, modify column c int not null invisible, change d `e` varchar(32) visible comment 'invisible'"
+		err := parser.ParseAlterStatement(statement)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectTrue(reflect.DeepEqual(parser.ColumnVisibilityMap(), map[string]bool{"a": true, "b": false, "c": true, "e": false}))
+	}
+	{
+		parser := NewAlterTableParser()
+		statement := "modify column c int not null, alter column d set default 'invisible', add column f int invisible"
+		err := parser.ParseAlterStatement(statement)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(len(parser.ColumnVisibilityMap()), 0)
+	}
+}
+
 func TestParseEnumValues(t *testing.T) {
 	{
 		s := "enum('red','green','blue','orange')"
@@ -339,4 +375,97 @@ func TestParseEnumValues(t *testing.T) {
 		values := ParseEnumValues(s)
 		test.S(t).ExpectEquals(values, "zzz")
 	}
+	{
+		s := "set('a','b','c')"
+		values := ParseEnumValues(s)
+		test.S(t).ExpectEquals(values, "'a','b','c'")
+	}
+}
+
+func TestParseEnumLabels(t *testing.T) {
+	test.S(t).ExpectTrue(reflect.DeepEqual(ParseEnumLabels("'red','green','blue'"), []string{"red", "green", "blue"}))
+	test.S(t).ExpectTrue(reflect.DeepEqual(ParseEnumLabels(ParseEnumValues("set('a,b','it''s','')")), []string{"a,b", "it's", ""}))
+	test.S(t).ExpectEquals(len(ParseEnumLabels("")), 0)
+}
+
+func TestParseDDLStatementTables(t *testing.T) {
+	{
+		ddl, tables := ParseDDLStatementTables("alter table t add column i int")
+		test.S(t).ExpectEquals(ddl, "ALTER")
+		test.S(t).ExpectEquals(len(tables), 1)
+		test.S(t).ExpectEquals(tables[0], DDLTable{Name: "t"})
+	}
+	{
+		ddl, tables := ParseDDLStatementTables("ALTER /* some comment */ ONLINE TABLE `scm`.`tbl` engine=innodb")
+		test.S(t).ExpectEquals(ddl, "ALTER")
+		test.S(t).ExpectEquals(len(tables), 1)
+		test.S(t).ExpectEquals(tables[0], DDLTable{Schema: "scm", Name: "tbl"})
+	}
+	{
+		ddl, tables := ParseDDLStatementTables("truncate tbl")
+		test.S(t).ExpectEquals(ddl, "TRUNCATE")
+		test.S(t).ExpectEquals(len(tables), 1)
+		test.S(t).ExpectEquals(tables[0], DDLTable{Name: "tbl"})
+	}
+	{
+		ddl, tables := ParseDDLStatementTables("DROP TABLE IF EXISTS `t1`,scm.t2 /* generated by server */")
+		test.S(t).ExpectEquals(ddl, "DROP")
+		test.S(t).ExpectEquals(len(tables), 2)
+		test.S(t).ExpectEquals(tables[0], DDLTable{Name: "t1"})
+		test.S(t).ExpectEquals(tables[1], DDLTable{Schema: "scm", Name: "t2"})
+	}
+	{
+		ddl, tables := ParseDDLStatementTables("rename table t1 to t2, `scm`.t3 to t4")
+		test.S(t).ExpectEquals(ddl, "RENAME")
+		test.S(t).ExpectEquals(len(tables), 4)
+		test.S(t).ExpectEquals(tables[0], DDLTable{Name: "t1"})
+		test.S(t).ExpectEquals(tables[1], DDLTable{Name: "t2"})
+		test.S(t).ExpectEquals(tables[2], DDLTable{Schema: "scm", Name: "t3"})
+		test.S(t).ExpectEquals(tables[3], DDLTable{Name: "t4"})
+	}
+	{
+		ddl, tables := ParseDDLStatementTables("drop view v")
+		test.S(t).ExpectEquals(ddl, "")
+		test.S(t).ExpectEquals(len(tables), 0)
+	}
+	{
+		ddl, tables := ParseDDLStatementTables("BEGIN")
+		test.S(t).ExpectEquals(ddl, "")
+		test.S(t).ExpectEquals(len(tables), 0)
+	}
+}
+
+func TestParseCreateTableAutoIncrement(t *testing.T) {
+	{
+		statement := "CREATE TABLE `tbl` (\n  `id` int NOT NULL AUTO_INCREMENT,\n  `i` int DEFAULT NULL,\n  PRIMARY KEY (`id`)\n) ENGINE=InnoDB AUTO_INCREMENT=1043 DEFAULT CHARSET=utf8mb4"
+		test.S(t).ExpectEquals(ParseCreateTableAutoIncrement(statement), uint64(1043))
+	}
+	{
+		statement := "CREATE TABLE `tbl` (\n  `id` int NOT NULL AUTO_INCREMENT,\n  PRIMARY KEY (`id`)\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"
+		test.S(t).ExpectEquals(ParseCreateTableAutoIncrement(statement), uint64(0))
+	}
+	{
+		statement := "CREATE TABLE `tbl` (\n  `id` int NOT NULL COMMENT 'auto_increment=7',\n  PRIMARY KEY (`id`)\n) ENGINE=InnoDB"
+		test.S(t).ExpectEquals(ParseCreateTableAutoIncrement(statement), uint64(0))
+	}
+	{
+		statement := "CREATE TABLE `tbl` (\n  `id` int NOT NULL AUTO_INCREMENT,\n  PRIMARY KEY (`id`)\n) ENGINE=InnoDB AUTO_INCREMENT=1043 DEFAULT CHARSET=utf8mb4\n/*!50100 PARTITION BY RANGE (`id`)\n(PARTITION p0 VALUES LESS THAN (1000) ENGINE = InnoDB,\n PARTITION p1 VALUES LESS THAN MAXVALUE ENGINE = InnoDB) */"
+		test.S(t).ExpectEquals(ParseCreateTableAutoIncrement(statement), uint64(1043))
+	}
+	{
+		statement := "CREATE TABLE `tbl` (\n  `id` int NOT NULL AUTO_INCREMENT,\n  PRIMARY KEY (`id`)\n) ENGINE=InnoDB AUTO_INCREMENT=1043 DEFAULT CHARSET=utf8mb4\n/*!50100 PARTITION BY HASH (`id`)\nPARTITIONS 4 */"
+		test.S(t).ExpectEquals(ParseCreateTableAutoIncrement(statement), uint64(1043))
+	}
+	{
+		statement := "CREATE TABLE `tbl` (\n  `id` int NOT NULL AUTO_INCREMENT,\n  PRIMARY KEY (`id`)\n) ENGINE=InnoDB AUTO_INCREMENT=1043 DEFAULT CHARSET=utf8mb4 COMMENT='ids (as in the old schema) AUTO_INCREMENT=5'"
+		test.S(t).ExpectEquals(ParseCreateTableAutoIncrement(statement), uint64(1043))
+	}
+	{
+		statement := "CREATE TABLE `tbl (old)` (\n  `id` int NOT NULL AUTO_INCREMENT COMMENT 'it''s (still) \\' the id)',\n  `v` varchar(10) DEFAULT ')',\n  PRIMARY KEY (`id`)\n) ENGINE=InnoDB AUTO_INCREMENT=7"
+		test.S(t).ExpectEquals(ParseCreateTableAutoIncrement(statement), uint64(7))
+	}
+	{
+		statement := "CREATE TABLE `tbl` (\n  `id` int NOT NULL AUTO_INCREMENT,\n  PRIMARY KEY (`id`)\n) ENGINE=InnoDB COMMENT='(AUTO_INCREMENT=5)'\n/*!50100 PARTITION BY RANGE (`id`)\n(PARTITION p0 VALUES LESS THAN (10) COMMENT = 'AUTO_INCREMENT=9)' ENGINE = InnoDB) */"
+		test.S(t).ExpectEquals(ParseCreateTableAutoIncrement(statement), uint64(0))
+	}
 }
@@ -9,8 +9,10 @@ import (
 	"bytes"
 	"fmt"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 type ColumnType int
@@ -24,33 +26,233 @@ const (
 	JSONColumnType
 	FloatColumnType
 	BinaryColumnType
+	GeometryColumnType
+	SetColumnType
+	BitColumnType
 )
 
 const maxMediumintUnsigned int32 = 16777215
 
+// GeneratedColumnType tells whether a column is generated, and whether its values are stored
+type GeneratedColumnType int
+
+const (
+	NotGeneratedColumn GeneratedColumnType = iota
+	VirtualGeneratedColumn
+	StoredGeneratedColumn
+)
+
+// ParseGeneratedColumnType parses a column's `EXTRA` attribute, as in information_schema.columns
+func ParseGeneratedColumnType(extra string) GeneratedColumnType {
+	switch {
+	case strings.Contains(strings.ToUpper(extra), "VIRTUAL GENERATED"):
+		return VirtualGeneratedColumn
+	case strings.Contains(strings.ToUpper(extra), "STORED GENERATED"):
+		return StoredGeneratedColumn
+	}
+	return NotGeneratedColumn
+}
+
+// TimezoneConversion describes a column converted between DATETIME and TIMESTAMP. TIMESTAMP values
+// are read and written in the sessions' time zone (UTC, unless --connection-time-zone says otherwise);
+// DATETIME values are taken as wall clock time in the applier's time zone.
 type TimezoneConversion struct {
-	ToTimezone string
+	FromTimezone string
+	ToTimezone   string
+}
+
+// CharsetConversion describes a string column whose charset changes as part of the migration
+type CharsetConversion struct {
+	FromCharset string
+	ToCharset   string
+}
+
+// GeometrySRIDConversion describes a spatial column restricted to an SRID on the ghost table, but
+// not (or to another SRID) on the original table. Values keep their coordinates and take on the SRID.
+type GeometrySRIDConversion struct {
+	ToSRID uint32
+}
+
+// TypeConversion describes a column whose type changes as part of the migration such that binlog values,
+// which hold MySQL's internal representation, differ from the values a cast gives: ENUM indexes, SET
+// bitmasks and BIT values. The row copy casts values on the server, and needs no conversion.
+type TypeConversion struct {
+	FromType ColumnType
+	ToText   bool
+	labels   []string // ENUM or SET members, in definition order
+	bitBytes int      // bytes of a BIT value as a binary string
+}
+
+// NewTypeConversion returns the conversion of binlog values of given original column into values of given
+// ghost column, or nil when the binlog values apply as they are
+func NewTypeConversion(fromColumn, toColumn *Column) *TypeConversion {
+	if fromColumn.MySQLType == toColumn.MySQLType {
+		return nil
+	}
+	// ENUM, SET and string columns all have a charset, and take ENUM & SET labels
+	toText := toColumn.Charset != ""
+	switch fromColumn.Type {
+	case EnumColumnType, SetColumnType:
+		if !toText {
+			// In numeric context, ENUM values are indexes and SET values are bitmasks, same as in the binlog
+			return nil
+		}
+		return &TypeConversion{FromType: fromColumn.Type, ToText: true, labels: ParseEnumLabels(fromColumn.EnumValues)}
+	case BitColumnType:
+		if toColumn.Type == BitColumnType {
+			return nil
+		}
+		return &TypeConversion{FromType: BitColumnType, ToText: toText, bitBytes: int(fromColumn.BitLength+7) / 8}
+	}
+	return nil
+}
+
+// convert converts a binlog value, which is given as int64 for ENUM, SET and BIT columns alike
+func (this *TypeConversion) convert(arg interface{}) (interface{}, bool) {
+	value, ok := arg.(int64)
+	if !ok {
+		return arg, false
+	}
+	switch this.FromType {
+	case EnumColumnType:
+		// Index 0 is the empty string, which MySQL stores for invalid values in non-strict mode
+		if value == 0 {
+			return "", true
+		}
+		if value > 0 && int(value) <= len(this.labels) {
+			return this.labels[value-1], true
+		}
+	case SetColumnType:
+		members := []string{}
+		for i, label := range this.labels {
+			if uint64(value)&(1<<uint(i)) != 0 {
+				members = append(members, label)
+			}
+		}
+		return strings.Join(members, ","), true
+	case BitColumnType:
+		if !this.ToText {
+			// BIT(64) values at or above 2^63 are given as negative int64
+			return uint64(value), true
+		}
+		// As a string, a BIT value is a big-endian binary string of the column's width
+		buf := make([]byte, this.bitBytes)
+		for i := len(buf) - 1; i >= 0; i-- {
+			buf[i] = byte(value)
+			value = int64(uint64(value) >> 8)
+		}
+		return buf, true
+	}
+	return arg, false
+}
+
+// ZeroDateRewrite describes zero dates (e.g. '0000-00-00') and dates with zero parts (e.g. '2020-00-15')
+// rewritten into NULL or into a given value, as per --zero-date-rewrite
+type ZeroDateRewrite struct {
+	ToNull bool
+	Value  string
+}
+
+var (
+	zeroDateRewriteValueRegexp = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}( [0-9]{2}:[0-9]{2}:[0-9]{2}([.][0-9]{1,6})?)?$`)
+	zeroDateValueRegexp        = regexp.MustCompile(`^[0-9]{4}-([0-9]{2})-([0-9]{2})`)
+)
+
+// ParseZeroDateRewrite parses a --zero-date-rewrite value: either NULL, or a date or datetime literal
+func ParseZeroDateRewrite(value string) (*ZeroDateRewrite, error) {
+	if strings.EqualFold(value, "NULL") {
+		return &ZeroDateRewrite{ToNull: true}, nil
+	}
+	if !zeroDateRewriteValueRegexp.MatchString(value) {
+		return nil, fmt.Errorf("Invalid zero date rewrite: %s. Expected NULL, or a value such as '1970-01-01' or '1970-01-01 00:00:00'", value)
+	}
+	if IsZeroDateValue(value) {
+		return nil, fmt.Errorf("Invalid zero date rewrite: %s is itself a zero date", value)
+	}
+	return &ZeroDateRewrite{Value: value}, nil
+}
+
+// IsZeroDateValue tells whether given value, as read from the binary log, is a zero date or has a zero month or day
+func IsZeroDateValue(value string) bool {
+	submatch := zeroDateValueRegexp.FindStringSubmatch(value)
+	if submatch == nil {
+		return false
+	}
+	return submatch[1] == "00" || submatch[2] == "00"
+}
+
+// rewrittenArg returns the value a zero date is rewritten into
+func (this *ZeroDateRewrite) rewrittenArg() interface{} {
+	if this.ToNull {
+		return nil
+	}
+	return this.Value
+}
+
+// rewrittenLiteral returns the SQL literal a zero date is rewritten into
+func (this *ZeroDateRewrite) rewrittenLiteral() string {
+	if this.ToNull {
+		return "NULL"
+	}
+	return fmt.Sprintf("'%s'", this.Value)
+}
+
+func (this *ZeroDateRewrite) String() string {
+	return this.rewrittenLiteral()
 }
 
 type Column struct {
-	Name                 string
-	IsUnsigned           bool
-	Charset              string
-	Type                 ColumnType
-	EnumValues           string
-	timezoneConversion   *TimezoneConversion
-	enumToTextConversion bool
+	Name                   string
+	IsUnsigned             bool
+	Charset                string
+	Type                   ColumnType
+	MySQLType              string
+	EnumValues             string
+	Generated              GeneratedColumnType
+	Nullable               bool
+	Invisible              bool // per MySQL 8.0.23 INVISIBLE attribute
+	Descending             bool // as a unique key column: a DESC key part (MySQL 8.0+), sorting greater values first
+	HasSRID                bool // spatial column restricted to SRID, per MySQL 8.0 SRID attribute
+	SRID                   uint32
+	geometrySRIDConversion *GeometrySRIDConversion
+	zeroDateRewrite        *ZeroDateRewrite
+	timezoneConversion     *TimezoneConversion
+	charsetConversion      *CharsetConversion
+	// serverCharsetConversion is set on ghost columns taking values which gh-ost cannot decode
+	serverCharsetConversion *CharsetConversion
+	typeConversion          *TypeConversion
 	// add Octet length for binary type, fix bytes with suffix "00" get clipped in mysql binlog.
 	// https://github.com/github/gh-ost/issues/909
 	BinaryOctetLength uint
+	// BitLength is the number of bits of a BIT column
+	BitLength uint
+}
+
+// IsGenerated tells whether the column's values are computed by the server, and cannot be written
+func (this *Column) IsGenerated() bool {
+	return this.Generated != NotGeneratedColumn
 }
 
 func (this *Column) convertArg(arg interface{}, isUniqueKeyColumn bool) interface{} {
+	if this.typeConversion != nil {
+		if converted, ok := this.typeConversion.convert(arg); ok {
+			return converted
+		}
+	}
+	if s, ok := arg.(string); ok && this.zeroDateRewrite != nil && IsZeroDateValue(s) {
+		return this.zeroDateRewrite.rewrittenArg()
+	}
+	if b, ok := arg.([]byte); ok && this.charsetConversion != nil {
+		// TEXT values are read from the binlog as bytes
+		arg = string(b)
+	}
 	if s, ok := arg.(string); ok {
-		// string, charset conversion
-		if encoding, ok := charsetEncodingMap[this.Charset]; ok {
-			arg, _ = encoding.NewDecoder().String(s)
+		if this.charsetConversion != nil && !IsDecodableCharset(this.Charset) {
+			// Given as a binary string, the value is converted by the server (see SetServerCharsetConversion)
+			return []byte(s)
 		}
+		// string, charset conversion
+		arg = this.decodeString(s)
 
 		if this.Type == BinaryColumnType && isUniqueKeyColumn {
 			arg2Bytes := []byte(arg.(string))
@@ -86,7 +288,9 @@ func (this *Column) convertArg(arg interface{}, isUniqueKeyColumn bool) interfac
 			return uint32(i)
 		}
 		if i, ok := arg.(int64); ok {
-			return strconv.FormatUint(uint64(i), 10)
+			// Given as uint64, BIGINT UNSIGNED values above 2^63 compare exactly; as strings, they would
+			// compare as floating point numbers, and match neighbouring values
+			return uint64(i)
 		}
 		if i, ok := arg.(int); ok {
 			return uint(i)
@@ -95,6 +299,67 @@ func (this *Column) convertArg(arg interface{}, isUniqueKeyColumn bool) interfac
 	return arg
 }
 
+// rangeArg converts a unique key value, as scanned off the database, into a query argument comparing
+// exactly to the column's values. The driver scans BIGINT UNSIGNED values above 2^63 as strings, and
+// text protocol results are all bytes; compared to an integer column as strings, they compare as
+// floating point numbers. Unsigned integer values are thus given as uint64.
+func (this *Column) rangeArg(arg interface{}) interface{} {
+	if !this.IsUnsigned {
+		return arg
+	}
+	switch value := arg.(type) {
+	case []byte:
+		if i, err := strconv.ParseUint(string(value), 10, 64); err == nil {
+			return i
+		}
+	case string:
+		if i, err := strconv.ParseUint(value, 10, 64); err == nil {
+			return i
+		}
+	case int64:
+		return uint64(value)
+	}
+	return arg
+}
+
+// rangeArgs converts unique key values into query arguments, see rangeArg
+func (this *ColumnList) rangeArgs(args []interface{}) []interface{} {
+	if len(args) != this.Len() {
+		// Mismatch is reported by the query builders
+		return args
+	}
+	converted := make([]interface{}, len(args))
+	for i, column := range this.columns {
+		converted[i] = column.rangeArg(args[i])
+	}
+	return converted
+}
+
+// decodeString converts a string value, as read from the binlog in the column's raw charset,
+// into a UTF-8 string that the applier's utf8mb4 connection hands over to the server, which in
+// turn converts it into the ghost column's charset.
+func (this *Column) decodeString(s string) string {
+	toCharset := this.Charset
+	if this.charsetConversion != nil {
+		toCharset = this.charsetConversion.ToCharset
+	}
+	if toCharset == binaryCharset {
+		// Target column is binary: write the raw bytes, same as the row copy does
+		return s
+	}
+	fromCharset := this.Charset
+	if fromCharset == binaryCharset {
+		// Raw bytes into a text column are taken to be in the target charset
+		fromCharset = toCharset
+	}
+	if encoding, ok := charsetEncodingMap[fromCharset]; ok {
+		if decoded, err := encoding.NewDecoder().String(s); err == nil {
+			return decoded
+		}
+	}
+	return s
+}
+
 func NewColumns(names []string) []Column {
 	result := make([]Column, len(names))
 	for i := range names {
@@ -175,6 +440,10 @@ func (this *ColumnList) IsUnsigned(columnName string) bool {
 	return this.GetColumn(columnName).IsUnsigned
 }
 
+func (this *ColumnList) SetDescending(columnName string) {
+	this.GetColumn(columnName).Descending = true
+}
+
 func (this *ColumnList) SetCharset(columnName string, charset string) {
 	this.GetColumn(columnName).Charset = charset
 }
@@ -191,20 +460,64 @@ func (this *ColumnList) GetColumnType(columnName string) ColumnType {
 	return this.GetColumn(columnName).Type
 }
 
-func (this *ColumnList) SetConvertDatetimeToTimestamp(columnName string, toTimezone string) {
-	this.GetColumn(columnName).timezoneConversion = &TimezoneConversion{ToTimezone: toTimezone}
+func (this *ColumnList) SetGenerated(columnName string, generated GeneratedColumnType) {
+	this.GetColumn(columnName).Generated = generated
+}
+
+func (this *ColumnList) IsGenerated(columnName string) bool {
+	return this.GetColumn(columnName).IsGenerated()
+}
+
+func (this *ColumnList) SetConvertDatetimeToTimestamp(columnName string, timezone string, sessionTimezone string) {
+	this.GetColumn(columnName).timezoneConversion = &TimezoneConversion{FromTimezone: timezone, ToTimezone: sessionTimezone}
+}
+
+func (this *ColumnList) SetConvertTimestampToDatetime(columnName string, timezone string, sessionTimezone string) {
+	this.GetColumn(columnName).timezoneConversion = &TimezoneConversion{FromTimezone: sessionTimezone, ToTimezone: timezone}
 }
 
 func (this *ColumnList) HasTimezoneConversion(columnName string) bool {
 	return this.GetColumn(columnName).timezoneConversion != nil
 }
 
-func (this *ColumnList) SetEnumToTextConversion(columnName string) {
-	this.GetColumn(columnName).enumToTextConversion = true
+func (this *ColumnList) SetCharsetConversion(columnName string, toCharset string) {
+	column := this.GetColumn(columnName)
+	column.charsetConversion = &CharsetConversion{FromCharset: column.Charset, ToCharset: toCharset}
+}
+
+// SetServerCharsetConversion has values written onto given ghost column converted by the server from given
+// charset, which gh-ost cannot decode
+func (this *ColumnList) SetServerCharsetConversion(columnName string, fromCharset string) {
+	column := this.GetColumn(columnName)
+	column.serverCharsetConversion = &CharsetConversion{FromCharset: fromCharset, ToCharset: column.Charset}
+}
+
+func (this *ColumnList) HasCharsetConversion(columnName string) bool {
+	return this.GetColumn(columnName).charsetConversion != nil
+}
+
+func (this *ColumnList) SetGeometrySRIDConversion(columnName string, srid uint32) {
+	this.GetColumn(columnName).geometrySRIDConversion = &GeometrySRIDConversion{ToSRID: srid}
+}
+
+func (this *ColumnList) HasGeometrySRIDConversion(columnName string) bool {
+	return this.GetColumn(columnName).geometrySRIDConversion != nil
+}
+
+func (this *ColumnList) SetZeroDateRewrite(columnName string, rewrite *ZeroDateRewrite) {
+	this.GetColumn(columnName).zeroDateRewrite = rewrite
+}
+
+func (this *ColumnList) HasZeroDateRewrite(columnName string) bool {
+	return this.GetColumn(columnName).zeroDateRewrite != nil
 }
 
-func (this *ColumnList) IsEnumToTextConversion(columnName string) bool {
-	return this.GetColumn(columnName).enumToTextConversion
+func (this *ColumnList) SetTypeConversion(columnName string, conversion *TypeConversion) {
+	this.GetColumn(columnName).typeConversion = conversion
+}
+
+func (this *ColumnList) HasTypeConversion(columnName string) bool {
+	return this.GetColumn(columnName).typeConversion != nil
 }
 
 func (this *ColumnList) SetEnumValues(columnName string, enumValues string) {
@@ -244,6 +557,59 @@ type UniqueKey struct {
 	Columns         ColumnList
 	HasNullable     bool
 	IsAutoIncrement bool
+	// Expressions are the key's functional key parts (MySQL 8.0.13+), e.g. "lower(`email`)", in index order.
+	// Columns only lists the key's column parts.
+	Expressions []string
+	// ExpressionPositions are the positions of Expressions among all of the key's parts (SEQ_IN_INDEX, from 1)
+	ExpressionPositions []int
+}
+
+// IsFunctional checks if this unique key has functional key parts. Row copy cannot iterate such a key.
+func (this *UniqueKey) IsFunctional() bool {
+	return len(this.Expressions) > 0
+}
+
+// EqualsByParts checks if this and given unique keys have the same columns, in the same key part directions,
+// and the same functional key parts, at the same positions among the key parts: (a, (lower(b))) does not equal
+// ((lower(b)), a)
+func (this *UniqueKey) EqualsByParts(other *UniqueKey) bool {
+	if !this.Columns.EqualsByNames(&other.Columns) {
+		return false
+	}
+	otherColumns := other.Columns.Columns()
+	for i, column := range this.Columns.Columns() {
+		if column.Descending != otherColumns[i].Descending {
+			return false
+		}
+	}
+	if !reflect.DeepEqual(this.Expressions, other.Expressions) {
+		return false
+	}
+	for i := range this.Expressions {
+		if this.expressionPosition(i) != other.expressionPosition(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// expressionPosition returns the position among the key parts of the functional key part of given index, or 0
+// when unknown
+func (this *UniqueKey) expressionPosition(i int) int {
+	if i < len(this.ExpressionPositions) {
+		return this.ExpressionPositions[i]
+	}
+	return 0
+}
+
+// DescendingColumnNames returns the names of this unique key's DESC key parts, in index order
+func (this *UniqueKey) DescendingColumnNames() (names []string) {
+	for _, column := range this.Columns.Columns() {
+		if column.Descending {
+			names = append(names, column.Name)
+		}
+	}
+	return names
 }
 
 // IsPrimary checks if this unique key is primary
@@ -260,12 +626,193 @@ func (this *UniqueKey) String() string {
 	if this.IsAutoIncrement {
 		description = fmt.Sprintf("%s (auto_increment)", description)
 	}
+	if this.IsFunctional() {
+		description = fmt.Sprintf("%s (functional: %s)", description, strings.Join(this.Expressions, ", "))
+	}
+	if descendingColumnNames := this.DescendingColumnNames(); len(descendingColumnNames) > 0 {
+		description = fmt.Sprintf("%s (descending: %s)", description, strings.Join(descendingColumnNames, ", "))
+	}
 	return fmt.Sprintf("%s: %s; has nullable: %+v", description, this.Columns.Names(), this.HasNullable)
 }
 
+// MaxForeignKeyNameLength is the maximum length of a constraint name in MySQL
+const MaxForeignKeyNameLength = 64
+
+// ForeignKey is a constraint referencing a parent table from a child table, along with its
+// referential actions
+type ForeignKey struct {
+	Name              string
+	Columns           ColumnList
+	ReferencedSchema  string
+	ReferencedTable   string
+	ReferencedColumns ColumnList
+	UpdateRule        string
+	DeleteRule        string
+}
+
+// GhostName returns the name of this constraint on the ghost table. Constraint names are unique
+// per schema, and so the ghost table's constraints are named by toggling a leading underscore: a
+// table migrated twice ends up with its original constraint names.
+func (this *ForeignKey) GhostName() string {
+	if strings.HasPrefix(this.Name, "_") {
+		return strings.TrimPrefix(this.Name, "_")
+	}
+	return "_" + this.Name
+}
+
+// HasCascadingRules checks if this constraint has referential actions other than RESTRICT or
+// NO ACTION. Changes cascaded onto a child table are not written to the binary logs.
+func (this *ForeignKey) HasCascadingRules() bool {
+	for _, rule := range []string{this.UpdateRule, this.DeleteRule} {
+		switch strings.ToUpper(rule) {
+		case "RESTRICT", "NO ACTION":
+			continue
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+func (this *ForeignKey) String() string {
+	return fmt.Sprintf("%s: %s references %s.%s %s; on update %s; on delete %s", this.Name, this.Columns.Names(), this.ReferencedSchema, this.ReferencedTable, this.ReferencedColumns.Names(), this.UpdateRule, this.DeleteRule)
+}
+
+// checkClauseIdentifierRegexp matches the quoted identifiers of a CHECK constraint's clause, as normalized by
+// the server, e.g. "(`amount` > 0)"
+var checkClauseIdentifierRegexp = regexp.MustCompile("`((?:[^`]|``)+)`")
+
+// CheckConstraint is a table's CHECK constraint, as found in INFORMATION_SCHEMA.CHECK_CONSTRAINTS (MySQL 8.0.16+)
+type CheckConstraint struct {
+	Name     string
+	Clause   string
+	Enforced bool
+}
+
+// GhostName returns the name of this constraint on the ghost table. As with foreign keys, names are
+// toggled by a leading underscore: a table migrated twice ends up with its original constraint names.
+func (this *CheckConstraint) GhostName() string {
+	if strings.HasPrefix(this.Name, "_") {
+		return strings.TrimPrefix(this.Name, "_")
+	}
+	return "_" + this.Name
+}
+
+// HasGeneratedName checks if this constraint's name is one generated by the server for given table, e.g.
+// "tbl_chk_1". RENAME TABLE renames such constraints along with their table.
+func (this *CheckConstraint) HasGeneratedName(tableName string) bool {
+	generatedNameRegexp := regexp.MustCompile(fmt.Sprintf("^%s_chk_[0-9]+$", regexp.QuoteMeta(tableName)))
+	return generatedNameRegexp.MatchString(this.Name)
+}
+
+// ColumnNames returns the identifiers quoted in this constraint's clause, which are the columns it checks
+func (this *CheckConstraint) ColumnNames() (names []string) {
+	for _, submatch := range checkClauseIdentifierRegexp.FindAllStringSubmatch(this.Clause, -1) {
+		names = append(names, strings.ReplaceAll(submatch[1], "``", "`"))
+	}
+	return names
+}
+
+// MapClause returns this constraint's clause with its columns renamed per given map
+func (this *CheckConstraint) MapClause(columnRenameMap map[string]string) string {
+	return checkClauseIdentifierRegexp.ReplaceAllStringFunc(this.Clause, func(quoted string) string {
+		name := strings.ReplaceAll(quoted[1:len(quoted)-1], "``", "`")
+		if mappedName, ok := columnRenameMap[name]; ok {
+			return EscapeName(mappedName)
+		}
+		return quoted
+	})
+}
+
+func (this *CheckConstraint) String() string {
+	description := fmt.Sprintf("%s CHECK %s", EscapeName(this.Name), this.Clause)
+	if !this.Enforced {
+		description = fmt.Sprintf("%s NOT ENFORCED", description)
+	}
+	return description
+}
+
+// MaxTriggerNameLength is the maximum length of a trigger name in MySQL
+const MaxTriggerNameLength = 64
+
+// Trigger is the definition of a table trigger, as found in INFORMATION_SCHEMA.TRIGGERS
+type Trigger struct {
+	Name      string
+	Timing    string // BEFORE, AFTER
+	Event     string // INSERT, UPDATE, DELETE
+	Statement string
+	SQLMode   string
+}
+
+// GhostName returns the name of this trigger on the ghost table. Trigger names are unique per schema;
+// the trigger gets its original name back following cut-over.
+func (this *Trigger) GhostName() string {
+	return fmt.Sprintf("_%s_gho", this.Name)
+}
+
+// ReferencesTable checks if the trigger's body mentions the given table name, quoted or unquoted
+func (this *Trigger) ReferencesTable(tableName string) bool {
+	tableNameRegexp := regexp.MustCompile(fmt.Sprintf("(?i)(`%s`|\\b%s\\b)", regexp.QuoteMeta(tableName), regexp.QuoteMeta(tableName)))
+	return tableNameRegexp.MatchString(this.Statement)
+}
+
+func (this *Trigger) String() string {
+	return fmt.Sprintf("%s: %s %s", this.Name, this.Timing, this.Event)
+}
+
+// JSONDiffOperation is the operation of a partial JSON update, per binlog_row_value_options=PARTIAL_JSON
+type JSONDiffOperation int
+
+const (
+	JSONDiffReplace JSONDiffOperation = iota
+	JSONDiffInsert
+	JSONDiffRemove
+)
+
+// JSONDiff is a single change of a partial JSON update: Value, a JSON text, replaces the value at Path, or is
+// inserted at Path; or, the value at Path is removed
+type JSONDiff struct {
+	Operation JSONDiffOperation
+	Path      string
+	Value     string
+}
+
+// JSONDiffs is the value of a JSON column in the after image of a partial JSON update: the changes to apply,
+// in order, onto the column's before value
+type JSONDiffs []JSONDiff
+
+// Expression returns the expression applying these changes onto given JSON document, along with its args.
+// Insertions into arrays shift the following elements, as per JSON_ARRAY_INSERT; other insertions and
+// replacements are as per JSON_SET.
+func (this JSONDiffs) Expression(document string) (expression string, args []interface{}) {
+	expression = document
+	for _, diff := range this {
+		switch {
+		case diff.Operation == JSONDiffRemove:
+			expression = fmt.Sprintf("json_remove(%s, ?)", expression)
+			args = append(args, diff.Path)
+		case diff.Operation == JSONDiffInsert && strings.HasSuffix(diff.Path, "]"):
+			expression = fmt.Sprintf("json_array_insert(%s, ?, cast(convert(? using utf8mb4) as json))", expression)
+			args = append(args, diff.Path, diff.Value)
+		default:
+			expression = fmt.Sprintf("json_set(%s, ?, cast(convert(? using utf8mb4) as json))", expression)
+			args = append(args, diff.Path, diff.Value)
+		}
+	}
+	return expression, args
+}
+
+var columnValuesPool = sync.Pool{
+	New: func() interface{} {
+		return &ColumnValues{}
+	},
+}
+
 type ColumnValues struct {
 	abstractValues []interface{}
 	ValuesPointers []interface{}
+	// presentColumns is nil when all columns are present, as is the case with binlog_row_image=FULL
+	presentColumns []bool
 }
 
 func NewColumnValues(length int) *ColumnValues {
@@ -292,10 +839,161 @@ func ToColumnValues(abstractValues []interface{}) *ColumnValues {
 	return result
 }
 
+// ToPartialColumnValues creates values of a partial row image (binlog_row_image=MINIMAL), in which
+// only some of the columns are present
+func ToPartialColumnValues(abstractValues []interface{}, presentColumns []bool) *ColumnValues {
+	result := ToColumnValues(abstractValues)
+	result.presentColumns = presentColumns
+	return result
+}
+
+// WrapColumnValues creates column values over given slice, without copying it, and without
+// value pointers; it is meant for values which are only ever read, such as binlog row images.
+// presentColumns is optional, see ToPartialColumnValues. Values created this way are taken
+// from a pool, to which they may be returned via Release().
+func WrapColumnValues(abstractValues []interface{}, presentColumns []bool) *ColumnValues {
+	result := columnValuesPool.Get().(*ColumnValues)
+	result.abstractValues = abstractValues
+	result.presentColumns = presentColumns
+	return result
+}
+
+// Release returns these values to the pool. The values must not be used afterwards.
+func (this *ColumnValues) Release() {
+	*this = ColumnValues{}
+	columnValuesPool.Put(this)
+}
+
 func (this *ColumnValues) AbstractValues() []interface{} {
 	return this.abstractValues
 }
 
+// ApplyUnsigned converts values of given unsigned integer columns into uint64 (see rangeArg), such that
+// unique key range values keep their unsignedness across row copy iterations, checkpoints and logs
+func (this *ColumnValues) ApplyUnsigned(columns *ColumnList) {
+	if columns.Len() != len(this.abstractValues) {
+		return
+	}
+	for i, column := range columns.Columns() {
+		this.abstractValues[i] = column.rangeArg(this.abstractValues[i])
+	}
+}
+
+// IsPartial returns true when some columns are absent from these values
+func (this *ColumnValues) IsPartial() bool {
+	return this.presentColumns != nil
+}
+
+// IsPresent returns true when the column at given ordinal is present in these values
+func (this *ColumnValues) IsPresent(index int) bool {
+	if this.presentColumns == nil {
+		return true
+	}
+	return index < len(this.presentColumns) && this.presentColumns[index]
+}
+
+// MergeAbsentFrom fills in columns absent from these values with the values present in other.
+// With a minimal row image, an UPDATE's after image lacks unchanged columns, which in turn may
+// be found in the before image.
+func (this *ColumnValues) MergeAbsentFrom(other *ColumnValues) {
+	if !this.IsPartial() {
+		return
+	}
+	for i := range this.abstractValues {
+		if !this.IsPresent(i) && other.IsPresent(i) {
+			this.abstractValues[i] = other.abstractValues[i]
+			this.presentColumns[i] = true
+		}
+	}
+}
+
+// filterWritableColumns returns the subsets of given shared columns, and of their mapped counterparts,
+// which are not generated on the ghost table. Generated columns may still serve in unique keys.
+func filterWritableColumns(sharedColumns, mappedSharedColumns *ColumnList) (writableSharedColumns, writableMappedSharedColumns *ColumnList) {
+	hasGeneratedColumns := false
+	for _, column := range mappedSharedColumns.Columns() {
+		if column.IsGenerated() {
+			hasGeneratedColumns = true
+			break
+		}
+	}
+	if !hasGeneratedColumns {
+		return sharedColumns, mappedSharedColumns
+	}
+	sharedColumnsSlice := []Column{}
+	mappedSharedColumnsSlice := []Column{}
+	for i, mappedColumn := range mappedSharedColumns.Columns() {
+		if mappedColumn.IsGenerated() {
+			continue
+		}
+		sharedColumnsSlice = append(sharedColumnsSlice, sharedColumns.Columns()[i])
+		mappedSharedColumnsSlice = append(mappedSharedColumnsSlice, mappedColumn)
+	}
+	writableSharedColumns = &ColumnList{columns: sharedColumnsSlice, Ordinals: NewColumnsMap(sharedColumnsSlice)}
+	writableMappedSharedColumns = &ColumnList{columns: mappedSharedColumnsSlice, Ordinals: NewColumnsMap(mappedSharedColumnsSlice)}
+	return writableSharedColumns, writableMappedSharedColumns
+}
+
+// FilterPresentColumns returns the subsets of given shared columns, and of their mapped counterparts,
+// which are present in given values
+func FilterPresentColumns(tableColumns, sharedColumns, mappedSharedColumns *ColumnList, values *ColumnValues) (presentSharedColumns, presentMappedSharedColumns *ColumnList) {
+	if !values.IsPartial() {
+		return sharedColumns, mappedSharedColumns
+	}
+	sharedColumnsSlice := []Column{}
+	mappedSharedColumnsSlice := []Column{}
+	for i, column := range sharedColumns.Columns() {
+		if !values.IsPresent(tableColumns.Ordinals[column.Name]) {
+			continue
+		}
+		sharedColumnsSlice = append(sharedColumnsSlice, column)
+		mappedSharedColumnsSlice = append(mappedSharedColumnsSlice, mappedSharedColumns.Columns()[i])
+	}
+	presentSharedColumns = &ColumnList{columns: sharedColumnsSlice, Ordinals: NewColumnsMap(sharedColumnsSlice)}
+	presentMappedSharedColumns = &ColumnList{columns: mappedSharedColumnsSlice, Ordinals: NewColumnsMap(mappedSharedColumnsSlice)}
+	return presentSharedColumns, presentMappedSharedColumns
+}
+
+// FilterChangedColumns returns the subsets of given shared columns, and of their mapped counterparts,
+// whose values differ between given before and after images of an updated row. Unique key columns,
+// which identify the row, are always included.
+func FilterChangedColumns(tableColumns, sharedColumns, mappedSharedColumns, uniqueKeyColumns *ColumnList, whereValues, newValues *ColumnValues) (changedSharedColumns, changedMappedSharedColumns *ColumnList) {
+	sharedColumnsSlice := []Column{}
+	mappedSharedColumnsSlice := []Column{}
+	for i, column := range sharedColumns.Columns() {
+		ordinal := tableColumns.Ordinals[column.Name]
+		if uniqueKeyColumns.GetColumn(column.Name) == nil && columnValuesEqual(whereValues.AbstractValues()[ordinal], newValues.AbstractValues()[ordinal]) {
+			continue
+		}
+		sharedColumnsSlice = append(sharedColumnsSlice, column)
+		mappedSharedColumnsSlice = append(mappedSharedColumnsSlice, mappedSharedColumns.Columns()[i])
+	}
+	changedSharedColumns = &ColumnList{columns: sharedColumnsSlice, Ordinals: NewColumnsMap(sharedColumnsSlice)}
+	changedMappedSharedColumns = &ColumnList{columns: mappedSharedColumnsSlice, Ordinals: NewColumnsMap(mappedSharedColumnsSlice)}
+	return changedSharedColumns, changedMappedSharedColumns
+}
+
+// columnValuesEqual compares two values of a column, as read from the binary log; textual and binary
+// values are compared byte for byte
+func columnValuesEqual(a, b interface{}) bool {
+	aBytes, aIsBytes := toValueBytes(a)
+	bBytes, bIsBytes := toValueBytes(b)
+	if aIsBytes || bIsBytes {
+		return aIsBytes && bIsBytes && bytes.Equal(aBytes, bBytes)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toValueBytes(value interface{}) ([]byte, bool) {
+	switch value := value.(type) {
+	case string:
+		return []byte(value), true
+	case []byte:
+		return value, true
+	}
+	return nil, false
+}
+
 func (this *ColumnValues) StringColumn(index int) string {
 	val := this.AbstractValues()[index]
 	if ints, ok := val.([]uint8); ok {
@@ -311,3 +1009,14 @@ func (this *ColumnValues) String() string {
 	}
 	return strings.Join(stringValues, ",")
 }
+
+// Partition is a partition of the migrated table, as found in INFORMATION_SCHEMA.PARTITIONS.
+// Subpartitions are accounted for by their partition.
+type Partition struct {
+	Name         string
+	RowsEstimate int64
+}
+
+func (this *Partition) String() string {
+	return this.Name
+}
@@ -42,3 +42,358 @@ func TestGetColumn(t *testing.T) {
 		test.S(t).ExpectTrue(column == nil)
 	}
 }
+
+func TestPartialColumnValues(t *testing.T) {
+	tableColumns := NewColumnList([]string{"id", "name", "age", "city"})
+	sharedColumns := NewColumnList([]string{"id", "name", "city"})
+	mappedSharedColumns := NewColumnList([]string{"id", "full_name", "city"})
+
+	whereValues := ToPartialColumnValues([]interface{}{3, nil, nil, nil}, []bool{true, false, false, false})
+	newValues := ToPartialColumnValues([]interface{}{nil, "zoe", nil, nil}, []bool{false, true, false, false})
+	test.S(t).ExpectTrue(newValues.IsPartial())
+	test.S(t).ExpectFalse(newValues.IsPresent(0))
+
+	newValues.MergeAbsentFrom(whereValues)
+	test.S(t).ExpectTrue(newValues.IsPresent(0))
+	test.S(t).ExpectTrue(newValues.IsPresent(1))
+	test.S(t).ExpectFalse(newValues.IsPresent(2))
+	test.S(t).ExpectEquals(newValues.AbstractValues()[0], 3)
+
+	presentSharedColumns, presentMappedSharedColumns := FilterPresentColumns(tableColumns, sharedColumns, mappedSharedColumns, newValues)
+	test.S(t).ExpectEquals(presentSharedColumns.String(), "id,name")
+	test.S(t).ExpectEquals(presentMappedSharedColumns.String(), "id,full_name")
+
+	fullValues := ToColumnValues([]interface{}{3, "zoe", 42, "paris"})
+	test.S(t).ExpectFalse(fullValues.IsPartial())
+	presentSharedColumns, _ = FilterPresentColumns(tableColumns, sharedColumns, mappedSharedColumns, fullValues)
+	test.S(t).ExpectEquals(presentSharedColumns.String(), "id,name,city")
+}
+
+func TestFilterChangedColumns(t *testing.T) {
+	tableColumns := NewColumnList([]string{"id", "name", "doc", "age", "city"})
+	sharedColumns := NewColumnList([]string{"id", "name", "doc", "city"})
+	mappedSharedColumns := NewColumnList([]string{"id", "full_name", "doc", "city"})
+
+	uniqueKeyColumns := NewColumnList([]string{"id"})
+
+	whereValues := ToColumnValues([]interface{}{3, "zoe", []byte(`{"a":1}`), 42, nil})
+	newValues := ToColumnValues([]interface{}{3, []byte("zoe"), []byte(`{"a":2}`), 43, "paris"})
+	changedSharedColumns, changedMappedSharedColumns := FilterChangedColumns(tableColumns, sharedColumns, mappedSharedColumns, uniqueKeyColumns, whereValues, newValues)
+	test.S(t).ExpectEquals(changedSharedColumns.String(), "id,doc,city")
+	test.S(t).ExpectEquals(changedMappedSharedColumns.String(), "id,doc,city")
+
+	newValues = ToColumnValues([]interface{}{3, "lea", []byte(`{"a":1}`), 43, nil})
+	changedSharedColumns, changedMappedSharedColumns = FilterChangedColumns(tableColumns, sharedColumns, mappedSharedColumns, uniqueKeyColumns, whereValues, newValues)
+	test.S(t).ExpectEquals(changedSharedColumns.String(), "id,name")
+	test.S(t).ExpectEquals(changedMappedSharedColumns.String(), "id,full_name")
+
+	changedSharedColumns, _ = FilterChangedColumns(tableColumns, sharedColumns, mappedSharedColumns, uniqueKeyColumns, whereValues, whereValues)
+	test.S(t).ExpectEquals(changedSharedColumns.String(), "id")
+}
+
+func TestTypeConversion(t *testing.T) {
+	enumColumn := Column{Name: "c", Type: EnumColumnType, MySQLType: "enum('red','green','blue')", EnumValues: "'red','green','blue'", Charset: "utf8mb4"}
+	setColumn := Column{Name: "c", Type: SetColumnType, MySQLType: "set('a','b','c','d')", EnumValues: "'a','b','c','d'", Charset: "utf8mb4"}
+	bitColumn := Column{Name: "c", Type: BitColumnType, MySQLType: "bit(12)", BitLength: 12}
+	bit64Column := Column{Name: "c", Type: BitColumnType, MySQLType: "bit(64)", BitLength: 64}
+	varcharColumn := Column{Name: "c", MySQLType: "varchar(32)", Charset: "utf8mb4"}
+	varbinaryColumn := Column{Name: "c", MySQLType: "varbinary(8)", Charset: "binary"}
+	intColumn := Column{Name: "c", MySQLType: "int(11)"}
+	bigintColumn := Column{Name: "c", MySQLType: "bigint(20) unsigned", IsUnsigned: true}
+
+	// Each binlog value must convert into the value the row copy's INSERT ... SELECT writes
+	testCases := []struct {
+		name        string
+		from        Column
+		to          Column
+		binlogValue interface{}
+		copyValue   interface{}
+	}{
+		{"enum to varchar", enumColumn, varcharColumn, int64(2), "green"},
+		{"enum to varchar, invalid value", enumColumn, varcharColumn, int64(0), ""},
+		{"enum to reordered enum", enumColumn, Column{Name: "c", Type: EnumColumnType, MySQLType: "enum('blue','green','red')", Charset: "utf8mb4"}, int64(1), "red"},
+		{"enum to int", enumColumn, intColumn, int64(3), int64(3)},
+		{"enum to same enum", enumColumn, enumColumn, int64(3), int64(3)},
+		{"set to varchar", setColumn, varcharColumn, int64(0x0b), "a,b,d"},
+		{"set to varchar, empty", setColumn, varcharColumn, int64(0), ""},
+		{"set to wider set", setColumn, Column{Name: "c", Type: SetColumnType, MySQLType: "set('d','c','b','a','e')", Charset: "utf8mb4"}, int64(0x06), "b,c"},
+		{"set to int", setColumn, intColumn, int64(0x06), int64(0x06)},
+		{"bit to int", bitColumn, intColumn, int64(0x0a5), uint64(0x0a5)},
+		{"bit to varbinary", bitColumn, varbinaryColumn, int64(0x0a5), []byte{0x00, 0xa5}},
+		{"bit to wider bit", bitColumn, bit64Column, int64(0x0a5), int64(0x0a5)},
+		{"bit(64) to bigint unsigned", bit64Column, bigintColumn, int64(-1), uint64(18446744073709551615)},
+		{"null", enumColumn, varcharColumn, nil, nil},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			columns := NewColumnList([]string{"c"})
+			*columns.GetColumn("c") = testCase.from
+			columns.SetTypeConversion("c", NewTypeConversion(&testCase.from, &testCase.to))
+			converted := columns.GetColumn("c").convertArg(testCase.binlogValue, false)
+			test.S(t).ExpectTrue(reflect.DeepEqual(converted, testCase.copyValue))
+		})
+	}
+}
+
+func TestWrapColumnValues(t *testing.T) {
+	row := []interface{}{3, "zoe", nil}
+	values := WrapColumnValues(row, nil)
+	test.S(t).ExpectFalse(values.IsPartial())
+	test.S(t).ExpectEquals(values.AbstractValues()[1], "zoe")
+	// The row is not copied
+	row[1] = "lea"
+	test.S(t).ExpectEquals(values.AbstractValues()[1], "lea")
+
+	values = WrapColumnValues(row, []bool{true, false, true})
+	test.S(t).ExpectTrue(values.IsPartial())
+	values.Release()
+	test.S(t).ExpectFalse(values.IsPartial())
+	test.S(t).ExpectEquals(len(values.AbstractValues()), 0)
+}
+
+func TestColumnValuesApplyUnsigned(t *testing.T) {
+	columns := NewColumnList([]string{"id", "rank", "price"})
+	columns.SetUnsigned("id")
+	columns.SetUnsigned("price")
+	{
+		// Scanned off the text protocol
+		values := ToColumnValues([]interface{}{[]byte("9223372036854775808"), []byte("-3"), []byte("12.50")})
+		values.ApplyUnsigned(columns)
+		test.S(t).ExpectTrue(reflect.DeepEqual(values.AbstractValues(), []interface{}{uint64(9223372036854775808), []byte("-3"), []byte("12.50")}))
+		test.S(t).ExpectEquals(values.String(), "9223372036854775808,-3,12.50")
+	}
+	{
+		// Scanned off the binary protocol: int64 up to 2^63-1, string above
+		for _, value := range []interface{}{int64(9223372036854775807), "9223372036854775808", "9223372036854775809", "18446744073709551615"} {
+			values := ToColumnValues([]interface{}{value, int64(-3), nil})
+			values.ApplyUnsigned(columns)
+			_, isUint64 := values.AbstractValues()[0].(uint64)
+			test.S(t).ExpectTrue(isUint64)
+			test.S(t).ExpectEquals(values.AbstractValues()[1], int64(-3))
+			test.S(t).ExpectEquals(values.AbstractValues()[2], nil)
+		}
+	}
+	{
+		values := ToColumnValues([]interface{}{"18446744073709551615", int64(-3), nil})
+		values.ApplyUnsigned(columns)
+		test.S(t).ExpectEquals(values.AbstractValues()[0], uint64(18446744073709551615))
+	}
+}
+
+func TestConvertArgUnsignedBigint(t *testing.T) {
+	// Binlog row images carry BIGINT UNSIGNED values as int64
+	columns := NewColumnList([]string{"id"})
+	columns.SetUnsigned("id")
+	column := columns.GetColumn("id")
+	test.S(t).ExpectEquals(column.convertArg(int64(9223372036854775807), true), uint64(9223372036854775807))
+	test.S(t).ExpectEquals(column.convertArg(int64(-9223372036854775808), true), uint64(9223372036854775808))
+	test.S(t).ExpectEquals(column.convertArg(int64(-9223372036854775807), true), uint64(9223372036854775809))
+	test.S(t).ExpectEquals(column.convertArg(int64(-1), true), uint64(18446744073709551615))
+	// The binlog value compares the same as the row copy's range value
+	rangeValues := ToColumnValues([]interface{}{"9223372036854775809"})
+	rangeValues.ApplyUnsigned(columns)
+	test.S(t).ExpectEquals(column.convertArg(int64(-9223372036854775807), true), rangeValues.AbstractValues()[0])
+}
+
+func TestConvertArgCharset(t *testing.T) {
+	latin1Value := string([]byte{'c', 'a', 'f', 0xe9})
+	columns := NewColumnList([]string{"id", "name", "data", "raw", "title"})
+	columns.SetCharset("name", "latin1")
+	columns.SetCharset("data", "binary")
+	columns.SetCharset("raw", "latin1")
+	columns.SetCharset("title", "binary")
+	{
+		// latin1 -> utf8mb4: decoded from latin1
+		columns.SetCharsetConversion("name", "utf8mb4")
+		test.S(t).ExpectTrue(columns.HasCharsetConversion("name"))
+		test.S(t).ExpectEquals(columns.GetColumn("name").convertArg(latin1Value, false), "café")
+	}
+	{
+		// binary columns are never decoded
+		test.S(t).ExpectFalse(columns.HasCharsetConversion("data"))
+		test.S(t).ExpectEquals(columns.GetColumn("data").convertArg(latin1Value, false), latin1Value)
+	}
+	{
+		// latin1 -> binary: raw bytes are kept
+		columns.SetCharsetConversion("raw", "binary")
+		test.S(t).ExpectEquals(columns.GetColumn("raw").convertArg(latin1Value, true), latin1Value)
+	}
+	{
+		// binary -> latin1: raw bytes are read as latin1
+		columns.SetCharsetConversion("title", "latin1")
+		test.S(t).ExpectEquals(columns.GetColumn("title").convertArg(latin1Value, false), "café")
+	}
+	{
+		// utf8mb4 -> latin1: already UTF-8, server converts into the target charset
+		column := Column{Name: "name", Charset: "utf8mb4", charsetConversion: &CharsetConversion{ToCharset: "latin1"}}
+		test.S(t).ExpectEquals(column.convertArg("café", false), "café")
+	}
+	{
+		// non string values are untouched
+		test.S(t).ExpectEquals(columns.GetColumn("name").convertArg(17, false), 17)
+	}
+	{
+		// latin1 TEXT -> utf8mb4: TEXT values are read from the binlog as bytes, and decoded all the same
+		test.S(t).ExpectEquals(columns.GetColumn("name").convertArg([]byte(latin1Value), false), "café")
+	}
+	{
+		// BLOB values are never decoded
+		test.S(t).ExpectTrue(reflect.DeepEqual(columns.GetColumn("data").convertArg([]byte(latin1Value), false), []byte(latin1Value)))
+	}
+	{
+		// ucs2 -> utf8mb4: undecodable, given as a binary string for the server to convert
+		ucs2Value := string([]byte{0x00, 'c', 0x00, 0xe9})
+		column := Column{Name: "name", Charset: "ucs2", charsetConversion: &CharsetConversion{FromCharset: "ucs2", ToCharset: "utf8mb4"}}
+		test.S(t).ExpectTrue(reflect.DeepEqual(column.convertArg(ucs2Value, false), []byte(ucs2Value)))
+		test.S(t).ExpectTrue(reflect.DeepEqual(column.convertArg([]byte(ucs2Value), false), []byte(ucs2Value)))
+	}
+}
+
+func TestParseZeroDateRewrite(t *testing.T) {
+	{
+		rewrite, err := ParseZeroDateRewrite("null")
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectTrue(rewrite.ToNull)
+		test.S(t).ExpectEquals(rewrite.String(), "NULL")
+	}
+	{
+		rewrite, err := ParseZeroDateRewrite("1970-01-01 00:00:00")
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectFalse(rewrite.ToNull)
+		test.S(t).ExpectEquals(rewrite.String(), "'1970-01-01 00:00:00'")
+	}
+	for _, value := range []string{"", "yesterday", "1970-01-01'; drop table t; --", "0000-00-00", "1970-00-01"} {
+		_, err := ParseZeroDateRewrite(value)
+		test.S(t).ExpectNotNil(err)
+	}
+}
+
+func TestConvertArgZeroDateRewrite(t *testing.T) {
+	columns := NewColumnList([]string{"d", "dt"})
+	nullRewrite, _ := ParseZeroDateRewrite("NULL")
+	valueRewrite, _ := ParseZeroDateRewrite("1970-01-01")
+	columns.SetZeroDateRewrite("d", nullRewrite)
+	columns.SetZeroDateRewrite("dt", valueRewrite)
+	test.S(t).ExpectTrue(columns.HasZeroDateRewrite("d"))
+
+	test.S(t).ExpectEquals(columns.GetColumn("d").convertArg("0000-00-00", false), nil)
+	test.S(t).ExpectEquals(columns.GetColumn("d").convertArg("2020-00-15", true), nil)
+	test.S(t).ExpectEquals(columns.GetColumn("d").convertArg("2020-01-15", false), "2020-01-15")
+	test.S(t).ExpectEquals(columns.GetColumn("dt").convertArg("0000-00-00 00:00:00", false), "1970-01-01")
+	test.S(t).ExpectEquals(columns.GetColumn("dt").convertArg("2020-01-00 10:00:00.123", false), "1970-01-01")
+	test.S(t).ExpectEquals(columns.GetColumn("dt").convertArg(nil, false), nil)
+}
+
+func TestParseGeneratedColumnType(t *testing.T) {
+	test.S(t).ExpectEquals(ParseGeneratedColumnType(""), NotGeneratedColumn)
+	test.S(t).ExpectEquals(ParseGeneratedColumnType("auto_increment"), NotGeneratedColumn)
+	test.S(t).ExpectEquals(ParseGeneratedColumnType("DEFAULT_GENERATED on update CURRENT_TIMESTAMP"), NotGeneratedColumn)
+	test.S(t).ExpectEquals(ParseGeneratedColumnType("VIRTUAL GENERATED"), VirtualGeneratedColumn)
+	test.S(t).ExpectEquals(ParseGeneratedColumnType("STORED GENERATED"), StoredGeneratedColumn)
+	test.S(t).ExpectEquals(ParseGeneratedColumnType("STORED GENERATED INVISIBLE"), StoredGeneratedColumn)
+}
+
+func TestForeignKeyGhostName(t *testing.T) {
+	test.S(t).ExpectEquals((&ForeignKey{Name: "fk_parent"}).GhostName(), "_fk_parent")
+	test.S(t).ExpectEquals((&ForeignKey{Name: "_fk_parent"}).GhostName(), "fk_parent")
+	test.S(t).ExpectEquals((&ForeignKey{Name: "__fk_parent"}).GhostName(), "_fk_parent")
+}
+
+func TestForeignKeyHasCascadingRules(t *testing.T) {
+	test.S(t).ExpectFalse((&ForeignKey{UpdateRule: "RESTRICT", DeleteRule: "NO ACTION"}).HasCascadingRules())
+	test.S(t).ExpectFalse((&ForeignKey{UpdateRule: "NO ACTION", DeleteRule: "restrict"}).HasCascadingRules())
+	test.S(t).ExpectTrue((&ForeignKey{UpdateRule: "CASCADE", DeleteRule: "RESTRICT"}).HasCascadingRules())
+	test.S(t).ExpectTrue((&ForeignKey{UpdateRule: "RESTRICT", DeleteRule: "SET NULL"}).HasCascadingRules())
+	test.S(t).ExpectTrue((&ForeignKey{UpdateRule: "NO ACTION", DeleteRule: "SET DEFAULT"}).HasCascadingRules())
+}
+
+func TestUniqueKeyFunctional(t *testing.T) {
+	plain := &UniqueKey{Name: "tenant_email_uidx", Columns: *NewColumnList([]string{"tenant_id", "email"})}
+	functional := &UniqueKey{Name: "tenant_email_ci_uidx", Columns: *NewColumnList([]string{"tenant_id"}), Expressions: []string{"lower(`email`)"}}
+	test.S(t).ExpectFalse(plain.IsFunctional())
+	test.S(t).ExpectTrue(functional.IsFunctional())
+	test.S(t).ExpectEquals(functional.String(), "tenant_email_ci_uidx (functional: lower(`email`)): [tenant_id]; has nullable: false")
+
+	test.S(t).ExpectTrue(functional.EqualsByParts(&UniqueKey{Name: "other", Columns: *NewColumnList([]string{"tenant_id"}), Expressions: []string{"lower(`email`)"}}))
+	test.S(t).ExpectFalse(functional.EqualsByParts(&UniqueKey{Name: "tenant_uidx", Columns: *NewColumnList([]string{"tenant_id"})}))
+	test.S(t).ExpectFalse(functional.EqualsByParts(&UniqueKey{Columns: *NewColumnList([]string{"tenant_id"}), Expressions: []string{"upper(`email`)"}}))
+	test.S(t).ExpectTrue(plain.EqualsByParts(&UniqueKey{Columns: *NewColumnList([]string{"tenant_id", "email"})}))
+
+	// (tenant_id, (lower(email))) vs ((lower(email)), tenant_id)
+	functional.ExpressionPositions = []int{2}
+	test.S(t).ExpectTrue(functional.EqualsByParts(&UniqueKey{Columns: *NewColumnList([]string{"tenant_id"}), Expressions: []string{"lower(`email`)"}, ExpressionPositions: []int{2}}))
+	test.S(t).ExpectFalse(functional.EqualsByParts(&UniqueKey{Columns: *NewColumnList([]string{"tenant_id"}), Expressions: []string{"lower(`email`)"}, ExpressionPositions: []int{1}}))
+	multiple := &UniqueKey{Columns: *NewColumnList([]string{"tenant_id"}), Expressions: []string{"lower(`email`)", "lower(`name`)"}, ExpressionPositions: []int{1, 3}}
+	test.S(t).ExpectTrue(multiple.EqualsByParts(&UniqueKey{Columns: *NewColumnList([]string{"tenant_id"}), Expressions: []string{"lower(`email`)", "lower(`name`)"}, ExpressionPositions: []int{1, 3}}))
+	test.S(t).ExpectFalse(multiple.EqualsByParts(&UniqueKey{Columns: *NewColumnList([]string{"tenant_id"}), Expressions: []string{"lower(`email`)", "lower(`name`)"}, ExpressionPositions: []int{1, 2}}))
+}
+
+func TestUniqueKeyDescending(t *testing.T) {
+	ascending := &UniqueKey{Name: "tenant_created_uidx", Columns: *NewColumnList([]string{"tenant_id", "created_at"})}
+	descending := &UniqueKey{Name: "tenant_created_uidx", Columns: *NewColumnList([]string{"tenant_id", "created_at"})}
+	descending.Columns.SetDescending("created_at")
+	test.S(t).ExpectEquals(len(ascending.DescendingColumnNames()), 0)
+	test.S(t).ExpectTrue(reflect.DeepEqual(descending.DescendingColumnNames(), []string{"created_at"}))
+	test.S(t).ExpectEquals(descending.String(), "tenant_created_uidx (descending: created_at): [tenant_id created_at]; has nullable: false")
+
+	test.S(t).ExpectFalse(ascending.EqualsByParts(descending))
+	test.S(t).ExpectFalse(descending.EqualsByParts(ascending))
+	other := &UniqueKey{Name: "other", Columns: *NewColumnList([]string{"tenant_id", "created_at"})}
+	other.Columns.SetDescending("created_at")
+	test.S(t).ExpectTrue(descending.EqualsByParts(other))
+	other.Columns.SetDescending("tenant_id")
+	test.S(t).ExpectFalse(descending.EqualsByParts(other))
+}
+
+func TestJSONDiffsExpression(t *testing.T) {
+	{
+		// Nested paths, applied in order
+		diffs := JSONDiffs{
+			{Operation: JSONDiffReplace, Path: `$.a."b c"[0].d`, Value: `"x"`},
+			{Operation: JSONDiffInsert, Path: "$.a.e", Value: `{"f": null}`},
+		}
+		expression, args := diffs.Expression("`doc`")
+		test.S(t).ExpectEquals(expression, "json_set(json_set(`doc`, ?, cast(convert(? using utf8mb4) as json)), ?, cast(convert(? using utf8mb4) as json))")
+		test.S(t).ExpectTrue(reflect.DeepEqual(args, []interface{}{`$.a."b c"[0].d`, `"x"`, "$.a.e", `{"f": null}`}))
+	}
+	{
+		// Array element removal and insertion
+		diffs := JSONDiffs{
+			{Operation: JSONDiffRemove, Path: "$.tags[2]"},
+			{Operation: JSONDiffInsert, Path: "$.tags[0]", Value: "7"},
+		}
+		expression, args := diffs.Expression("`doc`")
+		test.S(t).ExpectEquals(expression, "json_array_insert(json_remove(`doc`, ?), ?, cast(convert(? using utf8mb4) as json))")
+		test.S(t).ExpectTrue(reflect.DeepEqual(args, []interface{}{"$.tags[2]", "$.tags[0]", "7"}))
+	}
+}
+
+func TestCheckConstraint(t *testing.T) {
+	checkConstraint := &CheckConstraint{Name: "positive_amount", Clause: "((`amount` > 0) and (`discount` <= `amount`))", Enforced: true}
+	test.S(t).ExpectEquals(checkConstraint.GhostName(), "_positive_amount")
+	test.S(t).ExpectEquals((&CheckConstraint{Name: "_positive_amount"}).GhostName(), "positive_amount")
+	test.S(t).ExpectTrue(reflect.DeepEqual(checkConstraint.ColumnNames(), []string{"amount", "discount", "amount"}))
+	test.S(t).ExpectEquals(checkConstraint.MapClause(map[string]string{"amount": "total"}), "((`total` > 0) and (`discount` <= `total`))")
+	test.S(t).ExpectEquals(checkConstraint.String(), "`positive_amount` CHECK ((`amount` > 0) and (`discount` <= `amount`))")
+	test.S(t).ExpectEquals((&CheckConstraint{Name: "chk", Clause: "(`a` > 0)"}).String(), "`chk` CHECK (`a` > 0) NOT ENFORCED")
+
+	test.S(t).ExpectFalse(checkConstraint.HasGeneratedName("tbl"))
+	test.S(t).ExpectTrue((&CheckConstraint{Name: "tbl_chk_1"}).HasGeneratedName("tbl"))
+	test.S(t).ExpectFalse((&CheckConstraint{Name: "tbl_chk_1"}).HasGeneratedName("other"))
+	test.S(t).ExpectFalse((&CheckConstraint{Name: "tbl_chk_amount"}).HasGeneratedName("tbl"))
+}
+
+func TestTriggerGhostName(t *testing.T) {
+	test.S(t).ExpectEquals((&Trigger{Name: "tbl_audit"}).GhostName(), "_tbl_audit_gho")
+}
+
+func TestTriggerReferencesTable(t *testing.T) {
+	trigger := &Trigger{Statement: "insert into tbl_audit (id, action) values (NEW.id, 'insert')"}
+	test.S(t).ExpectFalse(trigger.ReferencesTable("tbl"))
+	test.S(t).ExpectTrue(trigger.ReferencesTable("tbl_audit"))
+
+	trigger = &Trigger{Statement: "BEGIN set NEW.rank = (select count(*) from `tbl`); END"}
+	test.S(t).ExpectTrue(trigger.ReferencesTable("tbl"))
+	trigger = &Trigger{Statement: "BEGIN set NEW.rank = (select count(*) from test.TBL); END"}
+	test.S(t).ExpectTrue(trigger.ReferencesTable("tbl"))
+}
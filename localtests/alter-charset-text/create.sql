@@ -0,0 +1,28 @@
+drop table if exists gh_ost_test;
+create table gh_ost_test (
+  id int auto_increment,
+  t1 text charset latin1,
+  t2 mediumtext charset latin1,
+  t3 varchar(128) charset ucs2,
+  b blob,
+  primary key(id)
+) auto_increment=1;
+
+insert into gh_ost_test values (null, 'átesting', 'çadena', 'ñandú', 'átesting');
+
+drop event if exists gh_ost_test;
+delimiter ;;
+create event gh_ost_test
+  on schedule every 1 second
+  starts current_timestamp
+  ends current_timestamp + interval 60 second
+  on completion not preserve
+  enable
+  do
+begin
+  insert into gh_ost_test values (null, md5(rand()), md5(rand()), md5(rand()), md5(rand()));
+  insert into gh_ost_test values (null, 'átesting', 'çadena', 'ñandú', 'átesting');
+  insert into gh_ost_test values (null, 'testátest', 'testçadena', 'testñandú', unhex('e9ff00'));
+  set @last_insert_id := last_insert_id();
+  update gh_ost_test set t1='ÿtesting', t2=concat(t2, 'ü'), t3='größe' where id = @last_insert_id;
+end ;;
@@ -0,0 +1,29 @@
+drop table if exists gh_ost_test;
+create table gh_ost_test (
+  id int auto_increment,
+  i int not null,
+  b bit(12) null default null,
+  primary key(id)
+) auto_increment=1;
+
+insert into gh_ost_test values (null, 7, b'0');
+insert into gh_ost_test values (null, 7, b'101');
+insert into gh_ost_test values (null, 7, b'111111111111');
+
+drop event if exists gh_ost_test;
+delimiter ;;
+create event gh_ost_test
+  on schedule every 1 second
+  starts current_timestamp
+  ends current_timestamp + interval 60 second
+  on completion not preserve
+  enable
+  do
+begin
+  insert into gh_ost_test values (null, 11, b'0');
+  insert into gh_ost_test values (null, 13, b'101');
+  insert into gh_ost_test values (null, 17, b'111111111111');
+  set @last_insert_id := last_insert_id();
+  update gh_ost_test set b=b'100000000001' where id = @last_insert_id;
+  insert into gh_ost_test values (null, 19, null);
+end ;;
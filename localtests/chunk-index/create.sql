@@ -0,0 +1,24 @@
+drop table if exists gh_ost_test;
+create table gh_ost_test (
+  id int auto_increment,
+  i int not null,
+  color varchar(32) not null,
+  primary key(id),
+  unique key i_color_uidx(i, color)
+) auto_increment=1;
+
+drop event if exists gh_ost_test;
+delimiter ;;
+create event gh_ost_test
+  on schedule every 1 second
+  starts current_timestamp
+  ends current_timestamp + interval 60 second
+  on completion not preserve
+  enable
+  do
+begin
+  insert into gh_ost_test values (null, 11, concat('red', rand()));
+  insert into gh_ost_test values (null, 13, concat('green', rand()));
+  update gh_ost_test set color=concat('blue', rand()) where i = 11 order by id desc limit 1;
+  delete from gh_ost_test where i = 13 order by id desc limit 1;
+end ;;
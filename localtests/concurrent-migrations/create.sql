@@ -0,0 +1,33 @@
+drop table if exists gh_ost_test;
+create table gh_ost_test (
+  id int auto_increment,
+  i int not null,
+  ts timestamp,
+  primary key(id)
+) auto_increment=1;
+
+drop table if exists gh_ost_test_concurrent;
+create table gh_ost_test_concurrent (
+  id int auto_increment,
+  i int not null,
+  ts timestamp,
+  primary key(id)
+) auto_increment=1;
+
+drop event if exists gh_ost_test;
+delimiter ;;
+create event gh_ost_test
+  on schedule every 1 second
+  starts current_timestamp
+  ends current_timestamp + interval 60 second
+  on completion not preserve
+  enable
+  do
+begin
+  insert into gh_ost_test values (null, 11, now());
+  insert into gh_ost_test values (null, 13, now());
+  update gh_ost_test set i = i + 1 where id = last_insert_id() - 1;
+  insert into gh_ost_test_concurrent values (null, 17, now());
+  insert into gh_ost_test_concurrent values (null, 19, now());
+  delete from gh_ost_test_concurrent where id = last_insert_id() - 1;
+end ;;
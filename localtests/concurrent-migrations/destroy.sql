@@ -0,0 +1,4 @@
+drop table if exists gh_ost_test_concurrent;
+drop table if exists _gh_ost_test_concurrent_gho;
+drop table if exists _gh_ost_test_concurrent_ghc;
+drop table if exists _gh_ost_test_concurrent_del;
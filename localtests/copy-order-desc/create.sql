@@ -0,0 +1,32 @@
+drop table if exists gh_ost_test;
+create table gh_ost_test (
+  id int auto_increment,
+  shard_id int not null,
+  seq int not null,
+  i int not null,
+  ts timestamp default current_timestamp,
+  primary key(id),
+  unique key shard_seq_uidx(shard_id, seq)
+) auto_increment=1;
+
+insert into gh_ost_test values (null, 1, 1, 11, now());
+insert into gh_ost_test values (null, 1, 2, 13, now());
+insert into gh_ost_test values (null, 2, 1, 17, now());
+insert into gh_ost_test values (null, 2, 2, 19, now());
+insert into gh_ost_test values (null, 3, 1, 23, now());
+
+drop event if exists gh_ost_test;
+delimiter ;;
+create event gh_ost_test
+  on schedule every 1 second
+  starts current_timestamp
+  ends current_timestamp + interval 60 second
+  on completion not preserve
+  enable
+  do
+begin
+  insert into gh_ost_test values (null, 3, unix_timestamp(), 29, now());
+  insert into gh_ost_test values (null, 1, unix_timestamp(), 31, now());
+  update gh_ost_test set i = i + 1 where shard_id = 2 and seq = 1;
+  delete from gh_ost_test where shard_id = 1 and seq = 2;
+end ;;
@@ -0,0 +1,35 @@
+drop table if exists gh_ost_test;
+create table gh_ost_test (
+  id int auto_increment,
+  i int not null,
+  counter int not null default 0,
+  primary key(id)
+) auto_increment=1;
+
+insert into gh_ost_test values (null, 11, 0);
+insert into gh_ost_test values (null, 13, 0);
+insert into gh_ost_test values (null, 17, 0);
+
+drop event if exists gh_ost_test;
+delimiter ;;
+create event gh_ost_test
+  on schedule every 1 second
+  starts current_timestamp
+  ends current_timestamp + interval 60 second
+  on completion not preserve
+  enable
+  do
+begin
+  -- Hot rows: successive updates of the same rows must apply in order
+  update gh_ost_test set counter = counter + 1 where id in (1, 2);
+  update gh_ost_test set counter = counter * 2 where id = 1;
+  update gh_ost_test set counter = counter + 1 where id in (1, 2);
+  insert into gh_ost_test values (null, 23, 0);
+  update gh_ost_test set counter = counter + 3 where id = last_insert_id();
+  delete from gh_ost_test where id = last_insert_id();
+  insert into gh_ost_test values (null, 29, 0);
+  -- Moving a row between keys
+  update gh_ost_test set id = id + 1000 where id = last_insert_id();
+  update gh_ost_test set counter = counter + 1 where id = 3;
+  update gh_ost_test set counter = counter * 3 where id = 3;
+end ;;
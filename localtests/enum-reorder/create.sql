@@ -0,0 +1,29 @@
+drop table if exists gh_ost_test;
+create table gh_ost_test (
+  id int auto_increment,
+  i int not null,
+  e enum('red', 'green', 'blue') not null default 'red',
+  primary key(id, e)
+) auto_increment=1;
+
+insert into gh_ost_test values (null, 7, 'red');
+insert into gh_ost_test values (null, 7, 'blue');
+
+drop event if exists gh_ost_test;
+delimiter ;;
+create event gh_ost_test
+  on schedule every 1 second
+  starts current_timestamp
+  ends current_timestamp + interval 60 second
+  on completion not preserve
+  enable
+  do
+begin
+  insert into gh_ost_test values (null, 11, 'red');
+  insert into gh_ost_test values (null, 13, 'green');
+  insert into gh_ost_test values (null, 17, 'blue');
+  set @last_insert_id := last_insert_id();
+  update gh_ost_test set e='green' where id = @last_insert_id;
+  update gh_ost_test set i=i+1 where id = @last_insert_id;
+  delete from gh_ost_test where id = @last_insert_id - 1;
+end ;;
@@ -0,0 +1,10 @@
+drop table if exists gh_ost_test;
+create table gh_ost_test (
+  i int not null,
+  color varchar(32) not null
+) auto_increment=1;
+
+insert into gh_ost_test values (11, 'red');
+insert into gh_ost_test values (11, 'red');
+
+drop event if exists gh_ost_test;
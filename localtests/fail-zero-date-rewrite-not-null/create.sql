@@ -0,0 +1,12 @@
+set session sql_mode='';
+
+drop table if exists gh_ost_test;
+create table gh_ost_test (
+  id int auto_increment,
+  d date not null,
+  primary key(id)
+) auto_increment=1;
+
+insert into gh_ost_test values (null, '0000-00-00');
+
+drop event if exists gh_ost_test;
@@ -0,0 +1,32 @@
+drop table if exists gh_ost_test;
+create table gh_ost_test (
+  id int auto_increment,
+  a int not null,
+  b int not null,
+  sum_ab int as (a + b) stored not null,
+  diff_ab int as (a - b) virtual not null,
+  primary key(id, sum_ab),
+  key diff_ab_idx(diff_ab)
+) auto_increment=1;
+
+insert into gh_ost_test (id, a, b) values (null, 2, 3);
+insert into gh_ost_test (id, a, b) values (null, 2, 4);
+insert into gh_ost_test (id, a, b) values (null, 2, 5);
+
+drop event if exists gh_ost_test;
+delimiter ;;
+create event gh_ost_test
+  on schedule every 1 second
+  starts current_timestamp
+  ends current_timestamp + interval 60 second
+  on completion not preserve
+  enable
+  do
+begin
+  insert into gh_ost_test (id, a, b) values (null, 2, 6);
+  insert into gh_ost_test (id, a, b) values (null, 3, 7);
+  update gh_ost_test set b = b + 1 where id = last_insert_id();
+  update gh_ost_test set a = a * 2 where id % 3 = 0 order by id desc limit 1;
+  insert into gh_ost_test (id, a, b) values (null, 5, 11);
+  delete from gh_ost_test where id = last_insert_id();
+end ;;
@@ -0,0 +1,25 @@
+set session sql_generate_invisible_primary_key=ON;
+drop table if exists gh_ost_test;
+create table gh_ost_test (
+  i int not null,
+  color varchar(32),
+  ts timestamp default current_timestamp
+) auto_increment=1;
+set session sql_generate_invisible_primary_key=OFF;
+
+drop event if exists gh_ost_test;
+delimiter ;;
+create event gh_ost_test
+  on schedule every 1 second
+  starts current_timestamp
+  ends current_timestamp + interval 60 second
+  on completion not preserve
+  enable
+  do
+begin
+  insert into gh_ost_test values (11, 'red', null);
+  insert into gh_ost_test values (13, 'green', null);
+  insert into gh_ost_test values (17, 'blue', null);
+  update gh_ost_test set color='orange' where color='red' order by my_row_id desc limit 1;
+  delete from gh_ost_test where color='green' order by my_row_id desc limit 1;
+end ;;
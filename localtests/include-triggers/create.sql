@@ -0,0 +1,36 @@
+drop table if exists gh_ost_test;
+drop table if exists gh_ost_test_audit;
+create table gh_ost_test (
+  id int auto_increment,
+  i int not null,
+  ts timestamp,
+  primary key(id)
+) auto_increment=1;
+
+create table gh_ost_test_audit (
+  id int auto_increment,
+  test_id int not null,
+  action varchar(16) not null,
+  primary key(id)
+);
+
+create trigger gh_ost_test_ai after insert on gh_ost_test for each row
+  insert into gh_ost_test_audit (test_id, action) values (NEW.id, 'insert');
+create trigger gh_ost_test_ad after delete on gh_ost_test for each row
+  insert into gh_ost_test_audit (test_id, action) values (OLD.id, 'delete');
+
+drop event if exists gh_ost_test;
+delimiter ;;
+create event gh_ost_test
+  on schedule every 1 second
+  starts current_timestamp
+  ends current_timestamp + interval 60 second
+  on completion not preserve
+  enable
+  do
+begin
+  insert into gh_ost_test values (null, 11, now());
+  insert into gh_ost_test values (null, 13, now());
+  insert into gh_ost_test values (null, 17, now());
+  delete from gh_ost_test where i = 13 order by id desc limit 1;
+end ;;
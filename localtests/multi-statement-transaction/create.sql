@@ -0,0 +1,40 @@
+drop table if exists gh_ost_test;
+create table gh_ost_test (
+  id int auto_increment,
+  account int not null,
+  balance int not null,
+  primary key(id),
+  key account_idx(account)
+) auto_increment=1;
+
+insert into gh_ost_test values (null, 1, 1000);
+insert into gh_ost_test values (null, 2, 1000);
+insert into gh_ost_test values (null, 3, 1000);
+
+drop event if exists gh_ost_test;
+delimiter ;;
+create event gh_ost_test
+  on schedule every 1 second
+  starts current_timestamp
+  ends current_timestamp + interval 60 second
+  on completion not preserve
+  enable
+  do
+begin
+  -- Each transaction moves balance between accounts: the total balance is constant
+  start transaction;
+    update gh_ost_test set balance = balance - 10 where account = 1;
+    insert into gh_ost_test values (null, 4, 5);
+    insert into gh_ost_test values (null, 4, 5);
+    update gh_ost_test set balance = balance + 5 where account = 2;
+    update gh_ost_test set balance = balance - 5 where account = 3;
+    update gh_ost_test set balance = balance + 5 where account = 3;
+  commit;
+
+  start transaction;
+    delete from gh_ost_test where account = 4 order by id limit 1;
+    update gh_ost_test set balance = balance + 5 where account = 1;
+    delete from gh_ost_test where account = 4 order by id limit 1;
+    insert into gh_ost_test values (null, 5, 10);
+  commit;
+end ;;
@@ -0,0 +1,56 @@
+drop table if exists gh_ost_test;
+create table gh_ost_test (
+  id int auto_increment,
+  a int null,
+  b int null,
+  c int null,
+  i int not null,
+  primary key(id),
+  unique key abc_uidx(a, b, c)
+) auto_increment=1;
+
+insert into gh_ost_test values (null, null, null, null, 0);
+insert into gh_ost_test values (null, null, null, 1, 1);
+insert into gh_ost_test values (null, null, null, 2, 2);
+insert into gh_ost_test values (null, null, 1, null, 3);
+insert into gh_ost_test values (null, null, 1, 1, 4);
+insert into gh_ost_test values (null, null, 1, 2, 5);
+insert into gh_ost_test values (null, null, 2, null, 6);
+insert into gh_ost_test values (null, null, 2, 1, 7);
+insert into gh_ost_test values (null, null, 2, 2, 8);
+insert into gh_ost_test values (null, 1, null, null, 9);
+insert into gh_ost_test values (null, 1, null, 1, 10);
+insert into gh_ost_test values (null, 1, null, 2, 11);
+insert into gh_ost_test values (null, 1, 1, null, 12);
+insert into gh_ost_test values (null, 1, 1, 1, 13);
+insert into gh_ost_test values (null, 1, 1, 2, 14);
+insert into gh_ost_test values (null, 1, 2, null, 15);
+insert into gh_ost_test values (null, 1, 2, 1, 16);
+insert into gh_ost_test values (null, 1, 2, 2, 17);
+insert into gh_ost_test values (null, 2, null, null, 18);
+insert into gh_ost_test values (null, 2, null, 1, 19);
+insert into gh_ost_test values (null, 2, null, 2, 20);
+insert into gh_ost_test values (null, 2, 1, null, 21);
+insert into gh_ost_test values (null, 2, 1, 1, 22);
+insert into gh_ost_test values (null, 2, 1, 2, 23);
+insert into gh_ost_test values (null, 2, 2, null, 24);
+insert into gh_ost_test values (null, 2, 2, 1, 25);
+insert into gh_ost_test values (null, 2, 2, 2, 26);
+insert into gh_ost_test values (null, 1, null, 1, 27);
+insert into gh_ost_test values (null, null, null, null, 28);
+insert into gh_ost_test values (null, 2, 2, null, 29);
+insert into gh_ost_test values (null, null, 1, 2, 30);
+
+drop event if exists gh_ost_test;
+delimiter ;;
+create event gh_ost_test
+  on schedule every 1 second
+  starts current_timestamp
+  ends current_timestamp + interval 60 second
+  on completion not preserve
+  enable
+  do
+begin
+  insert into gh_ost_test values (null, null, floor(rand() * 1000), null, 11);
+  insert into gh_ost_test values (null, 3, null, floor(rand() * 1000), 13);
+end ;;
@@ -0,0 +1,33 @@
+drop table if exists gh_ost_test;
+create table gh_ost_test (
+  id int auto_increment,
+  region int not null,
+  color varchar(32),
+  primary key(id, region)
+) auto_increment=1
+partition by list (region)
+subpartition by hash (id) subpartitions 2 (
+  partition p_east values in (1, 2),
+  partition p_west values in (3, 4),
+  partition p_empty values in (5)
+);
+
+insert into gh_ost_test values (null, 1, 'red');
+insert into gh_ost_test values (null, 2, 'green');
+insert into gh_ost_test values (null, 3, 'blue');
+insert into gh_ost_test values (null, 4, 'orange');
+
+drop event if exists gh_ost_test;
+delimiter ;;
+create event gh_ost_test
+  on schedule every 1 second
+  starts current_timestamp
+  ends current_timestamp + interval 60 second
+  on completion not preserve
+  enable
+  do
+begin
+  insert into gh_ost_test values (null, 1 + floor(rand() * 4), 'yellow');
+  update gh_ost_test set region = 4 where region = 1 order by id limit 1;
+  update gh_ost_test set color = 'purple' where region = 3 order by id desc limit 1;
+end ;;
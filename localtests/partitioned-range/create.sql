@@ -0,0 +1,35 @@
+drop table if exists gh_ost_test;
+create table gh_ost_test (
+  id int auto_increment,
+  i int not null,
+  ts timestamp,
+  primary key(id)
+) auto_increment=1
+partition by range (id) (
+  partition p0 values less than (10),
+  partition p1 values less than (20),
+  partition p2 values less than (30),
+  partition p3 values less than maxvalue
+);
+
+insert into gh_ost_test values (null, 11, now());
+insert into gh_ost_test values (null, 13, now());
+insert into gh_ost_test values (25, 17, now());
+insert into gh_ost_test values (26, 19, now());
+insert into gh_ost_test values (31, 23, now());
+
+drop event if exists gh_ost_test;
+delimiter ;;
+create event gh_ost_test
+  on schedule every 1 second
+  starts current_timestamp
+  ends current_timestamp + interval 60 second
+  on completion not preserve
+  enable
+  do
+begin
+  insert into gh_ost_test values (null, 29, now());
+  update gh_ost_test set i = i + 1 where id = 1;
+  update gh_ost_test set id = id + 100 where id = 25;
+  delete from gh_ost_test where id = 26;
+end ;;
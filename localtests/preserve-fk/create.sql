@@ -0,0 +1,34 @@
+drop table if exists _gh_ost_test_gho;
+drop table if exists gh_ost_test;
+drop table if exists gh_ost_test_preserve_fk_parent;
+create table gh_ost_test_preserve_fk_parent (
+  id int auto_increment,
+  ts timestamp,
+  primary key(id)
+);
+create table gh_ost_test (
+  id int auto_increment,
+  i int not null,
+  parent_id int not null,
+  primary key(id),
+  constraint test_fk foreign key (parent_id) references gh_ost_test_preserve_fk_parent (id) on delete restrict on update no action
+) auto_increment=1;
+
+insert into gh_ost_test_preserve_fk_parent (id) values (1),(2),(3);
+
+drop event if exists gh_ost_test;
+delimiter ;;
+create event gh_ost_test
+  on schedule every 1 second
+  starts current_timestamp
+  ends current_timestamp + interval 60 second
+  on completion not preserve
+  enable
+  do
+begin
+  insert into gh_ost_test values (null, 11, 1);
+  insert into gh_ost_test values (null, 13, 2);
+  insert into gh_ost_test values (null, 17, 3);
+  update gh_ost_test set parent_id = 1 + (parent_id % 3) where id = last_insert_id();
+  delete from gh_ost_test where i = 13 order by id desc limit 1;
+end ;;
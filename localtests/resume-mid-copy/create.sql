@@ -0,0 +1,38 @@
+drop table if exists gh_ost_test;
+create table gh_ost_test (
+  id int auto_increment,
+  i int not null,
+  counter int not null default 0,
+  primary key(id)
+) auto_increment=1;
+
+-- 2048 rows, copied over several seconds per --max-copy-rows-per-second
+insert into gh_ost_test (i) values (1);
+insert into gh_ost_test (i) select i + 1 from gh_ost_test;
+insert into gh_ost_test (i) select i + 2 from gh_ost_test;
+insert into gh_ost_test (i) select i + 4 from gh_ost_test;
+insert into gh_ost_test (i) select i + 8 from gh_ost_test;
+insert into gh_ost_test (i) select i + 16 from gh_ost_test;
+insert into gh_ost_test (i) select i + 32 from gh_ost_test;
+insert into gh_ost_test (i) select i + 64 from gh_ost_test;
+insert into gh_ost_test (i) select i + 128 from gh_ost_test;
+insert into gh_ost_test (i) select i + 256 from gh_ost_test;
+insert into gh_ost_test (i) select i + 512 from gh_ost_test;
+insert into gh_ost_test (i) select i + 1024 from gh_ost_test;
+
+drop event if exists gh_ost_test;
+delimiter ;;
+create event gh_ost_test
+  on schedule every 1 second
+  starts current_timestamp
+  ends current_timestamp + interval 60 second
+  on completion not preserve
+  enable
+  do
+begin
+  -- Rows both ahead of and behind the checkpoint, applied before and after the interruption
+  update gh_ost_test set counter = counter + 1 where id in (1, 1000, 2000);
+  insert into gh_ost_test (i) values (0);
+  delete from gh_ost_test where id = last_insert_id() - 1;
+  update gh_ost_test set counter = counter + 1 where id = last_insert_id();
+end ;;
@@ -0,0 +1,29 @@
+drop table if exists gh_ost_test;
+create table gh_ost_test (
+  id int auto_increment,
+  i int not null,
+  ts timestamp default current_timestamp,
+  primary key(id)
+) auto_increment=1;
+
+insert into gh_ost_test values (null, 11, now());
+insert into gh_ost_test values (null, 13, now());
+insert into gh_ost_test values (null, 17, now());
+insert into gh_ost_test values (null, 23, now());
+
+drop event if exists gh_ost_test;
+delimiter ;;
+create event gh_ost_test
+  on schedule every 1 second
+  starts current_timestamp
+  ends current_timestamp + interval 60 second
+  on completion not preserve
+  enable
+  do
+begin
+  insert into gh_ost_test values (null, 11, now());
+  insert into gh_ost_test values (null, 19, now());
+  update gh_ost_test set i = i + 5 where id = 1;
+  update gh_ost_test set i = i - 5 where id = 4;
+  delete from gh_ost_test where id = 3;
+end ;;
@@ -0,0 +1,31 @@
+drop table if exists gh_ost_test;
+create table gh_ost_test (
+  id int auto_increment,
+  i int not null,
+  s set('read', 'write', 'exec', 'admin') null default null,
+  primary key(id)
+) auto_increment=1;
+
+insert into gh_ost_test values (null, 7, '');
+insert into gh_ost_test values (null, 7, 'read');
+insert into gh_ost_test values (null, 7, 'read,exec');
+insert into gh_ost_test values (null, 7, 'read,write,exec,admin');
+
+drop event if exists gh_ost_test;
+delimiter ;;
+create event gh_ost_test
+  on schedule every 1 second
+  starts current_timestamp
+  ends current_timestamp + interval 60 second
+  on completion not preserve
+  enable
+  do
+begin
+  insert into gh_ost_test values (null, 11, '');
+  insert into gh_ost_test values (null, 11, 'read');
+  insert into gh_ost_test values (null, 13, 'read,exec');
+  insert into gh_ost_test values (null, 17, 'read,write,exec,admin');
+  set @last_insert_id := last_insert_id();
+  update gh_ost_test set s='write,admin' where id = @last_insert_id;
+  insert into gh_ost_test values (null, 19, null);
+end ;;
@@ -0,0 +1,24 @@
+drop table if exists gh_ost_test;
+create table gh_ost_test (
+  id int auto_increment,
+  pt point not null,
+  primary key(id)
+) auto_increment=1;
+
+insert into gh_ost_test values (null, ST_GeomFromText('POINT(1 2)'));
+insert into gh_ost_test values (null, ST_GeomFromText('POINT(5 6)'));
+
+drop event if exists gh_ost_test;
+delimiter ;;
+create event gh_ost_test
+  on schedule every 1 second
+  starts current_timestamp
+  ends current_timestamp + interval 60 second
+  on completion not preserve
+  enable
+  do
+begin
+  insert into gh_ost_test values (null, ST_GeomFromText('POINT(10 20)'));
+  insert into gh_ost_test values (null, ST_GeomFromText('POINT(30 40)'));
+  update gh_ost_test set pt=ST_GeomFromText('POINT(11 21)') order by id desc limit 1;
+end ;;
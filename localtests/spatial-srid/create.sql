@@ -0,0 +1,27 @@
+drop table if exists gh_ost_test;
+create table gh_ost_test (
+  id int auto_increment,
+  pt point not null srid 4326,
+  g geometry srid 4326,
+  primary key(id),
+  spatial key pt_idx(pt)
+) auto_increment=1;
+
+insert into gh_ost_test values (null, ST_GeomFromText('POINT(1 2)', 4326), ST_GeomFromText('LINESTRING(1 2, 3 4)', 4326));
+insert into gh_ost_test values (null, ST_GeomFromText('POINT(5 6)', 4326), null);
+
+drop event if exists gh_ost_test;
+delimiter ;;
+create event gh_ost_test
+  on schedule every 1 second
+  starts current_timestamp
+  ends current_timestamp + interval 60 second
+  on completion not preserve
+  enable
+  do
+begin
+  insert into gh_ost_test values (null, ST_GeomFromText('POINT(10 20)', 4326), ST_GeomFromText('POLYGON((0 0, 0 1, 1 1, 0 0))', 4326));
+  insert into gh_ost_test values (null, ST_GeomFromText('POINT(30 40)', 4326), null);
+  update gh_ost_test set pt=ST_GeomFromText('POINT(11 21)', 4326), g=ST_GeomFromText('POINT(7 8)', 4326) order by id desc limit 1;
+  delete from gh_ost_test where id = 1;
+end ;;
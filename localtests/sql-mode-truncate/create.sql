@@ -0,0 +1,25 @@
+set session sql_mode='NO_ENGINE_SUBSTITUTION';
+
+drop table if exists gh_ost_test;
+create table gh_ost_test (
+  id int auto_increment,
+  name varchar(16),
+  primary key(id)
+) auto_increment=1;
+
+insert into gh_ost_test values (null, 'abc');
+insert into gh_ost_test values (null, 'abcdefgh');
+
+drop event if exists gh_ost_test;
+delimiter ;;
+create event gh_ost_test
+  on schedule every 1 second
+  starts current_timestamp
+  ends current_timestamp + interval 60 second
+  on completion not preserve
+  enable
+  do
+begin
+  insert into gh_ost_test values (null, 'abcdefghijkl');
+  update gh_ost_test set name = 'ijklmnop' where id = 1;
+end ;;
@@ -0,0 +1,26 @@
+set session sql_mode='';
+
+drop table if exists gh_ost_test;
+create table gh_ost_test (
+  id int auto_increment,
+  d date,
+  dt datetime,
+  primary key(id)
+) auto_increment=1;
+
+insert into gh_ost_test values (null, '0000-00-00', '0000-00-00 00:00:00');
+insert into gh_ost_test values (null, '2020-01-15', '2020-01-15 10:00:00');
+
+drop event if exists gh_ost_test;
+delimiter ;;
+create event gh_ost_test
+  on schedule every 1 second
+  starts current_timestamp
+  ends current_timestamp + interval 60 second
+  on completion not preserve
+  enable
+  do
+begin
+  insert into gh_ost_test values (null, '0000-00-00', '0000-00-00 00:00:00');
+  update gh_ost_test set dt = '0000-00-00 00:00:00' where id = 2;
+end ;;
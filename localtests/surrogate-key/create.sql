@@ -0,0 +1,27 @@
+drop table if exists gh_ost_test;
+create table gh_ost_test (
+  i int not null,
+  color varchar(32) not null,
+  ts timestamp default current_timestamp,
+  key i_idx(i)
+) auto_increment=1;
+
+insert into gh_ost_test values (11, 'red', now());
+insert into gh_ost_test values (13, 'green', now());
+insert into gh_ost_test values (17, 'blue', now());
+
+drop event if exists gh_ost_test;
+delimiter ;;
+create event gh_ost_test
+  on schedule every 1 second
+  starts current_timestamp
+  ends current_timestamp + interval 60 second
+  on completion not preserve
+  enable
+  do
+begin
+  insert into gh_ost_test values (11, concat('red', rand()), now());
+  insert into gh_ost_test values (13, concat('green', rand()), now());
+  update gh_ost_test set color=concat('blue', rand()) where i = 11 order by color limit 1;
+  delete from gh_ost_test where i = 13 order by color limit 1;
+end ;;
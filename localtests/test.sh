@@ -12,6 +12,8 @@ test_logfile=/tmp/gh-ost-test.log
 default_ghost_binary=/tmp/gh-ost-test
 ghost_binary=""
 exec_command_file=/tmp/gh-ost-test.bash
+concurrent_test_logfile=/tmp/gh-ost-test.concurrent.log
+concurrent_exec_command_file=/tmp/gh-ost-test.concurrent.bash
 ghost_structure_output_file=/tmp/gh-ost-test.ghost.structure.sql
 orig_content_output_file=/tmp/gh-ost-test.orig.content.csv
 ghost_content_output_file=/tmp/gh-ost-test.ghost.content.csv
@@ -22,6 +24,7 @@ master_port=
 replica_host=
 replica_port=
 original_sql_mode=
+original_time_zone=
 
 OPTIND=1
 while getopts "b:" OPTION
@@ -50,6 +53,8 @@ verify_master_and_replica() {
   fi
   original_sql_mode="$(gh-ost-test-mysql-master -e "select @@global.sql_mode" -s -s)"
   echo "sql_mode on master is ${original_sql_mode}"
+  original_time_zone="$(gh-ost-test-mysql-master -e "select @@global.time_zone" -s -s)"
+  echo "time_zone on master is ${original_time_zone}"
 
   echo "Gracefully sleeping for 3 seconds while replica is setting up..."
   sleep 3
@@ -92,6 +97,106 @@ start_replication() {
   done
 }
 
+# start_concurrent_migration starts migrating another table, with the same --replica-server-id, and waits
+# for it to stream binary logs, such that the test's migration finds its server_id in use
+start_concurrent_migration() {
+  local test_name cmd concurrent_table num_attempts
+  test_name="$1"
+  cmd="$2"
+  concurrent_table="$3"
+
+  echo $cmd | sed \
+    -e "s/--table=gh_ost_test /--table=${concurrent_table} /" \
+    -e "s/_gh_ost_test_ghc/_${concurrent_table}_ghc/" \
+    -e "s|/tmp/gh-ost.test.sock|/tmp/gh-ost.test.concurrent.sock|" > $concurrent_exec_command_file
+  bash $concurrent_exec_command_file 1> $concurrent_test_logfile 2>&1 &
+  concurrent_pid=$!
+  num_attempts=0
+  while [ -z "$(gh-ost-test-mysql-replica test -ss -e "show tables like '\_${concurrent_table}\_ghc'")" ] ; do
+    ((num_attempts=num_attempts+1))
+    if [ $num_attempts -gt 60 ] || ! kill -0 $concurrent_pid 2>/dev/null ; then
+      echo
+      echo "ERROR $test_name: concurrent migration of ${concurrent_table} did not start. cat $concurrent_test_logfile"
+      return 1
+    fi
+    echo_dot
+    sleep 1
+  done
+}
+
+# verify_concurrent_migration waits for the concurrent migration to complete, and checksums its table
+verify_concurrent_migration() {
+  local test_name concurrent_table orig_checksum ghost_checksum
+  test_name="$1"
+  concurrent_table="$2"
+
+  if ! wait $concurrent_pid ; then
+    echo
+    echo "ERROR $test_name: concurrent migration of ${concurrent_table} failed. cat $concurrent_test_logfile:"
+    cat $concurrent_test_logfile
+    return 1
+  fi
+  if ! grep -q "is in use by another replication client" $test_logfile $concurrent_test_logfile ; then
+    echo
+    echo "ERROR $test_name: no server_id conflict detected between concurrent migrations. cat $test_logfile $concurrent_test_logfile"
+    return 1
+  fi
+  orig_checksum=$(gh-ost-test-mysql-replica --default-character-set=utf8mb4 test -e "select * from ${concurrent_table}" -ss | md5sum)
+  ghost_checksum=$(gh-ost-test-mysql-replica --default-character-set=utf8mb4 test -e "select * from _${concurrent_table}_gho" -ss | md5sum)
+  if [ "$orig_checksum" != "$ghost_checksum" ] ; then
+    echo
+    echo "ERROR $test_name: concurrent migration of ${concurrent_table} checksum mismatch"
+    return 1
+  fi
+}
+
+# interrupt_and_resume kills the migration mid row copy, once it has written a checkpoint, and then
+# resumes it with --resume. The migration's result is that of the resumed run.
+interrupt_and_resume() {
+  local test_name cmd ghost_pid num_attempts orig_rows ghost_rows
+  test_name="$1"
+  cmd="$2"
+
+  bash $exec_command_file 1> $test_logfile 2>&1 &
+  ghost_pid=$!
+  num_attempts=0
+  while [ "$(gh-ost-test-mysql-replica test -ss -e "select count(*) from _gh_ost_test_ghc where hint='checkpoint'" 2>/dev/null)" != "1" ] ; do
+    ((num_attempts=num_attempts+1))
+    if [ $num_attempts -gt 60 ] || ! kill -0 $ghost_pid 2>/dev/null ; then
+      echo
+      echo "ERROR $test_name: no checkpoint written ahead of interruption"
+      pkill -9 -P $ghost_pid ; kill -9 $ghost_pid
+      return 1
+    fi
+    echo_dot
+    sleep 1
+  done
+  # Let row copy advance beyond the first checkpoint
+  sleep 2
+  pkill -9 -P $ghost_pid
+  kill -9 $ghost_pid
+  wait $ghost_pid 2>/dev/null
+
+  orig_rows=$(gh-ost-test-mysql-replica test -ss -e "select count(*) from gh_ost_test")
+  ghost_rows=$(gh-ost-test-mysql-replica test -ss -e "select count(*) from _gh_ost_test_gho")
+  if [ "$ghost_rows" -ge "$orig_rows" ] ; then
+    echo
+    echo "ERROR $test_name: row copy complete ahead of interruption; ${ghost_rows} of ${orig_rows} rows copied"
+    return 1
+  fi
+  echo_dot
+
+  echo "$cmd --resume" | sed -e 's/--initially-drop-ghost-table//' > $exec_command_file
+  bash $exec_command_file 1>> $test_logfile 2>&1
+  execution_result=$?
+  if [ $execution_result -eq 0 ] && ! grep -q "Resuming from checkpoint" $test_logfile ; then
+    echo
+    echo "ERROR $test_name: migration did not resume from checkpoint. cat $test_logfile"
+    return 1
+  fi
+  return $execution_result
+}
+
 test_single() {
   local test_name
   test_name="$1"
@@ -105,6 +210,14 @@ test_single() {
     fi
   fi
 
+  if [ -f $tests_path/$test_name/time_zone ] ; then
+    # Named time zones require the server's time zone tables
+    if [ "$(gh-ost-test-mysql-master -s -s -e "select convert_tz(now(), @@session.time_zone, '$(cat $tests_path/$test_name/time_zone)') is not null")" != "1" ] ; then
+      echo -n "Skipping: $test_name (time zone not loaded)"
+      return 0
+    fi
+  fi
+
   echo -n "Testing: $test_name"
 
   echo_dot
@@ -115,6 +228,13 @@ test_single() {
     gh-ost-test-mysql-master --default-character-set=utf8mb4 test -e "set @@global.sql_mode='$(cat $tests_path/$test_name/sql_mode)'"
     gh-ost-test-mysql-replica --default-character-set=utf8mb4 test -e "set @@global.sql_mode='$(cat $tests_path/$test_name/sql_mode)'"
   fi
+  time_zone_setup=""
+  if [ -f $tests_path/$test_name/time_zone ] ; then
+    # Global time zone is restored right after migration; table contents are compared within the test's time zone
+    time_zone_setup="set session time_zone='$(cat $tests_path/$test_name/time_zone)';"
+    gh-ost-test-mysql-master --default-character-set=utf8mb4 test -e "set @@global.time_zone='$(cat $tests_path/$test_name/time_zone)'"
+    gh-ost-test-mysql-replica --default-character-set=utf8mb4 test -e "set @@global.time_zone='$(cat $tests_path/$test_name/time_zone)'"
+  fi
 
   gh-ost-test-mysql-master --default-character-set=utf8mb4 test < $tests_path/$test_name/create.sql
 
@@ -125,6 +245,7 @@ test_single() {
   orig_columns="*"
   ghost_columns="*"
   order_by=""
+  orig_where=""
   if [ -f $tests_path/$test_name/orig_columns ] ; then
     orig_columns=$(cat $tests_path/$test_name/orig_columns)
   fi
@@ -134,6 +255,9 @@ test_single() {
   if [ -f $tests_path/$test_name/order_by ] ; then
     order_by="order by $(cat $tests_path/$test_name/order_by)"
   fi
+  if [ -f $tests_path/$test_name/orig_where ] ; then
+    orig_where="where $(cat $tests_path/$test_name/orig_where)"
+  fi
   # graceful sleep for replica to catch up
   echo_dot
   sleep 1
@@ -165,14 +289,30 @@ test_single() {
   echo_dot
   echo $cmd > $exec_command_file
   echo_dot
-  bash $exec_command_file 1> $test_logfile 2>&1
+  concurrent_table=""
+  if [ -f $tests_path/$test_name/concurrent_table ] ; then
+    concurrent_table=$(cat $tests_path/$test_name/concurrent_table)
+    start_concurrent_migration "$test_name" "$cmd" "$concurrent_table" || return 1
+  fi
+  if [ -f $tests_path/$test_name/interrupt_resume ] ; then
+    interrupt_and_resume "$test_name" "$cmd"
+  else
+    bash $exec_command_file 1> $test_logfile 2>&1
+  fi
 
   execution_result=$?
+  if [ -n "$concurrent_table" ] ; then
+    verify_concurrent_migration "$test_name" "$concurrent_table" || return 1
+  fi
 
   if [ -f $tests_path/$test_name/sql_mode ] ; then
     gh-ost-test-mysql-master --default-character-set=utf8mb4 test -e "set @@global.sql_mode='${original_sql_mode}'"
     gh-ost-test-mysql-replica --default-character-set=utf8mb4 test -e "set @@global.sql_mode='${original_sql_mode}'"
   fi
+  if [ -f $tests_path/$test_name/time_zone ] ; then
+    gh-ost-test-mysql-master --default-character-set=utf8mb4 test -e "set @@global.time_zone='${original_time_zone}'"
+    gh-ost-test-mysql-replica --default-character-set=utf8mb4 test -e "set @@global.time_zone='${original_time_zone}'"
+  fi
 
   if [ -f $tests_path/$test_name/destroy.sql ] ; then
     gh-ost-test-mysql-master --default-character-set=utf8mb4 test < $tests_path/$test_name/destroy.sql
@@ -218,14 +358,14 @@ test_single() {
   fi
 
   echo_dot
-  gh-ost-test-mysql-replica --default-character-set=utf8mb4 test -e "select ${orig_columns} from gh_ost_test ${order_by}" -ss > $orig_content_output_file
-  gh-ost-test-mysql-replica --default-character-set=utf8mb4 test -e "select ${ghost_columns} from _gh_ost_test_gho ${order_by}" -ss > $ghost_content_output_file
+  gh-ost-test-mysql-replica --default-character-set=utf8mb4 test -e "${time_zone_setup} select ${orig_columns} from gh_ost_test ${orig_where} ${order_by}" -ss > $orig_content_output_file
+  gh-ost-test-mysql-replica --default-character-set=utf8mb4 test -e "${time_zone_setup} select ${ghost_columns} from _gh_ost_test_gho ${order_by}" -ss > $ghost_content_output_file
   orig_checksum=$(cat $orig_content_output_file | md5sum)
   ghost_checksum=$(cat $ghost_content_output_file | md5sum)
 
   if [ "$orig_checksum" != "$ghost_checksum" ] ; then
-    gh-ost-test-mysql-replica --default-character-set=utf8mb4 test -e "select ${orig_columns} from gh_ost_test" -ss > $orig_content_output_file
-    gh-ost-test-mysql-replica --default-character-set=utf8mb4 test -e "select ${ghost_columns} from _gh_ost_test_gho" -ss > $ghost_content_output_file
+    gh-ost-test-mysql-replica --default-character-set=utf8mb4 test -e "${time_zone_setup} select ${orig_columns} from gh_ost_test ${orig_where}" -ss > $orig_content_output_file
+    gh-ost-test-mysql-replica --default-character-set=utf8mb4 test -e "${time_zone_setup} select ${ghost_columns} from _gh_ost_test_gho" -ss > $ghost_content_output_file
     echo "ERROR $test_name: checksum mismatch"
     echo "---"
     diff $orig_content_output_file $ghost_content_output_file
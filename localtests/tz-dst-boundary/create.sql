@@ -0,0 +1,47 @@
+drop table if exists gh_ost_test;
+create table gh_ost_test (
+  id int auto_increment,
+  i int not null,
+  ts timestamp not null,
+  dt datetime null,
+  t timestamp null,
+  updated tinyint unsigned default 0,
+  primary key(ts, id),
+  key id_idx(id)
+) auto_increment=1;
+
+-- Europe/Berlin turns clocks back on 2021-10-31 at 01:00 UTC: 02:00-03:00 local time happens twice.
+-- Values are written in UTC so as to cover both occurrences, which only differ by their UTC offset.
+set session time_zone='+00:00';
+insert into gh_ost_test values (null, 1, '2021-10-30 23:50:00', '2021-10-31 01:59:59', '2021-10-31 00:59:59', 0);
+insert into gh_ost_test values (null, 2, '2021-10-31 00:00:00', '2021-10-31 03:00:00', '2021-10-31 01:00:00', 0);
+insert into gh_ost_test values (null, 3, '2021-10-31 00:30:00', '2021-10-31 03:00:01', '2021-10-31 01:30:00', 0);
+insert into gh_ost_test values (null, 4, '2021-10-31 00:59:59', '2021-03-28 01:59:59', '2021-03-28 00:59:59', 0);
+insert into gh_ost_test values (null, 5, '2021-10-31 01:00:00', '2021-03-28 03:00:00', '2021-03-28 01:00:00', 0);
+insert into gh_ost_test values (null, 6, '2021-10-31 01:30:00', '2010-10-20 10:20:30', '2021-10-31 00:30:00', 0);
+insert into gh_ost_test values (null, 7, '2021-10-31 01:59:59', '2010-10-20 10:20:30', '2021-10-31 01:59:59', 0);
+insert into gh_ost_test values (null, 8, '2021-10-31 02:00:00', '2010-10-20 10:20:30', null, 0);
+insert into gh_ost_test select null, i + 8, ts + interval 1 second, dt, t, 0 from gh_ost_test;
+insert into gh_ost_test select null, i + 16, ts + interval 2 second, dt, t, 0 from gh_ost_test;
+insert into gh_ost_test select null, i + 32, ts + interval 5 second, dt, t, 0 from gh_ost_test;
+
+drop event if exists gh_ost_test;
+delimiter ;;
+create event gh_ost_test
+  on schedule every 1 second
+  starts current_timestamp
+  ends current_timestamp + interval 60 second
+  on completion not preserve
+  enable
+  do
+begin
+  insert into gh_ost_test values (null, 100, now(), now(), now(), 0);
+  update gh_ost_test set dt=now() + interval 1 minute, t=now() + interval 1 minute, updated = 1 where i = 100 order by id desc limit 1;
+
+  set session time_zone='+00:00';
+  insert into gh_ost_test values (null, 101, '2021-10-31 00:45:00', '2021-10-31 02:45:00', '2021-10-31 00:45:00', 0);
+  insert into gh_ost_test values (null, 102, '2021-10-31 01:45:00', '2021-10-31 03:45:00', '2021-10-31 01:45:00', 0);
+  update gh_ost_test set t='2021-10-31 01:15:00', updated = 1 where i = 101 order by id desc limit 1;
+  update gh_ost_test set t='2021-10-31 00:15:00', updated = 1 where i = 102 order by id desc limit 1;
+  delete from gh_ost_test where i = 3 order by id desc limit 1;
+end ;;
@@ -0,0 +1,24 @@
+drop table if exists gh_ost_test;
+create table gh_ost_test (
+  id int auto_increment,
+  i int not null,
+  name varchar(32) charset latin1,
+  dt datetime,
+  primary key(id)
+) auto_increment=1;
+
+drop event if exists gh_ost_test;
+delimiter ;;
+create event gh_ost_test
+  on schedule every 1 second
+  starts current_timestamp
+  ends current_timestamp + interval 60 second
+  on completion not preserve
+  enable
+  do
+begin
+  insert into gh_ost_test values (null, 11, 'blue', now());
+  insert into gh_ost_test values (null, 13, 'green', '2010-10-20 10:20:30');
+  update gh_ost_test set name='red' where i = 11 order by id desc limit 1;
+  delete from gh_ost_test where i = 13 order by id desc limit 1;
+end ;;